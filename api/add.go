@@ -19,6 +19,10 @@ type AddResult struct{}
 // Add only returns an error status, since adding an article doesn't have
 // any other meaningful return value.
 func (c *Client) Add(options *AddOption) error {
+	if ReadOnly {
+		return ErrReadOnly
+	}
+
 	data := addAPIOptionWithAuth{
 		authInfo:  c.authInfo,
 		AddOption: options,
@@ -29,6 +33,10 @@ func (c *Client) Add(options *AddOption) error {
 	if err != nil {
 		return nil
 	}
+	if OnModify != nil {
+		action := &Action{Action: "add", URL: options.URL, Title: options.Title, Tags: options.Tags}
+		OnModify(c, []*Action{action}, []ActionResult{{Success: true}})
+	}
 
 	return err
 }
@@ -1,17 +1,49 @@
+// Package api is a client of the Pocket API: retrieving the items of an
+// account, adding items, and changing them in bulk with actions.
+//
+// The package follows semantic versioning from v1: its API is only ever
+// added to until a v2, except for the identifiers documented as
+// Experimental, which may change in a minor release. See the Compatibility
+// section of the README.
 package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Origin is the constant origin URL for the Pocket API
 var Origin = "https://getpocket.com"
 
+// Transport is the transport of DefaultClient. It keeps connections to the
+// API open between requests, more of them than http.DefaultTransport, as
+// bulk commands make many small requests and several at a time; a new
+// connection for each would take a TLS handshake each.
+var Transport = newTransport()
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = 16
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
 // DefaultClient is the client used for making all requests
-var DefaultClient = http.DefaultClient
+var DefaultClient = &http.Client{Transport: Transport}
 
 // Client represents a Pocket client that grants OAuth access to your application
 type Client struct {
@@ -33,41 +65,266 @@ func NewClient(consumerKey, accessToken string) *Client {
 	}
 }
 
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	// Code and Message are the X-Error-Code and X-Error headers.
+	Code    string
+	Message string
+	// Header holds the rate limit headers, among others.
+	Header http.Header
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("got response %d; X-Error=%q; X-Error-Code=%q; X-Limit-User-Limit=%q; X-Limit-User-Remaining=%q; X-Limit-User-Reset=%q; X-Limit-Key-Limit=%q; X-Limit-Key-Remaining=%q; X-Limit-Key-Reset=%q",
+		e.StatusCode,
+		e.Message,
+		e.Code,
+		e.Header.Get("X-Limit-User-Limit"),
+		e.Header.Get("X-Limit-User-Remaining"),
+		e.Header.Get("X-Limit-User-Reset"),
+		e.Header.Get("X-Limit-Key-Limit"),
+		e.Header.Get("X-Limit-Key-Remaining"),
+		e.Header.Get("X-Limit-Key-Reset"),
+	)
+}
+
+// PageError is the error of a response that is a web page rather than JSON,
+// as when Pocket is down for maintenance or a Cloudflare challenge stands in
+// front of the API. It is transient, unlike the errors of the API itself.
+type PageError struct {
+	StatusCode  int
+	ContentType string
+	// Title is the title of the page, if it has one.
+	Title string
+}
+
+func (e *PageError) Error() string {
+	page := "a web page"
+	if e.Title != "" {
+		page = fmt.Sprintf("a web page (%q)", e.Title)
+	}
+	return fmt.Sprintf("Pocket answered with %s instead of JSON, status %d: it is likely down for maintenance or asking for a browser check; try again later, or from another network if this persists",
+		page, e.StatusCode)
+}
+
+// isPage tells if a response with header and body is a web page.
+func isPage(header http.Header, body []byte) bool {
+	if strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+		return true
+	}
+	body = bytes.TrimSpace(body)
+	return len(body) > 0 && body[0] == '<'
+}
+
+var pageTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+func newPageError(resp *http.Response, body []byte) *PageError {
+	e := &PageError{StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type")}
+	if m := pageTitle.FindSubmatch(body); m != nil {
+		e.Title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	}
+	return e
+}
+
+// RetryAfter returns how long to wait before retrying, from the Retry-After
+// header or the reset time of an exhausted rate limit, or zero if unknown.
+func (e *Error) RetryAfter() time.Duration {
+	if seconds, err := strconv.Atoi(e.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	var wait time.Duration
+	for _, limit := range []string{"User", "Key"} {
+		if e.Header.Get("X-Limit-"+limit+"-Remaining") != "0" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(e.Header.Get("X-Limit-" + limit + "-Reset")); err == nil {
+			wait = max(wait, time.Duration(seconds)*time.Second)
+		}
+	}
+	return wait
+}
+
+// Usage is the use made of the API by this process.
+type Usage struct {
+	// Requests is the number of requests made.
+	Requests int
+	// UserRemaining and KeyRemaining are the requests left within the rate
+	// limits of the user and of the consumer key, as of the last response
+	// telling them, or -1 if none has.
+	UserRemaining int
+	KeyRemaining  int
+	// UserReset and KeyReset are when those rate limits are reset, or zero
+	// if unknown.
+	UserReset time.Time
+	KeyReset  time.Time
+}
+
+// ReadOnly, if set, makes Add and Modify fail with ErrReadOnly instead of
+// changing the account.
+var ReadOnly bool
+
+// ErrReadOnly is returned by the calls that would change the account while
+// ReadOnly is set.
+var ErrReadOnly = errors.New("refusing to change the account in read-only mode")
+
+// OnModify, if set, is called by Add and Modify with the actions Pocket
+// made and their results, as for keeping a log of the changes to the
+// account. An Add is passed as an "add" action.
+//
+// Experimental: the hooks of the package may become options of Client.
+var OnModify func(c *Client, actions []*Action, results []ActionResult)
+
+// OnRateLimit, if set, is called with the usage after each response telling
+// the rate limits, as for keeping them across processes.
+//
+// Experimental: as OnModify.
+var OnRateLimit func(Usage)
+
+var usage = struct {
+	sync.Mutex
+	Usage
+}{Usage: Usage{UserRemaining: -1, KeyRemaining: -1}}
+
+// CurrentUsage returns the use made of the API so far.
+func CurrentUsage() Usage {
+	usage.Lock()
+	defer usage.Unlock()
+	return usage.Usage
+}
+
+// recordUsage counts a request, with the rate limit headers of its
+// response if there is one.
+func recordUsage(resp *http.Response) {
+	usage.Lock()
+	usage.Requests++
+	limited := false
+	if resp != nil {
+		now := time.Now()
+		limited = readRateLimit(resp.Header, "User", now, &usage.UserRemaining, &usage.UserReset)
+		limited = readRateLimit(resp.Header, "Key", now, &usage.KeyRemaining, &usage.KeyReset) || limited
+	}
+	current := usage.Usage
+	usage.Unlock()
+
+	if limited && OnRateLimit != nil {
+		OnRateLimit(current)
+	}
+}
+
+// readRateLimit reads the remaining requests and reset time of the rate
+// limit named from header, telling if it has them.
+func readRateLimit(header http.Header, limit string, now time.Time, remaining *int, reset *time.Time) bool {
+	n, err := strconv.Atoi(header.Get("X-Limit-" + limit + "-Remaining"))
+	if err != nil {
+		return false
+	}
+	*remaining = n
+	if seconds, err := strconv.Atoi(header.Get("X-Limit-" + limit + "-Reset")); err == nil {
+		*reset = now.Add(time.Duration(seconds) * time.Second)
+	}
+	return true
+}
+
 func doJSON(req *http.Request, res interface{}) error {
 	req.Header.Add("X-Accept", "application/json")
 	req.Header.Add("Content-Type", "application/json")
 
+	reused := false
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+	}))
+	if err := checkGone(req.URL.Path); err != nil {
+		return err
+	}
+	if Breaker != nil {
+		if err := Breaker.allow(); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
 	resp, err := DefaultClient.Do(req)
+	recordUsage(resp)
 	if err != nil {
+		recordOutcome(true)
+		slog.Debug("API request failed", "path", req.URL.Path, "duration", time.Since(start), "err", err)
 		return err
 	}
+	// Logged once the body is read, for the latency of the whole call
+	logRequest := func() {
+		slog.Debug("API request", "path", req.URL.Path, "status", resp.StatusCode, "proto", resp.Proto,
+			"duration", time.Since(start), "reused_connection", reused)
+	}
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("got response %d; X-Error=%q; X-Error-Code=%q; X-Limit-User-Limit=%q; X-Limit-User-Remaining=%q; X-Limit-User-Reset=%q; X-Limit-Key-Limit=%q; X-Limit-Key-Remaining=%q; X-Limit-Key-Reset=%q",
-			resp.StatusCode,
-			resp.Header.Get("X-Error"),
-			resp.Header.Get("X-Error-Code"),
-			resp.Header.Get("X-Limit-User-Limit"),
-			resp.Header.Get("X-Limit-User-Remaining"),
-			resp.Header.Get("X-Limit-User-Reset"),
-			resp.Header.Get("X-Limit-Key-Limit"),
-			resp.Header.Get("X-Limit-Key-Remaining"),
-			resp.Header.Get("X-Limit-Key-Reset"),
-		)
+		// Read out for the connection to be reused, and to tell a page
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		logRequest()
+		if isGone(resp) {
+			recordOutcome(false)
+			return newGoneError(resp)
+		}
+		if resp.Header.Get("X-Error") == "" && isPage(resp.Header, body) {
+			recordOutcome(true)
+			return newPageError(resp, body)
+		}
+		recordOutcome(resp.StatusCode >= 500)
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Code:       resp.Header.Get("X-Error-Code"),
+			Message:    resp.Header.Get("X-Error"),
+			Header:     resp.Header,
+		}
 	}
 
 	defer resp.Body.Close()
-	return json.NewDecoder(resp.Body).Decode(res)
+
+	// The body is read whole into a buffer kept for the next response, as
+	// a json.Decoder grows a buffer of its own for each, copying a long
+	// list several times over
+	buf := responseBuffers.Get().(*bytes.Buffer)
+	defer responseBuffers.Put(buf)
+	buf.Reset()
+	if resp.ContentLength > 0 {
+		buf.Grow(int(resp.ContentLength))
+	}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		recordOutcome(true)
+		return err
+	}
+	logRequest()
+	if isPage(resp.Header, buf.Bytes()) {
+		recordOutcome(true)
+		return newPageError(resp, buf.Bytes())
+	}
+	recordOutcome(false)
+	return json.Unmarshal(buf.Bytes(), res)
+}
+
+// responseBuffers are the buffers doJSON reads responses into.
+var responseBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
 }
 
 // PostJSON posts the data to the API endpoint, storing the result in res.
 func PostJSON(action string, data, res interface{}) error {
+	return PostJSONContext(context.Background(), action, data, res)
+}
+
+// PostJSONContext is like PostJSON, with the request bound to ctx.
+func PostJSONContext(ctx context.Context, action string, data, res interface{}) error {
+	return postJSONTo(ctx, Origin+action, data, res)
+}
+
+func postJSONTo(ctx context.Context, url string, data, res interface{}) error {
 	body, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", Origin+action, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,168 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	. "github.com/onsi/gomega"
+)
+
+func TestPageError(t *testing.T) {
+	RegisterTestingT(t)
+
+	var status int
+	var contentType, xError, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Error", xError)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	origin := api.Origin
+	api.Origin = ts.URL
+	defer func() { api.Origin = origin }()
+	client := &api.Client{}
+
+	status, contentType = 503, "text/html; charset=UTF-8"
+	body = "<!DOCTYPE html><html><head><title>Pocket is down\n  for maintenance &amp; upgrades</title></head></html>"
+	_, err := client.Retrieve(&api.RetrieveOption{})
+	var pageErr *api.PageError
+	Expect(errors.As(err, &pageErr)).To(BeTrue())
+	Expect(pageErr.StatusCode).To(Equal(503))
+	Expect(pageErr.Title).To(Equal("Pocket is down for maintenance & upgrades"))
+	Expect(err.Error()).To(ContainSubstring("try again later"))
+
+	// A page sent as a success, without a content type to tell it by
+	status, contentType = 200, ""
+	body = "\n<html><body>Checking your browser</body></html>"
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &pageErr)).To(BeTrue())
+	Expect(pageErr.StatusCode).To(Equal(200))
+	Expect(pageErr.Title).To(Equal(""))
+
+	// Errors of the API itself stay as they are
+	status, contentType, xError = 401, "text/html", "Invalid access token"
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	var apiErr *api.Error
+	Expect(errors.As(err, &apiErr)).To(BeTrue())
+	Expect(apiErr.Message).To(Equal("Invalid access token"))
+}
+
+func TestReadOnly(t *testing.T) {
+	RegisterTestingT(t)
+
+	paths := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": 1, "list": {}}`))
+	}))
+	defer ts.Close()
+	origin := api.Origin
+	api.Origin = ts.URL
+	defer func() { api.Origin = origin }()
+	api.ReadOnly = true
+	defer func() { api.ReadOnly = false }()
+	client := &api.Client{}
+
+	Expect(client.Add(&api.AddOption{URL: "https://example.com/"})).To(Equal(api.ErrReadOnly))
+	_, err := client.Modify(api.NewArchiveAction(1))
+	Expect(err).To(Equal(api.ErrReadOnly))
+	Expect(paths).To(BeEmpty())
+
+	// Reading is still allowed
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(err).To(BeNil())
+	Expect(paths).To(Equal([]string{"/v3/get"}))
+}
+
+func TestOnModify(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": 1, "action_results": [true, false, {"item_id": "7", "title": "Example Domain"}]}`))
+	}))
+	defer ts.Close()
+	origin := api.Origin
+	api.Origin = ts.URL
+	defer func() { api.Origin = origin }()
+
+	var made []*api.Action
+	var results []api.ActionResult
+	api.OnModify = func(c *api.Client, actions []*api.Action, res []api.ActionResult) {
+		made, results = append(made, actions...), append(results, res...)
+	}
+	defer func() { api.OnModify = nil }()
+	client := &api.Client{}
+
+	// Only the actions made are passed
+	archive, fail, add := api.NewArchiveAction(1), api.NewArchiveAction(2), api.NewAddAction("https://example.com/", "")
+	_, err := client.Modify(archive, fail, add)
+	Expect(err).To(BeNil())
+	Expect(made).To(Equal([]*api.Action{archive, add}))
+	Expect(results[1].ItemID).To(Equal(7))
+	Expect(results[1].Title).To(Equal("Example Domain"))
+}
+
+func TestActionLog(t *testing.T) {
+	RegisterTestingT(t)
+
+	var sent struct {
+		Actions []json.RawMessage `json:"actions"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Expect(json.NewDecoder(r.Body).Decode(&sent)).To(Succeed())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": 1, "action_results": [true, true]}`))
+	}))
+	defer ts.Close()
+	origin := api.Origin
+	api.Origin = ts.URL
+	defer func() { api.Origin = origin }()
+
+	var log bytes.Buffer
+	api.ActionLog = &log
+	defer func() { api.ActionLog = nil }()
+	client := &api.Client{}
+
+	actions := []*api.Action{api.NewArchiveAction(1), api.NewTagsAddAction(2, "go", "web")}
+	actions[0].Time = 1700000000
+	_, err := client.Modify(actions...)
+	Expect(err).To(BeNil())
+
+	// The lines are the actions as sent
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	Expect(lines).To(HaveLen(2))
+	for i, line := range lines {
+		Expect(line).To(MatchJSON(sent.Actions[i]))
+	}
+
+	read, err := api.ReadActions(strings.NewReader(log.String() + "\n"))
+	Expect(err).To(BeNil())
+	Expect(read).To(Equal(actions))
+
+	_, err = api.ReadActions(strings.NewReader(`{"action": "archive", "item": 1}`))
+	Expect(err).To(MatchError(ContainSubstring("line 1")))
+}
+
+func TestAddAnnotationAction(t *testing.T) {
+	RegisterTestingT(t)
+
+	action := api.NewAddAnnotationAction(2, "A passage")
+	b, err := json.Marshal(action)
+	Expect(err).To(BeNil())
+	Expect(b).To(MatchJSON(`{"action": "add_annotation", "item_id": "2", "annotation": {"quote": "A passage"}}`))
+
+	// Logged and read back as the other actions
+	read, err := api.ReadActions(bytes.NewReader(b))
+	Expect(err).To(BeNil())
+	Expect(read).To(Equal([]*api.Action{action}))
+}
@@ -0,0 +1,48 @@
+package api
+
+import "context"
+
+// ArticleOrigin is the origin URL for the Article View API, which parses the
+// readable text out of a web page.
+//
+// Experimental: the Article View API is not documented by Pocket, and may
+// change or go away with it.
+var ArticleOrigin = "https://text.getpocket.com"
+
+// Article is the parsed content of a web page.
+//
+// Experimental: as ArticleOrigin.
+type Article struct {
+	Title       string `json:"title"`
+	ResolvedURL string `json:"resolvedUrl"`
+	Excerpt     string `json:"excerpt"`
+	// HTML is the article body as an HTML fragment.
+	HTML string `json:"article"`
+}
+
+type articleAPIOption struct {
+	ConsumerKey string `json:"consumer_key"`
+	URL         string `json:"url"`
+	Images      int    `json:"images"`
+	Output      string `json:"output"`
+}
+
+// Article fetches the parsed article text for url, with images inlined.
+//
+// Experimental: as ArticleOrigin.
+func (c *Client) Article(url string) (*Article, error) {
+	data := articleAPIOption{
+		ConsumerKey: c.ConsumerKey,
+		URL:         url,
+		Images:      1,
+		Output:      "json",
+	}
+
+	res := &Article{}
+	err := postJSONTo(context.Background(), ArticleOrigin+"/v3/text", data, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops requests to the API for a while after a run of
+// failures of the network or of the server, so that clients do not keep
+// hammering Pocket while it is down.
+//
+// Experimental: the thresholds and the errors returned may change in a
+// minor release.
+type CircuitBreaker struct {
+	// Threshold is the number of failures in a row that opens the breaker.
+	Threshold int
+	// Cooldown is how long requests fail without being made once it is
+	// open. The first request after that is made; if it fails too, the
+	// breaker opens again.
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// Breaker, if set, guards every request to the API. It is nil by default.
+//
+// Experimental: as CircuitBreaker.
+var Breaker *CircuitBreaker
+
+// UnavailableError is the error of the requests not made while Breaker is
+// open.
+//
+// Experimental: as CircuitBreaker.
+type UnavailableError struct {
+	// Failures is the number of requests failed in a row.
+	Failures int
+	// Until is when requests are made again.
+	Until time.Time
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("Pocket appears down after %d failed requests in a row, backing off until %s",
+		e.Failures, e.Until.Local().Format("15:04:05"))
+}
+
+// allow returns an *UnavailableError while the breaker is open.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Now().Before(b.openUntil) {
+		return &UnavailableError{Failures: b.failures, Until: b.openUntil}
+	}
+	return nil
+}
+
+// record counts the outcome of a request, opening the breaker once
+// Threshold requests have failed in a row.
+func (b *CircuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= max(b.Threshold, 1) {
+		b.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// recordOutcome counts the outcome of a request in Breaker, if set.
+func recordOutcome(failed bool) {
+	if Breaker != nil {
+		Breaker.record(failed)
+	}
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	. "github.com/onsi/gomega"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	RegisterTestingT(t)
+
+	var requests atomic.Int32
+	var down atomic.Bool
+	down.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":1,"list":[]}`))
+	}))
+	defer ts.Close()
+	origin := api.Origin
+	api.Origin = ts.URL
+	api.Breaker = &api.CircuitBreaker{Threshold: 2, Cooldown: 100 * time.Millisecond}
+	defer func() { api.Origin, api.Breaker = origin, nil }()
+
+	client := &api.Client{}
+	var apiErr *api.Error
+	for i := 0; i < 2; i++ {
+		_, err := client.Retrieve(&api.RetrieveOption{})
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(apiErr.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	}
+
+	// Open: requests fail without being made
+	_, err := client.Retrieve(&api.RetrieveOption{})
+	var downErr *api.UnavailableError
+	Expect(errors.As(err, &downErr)).To(BeTrue())
+	Expect(downErr.Failures).To(Equal(2))
+	Expect(downErr.Error()).To(ContainSubstring("Pocket appears down"))
+	Expect(requests.Load()).To(Equal(int32(2)))
+
+	// After the cooldown, one more failure opens it again
+	time.Sleep(150 * time.Millisecond)
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &apiErr)).To(BeTrue())
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &downErr)).To(BeTrue())
+	Expect(downErr.Failures).To(Equal(3))
+	Expect(requests.Load()).To(Equal(int32(3)))
+
+	// A success closes it
+	time.Sleep(150 * time.Millisecond)
+	down.Store(false)
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(err).To(BeNil())
+	down.Store(true)
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &apiErr)).To(BeTrue())
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &apiErr)).To(BeTrue())
+	Expect(requests.Load()).To(Equal(int32(6)))
+}
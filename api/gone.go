@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GoneRetry is how long requests to an endpoint found gone fail without
+// being made, before one is tried again in case Pocket serves it anew.
+//
+// Experimental: as GoneError.
+var GoneRetry = 24 * time.Hour
+
+// OnGone, if set, is called with the path of an endpoint, as in "/v3/get",
+// when Pocket first answers that it no longer serves it.
+//
+// Experimental: as GoneError.
+var OnGone func(endpoint string)
+
+// GoneError is the error of a request to an endpoint Pocket no longer
+// serves: one answered with 410 Gone, or with 404 Not Found lacking the
+// X-Error header the API sets on its own errors.
+//
+// Experimental: Pocket has not said how it retires endpoints, so what
+// counts as gone may change as it does.
+type GoneError struct {
+	// Endpoint is the path requested, as in "/v3/get".
+	Endpoint string
+	// StatusCode is the status answered, or 0 if the request was not made
+	// as the endpoint was already known to be gone.
+	StatusCode int
+	// Since is when the endpoint was found gone.
+	Since time.Time
+}
+
+func (e *GoneError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("Pocket no longer serves %s (since %s)", e.Endpoint, e.Since.Local().Format("2006-01-02 15:04"))
+	}
+	return fmt.Sprintf("Pocket no longer serves %s (%d %s)", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// gone holds when each endpoint found gone was.
+var gone = struct {
+	sync.Mutex
+	since map[string]time.Time
+}{since: map[string]time.Time{}}
+
+// MarkGone records that endpoint was found gone at since, as by an earlier
+// run, so that requests to it fail with a *GoneError until GoneRetry has
+// passed.
+//
+// Experimental: as GoneError.
+func MarkGone(endpoint string, since time.Time) {
+	gone.Lock()
+	defer gone.Unlock()
+	gone.since[endpoint] = since
+}
+
+// checkGone returns a *GoneError if endpoint was found gone less than
+// GoneRetry ago.
+func checkGone(endpoint string) error {
+	gone.Lock()
+	defer gone.Unlock()
+	since, ok := gone.since[endpoint]
+	if !ok {
+		return nil
+	}
+	if time.Since(since) >= GoneRetry {
+		delete(gone.since, endpoint)
+		return nil
+	}
+	return &GoneError{Endpoint: endpoint, Since: since}
+}
+
+// isGone tells if resp, not 200, says that its endpoint is no longer served.
+func isGone(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusGone ||
+		resp.StatusCode == http.StatusNotFound && resp.Header.Get("X-Error") == ""
+}
+
+// newGoneError records the endpoint of resp gone and returns its error.
+func newGoneError(resp *http.Response) error {
+	endpoint := resp.Request.URL.Path
+	now := time.Now()
+	MarkGone(endpoint, now)
+	if OnGone != nil {
+		OnGone(endpoint)
+	}
+	return &GoneError{Endpoint: endpoint, StatusCode: resp.StatusCode, Since: now}
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	. "github.com/onsi/gomega"
+)
+
+func TestGone(t *testing.T) {
+	RegisterTestingT(t)
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/v3/get":
+			w.WriteHeader(http.StatusGone)
+		case "/v3/send":
+			// An error of the API, not a missing endpoint
+			w.Header().Set("X-Error", "Item not found")
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	origin, articleOrigin, retry := api.Origin, api.ArticleOrigin, api.GoneRetry
+	api.Origin, api.ArticleOrigin = ts.URL, ts.URL
+	var found []string
+	api.OnGone = func(endpoint string) { found = append(found, endpoint) }
+	defer func() {
+		api.Origin, api.ArticleOrigin, api.GoneRetry, api.OnGone = origin, articleOrigin, retry, nil
+		// Found gone long ago, for the other tests to make requests
+		for _, endpoint := range []string{"/v3/get", "/v3/text", "/v3/send"} {
+			api.MarkGone(endpoint, time.Time{})
+		}
+	}()
+
+	client := &api.Client{}
+	_, err := client.Retrieve(&api.RetrieveOption{})
+	var goneErr *api.GoneError
+	Expect(errors.As(err, &goneErr)).To(BeTrue())
+	Expect(goneErr.Endpoint).To(Equal("/v3/get"))
+	Expect(goneErr.StatusCode).To(Equal(http.StatusGone))
+	Expect(err.Error()).To(Equal("Pocket no longer serves /v3/get (410 Gone)"))
+	Expect(found).To(Equal([]string{"/v3/get"}))
+
+	// Known gone: fails without a request, and without calling OnGone again
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &goneErr)).To(BeTrue())
+	Expect(goneErr.StatusCode).To(Equal(0))
+	Expect(requests.Load()).To(Equal(int32(1)))
+	Expect(found).To(HaveLen(1))
+
+	// A 404 with X-Error is an error of the API
+	_, err = client.Modify(&api.Action{Action: "archive", ItemID: 1})
+	var apiErr *api.Error
+	Expect(errors.As(err, &apiErr)).To(BeTrue())
+	Expect(errors.As(err, &goneErr)).To(BeFalse())
+
+	// A 404 without one is a missing endpoint
+	_, err = client.Article("https://example.com/")
+	Expect(errors.As(err, &goneErr)).To(BeTrue())
+	Expect(goneErr.Endpoint).To(Equal("/v3/text"))
+
+	// Tried again once GoneRetry has passed
+	api.GoneRetry = 0
+	_, err = client.Retrieve(&api.RetrieveOption{})
+	Expect(errors.As(err, &goneErr)).To(BeTrue())
+	Expect(goneErr.StatusCode).To(Equal(http.StatusGone))
+	Expect(requests.Load()).To(Equal(int32(4)))
+
+	// Marked gone by an earlier run
+	api.GoneRetry = time.Hour
+	api.MarkGone("/v3/send", time.Now().Add(-time.Minute))
+	_, err = client.Modify(&api.Action{Action: "archive", ItemID: 1})
+	Expect(errors.As(err, &goneErr)).To(BeTrue())
+	Expect(goneErr.Endpoint).To(Equal("/v3/send"))
+	Expect(requests.Load()).To(Equal(int32(4)))
+}
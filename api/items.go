@@ -0,0 +1,52 @@
+//go:build go1.23
+
+package api
+
+import (
+	"context"
+	"iter"
+)
+
+// ItemsPageSize is the number of items Items retrieves per request.
+var ItemsPageSize = 500
+
+// Items returns an iterator over the items options select, retrieving them
+// a page at a time as the loop goes on, so that the account need not be
+// held in memory at once nor paged through by hand. options.Offset is where
+// to start, and options.Count, if not zero, the most items to yield. If a
+// request fails, or ctx is done, the error is yielded and the loop ends.
+func (c *Client) Items(ctx context.Context, options *RetrieveOption) iter.Seq2[Item, error] {
+	return func(yield func(Item, error) bool) {
+		page := RetrieveOption{}
+		if options != nil {
+			page = *options
+		}
+		limit := page.Count
+
+		for yielded := 0; limit == 0 || yielded < limit; {
+			if err := ctx.Err(); err != nil {
+				yield(Item{}, err)
+				return
+			}
+			page.Count = ItemsPageSize
+			if limit > 0 {
+				page.Count = min(page.Count, limit-yielded)
+			}
+			res, err := c.RetrieveContext(ctx, &page)
+			if err != nil {
+				yield(Item{}, err)
+				return
+			}
+			for _, item := range res.Items() {
+				if !yield(item, nil) {
+					return
+				}
+				yielded++
+			}
+			if len(res.List) < page.Count {
+				return
+			}
+			page.Offset += len(res.List)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//go:build go1.23
+
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/pockettest"
+	. "github.com/onsi/gomega"
+)
+
+func TestItems(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := pockettest.NewServer()
+	defer server.Close()
+	origin, pageSize := api.Origin, api.ItemsPageSize
+	defer func() { api.Origin, api.ItemsPageSize = origin, pageSize }()
+	api.Origin = server.URL
+	api.ItemsPageSize = 3
+	client := api.NewClient(server.ConsumerKey, server.AccessToken)
+	for i := 1; i <= 7; i++ {
+		server.Add(api.Item{GivenURL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	options := &api.RetrieveOption{State: api.StateAll, Sort: api.SortOldest}
+	ctx := context.Background()
+
+	ids := []int{}
+	for item, err := range client.Items(ctx, options) {
+		Expect(err).To(BeNil())
+		ids = append(ids, item.ItemID)
+	}
+	Expect(ids).To(Equal([]int{1, 2, 3, 4, 5, 6, 7}))
+	Expect(server.Requests("/v3/get")).To(Equal(3))
+
+	// Pages are only retrieved as the loop goes on
+	ids = []int{}
+	for item, err := range client.Items(ctx, options) {
+		Expect(err).To(BeNil())
+		ids = append(ids, item.ItemID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	Expect(ids).To(Equal([]int{1, 2}))
+	Expect(server.Requests("/v3/get")).To(Equal(4))
+
+	// Offset and Count say where to start and how many to yield
+	ids = []int{}
+	for item, err := range client.Items(ctx, &api.RetrieveOption{State: api.StateAll, Sort: api.SortOldest, Offset: 2, Count: 4}) {
+		Expect(err).To(BeNil())
+		ids = append(ids, item.ItemID)
+	}
+	Expect(ids).To(Equal([]int{3, 4, 5, 6}))
+
+	// Errors end the loop
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	errs := 0
+	for _, err := range client.Items(canceled, options) {
+		Expect(err).To(MatchError(context.Canceled))
+		errs++
+	}
+	Expect(errs).To(Equal(1))
+}
@@ -1,11 +1,41 @@
 package api
 
-import "log"
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
 
 // Action represents one action in a bulk modify requests.
 type Action struct {
 	Action string `json:"action"`
-	ItemID int    `json:"item_id,string"`
+	ItemID int    `json:"item_id,string,omitempty"`
+
+	// Time is the Unix time the action occurred at. Pocket uses the current
+	// time when it is zero.
+	Time int64 `json:"time,string,omitempty"`
+
+	// Fields for the "add" and tag actions
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+	Tags  string `json:"tags,omitempty"`
+
+	// Annotation is the highlight made by the "add_annotation" action.
+	//
+	// Experimental: as NewAddAnnotationAction.
+	Annotation *ActionAnnotation `json:"annotation,omitempty"`
+}
+
+// ActionAnnotation is the highlight an "add_annotation" action makes.
+//
+// Experimental: as NewAddAnnotationAction.
+type ActionAnnotation struct {
+	// Quote is the passage highlighted, as in the article text.
+	Quote string `json:"quote"`
 }
 
 // NewArchiveAction creates an archive action.
@@ -24,12 +54,122 @@ func NewDeleteAction(itemID int) *Action {
 	}
 }
 
+// NewReaddAction creates a readd action, which moves an archived item back
+// to the list of unread items.
+func NewReaddAction(itemID int) *Action {
+	return &Action{
+		Action: "readd",
+		ItemID: itemID,
+	}
+}
+
+// NewFavoriteAction creates a favorite action.
+func NewFavoriteAction(itemID int) *Action {
+	return &Action{
+		Action: "favorite",
+		ItemID: itemID,
+	}
+}
+
+// NewUnfavoriteAction creates an unfavorite action.
+func NewUnfavoriteAction(itemID int) *Action {
+	return &Action{
+		Action: "unfavorite",
+		ItemID: itemID,
+	}
+}
+
+// NewAddAction creates an add action for a new URL.
+func NewAddAction(url, title string, tags ...string) *Action {
+	return &Action{
+		Action: "add",
+		URL:    url,
+		Title:  title,
+		Tags:   strings.Join(tags, ","),
+	}
+}
+
+// NewTagsAddAction creates an action adding tags to an item.
+func NewTagsAddAction(itemID int, tags ...string) *Action {
+	return &Action{
+		Action: "tags_add",
+		ItemID: itemID,
+		Tags:   strings.Join(tags, ","),
+	}
+}
+
+// NewTagsRemoveAction creates an action removing tags from an item.
+func NewTagsRemoveAction(itemID int, tags ...string) *Action {
+	return &Action{
+		Action: "tags_remove",
+		ItemID: itemID,
+		Tags:   strings.Join(tags, ","),
+	}
+}
+
+// NewAddAnnotationAction creates an action highlighting quote in an item.
+//
+// Experimental: creating highlights is not documented by Pocket, which
+// takes it from its own apps and may refuse it from others, failing the
+// action.
+func NewAddAnnotationAction(itemID int, quote string) *Action {
+	return &Action{
+		Action:     "add_annotation",
+		ItemID:     itemID,
+		Annotation: &ActionAnnotation{Quote: quote},
+	}
+}
+
+// ActionResult is the result of one action. Most actions only report
+// success, but a successful "add" action also reports the added item.
+type ActionResult struct {
+	Success bool
+
+	// ItemID is the ID of the added item for "add" actions.
+	ItemID int
+	// Title is the title Pocket found for the added item of "add" actions,
+	// if it did yet.
+	Title string `json:",omitempty"`
+
+	// Error is why the action failed, from the action_errors of the
+	// response, if Pocket said.
+	Error string `json:",omitempty"`
+}
+
+// UnmarshalJSON decodes either a boolean or an added item object.
+func (r *ActionResult) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &r.Success); err == nil {
+		return nil
+	}
+
+	var item struct {
+		ItemID        int    `json:"item_id,string"`
+		Title         string `json:"title"`
+		ResolvedTitle string `json:"resolved_title"`
+	}
+	if err := json.Unmarshal(b, &item); err != nil {
+		return err
+	}
+
+	r.Success = true
+	r.ItemID = item.ItemID
+	r.Title = item.Title
+	if r.Title == "" {
+		r.Title = item.ResolvedTitle
+	}
+
+	return nil
+}
+
 // ModifyResult represents the modify API's result.
 type ModifyResult struct {
 	// The results for each of the requested actions.
-	ActionResults []bool        `json:"action_results"`
-	ActionErrors  []interface{} `json:"action_errors"`
-	Status        int           `json:"status"`
+	ActionResults []bool `json:"-"`
+	// ActionDetails are the results of ActionResults in detail: the items
+	// added and why actions failed.
+	ActionDetails []ActionResult `json:"action_results"`
+	ActionErrors  []interface{}  `json:"action_errors"`
+	Status        int            `json:"status"`
 }
 
 type modifyAPIOptionsWithAuth struct {
@@ -37,8 +177,63 @@ type modifyAPIOptionsWithAuth struct {
 	authInfo
 }
 
+// ActionLog, if set, has Modify write the actions it sends to it, one per
+// line, as the JSON of the request, so that they can be reviewed or sent
+// again after being read back with ReadActions.
+var ActionLog io.Writer
+
+var actionLogMu sync.Mutex
+
+// logActions writes actions to ActionLog in the order sent.
+func logActions(actions []*Action) error {
+	if ActionLog == nil {
+		return nil
+	}
+	actionLogMu.Lock()
+	defer actionLogMu.Unlock()
+	for _, a := range actions {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := ActionLog.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("could not log actions: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadActions reads the actions written to ActionLog, skipping blank lines,
+// to send them again with Modify.
+func ReadActions(r io.Reader) ([]*Action, error) {
+	actions := []*Action{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		a := &Action{}
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(a); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, scanner.Err()
+}
+
 // Modify requests bulk modification on items.
 func (c *Client) Modify(actions ...*Action) (*ModifyResult, error) {
+	if ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if err := logActions(actions); err != nil {
+		return nil, err
+	}
+
 	res := &ModifyResult{}
 	data := modifyAPIOptionsWithAuth{
 		authInfo: c.authInfo,
@@ -48,11 +243,49 @@ func (c *Client) Modify(actions ...*Action) (*ModifyResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	for i, r := range res.ActionResults {
-		if !r {
-			log.Printf("Action %q on item %d failed", actions[i].Action, actions[i].ItemID)
+
+	// Results are matched to the actions by their position; those missing
+	// from the response count as failed.
+	for len(res.ActionDetails) < len(actions) {
+		res.ActionDetails = append(res.ActionDetails, ActionResult{Error: "no result in the response"})
+	}
+	for i, e := range res.ActionErrors {
+		if i < len(res.ActionDetails) {
+			if message := actionErrorMessage(e); message != "" {
+				res.ActionDetails[i].Error = message
+			}
 		}
 	}
+	res.ActionResults = make([]bool, len(res.ActionDetails))
+	for i, r := range res.ActionDetails {
+		res.ActionResults[i] = r.Success
+	}
+	made, results := []*Action{}, []ActionResult{}
+	for i, r := range res.ActionDetails[:len(actions)] {
+		if !r.Success {
+			log.Printf("Action %q on item %d failed: %s", actions[i].Action, actions[i].ItemID, r.Error)
+			continue
+		}
+		made, results = append(made, actions[i]), append(results, r)
+	}
+	if OnModify != nil && len(made) > 0 {
+		OnModify(c, made, results)
+	}
 
 	return res, nil
 }
+
+// actionErrorMessage returns the message of an entry of action_errors, null
+// for actions that succeeded and otherwise an object such as
+// {"message": "Invalid item", "type": "Bad Request", "code": 422}.
+func actionErrorMessage(e interface{}) string {
+	obj, ok := e.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	message, _ := obj["message"].(string)
+	if code, ok := obj["code"].(float64); ok && message != "" {
+		return fmt.Sprintf("%s (code %d)", message, int(code))
+	}
+	return message
+}
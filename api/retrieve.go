@@ -2,7 +2,13 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,46 +25,56 @@ type RetrieveOption struct {
 	Since       int            `json:"since,omitempty"`
 	Count       int            `json:"count,omitempty"`
 	Offset      int            `json:"offset,omitempty"`
+
+	// Annotations requests the highlights of each item to be included.
+	Annotations bool `json:"annotations,omitempty"`
 }
 
+// State selects the items retrieved by whether they are archived.
 type State string
 
 const (
 	StateUnread  State = "unread"
-	StateArchive       = "archive"
-	StateAll           = "all"
+	StateArchive State = "archive"
+	StateAll     State = "all"
 )
 
+// ContentType selects the items retrieved by what they are.
 type ContentType string
 
 const (
 	ContentTypeArticle ContentType = "article"
-	ContentTypeVideo               = "video"
-	ContentTypeImage               = "image"
+	ContentTypeVideo   ContentType = "video"
+	ContentTypeImage   ContentType = "image"
 )
 
+// Sort is the order of the items retrieved.
 type Sort string
 
 const (
 	SortNewest Sort = "newest"
-	SortOldest      = "oldest"
-	SortTitle       = "title"
-	SortSite        = "site"
+	SortOldest Sort = "oldest"
+	SortTitle  Sort = "title"
+	SortSite   Sort = "site"
 )
 
+// DetailType is how much is retrieved of each item: with
+// DetailTypeComplete, their tags, authors, images, and videos too.
 type DetailType string
 
 const (
 	DetailTypeSimple   DetailType = "simple"
-	DetailTypeComplete            = "complete"
+	DetailTypeComplete DetailType = "complete"
 )
 
+// FavoriteFilter selects the items retrieved by whether they are
+// favorites.
 type FavoriteFilter string
 
 const (
 	FavoriteFilterUnspecified FavoriteFilter = ""
-	FavoriteFilterUnfavorited                = "0"
-	FavoriteFilterFavorited                  = "1"
+	FavoriteFilterUnfavorited FavoriteFilter = "0"
+	FavoriteFilterFavorited   FavoriteFilter = "1"
 )
 
 type retrieveAPIOptionWithAuth struct {
@@ -66,6 +82,8 @@ type retrieveAPIOptionWithAuth struct {
 	authInfo
 }
 
+// RetrieveResult is the result of the retrieve API: the items, by their
+// IDs, and the time to pass as Since to retrieve those changed after.
 type RetrieveResult struct {
 	List     map[string]Item
 	Status   int
@@ -73,22 +91,67 @@ type RetrieveResult struct {
 	Since    int
 }
 
+// UnmarshalJSON decodes the result, accepting the empty array the API sends
+// as the list when there are no items.
+func (r *RetrieveResult) UnmarshalJSON(b []byte) error {
+	type plain RetrieveResult
+	raw := struct {
+		*plain
+		List *itemList
+	}{plain: (*plain)(r), List: (*itemList)(&r.List)}
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if r.List == nil {
+		r.List = map[string]Item{}
+	}
+	return nil
+}
+
+// itemList is the list of a result, decoded in place rather than kept as a
+// json.RawMessage, which would copy the whole list before decoding it. The
+// titles are normalized there if NormalizeTitles is set.
+type itemList map[string]Item
+
+func (l *itemList) UnmarshalJSON(b []byte) error {
+	if len(b) == 0 || b[0] != '{' {
+		*l = itemList{}
+		return nil
+	}
+	if err := json.Unmarshal(b, (*map[string]Item)(l)); err != nil {
+		return err
+	}
+	if NormalizeTitles {
+		for id, item := range *l {
+			item.GivenTitle = NormalizeTitle(item.GivenTitle)
+			item.ResolvedTitle = NormalizeTitle(item.ResolvedTitle)
+			(*l)[id] = item
+		}
+	}
+	return nil
+}
+
+// ItemStatus is whether an item is archived, or deleted in the results of
+// a retrieve with Since.
 type ItemStatus int
 
 const (
 	ItemStatusUnread   ItemStatus = 0
-	ItemStatusArchived            = 1
-	ItemStatusDeleted             = 2
+	ItemStatusArchived ItemStatus = 1
+	ItemStatusDeleted  ItemStatus = 2
 )
 
+// ItemMediaAttachment is whether an item has images or videos, or is one.
 type ItemMediaAttachment int
 
 const (
 	ItemMediaAttachmentNoMedia  ItemMediaAttachment = 0
-	ItemMediaAttachmentHasMedia                     = 1
-	ItemMediaAttachmentIsMedia                      = 2
+	ItemMediaAttachmentHasMedia ItemMediaAttachment = 1
+	ItemMediaAttachmentIsMedia  ItemMediaAttachment = 2
 )
 
+// Item is an item saved to Pocket, as retrieved.
 type Item struct {
 	ItemID        int        `json:"item_id,string"`
 	ResolvedId    int        `json:"resolved_id,string"`
@@ -103,6 +166,11 @@ type Item struct {
 	HasImage      ItemMediaAttachment `json:"has_image,string"`
 	HasVideo      ItemMediaAttachment `json:"has_video,string"`
 	WordCount     int                 `json:"word_count,string"`
+	TimeToRead    int                 `json:"time_to_read"`
+	// ListenDurationEstimate is the time to listen to the item in seconds
+	ListenDurationEstimate int `json:"listen_duration_estimate"`
+	// Lang is the language of the item detected by Pocket, as in "en"
+	Lang string `json:"lang"`
 
 	// Fields for detailed response
 	Tags    map[string]map[string]interface{}
@@ -110,6 +178,9 @@ type Item struct {
 	Images  map[string]map[string]interface{}
 	Videos  map[string]map[string]interface{}
 
+	// Highlights, only included when requested with Annotations
+	Annotations []Annotation `json:"annotations,omitempty"`
+
 	// Fields that are not documented but exist
 	SortId        int  `json:"sort_id"`
 	TimeAdded     Time `json:"time_added"`
@@ -118,10 +189,21 @@ type Item struct {
 	TimeFavorited Time `json:"time_favorited"`
 }
 
+// Annotation is a highlighted passage in an item.
+type Annotation struct {
+	AnnotationID string `json:"annotation_id"`
+	ItemID       int    `json:"item_id,string"`
+	Quote        string `json:"quote"`
+	Patch        string `json:"patch"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// Time is a time of an item, encoded in JSON as a string of Unix seconds.
 type Time struct {
 	time.Time
 }
 
+// UnmarshalJSON decodes a string or number of Unix seconds.
 func (t *Time) UnmarshalJSON(b []byte) error {
 	i, err := strconv.ParseInt(string(bytes.Trim(b, `"`)), 10, 64)
 	if err != nil {
@@ -133,6 +215,16 @@ func (t *Time) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON encodes the time as a string of Unix seconds, the same way the
+// API does, so that items can be stored and decoded again.
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte(`"0"`), nil
+	}
+	return []byte(strconv.Quote(strconv.FormatInt(t.Unix(), 10))), nil
+}
+
+// Format formats the time as time.Time does.
 func (t Time) Format(layout string) string {
 	return t.Time.Format(layout)
 }
@@ -155,18 +247,90 @@ func (item Item) Title() string {
 	return title
 }
 
-// Retrieve returns the in Pocket
+// WordsPerMinute is the reading speed used to estimate reading time from the
+// word count when the API does not provide one.
+var WordsPerMinute = 230
+
+// ReadingMinutes returns the estimated time to read the item in minutes, or
+// zero if it is unknown.
+func (item Item) ReadingMinutes() int {
+	if item.TimeToRead > 0 {
+		return item.TimeToRead
+	}
+	return (item.WordCount + WordsPerMinute - 1) / WordsPerMinute
+}
+
+// ListenSeconds returns the estimated time to listen to the item in seconds,
+// or to watch its video if Pocket has no estimate, or zero if it is unknown.
+func (item Item) ListenSeconds() int {
+	if item.ListenDurationEstimate > 0 {
+		return item.ListenDurationEstimate
+	}
+	for _, video := range item.Videos {
+		if seconds, err := strconv.Atoi(fmt.Sprint(video["length"])); err == nil && seconds > 0 {
+			return seconds
+		}
+	}
+	return 0
+}
+
+// Domain returns the host name of the item's URL without any "www." prefix.
+func (item Item) Domain() string {
+	u, err := neturl.Parse(item.URL())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// TagNames returns the names of the tags on the item, sorted alphabetically.
+// Tags are only included in the response when DetailType is "complete".
+func (item Item) TagNames() []string {
+	names := make([]string, 0, len(item.Tags))
+	for name := range item.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Retrieve retrieves the items options select.
 func (c *Client) Retrieve(options *RetrieveOption) (*RetrieveResult, error) {
+	return c.RetrieveContext(context.Background(), options)
+}
+
+// RetrieveContext is like Retrieve, with the request bound to ctx.
+func (c *Client) RetrieveContext(ctx context.Context, options *RetrieveOption) (*RetrieveResult, error) {
 	data := retrieveAPIOptionWithAuth{
 		authInfo:       c.authInfo,
 		RetrieveOption: options,
 	}
 
 	res := &RetrieveResult{}
-	err := PostJSON("/v3/get", data, res)
+	err := PostJSONContext(ctx, "/v3/get", data, res)
 	if err != nil {
 		return nil, err
 	}
 
 	return res, nil
 }
+
+// Items returns the items of the list in the order of their SortId. Their
+// keys are sorted rather than the items, not to move each Item around.
+func (r *RetrieveResult) Items() []Item {
+	type entry struct {
+		key    string
+		sortID int
+	}
+	entries := make([]entry, 0, len(r.List))
+	for key, item := range r.List {
+		entries = append(entries, entry{key, item.SortId})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].sortID < entries[j].sortID })
+
+	items := make([]Item, len(entries))
+	for i, e := range entries {
+		items[i] = r.List[e.key]
+	}
+	return items
+}
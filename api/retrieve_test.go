@@ -0,0 +1,160 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	. "github.com/onsi/gomega"
+)
+
+// retrieveResponse returns a response of /v3/get listing n items in
+// complete detail, as Pocket sends them.
+func retrieveResponse(n int) []byte {
+	var b strings.Builder
+	b.WriteString(`{"status":1,"complete":1,"since":1700000000,"list":{`)
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `"%[1]d":{"item_id":"%[1]d","resolved_id":"%[1]d",`+
+			`"given_url":"https://example.com/articles/%[1]d","given_title":"",`+
+			`"favorite":"0","status":"0","time_added":"1699990000","time_updated":"1699990100",`+
+			`"time_read":"0","time_favorited":"0","sort_id":%[2]d,`+
+			`"resolved_title":"Article number %[1]d","resolved_url":"https://example.com/articles/%[1]d",`+
+			`"excerpt":"An excerpt of the article, a sentence or two long, as Pocket sends for every item.",`+
+			`"is_article":"1","is_index":"0","has_video":"0","has_image":"1","word_count":"1234",`+
+			`"lang":"en","time_to_read":6,"listen_duration_estimate":478,`+
+			`"tags":{"go":{"item_id":"%[1]d","tag":"go"},"reading":{"item_id":"%[1]d","tag":"reading"}},`+
+			`"authors":{"42":{"item_id":"%[1]d","author_id":"42","name":"Author","url":""}},`+
+			`"images":{"1":{"item_id":"%[1]d","image_id":"1","src":"https://example.com/%[1]d.png","width":"0","height":"0","credit":"","caption":""}}}`,
+			i, n-i)
+	}
+	b.WriteString(`}}`)
+	return []byte(b.String())
+}
+
+func TestRetrieveResult(t *testing.T) {
+	RegisterTestingT(t)
+
+	var res api.RetrieveResult
+	Expect(json.Unmarshal(retrieveResponse(3), &res)).To(Succeed())
+	Expect(res.Status).To(Equal(1))
+	Expect(res.Since).To(Equal(1700000000))
+	Expect(res.List).To(HaveLen(3))
+	item := res.List["2"]
+	Expect(item.ItemID).To(Equal(2))
+	Expect(item.URL()).To(Equal("https://example.com/articles/2"))
+	Expect(item.TimeAdded.Unix()).To(Equal(int64(1699990000)))
+	Expect(item.TimeRead.Unix()).To(Equal(int64(0)))
+	Expect(item.TagNames()).To(Equal([]string{"go", "reading"}))
+
+	items := res.Items()
+	Expect(items).To(HaveLen(3))
+	Expect(items[0].ItemID).To(Equal(3))
+	Expect(items[2].ItemID).To(Equal(1))
+
+	// The API sends an empty array for an empty list
+	res = api.RetrieveResult{}
+	Expect(json.Unmarshal([]byte(`{"status":2,"complete":1,"list":[],"since":1700000000}`), &res)).To(Succeed())
+	Expect(res.List).NotTo(BeNil())
+	Expect(res.List).To(BeEmpty())
+	Expect(res.Items()).To(BeEmpty())
+
+	Expect(json.Unmarshal([]byte(`{"list":{"1":{"item_id":"1","time_added":"x"}}}`), &res)).NotTo(Succeed())
+}
+
+// FuzzRetrieveResult checks that no response makes decoding panic, and that
+// the items decoded can be stored and decoded again unchanged, as backups and
+// the sync cache do.
+func FuzzRetrieveResult(f *testing.F) {
+	f.Add(retrieveResponse(2))
+	for _, body := range []string{
+		`{"status":2,"complete":1,"list":[],"since":1700000000}`,
+		`{"status":1,"list":null}`,
+		`{"status":1}`,
+		`{"status":1,"list":{"1":{"item_id":"1","status":"2"}}}`,
+		`{"status":1,"list":{"1":{"item_id":"1","time_added":1700000000,"time_read":"0"}}}`,
+		`{"status":1,"list":{"1":{"item_id":"1","word_count":"","tags":[],"authors":null}}}`,
+		`{"status":1,"list":{"1":{"item_id":"1","annotations":[{"quote":"q","item_id":"1"}]}}}`,
+		`{"status":1,"list":{"1":{"item_id":"1","given_title":"\u00e9\ud83d\ude00"}},"list":[]}`,
+		`{"status":1,"list":{"x":{"item_id":"-1","sort_id":-5,"time_added":"-62135596800"}}}`,
+	} {
+		f.Add([]byte(body))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var res api.RetrieveResult
+		if json.Unmarshal(body, &res) != nil {
+			return
+		}
+		if res.List == nil {
+			t.Fatal("decoded a nil list")
+		}
+
+		stored, err := json.Marshal(res.Items())
+		if err != nil {
+			t.Fatalf("encoding decoded items: %v", err)
+		}
+		var items []api.Item
+		err = json.Unmarshal(stored, &items)
+		if err != nil {
+			t.Fatalf("decoding stored items %s: %v", stored, err)
+		}
+		again, err := json.Marshal(items)
+		if err != nil {
+			t.Fatalf("encoding items again: %v", err)
+		}
+		if string(again) != string(stored) {
+			t.Fatalf("items changed when stored:\n%s\n%s", stored, again)
+		}
+	})
+}
+
+func BenchmarkRetrieveDecode(b *testing.B) {
+	data := retrieveResponse(10000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var res api.RetrieveResult
+		if err := json.Unmarshal(data, &res); err != nil {
+			b.Fatal(err)
+		}
+		if len(res.Items()) != 10000 {
+			b.Fatalf("got %d items", len(res.List))
+		}
+	}
+}
+
+func BenchmarkRetrieve(b *testing.B) {
+	data := retrieveResponse(10000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer ts.Close()
+	origin := api.Origin
+	api.Origin = ts.URL
+	defer func() { api.Origin = origin }()
+
+	client := &api.Client{}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		res, err := client.Retrieve(&api.RetrieveOption{DetailType: api.DetailTypeComplete})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(res.List) != 10000 {
+			b.Fatalf("got %d items", len(res.List))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// NormalizeTitles, if set, has the items retrieved decoded with their titles
+// passed through NormalizeTitle, as many saved long ago show HTML entities or
+// garbled characters.
+var NormalizeTitles bool
+
+// NormalizeTitle decodes the HTML entities of title, even those escaped
+// twice, and repairs UTF-8 that was decoded as Latin-1 or Windows-1252 and
+// encoded again, as in "Itâ€™s" for "It’s". Titles that were not garbled
+// this way are returned unchanged.
+func NormalizeTitle(title string) string {
+	for i := 0; i < 2 && strings.Contains(title, "&"); i++ {
+		title = html.UnescapeString(title)
+	}
+	for i := 0; i < 2; i++ {
+		fixed, ok := undoDoubleEncoding(title)
+		if !ok {
+			break
+		}
+		title = fixed
+	}
+	return title
+}
+
+// windows1252 maps the characters Windows-1252 has in place of the C1
+// controls of Latin-1 back to their bytes.
+var windows1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// undoDoubleEncoding turns the characters of s back into the bytes they
+// were decoded from, reporting whether those are UTF-8 with characters
+// beyond ASCII, and so s was garbled.
+func undoDoubleEncoding(s string) (string, bool) {
+	b := make([]byte, 0, len(s))
+	multibyte := false
+	for _, r := range s {
+		switch c, ok := windows1252[r]; {
+		case ok:
+			b = append(b, c)
+		case r <= 0xFF:
+			b = append(b, byte(r))
+		default:
+			return "", false
+		}
+		if r >= 0x80 {
+			multibyte = true
+		}
+	}
+	if !multibyte || !utf8.Valid(b) {
+		return "", false
+	}
+	return string(b), true
+}
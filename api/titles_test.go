@@ -0,0 +1,47 @@
+package api_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	. "github.com/onsi/gomega"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	RegisterTestingT(t)
+
+	for title, normalized := range map[string]string{
+		"Tom &amp; Jerry":          "Tom & Jerry",
+		"Q&amp;amp;A":              "Q&A",
+		"It&#8217;s here":          "It’s here",
+		"Itâ€™s here":              "It’s here",
+		"CafÃ© au lait":            "Café au lait",
+		"Ã¢â‚¬Å“Twice":             "“Twice",
+		"â€œQuotedâ€\u009d":        "“Quoted”",
+		"Â© 2011 &lt;Company&gt;":  "© 2011 <Company>",
+		"Café au lait":             "Café au lait",
+		"Crème brûlée — a history": "Crème brûlée — a history",
+		"日本語のタイトル":                 "日本語のタイトル",
+		"Plain ASCII & more":       "Plain ASCII & more",
+	} {
+		Expect(api.NormalizeTitle(title)).To(Equal(normalized), title)
+	}
+}
+
+func TestNormalizeTitles(t *testing.T) {
+	RegisterTestingT(t)
+
+	body := []byte(`{"status":1,"list":{"1":{"item_id":"1","given_title":"Tom &amp; Jerry","resolved_title":"Itâ€™s here"}}}`)
+
+	var res api.RetrieveResult
+	Expect(json.Unmarshal(body, &res)).To(Succeed())
+	Expect(res.List["1"].Title()).To(Equal("Itâ€™s here"))
+
+	api.NormalizeTitles = true
+	defer func() { api.NormalizeTitles = false }()
+	res = api.RetrieveResult{}
+	Expect(json.Unmarshal(body, &res)).To(Succeed())
+	Expect(res.List["1"].GivenTitle).To(Equal("Tom & Jerry"))
+	Expect(res.List["1"].Title()).To(Equal("It’s here"))
+}
@@ -1,3 +1,10 @@
+// Package auth obtains the access token of a Pocket account for a
+// consumer key, by the OAuth flow of Pocket: a request token is obtained,
+// the user authorizes it at the URL generated for it, and it is exchanged
+// for an access token. Authorize runs the whole flow, asking the user
+// through a Prompter.
+//
+// The package follows semantic versioning from v1, as the api package does.
 package auth
 
 import (
@@ -7,15 +14,19 @@ import (
 	"github.com/motemen/go-pocket/api"
 )
 
+// RequestToken is the token the user is asked to authorize.
 type RequestToken struct {
 	Code string `json:"code"`
 }
 
+// Authorization is the access token of an account, with its user name.
 type Authorization struct {
 	AccessToken string `json:"access_token"`
 	Username    string `json:"username"`
 }
 
+// ObtainRequestToken obtains a request token for consumerKey, to which the
+// user is sent back at redirectURL once they authorized it.
 func ObtainRequestToken(consumerKey, redirectURL string) (*RequestToken, error) {
 	res := &RequestToken{}
 	err := api.PostJSON(
@@ -33,6 +44,8 @@ func ObtainRequestToken(consumerKey, redirectURL string) (*RequestToken, error)
 	return res, nil
 }
 
+// ObtainAccessToken exchanges a request token the user authorized for the
+// access token of their account.
 func ObtainAccessToken(consumerKey string, requestToken *RequestToken) (*Authorization, error) {
 	res := &Authorization{}
 	err := api.PostJSON(
@@ -50,6 +63,8 @@ func ObtainAccessToken(consumerKey string, requestToken *RequestToken) (*Authori
 	return res, nil
 }
 
+// GenerateAuthorizationURL returns the URL at which the user authorizes
+// requestToken, sent back to redirectURL after.
 func GenerateAuthorizationURL(requestToken *RequestToken, redirectURL string) string {
 	values := url.Values{"request_token": {requestToken.Code}, "redirect_uri": {redirectURL}}
 	return fmt.Sprintf("%s/auth/authorize?%s", api.Origin, values.Encode())
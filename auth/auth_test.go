@@ -1,9 +1,13 @@
 package auth_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/motemen/go-pocket/api"
@@ -28,3 +32,58 @@ func TestObtainRequestToken(t *testing.T) {
 	Expect(err).To(BeNil())
 	Expect(res.Code).To(Equal(theCode))
 }
+
+func TestTerminalPrompter(t *testing.T) {
+	RegisterTestingT(t)
+
+	var out, errOut bytes.Buffer
+	p := &auth.TerminalPrompter{In: strings.NewReader("the-key\nrest\n"), Out: &out, Err: &errOut}
+
+	key, err := p.PromptConsumerKey()
+	Expect(err).To(BeNil())
+	Expect(key).To(Equal("the-key"))
+	Expect(errOut.String()).To(ContainSubstring("Enter your consumer key"))
+
+	// What was read ahead of the first answer is kept for the next
+	key, err = p.PromptConsumerKey()
+	Expect(err).To(BeNil())
+	Expect(key).To(Equal("rest"))
+
+	Expect(p.PromptAuthorization("https://getpocket.com/auth/authorize?x")).To(Succeed())
+	Expect(out.String()).To(Equal("https://getpocket.com/auth/authorize?x\n"))
+}
+
+// visitingPrompter visits the authorization URL as a browser would, and
+// Pocket sends it back to the redirect URL.
+type visitingPrompter struct{}
+
+func (visitingPrompter) PromptConsumerKey() (string, error) { return "key", nil }
+
+func (visitingPrompter) PromptAuthorization(authorizationURL string) error {
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		return err
+	}
+	go http.Get(u.Query().Get("redirect_uri"))
+	return nil
+}
+
+func TestAuthorize(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/oauth/request":
+			w.Write([]byte(`{"code":"the-code"}`))
+		case "/v3/oauth/authorize":
+			w.Write([]byte(`{"access_token":"the-token","username":"someone"}`))
+		}
+	}))
+	defer ts.Close()
+
+	api.Origin = ts.URL
+
+	res, err := auth.Authorize(context.Background(), "key", visitingPrompter{})
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(auth.Authorization{AccessToken: "the-token", Username: "someone"}))
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Prompter asks the user for what authorizing needs of them. It is
+// TerminalPrompter for a command line; applications embedding the package
+// show their own dialogs instead.
+type Prompter interface {
+	// PromptConsumerKey asks for the consumer key of the application, from
+	// https://getpocket.com/developer/apps/.
+	PromptConsumerKey() (string, error)
+	// PromptAuthorization sends the user to url, at which they authorize
+	// the request token, as by opening it in a browser. It need not wait
+	// for them to do so; Authorize does.
+	PromptAuthorization(url string) error
+}
+
+// TerminalPrompter prompts on a terminal: it asks questions on Err, reads
+// the answers from In, and writes the authorization URL to Out, for the
+// user to visit.
+type TerminalPrompter struct {
+	// In is os.Stdin if nil. Answers are read a line at a time, through a
+	// buffer the prompter keeps for all of its prompts, so that what is
+	// read ahead of one answer is left for the next.
+	In io.Reader
+	// Out is os.Stdout if nil.
+	Out io.Writer
+	// Err is os.Stderr if nil.
+	Err io.Writer
+
+	in *bufio.Reader
+}
+
+// reader returns the buffered In, made on the first prompt.
+func (p *TerminalPrompter) reader() *bufio.Reader {
+	if p.in == nil {
+		var in io.Reader = os.Stdin
+		if p.In != nil {
+			in = p.In
+		}
+		r, ok := in.(*bufio.Reader)
+		if !ok {
+			r = bufio.NewReader(in)
+		}
+		p.in = r
+	}
+	return p.in
+}
+
+// PromptConsumerKey implements Prompter.
+func (p *TerminalPrompter) PromptConsumerKey() (string, error) {
+	errOut := p.Err
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+	fmt.Fprint(errOut, "Enter your consumer key (from here https://getpocket.com/developer/apps/): ")
+
+	line, err := p.reader().ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// PromptAuthorization implements Prompter.
+func (p *TerminalPrompter) PromptAuthorization(url string) error {
+	out := p.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, err := fmt.Fprintln(out, url)
+	return err
+}
+
+// Authorize obtains the access token of an account for consumerKey, the
+// user authorizing it through p. Pocket sends them back to a server
+// listening on the loopback interface while this waits, by which it knows
+// they are done.
+func Authorize(ctx context.Context, consumerKey string, p Prompter) (*Authorization, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{}, 1)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/favicon.ico" {
+				http.Error(w, "Not Found", 404)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintln(w, "Authorized.")
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+		}),
+	}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	redirectURL := "http://" + l.Addr().String()
+
+	requestToken, err := ObtainRequestToken(consumerKey, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.PromptAuthorization(GenerateAuthorizationURL(requestToken, redirectURL))
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return ObtainAccessToken(consumerKey, requestToken)
+}
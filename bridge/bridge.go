@@ -0,0 +1,309 @@
+// Package bridge reads and adds bookmarks in other bookmark managers: buku,
+// shiori, and Pinboard.
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Bookmark is a bookmark in a bookmark manager.
+type Bookmark struct {
+	URL   string
+	Title string
+	Tags  []string
+	// Unread is set for bookmarks to read later, where the service has the
+	// notion; it is always false otherwise.
+	Unread bool
+}
+
+// Service is a bookmark manager.
+type Service interface {
+	// Bookmarks returns every bookmark.
+	Bookmarks() ([]Bookmark, error)
+	// Add adds a bookmark. Adding one already there is not an error.
+	Add(b Bookmark) error
+}
+
+// TagMap maps Pocket tags to the tags of a service. Tags not in the map are
+// the same in both.
+type TagMap map[string]string
+
+// ToService returns the tags of the service for Pocket tags.
+func (m TagMap) ToService(tags []string) []string {
+	result := make([]string, len(tags))
+	for i, tag := range tags {
+		if mapped, ok := m[tag]; ok {
+			tag = mapped
+		}
+		result[i] = tag
+	}
+	return result
+}
+
+// ToPocket returns the Pocket tags for tags of the service.
+func (m TagMap) ToPocket(tags []string) []string {
+	reverse := map[string]string{}
+	for pocket, service := range m {
+		reverse[service] = pocket
+	}
+	return TagMap(reverse).ToService(tags)
+}
+
+// run runs a command line tool and returns its output, with its standard
+// error in the error if it fails.
+func run(name string, args ...string) ([]byte, error) {
+	out, err := exec.Command(name, args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("%s failed: %s, %s", name, err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not run %s: %w", name, err)
+	}
+	return out, nil
+}
+
+// splitTags splits a list of tags separated by sep.
+func splitTags(s, sep string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Buku is the buku command line bookmark manager.
+type Buku struct {
+	// Command is the buku executable.
+	Command string
+}
+
+// NewBuku creates a Buku running the buku in $PATH, if command is empty.
+func NewBuku(command string) *Buku {
+	if command == "" {
+		command = "buku"
+	}
+	return &Buku{Command: command}
+}
+
+// Bookmarks implements Service.
+func (b *Buku) Bookmarks() ([]Bookmark, error) {
+	out, err := run(b.Command, "--nostdin", "--nc", "--print", "--json")
+	if err != nil {
+		return nil, err
+	}
+	// buku prints nothing at all without bookmarks
+	if len(strings.TrimSpace(string(out))) == 0 {
+		return nil, nil
+	}
+
+	var records []struct {
+		URI   string `json:"uri"`
+		Title string `json:"title"`
+		Tags  string `json:"tags"`
+	}
+	if err := json.Unmarshal(out, &records); err != nil {
+		return nil, fmt.Errorf("could not read the output of buku: %w", err)
+	}
+
+	bookmarks := make([]Bookmark, len(records))
+	for i, r := range records {
+		bookmarks[i] = Bookmark{URL: r.URI, Title: r.Title, Tags: splitTags(r.Tags, ",")}
+	}
+	return bookmarks, nil
+}
+
+// Add implements Service.
+func (b *Buku) Add(bookmark Bookmark) error {
+	// buku takes tags separated by commas, so commas in tags cannot be kept
+	tags := make([]string, len(bookmark.Tags))
+	for i, tag := range bookmark.Tags {
+		tags[i] = strings.ReplaceAll(tag, ",", " ")
+	}
+
+	args := []string{"--nostdin", "--nc", "--offline", "--add", bookmark.URL}
+	if len(tags) > 0 {
+		args = append(args, strings.Join(tags, ","))
+	}
+	if bookmark.Title != "" {
+		args = append(args, "--title", bookmark.Title)
+	}
+	_, err := run(b.Command, args...)
+	return err
+}
+
+// Shiori is the shiori bookmark manager, through its command line.
+type Shiori struct {
+	// Command is the shiori executable.
+	Command string
+}
+
+// NewShiori creates a Shiori running the shiori in $PATH, if command is empty.
+func NewShiori(command string) *Shiori {
+	if command == "" {
+		command = "shiori"
+	}
+	return &Shiori{Command: command}
+}
+
+// Bookmarks implements Service.
+func (s *Shiori) Bookmarks() ([]Bookmark, error) {
+	out, err := run(s.Command, "print", "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	var records []struct {
+		URL   string `json:"url"`
+		Title string `json:"title"`
+		Tags  []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+	}
+	if err := json.Unmarshal(out, &records); err != nil {
+		return nil, fmt.Errorf("could not read the output of shiori: %w", err)
+	}
+
+	bookmarks := make([]Bookmark, len(records))
+	for i, r := range records {
+		bookmarks[i] = Bookmark{URL: r.URL, Title: r.Title, Tags: []string{}}
+		for _, tag := range r.Tags {
+			bookmarks[i].Tags = append(bookmarks[i].Tags, tag.Name)
+		}
+	}
+	return bookmarks, nil
+}
+
+// Add implements Service.
+func (s *Shiori) Add(bookmark Bookmark) error {
+	args := []string{"add", bookmark.URL}
+	if bookmark.Title != "" {
+		args = append(args, "--title", bookmark.Title)
+	}
+	if len(bookmark.Tags) > 0 {
+		args = append(args, "--tags", strings.Join(bookmark.Tags, ","))
+	}
+	_, err := run(s.Command, args...)
+	return err
+}
+
+// PinboardOrigin is the origin of the Pinboard API.
+const PinboardOrigin = "https://api.pinboard.in"
+
+// Pinboard is the Pinboard bookmarking service. Its API allows one request
+// every three seconds, so adds are spaced by Interval.
+type Pinboard struct {
+	// Token is the API token, as "user:HEX", from the settings page.
+	Token    string
+	Origin   string
+	Client   *http.Client
+	Interval time.Duration
+
+	lastAdd time.Time
+}
+
+// NewPinboard creates a Pinboard using the API token.
+func NewPinboard(token string) *Pinboard {
+	return &Pinboard{
+		Token:    token,
+		Origin:   PinboardOrigin,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		Interval: 3 * time.Second,
+	}
+}
+
+func (p *Pinboard) get(method string, params url.Values, v interface{}) error {
+	if p.Token == "" {
+		return errors.New("no Pinboard API token")
+	}
+	params.Set("auth_token", p.Token)
+	params.Set("format", "json")
+
+	resp, err := p.Client.Get(p.Origin + "/v1/" + method + "?" + params.Encode())
+	if err != nil {
+		// The token is in the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("pinboard %s: %w", method, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pinboard %s: got response %d", method, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Bookmarks implements Service.
+func (p *Pinboard) Bookmarks() ([]Bookmark, error) {
+	var posts []struct {
+		Href        string `json:"href"`
+		Description string `json:"description"`
+		Tags        string `json:"tags"`
+		ToRead      string `json:"toread"`
+	}
+	if err := p.get("posts/all", url.Values{}, &posts); err != nil {
+		return nil, err
+	}
+
+	bookmarks := make([]Bookmark, len(posts))
+	for i, post := range posts {
+		bookmarks[i] = Bookmark{
+			URL:    post.Href,
+			Title:  post.Description,
+			Tags:   splitTags(post.Tags, " "),
+			Unread: post.ToRead == "yes",
+		}
+	}
+	return bookmarks, nil
+}
+
+// Add implements Service.
+func (p *Pinboard) Add(bookmark Bookmark) error {
+	if wait := p.Interval - time.Since(p.lastAdd); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() { p.lastAdd = time.Now() }()
+
+	// Pinboard separates tags with spaces
+	tags := make([]string, len(bookmark.Tags))
+	for i, tag := range bookmark.Tags {
+		tags[i] = strings.ReplaceAll(tag, " ", "_")
+	}
+	title := bookmark.Title
+	if title == "" {
+		title = bookmark.URL
+	}
+	toread := "no"
+	if bookmark.Unread {
+		toread = "yes"
+	}
+
+	var result struct {
+		ResultCode string `json:"result_code"`
+	}
+	err := p.get("posts/add", url.Values{
+		"url":         {bookmark.URL},
+		"description": {title},
+		"tags":        {strings.Join(tags, " ")},
+		"toread":      {toread},
+		"replace":     {"no"},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.ResultCode != "done" && result.ResultCode != "item already exists" {
+		return fmt.Errorf("pinboard posts/add: %s", result.ResultCode)
+	}
+	return nil
+}
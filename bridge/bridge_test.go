@@ -0,0 +1,119 @@
+package bridge_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/motemen/go-pocket/bridge"
+	. "github.com/onsi/gomega"
+)
+
+func TestTagMap(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := bridge.TagMap{"go": "golang", "to read": "toread"}
+	Expect(m.ToService([]string{"go", "rust", "to read"})).To(Equal([]string{"golang", "rust", "toread"}))
+	Expect(m.ToPocket([]string{"golang", "toread", "misc"})).To(Equal([]string{"go", "to read", "misc"}))
+}
+
+func TestPinboard(t *testing.T) {
+	RegisterTestingT(t)
+
+	added := []map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("auth_token") != "user:abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/posts/all":
+			w.Write([]byte(`[{"href":"https://example.com/1","description":"One","tags":"go web","toread":"yes"},{"href":"https://example.com/2","description":"Two","tags":"","toread":"no"}]`))
+		case "/v1/posts/add":
+			added = append(added, map[string]string{"url": q.Get("url"), "description": q.Get("description"), "tags": q.Get("tags"), "toread": q.Get("toread")})
+			json.NewEncoder(w).Encode(map[string]string{"result_code": "done"})
+		}
+	}))
+	defer ts.Close()
+
+	p := bridge.NewPinboard("user:abc")
+	p.Origin = ts.URL
+	p.Interval = 0
+
+	bookmarks, err := p.Bookmarks()
+	Expect(err).To(BeNil())
+	Expect(bookmarks).To(Equal([]bridge.Bookmark{
+		{URL: "https://example.com/1", Title: "One", Tags: []string{"go", "web"}, Unread: true},
+		{URL: "https://example.com/2", Title: "Two", Tags: []string{}},
+	}))
+
+	Expect(p.Add(bridge.Bookmark{URL: "https://example.com/3", Tags: []string{"long read"}, Unread: true})).To(Succeed())
+	Expect(added).To(Equal([]map[string]string{
+		{"url": "https://example.com/3", "description": "https://example.com/3", "tags": "long_read", "toread": "yes"},
+	}))
+
+	p.Token = "user:wrong"
+	_, err = p.Bookmarks()
+	Expect(err).To(MatchError("pinboard posts/all: got response 401"))
+}
+
+// fakeCommand writes a shell script recording its arguments in a file and
+// printing output.
+func fakeCommand(t *testing.T, output string) (command, argsFile string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are faked with sh")
+	}
+	dir := t.TempDir()
+	command = filepath.Join(dir, "fake")
+	argsFile = filepath.Join(dir, "args")
+	outFile := filepath.Join(dir, "out")
+	Expect(os.WriteFile(outFile, []byte(output), 0600)).To(Succeed())
+	script := "#!/bin/sh\nfor a in \"$@\"; do echo \"$a\"; done >> '" + argsFile + "'\ncat '" + outFile + "'\n"
+	Expect(os.WriteFile(command, []byte(script), 0700)).To(Succeed())
+	return command, argsFile
+}
+
+func TestBuku(t *testing.T) {
+	RegisterTestingT(t)
+
+	command, argsFile := fakeCommand(t, `[{"index":1,"uri":"https://example.com/1","title":"One","description":"","tags":",go,web,"}]`)
+	b := bridge.NewBuku(command)
+
+	bookmarks, err := b.Bookmarks()
+	Expect(err).To(BeNil())
+	Expect(bookmarks).To(Equal([]bridge.Bookmark{{URL: "https://example.com/1", Title: "One", Tags: []string{"go", "web"}}}))
+
+	Expect(os.Remove(argsFile)).To(Succeed())
+	Expect(b.Add(bridge.Bookmark{URL: "https://example.com/2", Title: "Two", Tags: []string{"a,b", "c"}})).To(Succeed())
+	args, _ := os.ReadFile(argsFile)
+	Expect(string(args)).To(Equal("--nostdin\n--nc\n--offline\n--add\nhttps://example.com/2\na b,c\n--title\nTwo\n"))
+
+	command, _ = fakeCommand(t, "")
+	bookmarks, err = bridge.NewBuku(command).Bookmarks()
+	Expect(err).To(BeNil())
+	Expect(bookmarks).To(BeEmpty())
+}
+
+func TestShiori(t *testing.T) {
+	RegisterTestingT(t)
+
+	command, argsFile := fakeCommand(t, `[{"id":1,"url":"https://example.com/1","title":"One","tags":[{"id":1,"name":"go"}]}]`)
+	s := bridge.NewShiori(command)
+
+	bookmarks, err := s.Bookmarks()
+	Expect(err).To(BeNil())
+	Expect(bookmarks).To(Equal([]bridge.Bookmark{{URL: "https://example.com/1", Title: "One", Tags: []string{"go"}}}))
+
+	Expect(os.Remove(argsFile)).To(Succeed())
+	Expect(s.Add(bridge.Bookmark{URL: "https://example.com/2", Tags: []string{"a", "b"}})).To(Succeed())
+	args, _ := os.ReadFile(argsFile)
+	Expect(string(args)).To(Equal("add\nhttps://example.com/2\n--tags\na,b\n"))
+
+	_, err = bridge.NewShiori(filepath.Join(t.TempDir(), "missing")).Bookmarks()
+	Expect(err).To(MatchError(ContainSubstring("could not run")))
+}
@@ -0,0 +1,237 @@
+// Package bulk runs many tasks, such as batches of Pocket actions or fetches
+// of articles, on a bounded number of workers. Failed tasks are retried, a
+// rate limit pauses every worker, and a run can be stopped between tasks.
+package bulk
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// ErrInterrupted is the error of the tasks not run because Interrupted
+// reported true, and of the run itself.
+var ErrInterrupted = errors.New("interrupted")
+
+// ErrSkipped is the error of the tasks not run because an earlier one failed
+// with StopOnError set.
+var ErrSkipped = errors.New("skipped after an earlier failure")
+
+// pollInterval is how often waits check Interrupted.
+const pollInterval = 100 * time.Millisecond
+
+// Engine runs tasks concurrently.
+type Engine struct {
+	// Workers is the number of tasks run at once.
+	Workers int
+	// Attempts is the number of times a task is tried before giving up.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after that.
+	Backoff time.Duration
+	// StopOnError stops starting tasks once one has failed for good.
+	StopOnError bool
+
+	// Retryable reports whether an error is worth retrying, and how long
+	// all workers should wait before that, as for a rate limit.
+	Retryable func(err error) (retry bool, wait time.Duration)
+	// Progress, if set, is called after each task with the number of tasks
+	// done and their total, from one goroutine at a time.
+	Progress func(done, total int)
+	// Retrying, if set, is called before a task is tried again with the
+	// number of the retry, from 1, and the error that failed the last try.
+	Retrying func(retry int, err error)
+	// Waiting, if set, is called when Retryable makes all workers wait, as
+	// for a rate limit, with how long. Like Progress, Retrying and Waiting
+	// are called from one goroutine at a time.
+	Waiting func(wait time.Duration)
+	// Interrupted, if set, is checked before each task and during waits.
+	// Once it returns true, no more tasks are started.
+	Interrupted func() bool
+}
+
+// New creates an engine running tasks on the given number of workers,
+// trying each three times.
+func New(workers int) *Engine {
+	return &Engine{
+		Workers:   workers,
+		Attempts:  3,
+		Backoff:   time.Second,
+		Retryable: Retryable,
+	}
+}
+
+// Retryable retries network errors, web pages answered instead of JSON,
+// and API errors from rate limits and the server side, waiting as long as
+// the API asks.
+func Retryable(err error) (bool, time.Duration) {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true, 0
+	}
+
+	var pageErr *api.PageError
+	if errors.As(err, &pageErr) {
+		return true, 0
+	}
+
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		wait := apiErr.RetryAfter()
+		return apiErr.StatusCode == 429 || apiErr.StatusCode >= 500 || wait > 0, wait
+	}
+
+	return false, 0
+}
+
+// run is the state of a Run.
+type run struct {
+	*Engine
+	mu          sync.Mutex
+	pausedUntil time.Time
+	done        int
+	failed      atomic.Bool
+}
+
+// Run calls task with each index from 0 to n-1, returning the error of each
+// task. If interrupted, the tasks not started fail with ErrInterrupted, and
+// so does Run; with StopOnError, those after a failure fail with ErrSkipped.
+func (e *Engine) Run(n int, task func(i int) error) ([]error, error) {
+	errs := make([]error, n)
+	r := &run{Engine: e}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(e.Workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = r.do(i, task)
+				if errs[i] != nil && errs[i] != ErrInterrupted && r.StopOnError {
+					r.failed.Store(true)
+				}
+				r.finished(n)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		if r.interrupted() {
+			errs[i] = ErrInterrupted
+			continue
+		}
+		if r.failed.Load() {
+			errs[i] = ErrSkipped
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == ErrInterrupted {
+			return errs, ErrInterrupted
+		}
+	}
+	return errs, nil
+}
+
+func (r *run) interrupted() bool {
+	return r.Interrupted != nil && r.Interrupted()
+}
+
+// do tries a task, waiting out pauses and backoffs between attempts.
+func (r *run) do(i int, task func(int) error) error {
+	if r.interrupted() {
+		return ErrInterrupted
+	}
+	if r.failed.Load() {
+		return ErrSkipped
+	}
+
+	backoff := r.Backoff
+	var err error
+	for attempt := 0; attempt < max(r.Attempts, 1); attempt++ {
+		if attempt > 0 {
+			if !r.sleep(backoff) {
+				return ErrInterrupted
+			}
+			backoff *= 2
+		}
+		if !r.waitPause() {
+			return ErrInterrupted
+		}
+
+		err = task(i)
+		if err == nil || r.Retryable == nil {
+			return err
+		}
+		retry, wait := r.Retryable(err)
+		if !retry {
+			return err
+		}
+		if wait > 0 {
+			r.pause(wait)
+		}
+		if attempt+1 < max(r.Attempts, 1) {
+			r.retrying(attempt+1, err)
+		}
+	}
+	return err
+}
+
+func (r *run) retrying(retry int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Retrying != nil {
+		r.Retrying(retry, err)
+	}
+}
+
+// pause makes every worker wait before starting a task.
+func (r *run) pause(wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if until := time.Now().Add(wait); until.After(r.pausedUntil) {
+		r.pausedUntil = until
+	}
+	if r.Waiting != nil {
+		r.Waiting(wait)
+	}
+}
+
+// waitPause waits for a pause to end, returning false if interrupted.
+func (r *run) waitPause() bool {
+	r.mu.Lock()
+	until := r.pausedUntil
+	r.mu.Unlock()
+	return r.sleep(time.Until(until))
+}
+
+// sleep waits for d, returning false if interrupted in the meantime.
+func (r *run) sleep(d time.Duration) bool {
+	for d > 0 {
+		if r.interrupted() {
+			return false
+		}
+		step := min(d, pollInterval)
+		time.Sleep(step)
+		d -= step
+	}
+	return true
+}
+
+func (r *run) finished(total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done++
+	if r.Progress != nil {
+		r.Progress(r.done, total)
+	}
+}
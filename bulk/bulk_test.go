@@ -0,0 +1,177 @@
+package bulk_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/bulk"
+	. "github.com/onsi/gomega"
+)
+
+func TestRun(t *testing.T) {
+	RegisterTestingT(t)
+
+	var running, most int32
+	var mu sync.Mutex
+	progress := [][2]int{}
+
+	e := bulk.New(3)
+	e.Progress = func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	}
+	errs, err := e.Run(10, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		mu.Lock()
+		if n > most {
+			most = n
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+
+		if i == 4 {
+			return errors.New("bad item")
+		}
+		return nil
+	})
+	Expect(err).To(BeNil())
+	Expect(errs).To(HaveLen(10))
+	for i, err := range errs {
+		if i == 4 {
+			Expect(err).To(MatchError("bad item"))
+		} else {
+			Expect(err).To(BeNil())
+		}
+	}
+	Expect(most).To(BeNumerically("<=", 3))
+	Expect(progress).To(HaveLen(10))
+	Expect(progress[9]).To(Equal([2]int{10, 10}))
+}
+
+func TestRunRetries(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := bulk.New(1)
+	e.Backoff = time.Millisecond
+	retries := []int{}
+	e.Retrying = func(retry int, err error) {
+		Expect(err).To(HaveOccurred())
+		retries = append(retries, retry)
+	}
+
+	// Network errors are retried
+	attempts := 0
+	errs, err := e.Run(1, func(int) error {
+		attempts++
+		if attempts < 3 {
+			return &url.Error{Op: "Post", URL: "https://getpocket.com", Err: errors.New("connection reset")}
+		}
+		return nil
+	})
+	Expect(err).To(BeNil())
+	Expect(errs[0]).To(BeNil())
+	Expect(attempts).To(Equal(3))
+	Expect(retries).To(Equal([]int{1, 2}))
+
+	// Client errors are not
+	attempts = 0
+	errs, _ = e.Run(1, func(int) error {
+		attempts++
+		return &api.Error{StatusCode: 400, Header: http.Header{}}
+	})
+	Expect(errs[0]).To(HaveOccurred())
+	Expect(attempts).To(Equal(1))
+	Expect(retries).To(Equal([]int{1, 2}))
+}
+
+func TestRunPausesWhenRateLimited(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := bulk.New(2)
+	e.Backoff = 0
+	e.Retryable = func(err error) (bool, time.Duration) {
+		return true, 50 * time.Millisecond
+	}
+	waits := []time.Duration{}
+	e.Waiting = func(wait time.Duration) { waits = append(waits, wait) }
+
+	var limited int32
+	start := time.Now()
+	var last time.Duration
+	var mu sync.Mutex
+	errs, err := e.Run(4, func(i int) error {
+		if i == 0 && atomic.AddInt32(&limited, 1) == 1 {
+			return errors.New("rate limited")
+		}
+		mu.Lock()
+		last = max(last, time.Since(start))
+		mu.Unlock()
+		return nil
+	})
+	Expect(err).To(BeNil())
+	Expect(errs).To(Equal([]error{nil, nil, nil, nil}))
+	Expect(last).To(BeNumerically(">=", 50*time.Millisecond))
+	Expect(waits).To(Equal([]time.Duration{50 * time.Millisecond}))
+}
+
+func TestRunInterrupted(t *testing.T) {
+	RegisterTestingT(t)
+
+	var started int32
+	e := bulk.New(1)
+	e.Interrupted = func() bool { return atomic.LoadInt32(&started) >= 2 }
+
+	errs, err := e.Run(5, func(int) error {
+		atomic.AddInt32(&started, 1)
+		return nil
+	})
+	Expect(err).To(Equal(bulk.ErrInterrupted))
+	Expect(errs).To(Equal([]error{nil, nil, bulk.ErrInterrupted, bulk.ErrInterrupted, bulk.ErrInterrupted}))
+}
+
+func TestRunStopOnError(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := bulk.New(1)
+	e.StopOnError = true
+	errs, err := e.Run(3, func(i int) error {
+		if i == 1 {
+			return errors.New("bad batch")
+		}
+		return nil
+	})
+	Expect(err).To(BeNil())
+	Expect(errs[0]).To(BeNil())
+	Expect(errs[1]).To(MatchError("bad batch"))
+	Expect(errs[2]).To(Equal(bulk.ErrSkipped))
+}
+
+func TestRetryable(t *testing.T) {
+	RegisterTestingT(t)
+
+	limited := &api.Error{StatusCode: 403, Header: http.Header{
+		"X-Limit-User-Remaining": {"0"},
+		"X-Limit-User-Reset":     {"60"},
+	}}
+	retry, wait := bulk.Retryable(limited)
+	Expect(retry).To(BeTrue())
+	Expect(wait).To(Equal(time.Minute))
+
+	retry, _ = bulk.Retryable(&api.Error{StatusCode: 503, Header: http.Header{}})
+	Expect(retry).To(BeTrue())
+
+	retry, _ = bulk.Retryable(&api.PageError{StatusCode: 200, Title: "Down for maintenance"})
+	Expect(retry).To(BeTrue())
+
+	retry, _ = bulk.Retryable(&api.Error{StatusCode: 401, Header: http.Header{}})
+	Expect(retry).To(BeFalse())
+
+	retry, _ = bulk.Retryable(errors.New("parse error"))
+	Expect(retry).To(BeFalse())
+}
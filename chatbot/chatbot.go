@@ -0,0 +1,150 @@
+// Package chatbot reads the messages posted to a channel of Matrix, Slack,
+// or Discord and posts answers to it, through their HTTP APIs, for a bot
+// taking commands, and those sent to a Telegram bot.
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Message is a message posted to the channel.
+type Message struct {
+	ID string
+	// User is the ID of the user posting it, as in "@alice:example.org"
+	// on Matrix or "U012AB3CD" on Slack.
+	User string
+	Text string
+}
+
+// Channel is a channel of a chat service.
+type Channel interface {
+	// Poll waits for the messages posted by others since the last call, in
+	// the order they were posted. The first call returns none, not to
+	// answer those posted before the bot started.
+	Poll(ctx context.Context) ([]Message, error)
+	// Send posts text to the channel.
+	Send(ctx context.Context, text string) error
+}
+
+// Config names a channel and the bot posting to it.
+type Config struct {
+	// Service is "matrix", "slack", or "discord".
+	Service string
+	// URL is the origin of the API: that of the homeserver for Matrix,
+	// https://matrix.org by default, and of Slack or Discord otherwise,
+	// for tests.
+	URL string
+	// Token is the access token of the bot account on Matrix, the bot
+	// token of a Slack app ("xoxb-..."), or that of a Discord bot.
+	Token string
+	// Channel is the room ID on Matrix, as in "!abc:example.org", and the
+	// channel ID on Slack and Discord.
+	Channel string
+	// Interval is the wait between polls of Slack and Discord, five
+	// seconds by default. Matrix waits on the server.
+	Interval time.Duration
+}
+
+// New returns the channel of c.
+func New(c Config) (Channel, error) {
+	if c.Token == "" || c.Channel == "" {
+		return nil, fmt.Errorf("a token and a channel are needed")
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	h := &httpAPI{client: &http.Client{Timeout: time.Minute}}
+	switch c.Service {
+	case "matrix":
+		h.origin, h.auth = "https://matrix.org", "Bearer "+c.Token
+		if c.URL != "" {
+			h.origin = c.URL
+		}
+		return &matrixChannel{api: h, room: c.Channel}, nil
+	case "slack":
+		h.origin, h.auth = "https://slack.com", "Bearer "+c.Token
+		if c.URL != "" {
+			h.origin = c.URL
+		}
+		return &slackChannel{api: h, channel: c.Channel, interval: c.Interval}, nil
+	case "discord":
+		h.origin, h.auth = "https://discord.com", "Bot "+c.Token
+		if c.URL != "" {
+			h.origin = c.URL
+		}
+		return &discordChannel{api: h, channel: c.Channel, interval: c.Interval}, nil
+	}
+	return nil, fmt.Errorf(`unknown chat service %q; use "matrix", "slack", or "discord"`, c.Service)
+}
+
+// httpAPI makes the JSON requests of a service.
+type httpAPI struct {
+	client *http.Client
+	origin string
+	// auth is the Authorization header, if any.
+	auth string
+}
+
+// StatusError is a response of a service with an unexpected status.
+type StatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.Status, e.Body)
+}
+
+// do sends a request with body, if not nil, encoded as JSON, and decodes
+// the response into v.
+func (h *httpAPI) do(ctx context.Context, method, path string, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.origin+path, r)
+	if err != nil {
+		return err
+	}
+	if h.auth != "" {
+		req.Header.Set("Authorization", h.auth)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{Status: resp.StatusCode, Body: string(bytes.TrimSpace(b))}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package chatbot_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/motemen/go-pocket/chatbot"
+	. "github.com/onsi/gomega"
+)
+
+// fakeService answers the requests of a channel with handle, keeping the
+// bodies posted and the Authorization headers.
+type fakeService struct {
+	sync.Mutex
+	posted []map[string]any
+	auth   string
+}
+
+func (f *fakeService) serve(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.Lock()
+		defer f.Unlock()
+		f.auth = r.Header.Get("Authorization")
+		if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			f.posted = append(f.posted, body)
+		}
+		handle(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestMatrix(t *testing.T) {
+	RegisterTestingT(t)
+
+	f := &fakeService{}
+	ts := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_matrix/client/v3/account/whoami":
+			fmt.Fprint(w, `{"user_id": "@bot:example.org"}`)
+		case r.URL.Path == "/_matrix/client/v3/sync" && r.URL.Query().Get("since") == "":
+			fmt.Fprint(w, `{"next_batch": "s1", "rooms": {"join": {"!room:example.org": {"timeline": {"events": [
+				{"type": "m.room.message", "event_id": "$old", "sender": "@alice:example.org", "content": {"msgtype": "m.text", "body": "!save https://example.com/old"}}
+			]}}}}}`)
+		case r.URL.Path == "/_matrix/client/v3/sync":
+			Expect(r.URL.Query().Get("since")).To(Equal("s1"))
+			fmt.Fprint(w, `{"next_batch": "s2", "rooms": {"join": {"!room:example.org": {"timeline": {"events": [
+				{"type": "m.room.message", "event_id": "$1", "sender": "@alice:example.org", "content": {"msgtype": "m.text", "body": "!list"}},
+				{"type": "m.room.message", "event_id": "$2", "sender": "@bot:example.org", "content": {"msgtype": "m.notice", "body": "No items"}}
+			]}}}}}`)
+		case strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/"):
+			fmt.Fprint(w, `{"event_id": "$3"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	c, err := chatbot.New(chatbot.Config{Service: "matrix", URL: ts.URL, Token: "t0ken", Channel: "!room:example.org"})
+	Expect(err).To(BeNil())
+	Expect(c.Poll(context.Background())).To(BeEmpty())
+	Expect(c.Poll(context.Background())).To(Equal([]chatbot.Message{{ID: "$1", User: "@alice:example.org", Text: "!list"}}))
+	Expect(c.Send(context.Background(), "No items")).To(Succeed())
+	Expect(f.posted).To(Equal([]map[string]any{{"msgtype": "m.notice", "body": "No items"}}))
+	Expect(f.auth).To(Equal("Bearer t0ken"))
+}
+
+func TestSlack(t *testing.T) {
+	RegisterTestingT(t)
+
+	f := &fakeService{}
+	ts := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/conversations.history":
+			Expect(r.URL.Query().Get("channel")).To(Equal("C1"))
+			if r.URL.Query().Get("oldest") == "" {
+				fmt.Fprint(w, `{"ok": true, "messages": [{"ts": "100.1", "user": "U1", "text": "before"}]}`)
+				return
+			}
+			Expect(r.URL.Query().Get("oldest")).To(Equal("100.1"))
+			fmt.Fprint(w, `{"ok": true, "messages": [
+				{"ts": "102.1", "bot_id": "B1", "text": "Saved"},
+				{"ts": "101.1", "user": "U1", "text": "!save <https://example.com/a?b=1&amp;c=2|example.com/a> &lt;3"}
+			]}`)
+		case "/api/chat.postMessage":
+			fmt.Fprint(w, `{"ok": false, "error": "not_in_channel"}`)
+		}
+	})
+
+	c, err := chatbot.New(chatbot.Config{Service: "slack", URL: ts.URL, Token: "xoxb-1", Channel: "C1", Interval: 1})
+	Expect(err).To(BeNil())
+	Expect(c.Poll(context.Background())).To(BeEmpty())
+	Expect(c.Poll(context.Background())).To(Equal([]chatbot.Message{{ID: "101.1", User: "U1", Text: "!save https://example.com/a?b=1&c=2 <3"}}))
+	Expect(c.Send(context.Background(), "Saved")).To(MatchError("slack: not_in_channel"))
+	Expect(f.posted).To(Equal([]map[string]any{{"channel": "C1", "text": "Saved"}}))
+}
+
+func TestDiscord(t *testing.T) {
+	RegisterTestingT(t)
+
+	f := &fakeService{}
+	ts := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.URL.Path).To(Equal("/api/v10/channels/42/messages"))
+		switch after := r.URL.Query().Get("after"); {
+		case r.Method == http.MethodPost:
+			fmt.Fprint(w, `{"id": "1003"}`)
+		case after == "":
+			fmt.Fprint(w, `[{"id": "999", "content": "before", "author": {"id": "7"}}]`)
+		default:
+			Expect(after).To(Equal("999"))
+			fmt.Fprint(w, `[
+				{"id": "1002", "content": "Saved", "author": {"id": "8", "bot": true}},
+				{"id": "1001", "content": "!list recipes", "author": {"id": "7"}},
+				{"id": "10000", "content": "!help", "author": {"id": "7"}}
+			]`)
+		}
+	})
+
+	c, err := chatbot.New(chatbot.Config{Service: "discord", URL: ts.URL, Token: "t0ken", Channel: "42", Interval: 1})
+	Expect(err).To(BeNil())
+	Expect(c.Poll(context.Background())).To(BeEmpty())
+	Expect(c.Poll(context.Background())).To(Equal([]chatbot.Message{
+		{ID: "1001", User: "7", Text: "!list recipes"},
+		{ID: "10000", User: "7", Text: "!help"},
+	}))
+	Expect(c.Send(context.Background(), strings.Repeat("x", 2500))).To(Succeed())
+	Expect([]rune(f.posted[0]["content"].(string))).To(HaveLen(2000))
+	Expect(f.auth).To(Equal("Bot t0ken"))
+}
+
+func TestNew(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := chatbot.New(chatbot.Config{Service: "irc", Token: "t", Channel: "#c"})
+	Expect(err).To(MatchError(ContainSubstring(`unknown chat service "irc"`)))
+	_, err = chatbot.New(chatbot.Config{Service: "slack"})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestTelegram(t *testing.T) {
+	RegisterTestingT(t)
+
+	f := &fakeService{}
+	ts := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bott0ken/getUpdates":
+			fmt.Fprint(w, `{"ok": true, "result": [
+				{"update_id": 5, "message": {"message_id": 1, "from": {"id": 7, "username": "alice"}, "chat": {"id": 7},
+					"text": "Read this #go", "entities": [{"type": "text_link", "offset": 0, "length": 4, "url": "https://go.dev/blog"}]}},
+				{"update_id": 6, "message": {"message_id": 2, "from": {"id": 8, "is_bot": true}, "chat": {"id": 7}, "text": "beep"}},
+				{"update_id": 7, "message": {"message_id": 3, "from": {"id": 7}, "chat": {"id": 7}, "caption": "https://example.com/photo"}}
+			]}`)
+		case "/bott0ken/sendMessage":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"ok": false, "description": "Bad Request: chat not found"}`)
+		}
+	})
+
+	bot, err := chatbot.NewTelegram(ts.URL, "t0ken")
+	Expect(err).To(BeNil())
+	messages, err := bot.Updates(context.Background())
+	Expect(err).To(BeNil())
+	Expect(messages).To(Equal([]chatbot.TelegramMessage{
+		{ID: 1, ChatID: 7, UserID: 7, Username: "alice", Text: "Read this #go", Links: []string{"https://go.dev/blog"}},
+		{ID: 3, ChatID: 7, UserID: 7, Text: "https://example.com/photo"},
+	}))
+	// Confirmed with the offset of the next poll
+	bot.Updates(context.Background())
+	Expect(f.posted[1]).To(HaveKeyWithValue("offset", float64(8)))
+
+	Expect(bot.Reply(context.Background(), messages[0], "Saved")).To(MatchError("telegram: Bad Request: chat not found"))
+	Expect(f.posted[2]).To(HaveKeyWithValue("reply_to_message_id", float64(1)))
+	Expect(f.auth).To(BeEmpty())
+}
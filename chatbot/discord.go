@@ -0,0 +1,87 @@
+package chatbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// discordMaxLength is the most characters of a message.
+const discordMaxLength = 2000
+
+// discordChannel is a channel polled through the REST API, for which the
+// bot needs the Message Content intent to read what others post.
+type discordChannel struct {
+	api      *httpAPI
+	channel  string
+	interval time.Duration
+	// after is the ID of the last message seen.
+	after string
+}
+
+type discordMessage struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Author  struct {
+		ID  string `json:"id"`
+		Bot bool   `json:"bot"`
+	} `json:"author"`
+}
+
+// snowflakeLess orders the IDs of Discord, numbers growing with time and
+// too large for JSON numbers.
+func snowflakeLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func (c *discordChannel) Poll(ctx context.Context) ([]Message, error) {
+	first := c.after == ""
+	query := url.Values{"limit": {"100"}}
+	if first {
+		query.Set("limit", "1")
+	} else {
+		if err := sleep(ctx, c.interval); err != nil {
+			return nil, err
+		}
+		query.Set("after", c.after)
+	}
+
+	var res []discordMessage
+	path := fmt.Sprintf("/api/v10/channels/%s/messages?%s", url.PathEscape(c.channel), query.Encode())
+	if err := c.api.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool { return snowflakeLess(res[i].ID, res[j].ID) })
+	if first {
+		c.after = "0"
+		if len(res) > 0 {
+			c.after = res[len(res)-1].ID
+		}
+		return nil, nil
+	}
+
+	messages := []Message{}
+	for _, m := range res {
+		c.after = m.ID
+		if m.Author.Bot {
+			continue
+		}
+		messages = append(messages, Message{ID: m.ID, User: m.Author.ID, Text: m.Content})
+	}
+	return messages, nil
+}
+
+func (c *discordChannel) Send(ctx context.Context, text string) error {
+	if r := []rune(text); len(r) > discordMaxLength {
+		text = string(r[:discordMaxLength-1]) + "…"
+	}
+	path := fmt.Sprintf("/api/v10/channels/%s/messages", url.PathEscape(c.channel))
+	// Links are not unfurled, as a list of them would fill the channel
+	return c.api.do(ctx, http.MethodPost, path, map[string]any{"content": text, "flags": 1 << 2}, nil)
+}
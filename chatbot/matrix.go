@@ -0,0 +1,105 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// matrixSyncTimeout is how long the homeserver holds a sync open waiting
+// for events.
+const matrixSyncTimeout = 30 * time.Second
+
+// matrixChannel is a room, followed with the sync API of the client-server
+// API, with the bot joined to it.
+type matrixChannel struct {
+	api  *httpAPI
+	room string
+	// self is the user ID of the bot.
+	self string
+	// since is the token of the last sync.
+	since string
+	txn   atomic.Int64
+}
+
+type matrixEvent struct {
+	Type    string `json:"type"`
+	ID      string `json:"event_id"`
+	Sender  string `json:"sender"`
+	Content struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"content"`
+}
+
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+func (c *matrixChannel) Poll(ctx context.Context) ([]Message, error) {
+	if c.self == "" {
+		var whoami struct {
+			UserID string `json:"user_id"`
+		}
+		if err := c.api.do(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil, &whoami); err != nil {
+			return nil, err
+		}
+		c.self = whoami.UserID
+	}
+
+	filter, err := json.Marshal(map[string]any{
+		"room": map[string]any{
+			"rooms":    []string{c.room},
+			"timeline": map[string]any{"types": []string{"m.room.message"}},
+			"state":    map[string]any{"types": []string{}},
+		},
+		"presence":     map[string]any{"types": []string{}},
+		"account_data": map[string]any{"types": []string{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"filter": {string(filter)}}
+	if c.since != "" {
+		query.Set("since", c.since)
+		query.Set("timeout", strconv.Itoa(int(matrixSyncTimeout/time.Millisecond)))
+	}
+
+	var res matrixSync
+	if err := c.api.do(ctx, http.MethodGet, "/_matrix/client/v3/sync?"+query.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	first := c.since == ""
+	c.since = res.NextBatch
+	if first {
+		return nil, nil
+	}
+
+	messages := []Message{}
+	for _, e := range res.Rooms.Join[c.room].Timeline.Events {
+		if e.Type != "m.room.message" || e.Sender == c.self || e.Content.MsgType != "m.text" {
+			continue
+		}
+		messages = append(messages, Message{ID: e.ID, User: e.Sender, Text: e.Content.Body})
+	}
+	return messages, nil
+}
+
+func (c *matrixChannel) Send(ctx context.Context, text string) error {
+	// The transaction ID makes retries of a request idempotent
+	txn := fmt.Sprintf("pocket-%d-%d", time.Now().UnixNano(), c.txn.Add(1))
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(c.room), txn)
+	return c.api.do(ctx, http.MethodPut, path, map[string]string{"msgtype": "m.notice", "body": text}, nil)
+}
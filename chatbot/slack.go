@@ -0,0 +1,104 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// slackChannel is a channel polled with conversations.history, for which
+// the bot needs the channels:history scope, or groups:history for private
+// channels, and chat:write to post.
+type slackChannel struct {
+	api      *httpAPI
+	channel  string
+	interval time.Duration
+	// oldest is the timestamp of the last message seen.
+	oldest string
+}
+
+type slackMessage struct {
+	TS      string `json:"ts"`
+	User    string `json:"user"`
+	Text    string `json:"text"`
+	BotID   string `json:"bot_id"`
+	Subtype string `json:"subtype"`
+}
+
+// slackLinkPattern matches the links Slack makes of URLs, as in
+// "<https://example.com|example.com>".
+var slackLinkPattern = regexp.MustCompile(`<(https?://[^|>]+)(\|[^>]*)?>`)
+
+// slackUnescaper undoes the escaping of "&", "<", and ">" in Slack text.
+var slackUnescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+// call calls a method of the Web API, which answers errors with "ok" false.
+func (c *slackChannel) call(ctx context.Context, method, path string, body, v any) error {
+	var raw json.RawMessage
+	if err := c.api.do(ctx, method, path, body, &raw); err != nil {
+		return err
+	}
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return errors.New("slack: " + res.Error)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (c *slackChannel) Poll(ctx context.Context) ([]Message, error) {
+	first := c.oldest == ""
+	query := url.Values{"channel": {c.channel}, "limit": {"100"}}
+	if first {
+		query.Set("limit", "1")
+	} else {
+		if err := sleep(ctx, c.interval); err != nil {
+			return nil, err
+		}
+		query.Set("oldest", c.oldest)
+	}
+
+	var res struct {
+		Messages []slackMessage `json:"messages"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/conversations.history?"+query.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	if first {
+		c.oldest = "0"
+		if len(res.Messages) > 0 {
+			c.oldest = res.Messages[0].TS
+		}
+		return nil, nil
+	}
+
+	messages := []Message{}
+	// Newest first
+	for i := len(res.Messages) - 1; i >= 0; i-- {
+		m := res.Messages[i]
+		c.oldest = m.TS
+		if m.BotID != "" || m.Subtype != "" {
+			continue
+		}
+		text := slackUnescaper.Replace(slackLinkPattern.ReplaceAllString(m.Text, "$1"))
+		messages = append(messages, Message{ID: m.TS, User: m.User, Text: text})
+	}
+	return messages, nil
+}
+
+func (c *slackChannel) Send(ctx context.Context, text string) error {
+	return c.call(ctx, http.MethodPost, "/api/chat.postMessage", map[string]string{"channel": c.channel, "text": text}, nil)
+}
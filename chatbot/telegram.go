@@ -0,0 +1,155 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// telegramPollTimeout is how long Telegram holds getUpdates open waiting for
+// messages.
+const telegramPollTimeout = 30 * time.Second
+
+// Telegram is a bot of the Telegram Bot API, taking the messages sent to it
+// by long polling. Unlike the channels of New, it answers each chat it is
+// messaged in, and messages sent while it was not polling are taken too, as
+// Telegram keeps them for a day.
+type Telegram struct {
+	api   *httpAPI
+	token string
+	// offset is the ID of the next update to take.
+	offset int64
+}
+
+// TelegramMessage is a message sent to the bot.
+type TelegramMessage struct {
+	ID     int64
+	ChatID int64
+	UserID int64
+	// Username is that of the sender, without "@", if they have one.
+	Username string
+	// Text is the text of the message, or the caption of a photo or the
+	// like.
+	Text string
+	// Links are the URLs of the links in the text whose text is not the
+	// URL itself.
+	Links []string
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		MessageID int64 `json:"message_id"`
+		From      struct {
+			ID       int64  `json:"id"`
+			IsBot    bool   `json:"is_bot"`
+			Username string `json:"username"`
+		} `json:"from"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text            string           `json:"text"`
+		Caption         string           `json:"caption"`
+		Entities        []telegramEntity `json:"entities"`
+		CaptionEntities []telegramEntity `json:"caption_entities"`
+	} `json:"message"`
+}
+
+type telegramEntity struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// NewTelegram returns the bot of token. origin is that of the Bot API,
+// https://api.telegram.org if empty.
+func NewTelegram(origin, token string) (*Telegram, error) {
+	if token == "" {
+		return nil, errors.New("a token is needed")
+	}
+	if origin == "" {
+		origin = "https://api.telegram.org"
+	}
+	return &Telegram{
+		api:   &httpAPI{client: &http.Client{Timeout: telegramPollTimeout + 30*time.Second}, origin: origin},
+		token: token,
+	}, nil
+}
+
+// call calls a method of the Bot API, which answers errors with "ok" false.
+func (t *Telegram) call(ctx context.Context, method string, body, v any) error {
+	var res struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	err := t.api.do(ctx, http.MethodPost, "/bot"+t.token+"/"+method, body, &res)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && json.Unmarshal([]byte(statusErr.Body), &res) == nil && res.Description != "" {
+		return fmt.Errorf("telegram: %s", res.Description)
+	}
+	if err != nil {
+		// Not to log the token, which is in the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram: %w", urlErr.Err)
+		}
+		return err
+	}
+	if !res.OK {
+		return fmt.Errorf("telegram: %s", res.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Result, v)
+}
+
+// Updates waits for the messages sent to the bot since the last call, and
+// returns them.
+func (t *Telegram) Updates(ctx context.Context) ([]TelegramMessage, error) {
+	var updates []telegramUpdate
+	err := t.call(ctx, "getUpdates", map[string]any{
+		"offset":          t.offset,
+		"timeout":         int(telegramPollTimeout / time.Second),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []TelegramMessage{}
+	for _, u := range updates {
+		t.offset = max(t.offset, u.UpdateID+1)
+		m := u.Message
+		if m == nil || m.From.IsBot {
+			continue
+		}
+		text, entities := m.Text, m.Entities
+		if text == "" {
+			text, entities = m.Caption, m.CaptionEntities
+		}
+		msg := TelegramMessage{ID: m.MessageID, ChatID: m.Chat.ID, UserID: m.From.ID, Username: m.From.Username, Text: text}
+		for _, e := range entities {
+			if e.Type == "text_link" {
+				msg.Links = append(msg.Links, e.URL)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// Reply answers m with text, in its chat, without previews of the links.
+func (t *Telegram) Reply(ctx context.Context, m TelegramMessage, text string) error {
+	return t.call(ctx, "sendMessage", map[string]any{
+		"chat_id":                  strconv.FormatInt(m.ChatID, 10),
+		"text":                     text,
+		"reply_to_message_id":      m.ID,
+		"disable_web_page_preview": true,
+	}, nil)
+}
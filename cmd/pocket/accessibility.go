@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// screenReader, set by --screen-reader or "screen_reader" in config.json,
+// keeps the output linear for screen readers: progress is reported in whole
+// lines instead of redrawn in place, there are no colors, hyperlinks, or
+// full-screen interfaces, prompts spell out the answers they take, and the
+// choices of triage and pick are numbered.
+var screenReader bool
+
+// progressSteps is the number of lines progress is reported in for screen
+// readers, besides the last one.
+const progressSteps = 10
+
+// spokenProgressReporter reports progress on stderr in a line every tenth of
+// the way, as in "Checking links: 12 of 120 done".
+func spokenProgressReporter(label string) func(done, total int) {
+	reported := 0
+	return func(done, total int) {
+		step := done * progressSteps / total
+		if step > reported || done == total {
+			reported = step
+			fmt.Fprintln(os.Stderr, tr(msgProgress, map[string]interface{}{"Label": label, "Done": done, "Total": total}))
+		}
+	}
+}
+
+// maxNumberedChoices bounds the items listed by chooseNumbered before asking
+// for words to narrow them down.
+const maxNumberedChoices = 20
+
+// chooseNumbered lets the user choose among lines by their numbers in a
+// list of their labels, asking first for words to narrow it down if it is
+// long, and returns the indexes of those chosen.
+func chooseNumbered(labels, lines []string) []int {
+	reader := stdin
+
+	indexes := make([]int, len(lines))
+	for i := range lines {
+		indexes[i] = i
+	}
+	for len(indexes) > maxNumberedChoices {
+		query := readLine(reader, tr(msgNarrowDown, map[string]int{"Count": len(indexes)}))
+		if query == "" {
+			break
+		}
+		matching := []int{}
+		for _, i := range indexes {
+			line := lines[i]
+			if fuzzyScore(query, line[strings.IndexByte(line, '\t')+1:]) >= 0 {
+				matching = append(matching, i)
+			}
+		}
+		if len(matching) == 0 {
+			fmt.Println(tr(msgNoMatches, nil))
+			continue
+		}
+		indexes = matching
+	}
+
+	for n, i := range indexes {
+		fmt.Printf("%d. %s\n", n+1, labels[i])
+	}
+	for {
+		answer := readLine(reader, tr(msgChooseNumbers, nil))
+		chosen, err := parseNumbers(answer, len(indexes))
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		chosenIndexes := []int{}
+		for _, n := range chosen {
+			chosenIndexes = append(chosenIndexes, indexes[n-1])
+		}
+		return chosenIndexes
+	}
+}
+
+// parseNumbers parses numbers from 1 to max and ranges of them, as in
+// "1 3-5" or "2,4".
+func parseNumbers(s string, max int) ([]int, error) {
+	seen := map[int]bool{}
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		if !isRange {
+			to = from
+		}
+		a, errA := strconv.Atoi(from)
+		b, errB := strconv.Atoi(to)
+		if errA != nil || errB != nil || a < 1 || b > max || a > b {
+			return nil, &localizedError{msgBadNumber, map[string]interface{}{"Number": field, "Max": max}}
+		}
+		for n := a; n <= b; n++ {
+			seen[n] = true
+		}
+	}
+	numbers := make([]int, 0, len(seen))
+	for n := range seen {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// defaultAccount names the account authorized in auth.json, used by every
+// command. Other accounts are authorized in auth-<name>.json as they are
+// first named.
+const defaultAccount = "default"
+
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// accountAuthFile returns the file holding the access token of an account.
+func accountAuthFile(account string) (string, error) {
+	if account == "" || account == defaultAccount {
+		return filepath.Join(configDir, "auth.json"), nil
+	}
+	if !accountNamePattern.MatchString(account) {
+		return "", fmt.Errorf("invalid account name %q; use letters, digits, - and _", account)
+	}
+	return filepath.Join(configDir, "auth-"+account+".json"), nil
+}
+
+// accountClient returns a client for an account, authorizing it first if it
+// is new.
+func accountClient(consumerKey, account string) (*api.Client, error) {
+	accessToken, err := restoreAccessToken(consumerKey, account)
+	if err != nil {
+		return nil, err
+	}
+	client := api.NewClient(consumerKey, accessToken.AccessToken)
+	audit.Lock()
+	audit.accounts[client] = account
+	audit.Unlock()
+	return client, nil
+}
+
+// fromToClients returns the clients of the accounts named by --from and --to,
+// client being that of the default account.
+func fromToClients(conf Config, command, consumerKey string, client *api.Client) (from, to *api.Client) {
+	switch {
+	case conf.To == "":
+		exitWithError(conf, &usageError{command: command, err: errors.New("missing --to")})
+	case conf.To == conf.From:
+		exitWithError(conf, &usageError{command: command, err: errors.New("--from and --to name the same account")})
+	}
+
+	clients := map[string]*api.Client{defaultAccount: client}
+	for _, account := range []string{conf.From, conf.To} {
+		if clients[account] != nil {
+			continue
+		}
+		c, err := accountClient(consumerKey, account)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		clients[account] = c
+	}
+	return clients[conf.From], clients[conf.To]
+}
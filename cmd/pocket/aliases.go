@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// builtinAliases are the short names of commands always available.
+var builtinAliases = map[string]string{
+	"ls": "list",
+	"rm": "delete",
+}
+
+// maxAliasDepth bounds the expansion of aliases defined by other aliases.
+const maxAliasDepth = 10
+
+// splitWords splits a command line into words at spaces, keeping quoted
+// strings together as the shell does.
+func splitWords(s string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// allAliases merges the builtin aliases with those of the settings, which
+// take precedence. Aliases named like a command are ignored.
+func allAliases(settings *Settings) map[string]string {
+	aliases := map[string]string{}
+	for name, expansion := range builtinAliases {
+		aliases[name] = expansion
+	}
+	for name, expansion := range settings.Aliases {
+		if _, ok := findCommand(name); !ok {
+			aliases[name] = expansion
+		}
+	}
+	return aliases
+}
+
+// expandAliases replaces an alias at the start of args with its expansion,
+// the rest of args following it. Aliases may expand to other aliases.
+func expandAliases(args []string, aliases map[string]string) ([]string, error) {
+	for depth := 0; len(args) > 0; depth++ {
+		expansion, ok := aliases[args[0]]
+		if !ok {
+			return args, nil
+		}
+		if depth == maxAliasDepth {
+			return nil, fmt.Errorf("alias %q expands too deeply; is it defined in terms of itself?", args[0])
+		}
+
+		words, err := splitWords(expansion)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %w", args[0], err)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("alias %q is empty", args[0])
+		}
+		args = append(words, args[1:]...)
+	}
+	return args, nil
+}
+
+// aliasNames returns the names of the aliases in order.
+func aliasNames(aliases map[string]string) []string {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
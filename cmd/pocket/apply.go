@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// applyActions are the actions taken by apply, with whether they need an
+// item.
+var applyActions = map[string]bool{
+	"add":         false,
+	"archive":     true,
+	"readd":       true,
+	"delete":      true,
+	"favorite":    true,
+	"unfavorite":  true,
+	"tags_add":    true,
+	"tags_remove": true,
+}
+
+// tagList is a list of tags given either as an array or as a comma-separated
+// string.
+type tagList []string
+
+func (t *tagList) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = splitTags(s)
+		return nil
+	}
+	var tags []string
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return errors.New("tags must be a string or an array of strings")
+	}
+	*t = tags
+	return nil
+}
+
+// applyLine is a line of the file given to apply. The item is given by its
+// ID, or by its URL. Lines written by --log-actions are taken as they are,
+// with their time.
+type applyLine struct {
+	Action string      `json:"action"`
+	ItemID json.Number `json:"item_id"`
+	URL    string      `json:"url"`
+	Title  string      `json:"title"`
+	Tags   tagList     `json:"tags"`
+	Time   json.Number `json:"time"`
+}
+
+// applyResult is the outcome of a line.
+type applyResult struct {
+	Line    int    `json:"line"`
+	Action  string `json:"action"`
+	ItemID  int    `json:"item_id,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// readApplyLines reads the lines of r, skipping blank ones and those starting
+// with "#". Every invalid line is reported in the error.
+func readApplyLines(r io.Reader) (lines []applyLine, numbers []int, err error) {
+	problems := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var line applyLine
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&line); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		if err := line.validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		lines = append(lines, line)
+		numbers = append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(problems) > 0 {
+		return nil, nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return lines, numbers, nil
+}
+
+func (l applyLine) validate() error {
+	needsItem, ok := applyActions[l.Action]
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown action %q", l.Action)
+	case !needsItem && l.URL == "":
+		return errors.New("missing url")
+	case needsItem && l.ItemID == "" && l.URL == "":
+		return errors.New("missing item_id or url")
+	case (l.Action == "tags_add" || l.Action == "tags_remove") && len(l.Tags) == 0:
+		return errors.New("missing tags")
+	}
+	if l.ItemID != "" {
+		if _, err := strconv.Atoi(string(l.ItemID)); err != nil {
+			return fmt.Errorf("invalid item_id %q", l.ItemID)
+		}
+	}
+	if l.Time != "" {
+		if _, err := l.Time.Int64(); err != nil {
+			return fmt.Errorf("invalid time %q", l.Time)
+		}
+	}
+	return nil
+}
+
+// action returns the action of the line, given the IDs of the items by their
+// cleaned up URL to look up items given by URL.
+func (l applyLine) action(idsByURL map[string]int) (*api.Action, error) {
+	at, _ := l.Time.Int64()
+	if l.Action == "add" {
+		a := api.NewAddAction(l.URL, l.Title, l.Tags...)
+		a.Time = at
+		return a, nil
+	}
+
+	itemID, _ := strconv.Atoi(string(l.ItemID))
+	if l.ItemID == "" {
+		id, ok := idsByURL[library.CleanURL(l.URL)]
+		if !ok {
+			return nil, fmt.Errorf("no item with url %s", l.URL)
+		}
+		itemID = id
+	}
+	return &api.Action{Action: l.Action, ItemID: itemID, Time: at, Tags: strings.Join(l.Tags, ",")}, nil
+}
+
+func commandApply(conf Config, client *api.Client) {
+	if conf.Output != "text" && conf.Output != "json" {
+		exitWithError(conf, &usageError{command: "apply", err: fmt.Errorf("unknown output %q; use \"text\" or \"json\"", conf.Output)})
+	}
+
+	var r io.Reader = os.Stdin
+	if conf.File != "-" {
+		f, err := os.Open(conf.File)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	lines, numbers, err := readApplyLines(r)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	idsByURL := map[string]int{}
+	for _, line := range lines {
+		if line.Action != "add" && line.ItemID == "" {
+			items, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll})
+			if err != nil {
+				panic(err)
+			}
+			for _, item := range items {
+				idsByURL[library.CleanURL(item.URL())] = item.ItemID
+			}
+			break
+		}
+	}
+
+	actions := make([]*api.Action, len(lines))
+	problems := []string{}
+	for i, line := range lines {
+		actions[i], err = line.action(idsByURL)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", numbers[i], err))
+		}
+	}
+	if len(problems) > 0 {
+		exitWithError(conf, errors.New(strings.Join(problems, "\n")))
+	}
+
+	if conf.DryRun {
+		fmt.Fprintf(os.Stderr, "%d actions are valid\n", len(actions))
+		return
+	}
+
+	summary := newBulkSummary("apply")
+	results, err := modifyInBatches(client, actions)
+	report := make([]applyResult, len(actions))
+	for i, action := range actions {
+		report[i] = applyResult{Line: numbers[i], Action: action.Action, ItemID: action.ItemID, Success: results[i].Success}
+		if action.Action == "add" {
+			report[i].ItemID = results[i].ItemID
+		}
+		switch {
+		case report[i].Success:
+			summary.Processed++
+			summary.Succeeded++
+			continue
+		case err != nil:
+			report[i].Error = "not sent: " + err.Error()
+		case results[i].Error != "":
+			report[i].Error = results[i].Error
+		default:
+			report[i].Error = "failed"
+		}
+		if err == errOverBudget {
+			summary.addNotSent(1)
+		} else {
+			summary.Processed++
+			summary.fail(action, fmt.Sprintf("line %d: %s", numbers[i], report[i].Error))
+		}
+	}
+
+	if conf.Output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			panic(err)
+		}
+	}
+	summary.report(conf.Summary)
+}
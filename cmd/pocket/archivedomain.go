@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// domainPreviewTitles is the number of titles shown before asking to archive
+// the items of a domain.
+const domainPreviewTitles = 5
+
+// domainCount is the number of items from a host.
+type domainCount struct {
+	Domain string
+	Count  int
+}
+
+// countByDomain counts the items by their domain, most first.
+func countByDomain(items []api.Item) []domainCount {
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[item.Domain()]++
+	}
+
+	result := make([]domainCount, 0, len(counts))
+	for domain, count := range counts {
+		result = append(result, domainCount{domain, count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Domain < result[j].Domain
+	})
+	return result
+}
+
+func commandArchiveDomain(conf Config, client *api.Client) {
+	items := []api.Item{}
+	seen := map[int]bool{}
+	for _, domain := range conf.Domains {
+		found, err := retrieveItems(client, &api.RetrieveOption{
+			State:  api.State(conf.State),
+			Domain: domain,
+		})
+		if err != nil {
+			panic(err)
+		}
+		for _, item := range found {
+			if !seen[item.ItemID] {
+				seen[item.ItemID] = true
+				items = append(items, item)
+			}
+		}
+	}
+
+	if len(items) == 0 {
+		fmt.Fprintf(os.Stderr, "No %s items from %s\n", conf.State, strings.Join(conf.Domains, ", "))
+		return
+	}
+
+	verb, command, newAction := "Archive", "archive", api.NewArchiveAction
+	if conf.DeleteAll {
+		verb, command, newAction = "Delete", "delete", api.NewDeleteAction
+	}
+
+	fmt.Printf("%d %s items:\n", len(items), conf.State)
+	for _, c := range countByDomain(items) {
+		fmt.Printf("  %5d  %s\n", c.Count, c.Domain)
+	}
+	for _, item := range items[:min(len(items), domainPreviewTitles)] {
+		fmt.Printf("  %s\n", item.Title())
+	}
+	if len(items) > domainPreviewTitles {
+		fmt.Printf("  and %d more\n", len(items)-domainPreviewTitles)
+	}
+
+	op := confirmArchive
+	if conf.DeleteAll {
+		op = confirmDelete
+	}
+	if !confirmOperation(conf, op, len(items), fmt.Sprintf("%s %d items?", verb, len(items))) {
+		return
+	}
+
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ItemID
+	}
+	summary := newBulkSummary(command)
+	summary.describe(items)
+	modifyItems(client, ids, newAction, summary)
+	summary.report(conf.Summary)
+}
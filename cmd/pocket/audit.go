@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/render"
+)
+
+// auditEntry is a change pocket made to an account, as recorded in the
+// audit log.
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	// Account and Command are the account changed and the pocket command
+	// that changed it.
+	Account string `json:"account"`
+	Command string `json:"command"`
+	Action  string `json:"action"`
+	ItemID  int    `json:"item_id,omitempty"`
+	// URL and Title are known for the items added, and filled in from the
+	// mirror for the others when shown.
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+	Tags  string `json:"tags,omitempty"`
+}
+
+// audit is the state of the audit log of this process: the command run,
+// and the account of each client, named as by accountClient.
+var audit = struct {
+	sync.Mutex
+	command  string
+	accounts map[*api.Client]string
+}{accounts: map[*api.Client]string{}}
+
+// auditPath is the log of every change pocket made to the accounts, one JSON
+// entry per line. It is only ever appended to.
+func auditPath() string {
+	return filepath.Join(configDir, "audit.jsonl")
+}
+
+// recordAudit appends the actions made with c, given their results, to the
+// audit log. It is api.OnModify; failing to is only logged.
+func recordAudit(c *api.Client, actions []*api.Action, results []api.ActionResult) {
+	audit.Lock()
+	defer audit.Unlock()
+
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	now := time.Now()
+	for i, action := range actions {
+		e := auditEntry{
+			Time:    now,
+			Account: audit.accounts[c],
+			Command: audit.command,
+			Action:  action.Action,
+			ItemID:  action.ItemID,
+			URL:     action.URL,
+			Title:   action.Title,
+			Tags:    action.Tags,
+		}
+		if i < len(results) && results[i].ItemID != 0 {
+			e.ItemID = results[i].ItemID
+		}
+		if err := enc.Encode(e); err != nil {
+			slog.Warn("Could not record a change in the audit log", "err", err)
+			return
+		}
+	}
+
+	f, err := os.OpenFile(auditPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err == nil {
+		_, err = f.WriteString(b.String())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		slog.Warn("Could not record the changes in the audit log", "err", err)
+	}
+}
+
+// readAudit returns the entries of the audit log made since, about itemID
+// unless it is zero.
+func readAudit(since time.Time, itemID int) ([]auditEntry, error) {
+	f, err := os.Open(auditPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []auditEntry{}
+	// The URLs of the items added, for the later changes to them
+	urls := map[int]auditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A line cut short by a crash
+			continue
+		}
+		if e.URL != "" {
+			urls[e.ItemID] = e
+		} else if added, ok := urls[e.ItemID]; ok {
+			e.URL, e.Title = added.URL, added.Title
+		}
+		if e.Time.Before(since) || itemID != 0 && e.ItemID != itemID {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// describeAudit describes an entry of the audit log for humans.
+func describeAudit(e auditEntry) string {
+	parts := []string{fmt.Sprintf("%-16s  %-11s [%9d]", formatTime(e.Time, "2006-01-02 15:04"), e.Action, e.ItemID)}
+	if e.URL != "" {
+		parts = append(parts, e.URL)
+	}
+	if e.Tags != "" {
+		parts = append(parts, "tags: "+e.Tags)
+	}
+	by := "pocket " + e.Command
+	if e.Account != "" && e.Account != defaultAccount {
+		by += ", account " + e.Account
+	}
+	return strings.Join(parts, "  ") + "  (" + by + ")"
+}
+
+func commandAudit(conf Config, client *api.Client) {
+	checkOutput(conf, "audit")
+	var since time.Time
+	if conf.Since != "" {
+		var err error
+		since, err = parseSince(conf.Since, time.Now())
+		if err != nil {
+			exitWithError(conf, &usageError{command: "audit", err: err})
+		}
+	}
+
+	entries, err := readAudit(since, conf.ItemID)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	// The URLs of the items not added by pocket come from the mirror, if
+	// it still has them
+	if m, err := openMirror(); err == nil {
+		items, err := m.Items()
+		m.Close()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		mirrored := map[int]*api.Item{}
+		for i := range items {
+			mirrored[items[i].ItemID] = &items[i]
+		}
+		for i, e := range entries {
+			if item := mirrored[e.ItemID]; item != nil && e.URL == "" {
+				entries[i].URL, entries[i].Title = item.URL(), item.Title()
+			}
+		}
+	}
+
+	renderOutput(conf, "audit", &render.Result{
+		Value: entries,
+		Text: func(w io.Writer) error {
+			for _, e := range entries {
+				fmt.Fprintln(w, describeAudit(e))
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+	"github.com/motemen/go-pocket/llm"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// autotagBatch is the most items embedded by each run of the daemon, so
+// that a large library is gone through over several syncs.
+const autotagBatch = 200
+
+// autotagChunk is the number of texts sent to the model at once.
+const autotagChunk = 32
+
+// autotagMaxTags is the most tags suggested for an item.
+const autotagMaxTags = 3
+
+// embeddingsPath is the file keeping the embeddings of items, sealed as the
+// search index is.
+func embeddingsPath() string {
+	return filepath.Join(configDir, "embeddings.gob")
+}
+
+// embedding is the vector of the title and excerpt of an item, with the
+// model that made it and a hash of the text, to tell when it is out of date.
+type embedding struct {
+	Model  string
+	Hash   uint64
+	Vector []float32
+}
+
+// loadEmbeddings returns the embeddings made so far by item ID.
+func loadEmbeddings() (map[int]embedding, error) {
+	embeddings := map[int]embedding{}
+	b, err := os.ReadFile(embeddingsPath())
+	if os.IsNotExist(err) {
+		return embeddings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := cacheCipher()
+	if err != nil {
+		return nil, err
+	}
+	b, err = mirror.Unseal(c, b)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, gob.NewDecoder(bytes.NewReader(b)).Decode(&embeddings)
+}
+
+func saveEmbeddings(embeddings map[int]embedding) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(embeddings); err != nil {
+		return err
+	}
+	c, err := cacheCipher()
+	if err != nil {
+		return err
+	}
+	b, err := mirror.Seal(c, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(embeddingsPath(), b, 0600)
+}
+
+// autotagText is the text of item embedded: its title and excerpt.
+func autotagText(item api.Item) string {
+	return strings.TrimSpace(item.Title() + "\n" + item.Excerpt)
+}
+
+func textHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// cosine is the cosine similarity of a and b, 0 if they differ in length.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// tagSuggestion is a tag suggested for an item, with its share of the votes
+// of the neighbors of the item.
+type tagSuggestion struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+// suggestTags suggests tags for vector from the tags of the k tagged items
+// closest to it, each voting with its similarity for its tags. The tags
+// with at least minScore of the votes are suggested, best first.
+func suggestTags(vector []float32, tagged []api.Item, embeddings map[int]embedding, k int, minScore float64) []tagSuggestion {
+	type neighbor struct {
+		item  api.Item
+		score float64
+	}
+	neighbors := make([]neighbor, 0, len(tagged))
+	for _, item := range tagged {
+		if score := cosine(vector, embeddings[item.ItemID].Vector); score > 0 {
+			neighbors = append(neighbors, neighbor{item, score})
+		}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool { return neighbors[i].score > neighbors[j].score })
+	neighbors = neighbors[:min(len(neighbors), k)]
+
+	var total float64
+	votes := map[string]float64{}
+	for _, n := range neighbors {
+		total += n.score
+		for _, tag := range n.item.TagNames() {
+			votes[tag] += n.score
+		}
+	}
+	suggestions := []tagSuggestion{}
+	for tag, v := range votes {
+		if score := v / total; score >= minScore {
+			suggestions = append(suggestions, tagSuggestion{tag, score})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Tag < suggestions[j].Tag
+	})
+	return suggestions[:min(len(suggestions), autotagMaxTags)]
+}
+
+// autotagResult is what an autotag call found.
+type autotagResult struct {
+	Embedded int
+	// Untagged are the unread items without tags, of which those in
+	// Suggestions have tags suggested.
+	Untagged    []api.Item
+	Suggestions map[int][]tagSuggestion
+}
+
+// actions are the actions giving the untagged items the tags suggested.
+func (r *autotagResult) actions() []*api.Action {
+	actions := []*api.Action{}
+	for _, item := range r.Untagged {
+		if suggestions := r.Suggestions[item.ItemID]; len(suggestions) > 0 {
+			tags := make([]string, len(suggestions))
+			for i, s := range suggestions {
+				tags[i] = s.Tag
+			}
+			actions = append(actions, api.NewTagsAddAction(item.ItemID, tags...))
+		}
+	}
+	return actions
+}
+
+// autotagEmbedder sets up the model of the autotag setting.
+func autotagEmbedder(s AutoTagSettings) (llm.Embedder, error) {
+	if s.Model == "" {
+		return nil, errors.New(`set "autotag": {"backend": "ollama", "model": "nomic-embed-text"}, or another embedding model, in config.json first`)
+	}
+	return llm.NewEmbedder(s.Backend, s.URL, s.Model, modelAPIKey(s.APIKey))
+}
+
+// autotag embeds the items in the mirror not embedded yet with the model of
+// s, or whose title or excerpt changed, up to limit of them if it is not
+// zero, the untagged unread items first. It then suggests tags for the
+// untagged unread items from those of the tagged items closest to them.
+// The embeddings of items no longer in the mirror are dropped.
+func autotag(m *mirror.Mirror, s AutoTagSettings, limit int) (*autotagResult, error) {
+	embedder, err := autotagEmbedder(s)
+	if err != nil {
+		return nil, err
+	}
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	embeddings, err := loadEmbeddings()
+	if err != nil {
+		return nil, err
+	}
+
+	mirrored := map[int]bool{}
+	for _, item := range items {
+		mirrored[item.ItemID] = true
+	}
+	for id, e := range embeddings {
+		if !mirrored[id] || e.Model != s.Model {
+			delete(embeddings, id)
+		}
+	}
+
+	result := &autotagResult{Suggestions: map[int][]tagSuggestion{}}
+	tagged := []api.Item{}
+	for _, item := range items {
+		switch {
+		case len(item.Tags) > 0:
+			tagged = append(tagged, item)
+		case item.Status == api.ItemStatusUnread:
+			result.Untagged = append(result.Untagged, item)
+		}
+	}
+
+	stale := []api.Item{}
+	for _, item := range append(append([]api.Item{}, result.Untagged...), tagged...) {
+		if e, ok := embeddings[item.ItemID]; !ok || e.Hash != textHash(autotagText(item)) {
+			stale = append(stale, item)
+		}
+	}
+	if limit > 0 {
+		stale = stale[:min(len(stale), limit)]
+	}
+	for len(stale) > 0 && !interruptRequested() {
+		chunk := stale[:min(len(stale), autotagChunk)]
+		stale = stale[len(chunk):]
+
+		texts := make([]string, len(chunk))
+		for i, item := range chunk {
+			texts[i] = autotagText(item)
+		}
+		vectors, err := embedder.Embed(context.Background(), texts)
+		if err != nil {
+			// Those embedded so far are kept for the next run
+			if saveErr := saveEmbeddings(embeddings); saveErr != nil {
+				return nil, saveErr
+			}
+			return nil, err
+		}
+		for i, item := range chunk {
+			embeddings[item.ItemID] = embedding{Model: s.Model, Hash: textHash(texts[i]), Vector: vectors[i]}
+		}
+		result.Embedded += len(chunk)
+	}
+	if err := saveEmbeddings(embeddings); err != nil {
+		return nil, err
+	}
+
+	k := s.Neighbors
+	if k <= 0 {
+		k = 10
+	}
+	minScore := s.MinScore
+	if minScore <= 0 {
+		minScore = 0.5
+	}
+	references := []api.Item{}
+	for _, item := range tagged {
+		if _, ok := embeddings[item.ItemID]; ok {
+			references = append(references, item)
+		}
+	}
+	for _, item := range result.Untagged {
+		e, ok := embeddings[item.ItemID]
+		if !ok {
+			continue
+		}
+		if suggestions := suggestTags(e.Vector, references, embeddings, k, minScore); len(suggestions) > 0 {
+			result.Suggestions[item.ItemID] = suggestions
+		}
+	}
+	return result, nil
+}
+
+func commandAutoTag(conf Config, client *api.Client) {
+	checkListingOutput(conf.Output)
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	m, err := openMirror()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	result, err := autotag(m, settings.AutoTag, 0)
+	m.Close()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	if result.Embedded > 0 {
+		fmt.Fprintf(os.Stderr, "Embedded %d items with %s\n", result.Embedded, settings.AutoTag.Model)
+	}
+	if len(result.Untagged) == 0 {
+		fmt.Fprintln(os.Stderr, `No untagged unread items in the local mirror; run "pocket sync" first if there should be`)
+		return
+	}
+
+	suggested := []api.Item{}
+	for _, item := range result.Untagged {
+		if len(result.Suggestions[item.ItemID]) > 0 {
+			suggested = append(suggested, item)
+		}
+	}
+	if conf.Output == "ids" {
+		printItemIDs(suggested)
+	} else {
+		for _, item := range suggested {
+			scored := []string{}
+			for _, s := range result.Suggestions[item.ItemID] {
+				scored = append(scored, fmt.Sprintf("%s (%.2f)", s.Tag, s.Score))
+			}
+			fmt.Printf("[%d] %s\n    %s\n", item.ItemID, item.Title(), strings.Join(scored, ", "))
+		}
+		fmt.Printf("%d of %d untagged items with tags suggested\n", len(suggested), len(result.Untagged))
+	}
+
+	if !conf.ApplyTags || len(suggested) == 0 {
+		return
+	}
+	summary := newBulkSummary("autotag")
+	summary.describe(suggested)
+	defer summary.report(conf.Summary)
+
+	actions := result.actions()
+	results, err := modifyInBatches(client, actions)
+	summary.addResults(actions, results, err)
+}
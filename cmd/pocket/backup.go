@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// backupTimeLayout is the layout of the time in the names of backups.
+const backupTimeLayout = "20060102T150405Z"
+
+// backupFilePattern matches the names of the backups written by the backup
+// command.
+var backupFilePattern = regexp.MustCompile(`^pocket-(\d{8}T\d{6}Z)\.json$`)
+
+// backupFile is a backup in the backup directory.
+type backupFile struct {
+	name string
+	time time.Time
+}
+
+// listBackups returns the backups in dir, newest first.
+func listBackups(dir string) ([]backupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []backupFile{}
+	for _, entry := range entries {
+		m := backupFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		t, err := time.Parse(backupTimeLayout, m[1])
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{name: entry.Name(), time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+	return backups, nil
+}
+
+// expiredBackups returns the backups, given newest first, that fall out of
+// retention: the newest backup is kept, along with the newest of each of the
+// last daily days and weekly weeks that have backups.
+func expiredBackups(backups []backupFile, daily, weekly int) []backupFile {
+	keep := map[string]bool{}
+	if len(backups) > 0 {
+		keep[backups[0].name] = true
+	}
+
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for _, b := range backups {
+		t := b.time.Local()
+		day := t.Format("2006-01-02")
+		if !days[day] && len(days) < daily {
+			days[day] = true
+			keep[b.name] = true
+		}
+		year, w := t.ISOWeek()
+		week := fmt.Sprintf("%d-%d", year, w)
+		if !weeks[week] && len(weeks) < weekly {
+			weeks[week] = true
+			keep[b.name] = true
+		}
+	}
+
+	expired := []backupFile{}
+	for _, b := range backups {
+		if !keep[b.name] {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// writeBackup exports items into a new backup in dir, written to a
+// temporary file first so that a backup is never left half written.
+func writeBackup(dir string, items []api.Item, now time.Time) (string, error) {
+	f, err := os.CreateTemp(dir, ".pocket-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	err = writeJSONBackup(f, items)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name := "pocket-" + now.UTC().Format(backupTimeLayout) + ".json"
+	return name, os.Rename(f.Name(), filepath.Join(dir, name))
+}
+
+func commandBackup(conf Config, client *api.Client) {
+	if conf.KeepDaily < 0 || conf.KeepWeekly < 0 {
+		exitWithError(conf, &usageError{command: "backup", err: fmt.Errorf("--keep-daily and --keep-weekly cannot be negative")})
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:       api.StateAll,
+		DetailType:  api.DetailTypeComplete,
+		Annotations: true,
+	})
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	if err := os.MkdirAll(conf.BackupDir, 0700); err != nil {
+		exitWithError(conf, err)
+	}
+	name, err := writeBackup(conf.BackupDir, items, time.Now())
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	backups, err := listBackups(conf.BackupDir)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	expired := expiredBackups(backups, conf.KeepDaily, conf.KeepWeekly)
+	for _, b := range expired {
+		if err := os.Remove(filepath.Join(conf.BackupDir, b.name)); err != nil {
+			exitWithError(conf, err)
+		}
+	}
+
+	fmt.Printf("Backed up %d items to %s, keeping %d backups, removed %d\n",
+		len(items), filepath.Join(conf.BackupDir, name), len(backups)-len(expired), len(expired))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/chatbot"
+)
+
+// botListCount is the most items "!list" answers with.
+const botListCount = 10
+
+// botRetryWait is the wait after the chat service could not be reached.
+const botRetryWait = 30 * time.Second
+
+// botHelp describes the commands of the bot, with their prefix.
+func botHelp(prefix string) string {
+	return strings.Join([]string{
+		prefix + "save <url> [tag...]: save a URL, with tags",
+		fmt.Sprintf("%slist [tag]: the %d newest unread items, with a tag", prefix, botListCount),
+		prefix + "help: this",
+	}, "\n")
+}
+
+// botReply answers a message of the channel, or returns "" for those that
+// are not commands.
+func botReply(client *api.Client, s BotSettings, rules []Rule, text string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "!"
+	}
+	command, ok := strings.CutPrefix(strings.TrimSpace(text), prefix)
+	if !ok {
+		return ""
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return ""
+	}
+
+	switch args[0] {
+	case "save":
+		if len(args) < 2 || len(clipboardURLs(args[1])) == 0 {
+			return "Usage: " + prefix + "save <url> [tag...]"
+		}
+		url := clipboardURLs(args[1])[0]
+		tags := []string{}
+		for _, tag := range args[2:] {
+			tags = append(tags, strings.TrimPrefix(tag, "#"))
+		}
+		_, queued, err := modifyOrQueue(client, newSaveAction(addRequest{URL: url, Tags: tags}, rules))
+		switch {
+		case err != nil:
+			return fmt.Sprintf("Could not save %s: %s", url, err)
+		case queued:
+			return "Saved offline, to be sent with the next sync: " + url
+		}
+		return "Saved " + url
+
+	case "list":
+		tag := ""
+		if len(args) > 1 {
+			tag = strings.TrimPrefix(args[1], "#")
+		}
+		items, err := retrieveItems(client, &api.RetrieveOption{
+			State: api.StateUnread,
+			Tag:   tag,
+			Sort:  api.SortNewest,
+			Count: botListCount,
+		})
+		if err != nil {
+			return "Could not list the items: " + err.Error()
+		}
+		tagged := ""
+		if tag != "" {
+			tagged = " tagged " + tag
+		}
+		if len(items) == 0 {
+			return "No unread items" + tagged
+		}
+		lines := []string{fmt.Sprintf("Newest unread items%s:", tagged)}
+		for _, item := range items[:min(len(items), botListCount)] {
+			if item.Title() == "" {
+				lines = append(lines, fmt.Sprintf("• <%s>", item.URL()))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("• %s <%s>", item.Title(), item.URL()))
+		}
+		return strings.Join(lines, "\n")
+
+	case "help":
+		return botHelp(prefix)
+	}
+	return fmt.Sprintf("Unknown command %s%s; %shelp lists them", prefix, args[0], prefix)
+}
+
+// daemonBot answers the commands posted to the channel of the bot settings,
+// acting on the account of client, for as long as the daemon runs.
+func daemonBot(client *api.Client, live *liveSettings) {
+	s := live.Get().Bot
+	channel, err := chatbot.New(chatbot.Config{
+		Service:  s.Service,
+		URL:      s.URL,
+		Token:    s.Token,
+		Channel:  s.Channel,
+		Interval: time.Duration(s.Interval) * time.Second,
+	})
+	if err != nil {
+		slog.Error("Could not start the bot", "err", err)
+		return
+	}
+
+	ctx := context.Background()
+	slog.Info("Taking commands", "service", s.Service, "channel", s.Channel)
+	for {
+		messages, err := channel.Poll(ctx)
+		if err != nil {
+			slog.Warn("Could not read the chat", "service", s.Service, "err", err)
+			time.Sleep(botRetryWait)
+			continue
+		}
+		for _, m := range messages {
+			if len(s.Users) > 0 && !slices.Contains(s.Users, m.User) {
+				continue
+			}
+			reply := botReply(client, s, live.Get().Rules, m.Text)
+			if reply == "" {
+				continue
+			}
+			slog.Info("Answering a command", "user", m.User, "command", strings.Fields(m.Text)[0])
+			if err := channel.Send(ctx, reply); err != nil {
+				slog.Warn("Could not answer in the chat", "service", s.Service, "err", err)
+			}
+		}
+	}
+}
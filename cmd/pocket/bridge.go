@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/bridge"
+	"github.com/motemen/go-pocket/library"
+)
+
+// bridgeServices are the bookmark managers bridge works with.
+var bridgeServices = []string{"buku", "shiori", "pinboard"}
+
+// bridgeService returns the bookmark manager named, set up from settings.
+func bridgeService(name string, settings BridgeSettings) (bridge.Service, error) {
+	switch name {
+	case "buku":
+		return bridge.NewBuku(settings.Buku), nil
+	case "shiori":
+		return bridge.NewShiori(settings.Shiori), nil
+	case "pinboard":
+		if settings.PinboardToken == "" {
+			return nil, errors.New("set bridge.pinboard_token in config.json to the API token from https://pinboard.in/settings/password")
+		}
+		return bridge.NewPinboard(settings.PinboardToken), nil
+	}
+	return nil, fmt.Errorf("unknown service %q; use buku, shiori, or pinboard", name)
+}
+
+func commandBridge(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	service, err := bridgeService(conf.Service, settings.Bridge)
+	if err != nil {
+		exitWithError(conf, &usageError{command: "bridge", err: err})
+	}
+
+	bookmarks, err := service.Bookmarks()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	if conf.Pull {
+		bridgePull(conf, client, bookmarks, settings.Bridge.Tags)
+	} else {
+		bridgePush(conf, client, service, bookmarks, settings.Bridge.Tags)
+	}
+}
+
+// bridgePush adds the items missing from the bookmarks to the service.
+func bridgePush(conf Config, client *api.Client, service bridge.Service, bookmarks []bridge.Bookmark, tags bridge.TagMap) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.State(conf.State),
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	present := map[string]bool{}
+	for _, b := range bookmarks {
+		present[library.URLKey(b.URL)] = true
+	}
+	pushes := []bridge.Bookmark{}
+	for _, item := range items {
+		url := library.URLKey(item.URL())
+		if present[url] {
+			continue
+		}
+		present[url] = true
+		pushes = append(pushes, bridge.Bookmark{
+			URL:    item.URL(),
+			Title:  item.Title(),
+			Tags:   tags.ToService(item.TagNames()),
+			Unread: item.Status == api.ItemStatusUnread,
+		})
+	}
+
+	if conf.DryRun {
+		for _, b := range pushes {
+			fmt.Println(b.URL)
+		}
+		fmt.Fprintf(os.Stderr, "Would add %d items to %s, skipping %d already there\n", len(pushes), conf.Service, len(items)-len(pushes))
+		return
+	}
+
+	j, err := openJournal(conf, "bridge push", strings.Join([]string{conf.Service, conf.State, conf.Domain, conf.SearchQuery, conf.Tag}, "\x00"))
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	todo := []bridge.Bookmark{}
+	for _, b := range pushes {
+		if !j.done(b.URL) {
+			todo = append(todo, b)
+		}
+	}
+	pushes = todo
+
+	e := newBulk("Adding to "+conf.Service, 1)
+	errs, runErr := e.Run(len(pushes), func(i int) error {
+		if err := service.Add(pushes[i]); err != nil {
+			return err
+		}
+		j.markDone(pushes[i].URL)
+		return nil
+	})
+	added := 0
+	for i, err := range errs {
+		if err == nil {
+			added++
+		} else if runErr == nil {
+			fmt.Fprintf(os.Stderr, "Could not add %s: %v\n", pushes[i].URL, err)
+		}
+	}
+
+	fmt.Printf("Added %d of %d items to %s, skipped %d already there\n", added, len(pushes), conf.Service, len(items)-len(pushes))
+	if runErr != nil || added < len(pushes) {
+		fmt.Fprintln(os.Stderr, "Push again with --resume to add the rest")
+	} else {
+		j.finish()
+	}
+	if runErr != nil {
+		exitWithError(conf, runErr)
+	}
+	if added < len(pushes) {
+		os.Exit(1)
+	}
+}
+
+// bridgePull adds the bookmarks missing from Pocket. They are archived, as
+// bookmarks are kept rather than waiting to be read, unless the service has
+// them marked unread. Bookmarks already in Pocket give it the tags it is
+// missing.
+func bridgePull(conf Config, client *api.Client, bookmarks []bridge.Bookmark, tags bridge.TagMap) {
+	existing, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll, DetailType: api.DetailTypeComplete})
+	if err != nil {
+		panic(err)
+	}
+	present := map[string]api.Item{}
+	for _, item := range existing {
+		present[library.URLKey(item.URL())] = item
+	}
+
+	pulls := []api.Item{}
+	pending := map[string]int{}
+	merges := []*api.Action{}
+	merged := 0
+	for _, b := range bookmarks {
+		item := api.Item{GivenURL: b.URL, GivenTitle: b.Title, Tags: map[string]map[string]interface{}{}}
+		for _, tag := range tags.ToPocket(b.Tags) {
+			item.Tags[tag] = map[string]interface{}{"tag": tag}
+		}
+
+		url := library.URLKey(b.URL)
+		if i, found := pending[url]; found {
+			library.MergeItem(&pulls[i], item)
+			continue
+		}
+		if current, found := present[url]; found {
+			// Only tags are merged; the archive state is Pocket's to keep
+			if actions := library.StateActions(item, current.ItemID, &current); len(actions) > 0 {
+				merges = append(merges, actions...)
+				merged++
+				library.MergeItem(&current, item)
+				present[url] = current
+			}
+			continue
+		}
+
+		if !b.Unread {
+			item.Status = api.ItemStatusArchived
+		}
+		pending[url] = len(pulls)
+		pulls = append(pulls, item)
+	}
+
+	if conf.DryRun {
+		for _, item := range pulls {
+			fmt.Println(item.GivenURL)
+		}
+		fmt.Fprintf(os.Stderr, "Would add %d bookmarks from %s, merge the tags of %d already in Pocket, and skip %d\n",
+			len(pulls), conf.Service, merged, len(bookmarks)-len(pulls)-merged)
+		return
+	}
+
+	j, err := openJournal(conf, "bridge pull", conf.Service)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	todo := []api.Item{}
+	for _, item := range pulls {
+		if !j.done(item.URL()) {
+			todo = append(todo, item)
+		}
+	}
+	pulls = todo
+
+	summary := newBulkSummary("bridge pull")
+	_, err = modifyInBatches(client, merges)
+	if err == errOverBudget {
+		summary.addNotSent(merged + len(pulls))
+		summary.report(conf.Summary)
+	}
+	if err != nil {
+		panic(err)
+	}
+	skipped := len(bookmarks) - len(pulls) - merged
+	summary.Processed += merged + skipped
+	summary.Succeeded += merged
+	summary.Skipped += skipped
+
+	added := 0
+	onShutdown(func() {
+		fmt.Printf("Added %d of %d bookmarks before being interrupted; pull again with --resume to add the rest\n", added, len(pulls))
+	})
+	sent := 0
+	runID := importRunID(j.Started, conf.Service)
+	err = addItemsWithState(client, pulls, runID, func(batch, failed []api.Item) {
+		added += len(batch) - len(failed)
+		sent += len(batch)
+		summary.addAdded(batch, failed)
+		j.markAdded(batch, failed)
+	})
+	if err == errOverBudget {
+		summary.addNotSent(len(pulls) - sent)
+	} else if err != nil {
+		panic(err)
+	}
+	if summary.complete() {
+		j.finish()
+	}
+	reportImportRun(runID, added)
+	summary.report(conf.Summary)
+}
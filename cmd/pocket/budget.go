@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// maxAPICalls, set by --max-api-calls, bounds the requests a command makes
+// to the Pocket API, for those sharing a consumer key; zero is no bound.
+var maxAPICalls int
+
+// errOverBudget is the error of the changes not sent as they would take
+// more requests than --max-api-calls leaves.
+var errOverBudget = errors.New("over the --max-api-calls budget")
+
+// callsLeft returns the requests --max-api-calls leaves, or -1 without it.
+func callsLeft() int {
+	if maxAPICalls == 0 {
+		return -1
+	}
+	return max(maxAPICalls-api.CurrentUsage().Requests, 0)
+}
+
+// fitBudget returns how many of n steps, taking calls requests each, fit in
+// what --max-api-calls leaves, warning on stderr if that is not all.
+func fitBudget(n, calls int) int {
+	left := callsLeft()
+	if left < 0 || n*calls <= left {
+		return n
+	}
+	fit := left / calls
+	fmt.Fprintf(os.Stderr, "pocket: --max-api-calls %d leaves room for %d of %d batches of changes; the rest are not sent\n",
+		maxAPICalls, fit, n)
+	return fit
+}
+
+// checkBudgetLeft exits if --max-api-calls leaves no request for the
+// retrieval about to be made.
+func checkBudgetLeft() {
+	if callsLeft() == 0 {
+		fmt.Fprintf(os.Stderr, "pocket: --max-api-calls %d leaves no API call to retrieve the items with\n", maxAPICalls)
+		os.Exit(1)
+	}
+}
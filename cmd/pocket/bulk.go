@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/bulk"
+	"github.com/motemen/go-pocket/pocketops"
+)
+
+// newBulk returns an engine for a bulk operation, showing its progress under
+// label and stopping when interrupted.
+func newBulk(label string, workers int) *bulk.Engine {
+	e := bulk.New(workers)
+	e.Interrupted = interruptRequested
+	pocketops.Observe(e, newBulkProgress(label))
+	return e
+}
+
+// bulkProgress reports the progress of a bulk operation under label on
+// stderr, in place on a terminal or in lines for screen readers, or in the
+// debug log otherwise. Retries are logged at the debug level, and waits for
+// the rate limits at the info level.
+type bulkProgress struct {
+	label   string
+	action  func(done, total int)
+	inPlace bool
+}
+
+func newBulkProgress(label string) *bulkProgress {
+	p := &bulkProgress{label: label}
+	switch {
+	case screenReader:
+		p.action = spokenProgressReporter(label)
+	case !term.IsTerminal(int(os.Stderr.Fd())):
+		p.action = func(done, total int) {
+			slog.Debug(label, "done", done, "total", total)
+		}
+	default:
+		p.action = func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\r%s %d/%d", label, done, total)
+			p.inPlace = done < total
+			if !p.inPlace {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
+	}
+	return p
+}
+
+// endLine ends a progress line shown in place, so that a log line does not
+// run on from it.
+func (p *bulkProgress) endLine() {
+	if p.inPlace {
+		fmt.Fprintln(os.Stderr)
+		p.inPlace = false
+	}
+}
+
+func (p *bulkProgress) OnPage(retrieved, selected int) {
+	slog.Debug(p.label, "retrieved", retrieved, "selected", selected)
+}
+
+func (p *bulkProgress) OnAction(done, total int) {
+	p.action(done, total)
+}
+
+func (p *bulkProgress) OnRetry(retry int, err error) {
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		p.endLine()
+	}
+	slog.Debug("Retrying", "operation", p.label, "retry", retry, "error", err)
+}
+
+func (p *bulkProgress) OnRateLimitWait(wait time.Duration) {
+	p.endLine()
+	slog.Info("Waiting for the rate limit to reset", "operation", p.label, "wait", wait.Round(time.Second))
+}
+
+// modifyBatchSize is the number of actions sent in a single modify request.
+const modifyBatchSize = 100
+
+// modifyInBatches sends actions in batches of modifyBatchSize, one at a
+// time, returning one result per action. Failed batches are retried; if one
+// still fails, the rest are not sent. Neither are those over --max-api-calls,
+// failing with errOverBudget.
+func modifyInBatches(client *api.Client, actions []*api.Action) ([]api.ActionResult, error) {
+	results := make([]api.ActionResult, len(actions))
+	batches := (len(actions) + modifyBatchSize - 1) / modifyBatchSize
+	checkQuota(batches)
+	sendable := fitBudget(batches, 1)
+
+	e := newBulk("Sending changes", 1)
+	if sendable < 2 {
+		e.Progress = nil
+	}
+	// Batches in flight are protected by critical sections instead.
+	e.Interrupted = nil
+	e.StopOnError = true
+
+	errs, _ := e.Run(sendable, func(b int) error {
+		start := b * modifyBatchSize
+		end := min(start+modifyBatchSize, len(actions))
+		res, err := modifyUninterrupted(client, actions[start:end]...)
+		if err != nil {
+			return err
+		}
+		copy(results[start:end], res.ActionDetails)
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	if sendable < batches {
+		return results, errOverBudget
+	}
+	return results, nil
+}
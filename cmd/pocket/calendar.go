@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// calendarEvent is an event of an iCalendar file.
+type calendarEvent struct {
+	UID         string
+	Start, End  time.Time
+	Summary     string
+	Description string
+	URL         string
+}
+
+// readingSessions lays out the items of a plan back to back from start,
+// each for its reading time, rounded up to whole minutes.
+func readingSessions(plan []api.Item, start time.Time) []calendarEvent {
+	events := make([]calendarEvent, len(plan))
+	at := start
+	for i, item := range plan {
+		minutes := item.ReadingMinutes()
+		events[i] = calendarEvent{
+			UID:         fmt.Sprintf("pocket-%d-%d@go-pocket", item.ItemID, at.Unix()),
+			Start:       at,
+			End:         at.Add(time.Duration(minutes) * time.Minute),
+			Summary:     item.Title(),
+			Description: fmt.Sprintf("%d min read\n%s", minutes, item.URL()),
+			URL:         item.URL(),
+		}
+		at = events[i].End
+	}
+	return events
+}
+
+// parseStart parses the start of a reading plan: a time of day today, as in
+// "18:30", or a date and time, as in "2006-01-02 18:30".
+func parseStart(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("15:04", s, now.Location()); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location()), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid start %q; use a time like 18:30 or 2006-01-02 18:30", s)
+}
+
+// nextQuarterHour is the first quarter of an hour at or after t.
+func nextQuarterHour(t time.Time) time.Time {
+	q := t.Truncate(15 * time.Minute)
+	if q.Before(t) {
+		q = q.Add(15 * time.Minute)
+	}
+	return q
+}
+
+// writeCalendar writes events as an iCalendar (RFC 5545) file, with the
+// times in UTC.
+func writeCalendar(w io.Writer, events []calendarEvent, now time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//go-pocket//pocket plan//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+e.UID,
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+e.Start.UTC().Format("20060102T150405Z"),
+			"DTEND:"+e.End.UTC().Format("20060102T150405Z"),
+			"SUMMARY:"+calendarText(e.Summary),
+			"DESCRIPTION:"+calendarText(e.Description),
+			"URL:"+e.URL,
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, foldCalendarLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calendarText escapes s as an iCalendar text value.
+func calendarText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldCalendarLine breaks line into lines of at most 75 bytes, each but the
+// first starting with a space, without splitting UTF-8 sequences.
+func foldCalendarLine(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The space starting the continuation counts
+		limit = 74
+	}
+	b.WriteString(line)
+	return b.String()
+}
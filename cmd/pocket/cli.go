@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/motemen/go-pocket/i18n"
+)
+
+// errHelp is returned by parseCommandLine when help was asked for.
+var errHelp = errors.New("help requested")
+
+// usageError is an error in the command line of a command.
+type usageError struct {
+	command string
+	err     error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+// findCommand returns the spec of the named command.
+func findCommand(name string) (commandSpec, bool) {
+	for _, command := range commandSpecs {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return commandSpec{}, false
+}
+
+// configField returns the field of conf tagged with name, as in "--format",
+// "<item-id>", or "list".
+func configField(conf *Config, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(conf).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("cli") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// newFlagSet defines the options of the command on a flag set, bound to the
+// fields of conf and set to their defaults. Each option is defined by its
+// long and short name.
+func newFlagSet(command commandSpec, conf *Config, help *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(help, "help", false, "")
+	fs.BoolVar(help, "h", false, "")
+
+	for _, option := range append(command.options(), loggingOptions...) {
+		field, ok := configField(conf, option.Long)
+		if !ok {
+			panic("no field for option " + option.Long)
+		}
+		names := []string{strings.TrimLeft(option.Long, "-")}
+		if option.Short != "" {
+			names = append(names, strings.TrimLeft(option.Short, "-"))
+		}
+		for _, name := range names {
+			switch ptr := field.Addr().Interface().(type) {
+			case *bool:
+				fs.BoolVar(ptr, name, false, option.Help)
+			case *string:
+				fs.StringVar(ptr, name, option.Default, option.Help)
+			case *int:
+				n, _ := strconv.Atoi(option.Default)
+				fs.IntVar(ptr, name, n, option.Help)
+			default:
+				panic("unsupported field for option " + option.Long)
+			}
+		}
+	}
+	return fs
+}
+
+// longName returns the long form of an option name given to the flag set.
+func longName(name string) string {
+	for _, option := range append(optionSpecs, loggingOptions...) {
+		if option.Short == "-"+name {
+			return option.Long
+		}
+	}
+	return "--" + name
+}
+
+// hoistGlobalOptions moves the global options given before the command
+// after its name, where its flag set parses them. They are dropped before
+// "help".
+func hoistGlobalOptions(args []string) []string {
+	leading := []string{}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		option := args[0]
+		leading, args = append(leading, option), args[1:]
+		if strings.Contains(option, "=") || len(args) == 0 {
+			continue
+		}
+		for _, spec := range loggingOptions {
+			if (option == spec.Long || option == spec.Short) && spec.Arg != "" {
+				leading, args = append(leading, args[0]), args[1:]
+				break
+			}
+		}
+	}
+	if len(args) == 0 || args[0] == "help" || strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	return append(append([]string{args[0]}, leading...), args[1:]...)
+}
+
+// fits reports whether line takes the positional arguments, its words
+// included, and all of the options named.
+func fits(line usageLine, positional, options []string) bool {
+	n := len(positional) - len(line.words)
+	if n < len(line.args) || n > len(line.args) && !line.repeated {
+		return false
+	}
+	for _, name := range options {
+		if _, ok := line.options[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var exclusivePattern = regexp.MustCompile(`\[(--[a-z-]+(?:=<[a-z-]+>)?(?:\|--[a-z-]+(?:=<[a-z-]+>)?)+)\]`)
+
+// parseCommandLine parses the arguments after "pocket" into a Config,
+// returning the spec of the command given. Options may come before or after
+// the positional arguments, and "--" ends them; global options may also
+// come before the command. It returns errHelp if help
+// was asked for, with the command if one was given.
+func parseCommandLine(args []string) (commandSpec, Config, error) {
+	var conf Config
+
+	args = hoistGlobalOptions(args)
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		return commandSpec{}, conf, errHelp
+	}
+	if args[0] == "help" {
+		if len(args) > 1 {
+			command, ok := findCommand(args[1])
+			if !ok {
+				return commandSpec{}, conf, &localizedError{msgUnknownCommand, map[string]string{"Command": args[1]}}
+			}
+			return command, conf, errHelp
+		}
+		return commandSpec{}, conf, errHelp
+	}
+
+	command, ok := findCommand(args[0])
+	if !ok {
+		return commandSpec{}, conf, &localizedError{msgUnknownCommand, map[string]string{"Command": args[0]}}
+	}
+	fail := func(m *i18n.Message, data map[string]string) (commandSpec, Config, error) {
+		return command, conf, &usageError{command: command.Name, err: &localizedError{m, data}}
+	}
+
+	var help bool
+	fs := newFlagSet(command, &conf, &help)
+
+	rest, literal := args[1:], []string{}
+	for i, arg := range rest {
+		if arg == "--" {
+			rest, literal = rest[:i], rest[i+1:]
+			break
+		}
+	}
+
+	positional := []string{command.Name}
+	for {
+		err := fs.Parse(rest)
+		if err != nil {
+			if name, ok := strings.CutPrefix(err.Error(), "flag provided but not defined: "); ok {
+				return fail(msgUnknownOption, map[string]string{"Option": longName(strings.TrimLeft(name, "-"))})
+			}
+			return command, conf, &usageError{command: command.Name, err: err}
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+	positional = append(positional, literal...)
+
+	if help {
+		return command, conf, errHelp
+	}
+
+	given := []string{}
+	fs.Visit(func(f *flag.Flag) {
+		given = append(given, longName(f.Name))
+	})
+
+	// The form with the longest words matching, as in "daemon status"
+	// rather than "daemon"
+	var line *usageLine
+	for _, form := range command.Forms {
+		for _, candidate := range parseForm(form) {
+			candidate := candidate
+			if !hasPrefix(positional, candidate.words) {
+				continue
+			}
+			// Of forms with as many words, one taking the options and the
+			// number of arguments given, as in "search --saved=<name>"
+			if line == nil || len(candidate.words) > len(line.words) ||
+				len(candidate.words) == len(line.words) && !fits(*line, positional, given) && fits(candidate, positional, given) {
+				line = &candidate
+			}
+		}
+	}
+	if line == nil {
+		if len(positional) > 1 {
+			return fail(msgUnknownSubcommand, map[string]string{"Subcommand": positional[1]})
+		}
+		return fail(msgMissingSubcommand, nil)
+	}
+
+	for _, name := range given {
+		if _, ok := line.options[name]; !ok {
+			return fail(msgOptionNotAccepted, map[string]string{"Option": name, "Command": strings.Join(line.words, " ")})
+		}
+	}
+	for _, form := range command.Forms {
+		for _, group := range exclusivePattern.FindAllStringSubmatch(form, -1) {
+			exclusive := []string{}
+			for _, option := range strings.Split(group[1], "|") {
+				name, _, _ := strings.Cut(option, "=")
+				exclusive = append(exclusive, name)
+			}
+			count := 0
+			for _, name := range given {
+				for _, option := range exclusive {
+					if name == option {
+						count++
+					}
+				}
+			}
+			if count > 1 {
+				return fail(msgOnlyOneOf, map[string]string{"Options": strings.Join(exclusive, ", ")})
+			}
+		}
+	}
+
+	values := positional[len(line.words):]
+	switch {
+	case len(values) < len(line.args):
+		return fail(msgMissingArgument, map[string]string{"Argument": line.args[len(values)]})
+	case len(values) > len(line.args) && !line.repeated:
+		return fail(msgUnexpectedArg, map[string]string{"Argument": values[len(line.args)]})
+	}
+
+	for _, word := range line.words {
+		if field, ok := configField(&conf, word); ok {
+			field.SetBool(true)
+		}
+	}
+	for i, arg := range line.args {
+		// A repeated argument may be bound apart from a single one, as
+		// "<item-id>..." is
+		field, ok := configField(&conf, arg+"...")
+		if !ok || !line.repeated || i != len(line.args)-1 {
+			field, ok = configField(&conf, arg)
+		}
+		if !ok {
+			panic("no field for argument " + arg)
+		}
+		switch field.Kind() {
+		case reflect.Int:
+			n, err := strconv.Atoi(values[i])
+			if err != nil {
+				return fail(msgNotANumber, map[string]string{"Argument": arg, "Value": values[i]})
+			}
+			field.SetInt(int64(n))
+		case reflect.String:
+			field.SetString(values[i])
+		case reflect.Slice:
+			field.Set(reflect.ValueOf(append([]string{}, values[i:]...)))
+		}
+	}
+
+	return command, conf, nil
+}
+
+// parseCommandLineOrExit expands aliases and saved searches and parses the
+// command line, printing help and exiting if asked, or printing the error and
+// the usage of the command on a mistake.
+func parseCommandLineOrExit(args []string, settings *Settings) (commandSpec, Config) {
+	var (
+		command commandSpec
+		conf    Config
+	)
+	aliases := allAliases(settings)
+	hoisted := hoistGlobalOptions(args)
+	leading := args[:len(args)-len(hoisted)]
+	args, err := expandAliases(hoisted, aliases)
+	if err == nil {
+		args, err = expandSavedSearches(args, settings.Searches)
+	}
+	if err == nil && len(args) > 1 && args[0] == "help" {
+		// As in "pocket help ls"
+		var expanded []string
+		expanded, err = expandAliases(args[1:2], aliases)
+		if err == nil {
+			args = []string{"help", expanded[0]}
+		}
+	}
+	if err == nil {
+		command, conf, err = parseCommandLine(args)
+	}
+	if err == errHelp {
+		// The global options given before "help", dropped in hoisting, such
+		// as --locale, apply to the help
+		var help bool
+		newFlagSet(commandSpec{Name: "help"}, &conf, &help).Parse(leading)
+	}
+	// For the help and mistakes too, if --locale was parsed
+	if localeErr := setLocale(conf.Locale); err == nil && localeErr != nil {
+		err = &usageError{command: command.Name, err: localeErr}
+	}
+	if err == nil {
+		return command, conf
+	}
+
+	if err == errHelp {
+		if command.Name == "" {
+			fmt.Print(overviewHelp(aliases))
+		} else {
+			fmt.Print(commandHelp(command))
+		}
+		os.Exit(0)
+	}
+
+	var uerr *usageError
+	if conf.Output == "json" {
+		printJSONError(&usageError{command: command.Name, err: err})
+	} else if errors.As(err, &uerr) {
+		fmt.Fprintf(os.Stderr, "pocket %s: %s\n\n%s\n", uerr.command, uerr.err, tr(msgHelpUsage, nil))
+		for _, form := range command.Forms {
+			fmt.Fprintf(os.Stderr, "  pocket %s\n", form)
+		}
+		fmt.Fprintf(os.Stderr, "\n%s\n", tr(msgHelpDetails, map[string]string{"Command": uerr.command}))
+	} else {
+		fmt.Fprintf(os.Stderr, "pocket: %s\n%s\n", err, tr(msgHelpCommandList, nil))
+	}
+	os.Exit(2)
+	return command, conf
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// clipboardPollInterval is how often the clipboard is read while watching it.
+const clipboardPollInterval = time.Second
+
+// clipboardURLPattern finds the URLs in the text copied.
+var clipboardURLPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// readClipboard returns the text in the clipboard.
+func readClipboard() (string, error) {
+	cmd, err := clipboardCommand()
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		// xclip and wl-paste fail while the clipboard is empty
+		if _, ok := err.(*exec.ExitError); ok {
+			slog.Debug("Could not read the clipboard", "command", cmd.Path, "err", err)
+			return "", nil
+		}
+		return "", fmt.Errorf("Failed to run %s: %s", cmd.Path, err)
+	}
+	return string(out), nil
+}
+
+// clipboardURLs returns the URLs in text, without the punctuation that
+// usually follows a URL in a sentence.
+func clipboardURLs(text string) []string {
+	urls := []string{}
+	for _, u := range clipboardURLPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}")
+		if len(u) > len("https://") {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// watchClipboard calls handle with each URL copied to the clipboard from now
+// on, once per URL. It returns only if the clipboard cannot be read.
+func watchClipboard(handle func(url string)) error {
+	last, err := readClipboard()
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for {
+		time.Sleep(clipboardPollInterval)
+
+		text, err := readClipboard()
+		if err != nil {
+			return err
+		}
+		if text == last {
+			continue
+		}
+		last = text
+
+		for _, url := range clipboardURLs(text) {
+			if key := library.CleanURL(url); !seen[key] {
+				seen[key] = true
+				handle(url)
+			}
+		}
+	}
+}
+
+// saveFromClipboard saves url with the tags, and those of the rules matching
+// it, queueing it if Pocket cannot be reached. It returns what happened.
+func saveFromClipboard(client *api.Client, rules []Rule, tags []string, url string) (string, error) {
+	_, queued, err := modifyOrQueue(client, newSaveAction(addRequest{URL: url, Tags: tags}, rules))
+	if err != nil {
+		return "", err
+	}
+	if queued {
+		return "Saved offline, to be sent with the next sync: " + url, nil
+	}
+	return "Saved " + url, nil
+}
+
+// daemonWatchClipboard saves the URLs copied in the background, notifying
+// each one saved.
+func daemonWatchClipboard(client *api.Client, live *liveSettings) {
+	err := watchClipboard(func(url string) {
+		settings := live.Get()
+		saved, err := saveFromClipboard(client, settings.Rules, settings.Clipboard.Tags, url)
+		if err != nil {
+			slog.Warn("Could not save from the clipboard", "url", url, "err", err)
+			return
+		}
+		slog.Info(saved)
+		if err := notify("Saved to Pocket", url); err != nil {
+			slog.Debug("Could not notify", "err", err)
+		}
+	})
+	slog.Error("Stopped watching the clipboard", "err", err)
+}
+
+func commandWatchClipboard(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	tags := splitTags(conf.Tags)
+
+	fmt.Fprintln(os.Stderr, "Watching the clipboard for URLs; press Ctrl-C to stop")
+	err = watchClipboard(func(url string) {
+		if !confirmOperation(conf, confirmSave, 1, "Save "+url+"?") {
+			return
+		}
+		saved, err := saveFromClipboard(client, settings.Rules, tags, url)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not save %s: %v\n", url, err)
+			return
+		}
+		fmt.Println(saved)
+	})
+	exitWithError(conf, err)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// collectionsPath is the file of the collections: named, ordered lists of
+// items kept locally, as Pocket only has tags.
+func collectionsPath() string {
+	return filepath.Join(configDir, "collections.json")
+}
+
+// loadCollections returns the item IDs of each collection, in order.
+func loadCollections() (map[string][]int, error) {
+	collections := map[string][]int{}
+	err := loadJSONFromFile(collectionsPath(), &collections)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func saveCollections(collections map[string][]int) error {
+	return saveJSONToFile(collectionsPath(), collections)
+}
+
+// collectionNames returns the names of the collections, sorted.
+func collectionNames(collections map[string][]int) []string {
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addToCollection appends the items not in a collection yet to it, keeping
+// the place of those already in it, and returns the number added.
+func addToCollection(ids []int, add []int) ([]int, int) {
+	in := map[int]bool{}
+	for _, id := range ids {
+		in[id] = true
+	}
+	added := 0
+	for _, id := range add {
+		if !in[id] {
+			ids, in[id] = append(ids, id), true
+			added++
+		}
+	}
+	return ids, added
+}
+
+// removeFromCollection removes items from a collection, returning the number
+// removed.
+func removeFromCollection(ids []int, remove []int) ([]int, int) {
+	gone := map[int]bool{}
+	for _, id := range remove {
+		gone[id] = true
+	}
+	kept := []int{}
+	for _, id := range ids {
+		if !gone[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept, len(ids) - len(kept)
+}
+
+// moveInCollection moves an item of a collection to a position counted from
+// 1, the last one if position is past it.
+func moveInCollection(ids []int, itemID, position int) ([]int, bool) {
+	kept, removed := removeFromCollection(ids, []int{itemID})
+	if removed == 0 {
+		return ids, false
+	}
+	i := min(max(position-1, 0), len(kept))
+	return append(kept[:i], append([]int{itemID}, kept[i:]...)...), true
+}
+
+// collectionItems returns the items of a collection in its order, along with
+// the IDs of those gone from Pocket.
+func collectionItems(client *api.Client, ids []int) ([]api.Item, []int, error) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+
+	found, missing := []api.Item{}, []int{}
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			found = append(found, item)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing, nil
+}
+
+// writeCollectionMarkdown writes a collection as a numbered Markdown reading
+// list, with the excerpts and notes of the items.
+func writeCollectionMarkdown(w io.Writer, name string, items []api.Item, notes map[int]string) error {
+	if _, err := fmt.Fprintf(w, "# %s\n\n", name); err != nil {
+		return err
+	}
+	for i, item := range items {
+		if _, err := fmt.Fprintf(w, "%d. [%s](%s)\n", i+1, item.Title(), item.URL()); err != nil {
+			return err
+		}
+		for _, text := range []string{item.Excerpt, notes[item.ItemID]} {
+			if text == "" {
+				continue
+			}
+			text = strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n   ")
+			if _, err := fmt.Fprintf(w, "\n   %s\n\n", text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCollectionOrg writes a collection as a numbered Org reading list.
+func writeCollectionOrg(w io.Writer, name string, items []api.Item, notes map[int]string) error {
+	if _, err := fmt.Fprintf(w, "#+title: %s\n\n", name); err != nil {
+		return err
+	}
+	for i, item := range items {
+		if _, err := fmt.Fprintf(w, "%d. [[%s][%s]]\n", i+1, item.URL(), item.Title()); err != nil {
+			return err
+		}
+		if note := notes[item.ItemID]; note != "" {
+			note = strings.ReplaceAll(strings.TrimSpace(note), "\n", "\n   ")
+			if _, err := fmt.Fprintf(w, "   %s\n", note); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func commandCollection(conf Config, client *api.Client) {
+	collections, err := loadCollections()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	name := conf.CollectionName
+	ids, exists := collections[name]
+	if name != "" && !exists && !conf.Create {
+		fmt.Fprintf(os.Stderr, "No collection %q; create it with \"pocket collection create %s\"\n", name, name)
+		os.Exit(1)
+	}
+
+	switch {
+	case conf.Create:
+		if exists {
+			fmt.Fprintf(os.Stderr, "Collection %q exists already\n", name)
+			os.Exit(1)
+		}
+		if strings.TrimSpace(name) == "" {
+			exitWithError(conf, &usageError{command: "collection", err: fmt.Errorf("the name of a collection may not be empty")})
+		}
+		collections[name] = []int{}
+		fmt.Printf("Created collection %q\n", name)
+
+	case conf.Add:
+		add, err := readItemIDs(conf.ItemIDs, os.Stdin)
+		if err != nil {
+			exitWithError(conf, &usageError{command: "collection", err: err})
+		}
+		var added int
+		collections[name], added = addToCollection(ids, add)
+		fmt.Printf("Added %d items to %q, which has %d\n", added, name, len(collections[name]))
+
+	case conf.Remove:
+		remove, err := readItemIDs(conf.ItemIDs, os.Stdin)
+		if err != nil {
+			exitWithError(conf, &usageError{command: "collection", err: err})
+		}
+		var removed int
+		collections[name], removed = removeFromCollection(ids, remove)
+		fmt.Printf("Removed %d items from %q, which has %d\n", removed, name, len(collections[name]))
+
+	case conf.Move:
+		moved, ok := moveInCollection(ids, conf.ItemID, conf.Position)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Item %d is not in %q\n", conf.ItemID, name)
+			os.Exit(1)
+		}
+		collections[name] = moved
+		fmt.Printf("Moved item %d of %q to position %d\n", conf.ItemID, name, min(max(conf.Position, 1), len(moved)))
+
+	case conf.Delete:
+		delete(collections, name)
+		fmt.Printf("Deleted collection %q; its items are left as they are\n", name)
+
+	case conf.Show:
+		checkListingOutput(conf.Output)
+		items, missing, err := collectionItems(client, ids)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if conf.Output == "ids" {
+			printItemIDs(items)
+			return
+		}
+		for i, item := range items {
+			fmt.Printf("%3d. [%9d] %s\n       <%s>\n", i+1, item.ItemID, hyperlink(item.URL(), item.Title()), item.URL())
+		}
+		for _, id := range missing {
+			fmt.Fprintf(os.Stderr, "Item %d is no longer in Pocket\n", id)
+		}
+		return
+
+	case conf.Export:
+		items, missing, err := collectionItems(client, ids)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		for _, id := range missing {
+			fmt.Fprintf(os.Stderr, "Item %d is no longer in Pocket; leaving it out\n", id)
+		}
+		notes, err := loadNotes()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+
+		format := conf.FormatTemplate
+		if format == "" {
+			format = "markdown"
+		}
+		var write func(io.Writer) error
+		switch format {
+		case "markdown", "md":
+			write = func(w io.Writer) error { return writeCollectionMarkdown(w, name, items, notes) }
+		case "org":
+			write = func(w io.Writer) error { return writeCollectionOrg(w, name, items, notes) }
+		default:
+			export, ok := listExporters[format]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Unknown export format %q; use \"markdown\", \"org\", \"json\", \"wallabag\", \"omnivore\", or \"shiori\"\n", format)
+				os.Exit(1)
+			}
+			write = func(w io.Writer) error { return export(w, items) }
+		}
+
+		w, err := createOutput(conf.Out)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer w.Close()
+		if err := write(w); err != nil {
+			exitWithError(conf, err)
+		}
+		return
+
+	default:
+		names := collectionNames(collections)
+		if len(names) == 0 {
+			fmt.Println(`No collections; create one with "pocket collection create <collection>"`)
+		}
+		for _, name := range names {
+			fmt.Printf("%-24s %d items\n", name, len(collections[name]))
+		}
+		return
+	}
+
+	if err := saveCollections(collections); err != nil {
+		exitWithError(conf, err)
+	}
+}
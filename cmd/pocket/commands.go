@@ -0,0 +1,909 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// optionSpec describes an option taken by one or more commands.
+type optionSpec struct {
+	Long  string
+	Short string
+	// Arg names the value of the option, as in "<template>", and is empty
+	// for switches.
+	Arg     string
+	Default string
+	Help    string
+}
+
+// argSpec describes a positional argument.
+type argSpec struct {
+	Name string
+	Help string
+}
+
+// commandSpec describes a command.
+type commandSpec struct {
+	Name    string
+	Summary string
+	// Forms are the synopses of the command, after "pocket".
+	Forms []string
+	Args  []argSpec
+	// Description adds to the summary in the help and the manual.
+	Description string
+}
+
+// filterOptions are the forms of the options filtering items.
+const filterOptions = "[--domain=<domain>] [--tag=<tag>] [--search=<query>] [--lang=<lang>]"
+
+// minutesOptions are the forms of the options filtering items by their
+// reading time.
+const minutesOptions = "[--min-minutes=<n>] [--max-minutes=<n>]"
+
+// commandSpecs define the command line; parsing, help, the manual, and
+// shell completion are all derived from them. Options and arguments are
+// bound to the fields of Config with the same cli tag.
+var commandSpecs = []commandSpec{
+	{
+		Name:    "list",
+		Summary: "List unread items",
+		Forms:   []string{"list [--cached] [--format=<template>|--plain] " + filterOptions + " " + minutesOptions + " [--state=<state>|--opened-unarchived] [--sort=<sort>] [--cull|--delete|--output=<format>] [--yes] [--summary=<format>] [--max-api-calls=<n>]"},
+		Description: "Duplicate items, by their cleaned up URL, are deleted at the end, asking first as \"confirm\" in config.json says. " +
+			"The copy kept is the favorite, else the one with the most tags, else the oldest, and it is given the tags and favorite state of the others. " +
+			"With --cull, each item is checked and can be opened in a browser before being kept or deleted.",
+	},
+	{
+		Name:    "archive",
+		Summary: "Archive items",
+		Forms: []string{
+			"archive [--yes] [--cached] [--state=<state>] [--summary=<format>] [--max-api-calls=<n>] <item-id>...",
+			"archive [--before-id=<item-id>] [--after-id=<item-id>] [--cached] [--state=<state>] [--yes] [--summary=<format>] [--max-api-calls=<n>]",
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, ranges of them such as "1000-1200" for the items of --state with IDs in between, or "-" to read them from standard input, as printed by --output ids`},
+		},
+	},
+	{
+		Name:    "open",
+		Summary: "Open items in a browser",
+		Forms: []string{
+			"open [--cached] <item-id>...",
+			"open [--cached] --next",
+			"open [--cached] [--oldest=<n>|--limit=<n>] [--yes] " + filterOptions + " " + minutesOptions,
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, or "-" to read them from standard input, as printed by --output ids`},
+		},
+		Description: "Without item IDs, the oldest --oldest unread items matching the filters are opened, or else the newest --limit; " +
+			"--next opens the next item of the reading queue kept by \"pocket queue\", taking it off. " +
+			"Opening more than 5 tabs asks first, unless --yes is given or \"confirm\" in config.json says otherwise, and more than 30 is refused. " +
+			`With "track_opened": true in config.json, the items opened here, in tui, triage, pick, and plan are recorded, ` +
+			`and "pocket list --opened-unarchived" lists those still unread, last opened first.`,
+	},
+	{
+		Name:    "delete",
+		Summary: "Delete items",
+		Forms: []string{
+			"delete [--yes] [--cached] [--state=<state>] [--summary=<format>] [--max-api-calls=<n>] <item-id>...",
+			"delete [--before-id=<item-id>] [--after-id=<item-id>] [--cached] [--state=<state>] [--yes] [--summary=<format>] [--max-api-calls=<n>]",
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, ranges of them such as "1000-1200" for the items of --state with IDs in between, or "-" to read them from standard input, as printed by --output ids`},
+		},
+	},
+	{
+		Name:    "archive-domain",
+		Summary: "Archive, or delete, every item from some domains",
+		Forms:   []string{"archive-domain [--cached] [--state=<state>] [--delete] [--yes] [--summary=<format>] [--max-api-calls=<n>] <domain>..."},
+		Args: []argSpec{
+			{"<domain>...", "Domains, such as example.com, which also cover their subdomains"},
+		},
+		Description: "The number of items from each domain and the latest titles are shown before asking to go ahead.",
+	},
+	{
+		Name:    "linkcheck",
+		Summary: "Check that the links of items still work",
+		Forms:   []string{"linkcheck [--cached] [--state=<state>] " + filterOptions + " [--link-status=<status>] [--output=<format>] [--fix-redirects] [--yes] [--summary=<format>] [--max-api-calls=<n>]"},
+		Description: "The items whose links are broken, paywalled, or redirect elsewhere are listed, with the redirects followed. " +
+			"Pages answering 401, 402, or 403, or asking to subscribe or log in, count as paywalled rather than broken. " +
+			"With --fix-redirects, the items whose links redirect only permanently, with 301 or 308, are saved again under the URL they lead to, " +
+			"with their tags, favorite and archive state, and time added, and the old ones deleted. " +
+			`"link_check" in config.json sets how long checks are kept and how fast links are checked.`,
+	},
+	{
+		Name:    "tag",
+		Summary: "Add tags to an item",
+		Forms:   []string{"tag <item-id> <tag>..."},
+	},
+	{
+		Name:    "add",
+		Summary: "Save a URL",
+		Forms: []string{
+			"add <url> [--title=<title>] [--tags=<tags>] [--queue]",
+			"add --from-tabs=<browser> [--profile=<dir>] [--tags=<tags>] [--yes]",
+		},
+		Description: "--from-tabs reads the tabs open in Firefox, Chrome, or Chromium from the session the browser keeps, " +
+			"even while it runs, and lets you choose those to save with fzf, or the built-in fuzzy finder; " +
+			"--yes saves them all. Tabs whose pages are saved already, as the local mirror knows, are left out. " +
+			"--queue only queues the URL, without reaching Pocket or being authorized, for the next sync to send: " +
+			"that of any machine sharing the config directory through Dropbox or Syncthing, each keeping a journal of its own in queue/, " +
+			"set apart by its host name or $POCKET_HOST.",
+	},
+	{
+		Name:    "export",
+		Summary: "Export items as Markdown, Org, JSON, or for other read-it-later services",
+		Forms:   []string{"export [--cached] [--format=<format>] [--dir=<dir>] [--out=<file>] [--git=<dir>] " + filterOptions},
+		Description: `The "markdown" and "org" formats write one file per item into --dir; ` +
+			`the others write a single file to --out. ` +
+			"--git backs up every item into a directory, such as a git repository, as one JSON file per item named by its ID, " +
+			"with the keys sorted and the fields that change on their own left out, and removes the files of items gone, " +
+			"so that committing it after each run keeps the history of the library.",
+	},
+	{
+		Name:    "site",
+		Summary: "Write a static HTML site of the items in the local mirror",
+		Forms:   []string{"site [--dir=<dir>] " + filterOptions},
+		Description: "The site has the items by date, tag, and site, a page for each item with its excerpt, tags, and note, " +
+			"and a search running in the browser on an index written along with it, to be served by any web server. " +
+			"Run again into the same directory, it removes the pages of the items gone.",
+	},
+	{
+		Name:    "snapshot",
+		Summary: "Save a copy of the pages of items",
+		Forms:   []string{"snapshot [--cached] [--dir=<dir>] [--pdf] [--state=<state>] " + filterOptions},
+		Description: "Each page is saved as a single HTML file, with its stylesheets and images inlined and its scripts removed, " +
+			"or printed to PDF with --pdf. manifest.json in the directory links the files to the items; " +
+			"items with a snapshot listed there are skipped, so a run can be repeated to save the new ones.",
+	},
+	{
+		Name:    "stats",
+		Summary: "Show statistics about the items",
+		Forms:   []string{"stats [--cached] [--output=<format>] [--chart] " + filterOptions},
+	},
+	{
+		Name:    "top",
+		Summary: "Show the most saved domains, tags, and authors",
+		Forms:   []string{"top [--cached] [--since=<when>] [--limit=<n>] [--output=<format>] [--tag=<tag>]"},
+	},
+	{
+		Name:    "domains",
+		Summary: "Show the number of items, unread items, and their average age by domain",
+		Forms:   []string{"domains [--cached] [--by=<column>] [--output=<format>] [--limit=<n>] [--tag=<tag>]"},
+		Description: `Domains with many old unread items are candidates for "pocket archive-domain" or an auto-tagging rule. ` +
+			"--output opml looks for the feeds the first --limit domains link to from their home pages, or else from the newest item saved from them, " +
+			"and writes them as an OPML subscription list for a feed reader.",
+	},
+	{
+		Name:    "tags",
+		Summary: "Show how much each tag is used, as a table or a tag cloud",
+		Forms:   []string{"tags [--cached] [--cloud|--stale] [--output=<format>]"},
+	},
+	{
+		Name:    "apply",
+		Summary: "Make the changes listed in a file of actions, one JSON object per line",
+		Forms:   []string{"apply <file> [--dry-run] [--output=<format>] [--summary=<format>] [--max-api-calls=<n>]"},
+		Args: []argSpec{
+			{"<file>", `A file of actions such as {"action": "tags_add", "item_id": 123, "tags": ["go"]}, or "-" for standard input`},
+		},
+		Description: `Actions are "add", "archive", "readd", "delete", "favorite", "unfavorite", "tags_add", and "tags_remove", ` +
+			`of the item with "item_id", or "url". Every line is checked before any change is made. ` +
+			"The exit status is 1 if any action failed.",
+	},
+	{
+		Name:    "backup",
+		Summary: "Back up every item into a directory, keeping a few recent backups",
+		Forms:   []string{"backup <dir> [--keep-daily=<n>] [--keep-weekly=<n>]"},
+		Args: []argSpec{
+			{"<dir>", "Directory keeping the backups, created if missing"},
+		},
+		Description: "Each backup is a JSON export, as written by \"pocket export --format json\" and read by \"pocket restore\", " +
+			"named by the time it was taken. Afterwards the newest backup, and the newest of each of the last days and weeks " +
+			"set by the options, are kept, and the others are removed; run it daily from cron to have backups to go back to.",
+	},
+	{
+		Name:    "restore",
+		Summary: "Restore items from an export in JSON",
+		Forms:   []string{"restore <file> [--conflict=<policy>] [--resume] [--summary=<format>] [--max-api-calls=<n>]"},
+		Description: "Items are matched by their URL, ignoring the scheme, \"www.\", fragments, and tracking parameters, " +
+			"so that restoring into an account that has some of them does not save them twice. " +
+			"Progress is saved in a journal after each batch; --resume continues a run stopped by a crash, an interrupt, or a rate limit.",
+	},
+	{
+		Name:    "copy",
+		Summary: "Copy items, with their tags and favorite and archive state, to another account",
+		Forms:   []string{"copy --to=<account> [--from=<account>] [--state=<state>] [--resume] [--summary=<format>] [--max-api-calls=<n>] " + filterOptions},
+		Description: "Accounts other than the default one are authorized the first time they are named, " +
+			"and kept in auth-<account>.json. Items already in the other account are skipped. " +
+			"Progress is saved in a journal after each batch; --resume continues a run stopped by a crash, an interrupt, or a rate limit.",
+	},
+	{
+		Name:    "migrate",
+		Summary: "Move everything from one account to another",
+		Forms:   []string{"migrate --to=<account> [--from=<account>]"},
+		Description: "Items are added with the time they were first added, and given their tags and favorite and archive state; " +
+			"items already in the other account are brought to the same state. " +
+			"Progress is saved in migrate-<from>-<to>.json after each batch, so a run stopped by a rate limit or an interrupt resumes when run again.",
+	},
+	{
+		Name:    "bridge",
+		Summary: "Copy items to, or bookmarks from, another bookmark manager",
+		Forms: []string{
+			"bridge push <service> [--cached] [--dry-run] [--state=<state>] [--resume] " + filterOptions,
+			"bridge pull <service> [--dry-run] [--resume] [--summary=<format>] [--max-api-calls=<n>]",
+		},
+		Args: []argSpec{
+			{"<service>", `"buku" or "shiori", through their command line, or "pinboard"`},
+		},
+		Description: "Items and bookmarks already on the other side, by their cleaned up URL, are skipped. " +
+			"Tags are mapped as set in the bridge settings of config.json. " +
+			"Bookmarks pulled are archived, unless Pinboard has them to read. " +
+			"Progress is saved in a journal as it goes; --resume continues a run stopped by a crash, an interrupt, or a rate limit.",
+	},
+	{
+		Name:    "sync",
+		Summary: "Update the local mirror of the account",
+		Forms:   []string{"sync [--articles] [--drift]"},
+		Description: "Actions queued while Pocket was unreachable are sent first. " +
+			"Snoozed items that are due are brought back, and the search index is refreshed. " +
+			`With --drift, the article text of up to 50 items, those checked longest ago first, is fetched again and fingerprinted, for "pocket drift".`,
+	},
+	{
+		Name:    "diff",
+		Summary: "Show what changed in Pocket since the last sync",
+		Forms:   []string{"diff [--output=<format>]"},
+	},
+	{
+		Name:    "drift",
+		Summary: "Show the articles rewritten, removed, or moved since they were saved",
+		Forms:   []string{"drift [--output=<format>]"},
+		Description: `The article text of items is compared with that first fingerprinted by "pocket sync --drift". ` +
+			"Articles count as changed when their text differs by more than a few edits, lost most of its words, or moved to another site.",
+	},
+	{
+		Name:    "highlights",
+		Summary: "Export the highlights of items",
+		Forms: []string{
+			"highlights [--cached] [--format=<format>] [--out=<file>] " + filterOptions,
+			"highlights push [--cached] [--dry-run] " + filterOptions,
+		},
+		Description: `"highlights push" sends the highlights not sent before to Readwise, with the access token set in config.json. ` +
+			`The highlights kept locally by "pocket highlight" are exported and pushed along with those from Pocket.`,
+	},
+	{
+		Name:    "highlight",
+		Summary: "Highlight a passage of an item",
+		Forms:   []string{"highlight <item-id> <quote>..."},
+		Args: []argSpec{
+			{"<quote>...", `The passage, as in the article text, or "-" to read it from standard input`},
+		},
+		Description: "The highlight is made in Pocket, as by its apps, if it takes it: creating highlights is not documented by Pocket, " +
+			"which may refuse them from other apps. Highlights it refuses, or made while it cannot be reached, are kept in highlights.json " +
+			"next to config.json instead, and exported and pushed to Readwise by highlights as if they were in Pocket.",
+	},
+	{
+		Name:    "epub",
+		Summary: "Make an EPUB book of the article text of items",
+		Forms:   []string{"epub [--out=<file>] [--archive] " + filterOptions},
+	},
+	{
+		Name:    "pdf",
+		Summary: "Make a printable PDF of the article text of items",
+		Forms:   []string{"pdf [--out=<file>] <item-id>..."},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, or "-" to read them from standard input, as printed by --output ids`},
+		},
+		Description: "Each article starts on a new page, with its title and URL; images are left out. " +
+			"Items are looked up in the local mirror, and their articles are read from the cache, or fetched and cached.",
+	},
+	{
+		Name:    "feed",
+		Summary: "Write or serve the items as an Atom feed, or a JSON Feed",
+		Forms:   []string{"feed [--cached] [--format=<format>] [--out=<file>] [--listen=<addr>] " + filterOptions},
+		Description: `--format json writes a JSON Feed 1.1 instead of Atom. ` +
+			"With --listen, the feed is served in both formats, as /feed.xml and /feed.json.",
+	},
+	{
+		Name:    "email",
+		Summary: "Email items, or their article text as an EPUB, using the smtp settings",
+		Forms:   []string{"email [--epub] [--to=<address>] [--archive] " + filterOptions},
+	},
+	{
+		Name:    "plan",
+		Summary: "Pick items to read within a time budget",
+		Forms:   []string{"plan [--cached] [--minutes=<n>] [--prefer=<what>] [--tag-as=<tag>] [--open] [--output=<format>] [--start=<time>] [--domain=<domain>] [--tag=<tag>] " + minutesOptions},
+		Description: "With --output ics, the plan is written as an iCalendar file, one event per item with its URL, " +
+			"back to back from --start, or the next quarter of an hour, for the reading to land on a calendar.",
+	},
+	{
+		Name:    "videos",
+		Summary: "Show the videos saved with their lengths, or play some",
+		Forms: []string{
+			"videos [--cached] [--output=<format>] [--state=<state>] " + filterOptions,
+			"videos play [--cached] [--archive] " + filterOptions,
+		},
+		Description: `"videos play" lets you choose among the unread videos, as "pocket pick" does, and plays them one after another ` +
+			"in the player set in config.json, mpv or VLC by default. With --archive, each video is archived once the player exits; " +
+			"if it fails, the rest are left for later.",
+	},
+	{
+		Name:    "listen",
+		Summary: "Show the videos and audio files saved, as a queue for a media player",
+		Forms:   []string{"listen [--cached] [--export=<file>] [--output=<format>] [--state=<state>] " + filterOptions},
+		Description: "The queue holds the items that are videos or links to audio files, oldest first, " +
+			"with the time to listen to each as estimated by Pocket. --export writes it as an M3U playlist, " +
+			`such as for "mpv --playlist=queue.m3u", and --output json prints it with the durations in seconds.`,
+	},
+	{
+		Name:    "goals",
+		Summary: "Show progress on the reading goals in the settings",
+		Forms:   []string{"goals"},
+	},
+	{
+		Name:    "timeline",
+		Summary: "Show the items by the month they were added",
+		Forms:   []string{"timeline [--cached] [--state=<state>] [--counts] [--output=<format>] " + filterOptions},
+	},
+	{
+		Name:    "search",
+		Summary: "Search items, or the full text of those in the local mirror",
+		Forms: []string{
+			"search [--cached] [--reindex] [--limit=<n>] [--output=<format>] <query>...",
+			"search --saved=<name> [--cached] [--limit=<n>] [--output=<format>]",
+		},
+		Args: []argSpec{
+			{"<query>", `Words to search for, "quoted phrases", tag:<tag>, and domain:<domain>`},
+		},
+		Description: "Titles and URLs are searched through the API. With --cached, or --reindex, the full text " +
+			"of the items in the local mirror is searched instead, ranked by relevance. " +
+			"Matched words are highlighted on a terminal. " +
+			"--saved searches for the items of a saved search, as does \"@<name>\" in place of options for commands listing items.",
+	},
+	{
+		Name:    "similar",
+		Summary: "Find saved items related to an item, from the local mirror",
+		Forms:   []string{"similar [--reindex] [--limit=<n>] [--output=<format>] <item-id>"},
+		Description: "Items are ranked by the uncommon words their titles, excerpts, and cached article text share with the item, " +
+			"the tags they share, and whether they are from the same site, using the search index of \"pocket search --cached\".",
+	},
+	{
+		Name:    "read",
+		Summary: "Show the article text of an item, from the cache if possible",
+		Forms:   []string{"read <item-id>"},
+	},
+	{
+		Name:    "summarize",
+		Summary: "Summarize items with a language model",
+		Forms: []string{
+			"summarize [--refresh] [--cached] <item-id>...",
+			"summarize [--refresh] [--cached] [--oldest=<n>|--limit=<n>] " + filterOptions + " " + minutesOptions,
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, or "-" to read them from standard input, as printed by --output ids`},
+		},
+		Description: "The article text of each item, from the cache if it is there, is sent to the model set by \"summarize\" in config.json, " +
+			"through the API of OpenAI, or of servers compatible with it, or of Ollama. " +
+			"Without item IDs, the oldest --oldest unread items matching the filters are summarized, or else the newest --limit. " +
+			"Summaries are kept in summaries.json and shown again, unless --refresh is given.",
+	},
+	{
+		Name:    "autotag",
+		Summary: "Suggest tags for untagged items, from the tagged items most like them",
+		Forms:   []string{"autotag [--apply] [--output=<format>] [--summary=<format>]"},
+		Description: "The titles and excerpts of the items in the local mirror are embedded with the model set by \"autotag\" in config.json, " +
+			"and each untagged unread item is given the tags most of its nearest tagged items have. " +
+			"Embeddings are kept in embeddings.gob; with \"daemon\": true in the autotag settings, the daemon makes them after each sync, " +
+			"and with \"apply\": true, tags the items too.",
+	},
+	{
+		Name:    "launcher",
+		Summary: "List items for a desktop launcher: Alfred, Raycast, rofi, or dmenu and the like",
+		Forms:   []string{"launcher [--format=<format>] [--state=<state>] " + filterOptions},
+		Description: "The items come from the local mirror, newest first. " +
+			`--format alfred writes the JSON of an Alfred Script Filter, whose item IDs "pocket open --cached {query}" opens. ` +
+			`--format raycast writes {"items": [...]} with the props of Raycast's List.Item: id, title, subtitle, keywords, and accessories, ` +
+			`and actions, each to "copy" its content or to "run" pocket with its args, for an extension to show and run as they are. ` +
+			`--format rofi is a rofi script mode, as in "rofi -show pocket -modi 'pocket:pocket launcher --format rofi'", opening the item chosen. ` +
+			`--format dmenu, the default, writes a line per item starting with its ID, for dmenu, wofi, fuzzel, or fzf, to pipe the choice into "pocket open --cached -".`,
+	},
+	{
+		Name:    "status",
+		Summary: "Show a line about the unread items for a tmux status line or a shell prompt, without the API",
+		Forms:   []string{"status [--format=<template>]", "status --daemon [--listen=<addr>] [--output=<format>]"},
+		Description: "The numbers come from status.json, which each sync writes, so that it answers at once even while the daemon syncs. " +
+			"--format takes the placeholders {unread}, {archived}, {favorites}, {minutes} of unread reading time, " +
+			`and {oldest_age} and {synced_age}, the age of the oldest unread item and of the last sync, as in "3d"; ` +
+			`the default is "{unread} unread". In tmux, set status-right to "#(pocket status --format '{unread} unread, {oldest_age}')". ` +
+			`"status --daemon" shows what /status of serve tells: whether the daemon is running and failing, the last sync, the actions queued, the rate limit left, and the size of the article cache; ` +
+			"it reads the same itself if serve is not running, and exits with status 1 if the daemon is failing.",
+	},
+	{
+		Name:    "note",
+		Summary: "Show, set, or clear the note of an item, kept locally",
+		Forms:   []string{"note [--clear] <item-id>", "note <item-id> <text>..."},
+		Args: []argSpec{
+			{"<text>...", `The note, replacing any other, or "-" to read it from standard input`},
+		},
+		Description: "Notes are kept in notes.json next to config.json, as the Pocket API has no place for them. " +
+			"They are shown by tui and exported along with their items.",
+	},
+	{
+		Name:    "cache",
+		Summary: "Show the size of the article cache, clear it, compact it, encrypt the local cache, or fill in word counts",
+		Forms:   []string{"cache (status|clear|gc|encrypt|wordcount)"},
+		Description: "gc removes the articles of items no longer mirrored, evicts articles down to cache.max_mb, " +
+			"those of items no longer unread first, and compacts the mirror; every sync does the same but the compaction. " +
+			"With cache.encrypt set in config.json, the mirror, the article text, and the search index are " +
+			"encrypted with a key kept in the keyring of the system. encrypt encrypts what was written before it was set. " +
+			"wordcount fills in the word counts of the articles saved before Pocket counted words, for reading times and --minutes: " +
+			"from the whole list retrieved again in full, then from the article text, cached or fetched and cached.",
+	},
+	{
+		Name:    "watch-clipboard",
+		Summary: "Save the URLs copied to the clipboard",
+		Forms:   []string{"watch-clipboard [--tags=<tags>] [--yes]"},
+		Description: "Each URL copied is offered to be saved, or saved right away with --yes. " +
+			"The clipboard is read with pbpaste on macOS, PowerShell on Windows, and wl-paste, xclip, or xsel elsewhere. " +
+			"The daemon can watch the clipboard too; see the clipboard settings.",
+	},
+	{
+		Name:    "rules",
+		Summary: "Show how many items each rule matches, or apply the rules",
+		Forms: []string{
+			"rules [--cached]",
+			"rules run [--cached] [--dry-run] [--output=<format>] [--summary=<format>] [--max-api-calls=<n>]",
+		},
+		Description: "Rules come from config.json and rules.json, in that order; the daemon applies them after each sync. " +
+			"Each rule is listed with the items it matches and the actions it takes on them. " +
+			`With --dry-run, --output "jsonl" writes the actions a run would take, one per line, and "diff" the same after the changes to each item as a unified diff in comments, ` +
+			`for "pocket apply" to take as they are once reviewed.`,
+	},
+	{
+		Name:    "daemon",
+		Summary: "Sync periodically in the background, applying rules and sending notifications",
+		Forms: []string{
+			"daemon [--interval=<duration>] [--articles]",
+			"daemon status",
+			"daemon install [--interval=<duration>] [--articles] [--print]",
+		},
+		Description: "Before each sync, the new entries of the feeds in config.json are saved. " +
+			"With mail_in set, the URLs in the mail sent to its SMTP address are saved as it comes. " +
+			"With bot set, it takes commands such as \"!save <url>\" and \"!list <tag>\" posted to a Matrix, Slack, or Discord channel. " +
+			"With telegram set, it saves the URLs messaged to a Telegram bot, tagged with their hashtags. " +
+			"A failed sync is tried again after --interval, doubled with each failure up to six hours. " +
+			"Changes to config.json and rules.json are applied as they are saved, without restarting. " +
+			"With daemon.sync set in config.json, it syncs at the times of that cron expression instead, --interval only setting how soon a failed sync is tried again. " +
+			`"daemon install" sets the daemon up as a systemd user service or a launchd agent.`,
+	},
+	{
+		Name:    "serve",
+		Summary: "Serve a web page and an HTTP API for the items",
+		Forms:   []string{"serve [--listen=<addr>]"},
+		Description: "Requests need the token stored in ~/.config/pocket/serve_token. " +
+			"The page offers a bookmarklet saving the current page. " +
+			"With mail_in set in config.json, the URLs in the mail sent to its SMTP address are saved too.",
+	},
+	{
+		Name:    "native-host",
+		Summary: "Run as the native messaging host of a browser extension",
+		Forms: []string{
+			"native-host",
+			"native-host install <extension-id> [--browser=<browser>] [--print]",
+		},
+	},
+	{
+		Name:    "mcp",
+		Summary: "Serve the Model Context Protocol on standard input and output",
+		Forms:   []string{"mcp"},
+	},
+	{
+		Name:    "tui",
+		Summary: "Browse the items in a full-screen terminal interface",
+		Forms:   []string{"tui [--cached] " + filterOptions},
+	},
+	{
+		Name:    "pick",
+		Summary: "Choose items with fzf, or a built-in fuzzy finder, and act on them",
+		Forms:   []string{"pick [--cached] [--action=<action>] [--lines] " + filterOptions},
+	},
+	{
+		Name:    "triage",
+		Summary: "Go through the items one at a time, deciding on each with a key",
+		Forms:   []string{"triage [--cached] " + filterOptions + " " + minutesOptions},
+		Description: "A session resumes where the last one with the same filters stopped. " +
+			"The latest decisions are sent in batches, and can be undone until then.",
+	},
+	{
+		Name:    "snooze",
+		Summary: "Archive an item until a later date",
+		Forms:   []string{"snooze <item-id> <until>"},
+		Args: []argSpec{
+			{"<until>", `A date (2006-01-02) or a period (3d, 2w, 1m, 1y); the item is archived until then, and brought back by "pocket sync" or the daemon`},
+		},
+	},
+	{
+		Name:    "qr",
+		Summary: "Show the URL of an item as a QR code",
+		Forms:   []string{"qr <item-id>"},
+	},
+	{
+		Name:    "priority",
+		Summary: "Set the priority of an item, to work through the unread items in order",
+		Forms:   []string{"priority <item-id> <priority>"},
+		Args: []argSpec{
+			{"<priority>", `From 1, the highest, to 5, or 0 or "none" to remove it; kept as a tag like "p:1"`},
+		},
+		Description: `"pocket list --sort priority" lists the items with the highest priority first, ` +
+			"and the keys 1 to 5 set the priority of the selected item in tui, 0 removing it.",
+	},
+	{
+		Name:    "history",
+		Summary: "Show when an item was added, tagged, favorited, archived, or brought back",
+		Forms:   []string{"history [--output=<format>] <item-id>"},
+		Description: "Changes made by pocket are recorded as they are made, and changes made elsewhere as \"pocket sync\" " +
+			"or the daemon sees them, in history.jsonl in the config directory; the times Pocket keeps fill in the rest.",
+	},
+	{
+		Name:    "audit",
+		Summary: "Show every change pocket made to the account, or to an item",
+		Forms: []string{
+			"audit [--since=<when>] [--output=<format>]",
+			"audit [--since=<when>] [--output=<format>] <item-id>",
+		},
+		Description: "Every action Pocket reports as made is appended to audit.jsonl in the config directory, " +
+			"with the time, the account, and the command that made it, whether run by hand, by the daemon, or by a server.",
+	},
+	{
+		Name:    "collection",
+		Summary: "Keep named, ordered reading lists of items",
+		Forms: []string{
+			"collection",
+			"collection create <collection>",
+			"collection add <collection> <item-id>...",
+			"collection remove <collection> <item-id>...",
+			"collection move <collection> <item-id> <position>",
+			"collection show [--cached] [--output=<format>] <collection>",
+			"collection export [--cached] [--format=<format>] [--out=<file>] <collection>",
+			"collection delete <collection>",
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, or "-" to read them from standard input, as printed by --output ids`},
+			{"<position>", "The place to move the item to, counting from 1"},
+		},
+		Description: "Collections are kept in collections.json in the config directory, as Pocket only has tags; " +
+			"items are added at the end, and exported in order as a numbered \"markdown\" or \"org\" list, " +
+			"or in any of the single-file formats of export. Without a subcommand, the collections are listed.",
+	},
+	{
+		Name:    "queue",
+		Summary: "Keep the order to read items in next, apart from when they were added",
+		Forms: []string{
+			"queue show [--cached] [--output=<format>]",
+			"queue push <item-id>...",
+			"queue pop [--cached] [--output=<format>]",
+			"queue move <item-id> <position>",
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, or "-" to read them from standard input, as printed by --output ids`},
+			{"<position>", "The place to move the item to, counting from 1"},
+		},
+		Description: "The reading queue is kept in reading-queue.json in the config directory. push adds items at the end, " +
+			"pop prints the first and takes it off, as \"pocket open --next\" does opening it, and items archived or deleted leave it on their own. " +
+			"tui shows it under _queue_ in the sidebar, and p there queues the selected item or takes it off.",
+	},
+	{
+		Name:    "trash",
+		Summary: "List the items in the trash, take them out, or delete those there long enough",
+		Forms: []string{
+			"trash [--cached]",
+			"trash empty [--cached] [--yes] [--summary=<format>] [--max-api-calls=<n>]",
+			"trash restore <item-id>...",
+		},
+		Args: []argSpec{
+			{"<item-id>...", `Item IDs, or "-" to read them from standard input, as printed by --output ids`},
+		},
+		Description: "With trash.days set in config.json, the items deleted by list --cull and the duplicates list deletes are moved to the trash instead: " +
+			"archived and tagged trash: and the day, as in trash:2025-07-01. " +
+			"empty deletes the items in the trash for trash.days or more, or all of them if it is not set, " +
+			"and restore moves items back to the unread list, without the tag.",
+	},
+	{
+		Name:    "import",
+		Summary: "List the import runs, or delete the items one added",
+		Forms: []string{
+			"import [--from=<account>] [--cached]",
+			"import rollback <run-id> [--from=<account>] [--dry-run] [--output=<format>] [--yes] [--summary=<format>] [--max-api-calls=<n>]",
+		},
+		Args: []argSpec{
+			{"<run-id>", `An import run, as in "2024-06-01-1504-pinboard", listed by "pocket import"`},
+		},
+		Description: "restore, copy, migrate, and bridge pull tag the items they add with import: and the run, " +
+			"named after when it started and where the items came from, so that a bad import can be undone exactly. " +
+			"Items that were already there are left as they are. " +
+			`With --dry-run, --output "jsonl" or "diff" writes the deletions for "pocket apply", as for "rules run".`,
+	},
+	{
+		Name:    "doctor",
+		Summary: "Check the configuration, authorization, and local state, and for notices about this release",
+		Forms:   []string{"doctor"},
+		Description: "Once a day, pocket also checks for notices about changes to the Pocket API that break its release, " +
+			"sending only its version and OS; set \"version_check\" to false in config.json, or POCKET_NO_VERSION_CHECK, to turn it off.",
+	},
+	{
+		Name:    "completion",
+		Summary: "Print a shell completion script",
+		Forms:   []string{"completion <shell>"},
+		Args: []argSpec{
+			{"<shell>", `"bash", "zsh", "fish", or "powershell", as in source <(pocket completion bash)`},
+		},
+	},
+	{
+		Name:    "help",
+		Summary: "Show the help of a command",
+		Forms:   []string{"help [<command>]"},
+	},
+	{
+		Name:    "man",
+		Summary: "Write the manual pages",
+		Forms:   []string{"man [--dir=<dir>]"},
+	},
+	{
+		Name:    "demo",
+		Summary: "Make up items to try pocket on with --simulate",
+		Forms:   []string{"demo [--items=<n>] [--seed=<n>] [--now=<date>] [--out=<file>]"},
+		Description: "The items are as \"pocket export --format json\" writes them, for --simulate to run commands against, as in pocket demo --out demo.json, then pocket --simulate demo.json tui: " +
+			"articles and talks from a few dozen sites on ten subjects, tagged and not, read and not, of all lengths, added over the last three years, and a few saved twice. " +
+			"Their dates are counted back from --now, today unless given; the same --seed and --now make the same items, to share a library reproducing an issue without sharing an account.",
+	},
+}
+
+// optionSpecs describe the options of all commands.
+var optionSpecs = []optionSpec{
+	{Long: "--cached", Help: `Read items from the local mirror updated by "pocket sync" instead of the Pocket API`},
+	{Long: "--format", Short: "-f", Arg: "<template>", Help: `A Go template to show items, or the output format of export ("markdown", "org", "json", "wallabag", "omnivore", or "shiori"), highlights ("markdown", "json", or Readwise-compatible "csv"), feed ("atom" or "json"), launcher ("alfred", "raycast", "rofi", or "dmenu"), and the placeholders of status, as in "{unread} unread"`},
+	{Long: "--domain", Short: "-d", Arg: "<domain>", Help: "Filter items by their domain"},
+	{Long: "--search", Short: "-s", Arg: "<query>", Help: "Filter items by a search of their title and URL"},
+	{Long: "--min-minutes", Arg: "<n>", Help: "Only items taking at least this many minutes to read, estimated by Pocket or from the word count"},
+	{Long: "--max-minutes", Arg: "<n>", Help: "Only items taking at most this many minutes to read; items of unknown length, such as videos, are left out"},
+	{Long: "--lang", Arg: "<lang>", Help: `Filter items by their language, as in "en" or "en,de": the one detected by Pocket, or else guessed from the title and excerpt; "unknown" for those it cannot tell`},
+	{Long: "--tag", Short: "-t", Arg: "<tag>", Help: "Filter items by a tag, or _untagged_"},
+	{Long: "--sort", Short: "-o", Arg: "<sort>", Help: `Sort items by "newest", "oldest", "title", "site", or for list, "priority"`},
+	{Long: "--plain", Help: "Leave out the emoji marking favorites, videos and images, long reads, and items over a year old"},
+	{Long: "--clear", Help: "Remove the note of the item"},
+	{Long: "--cull", Help: "Open items one by one in a browser and prompt to delete each one"},
+	{Long: "--oldest", Arg: "<n>", Help: "Open the n oldest unread items (for summarize, summarize them)"},
+	{Long: "--next", Help: "Open the next item of the reading queue, taking it off"},
+	{Long: "--refresh", Help: "Summarize the items again, instead of showing the summaries kept"},
+	{Long: "--apply", Help: "Give the items the tags suggested"},
+	{Long: "--before-id", Arg: "<item-id>", Help: "Take the items of --state added before this one, as to clean up what came before a point"},
+	{Long: "--after-id", Arg: "<item-id>", Help: "Take the items of --state added after this one, as to undo an import that went wrong"},
+	{Long: "--opened-unarchived", Help: `List the unread items that were opened, last opened first; needs "track_opened": true in config.json`},
+	{Long: "--delete", Help: "Delete all items retrieved (for archive-domain, instead of archiving them)"},
+	{Long: "--link-status", Arg: "<status>", Help: `Only show the items whose links are "broken", "paywalled", or "redirected", or several, as in "broken,paywalled"`},
+	{Long: "--fix-redirects", Help: "Save the items whose links redirect permanently under their new URLs, deleting the old ones"},
+	{Long: "--yes", Short: "-y", Help: "Do not ask for confirmation"},
+	{Long: "--max-api-calls", Arg: "<n>", Help: "Make at most this many requests to the Pocket API, leaving out the changes that would take more, as when sharing a consumer key"},
+	{Long: "--resume", Help: "Continue the run stopped before, from its journal, instead of starting over"},
+	{Long: "--summary", Arg: "<format>", Default: "text", Help: `Print a summary of the changes made on stderr, with the failures and the API calls used, as "text", "json", or "none"`},
+	{Long: "--title", Arg: "<title>", Help: "A manually specified title for the article"},
+	{Long: "--tags", Arg: "<tags>", Help: "A comma-separated list of tags"},
+	{Long: "--from-tabs", Arg: "<browser>", Help: `Save tabs open in "firefox", "chrome", or "chromium"`},
+	{Long: "--queue", Help: "Queue the URL for the next sync instead of saving it now"},
+	{Long: "--profile", Arg: "<dir>", Help: "Directory of the browser profile to read the tabs of, instead of the one used last"},
+	{Long: "--export", Arg: "<file>", Help: `File to write the listening queue to as an M3U playlist, or "-" for stdout`},
+	{Long: "--git", Arg: "<dir>", Help: "Directory to back up every item into, one file per item"},
+	{Long: "--dir", Arg: "<dir>", Default: ".", Help: "Directory to write one file per item (or manual page, or the pages of the site) into"},
+	{Long: "--pdf", Help: "Print pages to PDF with headless Chrome or Chromium"},
+	{Long: "--out", Arg: "<file>", Default: "-", Help: `File to write output to, or "-" for stdout`},
+	{Long: "--archive", Help: "Archive the items included in the book (or email), or the videos played, afterwards"},
+	{Long: "--epub", Help: "Attach the article text of the items as an EPUB"},
+	{Long: "--to", Arg: "<address>", Help: `Recipient, overriding "to" in the smtp settings (for copy and migrate, the account to add items to)`},
+	{Long: "--listen", Arg: "<addr>", Help: "Serve the feed at /feed.xml and /feed.json on this address instead (for serve, the address to listen on, and for status --daemon, that of serve; 127.0.0.1:8765 if not given)"},
+	{Long: "--daemon", Help: "Report on the daemon, through /status of serve"},
+	{Long: "--output", Arg: "<format>", Default: "text", Help: `Output as "text", "json", "csv" or "markdown" for a table, or for commands listing items, "ids": the item IDs one per line, to pipe into "pocket archive -" and the like; with --dry-run, "jsonl" or "diff" for the actions to take, as "pocket apply" takes them`},
+	{Long: "--chart", Help: "Show weekly activity as sparklines"},
+	{Long: "--since", Arg: "<when>", Help: "Only count items added since a date (2006-01-02) or within a period (30d, 6w, 3m, 1y) (for audit, only show the changes made since)"},
+	{Long: "--limit", Arg: "<n>", Default: "10", Help: "Number of entries to show per section, or of search results (for domains, of the domains to look for feeds of)"},
+	{Long: "--by", Arg: "<column>", Default: "items", Help: `Sort domains by their number of "items", of "unread" items, by average "age", or by "domain" name`},
+	{Long: "--cloud", Help: "Show the tags as a cloud, more used tags in bolder type"},
+	{Long: "--stale", Help: "Only show tags not given to any item added in the last year"},
+	{Long: "--minutes", Arg: "<n>", Default: "30", Help: "Reading time budget in minutes"},
+	{Long: "--prefer", Arg: "<what>", Default: "oldest", Help: `Prefer the "oldest" items, or "tagged" ones`},
+	{Long: "--tag-as", Arg: "<tag>", Help: `Tag the planned items, e.g. with "today"`},
+	{Long: "--open", Help: "Open the planned items in a browser"},
+	{Long: "--start", Arg: "<time>", Help: `When to start reading the plan written with --output ics, as in "18:30" today or "2006-01-02 18:30"`},
+	{Long: "--state", Arg: "<state>", Default: "unread", Help: `Include "unread", "archive", or "all" items`},
+	{Long: "--counts", Help: "Only show the number of items added each month"},
+	{Long: "--keep-daily", Arg: "<n>", Default: "7", Help: "Number of days to keep the last backup of"},
+	{Long: "--keep-weekly", Arg: "<n>", Default: "4", Help: "Number of weeks to keep the last backup of"},
+	{Long: "--saved", Arg: "<name>", Help: `A saved search in the settings, also given as "@<name>"`},
+	{Long: "--conflict", Arg: "<policy>", Default: "merge", Help: `What to do with items already in Pocket, found by their URL: "merge" the tags, favorite, and archive state into them, "update" them, the same as "merge" under its earlier name, or "skip" them`},
+	{Long: "--from", Arg: "<account>", Default: "default", Help: "Account to take items from (for import, the account the items were imported into)"},
+	{Long: "--dry-run", Help: "Only check the actions, without making any change"},
+	{Long: "--articles", Help: `Also cache the article text of unread items, for "pocket read" to work offline`},
+	{Long: "--drift", Help: `Also fingerprint the article text of some items, to find those changed since they were saved with "pocket drift"`},
+	{Long: "--interval", Arg: "<duration>", Default: "15m", Help: "Time between syncs, doubled after each failure up to 6h"},
+	{Long: "--print", Help: "Print the service definition (or native host files) instead of installing it"},
+	{Long: "--browser", Arg: "<browser>", Default: "chrome", Help: `Register the host with "chrome", "chromium", or "firefox"`},
+	{Long: "--reindex", Help: "Rebuild the search index from the local mirror first"},
+	{Long: "--action", Arg: "<action>", Default: "open", Help: `What to do with the chosen items: "open", "archive", "delete", or "copy" their URLs`},
+	{Long: "--lines", Help: "Print the items as tab-separated lines for a fuzzy finder instead (item ID first)"},
+	{Long: "--items", Arg: "<n>", Default: "200", Help: "Number of items to make up"},
+	{Long: "--seed", Arg: "<n>", Default: "1", Help: "Seed of the items made up; the same one and --now make the same items"},
+	{Long: "--now", Arg: "<date>", Help: "Date (2006-01-02) the dates of the items made up are counted back from, instead of today"},
+}
+
+// wrapText breaks text into lines of at most width columns.
+func wrapText(text string, width int) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// helpColumn is where descriptions start in the help.
+const helpColumn = 26
+
+// describe formats a term and its description for the help, with the
+// description wrapped at 80 columns. The suffix, such as the default value,
+// is kept on one line.
+func describe(b *strings.Builder, term, help, suffix string) {
+	indent := strings.Repeat(" ", helpColumn)
+	lines := wrapText(help, 80-helpColumn)
+	if suffix != "" {
+		if last := lines[len(lines)-1]; len(last)+1+len(suffix) <= 80-helpColumn {
+			lines[len(lines)-1] = last + " " + suffix
+		} else {
+			lines = append(lines, suffix)
+		}
+	}
+	for i, line := range lines {
+		if i == 0 {
+			if len(term)+4 <= helpColumn {
+				fmt.Fprintf(b, "  %-*s%s\n", helpColumn-2, term, line)
+				continue
+			}
+			fmt.Fprintf(b, "  %s\n", term)
+		}
+		b.WriteString(indent + line + "\n")
+	}
+}
+
+func (o optionSpec) term() string {
+	term := o.Long
+	if o.Arg != "" {
+		term += " " + o.Arg
+	}
+	if o.Short != "" {
+		term = o.Short + ", " + term
+	}
+	return term
+}
+
+// defaultSuffix notes the default value in the help.
+func (o optionSpec) defaultSuffix() string {
+	if o.Default != "" {
+		return tr(msgHelpDefault, map[string]string{"Default": o.Default})
+	}
+	return ""
+}
+
+// loggingOptions are the global options taken by every command, before or
+// after its name.
+var loggingOptions = []optionSpec{
+	{Long: "--quiet", Short: "-q", Help: "Only log warnings and errors"},
+	{Long: "--verbose", Short: "-v", Help: "Also log debugging messages"},
+	{Long: "--log-format", Arg: "<format>", Default: "text", Help: `Log to stderr as "text" or "json" lines`},
+	{Long: "--dates", Arg: "<style>", Help: `Show dates as "relative" ("3 weeks ago"), "iso" (ISO 8601), or in the "locale" of $LC_TIME, also in the "date" function of --format templates`},
+	{Long: "--wait", Help: "Wait for another pocket process using the local mirror or authorizing, such as a sync run by cron, instead of failing"},
+	{Long: "--wait-for-quota", Help: "Wait for the API rate limit to reset when an operation needs more requests than are left, instead of only warning"},
+	{Long: "--screen-reader", Help: "Suit the output to screen readers: progress in whole lines, no colors, links, or full-screen interfaces, prompts spelling out their answers, and numbered choices"},
+	{Long: "--simulate", Arg: "<fixture>", Help: `Run against an account kept in memory, holding the items of a file written by "pocket export --format json" or "pocket demo", instead of Pocket, with the state kept apart and thrown away after`},
+	{Long: "--log-actions", Arg: "<file>", Help: `Append the actions sent to Pocket to a file, one JSON object per line, as "pocket apply" takes them; with --simulate, to review a plan before applying it`},
+	{Long: "--read-only", Help: "Refuse anything that would change the account, as when developing automations against it"},
+	{Long: "--locale", Arg: "<locale>", Help: `Show messages in the language of this locale, as in "de" or "de_AT", instead of that of $LC_ALL, $LC_MESSAGES, or $LANG`},
+}
+
+// globalOptions are the options taken before any command.
+var globalOptions = append([]optionSpec{
+	{Long: "--help", Short: "-h", Help: "Show this help, or with a command, the help of the command"},
+	{Long: "--version", Help: "Show the version"},
+}, loggingOptions...)
+
+// overviewHelp renders the help listing the commands and aliases.
+func overviewHelp(aliases map[string]string) string {
+	var b strings.Builder
+	b.WriteString(tr(msgHelpIntro, nil) + "\n\n" + tr(msgHelpUsage, nil) + "\n")
+	b.WriteString("  pocket <command> [<args>...] [options]\n  pocket help <command>\n")
+
+	b.WriteString("\n" + tr(msgHelpCommands, nil) + "\n")
+	for _, command := range commandSpecs {
+		describe(&b, command.Name, commandSummary(command), "")
+	}
+
+	if len(aliases) > 0 {
+		b.WriteString("\n" + tr(msgHelpAliases, nil) + "\n")
+		for _, name := range aliasNames(aliases) {
+			describe(&b, name, aliases[name], "")
+		}
+	}
+
+	b.WriteString("\n" + tr(msgHelpGlobalOptions, nil) + "\n")
+	for _, option := range globalOptions {
+		describe(&b, option.term(), optionHelp(option), option.defaultSuffix())
+	}
+
+	b.WriteString("\n" + tr(msgHelpMore, nil) + "\n")
+	return b.String()
+}
+
+// commandHelp renders the help of a command.
+func commandHelp(command commandSpec) string {
+	var b strings.Builder
+	b.WriteString(commandSummary(command) + ".\n\n" + tr(msgHelpUsage, nil) + "\n")
+	for _, form := range command.Forms {
+		b.WriteString("  pocket " + form + "\n")
+	}
+
+	if command.Description != "" {
+		b.WriteString("\n")
+		for _, line := range wrapText(command.Description, 80) {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if len(command.Args) > 0 {
+		b.WriteString("\n" + tr(msgHelpArguments, nil) + "\n")
+		for _, arg := range command.Args {
+			describe(&b, arg.Name, arg.Help, "")
+		}
+	}
+
+	if options := command.options(); len(options) > 0 {
+		b.WriteString("\n" + tr(msgHelpOptions, nil) + "\n")
+		for _, option := range options {
+			describe(&b, option.term(), optionHelp(option), option.defaultSuffix())
+		}
+	}
+
+	b.WriteString("\n" + tr(msgHelpGlobalOptions, nil) + "\n")
+	for _, option := range loggingOptions {
+		describe(&b, option.term(), optionHelp(option), option.defaultSuffix())
+	}
+
+	return b.String()
+}
+
+var formOptionPattern = regexp.MustCompile(`(--[a-z-]+)(?:=(<[a-z-]+>))?`)
+
+// options returns the options the command takes in any of its forms, with
+// the value named as in the forms, as --to is "<address>" for email but
+// "<account>" for copy.
+func (c commandSpec) options() []optionSpec {
+	used := map[string]string{}
+	for _, form := range c.Forms {
+		for _, m := range formOptionPattern.FindAllStringSubmatch(form, -1) {
+			used[m[1]] = m[2]
+		}
+	}
+
+	options := []optionSpec{}
+	for _, option := range optionSpecs {
+		if arg, ok := used[option.Long]; ok {
+			if arg != "" {
+				option.Arg = arg
+			}
+			options = append(options, option)
+		}
+	}
+	return options
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// completeCommand is the hidden command the completion scripts call with the
+// words on the command line, the last one being completed.
+const completeCommand = "__complete"
+
+// maxCompletedItems bounds the item IDs offered for completion.
+const maxCompletedItems = 50
+
+// usageLine is one form of a command from the usage.
+type usageLine struct {
+	// words are the literal words naming the command, as in "daemon status".
+	words []string
+	// options maps the options accepted to whether they take a value.
+	options map[string]bool
+	// args are the positional arguments, as in "<item-id>".
+	args []string
+	// repeated is whether the last argument may be given more than once.
+	repeated bool
+}
+
+var usageTokenPattern = regexp.MustCompile(`--[a-z-]+(=<[^>]+>)?|<[a-z-]+>(\.\.\.)?|\([a-z|-]+\)|[a-z][a-z-]*`)
+
+// parseForms parses the forms of the commands in their specs.
+func parseForms(specs []commandSpec) []usageLine {
+	lines := []usageLine{}
+	for _, spec := range specs {
+		for _, form := range spec.Forms {
+			lines = append(lines, parseForm(form)...)
+		}
+	}
+	return lines
+}
+
+// parseForm parses a form, as in "cache (status|clear)", into usage lines
+// without alternatives.
+func parseForm(synopsis string) []usageLine {
+	// Global options are accepted by every form
+	options := map[string]bool{}
+	for _, option := range loggingOptions {
+		options[option.Long] = option.Arg != ""
+	}
+	forms := []usageLine{{options: options}}
+	literal := true
+	for _, token := range usageTokenPattern.FindAllString(synopsis, -1) {
+		switch {
+		case strings.HasPrefix(token, "--"):
+			name, value, _ := strings.Cut(token, "=")
+			for _, form := range forms {
+				form.options[name] = value != ""
+			}
+			literal = false
+		case strings.HasPrefix(token, "<"):
+			for i := range forms {
+				forms[i].args = append(forms[i].args, strings.TrimSuffix(token, "..."))
+				forms[i].repeated = strings.HasSuffix(token, "...")
+			}
+			literal = false
+		case strings.HasPrefix(token, "("):
+			// Alternatives, as in "(status|clear)"
+			expanded := []usageLine{}
+			for _, alt := range strings.Split(strings.Trim(token, "()"), "|") {
+				for _, form := range forms {
+					form.words = append(append([]string{}, form.words...), alt)
+					expanded = append(expanded, form)
+				}
+			}
+			forms = expanded
+		case literal:
+			for i := range forms {
+				forms[i].words = append(forms[i].words, token)
+			}
+		}
+	}
+	return forms
+}
+
+// optionValues lists the fixed values some options take.
+var optionValues = map[string][]string{
+	"--sort":       {"newest", "oldest", "title", "site", "priority"},
+	"--output":     {"text", "json", "ids"},
+	"--state":      {"unread", "archive", "all"},
+	"--by":         {"items", "unread", "age", "domain"},
+	"--prefer":     {"oldest", "tagged"},
+	"--conflict":   {"merge", "update", "skip"},
+	"--action":     {"open", "archive", "delete", "copy"},
+	"--browser":    {"chrome", "chromium", "firefox"},
+	"--from-tabs":  {"chrome", "chromium", "firefox"},
+	"--log-format": {"text", "json"},
+	"--dates":      {"relative", "iso", "locale"},
+	"--summary":    {"text", "json", "none"},
+}
+
+// mirrorCompletions returns values from the local mirror: tags and domains
+// by how often they are used, and the newest unread item IDs with their
+// titles. It returns nothing if the mirror is missing or busy.
+func mirrorCompletions(kind string) []string {
+	m, err := openMirror()
+	if err != nil {
+		return nil
+	}
+	defer m.Close()
+
+	items, err := m.Items()
+	if err != nil {
+		return nil
+	}
+
+	if kind == "item" {
+		unread := []api.Item{}
+		for _, item := range items {
+			if item.Status == api.ItemStatusUnread {
+				unread = append(unread, item)
+			}
+		}
+		sort.Slice(unread, func(i, j int) bool { return unread[i].TimeAdded.After(unread[j].TimeAdded.Time) })
+		if len(unread) > maxCompletedItems {
+			unread = unread[:maxCompletedItems]
+		}
+
+		result := make([]string, len(unread))
+		for i, item := range unread {
+			result[i] = strconv.Itoa(item.ItemID) + "\t" + item.Title()
+		}
+		return result
+	}
+
+	counts := map[string]int{}
+	for _, item := range items {
+		if kind == "tag" {
+			for _, tag := range item.TagNames() {
+				counts[tag]++
+			}
+		} else if kind == "lang" {
+			counts[library.Lang(item)]++
+		} else if domain := item.Domain(); domain != "" {
+			counts[domain]++
+		}
+	}
+	result := make([]string, 0, len(counts))
+	for value := range counts {
+		result = append(result, value)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if counts[result[i]] != counts[result[j]] {
+			return counts[result[i]] > counts[result[j]]
+		}
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// tagCompletions returns the tags of the tag cache, which is there even while
+// another process holds the mirror, or else of the mirror.
+func tagCompletions() []string {
+	if tags := cachedTags(); tags != nil {
+		return tags
+	}
+	return mirrorCompletions("tag")
+}
+
+// valueCompletions completes the value of an option.
+func valueCompletions(option string) []string {
+	switch option {
+	case "--tag", "--tag-as":
+		return tagCompletions()
+	case "--domain":
+		return mirrorCompletions("domain")
+	case "--lang":
+		return mirrorCompletions("lang")
+	case "--locale":
+		return append([]string{"en"}, catalog.Languages()...)
+	case "--saved":
+		settings, err := loadSettings()
+		if err != nil {
+			return nil
+		}
+		return savedSearchNames(settings.Searches)
+	}
+	return optionValues[option]
+}
+
+// argCompletions completes a positional argument.
+func argCompletions(arg string) []string {
+	switch arg {
+	case "<item-id>":
+		return mirrorCompletions("item")
+	case "<tag>":
+		return tagCompletions()
+	case "<domain>":
+		return mirrorCompletions("domain")
+	case "<priority>":
+		return []string{"1", "2", "3", "4", "5", "none"}
+	case "<collection>":
+		collections, err := loadCollections()
+		if err != nil {
+			return nil
+		}
+		return collectionNames(collections)
+	case "<shell>":
+		return []string{"bash", "zsh", "fish", "powershell"}
+	case "<service>":
+		return bridgeServices
+	case "<command>":
+		names := []string{}
+		for _, command := range commandSpecs {
+			names = append(names, command.Name+"\t"+command.Summary)
+		}
+		return names
+	}
+	return nil
+}
+
+func hasPrefix(words, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if words[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// complete returns the candidates for the last of words, the arguments
+// typed so far. Candidates may be followed by a tab and a description.
+func complete(lines []usageLine, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	// Bash splits "--tag=foo" into "--tag", "=", and "foo".
+	prior := []string{}
+	for _, word := range words[:len(words)-1] {
+		if word != "=" {
+			prior = append(prior, word)
+		}
+	}
+	if current == "=" {
+		current = ""
+	}
+
+	takesValue := map[string]bool{}
+	for _, line := range lines {
+		for option, value := range line.options {
+			takesValue[option] = takesValue[option] || value
+		}
+	}
+
+	positional := []string{}
+	pending := ""
+	for _, word := range prior {
+		switch {
+		case pending != "":
+			pending = ""
+		case strings.HasPrefix(word, "-"):
+			if !strings.Contains(word, "=") && takesValue[word] {
+				pending = word
+			}
+		default:
+			positional = append(positional, word)
+		}
+	}
+
+	// The forms of the command given, as in "daemon status" rather than
+	// "daemon" when both match
+	matched := []usageLine{}
+	longest := 0
+	for _, line := range lines {
+		if !hasPrefix(positional, line.words) || len(line.words) < longest {
+			continue
+		}
+		if len(line.words) > longest {
+			matched, longest = nil, len(line.words)
+		}
+		matched = append(matched, line)
+	}
+
+	candidates := []string{}
+	prefix := ""
+	switch {
+	case pending != "":
+		candidates = valueCompletions(pending)
+
+	case strings.HasPrefix(current, "--") && strings.Contains(current, "="):
+		option, value, _ := strings.Cut(current, "=")
+		prefix, current = option+"=", value
+		candidates = valueCompletions(option)
+
+	case strings.HasPrefix(current, "-"):
+		for _, line := range matched {
+			for option := range line.options {
+				candidates = append(candidates, option)
+			}
+		}
+		sort.Strings(candidates)
+
+	default:
+		for _, line := range lines {
+			if len(line.words) > len(positional) && hasPrefix(line.words, positional) {
+				candidates = append(candidates, line.words[len(positional)])
+			}
+		}
+		for _, line := range matched {
+			if len(line.args) == 0 {
+				continue
+			}
+			i := len(positional) - len(line.words)
+			if i >= len(line.args) && line.repeated {
+				i = len(line.args) - 1
+			}
+			if i < len(line.args) {
+				candidates = append(candidates, argCompletions(line.args[i])...)
+			}
+		}
+	}
+
+	result := []string{}
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		value, _, _ := strings.Cut(candidate, "\t")
+		if seen[value] || !strings.HasPrefix(value, current) {
+			continue
+		}
+		seen[value] = true
+		result = append(result, prefix+candidate)
+	}
+	return result
+}
+
+const bashCompletion = `# bash completion for pocket
+_pocket() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    [[ $cur == "=" ]] && cur=
+    local IFS=$'\n'
+    local candidates
+    candidates=$(pocket __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1)
+    if [[ -z $candidates ]]; then
+        compopt -o default
+        COMPREPLY=()
+        return
+    fi
+    COMPREPLY=($(compgen -W "$candidates" -- "$cur"))
+}
+complete -F _pocket pocket
+`
+
+const zshCompletion = `#compdef pocket
+_pocket() {
+    local -a lines described
+    local line value
+    lines=(${(f)"$(pocket __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#lines} == 0 )); then
+        _files
+        return
+    fi
+    for line in $lines; do
+        value=${line%%$'\t'*}
+        value=${value//:/\\:}
+        if [[ $line == *$'\t'* ]]; then
+            described+=("$value:${line#*$'\t'}")
+        else
+            described+=("$value")
+        fi
+    done
+    _describe -V pocket described
+}
+compdef _pocket pocket
+`
+
+const fishCompletion = `# fish completion for pocket
+function __pocket_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    pocket __complete $tokens[2..-1] "$current" 2>/dev/null
+end
+complete -c pocket -f -a '(__pocket_complete)'
+`
+
+const powershellCompletion = `# PowerShell completion for pocket
+Register-ArgumentCompleter -Native -CommandName pocket -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    pocket __complete @words 2>$null | ForEach-Object {
+        $value, $description = $_ -split "` + "`" + `t", 2
+        if (-not $description) { $description = $value }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
+    }
+}
+`
+
+// commandCompletion prints the completion script for a shell.
+func commandCompletion(conf Config) {
+	scripts := map[string]string{
+		"bash":       bashCompletion,
+		"zsh":        zshCompletion,
+		"fish":       fishCompletion,
+		"powershell": powershellCompletion,
+	}
+	script, ok := scripts[conf.Shell]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown shell %q; use bash, zsh, fish, or powershell\n", conf.Shell)
+		os.Exit(1)
+	}
+	fmt.Print(script)
+}
+
+// runComplete prints the candidates for "pocket __complete <word>...".
+func runComplete(words []string) {
+	settings, err := loadSettings()
+	if err != nil {
+		settings = &Settings{}
+	}
+	aliases := allAliases(settings)
+
+	if len(words) > 1 {
+		if expanded, err := expandAliases(words[:1], aliases); err == nil {
+			words = append(expanded, words[1:]...)
+		}
+	}
+	for _, candidate := range complete(parseForms(commandSpecs), words) {
+		fmt.Println(candidate)
+	}
+	if len(words) == 1 {
+		for _, name := range aliasNames(aliases) {
+			if strings.HasPrefix(name, words[0]) {
+				fmt.Println(name + "\t" + aliases[name])
+			}
+		}
+	}
+}
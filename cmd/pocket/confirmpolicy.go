@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// confirmRule says when an operation asks for confirmation: always, never,
+// or when it affects more than Above items. In config.json, it is "always",
+// "never", or the number.
+type confirmRule struct {
+	Never bool
+	Above int
+}
+
+func (r *confirmRule) UnmarshalJSON(data []byte) error {
+	var above int
+	if err := json.Unmarshal(data, &above); err == nil {
+		if above < 0 {
+			return fmt.Errorf("confirm: %d is not a number of items", above)
+		}
+		*r = confirmRule{Above: above}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf(`confirm: use "always", "never", or a number of items, not %s`, data)
+	}
+	switch s {
+	case "always":
+		*r = confirmRule{}
+	case "never":
+		*r = confirmRule{Never: true}
+	default:
+		return fmt.Errorf(`confirm: use "always", "never", or a number of items, not %q`, s)
+	}
+	return nil
+}
+
+// The operations of the confirmation policy
+const (
+	confirmDelete  = "delete"
+	confirmArchive = "archive"
+	confirmOpen    = "open"
+	confirmSave    = "save"
+)
+
+// confirmPolicy says which operations ask for confirmation, from the
+// defaults below and "confirm" in config.json. --yes skips them all.
+var confirmPolicy = map[string]confirmRule{
+	// Deleting one item named on the command line goes ahead, as do those
+	// decided on one at a time, in triage or with --cull.
+	confirmDelete:  {Above: 1},
+	confirmArchive: {Above: 10},
+	// Not to flood the browser with tabs
+	confirmOpen: {Above: 5},
+	// Each URL copied, by watch-clipboard
+	confirmSave: {},
+}
+
+// setConfirmPolicy overrides the default policy with rules.
+func setConfirmPolicy(rules map[string]confirmRule) error {
+	for op, rule := range rules {
+		if _, ok := confirmPolicy[op]; !ok {
+			ops := make([]string, 0, len(confirmPolicy))
+			for op := range confirmPolicy {
+				ops = append(ops, op)
+			}
+			sort.Strings(ops)
+			return fmt.Errorf("config.json: unknown operation %q in confirm; use %s", op, strings.Join(ops, ", "))
+		}
+		confirmPolicy[op] = rule
+	}
+	return nil
+}
+
+// asksConfirmation tells if doing op on count items asks for confirmation.
+func asksConfirmation(conf Config, op string, count int) bool {
+	rule := confirmPolicy[op]
+	return !conf.Yes && !rule.Never && count > rule.Above
+}
+
+// confirmOperation asks with prompt before doing op on count items, if the
+// policy says to and --yes was not given. Without a terminal to ask on, it
+// says so on stderr and declines.
+func confirmOperation(conf Config, op string, count int, prompt string) bool {
+	if !asksConfirmation(conf, op, count) {
+		return true
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintf(os.Stderr, "%s Not without a terminal to confirm on; pass --yes to go ahead\n", prompt)
+		return false
+	}
+	return confirm(prompt)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+func commandCopy(conf Config, consumerKey string, client *api.Client) {
+	from, to := fromToClients(conf, "copy", consumerKey, client)
+
+	items, err := retrieveItems(from, &api.RetrieveOption{
+		State:      api.State(conf.State),
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	existing, err := retrieveItems(to, &api.RetrieveOption{State: api.StateAll})
+	if err != nil {
+		panic(err)
+	}
+	present := map[string]bool{}
+	for _, item := range existing {
+		present[library.URLKey(item.URL())] = true
+	}
+
+	j, err := openJournal(conf, "copy", strings.Join([]string{conf.From, conf.To, conf.State, conf.Domain, conf.SearchQuery, conf.Tag}, "\x00"))
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	copies := []api.Item{}
+	for _, item := range items {
+		url := library.URLKey(item.URL())
+		if !present[url] && !j.done(item.URL()) {
+			copies = append(copies, item)
+			present[url] = true
+		}
+	}
+
+	copied := 0
+	onShutdown(func() {
+		fmt.Printf("Copied %d of %d items before being interrupted; copy again with --resume to add the rest\n", copied, len(copies))
+	})
+
+	summary := newBulkSummary("copy")
+	sent := 0
+	runID := importRunID(j.Started, "copy")
+	err = addItemsWithState(to, copies, runID, func(batch, failed []api.Item) {
+		copied += len(batch) - len(failed)
+		sent += len(batch)
+		summary.addAdded(batch, failed)
+		j.markAdded(batch, failed)
+	})
+	if err == errOverBudget {
+		summary.addNotSent(len(copies) - sent)
+	} else if err != nil {
+		panic(err)
+	}
+
+	if summary.complete() {
+		j.finish()
+	}
+	// Those already in the other account
+	summary.Processed += len(items) - len(copies)
+	summary.Skipped += len(items) - len(copies)
+	reportImportRun(runID, copied)
+	summary.report(conf.Summary)
+}
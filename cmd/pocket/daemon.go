@@ -0,0 +1,481 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/auth"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/webhook"
+)
+
+// maxDaemonBackoff caps the wait between syncs after repeated failures.
+const maxDaemonBackoff = 6 * time.Hour
+
+// daemonNotifyFailures is the number of syncs failing in a row after which
+// the daemon notifies, once, that syncing is failing.
+const daemonNotifyFailures = 3
+
+// authCheckInterval is how often the daemon looks for a new access token
+// while Pocket refuses the one it has.
+const authCheckInterval = time.Minute
+
+// DaemonStatus is written by the daemon after each run, for "pocket daemon
+// status" and other commands to read.
+type DaemonStatus struct {
+	PID       int       `json:"pid"`
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	LastRun   time.Time `json:"last_run"`
+	LastSync  time.Time `json:"last_sync"`
+	LastError string    `json:"last_error,omitempty"`
+	Failures  int       `json:"failures"`
+	// FailingSince is when the syncs failing in a row began to, and
+	// FailureNotified whether that was notified.
+	FailingSince    time.Time `json:"failing_since"`
+	FailureNotified bool      `json:"failure_notified"`
+	NextRun         time.Time `json:"next_run"`
+	// LastDigest is when the weekly digest was last notified.
+	LastDigest time.Time `json:"last_digest"`
+	// LastBulk is when the bulk jobs last ran on their schedule.
+	LastBulk time.Time `json:"last_bulk"`
+	Report   []string  `json:"report,omitempty"`
+}
+
+func daemonStatusPath() string {
+	return filepath.Join(configDir, "daemon.json")
+}
+
+// readDaemonStatus reads the status left by the daemon. Running is cleared if
+// the process that wrote it is gone.
+func readDaemonStatus() (*DaemonStatus, error) {
+	status := &DaemonStatus{}
+	err := loadJSONFromFile(daemonStatusPath(), status)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.Running {
+		status.Running = processRunning(status.PID)
+	}
+
+	return status, nil
+}
+
+// daemonBackoff returns how long to wait after the given number of
+// consecutive failures, doubling the interval each time.
+func daemonBackoff(interval time.Duration, failures int) time.Duration {
+	wait := interval
+	for i := 0; i < failures && wait < maxDaemonBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxDaemonBackoff {
+		wait = maxDaemonBackoff
+	}
+	return wait
+}
+
+// daemonJitter takes up to a fifth off a wait after a failure at random,
+// so that daemons failing together, as when the network goes down, do not
+// all try again at once.
+func daemonJitter(wait time.Duration) time.Duration {
+	if wait <= 0 {
+		return wait
+	}
+	return wait - time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+// daemonFailed records a failed sync in status, notifying once when as many
+// as daemonNotifyFailures have failed in a row.
+func daemonFailed(status *DaemonStatus, err error, now time.Time) {
+	if status.Failures == 0 {
+		status.FailingSince = now
+	}
+	status.LastError = err.Error()
+	status.Failures++
+	slog.Error("Sync failed", "err", err, "failures", status.Failures)
+	if status.Failures < daemonNotifyFailures || status.FailureNotified {
+		return
+	}
+
+	body := fmt.Sprintf("%d syncs have failed since %s: %s", status.Failures, status.FailingSince.Format("Jan 2 15:04"), err)
+	if newJSONError(err).Code == "unauthorized" {
+		body = "Pocket refused the access token. Delete auth.json in the config directory and run pocket to authorize again; the daemon picks up the new token."
+	}
+	// Not tried again, as notify-send may be missing on a server
+	status.FailureNotified = true
+	if err := notify("Pocket sync failing", body); err != nil {
+		slog.Warn("Could not notify the failures", "err", err)
+	}
+}
+
+// daemonRecovered clears the failures of status after a sync succeeded,
+// notifying that it did if the failures were notified.
+func daemonRecovered(status *DaemonStatus) {
+	if status.FailureNotified {
+		body := fmt.Sprintf("Synced again after %d failures since %s", status.Failures, status.FailingSince.Format("Jan 2 15:04"))
+		if err := notify("Pocket sync recovered", body); err != nil {
+			slog.Warn("Could not notify the recovery", "err", err)
+		}
+	}
+	if status.Failures > 0 {
+		slog.Info("Synced again", "failures", status.Failures)
+	}
+	status.LastError = ""
+	status.Failures = 0
+	status.FailingSince = time.Time{}
+	status.FailureNotified = false
+}
+
+// reloadClient returns a client with the access token of auth.json, if it
+// is not that of client, as after authorizing again; nil otherwise.
+func reloadClient(client *api.Client) *api.Client {
+	authFile, err := accountAuthFile(defaultAccount)
+	if err != nil {
+		return nil
+	}
+	accessToken := &auth.Authorization{}
+	if err := loadJSONFromFile(authFile, accessToken); err != nil || accessToken.AccessToken == "" || accessToken.AccessToken == client.AccessToken {
+		return nil
+	}
+	fresh := api.NewClient(client.ConsumerKey, accessToken.AccessToken)
+	audit.Lock()
+	audit.accounts[fresh] = defaultAccount
+	audit.Unlock()
+	return fresh
+}
+
+// daemonRun saves new feed entries, syncs once, applies the rules, sends the
+// notifications and webhooks configured in settings, and runs the hooks of
+// the changes synced, running the bulk jobs and the digest when sched says
+// they are due. The mirror is opened only for the duration of the run,
+// so that other commands can use it in between. An interrupt during the run
+// takes effect at its end.
+func daemonRun(client *api.Client, fetchArticles bool, settings *Settings, sched *daemonSchedule, status *DaemonStatus) ([]string, error) {
+	beginCritical()
+	defer endCritical()
+
+	m, err := openMirror()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	changes := []mirror.Change{}
+	added := []int{}
+	m.OnChange = func(c mirror.Change) {
+		changes = append(changes, c)
+		if c.Kind == mirror.ChangeAdded {
+			added = append(added, c.ItemID)
+		}
+	}
+
+	// New feed entries are saved first, for the sync to bring them in
+	saved, err := pollFeeds(client, m, settings)
+	if err != nil {
+		slog.Warn("Could not poll the feeds", "err", err)
+	}
+
+	now := time.Now()
+	bulk := sched.bulkDue(status, now)
+	report, err := runSync(m, client, fetchArticles && bulk, false)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Synced", report.LogArgs()...)
+	lines := report.Lines()
+	if saved > 0 {
+		lines = append(lines, fmt.Sprintf("Feeds: %d new entries saved", saved))
+	}
+
+	if len(settings.Webhooks) > 0 && !report.Synced.Full {
+		sender := webhook.NewSender(settings.Webhooks)
+		for _, c := range changes {
+			err := sender.Send(string(c.Kind), c)
+			if err != nil {
+				slog.Warn("Webhook delivery failed", "event", c.Kind, "item_id", c.ItemID, "err", err)
+			}
+		}
+	}
+
+	if hookRunner != nil && !report.Synced.Full {
+		for _, c := range changes {
+			runHook(c)
+		}
+	}
+
+	// The first sync downloads everything, which is not news.
+	if settings.Notify.NewItems && !report.Synced.Full && !sched.quietAt(now) {
+		items, err := notableItems(m, added, settings.Notify.Tags)
+		if err == nil {
+			err = notifyNewItems(items)
+		}
+		if err != nil {
+			slog.Warn("Could not notify new items", "err", err)
+		}
+	}
+
+	if settings.Readwise.PushAfterSync {
+		items, err := m.Retrieve(&api.RetrieveOption{State: api.StateAll})
+		if err == nil {
+			var pushed int
+			pushed, err = pushHighlights(settings.Readwise.Token, items)
+			if pushed > 0 {
+				lines = append(lines, fmt.Sprintf("Readwise: %d highlights pushed", pushed))
+			}
+		}
+		if err != nil {
+			slog.Warn("Could not push highlights to Readwise", "err", err)
+		}
+	}
+
+	if settings.Notify.WeeklyDigest && sched.digestDue(status, now) {
+		digest, err := weeklyDigest(m, now)
+		if err == nil {
+			err = notify("Your week in Pocket", digest)
+		}
+		if err != nil {
+			slog.Warn("Could not notify the weekly digest", "err", err)
+		} else {
+			status.LastDigest = now
+		}
+	}
+
+	if settings.AutoTag.Daemon && bulk {
+		if line := daemonAutoTag(client, m, settings.AutoTag); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if bulk {
+		status.LastBulk = now
+	}
+
+	actions, counts, err := ruleActions(m, settings.Rules, now)
+	if err != nil {
+		return lines, err
+	}
+	for _, c := range counts {
+		if c.Actions > 0 {
+			slog.Info("Applying rule", "rule", c.Rule, "matched", c.Matched, "actions", c.Actions)
+		}
+	}
+	if len(actions) == 0 {
+		return lines, nil
+	}
+
+	_, err = modifyInBatches(client, actions)
+	if err != nil && isNetworkError(err) {
+		err = m.Enqueue(actions...)
+	}
+	if err != nil {
+		return lines, err
+	}
+
+	slog.Info("Applied rules", "actions", len(actions))
+	return append(lines, fmt.Sprintf("Rules: %d actions", len(actions))), nil
+}
+
+// daemonAutoTag embeds some more items and, if the settings say to, tags
+// the untagged ones as suggested, returning the line to report, if any.
+// Failures are logged, not to stop the run.
+func daemonAutoTag(client *api.Client, m *mirror.Mirror, s AutoTagSettings) string {
+	result, err := autotag(m, s, autotagBatch)
+	if err != nil {
+		slog.Warn("Could not suggest tags", "err", err)
+		return ""
+	}
+	if result.Embedded > 0 {
+		slog.Info("Embedded items", "model", s.Model, "items", result.Embedded)
+	}
+	actions := result.actions()
+	if len(actions) == 0 {
+		return ""
+	}
+	if !s.Apply {
+		return fmt.Sprintf("Autotag: tags suggested for %d items", len(actions))
+	}
+
+	_, err = modifyInBatches(client, actions)
+	if err != nil && isNetworkError(err) {
+		err = m.Enqueue(actions...)
+	}
+	if err != nil {
+		slog.Warn("Could not tag items", "err", err)
+		return ""
+	}
+	slog.Info("Tagged items", "items", len(actions))
+	return fmt.Sprintf("Autotag: %d items tagged", len(actions))
+}
+
+func commandDaemon(conf Config, client *api.Client) {
+	if conf.Install {
+		commandDaemonInstall(conf)
+		return
+	}
+
+	if conf.Status {
+		status, err := readDaemonStatus()
+		if os.IsNotExist(err) {
+			fmt.Println("The daemon has never run")
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		state := "stopped"
+		if status.Running {
+			state = fmt.Sprintf("running (pid %d)", status.PID)
+		}
+		fmt.Printf("Daemon:    %s\n", state)
+		if !status.LastSync.IsZero() {
+			fmt.Printf("Last sync: %s\n", status.LastSync.Format(time.RFC3339))
+		}
+		if status.LastError != "" {
+			if status.Failures > 0 {
+				fmt.Printf("Error:     %s (%d failures in a row since %s)\n", status.LastError, status.Failures, status.FailingSince.Format(time.RFC3339))
+			} else {
+				fmt.Printf("Error:     %s\n", status.LastError)
+			}
+		}
+		if status.Running {
+			fmt.Printf("Next run:  %s\n", status.NextRun.Format(time.RFC3339))
+		}
+		for _, line := range status.Report {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	interval, err := time.ParseDuration(conf.Interval)
+	if err != nil || interval <= 0 {
+		fmt.Fprintf(os.Stderr, "Invalid interval %q\n", conf.Interval)
+		os.Exit(1)
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		panic(err)
+	}
+	validate := func(settings *Settings) error {
+		if err := validateRules(settings.Rules); err != nil {
+			return err
+		}
+		_, err := settings.Daemon.schedule()
+		return err
+	}
+	if err := validate(settings); err != nil {
+		exitWithError(conf, err)
+	}
+	live := watchSettings(settings, validate)
+
+	status := &DaemonStatus{PID: os.Getpid(), Running: true, StartedAt: time.Now()}
+	if previous, err := readDaemonStatus(); err == nil {
+		status.LastDigest = previous.LastDigest
+		status.LastBulk = previous.LastBulk
+	}
+
+	onShutdown(func() {
+		status.Running = false
+		err := saveJSONToFile(daemonStatusPath(), status)
+		if err != nil {
+			slog.Warn("Could not write the status", "err", err)
+		}
+	})
+
+	if settings.Clipboard.Watch {
+		go daemonWatchClipboard(client, live)
+	}
+	if settings.Bot.Service != "" {
+		go daemonBot(client, live)
+	}
+	if settings.Telegram.Token != "" {
+		go daemonTelegram(client, live)
+	}
+	if settings.MailIn.Listen != "" {
+		go serveMailIn(settings.MailIn, live, func(actions ...*api.Action) error {
+			_, _, err := modifyOrQueue(client, actions...)
+			return err
+		})
+	}
+
+	for {
+		// Validated as they were read
+		settings := live.Get()
+		sched, _ := settings.Daemon.schedule()
+		lines, err := daemonRun(client, conf.FetchArticles, settings, sched, status)
+		status.LastRun = time.Now()
+		if lines != nil {
+			status.LastSync = status.LastRun
+			status.Report = lines
+
+		}
+		now := time.Now()
+		var pageErr *api.PageError
+		if errors.As(err, &pageErr) {
+			// Maintenance passes; the next sync is not put off further
+			status.LastError = err.Error()
+			slog.Warn("Pocket is unavailable; trying again at the next sync", "err", err)
+		} else if err != nil {
+			daemonFailed(status, err, now)
+		} else {
+			daemonRecovered(status)
+		}
+
+		backoff := daemonBackoff(interval, status.Failures)
+		if status.Failures > 0 {
+			backoff = daemonJitter(backoff)
+		}
+		// Pocket is not asked again before the breaker lets requests
+		// through, or before the time it asked to wait when rate limiting
+		notBefore := now
+		if err != nil {
+			notBefore = now.Add(time.Duration(newJSONError(err).RetryAfter) * time.Second)
+		}
+		planNextRun := func() {
+			bulk := conf.FetchArticles || settings.AutoTag.Daemon
+			status.NextRun = sched.nextRun(status, settings, bulk, backoff, now)
+			if notBefore.After(status.NextRun) {
+				status.NextRun = notBefore
+			}
+			err := saveJSONToFile(daemonStatusPath(), status)
+			if err != nil {
+				slog.Warn("Could not write the status", "err", err)
+			}
+		}
+		planNextRun()
+		unauthorized := err != nil && newJSONError(err).Code == "unauthorized"
+
+		for {
+			// Authorizing again need not wait out the backoff
+			if unauthorized {
+				if fresh := reloadClient(client); fresh != nil {
+					slog.Info("Found a new access token; syncing again")
+					client = fresh
+					break
+				}
+			}
+			wait := time.Until(status.NextRun)
+			if wait <= 0 {
+				break
+			}
+			if unauthorized {
+				wait = min(wait, authCheckInterval)
+			}
+			select {
+			case <-time.After(wait):
+			case <-live.changed:
+				// The schedules may have changed
+				settings = live.Get()
+				sched, _ = settings.Daemon.schedule()
+				planNextRun()
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// dateStyle is how formatTime shows dates, as set by --dates: "relative",
+// "iso", "locale", or empty for the layout of each output.
+var dateStyle string
+
+// setDateStyle sets the style of dates, checking it.
+func setDateStyle(style string) error {
+	switch style {
+	case "", "relative", "iso", "locale":
+		dateStyle = style
+		return nil
+	}
+	return fmt.Errorf("unknown date style %q; use \"relative\", \"iso\", or \"locale\"", style)
+}
+
+// formatTime formats t in the style of --dates, or with layout if none is
+// set. Whether layout shows the time of day decides whether the other styles
+// do.
+func formatTime(t time.Time, layout string) string {
+	withClock := strings.Contains(layout, "15") || strings.Contains(layout, "3:04")
+	switch dateStyle {
+	case "relative":
+		return relativeTime(t, time.Now())
+	case "iso":
+		if withClock {
+			return t.Format(time.RFC3339)
+		}
+		return t.Format("2006-01-02")
+	case "locale":
+		date, clock := localeLayouts(localeName())
+		if withClock {
+			return t.Format(date + " " + clock)
+		}
+		return t.Format(date)
+	}
+	return t.Format(layout)
+}
+
+// relativeTime describes t from now in the largest whole unit, as in "3
+// weeks ago" or "in 2 days".
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	day := 24 * time.Hour
+	var (
+		n    int
+		unit string
+	)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < day:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 7*day:
+		n, unit = int(d/day), "day"
+	case d < 30*day:
+		n, unit = int(d/(7*day)), "week"
+	case d < 365*day:
+		n, unit = int(d/(30*day)), "month"
+	default:
+		n, unit = int(d/(365*day)), "year"
+	}
+
+	s := fmt.Sprintf("%d %s", n, unit)
+	if n != 1 {
+		s += "s"
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+// localeName returns the locale of dates from the environment, as in
+// "en_US", without its encoding.
+func localeName() string {
+	for _, name := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			locale, _, _ = strings.Cut(locale, ".")
+			locale, _, _ = strings.Cut(locale, "@")
+			return locale
+		}
+	}
+	return ""
+}
+
+// dateLayouts are the numeric date layouts of languages, or of a language in
+// a territory, as in "en_US". Month names would need translating, so none are
+// used.
+var dateLayouts = map[string]string{
+	"en_US": "01/02/2006",
+	"en_CA": "2006-01-02",
+	"en":    "02/01/2006",
+	"fr_CA": "2006-01-02",
+	"fr":    "02/01/2006",
+	"es":    "02/01/2006",
+	"it":    "02/01/2006",
+	"pt":    "02/01/2006",
+	"el":    "02/01/2006",
+	"nl":    "02-01-2006",
+	"de":    "02.01.2006",
+	"da":    "02.01.2006",
+	"nb":    "02.01.2006",
+	"fi":    "02.01.2006",
+	"pl":    "02.01.2006",
+	"cs":    "02.01.2006",
+	"ru":    "02.01.2006",
+	"uk":    "02.01.2006",
+	"tr":    "02.01.2006",
+	"sv":    "2006-01-02",
+	"lt":    "2006-01-02",
+	"hu":    "2006.01.02.",
+	"ja":    "2006/01/02",
+	"zh":    "2006/01/02",
+	"ko":    "2006.01.02.",
+}
+
+// localeLayouts returns the layouts of the date and of the time of day in
+// locale, falling back to ISO 8601 dates and a 24-hour clock.
+func localeLayouts(locale string) (date, clock string) {
+	clock = "15:04"
+	if locale == "en_US" {
+		clock = "3:04 PM"
+	}
+	language, _, _ := strings.Cut(locale, "_")
+	for _, key := range []string{locale, language} {
+		if date, ok := dateLayouts[key]; ok {
+			return date, clock
+		}
+	}
+	return "2006-01-02", clock
+}
+
+// defaultTimeLayout is the layout of the times in the default item template.
+const defaultTimeLayout = "Mon, 02 Jan 2006 15:04:05 MST"
+
+// templateDate is the "date" function of item templates, formatting a time
+// in the style of --dates, or with the layout given, as in
+// {{date .TimeAdded "2006-01-02"}}.
+func templateDate(t api.Time, layout ...string) string {
+	if len(layout) > 0 {
+		return formatTime(t.Time, layout[0])
+	}
+	return formatTime(t.Time, defaultTimeLayout)
+}
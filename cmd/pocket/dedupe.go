@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// findDuplicatesInDetail finds the duplicates among the items retrieved
+// with options, returning the copy kept for each as library.FindDuplicates does,
+// and the items by ID. Simple items lack the tags deciding which copy is
+// kept, so if there are duplicates, the items are retrieved again in detail.
+// Items in the trash are neither kept nor deleted again.
+func findDuplicatesInDetail(client *api.Client, options *api.RetrieveOption, items []api.Item) (map[int]int, map[int]api.Item) {
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+	keptFor := library.FindDuplicates(untrashed(items))
+	if len(keptFor) == 0 || options.DetailType == api.DetailTypeComplete {
+		return keptFor, byID
+	}
+
+	detailed := *options
+	detailed.DetailType = api.DetailTypeComplete
+	found, err := retrieveItems(client, &detailed)
+	if err != nil {
+		panic(err)
+	}
+	for _, item := range found {
+		if _, ok := byID[item.ItemID]; ok {
+			byID[item.ItemID] = item
+		}
+	}
+	items = make([]api.Item, 0, len(byID))
+	for _, item := range byID {
+		items = append(items, item)
+	}
+	return library.FindDuplicates(untrashed(items)), byID
+}
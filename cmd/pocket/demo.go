@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// demoTopic is a subject of the made up items of "pocket demo": the tag
+// they are given, the sites they are from, and what they are about.
+type demoTopic struct {
+	tag      string
+	domains  []string
+	subjects []string
+}
+
+var demoTopics = []demoTopic{
+	{"go", []string{"go.dev", "dave.cheney.net", "eli.thegreenplace.net"}, []string{"goroutine leaks", "the Go scheduler", "error wrapping", "Go modules", "profiling Go services", "generics in Go"}},
+	{"rust", []string{"blog.rust-lang.org", "fasterthanli.me", "without.boats"}, []string{"the borrow checker", "async Rust", "unsafe code", "compile times", "Rust in the kernel"}},
+	{"databases", []string{"use-the-index-luke.com", "brandur.org", "sqlite.org"}, []string{"Postgres indexes", "SQLite in production", "query planners", "connection pooling", "database migrations"}},
+	{"design", []string{"alistapart.com", "smashingmagazine.com", "nngroup.com"}, []string{"typography on the web", "dark mode", "design systems", "accessible color palettes", "empty states"}},
+	{"cooking", []string{"seriouseats.com", "bonappetit.com", "smittenkitchen.com"}, []string{"sourdough starters", "cast iron pans", "weeknight curries", "fermentation", "knife skills"}},
+	{"travel", []string{"lonelyplanet.com", "theguardian.com", "nomadicmatt.com"}, []string{"night trains in Europe", "packing light", "Lisbon on a budget", "hiking the Alps", "travelling off season"}},
+	{"productivity", []string{"calnewport.com", "fs.blog", "zenhabits.net"}, []string{"deep work", "inbox zero", "weekly reviews", "note-taking systems", "saying no"}},
+	{"science", []string{"quantamagazine.org", "nautil.us", "newscientist.com"}, []string{"black holes", "CRISPR", "the gut microbiome", "fusion power", "the origin of life"}},
+	{"finance", []string{"ft.com", "morningstar.com", "mrmoneymustache.com"}, []string{"index funds", "compound interest", "early retirement", "inflation", "renting versus buying"}},
+	{"history", []string{"smithsonianmag.com", "historytoday.com", "aeon.co"}, []string{"the printing press", "the Silk Road", "the fall of Rome", "the space race", "medieval medicine"}},
+}
+
+// demoPatterns make the titles of the made up items out of their subjects.
+var demoPatterns = []string{
+	"A Practical Guide to %s",
+	"What I Got Wrong About %s",
+	"%s, Explained",
+	"Why %s Matters More Than You Think",
+	"The Hidden Costs of %s",
+	"Notes on %s",
+	"Getting Started With %s",
+	"Everything Wrong With %s",
+	"%s: A Deep Dive",
+	"How We Rethought %s",
+}
+
+// demoGerman are titles of items in German, for --lang to tell apart.
+var demoGerman = []string{
+	"Warum Rechenzentren so viel Wasser brauchen",
+	"Die Geschichte der Eisenbahn in zehn Karten",
+	"Wie man einen Sauerteig ansetzt",
+	"Was Open Source wirklich kostet",
+}
+
+// demoSlugJunk is what the slugs of URLs leave out of the titles.
+var demoSlugJunk = regexp.MustCompile(`[^a-z0-9]+`)
+
+// demoItems makes up n items, the same ones for the same seed and now: from
+// a dozen sites on ten subjects, added over the last three years, more of
+// them lately, of all lengths, some of them videos, some read or favorited,
+// and a few saved twice.
+func demoItems(n int, seed int64, now time.Time) []api.Item {
+	r := rand.New(rand.NewSource(seed))
+	items := make([]api.Item, 0, n)
+	for i := 0; i < n; i++ {
+		id := 1000 + i
+		// Most ages are of the last few months, fewer of years ago
+		age := time.Duration(math.Pow(r.Float64(), 2.5) * float64(3*365*24*time.Hour))
+		added := now.Add(-age).Truncate(time.Second)
+		item := api.Item{
+			ItemID:    id,
+			IsArticle: 1,
+			Lang:      "en",
+			TimeAdded: api.Time{Time: added},
+			SortId:    n - i,
+		}
+
+		var tags []string
+		switch {
+		case i > 10 && r.Intn(40) == 0:
+			// Saved again, from a link with tracking parameters
+			earlier := items[r.Intn(len(items))]
+			sep := "?"
+			if strings.Contains(earlier.GivenURL, "?") {
+				sep = "&"
+			}
+			item.GivenURL = earlier.GivenURL + sep + "utm_source=newsletter&utm_medium=email"
+			item.GivenTitle = earlier.GivenTitle
+			item.Excerpt = earlier.Excerpt
+			item.WordCount = earlier.WordCount
+			item.Lang = earlier.Lang
+		case r.Intn(50) == 0:
+			title := demoGerman[r.Intn(len(demoGerman))]
+			item.GivenURL = fmt.Sprintf("https://www.heise.de/hintergrund/%s-%d.html", demoSlug(title), id)
+			item.GivenTitle = title
+			item.Lang = "de"
+			item.WordCount = demoWordCount(r)
+		default:
+			topic := demoTopics[r.Intn(len(demoTopics))]
+			subject := topic.subjects[r.Intn(len(topic.subjects))]
+			title := demoTitle(demoPatterns[r.Intn(len(demoPatterns))], subject)
+			tags = append(tags, topic.tag)
+			if r.Intn(8) == 0 {
+				tags = append(tags, "to-share")
+			}
+			if r.Intn(12) == 0 {
+				// Talks on the subject
+				item.GivenURL = "https://www.youtube.com/watch?v=" + demoVideoID(r)
+				item.GivenTitle = title + " (Talk)"
+				item.IsArticle = 0
+				item.HasVideo = api.ItemMediaAttachmentIsMedia
+				item.Videos = map[string]map[string]interface{}{"1": {"item_id": strconv.Itoa(id), "video_id": "1", "length": strconv.Itoa(300 + r.Intn(3300))}}
+				break
+			}
+			domain := topic.domains[r.Intn(len(topic.domains))]
+			item.GivenURL = fmt.Sprintf("https://%s/%d/%02d/%s", domain, added.Year(), added.Month(), demoSlug(title))
+			item.GivenTitle = title
+			item.Excerpt = fmt.Sprintf("A look at %s: what works, what doesn't, and what we would do differently next time.", subject)
+			item.WordCount = demoWordCount(r)
+			if r.Intn(3) == 0 {
+				item.HasImage = api.ItemMediaAttachmentHasMedia
+			}
+		}
+		if item.WordCount > 0 {
+			item.TimeToRead = (item.WordCount + 219) / 220
+			item.ListenDurationEstimate = item.WordCount * 60 / 155
+		}
+
+		// The older ones more likely read
+		if r.Float64() < 0.15+0.5*float64(age)/float64(3*365*24*time.Hour) {
+			item.Status = api.ItemStatusArchived
+			item.TimeRead = api.Time{Time: added.Add(time.Duration(r.Int63n(int64(age) + 1)))}
+		}
+		if r.Intn(12) == 0 {
+			item.Favorite = 1
+			item.TimeFavorited = api.Time{Time: added.Add(time.Duration(r.Int63n(int64(age) + 1)))}
+		}
+		if len(tags) > 0 && r.Intn(4) != 0 {
+			item.Tags = map[string]map[string]interface{}{}
+			for _, tag := range tags {
+				item.Tags[tag] = map[string]interface{}{"item_id": strconv.Itoa(id), "tag": tag}
+			}
+		}
+		item.TimeUpdated = api.Time{Time: maxTime(added, item.TimeRead.Time, item.TimeFavorited.Time)}
+
+		items = append(items, item)
+	}
+	return items
+}
+
+// demoTitle makes a title of pattern and subject, capitalizing the subject
+// when it starts the title.
+func demoTitle(pattern, subject string) string {
+	if strings.HasPrefix(pattern, "%s") {
+		r, size := utf8.DecodeRuneInString(subject)
+		subject = string(unicode.ToUpper(r)) + subject[size:]
+	}
+	return fmt.Sprintf(pattern, subject)
+}
+
+// demoSlug returns title as the last part of the path of a URL.
+func demoSlug(title string) string {
+	return strings.Trim(demoSlugJunk.ReplaceAllString(strings.ToLower(title), "-"), "-")
+}
+
+// demoVideoID returns an ID looking like one of YouTube.
+func demoVideoID(r *rand.Rand) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	b := make([]byte, 11)
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// demoWordCount returns the length of an article, mostly of a few minutes
+// of reading, some of an hour.
+func demoWordCount(r *rand.Rand) int {
+	return int(math.Min(math.Exp(r.NormFloat64()*0.8+7.2), 20000))
+}
+
+// maxTime returns the latest of times.
+func maxTime(times ...time.Time) time.Time {
+	latest := times[0]
+	for _, t := range times[1:] {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// writeDemoItems writes items as "pocket export --format json" does, for
+// --simulate to take.
+func writeDemoItems(w io.Writer, items []api.Item) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
+
+func commandDemo(conf Config) {
+	if conf.Items <= 0 {
+		exitWithError(conf, &usageError{command: "demo", err: fmt.Errorf("--items must be a positive number of items")})
+	}
+
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	if conf.Now != "" {
+		var err error
+		now, err = time.Parse("2006-01-02", conf.Now)
+		if err != nil {
+			exitWithError(conf, &usageError{command: "demo", err: fmt.Errorf("--now must be a date such as 2006-01-02")})
+		}
+	}
+
+	w, err := createOutput(conf.Out)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	defer w.Close()
+
+	err = writeDemoItems(w, demoItems(conf.Items, int64(conf.Seed), now))
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	if conf.Out != "" && conf.Out != "-" {
+		fmt.Fprintf(os.Stderr, "Wrote %d made up items, added until %s; try them with \"pocket --simulate %s triage\"\n", conf.Items, now.Format("2006-01-02"), conf.Out)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/render"
+)
+
+// formatChange describes a change on one line, prefixed like a diff.
+func formatChange(c mirror.Change) string {
+	prefix := "~"
+	detail := string(c.Kind)
+	switch c.Kind {
+	case mirror.ChangeAdded:
+		prefix = "+"
+	case mirror.ChangeDeleted:
+		prefix = "-"
+	case mirror.ChangeTagsChanged:
+		tags := []string{}
+		for _, tag := range c.TagsAdded {
+			tags = append(tags, "+"+tag)
+		}
+		for _, tag := range c.TagsRemoved {
+			tags = append(tags, "-"+tag)
+		}
+		detail = "tags " + strings.Join(tags, " ")
+	}
+	return fmt.Sprintf("%s [%9d] %s (%s)", prefix, c.ItemID, c.Title, detail)
+}
+
+func commandDiff(conf Config, client *api.Client) {
+	m, err := openMirror()
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	changes, err := m.Diff(client)
+	if err != nil {
+		panic(err)
+	}
+
+	renderOutput(conf, "diff", &render.Result{
+		Value: changes,
+		Text: func(w io.Writer) error {
+			for _, c := range changes {
+				fmt.Fprintln(w, formatChange(c))
+			}
+			return nil
+		},
+	})
+}
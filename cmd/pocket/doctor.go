@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/auth"
+)
+
+// doctorCheck is the outcome of one check of commandDoctor.
+type doctorCheck struct {
+	Name string
+	// Problem is set by failed checks, and Result by the others.
+	Result  string
+	Problem string
+}
+
+// checkVersion fetches the project metadata afresh, to report the latest
+// release and the notices for this one.
+func checkVersion(settings *Settings) []doctorCheck {
+	if versionCheckDisabled(settings) {
+		return []doctorCheck{{Name: "version", Result: "pocket " + version + "; checking for notices is turned off"}}
+	}
+	metadata, err := fetchMetadata()
+	if err != nil {
+		return []doctorCheck{{Name: "version", Problem: fmt.Sprintf("pocket %s; could not check for notices: %v", version, err)}}
+	}
+
+	checks := []doctorCheck{}
+	if metadata.Latest != "" && compareVersions(version, metadata.Latest) < 0 {
+		checks = append(checks, doctorCheck{Name: "version", Problem: fmt.Sprintf("pocket %s; %s is out", version, metadata.Latest)})
+	} else {
+		checks = append(checks, doctorCheck{Name: "version", Result: "pocket " + version + ", the latest release"})
+	}
+	for _, notice := range metadata.noticesFor(version) {
+		checks = append(checks, doctorCheck{Name: "notice", Problem: notice})
+	}
+	return checks
+}
+
+func checkAuthorization() doctorCheck {
+	key, err := os.ReadFile(filepath.Join(configDir, "consumer_key"))
+	if err != nil || strings.TrimSpace(string(key)) == "" {
+		return doctorCheck{Name: "authorization", Problem: "no consumer key; the next command asks for one"}
+	}
+	authFile, _ := accountAuthFile(defaultAccount)
+	accessToken := &auth.Authorization{}
+	if err := loadJSONFromFile(authFile, accessToken); err != nil || accessToken.AccessToken == "" {
+		return doctorCheck{Name: "authorization", Problem: "not authorized; the next command opens the authorization page"}
+	}
+	return doctorCheck{Name: "authorization", Result: "authorized as " + accessToken.Username}
+}
+
+func checkLock() doctorCheck {
+	unlock, err := lockState()
+	if err != nil {
+		return doctorCheck{Name: "lock", Problem: err.Error()}
+	}
+	unlock()
+	return doctorCheck{Name: "lock", Result: "no other pocket process is running"}
+}
+
+func checkQueue() doctorCheck {
+	n, err := queueDepth()
+	if err != nil {
+		return doctorCheck{Name: "queue", Problem: err.Error()}
+	}
+	if n > 0 {
+		return doctorCheck{Name: "queue", Problem: fmt.Sprintf("%d actions are waiting to be sent by \"pocket sync\"", n)}
+	}
+	return doctorCheck{Name: "queue", Result: "no actions are waiting to be sent"}
+}
+
+func checkRateLimit() doctorCheck {
+	q, err := loadQuota()
+	if err != nil {
+		return doctorCheck{Name: "rate limit", Result: "not seen yet"}
+	}
+	left, reset := q.remaining(time.Now())
+	switch {
+	case left < 0:
+		return doctorCheck{Name: "rate limit", Result: "reset since last seen"}
+	case left == 0:
+		return doctorCheck{Name: "rate limit", Problem: "no API requests left until " + reset.Local().Format("15:04")}
+	case reset.IsZero():
+		return doctorCheck{Name: "rate limit", Result: fmt.Sprintf("%d API requests left", left)}
+	}
+	return doctorCheck{Name: "rate limit", Result: fmt.Sprintf("%d API requests left until %s", left, reset.Local().Format("15:04"))}
+}
+
+func checkEndpoints() doctorCheck {
+	gone := loadGoneEndpoints()
+	if len(gone) == 0 {
+		return doctorCheck{Name: "endpoints", Result: "Pocket serves every endpoint used"}
+	}
+	return doctorCheck{Name: "endpoints", Problem: fmt.Sprintf("Pocket no longer serves %s; reading the local mirror and queueing changes", gone.describe())}
+}
+
+// commandDoctor checks the installation of pocket, exiting with 1 if
+// anything needs attention.
+func commandDoctor(conf Config) {
+	config := doctorCheck{Name: "config", Result: "config.json and rules are valid"}
+	settings, err := loadSettings()
+	if err != nil {
+		config = doctorCheck{Name: "config", Problem: err.Error()}
+		settings = &Settings{}
+	}
+	checks := checkVersion(settings)
+	checks = append(checks, config, checkAuthorization(), checkLock(), checkQueue(), checkRateLimit(), checkEndpoints())
+
+	failed := false
+	for _, check := range checks {
+		if check.Problem != "" {
+			failed = true
+			fmt.Printf("[!!] %-13s %s\n", check.Name, check.Problem)
+		} else {
+			fmt.Printf("[ok] %-13s %s\n", check.Name, check.Result)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
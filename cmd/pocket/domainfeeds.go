@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/feeds"
+)
+
+// domainFeed is the feed found for a domain items are saved from.
+type domainFeed struct {
+	Domain string
+	// Home is the page the feed was found on.
+	Home string
+	feeds.Link
+}
+
+// discoverDomainFeed looks for a feed of a domain on its home page, and
+// then on the newest item saved from it, as blogs often only link their
+// feeds from their posts. RSS and Atom feeds are preferred to JSON Feeds,
+// which fewer readers take.
+func discoverDomainFeed(client *http.Client, domain string, newest api.Item) (*domainFeed, error) {
+	u, err := url.Parse(newest.URL())
+	if err != nil {
+		return nil, err
+	}
+	home := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+
+	var firstErr error
+	for _, page := range []string{home, newest.URL()} {
+		links, err := feeds.Discover(client, page)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if len(links) == 0 {
+			continue
+		}
+		best := links[0]
+		for _, link := range links {
+			if link.Type != "application/feed+json" {
+				best = link
+				break
+			}
+		}
+		return &domainFeed{Domain: domain, Home: home, Link: best}, nil
+	}
+	return nil, firstErr
+}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Created string        `xml:"head>dateCreated"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr"`
+}
+
+// writeOPML writes the feeds as an OPML 2.0 subscription list, which feed
+// readers import.
+func writeOPML(w io.Writer, found []domainFeed, now time.Time) error {
+	doc := opmlDocument{
+		Version: "2.0",
+		Title:   "Sites saved to Pocket",
+		Created: now.UTC().Format(time.RFC1123Z),
+		Body:    []opmlOutline{},
+	}
+	for _, f := range found {
+		title := f.Title
+		if title == "" {
+			title = f.Domain
+		}
+		doc.Body = append(doc.Body, opmlOutline{Type: "rss", Text: title, Title: title, XMLURL: f.URL, HTMLURL: f.Home})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeDomainFeeds looks for the feeds of the first limit domains of
+// summaries and writes those found as OPML, telling of the others on
+// stderr.
+func writeDomainFeeds(w io.Writer, summaries []domainSummary, items []api.Item, limit int) error {
+	newest := map[string]api.Item{}
+	for _, item := range items {
+		if n, ok := newest[item.Domain()]; !ok || item.TimeAdded.After(n.TimeAdded.Time) {
+			newest[item.Domain()] = item
+		}
+	}
+	if limit > 0 && len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	found := make([]*domainFeed, len(summaries))
+	errs := make([]error, len(summaries))
+	newBulk("Discovering feeds", linkChecking.concurrency()).Run(len(summaries), func(i int) error {
+		domain := summaries[i].Domain
+		found[i], errs[i] = discoverDomainFeed(client, domain, newest[domain])
+		return nil
+	})
+
+	feedList := []domainFeed{}
+	for i, f := range found {
+		switch {
+		case f != nil:
+			feedList = append(feedList, *f)
+		case errs[i] != nil:
+			fmt.Fprintf(os.Stderr, "Could not look for a feed of %s: %s\n", summaries[i].Domain, errs[i])
+		default:
+			fmt.Fprintf(os.Stderr, "No feed found for %s\n", summaries[i].Domain)
+		}
+	}
+	return writeOPML(w, feedList, time.Now())
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/render"
+)
+
+// domainSummary describes the items saved from a domain.
+type domainSummary struct {
+	Domain string `json:"domain"`
+	Items  int    `json:"items"`
+	Unread int    `json:"unread"`
+	// AverageAgeDays is how long ago the items were added, on average.
+	AverageAgeDays float64 `json:"average_age_days"`
+}
+
+// domainOrder returns the order of summaries given by --by: "items",
+// "unread", "age" (oldest first), or "domain".
+func domainOrder(by string) (func(a, b domainSummary) bool, error) {
+	switch by {
+	case "items":
+		return func(a, b domainSummary) bool { return a.Items > b.Items }, nil
+	case "unread":
+		return func(a, b domainSummary) bool { return a.Unread > b.Unread }, nil
+	case "age":
+		return func(a, b domainSummary) bool { return a.AverageAgeDays > b.AverageAgeDays }, nil
+	case "domain":
+		return func(a, b domainSummary) bool { return false }, nil
+	}
+	return nil, fmt.Errorf("unknown order %q; use \"items\", \"unread\", \"age\", or \"domain\"", by)
+}
+
+// summarizeDomains summarizes the items by domain, sorted by less and then
+// by domain name.
+func summarizeDomains(items []api.Item, less func(a, b domainSummary) bool, now time.Time) []domainSummary {
+	summaries := map[string]*domainSummary{}
+	ages := map[string]time.Duration{}
+	for _, item := range items {
+		domain := item.Domain()
+		if domain == "" {
+			continue
+		}
+		s, ok := summaries[domain]
+		if !ok {
+			s = &domainSummary{Domain: domain}
+			summaries[domain] = s
+		}
+		s.Items++
+		if item.Status == api.ItemStatusUnread {
+			s.Unread++
+		}
+		ages[domain] += now.Sub(item.TimeAdded.Time)
+	}
+
+	result := make([]domainSummary, 0, len(summaries))
+	for domain, s := range summaries {
+		days := (ages[domain] / time.Duration(s.Items)).Hours() / 24
+		s.AverageAgeDays = math.Round(days*10) / 10
+		result = append(result, *s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if less(result[i], result[j]) {
+			return true
+		}
+		if less(result[j], result[i]) {
+			return false
+		}
+		return result[i].Domain < result[j].Domain
+	})
+	return result
+}
+
+func commandDomains(conf Config, client *api.Client) {
+	less, err := domainOrder(conf.By)
+	if err != nil {
+		exitWithError(conf, &usageError{command: "domains", err: err})
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State: api.StateAll,
+		Tag:   conf.Tag,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	summaries := summarizeDomains(items, less, time.Now())
+
+	if conf.Output == "opml" {
+		if err := writeDomainFeeds(os.Stdout, summaries, items, conf.Limit); err != nil {
+			panic(err)
+		}
+		return
+	}
+	rows := [][]string{}
+	for _, s := range summaries {
+		rows = append(rows, []string{s.Domain, strconv.Itoa(s.Items), strconv.Itoa(s.Unread), strconv.FormatFloat(s.AverageAgeDays, 'f', -1, 64)})
+	}
+	renderOutput(conf, "domains", &render.Result{
+		Value:   summaries,
+		Columns: []string{"domain", "items", "unread", "average_age_days"},
+		Rows:    rows,
+		Text: func(w io.Writer) error {
+			fmt.Fprintf(w, "%6s  %6s  %8s  %s\n", "ITEMS", "UNREAD", "AVG AGE", "DOMAIN")
+			for _, s := range summaries {
+				fmt.Fprintf(w, "%6d  %6d  %7.0fd  %s\n", s.Items, s.Unread, s.AverageAgeDays, s.Domain)
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/search"
+)
+
+// driftBatch is the most articles fetched again by each "pocket sync
+// --drift", those checked longest ago first, so that a large library is
+// gone through over several syncs.
+const driftBatch = 50
+
+// driftMaxDistance is the most bits two fingerprints can differ by for the
+// article to count as the same; small edits change a few bits.
+const driftMaxDistance = 12
+
+// Kinds of drift of the content of an item.
+const (
+	driftRewritten  = "rewritten"
+	driftRemoved    = "removed"
+	driftRedirected = "redirected"
+)
+
+// driftPath is the file keeping the fingerprints of the article text of
+// items, taken by "pocket sync --drift".
+func driftPath() string {
+	return filepath.Join(configDir, "drift.json")
+}
+
+// articlePrint is the fingerprint of the article text of an item when it
+// was fetched.
+type articlePrint struct {
+	SimHash     uint64    `json:"simhash"`
+	Words       int       `json:"words"`
+	ResolvedURL string    `json:"resolved_url"`
+	At          time.Time `json:"at"`
+}
+
+// fingerprint takes the fingerprint of article: a SimHash of its runs of
+// three words, which differs by a few bits for articles that differ by a
+// few words.
+func fingerprint(article *api.Article, at time.Time) articlePrint {
+	words := search.Tokenize(articleText(article.HTML))
+	shingles := []string{}
+	for i := 0; i+3 <= len(words); i++ {
+		shingles = append(shingles, strings.Join(words[i:i+3], " "))
+	}
+	if len(shingles) == 0 {
+		shingles = words
+	}
+
+	var weights [64]int
+	for _, s := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var simhash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			simhash |= 1 << bit
+		}
+	}
+	return articlePrint{SimHash: simhash, Words: len(words), ResolvedURL: article.ResolvedURL, At: at}
+}
+
+// driftRecord is the article of an item as first fingerprinted, standing
+// for it as saved, and as last.
+type driftRecord struct {
+	First articlePrint `json:"first"`
+	Last  articlePrint `json:"last"`
+}
+
+// distance is the number of bits the fingerprints differ by.
+func (r driftRecord) distance() int {
+	return bits.OnesCount64(r.First.SimHash ^ r.Last.SimHash)
+}
+
+// drift tells how the article changed since it was first fingerprinted, if
+// it did substantially: moved to another site, lost most of its text, or
+// rewritten. It is empty otherwise.
+func (r driftRecord) drift() string {
+	switch {
+	case driftHost(r.First.ResolvedURL) != driftHost(r.Last.ResolvedURL):
+		return driftRedirected
+	case r.Last.Words < r.First.Words/5:
+		return driftRemoved
+	case r.distance() > driftMaxDistance:
+		return driftRewritten
+	}
+	return ""
+}
+
+// describe tells how the article changed, for the drift report.
+func (r driftRecord) describe() string {
+	switch r.drift() {
+	case driftRedirected:
+		return fmt.Sprintf("redirected from %s to %s", driftHost(r.First.ResolvedURL), r.Last.ResolvedURL)
+	case driftRemoved:
+		return fmt.Sprintf("removed: %d words, down from %d", r.Last.Words, r.First.Words)
+	}
+	return fmt.Sprintf("rewritten: %d of 64 bits differ, %d words, from %d", r.distance(), r.Last.Words, r.First.Words)
+}
+
+// driftHost is the host of url, without "www.".
+func driftHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// loadDrift returns the fingerprints taken so far by item ID.
+func loadDrift() (map[int]driftRecord, error) {
+	records := map[int]driftRecord{}
+	err := loadJSONFromFile(driftPath(), &records)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return records, nil
+}
+
+// driftResult summarizes a checkDrift call.
+type driftResult struct {
+	Checked int
+	Failed  int
+	// Drifted is the number of items found changed for the first time.
+	Drifted int
+}
+
+// checkDrift fetches again the article text of the items in the mirror
+// fingerprinted longest ago, or never, up to driftBatch of them, and
+// records their fingerprints. The fingerprints of items no longer in the
+// mirror are dropped.
+func checkDrift(m *mirror.Mirror, client *api.Client) (*driftResult, error) {
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	records, err := loadDrift()
+	if err != nil {
+		return nil, err
+	}
+
+	mirrored := map[int]bool{}
+	for _, item := range items {
+		mirrored[item.ItemID] = true
+	}
+	for id := range records {
+		if !mirrored[id] {
+			delete(records, id)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return records[items[i].ItemID].Last.At.Before(records[items[j].ItemID].Last.At)
+	})
+	result := &driftResult{}
+	for _, item := range items[:min(len(items), driftBatch)] {
+		if interruptRequested() {
+			break
+		}
+		article, err := client.Article(item.URL())
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		result.Checked++
+
+		fp := fingerprint(article, time.Now())
+		record, ok := records[item.ItemID]
+		if !ok {
+			record.First = fp
+		}
+		drifted := ok && record.drift() != ""
+		record.Last = fp
+		if !drifted && record.drift() != "" {
+			result.Drifted++
+		}
+		records[item.ItemID] = record
+	}
+
+	return result, saveJSONToFile(driftPath(), records)
+}
+
+func commandDrift(conf Config) {
+	checkOutput(conf, "drift")
+	records, err := loadDrift()
+	if err != nil {
+		panic(err)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stderr, `No articles fingerprinted yet; run "pocket sync --drift"`)
+		return
+	}
+
+	m, err := openMirror()
+	if err != nil {
+		panic(err)
+	}
+	items, err := m.Items()
+	m.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	drifted := []api.Item{}
+	for _, item := range items {
+		if records[item.ItemID].drift() != "" {
+			drifted = append(drifted, item)
+		}
+	}
+	sort.Slice(drifted, func(i, j int) bool {
+		return records[drifted[i].ItemID].Last.At.After(records[drifted[j].ItemID].Last.At)
+	})
+
+	result := itemsResult(drifted)
+	result.Text = func(w io.Writer) error {
+		for _, item := range drifted {
+			r := records[item.ItemID]
+			fmt.Fprintf(w, "[%d] %s\n    %s, since %s\n", item.ItemID, item.Title(), r.describe(), r.First.At.Format("2006-01-02"))
+		}
+		fmt.Fprintf(w, "%d of %d articles fingerprinted changed since they were first\n", len(drifted), len(records))
+		return nil
+	}
+	renderOutput(conf, "drift", result)
+}
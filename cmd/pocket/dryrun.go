@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// The outputs of --dry-run writing the actions a command would take, both
+// of them for "pocket apply" to take as they are
+const (
+	// dryRunJSONL is the actions one per line, as --log-actions writes them.
+	dryRunJSONL = "jsonl"
+	// dryRunDiff is the same, the actions on each item following the
+	// changes to it as a unified diff in comments, for review.
+	dryRunDiff = "diff"
+)
+
+// checkDryRunOutput exits unless output is text or, with --dry-run, one of
+// those writing the actions.
+func checkDryRunOutput(conf Config, command string) {
+	switch {
+	case conf.Output == "text":
+	case conf.Output != dryRunJSONL && conf.Output != dryRunDiff:
+		exitWithError(conf, &usageError{command: command, err: unknownOutput(conf.Output, []string{"text", dryRunJSONL, dryRunDiff})})
+	case !conf.DryRun:
+		exitWithError(conf, &usageError{command: command, err: fmt.Errorf("--output %s is for --dry-run", conf.Output)})
+	}
+}
+
+// writeDryRun writes actions in the format of output, the diffs showing
+// the changes to items, by ID, that the actions are on.
+func writeDryRun(w io.Writer, output string, actions []*api.Action, items []api.Item) error {
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+
+	for start := 0; start < len(actions); {
+		// The actions on one item in a row, as rules take them
+		end := start + 1
+		for end < len(actions) && actions[end].ItemID == actions[start].ItemID {
+			end++
+		}
+		if output == dryRunDiff {
+			if item, ok := byID[actions[start].ItemID]; ok {
+				if _, err := io.WriteString(w, itemDiff(item, actions[start:end])); err != nil {
+					return err
+				}
+			}
+		}
+		for _, action := range actions[start:end] {
+			b, err := json.Marshal(action)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+				return err
+			}
+		}
+		start = end
+	}
+	return nil
+}
+
+// itemDiff returns the changes actions make to item as a unified diff of
+// its state, favorite, and tags, each line commented out.
+func itemDiff(item api.Item, actions []*api.Action) string {
+	before := itemDiffLines(item, false)
+
+	after := item
+	after.Tags = map[string]map[string]interface{}{}
+	for name, tag := range item.Tags {
+		after.Tags[name] = tag
+	}
+	deleted := false
+	for _, action := range actions {
+		deleted = !mirror.ApplyAction(&after, action) || deleted
+	}
+	afterLines := itemDiffLines(after, deleted)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# --- %d %s\n# +++ %d %s\n# @@ %s @@\n", item.ItemID, item.Title(), item.ItemID, item.Title(), item.URL())
+	for i := range before {
+		if before[i] == afterLines[i] {
+			fmt.Fprintf(&b, "#  %s\n", before[i])
+			continue
+		}
+		fmt.Fprintf(&b, "# -%s\n# +%s\n", before[i], afterLines[i])
+	}
+	return b.String()
+}
+
+// itemDiffLines returns the lines of the state of an item compared by
+// itemDiff.
+func itemDiffLines(item api.Item, deleted bool) []string {
+	state := "unread"
+	switch {
+	case deleted:
+		state = "deleted"
+	case item.Status == api.ItemStatusArchived:
+		state = "archived"
+	}
+	favorite := "no"
+	if item.Favorite == 1 {
+		favorite = "yes"
+	}
+	return []string{
+		"state: " + state,
+		"favorite: " + favorite,
+		"tags: " + strings.Join(item.TagNames(), ", "),
+	}
+}
@@ -0,0 +1,1752 @@
+package main_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/pockettest"
+	. "github.com/onsi/gomega"
+)
+
+// These tests run the pocket binary, built once by TestMain, against the
+// fake API of pockettest, each with a config directory of its own.
+
+var pocketBinary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "pocket-e2e")
+	if err != nil {
+		panic(err)
+	}
+	pocketBinary = filepath.Join(dir, "pocket")
+	if runtime.GOOS == "windows" {
+		pocketBinary += ".exe"
+	}
+	if out, err := exec.Command("go", "build", "-o", pocketBinary, ".").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not build pocket: %v\n%s", err, out)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// e2e is a config directory and the fake API the pocket run in it talks to.
+type e2e struct {
+	server    *pockettest.Server
+	configDir string
+	// env is added to the environment of the commands run
+	env []string
+}
+
+func newE2E(t *testing.T) *e2e {
+	server := pockettest.NewServer()
+	t.Cleanup(server.Close)
+	return &e2e{server: server, configDir: t.TempDir()}
+}
+
+// authorize saves the consumer key and access token the server accepts, as
+// if pocket had been authorized before.
+func (e *e2e) authorize() {
+	Expect(os.WriteFile(filepath.Join(e.configDir, "consumer_key"), []byte(e.server.ConsumerKey+"\n"), 0600)).To(Succeed())
+	auth, err := json.Marshal(map[string]string{"access_token": e.server.AccessToken, "username": "pockettest"})
+	Expect(err).To(BeNil())
+	Expect(os.WriteFile(filepath.Join(e.configDir, "auth.json"), auth, 0600)).To(Succeed())
+}
+
+func (e *e2e) command(stdin string, args ...string) *exec.Cmd {
+	cmd := exec.Command(pocketBinary, args...)
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + e.configDir,
+		"LANG=C",
+		"NO_COLOR=1",
+		"POCKET_NO_VERSION_CHECK=1",
+		"POCKET_CONFIG_DIR=" + e.configDir,
+		"POCKET_API_ORIGIN=" + e.server.URL,
+	}
+	// Windows needs these to reach the network and run anything at all
+	for _, name := range []string{"SYSTEMROOT", "WINDIR", "TEMP"} {
+		if value := os.Getenv(name); value != "" {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	cmd.Env = append(cmd.Env, e.env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	return cmd
+}
+
+// run runs pocket with args, answering its prompts from stdin, and returns
+// what it wrote and whether it succeeded.
+func (e *e2e) run(stdin string, args ...string) (stdout, stderr string, err error) {
+	var out, errOut bytes.Buffer
+	cmd := e.command(stdin, args...)
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	return out.String(), errOut.String(), err
+}
+
+// mustRun runs pocket with args, failing unless it succeeds.
+func (e *e2e) mustRun(args ...string) string {
+	stdout, stderr, err := e.run("", args...)
+	ExpectWithOffset(1, err).To(BeNil(), "pocket %s\n%s", strings.Join(args, " "), stderr)
+	return stdout
+}
+
+// ids returns the item IDs listed by "pocket list --output=ids" with args.
+func (e *e2e) ids(args ...string) []string {
+	return strings.Fields(e.mustRun(append([]string{"list", "--output=ids"}, args...)...))
+}
+
+func tags(names ...string) map[string]map[string]interface{} {
+	tags := map[string]map[string]interface{}{}
+	for _, name := range names {
+		tags[name] = nil
+	}
+	return tags
+}
+
+func TestE2EAuthorize(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	id := e.server.Add(api.Item{GivenURL: "https://go.dev/blog/intro", GivenTitle: "Go"})
+
+	// The consumer key is asked for, then the authorization URL printed
+	// and visited, as a browser would
+	cmd := e.command(e.server.ConsumerKey+"\n", "list", "--output=ids")
+	stdout, err := cmd.StdoutPipe()
+	Expect(err).To(BeNil())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	Expect(cmd.Start()).To(Succeed())
+
+	lines := bufio.NewScanner(stdout)
+	Expect(lines.Scan()).To(BeTrue())
+	Expect(lines.Text()).To(HavePrefix(e.server.URL + "/auth/authorize?"))
+	resp, err := http.Get(lines.Text())
+	Expect(err).To(BeNil())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	rest := []string{}
+	for lines.Scan() {
+		rest = append(rest, lines.Text())
+	}
+	Expect(cmd.Wait()).To(Succeed(), stderr.String())
+	Expect(rest).To(Equal([]string{fmt.Sprint(id)}))
+	Expect(stderr.String()).To(ContainSubstring("Enter your consumer key"))
+
+	consumerKey, err := os.ReadFile(filepath.Join(e.configDir, "consumer_key"))
+	Expect(err).To(BeNil())
+	Expect(string(consumerKey)).To(Equal(e.server.ConsumerKey))
+	auth, err := os.ReadFile(filepath.Join(e.configDir, "auth.json"))
+	Expect(err).To(BeNil())
+	Expect(string(auth)).To(ContainSubstring(e.server.AccessToken))
+
+	// Later runs use the token saved
+	Expect(e.ids()).To(Equal([]string{fmt.Sprint(id)}))
+	Expect(e.server.Requests("/v3/oauth/authorize")).To(Equal(1))
+}
+
+func TestE2EListFilters(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	goBlog := e.server.Add(api.Item{GivenURL: "https://go.dev/blog/generics", GivenTitle: "Generics", Tags: tags("golang")})
+	goTour := e.server.Add(api.Item{GivenURL: "https://www.go.dev/tour", GivenTitle: "A Tour of Go"})
+	news := e.server.Add(api.Item{GivenURL: "https://news.example.org/weekly", GivenTitle: "Weekly News", Tags: tags("news")})
+	read := e.server.Add(api.Item{GivenURL: "https://example.com/read", GivenTitle: "Read Already", Status: api.ItemStatusArchived})
+
+	id := func(ids ...int) []string {
+		s := []string{}
+		for _, id := range ids {
+			s = append(s, fmt.Sprint(id))
+		}
+		return s
+	}
+
+	// Newest first
+	Expect(e.ids()).To(Equal(id(news, goTour, goBlog)))
+	Expect(e.ids("--domain=go.dev")).To(Equal(id(goTour, goBlog)))
+	Expect(e.ids("--tag=golang")).To(Equal(id(goBlog)))
+	Expect(e.ids("--search=weekly")).To(Equal(id(news)))
+	Expect(e.ids("--state=archive")).To(Equal(id(read)))
+	Expect(e.ids("--state=all", "--sort=oldest")).To(Equal(id(goBlog, goTour, news, read)))
+
+	out := e.mustRun("list", "--plain", "--tag=news")
+	Expect(out).To(ContainSubstring("Weekly News"))
+	Expect(out).To(ContainSubstring("<https://news.example.org/weekly>"))
+	Expect(out).NotTo(ContainSubstring("Generics"))
+}
+
+func TestE2EOutputFormats(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://go.dev/blog/generics", GivenTitle: "Generics | Go", Tags: tags("golang")})
+
+	out := e.mustRun("list", "--output=csv")
+	Expect(out).To(HavePrefix("id,title,url,tags,added,state\n"))
+	Expect(out).To(ContainSubstring(fmt.Sprintf("%d,Generics | Go,https://go.dev/blog/generics,golang,", id)))
+
+	out = e.mustRun("list", "--output=markdown")
+	Expect(out).To(ContainSubstring(`| Generics \| Go |`))
+
+	var items []api.Item
+	Expect(json.Unmarshal([]byte(e.mustRun("list", "--output=json")), &items)).To(Succeed())
+	Expect(items).To(HaveLen(1))
+	Expect(items[0].ItemID).To(Equal(id))
+
+	Expect(e.mustRun("tags", "--output=csv")).To(HavePrefix("tag,items,unread,last_added\ngolang,1,1,"))
+	Expect(e.mustRun("timeline", "--output=ids")).To(Equal(fmt.Sprintln(id)))
+	_, stderr, err := e.run("", "timeline", "--state=read")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring(`unknown state "read"; use "unread", "archive", or "all"`))
+
+	// Stats are no table
+	_, stderr, err = e.run("", "stats", "--output=csv")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring(`unknown output "csv"; use "json" or "text"`))
+	_, stderr, err = e.run("", "list", "--output=yaml")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring(`unknown output "yaml"; use "csv", "ids", "json", "markdown", or "text"`))
+}
+
+func TestE2EAdd(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.mustRun("add", "https://go.dev/doc/effective_go", "--title=Effective Go", "--tags=golang,docs")
+
+	items := e.server.Items()
+	Expect(items).To(HaveLen(1))
+	Expect(items[0].URL()).To(Equal("https://go.dev/doc/effective_go"))
+	Expect(items[0].Title()).To(Equal("Effective Go"))
+	Expect(items[0].TagNames()).To(Equal([]string{"docs", "golang"}))
+	Expect(e.ids("--tag=docs")).To(Equal([]string{fmt.Sprint(items[0].ItemID)}))
+}
+
+func TestE2EAddFromTabs(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://example.com/saved", GivenTitle: "Saved"})
+	e.mustRun("sync")
+
+	// The session Firefox keeps while it runs, compressed as LZ4 literals
+	session, err := json.Marshal(map[string]any{"windows": []any{map[string]any{"tabs": []any{
+		map[string]any{"index": 1, "entries": []any{map[string]any{"url": "https://example.com/saved", "title": "Saved"}}},
+		map[string]any{"index": 1, "entries": []any{map[string]any{"url": "https://go.dev/blog/intro", "title": "The Go Blog"}}},
+		map[string]any{"index": 1, "entries": []any{map[string]any{"url": "about:newtab", "title": "New Tab"}}},
+		map[string]any{"index": 1, "entries": []any{map[string]any{"url": "https://example.org/tabs", "title": "Tabs"}}},
+	}}}})
+	Expect(err).To(BeNil())
+	lz4 := append([]byte("mozLz40\x00"), binary.LittleEndian.AppendUint32(nil, uint32(len(session)))...)
+	lz4 = append(lz4, 0xf0)
+	for n := len(session) - 15; ; n -= 255 {
+		lz4 = append(lz4, byte(min(n, 255)))
+		if n < 255 {
+			break
+		}
+	}
+	lz4 = append(lz4, session...)
+	profile := filepath.Join(e.configDir, "firefox-profile")
+	Expect(os.MkdirAll(filepath.Join(profile, "sessionstore-backups"), 0700)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(profile, "sessionstore-backups", "recovery.jsonlz4"), lz4, 0600)).To(Succeed())
+
+	stdout, stderr, err := e.run("2\n", "add", "--screen-reader", "--from-tabs=firefox", "--profile="+profile, "--tags=tabs")
+	Expect(err).To(BeNil(), stderr)
+	Expect(stderr).To(ContainSubstring("Leaving out 1 tab(s) saved already"))
+	Expect(stdout).To(ContainSubstring("1. The Go Blog, go.dev\n2. Tabs, example.org\n"))
+	Expect(stdout).To(ContainSubstring("Saved 1 tab(s)"))
+	items := e.server.Items()
+	Expect(items).To(HaveLen(2))
+	Expect(items[1].URL()).To(Equal("https://example.org/tabs"))
+	Expect(items[1].Title()).To(Equal("Tabs"))
+	Expect(items[1].TagNames()).To(Equal([]string{"tabs"}))
+
+	e.mustRun("sync")
+	Expect(e.mustRun("add", "--from-tabs=firefox", "--profile="+profile, "--yes")).To(ContainSubstring("Saved 1 tab(s)"))
+	Expect(e.server.Items()[2].URL()).To(Equal("https://go.dev/blog/intro"))
+
+	_, stderr, err = e.run("", "add", "--from-tabs=firefox", "--profile="+e.configDir)
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("firefox: no session file found"))
+}
+
+func TestE2EDedupe(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "http://example.com/post", GivenTitle: "Post", Tags: tags("go")})
+	e.server.Add(api.Item{GivenURL: "https://example.com/post", GivenTitle: "Post again", Tags: tags("web")})
+	other := e.server.Add(api.Item{GivenURL: "https://example.com/other", GivenTitle: "Other"})
+	favorite := e.server.Add(api.Item{GivenURL: "https://example.com/post", GivenTitle: "Post once more", Favorite: 1})
+
+	// Without a terminal to confirm on, more than one duplicate is only
+	// deleted with --yes
+	stdout, stderr, err := e.run("", "list", "--plain")
+	Expect(err).To(BeNil(), stderr)
+	Expect(strings.Count(stdout, fmt.Sprintf("Duplicate of item %d", favorite))).To(Equal(2))
+	Expect(stderr).To(ContainSubstring("--yes"))
+	Expect(e.server.Items()).To(HaveLen(4))
+
+	// The favorite is kept, given the tags of the others
+	e.mustRun("list", "--plain", "--yes")
+	remaining := []int{}
+	for _, item := range e.server.Items() {
+		remaining = append(remaining, item.ItemID)
+	}
+	Expect(remaining).To(ConsistOf(other, favorite))
+	kept, _ := e.server.Item(favorite)
+	Expect(kept.TagNames()).To(Equal([]string{"go", "web"}))
+}
+
+func TestE2ECull(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	// Links to the fake API itself, which answers 404 to them, so that
+	// only deleting is asked
+	gone := e.server.Add(api.Item{GivenURL: e.server.URL + "/gone", GivenTitle: "Gone"})
+	kept := e.server.Add(api.Item{GivenURL: e.server.URL + "/kept", GivenTitle: "Kept"})
+
+	// Newest first: the answer for kept, then for gone
+	stdout, stderr, err := e.run("n\ny\n", "list", "--plain", "--cull")
+	Expect(err).To(BeNil(), stderr)
+	Expect(strings.Count(stdout, "Status was 404")).To(Equal(2))
+	Expect(strings.Count(stdout, "Delete?")).To(Equal(2))
+
+	_, found := e.server.Item(gone)
+	Expect(found).To(BeFalse())
+	_, found = e.server.Item(kept)
+	Expect(found).To(BeTrue())
+
+	// The link checked is not requested again while its check is fresh
+	checked := e.server.Requests("/kept")
+	stdout, stderr, err = e.run("n\n", "list", "--plain", "--cull")
+	Expect(err).To(BeNil(), stderr)
+	Expect(stdout).To(ContainSubstring("Status was 404"))
+	Expect(e.server.Requests("/kept")).To(Equal(checked))
+
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(`{"link_check": {"ttl": -1}}`), 0600)).To(Succeed())
+	_, stderr, err = e.run("n\n", "list", "--plain", "--cull")
+	Expect(err).To(BeNil(), stderr)
+	Expect(e.server.Requests("/kept")).To(BeNumerically(">", checked))
+}
+
+func TestE2ETrash(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(`{"trash": {"days": 30}, "link_check": {"ttl": -1}}`), 0600)).To(Succeed())
+	culled := e.server.Add(api.Item{GivenURL: e.server.URL + "/culled", GivenTitle: "Culled"})
+	old := e.server.Add(api.Item{GivenURL: "https://example.com/old", GivenTitle: "Old", Status: api.ItemStatusArchived, Tags: tags("trash:2020-01-01")})
+
+	// Culled items are archived and tagged instead of deleted
+	_, stderr, err := e.run("y\n", "list", "--plain", "--cull")
+	Expect(err).To(BeNil(), stderr)
+	item, found := e.server.Item(culled)
+	Expect(found).To(BeTrue())
+	Expect(item.Status).To(Equal(api.ItemStatusArchived))
+	Expect(item.TagNames()).To(Equal([]string{"trash:" + time.Now().Format("2006-01-02")}))
+
+	stdout := e.mustRun("trash")
+	Expect(stdout).To(ContainSubstring("(2020-01-01) Old"))
+	Expect(stdout).To(ContainSubstring("Culled"))
+
+	// Only the items in the trash for long enough are deleted
+	e.mustRun("trash", "empty", "--yes")
+	_, found = e.server.Item(old)
+	Expect(found).To(BeFalse())
+	_, found = e.server.Item(culled)
+	Expect(found).To(BeTrue())
+
+	Expect(e.mustRun("trash", "restore", fmt.Sprint(culled))).To(Equal("Restored 1 items to the unread list\n"))
+	item, _ = e.server.Item(culled)
+	Expect(item.Status).To(Equal(api.ItemStatusUnread))
+	Expect(item.TagNames()).To(BeEmpty())
+	Expect(e.mustRun("trash")).To(Equal("The trash is empty\n"))
+}
+
+func TestE2ECullDomainDelay(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	for _, path := range []string{"/a", "/b", "/c"} {
+		e.server.Add(api.Item{GivenURL: e.server.URL + path})
+	}
+	settings := `{"link_check": {"ttl": -1, "concurrency": 3, "domain_delay": 200, "serialize_domains": true}}`
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(settings), 0600)).To(Succeed())
+
+	// The three links are on one host, so checked in turn with the delay
+	// in between
+	started := time.Now()
+	_, stderr, err := e.run("n\nn\nn\n", "list", "--plain", "--cull")
+	Expect(err).To(BeNil(), stderr)
+	Expect(time.Since(started)).To(BeNumerically(">=", 400*time.Millisecond))
+}
+
+func TestE2ELinkCheckFixRedirects(t *testing.T) {
+	RegisterTestingT(t)
+
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/moved", http.StatusMovedPermanently))
+	mux.Handle("/moved", http.RedirectHandler("/new", http.StatusPermanentRedirect))
+	mux.Handle("/temporary", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	old := e.server.Add(api.Item{GivenURL: site.URL + "/old", GivenTitle: "Old", Tags: tags("go"), Favorite: 1,
+		TimeAdded: api.Time{Time: time.Unix(1600000000, 0)}})
+	temporary := e.server.Add(api.Item{GivenURL: site.URL + "/temporary", GivenTitle: "Temporary"})
+	e.server.Add(api.Item{GivenURL: site.URL + "/gone", GivenTitle: "Gone"})
+
+	stdout := e.mustRun("linkcheck")
+	Expect(stdout).To(ContainSubstring("404 Not Found"))
+	Expect(stdout).To(ContainSubstring(fmt.Sprintf("301 %s/old → 308 %s/moved → %s/new", site.URL, site.URL, site.URL)))
+	Expect(stdout).To(ContainSubstring("3 links checked: 1 broken, 0 paywalled, 2 redirected, 1 of them permanently"))
+	Expect(e.server.Items()).To(HaveLen(3))
+
+	// Only the permanent redirect is followed, keeping the tags and state
+	e.mustRun("linkcheck", "--fix-redirects", "--yes")
+	_, found := e.server.Item(old)
+	Expect(found).To(BeFalse())
+	_, found = e.server.Item(temporary)
+	Expect(found).To(BeTrue())
+	var moved api.Item
+	for _, item := range e.server.Items() {
+		if item.GivenURL == site.URL+"/new" {
+			moved = item
+		}
+	}
+	Expect(moved.ItemID).NotTo(BeZero())
+	Expect(moved.TagNames()).To(Equal([]string{"go"}))
+	Expect(moved.Favorite).To(Equal(1))
+	Expect(moved.TimeAdded.Unix()).To(Equal(int64(1600000000)))
+}
+
+func TestE2ELinkCheckPaywalls(t *testing.T) {
+	RegisterTestingT(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("/premium", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<script type="application/ld+json">{"isAccessibleForFree": false}</script>`)
+	})
+	mux.HandleFunc("/free", func(w http.ResponseWriter, r *http.Request) {})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	forbidden := e.server.Add(api.Item{GivenURL: site.URL + "/forbidden"})
+	premium := e.server.Add(api.Item{GivenURL: site.URL + "/premium"})
+	gone := e.server.Add(api.Item{GivenURL: site.URL + "/gone"})
+	e.server.Add(api.Item{GivenURL: site.URL + "/free"})
+
+	stdout := e.mustRun("linkcheck")
+	Expect(stdout).To(ContainSubstring("Paywalled or needs a login (403 Forbidden)"))
+	Expect(stdout).To(ContainSubstring("4 links checked: 1 broken, 2 paywalled, 0 redirected"))
+
+	Expect(e.mustRun("linkcheck", "--link-status", "paywalled", "--output", "ids")).To(
+		Equal(fmt.Sprintf("%d\n%d\n", premium, forbidden)))
+	Expect(e.mustRun("linkcheck", "--link-status", "broken", "--output", "ids")).To(Equal(fmt.Sprintf("%d\n", gone)))
+
+	_, stderr, err := e.run("", "linkcheck", "--link-status", "slow")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring(`unknown link status "slow"`))
+}
+
+func TestE2EExportRestore(t *testing.T) {
+	RegisterTestingT(t)
+
+	from := newE2E(t)
+	from.authorize()
+	from.server.Add(api.Item{GivenURL: "https://go.dev/blog/intro", GivenTitle: "Intro", Tags: tags("golang", "blog")})
+	from.server.Add(api.Item{GivenURL: "https://example.com/done", GivenTitle: "Done", Status: api.ItemStatusArchived,
+		TimeRead: api.Time{Time: time.Unix(1700000000, 0)}})
+	from.server.Add(api.Item{GivenURL: "https://example.com/fav", GivenTitle: "Favorite", Favorite: 1,
+		TimeFavorited: api.Time{Time: time.Unix(1700000100, 0)}})
+
+	backup := filepath.Join(t.TempDir(), "backup.json")
+	from.mustRun("export", "--format=json", "--out="+backup)
+
+	to := newE2E(t)
+	to.authorize()
+	// One item is there already, and only gets the tags it is missing
+	to.server.Add(api.Item{GivenURL: "https://go.dev/blog/intro", Tags: tags("golang")})
+	to.mustRun("restore", backup, "--summary=none")
+
+	byURL := map[string]api.Item{}
+	for _, item := range to.server.Items() {
+		byURL[item.URL()] = item
+	}
+	Expect(byURL).To(HaveLen(3))
+	Expect(byURL["https://go.dev/blog/intro"].TagNames()).To(Equal([]string{"blog", "golang"}))
+	Expect(byURL["https://example.com/done"].Status).To(Equal(api.ItemStatus(api.ItemStatusArchived)))
+	Expect(byURL["https://example.com/done"].TimeRead.Unix()).To(Equal(int64(1700000000)))
+	Expect(byURL["https://example.com/fav"].Favorite).To(Equal(1))
+	Expect(byURL["https://example.com/fav"].Title()).To(Equal("Favorite"))
+
+	// Restoring again changes nothing
+	sends := to.server.Requests("/v3/send")
+	to.mustRun("restore", backup, "--summary=none")
+	Expect(to.server.Requests("/v3/send")).To(Equal(sends))
+	Expect(to.server.Items()).To(HaveLen(3))
+	_, stderr, err := to.run("", "restore", backup, "--conflict", "replace")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring(`use "merge", "update", or "skip"`))
+
+	// The items added are tagged with the run, which rolls back to the
+	// item there before
+	runs := strings.Fields(to.mustRun("import"))
+	Expect(runs).To(HaveLen(3))
+	Expect(runs[0]).To(HaveSuffix("-restore"))
+	Expect(runs[1]).To(Equal("2"))
+	Expect(byURL["https://example.com/done"].Tags).To(HaveKey("import:" + runs[0]))
+	Expect(byURL["https://go.dev/blog/intro"].Tags).NotTo(HaveKey("import:" + runs[0]))
+	to.mustRun("import", "rollback", runs[0], "--yes", "--summary=none")
+	Expect(to.server.Items()).To(HaveLen(1))
+}
+
+func TestE2EOfflineQueue(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.env = []string{"POCKET_HOST=laptop"}
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/later", GivenTitle: "Later"})
+	e.mustRun("sync")
+
+	// While Pocket cannot be reached, the archive is queued and made in
+	// the mirror
+	e.server.SetDown(true)
+	_, stderr, err := e.run("", "archive", fmt.Sprint(id))
+	Expect(err).To(BeNil(), stderr)
+	Expect(stderr).To(ContainSubstring("1 queued until the next sync"))
+	Expect(e.ids("--cached")).To(BeEmpty())
+	queue, err := os.ReadFile(filepath.Join(e.configDir, "queue", "laptop.jsonl"))
+	Expect(err).To(BeNil())
+	Expect(string(queue)).To(ContainSubstring(`"archive"`))
+
+	// The next sync sends it
+	e.server.SetDown(false)
+	item, _ := e.server.Item(id)
+	Expect(item.Status).To(Equal(api.ItemStatus(api.ItemStatusUnread)))
+	e.mustRun("sync")
+	item, _ = e.server.Item(id)
+	Expect(item.Status).To(Equal(api.ItemStatus(api.ItemStatusArchived)))
+	queue, err = os.ReadFile(filepath.Join(e.configDir, "queue", "laptop.jsonl"))
+	Expect(err == nil && len(bytes.TrimSpace(queue)) == 0 || os.IsNotExist(err)).To(BeTrue())
+	Expect(e.ids("--cached", "--state=archive")).To(Equal([]string{fmt.Sprint(id)}))
+}
+
+func TestE2EHighlight(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/post", GivenTitle: "Post"})
+
+	Expect(e.mustRun("highlight", fmt.Sprint(id), "A", "passage")).To(Equal(fmt.Sprintf("Highlighted item %d in Pocket\n", id)))
+	item, _ := e.server.Item(id)
+	Expect(item.Annotations).To(HaveLen(1))
+	Expect(item.Annotations[0].Quote).To(Equal("A passage"))
+
+	// Nothing is kept of items that are not there
+	_, stderr, err := e.run("", "highlight", "999999", "Missing")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("No item 999999"))
+
+	// While Pocket cannot be reached, highlights of the items in the local
+	// mirror are kept locally, and exported along with those in Pocket
+	e.mustRun("sync")
+	e.server.SetDown(true)
+	Expect(e.mustRun("highlight", fmt.Sprint(id), "Another")).To(Equal(fmt.Sprintf("Highlighted item %d locally; Pocket is unreachable\n", id)))
+	e.server.SetDown(false)
+	var articles []struct {
+		Highlights []api.Annotation `json:"highlights"`
+	}
+	Expect(json.Unmarshal([]byte(e.mustRun("highlights", "--format=json")), &articles)).To(Succeed())
+	Expect(articles).To(HaveLen(1))
+	quotes := []string{}
+	for _, h := range articles[0].Highlights {
+		quotes = append(quotes, h.Quote)
+	}
+	Expect(quotes).To(Equal([]string{"A passage", "Another"}))
+}
+
+func TestE2EGoneEndpoints(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.env = []string{"POCKET_HOST=laptop"}
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/kept", GivenTitle: "Kept"})
+	e.mustRun("sync")
+
+	// Once Pocket retires its list and its changes, the list is read from
+	// the mirror, and changes are queued
+	e.server.SetGone("/v3/get", true)
+	e.server.SetGone("/v3/send", true)
+	stdout, stderr, err := e.run("", "list")
+	Expect(err).To(BeNil(), stderr)
+	Expect(stdout).To(ContainSubstring("https://example.com/kept"))
+	Expect(stderr).To(ContainSubstring("Pocket no longer serves /v3/get (410 Gone); showing the local mirror as of"))
+	_, stderr, err = e.run("", "archive", fmt.Sprint(id))
+	Expect(err).To(BeNil(), stderr)
+	Expect(stderr).To(ContainSubstring("1 queued until the next sync"))
+
+	// Later commands remember it, without asking Pocket again
+	requests := e.server.Requests("/v3/get")
+	_, stderr, err = e.run("", "list")
+	Expect(err).To(BeNil(), stderr)
+	Expect(stderr).To(ContainSubstring("Pocket no longer serves /v3/get (since"))
+	Expect(e.server.Requests("/v3/get")).To(Equal(requests))
+	stdout, _, err = e.run("", "doctor")
+	Expect(err).NotTo(BeNil())
+	Expect(stdout).To(ContainSubstring("[!!] endpoints     Pocket no longer serves /v3/get and /v3/send"))
+}
+
+func TestE2EReadingQueue(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	first := e.server.Add(api.Item{GivenURL: "https://example.com/first", GivenTitle: "First"})
+	second := e.server.Add(api.Item{GivenURL: "https://example.com/second", GivenTitle: "Second"})
+	third := e.server.Add(api.Item{GivenURL: "https://example.com/third", GivenTitle: "Third"})
+	queued := func() []string { return strings.Fields(e.mustRun("queue", "show", "--output=ids")) }
+
+	Expect(e.mustRun("queue", "show")).To(HavePrefix("The reading queue is empty"))
+	Expect(e.mustRun("queue", "push", fmt.Sprint(third), fmt.Sprint(first), fmt.Sprint(second))).To(Equal("Queued 3 items, 3 in all\n"))
+	Expect(e.mustRun("queue", "push", fmt.Sprint(first))).To(Equal("Queued 0 items, 3 in all\n"))
+	Expect(e.mustRun("queue", "move", fmt.Sprint(second), "1")).To(Equal(fmt.Sprintf("Moved item %d to position 1 of the reading queue\n", second)))
+	Expect(queued()).To(Equal([]string{fmt.Sprint(second), fmt.Sprint(third), fmt.Sprint(first)}))
+	Expect(e.mustRun("queue", "show")).To(ContainSubstring("  1. ["))
+
+	// Archived items leave the queue
+	e.mustRun("archive", fmt.Sprint(second))
+	Expect(e.mustRun("queue", "pop", "--output", "ids")).To(Equal(fmt.Sprintln(third)))
+	Expect(queued()).To(Equal([]string{fmt.Sprint(first)}))
+
+	// open --next takes the next one off once opened
+	e.env = []string{"BROWSER=true"}
+	e.mustRun("open", "--next")
+	Expect(queued()).To(BeEmpty())
+	Expect(e.mustRun("open", "--next")).To(Equal("No items to open; the reading queue is empty\n"))
+	_, stderr, err := e.run("", "queue", "pop")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(Equal("The reading queue is empty\n"))
+}
+
+func TestE2ESharedQueue(t *testing.T) {
+	RegisterTestingT(t)
+
+	// One config directory, as synced between a laptop and a machine that
+	// is not authorized, which queues what it adds for the laptop to send
+	e := newE2E(t)
+	e.env = []string{"POCKET_HOST=work-desktop"}
+	Expect(e.mustRun("add", "--queue", "https://example.com/from-work", "--tags=work")).To(ContainSubstring("Queued https://example.com/from-work"))
+	// Queued by an older version
+	Expect(os.WriteFile(filepath.Join(e.configDir, "queue.jsonl"), []byte(`{"action":"add","url":"https://example.com/legacy"}`+"\n"), 0600)).To(Succeed())
+	Expect(e.server.Items()).To(BeEmpty())
+
+	e.authorize()
+	e.env = []string{"POCKET_HOST=laptop"}
+	e.mustRun("sync")
+	urls := func() []string {
+		urls := []string{}
+		for _, item := range e.server.Items() {
+			urls = append(urls, item.URL())
+		}
+		return urls
+	}
+	Expect(urls()).To(ConsistOf("https://example.com/from-work", "https://example.com/legacy"))
+	for _, item := range e.server.Items() {
+		if item.URL() == "https://example.com/from-work" {
+			Expect(item.TagNames()).To(Equal([]string{"work"}))
+		}
+	}
+	Expect(filepath.Join(e.configDir, "queue.jsonl")).NotTo(BeAnExistingFile())
+
+	// Each machine writes its own files only; what the laptop sent is
+	// dropped from the journal of the other as it queues more, and not
+	// sent again
+	journal := filepath.Join(e.configDir, "queue", "work-desktop.jsonl")
+	Expect(os.ReadFile(journal)).To(ContainSubstring("from-work"))
+	e.env = []string{"POCKET_HOST=work-desktop"}
+	e.mustRun("add", "--queue", "https://example.com/more")
+	Expect(os.ReadFile(journal)).NotTo(ContainSubstring("from-work"))
+	e.env = []string{"POCKET_HOST=laptop"}
+	e.mustRun("sync")
+	Expect(urls()).To(ConsistOf("https://example.com/from-work", "https://example.com/legacy", "https://example.com/more"))
+}
+
+func TestE2EEncryptedCache(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.env = []string{"POCKET_CACHE_KEY=" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))}
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/diary", GivenTitle: "A secret title"})
+	e.mustRun("sync")
+	mirrorDB := filepath.Join(e.configDir, "mirror.db")
+	db, err := os.ReadFile(mirrorDB)
+	Expect(err).To(BeNil())
+	Expect(string(db)).To(ContainSubstring("A secret title"))
+
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(`{"cache": {"encrypt": true}}`), 0600)).To(Succeed())
+	Expect(e.mustRun("cache", "encrypt")).To(Equal("Encrypted 1 items and 0 articles\n"))
+	db, err = os.ReadFile(mirrorDB)
+	Expect(err).To(BeNil())
+	Expect(string(db)).NotTo(ContainSubstring("A secret title"))
+	Expect(e.ids("--cached")).To(Equal([]string{fmt.Sprint(id)}))
+
+	// Another key cannot read it
+	e.env = []string{"POCKET_CACHE_KEY=" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))}
+	_, stderr, err := e.run("", "list", "--cached")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring("cannot be decrypted"))
+}
+
+func TestE2ECacheGC(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/kept", GivenTitle: "Kept"})
+	e.mustRun("sync")
+
+	articles := filepath.Join(e.configDir, "articles")
+	Expect(os.MkdirAll(articles, 0700)).To(Succeed())
+	for _, name := range []string{fmt.Sprint(id), "999999"} {
+		Expect(os.WriteFile(filepath.Join(articles, name+".json"), []byte(`{"title": "x"}`), 0600)).To(Succeed())
+	}
+
+	// The article of the item no longer mirrored goes
+	Expect(e.mustRun("cache", "gc")).To(HavePrefix("Removed 1 articles; compacted the mirror from "))
+	Expect(filepath.Join(articles, fmt.Sprint(id)+".json")).To(BeARegularFile())
+	Expect(filepath.Join(articles, "999999.json")).NotTo(BeAnExistingFile())
+	Expect(e.ids("--cached")).To(Equal([]string{fmt.Sprint(id)}))
+
+	// So does that of a deleted item, at the next sync
+	e.mustRun("delete", "--yes", fmt.Sprint(id))
+	e.mustRun("sync")
+	Expect(filepath.Join(articles, fmt.Sprint(id)+".json")).NotTo(BeAnExistingFile())
+}
+
+func TestE2ECacheWordCount(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://example.com/old", GivenTitle: "Saved before word counts"})
+	e.server.SetArticle("https://example.com/old", api.Article{HTML: "<p>" + strings.Repeat("word ", 300) + "</p>"})
+	e.mustRun("sync")
+	Expect(e.mustRun("stats", "--cached")).To(ContainSubstring("Avg word count: 0\n"))
+
+	Expect(e.mustRun("cache", "wordcount")).To(Equal("Filled in the word counts of 1 of 1 articles: 0 counted by Pocket, 1 from their text; 0 failed\n"))
+	Expect(e.mustRun("stats", "--cached")).To(ContainSubstring("Avg word count: 300\n"))
+	Expect(e.mustRun("cache", "status")).To(HavePrefix("1 articles, "))
+
+	// Nothing is left to fill in, nor asked of Pocket
+	requests := e.server.Requests("/v3/get")
+	Expect(e.mustRun("cache", "wordcount")).To(HavePrefix("Filled in the word counts of 0 of 0 articles"))
+	Expect(e.server.Requests("/v3/get")).To(Equal(requests))
+}
+
+func TestE2EDrift(t *testing.T) {
+	RegisterTestingT(t)
+
+	// A paragraph of n words from those numbered from
+	paragraph := func(from, n int) string {
+		words := make([]string, n)
+		for i := range words {
+			words[i] = fmt.Sprintf("word%d", from+i)
+		}
+		return "<p>" + strings.Join(words, " ") + "</p>"
+	}
+
+	e := newE2E(t)
+	e.authorize()
+	urls := map[string]string{}
+	ids := map[string]int{}
+	for _, name := range []string{"same", "edited", "rewritten", "removed", "moved"} {
+		urls[name] = "https://example.com/" + name
+		ids[name] = e.server.Add(api.Item{GivenURL: urls[name], GivenTitle: name})
+		e.server.SetArticle(urls[name], api.Article{HTML: paragraph(0, 300)})
+	}
+	Expect(e.mustRun("sync", "--drift")).To(ContainSubstring("Drift: 5 articles fingerprinted, 0 failed, 0 newly changed"))
+
+	e.server.SetArticle(urls["edited"], api.Article{HTML: paragraph(0, 295) + paragraph(1000, 5)})
+	e.server.SetArticle(urls["rewritten"], api.Article{HTML: paragraph(1000, 300)})
+	e.server.SetArticle(urls["removed"], api.Article{HTML: paragraph(0, 20)})
+	e.server.SetArticle(urls["moved"], api.Article{HTML: paragraph(0, 300), ResolvedURL: "https://elsewhere.example.org/moved"})
+	Expect(e.mustRun("sync", "--drift")).To(ContainSubstring("Drift: 5 articles fingerprinted, 0 failed, 3 newly changed"))
+
+	stdout := e.mustRun("drift")
+	Expect(stdout).To(ContainSubstring("rewritten: "))
+	Expect(stdout).To(ContainSubstring("removed: 20 words, down from 300"))
+	Expect(stdout).To(ContainSubstring("redirected from example.com to https://elsewhere.example.org/moved"))
+	Expect(stdout).To(ContainSubstring("3 of 5 articles fingerprinted changed since they were first"))
+	Expect(strings.Fields(e.mustRun("drift", "--output", "ids"))).To(ConsistOf(
+		fmt.Sprint(ids["rewritten"]), fmt.Sprint(ids["removed"]), fmt.Sprint(ids["moved"])))
+}
+
+func TestE2ESummarize(t *testing.T) {
+	RegisterTestingT(t)
+
+	prompts := []string{}
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string }
+		}
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		prompt := body.Messages[len(body.Messages)-1].Content
+		prompts = append(prompts, prompt)
+		title, _, _ := strings.Cut(prompt, "\n")
+		fmt.Fprintf(w, `{"choices": [{"message": {"content": "About %s."}}]}`, title)
+	}))
+	defer model.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	_, stderr, err := e.run("", "summarize", "--oldest", "2")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring(`set "summarize"`))
+
+	settings := fmt.Sprintf(`{"summarize": {"backend": "openai", "url": %q, "model": "small", "max_words": 3}}`, model.URL)
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(settings), 0600)).To(Succeed())
+	for i, title := range []string{"First", "Second", "Third"} {
+		url := "https://example.com/" + title
+		e.server.Add(api.Item{GivenURL: url, GivenTitle: title, TimeAdded: api.Time{Time: time.Unix(int64(1600000000+i), 0)}})
+		e.server.SetArticle(url, api.Article{HTML: "<p>one two three four</p>"})
+	}
+
+	stdout := e.mustRun("summarize", "--oldest", "2")
+	Expect(stdout).To(ContainSubstring("First\nAbout First."))
+	Expect(stdout).To(ContainSubstring("Second\nAbout Second."))
+	Expect(stdout).NotTo(ContainSubstring("Third"))
+	Expect(prompts).To(Equal([]string{"First\n\none two three", "Second\n\none two three"}))
+
+	// Kept summaries are shown without asking the model again
+	e.mustRun("summarize", "--oldest", "2")
+	Expect(prompts).To(HaveLen(2))
+	e.mustRun("summarize", "--refresh", "--oldest", "1")
+	Expect(prompts).To(HaveLen(3))
+}
+
+func TestE2EPlanCalendar(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.env = []string{"TZ=UTC"}
+	e.server.Add(api.Item{GivenURL: "https://example.com/short", GivenTitle: "Short, sweet; and to the point", TimeToRead: 10, TimeAdded: api.Time{Time: time.Unix(1600000000, 0)}})
+	e.server.Add(api.Item{GivenURL: "https://example.com/long", GivenTitle: "Long", TimeToRead: 20, TimeAdded: api.Time{Time: time.Unix(1600000001, 0)}})
+	e.server.Add(api.Item{GivenURL: "https://example.com/too-long", GivenTitle: "Too long", TimeToRead: 45})
+
+	stdout := e.mustRun("plan", "--minutes", "30", "--output", "ics", "--start", "2030-01-02 18:00")
+	Expect(stdout).To(HavePrefix("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	Expect(stdout).To(HaveSuffix("END:VCALENDAR\r\n"))
+	Expect(strings.Count(stdout, "BEGIN:VEVENT")).To(Equal(2))
+	Expect(stdout).To(ContainSubstring("DTSTART:20300102T180000Z\r\nDTEND:20300102T181000Z\r\nSUMMARY:Short\\, sweet\\; and to the point\r\n" +
+		"DESCRIPTION:10 min read\\nhttps://example.com/short\r\nURL:https://example.com/short\r\n"))
+	Expect(stdout).To(ContainSubstring("DTSTART:20300102T181000Z\r\nDTEND:20300102T183000Z\r\nSUMMARY:Long\r\n"))
+	Expect(stdout).NotTo(ContainSubstring("Too long"))
+
+	_, stderr, err := e.run("", "plan", "--output", "ics", "--start", "tonight")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring(`invalid start "tonight"`))
+
+	_, stderr, err = e.run("", "plan", "--minutes", "-5")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring("--minutes must be a positive number of minutes"))
+
+	// A budget past the items takes all of them that fit
+	Expect(strings.Fields(e.mustRun("plan", "--minutes", "1000000000", "--output", "ids"))).To(HaveLen(3))
+}
+
+func TestE2EJSONFeed(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://go.dev/blog/generics", GivenTitle: "Generics", Tags: tags("golang")})
+
+	var feed struct {
+		Version string
+		Items   []struct {
+			ID, URL, Title string
+			Tags           []string
+		}
+	}
+	Expect(json.Unmarshal([]byte(e.mustRun("feed", "--format", "json")), &feed)).To(Succeed())
+	Expect(feed.Version).To(Equal("https://jsonfeed.org/version/1.1"))
+	Expect(feed.Items).To(HaveLen(1))
+	Expect(feed.Items[0].ID).To(Equal(fmt.Sprintf("urn:pocket:item:%d", id)))
+	Expect(feed.Items[0].URL).To(Equal("https://go.dev/blog/generics"))
+	Expect(feed.Items[0].Tags).To(Equal([]string{"golang"}))
+
+	Expect(e.mustRun("feed")).To(HavePrefix("<?xml"))
+	_, stderr, err := e.run("", "feed", "--format", "rss")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring(`unknown feed format "rss"`))
+}
+
+func TestE2EDomainsOPML(t *testing.T) {
+	RegisterTestingT(t)
+
+	// The feed is only linked from the posts
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/posts/") {
+			fmt.Fprint(w, `<html><head><link rel="alternate" type="application/atom+xml" title="A blog" href="/feed.atom"></head></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>Home</title></head></html>`)
+	}))
+	defer site.Close()
+	other := strings.Replace(site.URL, "127.0.0.1", "localhost", 1)
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: site.URL + "/posts/1", GivenTitle: "One"})
+	e.server.Add(api.Item{GivenURL: site.URL + "/posts/2", GivenTitle: "Two"})
+	e.server.Add(api.Item{GivenURL: other + "/page", GivenTitle: "Page"})
+
+	stdout, stderr, err := e.run("", "domains", "--output", "opml")
+	Expect(err).To(BeNil())
+	Expect(stdout).To(ContainSubstring(fmt.Sprintf(`<outline type="rss" text="A blog" title="A blog" xmlUrl="%s/feed.atom" htmlUrl="%s/">`, site.URL, site.URL)))
+	Expect(strings.Count(stdout, "<outline")).To(Equal(1))
+	Expect(stderr).To(ContainSubstring("No feed found for localhost"))
+
+	// Only the domain most saved from
+	_, stderr, err = e.run("", "domains", "--output", "opml", "--limit", "1")
+	Expect(err).To(BeNil())
+	Expect(stderr).NotTo(ContainSubstring("localhost"))
+}
+
+func TestE2ELauncher(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://go.dev/blog/generics", GivenTitle: "Generics\nin Go", Tags: tags("golang")})
+	e.mustRun("sync")
+
+	var alfred struct {
+		Items []struct{ UID, Title, Subtitle, Arg string }
+	}
+	Expect(json.Unmarshal([]byte(e.mustRun("launcher", "--format", "alfred")), &alfred)).To(Succeed())
+	Expect(alfred.Items).To(HaveLen(1))
+	Expect(alfred.Items[0].Arg).To(Equal(fmt.Sprint(id)))
+	Expect(alfred.Items[0].Subtitle).To(Equal("go.dev #golang"))
+
+	var raycast struct {
+		Items []struct {
+			ID, Title string
+			Keywords  []string
+			Actions   []struct {
+				Type    string
+				Content string
+				Args    []string
+			}
+		}
+	}
+	Expect(json.Unmarshal([]byte(e.mustRun("launcher", "--format", "raycast")), &raycast)).To(Succeed())
+	Expect(raycast.Items).To(HaveLen(1))
+	Expect(raycast.Items[0].Keywords).To(Equal([]string{"go.dev", "golang"}))
+	Expect(raycast.Items[0].Actions[0].Args).To(Equal([]string{"open", "--cached", fmt.Sprint(id)}))
+	Expect(raycast.Items[0].Actions[1].Content).To(Equal("https://go.dev/blog/generics"))
+
+	Expect(e.mustRun("launcher")).To(Equal(fmt.Sprintf("%d\tGenerics in Go\tgo.dev #golang\n", id)))
+	rofi := e.mustRun("launcher", "--format", "rofi")
+	Expect(rofi).To(HavePrefix("\x00prompt\x1fpocket\n"))
+	Expect(rofi).To(ContainSubstring(fmt.Sprintf("Generics in Go\x00info\x1f%d\x1fmeta\x1fgo.dev #golang\n", id)))
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// Chosen in rofi, the item is opened
+	opened := filepath.Join(e.configDir, "opened.txt")
+	browser := filepath.Join(e.configDir, "browser")
+	Expect(os.WriteFile(browser, []byte("#!/bin/sh\necho \"$1\" > "+opened+"\n"), 0700)).To(Succeed())
+	e.env = []string{"BROWSER=" + browser, "ROFI_RETV=1", fmt.Sprintf("ROFI_INFO=%d", id)}
+	e.mustRun("launcher", "--format", "rofi")
+	Expect(os.ReadFile(opened)).To(Equal([]byte("https://go.dev/blog/generics\n")))
+}
+
+func TestE2EStatus(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	// Without a sync, summarized from the mirror
+	Expect(e.mustRun("status")).To(Equal("0 unread\n"))
+
+	e.server.Add(api.Item{GivenURL: "https://example.com/old", GivenTitle: "Old", TimeAdded: api.Time{Time: time.Now().Add(-3*24*time.Hour - time.Hour)}})
+	e.server.Add(api.Item{GivenURL: "https://example.com/new", GivenTitle: "New", Favorite: 1})
+	e.server.Add(api.Item{GivenURL: "https://example.com/read", GivenTitle: "Read", Status: api.ItemStatusArchived})
+	e.mustRun("sync")
+
+	Expect(e.mustRun("status", "--format", "{unread} unread, {oldest_age}")).To(Equal("2 unread, 3d\n"))
+	Expect(e.mustRun("status", "--format", "{archived}/{favorites} {synced_age}")).To(Equal("1/1 0m\n"))
+
+	_, stderr, err := e.run("", "status", "--format", "{unread} {bogus}")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring("unknown placeholder {bogus}"))
+}
+
+func TestE2ERoutes(t *testing.T) {
+	RegisterTestingT(t)
+
+	received := make(chan map[string]any, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer hook.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(fmt.Sprintf(`{"routes": [
+		{"tag": "recipe", "append": "recipes.md"},
+		{"tag": "work", "webhook": {"url": %q}}
+	]}`, hook.URL)), 0600)).To(Succeed())
+
+	// The first sync routes nothing
+	e.server.Add(api.Item{GivenURL: "https://example.com/old-soup", GivenTitle: "Old soup", Tags: tags("recipe")})
+	e.mustRun("sync")
+	Expect(filepath.Join(e.configDir, "recipes.md")).NotTo(BeAnExistingFile())
+
+	e.server.Add(api.Item{GivenURL: "https://example.com/bread", GivenTitle: "Bread [easy]", Tags: tags("recipe")})
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/memo", GivenTitle: "Memo"})
+	Expect(e.mustRun("sync")).To(ContainSubstring("Routed 1 items by their tags\n"))
+	Expect(os.ReadFile(filepath.Join(e.configDir, "recipes.md"))).To(Equal([]byte("- [Bread \\[easy\\]](https://example.com/bread)\n")))
+
+	// Tagged later
+	e.mustRun("tag", fmt.Sprint(id), "work")
+	Expect(e.mustRun("sync")).To(ContainSubstring("Routed 1 items by their tags\n"))
+	var payload map[string]any
+	Eventually(received).Should(Receive(&payload))
+	Expect(payload["event"]).To(Equal("routed"))
+	Expect(payload["data"]).To(HaveKeyWithValue("tag", "work"))
+	Expect(payload["data"]).To(HaveKeyWithValue("url", "https://example.com/memo"))
+	Expect(os.ReadFile(filepath.Join(e.configDir, "recipes.md"))).To(HaveLen(len("- [Bread \\[easy\\]](https://example.com/bread)\n")))
+}
+
+func TestE2EMailIn(t *testing.T) {
+	RegisterTestingT(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	addr := l.Addr().String()
+	l.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(fmt.Sprintf(`{"mail_in": {"listen": %q, "from": ["me@example.com"], "tags": ["mail"]}}`, addr)), 0600)).To(Succeed())
+	serve := e.command("", "serve", "--listen", "127.0.0.1:0")
+	Expect(serve.Start()).To(Succeed())
+	defer serve.Process.Kill()
+
+	send := func(from, body string) error {
+		return smtp.SendMail(addr, nil, from, []string{"pocket@localhost"}, []byte(body))
+	}
+	Eventually(func() error {
+		return send("me@example.com", "From: me@example.com\r\nSubject: Read later\r\n\r\nhttps://example.com/mailed\r\n")
+	}, "10s").Should(Succeed())
+	Expect(e.server.Items()).To(ConsistOf(
+		SatisfyAll(HaveField("GivenURL", "https://example.com/mailed"), HaveField("Tags", HaveKey("mail"))),
+	))
+
+	// Not from a sender allowed
+	Expect(send("someone@example.com", "From: someone@example.com\r\n\r\nhttps://example.com/spam\r\n")).To(Succeed())
+	Expect(e.server.Items()).To(HaveLen(1))
+}
+
+func TestE2EBot(t *testing.T) {
+	RegisterTestingT(t)
+
+	// A Slack channel, where a command is posted once the bot has looked
+	var mu sync.Mutex
+	polls := 0
+	replies := []string{}
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/conversations.history":
+			polls++
+			switch polls {
+			case 2:
+				fmt.Fprint(w, `{"ok": true, "messages": [
+					{"ts": "2.0", "user": "U2", "text": "!save <https://example.com/stranger>"},
+					{"ts": "1.0", "user": "U1", "text": "!save <https://example.com/shared> #family"}
+				]}`)
+			case 3:
+				fmt.Fprint(w, `{"ok": true, "messages": [{"ts": "3.0", "user": "U1", "text": "!list family"}]}`)
+			default:
+				fmt.Fprint(w, `{"ok": true, "messages": []}`)
+			}
+		case "/api/chat.postMessage":
+			var body struct{ Text string }
+			json.NewDecoder(r.Body).Decode(&body)
+			replies = append(replies, body.Text)
+			fmt.Fprint(w, `{"ok": true}`)
+		}
+	}))
+	defer slack.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(fmt.Sprintf(
+		`{"bot": {"service": "slack", "url": %q, "token": "xoxb-1", "channel": "C1", "users": ["U1"], "interval": 1}}`, slack.URL,
+	)), 0600)).To(Succeed())
+	daemon := e.command("", "daemon", "--interval", "1h")
+	Expect(daemon.Start()).To(Succeed())
+	defer daemon.Process.Kill()
+
+	Eventually(func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, replies...)
+	}, "20s").Should(Equal([]string{
+		"Saved https://example.com/shared",
+		"Newest unread items tagged family:\n• <https://example.com/shared>",
+	}))
+	Expect(e.server.Items()).To(HaveLen(1))
+}
+
+func TestE2ETelegram(t *testing.T) {
+	RegisterTestingT(t)
+
+	var mu sync.Mutex
+	polls := 0
+	replies := map[float64]string{}
+	telegram := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/bott0ken/getUpdates":
+			polls++
+			if polls > 1 {
+				time.Sleep(100 * time.Millisecond)
+				fmt.Fprint(w, `{"ok": true, "result": []}`)
+				return
+			}
+			fmt.Fprint(w, `{"ok": true, "result": [
+				{"update_id": 1, "message": {"message_id": 10, "from": {"id": 7, "username": "alice"}, "chat": {"id": 7}, "text": "https://go.dev/blog/generics #golang #later"}},
+				{"update_id": 2, "message": {"message_id": 11, "from": {"id": 8}, "chat": {"id": 8}, "text": "https://example.com/stranger"}},
+				{"update_id": 3, "message": {"message_id": 12, "from": {"id": 7, "username": "alice"}, "chat": {"id": 7}, "text": "hello"}}
+			]}`)
+		case "/bott0ken/sendMessage":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			replies[body["reply_to_message_id"].(float64)] = body["text"].(string)
+			fmt.Fprint(w, `{"ok": true, "result": {}}`)
+		}
+	}))
+	defer telegram.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://go.dev/blog/generics", GivenTitle: "An Introduction To Generics"})
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(fmt.Sprintf(
+		`{"telegram": {"url": %q, "token": "t0ken", "users": ["@alice"]}}`, telegram.URL,
+	)), 0600)).To(Succeed())
+	daemon := e.command("", "daemon", "--interval", "1h")
+	Expect(daemon.Start()).To(Succeed())
+	defer daemon.Process.Kill()
+
+	Eventually(func() map[float64]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(replies)
+	}, "20s").Should(Equal(map[float64]string{
+		10: "Saved: An Introduction To Generics\nTagged golang, later",
+		11: "Your Telegram user ID is 8; add it to telegram.users in config.json to save with this bot",
+		12: "Send me a URL to save it, with #hashtags to tag it",
+	}))
+	Expect(e.server.Items()).To(ConsistOf(HaveField("Tags", SatisfyAll(HaveKey("golang"), HaveKey("later")))))
+}
+
+func TestE2EDaemonSchedule(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	config := filepath.Join(e.configDir, "config.json")
+	Expect(os.WriteFile(config, []byte(`{"daemon": {"sync": "0 25 * * *"}}`), 0600)).To(Succeed())
+	_, stderr, err := e.run("", "daemon")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring(`daemon.sync: hour: "25" is not a value from 0 to 23`))
+	Expect(os.WriteFile(config, []byte(`{"daemon": {"time_zone": "Asia/Tokyo", "quiet_hours": "22:00"}}`), 0600)).To(Succeed())
+	_, stderr, err = e.run("", "daemon")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring("daemon.quiet_hours: "))
+
+	Expect(os.WriteFile(config, []byte(`{"daemon": {"time_zone": "Asia/Tokyo", "sync": "30 7 * * *"}}`), 0600)).To(Succeed())
+	daemon := e.command("", "daemon", "--interval", "1m")
+	Expect(daemon.Start()).To(Succeed())
+	defer daemon.Process.Kill()
+
+	var status struct {
+		LastSync time.Time `json:"last_sync"`
+		NextRun  time.Time `json:"next_run"`
+	}
+	Eventually(func() time.Time {
+		b, _ := os.ReadFile(filepath.Join(e.configDir, "daemon.json"))
+		json.Unmarshal(b, &status)
+		return status.LastSync
+	}, "20s").ShouldNot(BeZero())
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	Expect(err).To(BeNil())
+	next := status.NextRun.In(tokyo)
+	Expect([]int{next.Hour(), next.Minute()}).To(Equal([]int{7, 30}))
+	Expect(next.Sub(status.LastSync)).To(BeNumerically("<=", 24*time.Hour))
+}
+
+func TestE2EDaemonBackoff(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://example.com/", GivenTitle: "Example"})
+	auth := filepath.Join(e.configDir, "auth.json")
+	Expect(os.WriteFile(auth, []byte(`{"access_token": "revoked", "username": "pockettest"}`), 0600)).To(Succeed())
+	daemon := e.command("", "daemon", "--interval", "100ms")
+	Expect(daemon.Start()).To(Succeed())
+	defer daemon.Process.Kill()
+
+	var status struct {
+		LastSync        time.Time `json:"last_sync"`
+		LastError       string    `json:"last_error"`
+		Failures        int       `json:"failures"`
+		FailureNotified bool      `json:"failure_notified"`
+	}
+	readStatus := func() bool {
+		b, _ := os.ReadFile(filepath.Join(e.configDir, "daemon.json"))
+		status.FailureNotified = false
+		json.Unmarshal(b, &status)
+		return status.FailureNotified
+	}
+	Eventually(readStatus, "20s").Should(BeTrue())
+	Expect(status.Failures).To(BeNumerically(">=", 3))
+	Expect(status.LastError).To(ContainSubstring("Invalid access token"))
+	Expect(status.LastSync).To(BeZero())
+
+	// Authorized again, without restarting the daemon
+	e.authorize()
+	Eventually(func() int {
+		readStatus()
+		return status.Failures
+	}, "20s").Should(BeZero())
+	Expect(status.LastSync).NotTo(BeZero())
+	Expect(status.FailureNotified).To(BeFalse())
+}
+
+func TestE2EHealth(t *testing.T) {
+	RegisterTestingT(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	addr := l.Addr().String()
+	l.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://example.com/", GivenTitle: "Example"})
+	e.mustRun("sync")
+	e.mustRun("add", "--queue", "https://example.com/queued")
+	// An empty token file is no token, rather than one of ""
+	tokenPath := filepath.Join(e.configDir, "serve_token")
+	Expect(os.WriteFile(tokenPath, []byte("\n"), 0600)).To(Succeed())
+	serve := e.command("", "serve", "--listen", addr)
+	Expect(serve.Start()).To(Succeed())
+	defer serve.Process.Kill()
+
+	var resp *http.Response
+	Eventually(func() error {
+		resp, err = http.Get("http://" + addr + "/healthz")
+		return err
+	}, "10s").Should(Succeed())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	resp, err = http.Get("http://" + addr + "/status")
+	Expect(err).To(BeNil())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	token, err := os.ReadFile(tokenPath)
+	Expect(err).To(BeNil())
+	Expect(strings.TrimSpace(string(token))).To(HaveLen(32))
+
+	stdout := e.mustRun("status", "--daemon", "--listen", addr)
+	Expect(stdout).To(ContainSubstring("Daemon:     never run"))
+	Expect(stdout).To(ContainSubstring("Health:     ok"))
+	Expect(stdout).To(ContainSubstring("Queue:      1 actions"))
+	Expect(stdout).NotTo(ContainSubstring("Last sync:  never"))
+
+	// A daemon failing, as this process is running
+	status := fmt.Sprintf(`{"pid": %d, "running": true, "failures": 4, "last_error": "Invalid access token."}`, os.Getpid())
+	Expect(os.WriteFile(filepath.Join(e.configDir, "daemon.json"), []byte(status), 0600)).To(Succeed())
+	resp, err = http.Get("http://" + addr + "/healthz")
+	Expect(err).To(BeNil())
+	var health map[string]string
+	Expect(json.NewDecoder(resp.Body).Decode(&health)).To(Succeed())
+	resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	Expect(health).To(Equal(map[string]string{"status": "failing", "error": "Invalid access token."}))
+
+	stdout, _, err = e.run("", "status", "--daemon", "--listen", addr, "--output", "json")
+	Expect(err).NotTo(BeNil())
+	var report struct {
+		Status string `json:"status"`
+		Queue  int    `json:"queue"`
+		Daemon struct {
+			Failures int `json:"failures"`
+		} `json:"daemon"`
+	}
+	Expect(json.Unmarshal([]byte(stdout), &report)).To(Succeed())
+	Expect(report.Status).To(Equal("failing"))
+	Expect(report.Queue).To(Equal(1))
+	Expect(report.Daemon.Failures).To(Equal(4))
+}
+
+func TestE2EReloadSettings(t *testing.T) {
+	RegisterTestingT(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	addr := l.Addr().String()
+	l.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	rules := filepath.Join(e.configDir, "rules.json")
+	Expect(os.WriteFile(rules, []byte(`[{"name": "go", "domain": "go.dev", "add_tags": ["golang"]}]`), 0600)).To(Succeed())
+	serve := e.command("", "serve", "--listen", addr, "--log-format", "json")
+	stderr, err := serve.StderrPipe()
+	Expect(err).To(BeNil())
+	Expect(serve.Start()).To(Succeed())
+	defer serve.Process.Kill()
+
+	var mu sync.Mutex
+	logged := []map[string]any{}
+	go func() {
+		lines := bufio.NewScanner(stderr)
+		for lines.Scan() {
+			var record map[string]any
+			if json.Unmarshal(lines.Bytes(), &record) == nil {
+				mu.Lock()
+				logged = append(logged, record)
+				mu.Unlock()
+			}
+		}
+	}()
+	messages := func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(logged)
+	}
+
+	save := func(url string) {
+		token, err := os.ReadFile(filepath.Join(e.configDir, "serve_token"))
+		Expect(err).To(BeNil())
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/api/items", strings.NewReader(fmt.Sprintf(`{"url": %q}`, url)))
+		Expect(err).To(BeNil())
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	}
+	Eventually(messages, "10s").Should(ContainElement(HaveKeyWithValue("msg", "Serving")))
+	save("https://go.dev/blog/intro")
+
+	// A rule changed and another added, without restarting
+	Expect(os.WriteFile(rules, []byte(`[
+		{"name": "go", "domain": "go.dev", "add_tags": ["golang", "later"]},
+		{"name": "example", "domain": "example.com", "add_tags": ["example"]}
+	]`), 0600)).To(Succeed())
+	Eventually(messages, "10s").Should(ContainElement(SatisfyAll(
+		HaveKeyWithValue("msg", "Reloaded the settings"),
+		HaveKeyWithValue("rules_added", []any{"example"}),
+		HaveKeyWithValue("rules_changed", []any{"go"}),
+	)))
+	save("https://example.com/page")
+
+	// An invalid rule leaves those in use
+	Expect(os.WriteFile(rules, []byte(`[{"name": "bad", "state": "nowhere"}]`), 0600)).To(Succeed())
+	Eventually(messages, "10s").Should(ContainElement(HaveKeyWithValue("msg", "Not reloading the settings, keeping those in use")))
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(`{"notify": {"tags": ["later"]}, "http": {"max_idle_conns": 4}}`), 0600)).To(Succeed())
+	Expect(os.WriteFile(rules, []byte(`[]`), 0600)).To(Succeed())
+	Eventually(messages, "10s").Should(ContainElement(HaveKeyWithValue("msg", "Restart to apply some of the changes")))
+	Expect(messages()).To(ContainElement(SatisfyAll(
+		HaveKeyWithValue("msg", "Reloaded the settings"),
+		HaveKeyWithValue("changed", []any{"http.max_idle_conns", "notify.tags"}),
+		HaveKeyWithValue("rules_removed", []any{"example", "go"}),
+	)))
+
+	Expect(e.server.Items()).To(ConsistOf(
+		SatisfyAll(HaveField("GivenURL", "https://go.dev/blog/intro"), HaveField("Tags", SatisfyAll(HaveKey("golang"), Not(HaveKey("later"))))),
+		SatisfyAll(HaveField("GivenURL", "https://example.com/page"), HaveField("Tags", HaveKey("example"))),
+	))
+}
+
+func TestE2ESite(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	goBlog := e.server.Add(api.Item{GivenURL: "https://go.dev/blog/generics", GivenTitle: "Generics <in Go>", Excerpt: "Type parameters at last", Tags: tags("golang", "c++")})
+	news := e.server.Add(api.Item{GivenURL: "https://news.example.org/weekly", GivenTitle: "Weekly News"})
+	e.mustRun("sync")
+	e.mustRun("note", fmt.Sprint(goBlog), "read twice")
+
+	dir := filepath.Join(e.configDir, "public")
+	stdout := e.mustRun("site", "--dir", dir)
+	Expect(stdout).To(ContainSubstring("Wrote a site of 2 items"))
+
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).To(BeNil())
+		return string(b)
+	}
+	Expect(read("index.html")).To(ContainSubstring(fmt.Sprintf(`<a href="items/%d.html">Generics &lt;in Go&gt;</a>`, goBlog)))
+	page := read(fmt.Sprintf("items/%d.html", goBlog))
+	Expect(page).To(ContainSubstring(`<a href="https://go.dev/blog/generics">`))
+	Expect(page).To(ContainSubstring("<blockquote>Type parameters at last</blockquote>"))
+	Expect(page).To(ContainSubstring("read twice"))
+	Expect(page).To(ContainSubstring(`<a href="../tags/c.html">c&#43;&#43;</a>`))
+	Expect(page).To(ContainSubstring(`<a href="../domains/go.dev.html">go.dev</a>`))
+	Expect(read("tags/index.html")).To(ContainSubstring(`<a href="golang.html">golang</a> <small>1</small>`))
+	Expect(read("tags/golang.html")).To(ContainSubstring(fmt.Sprintf(`<a href="../items/%d.html">`, goBlog)))
+
+	var index struct {
+		Docs  []struct{ ID int }
+		Terms map[string][]int
+	}
+	Expect(json.Unmarshal([]byte(read("search.json")), &index)).To(Succeed())
+	Expect(index.Docs).To(HaveLen(2))
+	Expect(index.Docs[index.Terms["parameters"][0]].ID).To(Equal(goBlog))
+	Expect(index.Docs[index.Terms["weekly"][0]].ID).To(Equal(news))
+
+	// The pages of items gone are removed when built again
+	e.mustRun("delete", "--yes", fmt.Sprint(news))
+	e.mustRun("sync")
+	e.mustRun("site", "--dir", dir)
+	Expect(filepath.Join(dir, fmt.Sprintf("items/%d.html", news))).NotTo(BeAnExistingFile())
+	Expect(filepath.Join(dir, "domains/news.example.org.html")).NotTo(BeAnExistingFile())
+	Expect(filepath.Join(dir, fmt.Sprintf("items/%d.html", goBlog))).To(BeAnExistingFile())
+}
+
+func TestE2EAutoTag(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Titles about Go point one way, those about Rust another
+	embedded := 0
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.URL.Path).To(Equal("/api/embed"))
+		var body struct{ Input []string }
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		vectors := [][]float32{}
+		for _, text := range body.Input {
+			if strings.HasPrefix(text, "Go ") {
+				vectors = append(vectors, []float32{1, 0.1})
+			} else {
+				vectors = append(vectors, []float32{0.1, 1})
+			}
+		}
+		embedded += len(body.Input)
+		Expect(json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": vectors})).To(Succeed())
+	}))
+	defer model.Close()
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://example.com/go-tips", GivenTitle: "Go tips", Tags: tags("golang")})
+	e.server.Add(api.Item{GivenURL: "https://example.com/go-generics", GivenTitle: "Go generics", Tags: tags("golang", "programming")})
+	e.server.Add(api.Item{GivenURL: "https://example.com/rust-traits", GivenTitle: "Rust traits", Tags: tags("rust", "programming")})
+	goModules := e.server.Add(api.Item{GivenURL: "https://example.com/go-modules", GivenTitle: "Go modules"})
+	rustAsync := e.server.Add(api.Item{GivenURL: "https://example.com/rust-async", GivenTitle: "Rust async"})
+	e.mustRun("sync")
+
+	settings := fmt.Sprintf(`{"autotag": {"backend": "ollama", "url": %q, "model": "embed", "neighbors": 2}}`, model.URL)
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(settings), 0600)).To(Succeed())
+	stdout := e.mustRun("autotag")
+	Expect(stdout).To(ContainSubstring("Go modules\n    golang (1.00), programming (0.50)"))
+	Expect(stdout).To(ContainSubstring("Rust async\n    programming (0.83), rust (0.83)"))
+	Expect(stdout).To(ContainSubstring("2 of 2 untagged items with tags suggested"))
+	Expect(embedded).To(Equal(5))
+
+	// Embeddings are kept, and the tags given with --apply
+	e.mustRun("autotag", "--apply")
+	Expect(embedded).To(Equal(5))
+	item, _ := e.server.Item(goModules)
+	Expect(item.TagNames()).To(ConsistOf("golang", "programming"))
+	item, _ = e.server.Item(rustAsync)
+	Expect(item.TagNames()).To(ConsistOf("rust", "programming"))
+}
+
+func TestE2EReadOnly(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/precious", GivenTitle: "Precious"})
+
+	_, stderr, err := e.run("", "archive", "--read-only", fmt.Sprint(id))
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring("refusing to change the account in read-only mode"))
+	item, _ := e.server.Item(id)
+	Expect(item.Status).To(Equal(api.ItemStatus(api.ItemStatusUnread)))
+
+	// Reading still works, and so does a sync, which pushes nothing
+	Expect(e.ids("--read-only")).To(Equal([]string{fmt.Sprint(id)}))
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(`{"read_only": true}`), 0600)).To(Succeed())
+	e.mustRun("sync")
+	_, stderr, err = e.run("", "add", "https://example.com/new")
+	Expect(err).NotTo(BeNil())
+	Expect(stderr).To(ContainSubstring("read-only mode"))
+	Expect(e.server.Items()).To(HaveLen(1))
+}
+
+func TestE2EAudit(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/audited", GivenTitle: "Audited"})
+	e.mustRun("sync")
+	e.mustRun("archive", fmt.Sprint(id))
+	e.mustRun("add", "https://example.com/added")
+
+	type entry struct {
+		Account string `json:"account"`
+		Command string `json:"command"`
+		Action  string `json:"action"`
+		ItemID  int    `json:"item_id"`
+		URL     string `json:"url"`
+	}
+	var entries []entry
+	Expect(json.Unmarshal([]byte(e.mustRun("audit", "--output=json")), &entries)).To(Succeed())
+	Expect(entries).To(HaveLen(2))
+	Expect(entries[0]).To(Equal(entry{"default", "archive", "archive", id, "https://example.com/audited"}))
+	Expect(entries[1].Command).To(Equal("add"))
+	Expect(entries[1].Action).To(Equal("add"))
+	Expect(entries[1].URL).To(Equal("https://example.com/added"))
+
+	out := e.mustRun("audit", fmt.Sprint(id))
+	Expect(strings.Count(out, "\n")).To(Equal(1))
+	Expect(out).To(ContainSubstring("archive"))
+	Expect(out).To(ContainSubstring("(pocket archive)"))
+}
+
+func TestE2ESimulate(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	Expect(os.WriteFile(fixture, []byte(`[
+		{"item_id": "11", "given_url": "https://example.com/a", "given_title": "A", "status": "0"},
+		{"item_id": "12", "given_url": "https://example.com/b", "given_title": "B", "status": "0"}
+	]`), 0600)).To(Succeed())
+
+	// No authorization is needed, and nothing reaches Pocket or the state
+	Expect(e.ids("--simulate", fixture)).To(ConsistOf("11", "12"))
+	_, stderr, err := e.run("", "--simulate", fixture, "archive", "11")
+	Expect(err).To(BeNil(), stderr)
+	Expect(stderr).To(ContainSubstring("Simulated: 1 archived; nothing was sent to Pocket"))
+	_, stderr, err = e.run("", "--simulate", fixture, "sync")
+	Expect(err).To(BeNil(), stderr)
+	Expect(e.server.Requests("/v3/send") + e.server.Requests("/v3/get")).To(BeZero())
+	Expect(filepath.Join(e.configDir, "mirror.db")).NotTo(BeAnExistingFile())
+	Expect(filepath.Join(e.configDir, "auth.json")).NotTo(BeAnExistingFile())
+
+	// Each run starts from the fixture again
+	Expect(e.ids("--simulate", fixture)).To(ConsistOf("11", "12"))
+}
+
+func TestE2EDemo(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	fixture := filepath.Join(t.TempDir(), "demo.json")
+	_, stderr, err := e.run("", "demo", "--items", "50", "--out", fixture)
+	Expect(err).To(BeNil(), stderr)
+	Expect(stderr).To(ContainSubstring("pocket --simulate " + fixture))
+
+	items := []api.Item{}
+	b, err := os.ReadFile(fixture)
+	Expect(err).To(BeNil())
+	Expect(json.Unmarshal(b, &items)).To(Succeed())
+	Expect(items).To(HaveLen(50))
+	unread := []string{}
+	for _, item := range items {
+		if item.Status == api.ItemStatusUnread {
+			unread = append(unread, fmt.Sprint(item.ItemID))
+		}
+	}
+
+	// Commands run against them without an account
+	Expect(e.ids("--simulate", fixture)).To(ConsistOf(unread))
+	Expect(filepath.Join(e.configDir, "auth.json")).NotTo(BeAnExistingFile())
+
+	// The same seed and date make the same items, whenever they are made
+	first, stderr, err := e.run("", "demo", "--items", "20", "--seed", "7", "--now", "2024-03-01")
+	Expect(err).To(BeNil(), stderr)
+	Expect(e.mustRun("demo", "--items", "20", "--seed", "7", "--now", "2024-03-01")).To(Equal(first))
+	made := []api.Item{}
+	Expect(json.Unmarshal([]byte(first), &made)).To(Succeed())
+	for _, item := range made {
+		Expect(item.TimeAdded.Time).To(BeTemporally("<=", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+	}
+
+	_, stderr, err = e.run("", "demo", "--items", "0")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("--items must be a positive number of items"))
+
+	_, stderr, err = e.run("", "demo", "--now", "yesterday")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("--now must be a date such as 2006-01-02"))
+}
+
+func TestE2EIDRanges(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	ids := make([]int, 6)
+	for i := range ids {
+		ids[i] = e.server.Add(api.Item{GivenURL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	e.server.Add(api.Item{GivenURL: "https://example.com/read", Status: api.ItemStatusArchived})
+
+	// The items added after the last good one, in the same second here
+	out := e.mustRun("archive", "--after-id", fmt.Sprint(ids[3]), "--yes")
+	Expect(out).To(ContainSubstring("2 unread items:"))
+	Expect(e.ids()).To(ConsistOf(fmt.Sprint(ids[0]), fmt.Sprint(ids[1]), fmt.Sprint(ids[2]), fmt.Sprint(ids[3])))
+
+	// Ranges take the items of --state with IDs in between
+	e.mustRun("delete", fmt.Sprintf("%d-%d", ids[1], ids[4]), "--state", "all", "--yes")
+	Expect(e.ids("--state", "all")).To(HaveLen(3))
+	_, stderr, err := e.run("", "archive", "--before-id", fmt.Sprint(ids[0]), "--yes")
+	Expect(err).To(BeNil())
+	Expect(stderr).To(ContainSubstring("No unread items selected"))
+	_, stderr, err = e.run("", "archive", "--before-id", "999999", "--yes")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("no item 999999"))
+}
+
+func TestE2ELogActions(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/planned", GivenTitle: "Planned"})
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	Expect(os.WriteFile(fixture, []byte(e.mustRun("export", "--format", "json")), 0600)).To(Succeed())
+
+	// The plan is made against the simulated account
+	plan := filepath.Join(t.TempDir(), "plan.jsonl")
+	e.mustRun("--simulate", fixture, "--log-actions", plan, "tag", fmt.Sprint(id), "later")
+	e.mustRun("--simulate", fixture, "--log-actions", plan, "archive", fmt.Sprint(id))
+	b, err := os.ReadFile(plan)
+	Expect(err).To(BeNil())
+	Expect(strings.Count(string(b), "\n")).To(Equal(2))
+	Expect(e.server.Requests("/v3/send")).To(BeZero())
+
+	// Then applied as it is
+	e.mustRun("apply", plan)
+	item, _ := e.server.Item(id)
+	Expect(item.Status).To(Equal(api.ItemStatus(api.ItemStatusArchived)))
+	Expect(item.Tags).To(HaveKey("later"))
+}
+
+func TestE2EDryRunDiff(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	id := e.server.Add(api.Item{GivenURL: "https://example.com/old", GivenTitle: "Old", Tags: tags("go", "inbox")})
+	settings := `{"rules": [{"name": "tidy", "domain": "example.com", "add_tags": ["read"], "remove_tags": ["inbox"], "archive": true}]}`
+	Expect(os.WriteFile(filepath.Join(e.configDir, "config.json"), []byte(settings), 0600)).To(Succeed())
+
+	stdout := e.mustRun("rules", "run", "--dry-run", "--output", "diff")
+	Expect(stdout).To(HavePrefix(fmt.Sprintf("# --- %d Old\n# +++ %d Old\n# @@ https://example.com/old @@\n", id, id)))
+	Expect(stdout).To(ContainSubstring("# -state: unread\n# +state: archived\n#  favorite: no\n# -tags: go, inbox\n# +tags: go, read\n"))
+	Expect(strings.Fields(e.mustRun("rules", "run", "--dry-run", "--output", "jsonl"))).To(HaveLen(3))
+	Expect(e.server.Requests("/v3/send")).To(BeZero())
+
+	// The diff is applied as it is
+	plan := filepath.Join(t.TempDir(), "plan.diff")
+	Expect(os.WriteFile(plan, []byte(stdout), 0600)).To(Succeed())
+	e.mustRun("apply", plan)
+	item, _ := e.server.Item(id)
+	Expect(item.Status).To(Equal(api.ItemStatus(api.ItemStatusArchived)))
+	Expect(item.TagNames()).To(Equal([]string{"go", "read"}))
+
+	_, stderr, err := e.run("", "rules", "run", "--output", "diff")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("--output diff is for --dry-run"))
+}
+
+func TestE2EGoalsReadingLog(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := newE2E(t)
+	e.authorize()
+	e.server.Add(api.Item{GivenURL: "https://example.com/read", Status: api.ItemStatusArchived, TimeRead: api.Time{Time: time.Now()}})
+
+	Expect(e.mustRun("goals")).NotTo(BeEmpty())
+	path := filepath.Join(e.configDir, "reading_log.json")
+	Expect(path).To(BeAnExistingFile())
+
+	// A log that can't be read is left alone rather than started over
+	Expect(os.WriteFile(path, []byte(`{"since": 12, "reads": {`), 0600)).To(Succeed())
+	_, stderr, err := e.run("", "goals")
+	Expect(err).To(HaveOccurred())
+	Expect(stderr).To(ContainSubstring("reading_log.json"))
+	b, err := os.ReadFile(path)
+	Expect(err).To(BeNil())
+	Expect(string(b)).To(Equal(`{"since": 12, "reads": {`))
+}
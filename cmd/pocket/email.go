@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/epub"
+	"github.com/motemen/go-pocket/library"
+)
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Items}}
+<h2><a href="{{.URL}}">{{.Title}}</a></h2>
+{{if .Excerpt}}<p>{{.Excerpt}}</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// base64Lines wraps base64 output at 76 characters as required by MIME.
+type base64Lines struct {
+	w io.Writer
+	n int
+}
+
+func (l *base64Lines) Write(p []byte) (int, error) {
+	for i, b := range p {
+		if l.n == 76 {
+			if _, err := l.w.Write([]byte("\r\n")); err != nil {
+				return i, err
+			}
+			l.n = 0
+		}
+		if _, err := l.w.Write([]byte{b}); err != nil {
+			return i, err
+		}
+		l.n++
+	}
+	return len(p), nil
+}
+
+// composeDigest builds a MIME message with an HTML digest body and an
+// optional attachment.
+func composeDigest(from, to, subject string, body []byte, attachmentName string, attachment []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	type mimePart struct {
+		header textproto.MIMEHeader
+		data   []byte
+	}
+
+	parts := []mimePart{
+		{textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}}, body},
+	}
+	if attachment != nil {
+		parts = append(parts, mimePart{textproto.MIMEHeader{
+			"Content-Type":        {mime.FormatMediaType("application/epub+zip", map[string]string{"name": attachmentName})},
+			"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{"filename": attachmentName})},
+		}, attachment})
+	}
+
+	for _, part := range parts {
+		part.header.Set("Content-Transfer-Encoding", "base64")
+		pw, err := mw.CreatePart(part.header)
+		if err != nil {
+			return nil, err
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, &base64Lines{w: pw})
+		if _, err := enc.Write(part.data); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func commandEmail(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		panic(err)
+	}
+
+	smtpConf := settings.SMTP
+	to := smtpConf.To
+	if conf.To != "" {
+		to = conf.To
+	}
+	if smtpConf.Host == "" || smtpConf.From == "" || to == "" {
+		fmt.Fprintln(os.Stderr, `Configure "smtp" with at least "host", "from", and "to" in config.json`)
+		os.Exit(1)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		Domain: conf.Domain,
+		Search: conf.SearchQuery,
+		Tag:    conf.Tag,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, "No items to send")
+		return
+	}
+
+	title := fmt.Sprintf("Pocket %s", time.Now().Format("2006-01-02"))
+
+	// The digest lists only the items that made it into the book
+	included := items
+	var attachment []byte
+	if conf.AttachEPUB {
+		var book *epub.Book
+		book, included = buildEPUB(client, title, items)
+		if len(included) == 0 {
+			fmt.Fprintln(os.Stderr, "No items could be bundled to send")
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := book.Write(&buf); err != nil {
+			panic(err)
+		}
+		attachment = buf.Bytes()
+	}
+
+	var body bytes.Buffer
+	err = digestTemplate.Execute(&body, struct {
+		Title string
+		Items []api.Item
+	}{title, included})
+	if err != nil {
+		panic(err)
+	}
+
+	msg, err := composeDigest(smtpConf.From, to, title, body.Bytes(), library.Slugify(title)+".epub", attachment)
+	if err != nil {
+		panic(err)
+	}
+
+	port := smtpConf.Port
+	if port == 0 {
+		port = 587
+	}
+
+	var auth smtp.Auth
+	if smtpConf.Username != "" {
+		auth = smtp.PlainAuth("", smtpConf.Username, smtpConf.Password, smtpConf.Host)
+	}
+
+	err = smtp.SendMail(smtpConf.Host+":"+strconv.Itoa(port), auth, smtpConf.From, []string{to}, msg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Sent %d items to %s\n", len(included), to)
+
+	if conf.ArchiveAfter {
+		actions := []*api.Action{}
+		for _, item := range included {
+			actions = append(actions, api.NewArchiveAction(item.ItemID))
+		}
+		_, err := modifyInBatches(client, actions)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Archived %d items\n", len(actions))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// goneEndpoints are the endpoints of the API Pocket answered it no longer
+// serves, with when it did, kept in endpoints.json so that every command
+// falls back without asking again until api.GoneRetry has passed.
+type goneEndpoints map[string]time.Time
+
+// goneEndpointsFile returns the path of endpoints.json.
+func goneEndpointsFile() string {
+	return filepath.Join(configDir, "endpoints.json")
+}
+
+// loadGoneEndpoints returns the endpoints found gone less than
+// api.GoneRetry ago.
+func loadGoneEndpoints() goneEndpoints {
+	all := goneEndpoints{}
+	if err := loadJSONFromFile(goneEndpointsFile(), &all); err != nil && !os.IsNotExist(err) {
+		slog.Debug("Could not load the endpoints found gone", "err", err)
+	}
+	gone := goneEndpoints{}
+	for endpoint, since := range all {
+		if time.Since(since) < api.GoneRetry {
+			gone[endpoint] = since
+		}
+	}
+	return gone
+}
+
+// restoreGoneEndpoints tells the api package of the endpoints found gone by
+// earlier commands.
+func restoreGoneEndpoints() {
+	for endpoint, since := range loadGoneEndpoints() {
+		api.MarkGone(endpoint, since)
+	}
+}
+
+// goneSaving serializes writes of endpoints.json by concurrent requests.
+var goneSaving sync.Mutex
+
+// saveGoneEndpoint keeps endpoint in endpoints.json and warns of it, as
+// api.OnGone.
+func saveGoneEndpoint(endpoint string) {
+	goneSaving.Lock()
+	defer goneSaving.Unlock()
+
+	slog.Warn(fmt.Sprintf("Pocket no longer serves %s; using the local mirror and queueing changes instead", endpoint),
+		"retry_after", api.GoneRetry)
+	gone := loadGoneEndpoints()
+	gone[endpoint] = time.Now()
+	if err := saveJSONToFile(goneEndpointsFile(), gone); err != nil {
+		slog.Debug("Could not save the endpoints found gone", "err", err)
+	}
+}
+
+// describe lists the endpoints, as "/v3/get and /v3/send".
+func (gone goneEndpoints) describe() string {
+	endpoints := make([]string, 0, len(gone))
+	for endpoint := range gone {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	if len(endpoints) == 1 {
+		return endpoints[0]
+	}
+	return strings.Join(endpoints[:len(endpoints)-1], ", ") + " and " + endpoints[len(endpoints)-1]
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/epub"
+)
+
+// fetchImage downloads an image for embedding into an EPUB.
+func fetchImage(url string) ([]byte, string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("got response %d for %s", resp.StatusCode, url)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, http.DetectContentType(data), nil
+}
+
+// buildEPUB fetches the article text of the items, a few at a time, and
+// bundles them into a book, returning the items that made it in.
+func buildEPUB(client *api.Client, title string, items []api.Item) (*epub.Book, []api.Item) {
+	book := epub.NewBook(title)
+	book.Author = "Pocket"
+
+	articles := make([]*api.Article, len(items))
+	errs, _ := newBulk("Fetching articles", 4).Run(len(items), func(i int) error {
+		article, err := client.Article(items[i].URL())
+		articles[i] = article
+		return err
+	})
+
+	included := []api.Item{}
+	for i, item := range items {
+		if errs[i] != nil {
+			slog.Warn("Skipping an item whose article could not be fetched", "url", item.URL(), "err", errs[i])
+			continue
+		}
+
+		url := html.EscapeString(item.URL())
+		body := fmt.Sprintf(`<p><a href="%s">%s</a></p>%s`, url, url, articles[i].HTML)
+		err := book.AddChapter(item.Title(), body, item.URL(), fetchImage)
+		if err != nil {
+			slog.Warn("Skipping an item that could not be added to the book", "url", item.URL(), "err", err)
+			continue
+		}
+
+		included = append(included, item)
+	}
+
+	return book, included
+}
+
+func commandEPUB(conf Config, client *api.Client) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		Domain:      conf.Domain,
+		Search:      conf.SearchQuery,
+		Tag:         conf.Tag,
+		ContentType: api.ContentTypeArticle,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, "No items to bundle")
+		return
+	}
+
+	title := fmt.Sprintf("Pocket %s", time.Now().Format("2006-01-02"))
+	book, included := buildEPUB(client, title, items)
+
+	w, err := createOutput(conf.Out)
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	err = book.Write(w)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Bundled %d of %d items\n", len(included), len(items))
+
+	if conf.ArchiveAfter {
+		actions := []*api.Action{}
+		for _, item := range included {
+			actions = append(actions, api.NewArchiveAction(item.ItemID))
+		}
+		_, err := modifyInBatches(client, actions)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(os.Stderr, "Archived %d items\n", len(actions))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// jsonError is an error reported on stderr with --output json.
+type jsonError struct {
+	// Code classifies the error: "usage", "network", "unauthorized",
+	// "rate_limited", "unavailable", "gone", "api", or "internal".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Status is the HTTP status of an API error.
+	Status int `json:"status,omitempty"`
+	// RetryAfter is the number of seconds to wait before retrying, if known.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// newJSONError classifies an error, or any other value a command panicked
+// with.
+func newJSONError(v interface{}) jsonError {
+	err, ok := v.(error)
+	if !ok {
+		return jsonError{Code: "internal", Message: fmt.Sprint(v)}
+	}
+
+	e := jsonError{Code: "internal", Message: err.Error()}
+
+	var uerr *usageError
+	var apiErr *api.Error
+	var downErr *api.UnavailableError
+	var pageErr *api.PageError
+	var goneErr *api.GoneError
+	switch {
+	case errors.As(err, &uerr):
+		e.Code = "usage"
+	case errors.As(err, &downErr):
+		e.Code = "unavailable"
+		e.RetryAfter = int(time.Until(downErr.Until).Seconds()) + 1
+	case errors.As(err, &pageErr):
+		e.Code = "unavailable"
+		e.Status = pageErr.StatusCode
+	case errors.As(err, &goneErr):
+		e.Code = "gone"
+		e.Status = goneErr.StatusCode
+	case isNetworkError(err):
+		e.Code = "network"
+	case errors.As(err, &apiErr):
+		e.Status = apiErr.StatusCode
+		e.RetryAfter = int(apiErr.RetryAfter().Seconds())
+		e.Message = apiErr.Message
+		if e.Message == "" {
+			e.Message = http.StatusText(apiErr.StatusCode)
+		}
+		switch {
+		case apiErr.StatusCode == 401:
+			e.Code = "unauthorized"
+		case apiErr.StatusCode == 429 || apiErr.StatusCode == 403 && e.RetryAfter > 0:
+			e.Code = "rate_limited"
+		case apiErr.StatusCode >= 500:
+			e.Code = "unavailable"
+		default:
+			e.Code = "api"
+		}
+	}
+	return e
+}
+
+// printJSONError writes the error as a line of JSON to stderr.
+func printJSONError(v interface{}) {
+	json.NewEncoder(os.Stderr).Encode(newJSONError(v))
+}
+
+// exitWithError prints err, as JSON with --output json, and exits with
+// status 1.
+func exitWithError(conf Config, err error) {
+	if conf.Output == "json" {
+		printJSONError(err)
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(1)
+}
+
+// exitOnReadOnly is deferred by main, turning a panic of a command refused
+// by read-only mode into an error message and exit status 1, as it is not
+// a bug to report.
+func exitOnReadOnly(conf Config, command string) {
+	if v := recover(); v != nil {
+		if err, ok := v.(error); ok && errors.Is(err, api.ErrReadOnly) {
+			exitWithError(conf, errors.New(tr(msgReadOnly, map[string]string{"Command": command})))
+		}
+		panic(v)
+	}
+}
+
+// reportJSONError is deferred by main with --output json, turning a panic
+// of a command into a JSON error on stderr and exit status 1.
+func reportJSONError() {
+	if v := recover(); v != nil {
+		printJSONError(v)
+		os.Exit(1)
+	}
+}
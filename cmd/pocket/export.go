@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// writeJSONBackup writes items as a JSON array that the restore command can
+// read back, with the notes of items in a "note" field.
+func writeJSONBackup(w io.Writer, items []api.Item) error {
+	notes, err := loadNotes()
+	if err != nil {
+		return err
+	}
+	noted := make([]notedItem, len(items))
+	for i, item := range items {
+		noted[i] = notedItem{Item: item, Note: notes[item.ItemID]}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(noted)
+}
+
+// listExporters write all exported items into a single file.
+var listExporters = map[string]func(io.Writer, []api.Item) error{
+	"json":     writeJSONBackup,
+	"wallabag": writeWallabag,
+	"omnivore": writeOmnivore,
+	"shiori":   writeNetscapeBookmarks,
+}
+
+func commandExport(conf Config, client *api.Client) {
+	if conf.GitDir != "" {
+		commandExportGit(conf, client)
+		return
+	}
+
+	format := conf.FormatTemplate
+	if format == "" {
+		format = "markdown"
+	}
+
+	if export, ok := listExporters[format]; ok {
+		items, err := retrieveItems(client, &api.RetrieveOption{
+			State:      api.StateAll,
+			Domain:     conf.Domain,
+			Search:     conf.SearchQuery,
+			Tag:        conf.Tag,
+			DetailType: api.DetailTypeComplete,
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		w, err := createOutput(conf.Out)
+		if err != nil {
+			panic(err)
+		}
+		defer w.Close()
+
+		err = export(w, items)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	var (
+		ext   string
+		write func(io.Writer, api.Item, string) error
+	)
+	switch format {
+	case "markdown", "md":
+		ext, write = "md", library.WriteMarkdown
+	case "org":
+		ext, write = "org", library.WriteOrg
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown export format %q; use \"markdown\", \"org\", \"json\", \"wallabag\", \"omnivore\", or \"shiori\"\n", format)
+		os.Exit(1)
+	}
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	notes, err := loadNotes()
+	if err != nil {
+		panic(err)
+	}
+
+	err = os.MkdirAll(conf.Dir, 0777)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, item := range items {
+		err := library.RemoveStaleExports(conf.Dir, item, ext)
+		if err != nil {
+			panic(err)
+		}
+		path := filepath.Join(conf.Dir, library.ExportFileName(item, ext))
+		f, err := os.Create(path)
+		if err != nil {
+			panic(err)
+		}
+		err = write(f, item, notes[item.ItemID])
+		f.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	fmt.Printf("Exported %d items to %s\n", len(items), conf.Dir)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type wallabagEntry struct {
+	Title      string   `json:"title"`
+	URL        string   `json:"url"`
+	IsArchived int      `json:"is_archived"`
+	IsStarred  int      `json:"is_starred"`
+	Tags       []string `json:"tags"`
+	Content    string   `json:"content"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// writeWallabag writes items in the format of wallabag's "wallabag v2" importer.
+func writeWallabag(w io.Writer, items []api.Item) error {
+	entries := make([]wallabagEntry, 0, len(items))
+	for _, item := range items {
+		entries = append(entries, wallabagEntry{
+			Title:      item.Title(),
+			URL:        item.URL(),
+			IsArchived: boolInt(item.Status == api.ItemStatusArchived),
+			IsStarred:  boolInt(item.Favorite == 1),
+			Tags:       item.TagNames(),
+			Content:    html.EscapeString(item.Excerpt),
+			CreatedAt:  item.TimeAdded.UTC().Format(time.RFC3339),
+			UpdatedAt:  item.TimeUpdated.UTC().Format(time.RFC3339),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// writeOmnivore writes items as the CSV accepted by Omnivore's importer.
+func writeOmnivore(w io.Writer, items []api.Item) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"url", "state", "labels", "saved_at", "published_at"})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		state := "SUCCEEDED"
+		if item.Status == api.ItemStatusArchived {
+			state = "ARCHIVED"
+		}
+
+		err := cw.Write([]string{
+			item.URL(),
+			state,
+			"[" + strings.Join(item.TagNames(), ",") + "]",
+			strconv.FormatInt(item.TimeAdded.Unix()*1000, 10),
+			"",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeNetscapeBookmarks writes items as a Netscape bookmark file, which
+// Shiori (and most browsers and bookmark managers) can import.
+func writeNetscapeBookmarks(w io.Writer, items []api.Item) error {
+	_, err := io.WriteString(w, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Pocket</TITLE>
+<H1>Pocket</H1>
+<DL><p>
+`)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		_, err := fmt.Fprintf(w, "<DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\" TAGS=\"%s\">%s</A>\n",
+			html.EscapeString(item.URL()),
+			item.TimeAdded.Unix(),
+			item.TimeUpdated.Unix(),
+			html.EscapeString(strings.Join(item.TagNames(), ",")),
+			html.EscapeString(item.Title()),
+		)
+		if err != nil {
+			return err
+		}
+		if item.Excerpt != "" {
+			_, err = fmt.Fprintf(w, "<DD>%s\n", html.EscapeString(item.Excerpt))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = io.WriteString(w, "</DL><p>\n")
+	return err
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Links      []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func atomTime(t api.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// newAtomFeed builds an Atom feed of items, which should be ordered newest
+// first. The feed's update time is that of the most recently updated item, so
+// that the output only changes when the items do.
+func newAtomFeed(items []api.Item) *atomFeed {
+	updated := api.Time{Time: time.Now()}
+	if len(items) > 0 {
+		updated = items[0].TimeUpdated
+		for _, item := range items {
+			if item.TimeUpdated.After(updated.Time) {
+				updated = item.TimeUpdated
+			}
+		}
+	}
+
+	feed := &atomFeed{
+		Title:   "Pocket",
+		ID:      "urn:pocket:list",
+		Updated: atomTime(updated),
+		Author:  atomPerson{Name: "Pocket"},
+		Links:   []atomLink{{Href: "https://getpocket.com/saves"}},
+	}
+
+	for _, item := range items {
+		entry := atomEntry{
+			Title:     item.Title(),
+			ID:        fmt.Sprintf("urn:pocket:item:%d", item.ItemID),
+			Updated:   atomTime(item.TimeUpdated),
+			Published: atomTime(item.TimeAdded),
+			Links:     []atomLink{{Href: item.URL(), Rel: "alternate"}},
+			Summary:   item.Excerpt,
+		}
+		for _, tag := range item.TagNames() {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func writeAtomFeed(w io.Writer, items []api.Item) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(newAtomFeed(items))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// jsonFeed is a feed in the JSON Feed 1.1 format, https://jsonfeed.org.
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// ContentText is the excerpt, as every item needs some content
+	ContentText   string   `json:"content_text"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// newJSONFeed builds a JSON Feed of items with the same entries as
+// newAtomFeed. feedURL, if known, is where the feed is served.
+func newJSONFeed(items []api.Item, feedURL string) *jsonFeed {
+	feed := &jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "Pocket",
+		HomePageURL: "https://getpocket.com/saves",
+		FeedURL:     feedURL,
+		Authors:     []jsonFeedAuthor{{Name: "Pocket"}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range items {
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            fmt.Sprintf("urn:pocket:item:%d", item.ItemID),
+			URL:           item.URL(),
+			Title:         item.Title(),
+			ContentText:   item.Excerpt,
+			DatePublished: atomTime(item.TimeAdded),
+			DateModified:  atomTime(item.TimeUpdated),
+			Tags:          item.TagNames(),
+		})
+	}
+	return feed
+}
+
+func writeJSONFeed(w io.Writer, items []api.Item, feedURL string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONFeed(items, feedURL))
+}
+
+// writeFeed writes items as a feed in format, "atom" or "json".
+func writeFeed(w io.Writer, format string, items []api.Item, feedURL string) error {
+	if format == "json" {
+		return writeJSONFeed(w, items, feedURL)
+	}
+	return writeAtomFeed(w, items)
+}
+
+func feedItems(conf Config, client *api.Client) ([]api.Item, error) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		Sort:       api.SortNewest,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].TimeAdded.After(items[j].TimeAdded.Time)
+	})
+
+	return items, nil
+}
+
+func commandFeed(conf Config, client *api.Client) {
+	format := conf.FormatTemplate
+	switch format {
+	case "":
+		format = "atom"
+	case "atom", "json":
+	default:
+		exitWithError(conf, &usageError{err: fmt.Errorf("unknown feed format %q; use \"atom\" or \"json\"", format)})
+	}
+
+	if conf.Listen != "" {
+		serve := func(format, contentType string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				items, err := feedItems(conf, client)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadGateway)
+					return
+				}
+
+				w.Header().Set("Content-Type", contentType)
+				err = writeFeed(w, format, items, "http://"+r.Host+r.URL.Path)
+				if err != nil {
+					slog.Error("Could not write the feed", "err", err)
+				}
+			}
+		}
+		http.HandleFunc("/feed.xml", serve("atom", "application/atom+xml; charset=utf-8"))
+		http.HandleFunc("/feed.json", serve("json", "application/feed+json; charset=utf-8"))
+
+		slog.Info("Serving the feed", "url", "http://"+conf.Listen+"/feed.xml", "json_url", "http://"+conf.Listen+"/feed.json")
+		logFatal("Serving the feed failed", http.ListenAndServe(conf.Listen, nil))
+	}
+
+	items, err := feedItems(conf, client)
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := createOutput(conf.Out)
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	err = writeFeed(w, format, items, "")
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/feeds"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// feedStatePath is the file keeping what was seen of each feed followed by
+// the daemon.
+func feedStatePath() string {
+	return filepath.Join(configDir, "feeds.json")
+}
+
+// pollFeeds saves the new entries of the feeds in settings, and returns the
+// number saved. The entries found when a feed is first polled are only
+// recorded as seen, so that following a feed does not bring in its whole
+// backlog. If the entries cannot be saved, they are queued in m.
+func pollFeeds(client *api.Client, m *mirror.Mirror, settings *Settings) (int, error) {
+	if len(settings.Feeds) == 0 {
+		return 0, nil
+	}
+
+	state := map[string]*feeds.Feed{}
+	err := loadJSONFromFile(feedStatePath(), &state)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	polled := make([]*feeds.Feed, len(settings.Feeds))
+	first := make([]bool, len(settings.Feeds))
+	for i, s := range settings.Feeds {
+		f, ok := state[s.URL]
+		if !ok {
+			f = &feeds.Feed{URL: s.URL}
+		}
+		polled[i], first[i] = f, !ok
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	fresh := make([][]feeds.Entry, len(polled))
+	e := newBulk("Polling feeds", 4)
+	e.Progress = nil
+	errs, _ := e.Run(len(polled), func(i int) error {
+		entries, err := polled[i].Fetch(httpClient)
+		if err != nil {
+			return err
+		}
+		fresh[i] = polled[i].New(entries)
+		return nil
+	})
+
+	actions := []*api.Action{}
+	for i, s := range settings.Feeds {
+		if errs[i] != nil {
+			if !errors.Is(errs[i], feeds.ErrNotModified) {
+				slog.Warn("Could not poll a feed", "url", s.URL, "err", errs[i])
+			}
+			continue
+		}
+		if first[i] {
+			state[s.URL] = polled[i]
+			slog.Info("Following a feed", "url", s.URL, "entries", len(fresh[i]))
+			continue
+		}
+		for _, entry := range fresh[i] {
+			req := addRequest{URL: entry.URL, Title: entry.Title, Tags: s.Tags}
+			actions = append(actions, newSaveAction(req, settings.Rules))
+		}
+	}
+
+	if len(actions) > 0 {
+		_, err = modifyInBatches(client, actions)
+		if err != nil && isNetworkError(err) {
+			err = m.Enqueue(actions...)
+		}
+		if err != nil {
+			// The entries are tried again on the next poll
+			return 0, fmt.Errorf("could not save feed entries: %w", err)
+		}
+		slog.Info("Saved feed entries", "count", len(actions))
+	}
+
+	// Feeds no longer followed are forgotten
+	followed := map[string]*feeds.Feed{}
+	for _, s := range settings.Feeds {
+		if f, ok := state[s.URL]; ok {
+			followed[s.URL] = f
+		}
+	}
+	return len(actions), saveJSONToFile(feedStatePath(), followed)
+}
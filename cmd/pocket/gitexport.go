@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// gitItem is the file written for an item by "export --git". Its fields are
+// in alphabetical order, and those that change without the item changing,
+// like the sort order and the time updated, are left out, so that the file
+// only changes with the item.
+type gitItem struct {
+	Added      string         `json:"added"`
+	Archived   bool           `json:"archived"`
+	Excerpt    string         `json:"excerpt,omitempty"`
+	Favorite   bool           `json:"favorite"`
+	Favorited  string         `json:"favorited,omitempty"`
+	Highlights []gitHighlight `json:"highlights,omitempty"`
+	ItemID     int            `json:"item_id"`
+	Note       string         `json:"note,omitempty"`
+	Read       string         `json:"read,omitempty"`
+	Tags       []string       `json:"tags"`
+	Title      string         `json:"title"`
+	URL        string         `json:"url"`
+	WordCount  int            `json:"word_count,omitempty"`
+}
+
+type gitHighlight struct {
+	Created string `json:"created"`
+	ID      string `json:"id"`
+	Quote   string `json:"quote"`
+}
+
+// gitFilePattern matches the names of the files written by "export --git".
+var gitFilePattern = regexp.MustCompile(`^\d+\.json$`)
+
+func gitTime(t api.Time) string {
+	if t.IsZero() || t.Unix() == 0 {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// gitItemFile returns the content of the file of an item, with its note.
+func gitItemFile(item api.Item, note string) ([]byte, error) {
+	highlights := []gitHighlight{}
+	for _, a := range item.Annotations {
+		highlights = append(highlights, gitHighlight{Created: a.CreatedAt, ID: a.AnnotationID, Quote: a.Quote})
+	}
+	sort.Slice(highlights, func(i, j int) bool { return highlights[i].ID < highlights[j].ID })
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(gitItem{
+		Added:      gitTime(item.TimeAdded),
+		Archived:   item.Status == api.ItemStatusArchived,
+		Excerpt:    item.Excerpt,
+		Favorite:   item.Favorite == 1,
+		Favorited:  gitTime(item.TimeFavorited),
+		Highlights: highlights,
+		ItemID:     item.ItemID,
+		Note:       note,
+		Read:       gitTime(item.TimeRead),
+		Tags:       item.TagNames(),
+		Title:      item.Title(),
+		URL:        item.URL(),
+		WordCount:  item.WordCount,
+	})
+	return buf.Bytes(), err
+}
+
+// gitExportResult counts the files changed by "export --git".
+type gitExportResult struct {
+	Added, Changed, Removed int
+}
+
+// exportToGit writes one file per item into dir, named by the item ID, and
+// removes the files of items not given. Files whose content is the same are
+// left alone.
+func exportToGit(dir string, items []api.Item) (*gitExportResult, error) {
+	notes, err := loadNotes()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	result := &gitExportResult{}
+	kept := map[string]bool{}
+	for _, item := range items {
+		data, err := gitItemFile(item, notes[item.ItemID])
+		if err != nil {
+			return nil, err
+		}
+
+		name := strconv.Itoa(item.ItemID) + ".json"
+		kept[name] = true
+		path := filepath.Join(dir, name)
+		old, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			result.Added++
+		case err != nil:
+			return nil, err
+		case bytes.Equal(old, data):
+			continue
+		default:
+			result.Changed++
+		}
+		if err := os.WriteFile(path, data, 0666); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !gitFilePattern.MatchString(entry.Name()) || kept[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return nil, err
+		}
+		result.Removed++
+	}
+
+	return result, nil
+}
+
+func commandExportGit(conf Config, client *api.Client) {
+	if conf.Domain != "" || conf.Tag != "" || conf.SearchQuery != "" {
+		exitWithError(conf, &usageError{command: "export", err: errors.New("--git backs up every item, and cannot be combined with filters")})
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:       api.StateAll,
+		DetailType:  api.DetailTypeComplete,
+		Annotations: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := exportToGit(conf.GitDir, items)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	fmt.Printf("Exported %d items to %s: %d added, %d changed, %d removed\n",
+		len(items), conf.GitDir, result.Added, result.Changed, result.Removed)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// readingLog records when items were archived. It is kept locally so that
+// reads still count after the items are deleted from Pocket.
+type readingLog struct {
+	// Since is the timestamp returned by the last retrieve, used to only
+	// fetch changes on the next update.
+	Since int `json:"since"`
+	// Reads maps item IDs to the Unix time they were archived.
+	Reads map[string]int64 `json:"reads"`
+}
+
+func readingLogPath() string {
+	return filepath.Join(configDir, "reading_log.json")
+}
+
+// updateReadingLog loads the reading log and adds the items archived since
+// it was last updated.
+func updateReadingLog(client *api.Client) (*readingLog, error) {
+	rlog := &readingLog{Reads: map[string]int64{}}
+	path := readingLogPath()
+	err := loadJSONFromFile(path, rlog)
+	if err != nil && !os.IsNotExist(err) {
+		// Starting over would overwrite the reads it records
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	res, err := client.Retrieve(&api.RetrieveOption{
+		State: api.StateArchive,
+		Since: rlog.Since,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range res.List {
+		if item.Status == api.ItemStatusArchived && library.UnixTime(item.TimeRead) > 0 {
+			rlog.Reads[strconv.Itoa(item.ItemID)] = item.TimeRead.Unix()
+		}
+	}
+	rlog.Since = res.Since
+
+	err = saveJSONToFile(path, rlog)
+	if err != nil {
+		return nil, err
+	}
+
+	return rlog, nil
+}
+
+// readsPerDay counts reads by local calendar day (2006-01-02).
+func readsPerDay(rlog *readingLog) map[string]int {
+	days := map[string]int{}
+	for _, t := range rlog.Reads {
+		days[time.Unix(t, 0).Format("2006-01-02")]++
+	}
+	return days
+}
+
+// readingStreak returns the number of consecutive days, ending today, on
+// which at least goal items were read. Today is not counted against the
+// streak until it is over.
+func readingStreak(days map[string]int, goal int, now time.Time) int {
+	if goal < 1 {
+		goal = 1
+	}
+
+	streak := 0
+	day := now
+	if days[day.Format("2006-01-02")] < goal {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day.Format("2006-01-02")] >= goal {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+func progressBar(n, goal int) string {
+	const width = 20
+	if goal <= 0 {
+		return ""
+	}
+	if screenReader {
+		return fmt.Sprintf("(%d%%)", n*100/goal)
+	}
+	filled := n * width / goal
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+func commandGoals(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		panic(err)
+	}
+
+	rlog, err := updateReadingLog(client)
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	days := readsPerDay(rlog)
+
+	today := days[now.Format("2006-01-02")]
+	week := 0
+	for d := weekStart(now); !d.After(now); d = d.AddDate(0, 0, 1) {
+		week += days[d.Format("2006-01-02")]
+	}
+
+	goals := settings.Goals
+	fmt.Printf("Today:     %3d / %-3d %s\n", today, goals.Daily, progressBar(today, goals.Daily))
+	fmt.Printf("This week: %3d / %-3d %s\n", week, goals.Weekly, progressBar(week, goals.Weekly))
+	fmt.Printf("Streak:    %d days\n\n", readingStreak(days, goals.Daily, now))
+
+	for i := 13; i >= 0; i-- {
+		d := now.AddDate(0, 0, -i)
+		n := days[d.Format("2006-01-02")]
+		mark := " "
+		if goals.Daily > 0 && n >= goals.Daily {
+			mark = "✓"
+		}
+		fmt.Printf("  %s %s %3d %s\n", d.Format("Mon 01/02"), mark, n, strings.Repeat("▇", n))
+	}
+}
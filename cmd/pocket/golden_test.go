@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/feeds"
+	"github.com/motemen/go-pocket/library"
+	. "github.com/onsi/gomega"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenItems are the items every output format is checked with: an article
+// with the usual details, a video without them, and one whose title and URL
+// need escaping.
+func goldenItems() []api.Item {
+	at := func(s string) api.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return api.Time{Time: t}
+	}
+
+	return []api.Item{
+		{
+			ItemID:        1001,
+			ResolvedId:    1001,
+			GivenURL:      "https://example.com/go-memory-model",
+			ResolvedURL:   "https://example.com/go-memory-model",
+			GivenTitle:    "The Go Memory Model",
+			ResolvedTitle: "The Go Memory Model",
+			Favorite:      1,
+			Excerpt:       "What a goroutine is guaranteed to observe.",
+			IsArticle:     1,
+			WordCount:     6200,
+			TimeToRead:    28,
+			Lang:          "en",
+			Tags: map[string]map[string]interface{}{
+				"go":          {"item_id": "1001", "tag": "go"},
+				"concurrency": {"item_id": "1001", "tag": "concurrency"},
+			},
+			Annotations: []api.Annotation{
+				{AnnotationID: "a1", ItemID: 1001, Quote: "Don't be clever.", CreatedAt: "2021-03-04 10:00:00"},
+				{AnnotationID: "a2", ItemID: 1001, Quote: "Programs that modify data, \"must\" serialize access.", CreatedAt: "2021-03-05 11:30:00"},
+			},
+			SortId:        0,
+			TimeAdded:     at("2021-03-01T09:00:00Z"),
+			TimeUpdated:   at("2021-03-05T11:30:00Z"),
+			TimeFavorited: at("2021-03-02T08:00:00Z"),
+		},
+		{
+			ItemID:      1002,
+			ResolvedId:  1002,
+			GivenURL:    "https://video.example.com/watch?v=abc",
+			ResolvedURL: "https://video.example.com/watch?v=abc",
+			GivenTitle:  "A talk",
+			Status:      api.ItemStatusArchived,
+			HasVideo:    api.ItemMediaAttachmentIsMedia,
+			SortId:      1,
+			TimeAdded:   at("2021-02-14T18:45:00Z"),
+			TimeUpdated: at("2021-02-20T07:15:00Z"),
+			TimeRead:    at("2021-02-20T07:15:00Z"),
+		},
+		{
+			ItemID:        1003,
+			ResolvedId:    1003,
+			GivenURL:      "https://example.org/search?q=a&b=<c>",
+			ResolvedURL:   "https://example.org/search?q=a&b=<c>",
+			ResolvedTitle: `Tom & Jerry's "<best>" episodes — ranked`,
+			Excerpt:       "Cats <em>and</em> mice & more.",
+			IsArticle:     1,
+			WordCount:     900,
+			Tags: map[string]map[string]interface{}{
+				"fun": {"item_id": "1003", "tag": "fun"},
+			},
+			SortId:      2,
+			TimeAdded:   at("2020-12-31T23:59:59Z"),
+			TimeUpdated: at("2020-12-31T23:59:59Z"),
+		},
+	}
+}
+
+// goldenHighlights groups the highlights of goldenItems as the highlights
+// command does.
+func goldenHighlights() []highlightedArticle {
+	var articles []highlightedArticle
+	for _, item := range goldenItems() {
+		if len(item.Annotations) == 0 {
+			continue
+		}
+		articles = append(articles, highlightedArticle{
+			ItemID:     item.ItemID,
+			Title:      item.Title(),
+			URL:        item.URL(),
+			Tags:       item.TagNames(),
+			Highlights: item.Annotations,
+		})
+	}
+	return articles
+}
+
+// writeItemTemplates writes items with the default and the --plain item
+// templates of the list command, showing dates in style.
+func writeItemTemplates(w io.Writer, items []api.Item, style string) error {
+	err := setDateStyle(style)
+	if err != nil {
+		return err
+	}
+	defer setDateStyle("")
+
+	for _, tmpl := range []*template.Template{defaultItemTemplate, plainItemTemplate} {
+		for _, item := range items {
+			err := tmpl.Execute(w, item)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, "\n")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestGolden(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+	links := hyperlinks
+	hyperlinks = func() bool { return false }
+	defer func() { hyperlinks = links }()
+
+	items := goldenItems()
+	notes := map[int]string{1001: "Reread the section on channels."}
+
+	tests := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"list.txt", func(w io.Writer) error { return writeItemTemplates(w, items, "") }},
+		{"list-iso.txt", func(w io.Writer) error { return writeItemTemplates(w, items, "iso") }},
+		{"digest.html", func(w io.Writer) error {
+			return digestTemplate.Execute(w, struct {
+				Title string
+				Items []api.Item
+			}{"Pocket 2021-03-06", items})
+		}},
+		{"export.json", func(w io.Writer) error { return writeJSONBackup(w, items) }},
+		{"export.md", func(w io.Writer) error {
+			for _, item := range items {
+				err := library.WriteMarkdown(w, item, notes[item.ItemID])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"export.org", func(w io.Writer) error {
+			for _, item := range items {
+				err := library.WriteOrg(w, item, notes[item.ItemID])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"wallabag.json", func(w io.Writer) error { return writeWallabag(w, items) }},
+		{"omnivore.json", func(w io.Writer) error { return writeOmnivore(w, items) }},
+		{"bookmarks.html", func(w io.Writer) error { return writeNetscapeBookmarks(w, items) }},
+		{"feed.atom", func(w io.Writer) error { return writeAtomFeed(w, items) }},
+		{"domains.opml", func(w io.Writer) error {
+			return writeOPML(w, []domainFeed{
+				{Domain: "go.dev", Home: "https://go.dev/", Link: feeds.Link{URL: "https://go.dev/blog/feed.atom", Title: "The Go Blog"}},
+				{Domain: "example.org", Home: "https://example.org/", Link: feeds.Link{URL: "https://example.org/rss?a=1&b=2"}},
+			}, time.Date(2021, 3, 6, 12, 0, 0, 0, time.UTC))
+		}},
+		{"feed.json", func(w io.Writer) error { return writeJSONFeed(w, items, "http://localhost:8080/feed.json") }},
+		{"collection.md", func(w io.Writer) error { return writeCollectionMarkdown(w, "Reading", items, notes) }},
+		{"collection.org", func(w io.Writer) error { return writeCollectionOrg(w, "Reading", items, notes) }},
+		{"highlights.md", func(w io.Writer) error { return writeHighlightsMarkdown(w, goldenHighlights()) }},
+		{"highlights.json", func(w io.Writer) error { return writeHighlightsJSON(w, goldenHighlights()) }},
+		{"highlights.csv", func(w io.Writer) error { return writeHighlightsCSV(w, goldenHighlights()) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			RegisterTestingT(t)
+
+			var buf bytes.Buffer
+			Expect(test.write(&buf)).To(Succeed())
+
+			path := filepath.Join("testdata", "golden", test.name+".golden")
+			if *update {
+				Expect(os.MkdirAll(filepath.Dir(path), 0777)).To(Succeed())
+				Expect(os.WriteFile(path, buf.Bytes(), 0666)).To(Succeed())
+			}
+			want, err := os.ReadFile(path)
+			Expect(err).To(BeNil(), "run go test -run TestGolden -update to create it")
+			Expect(buf.String()).To(Equal(string(want)), "run go test -run TestGolden -update if the change is intended")
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/render"
+)
+
+// healthClient asks serve for /status, answering at once if it is running
+// on the same machine.
+var healthClient = &http.Client{Timeout: 5 * time.Second}
+
+// healthReport is what /status of serve and "pocket status --daemon" tell
+// about the daemon and the local state, for monitoring tools to watch.
+type healthReport struct {
+	// Status is "ok", or "failing" if the daemon has failed to sync as many
+	// times in a row as it notifies after.
+	Status string `json:"status"`
+	// Daemon is the status the daemon last wrote, if it ever ran.
+	Daemon *DaemonStatus `json:"daemon"`
+	// LastSync is when the mirror was last synced, by any command.
+	LastSync time.Time `json:"last_sync"`
+	// Queue is the number of actions waiting to be sent.
+	Queue int `json:"queue"`
+	// RateLimit is the tighter of the rate limits last seen.
+	RateLimit rateLimitReport `json:"rate_limit"`
+	// Cache is the size of the article cache.
+	Cache *mirror.ArticleCacheStatus `json:"cache"`
+}
+
+// rateLimitReport is the requests left, -1 if unknown, and when they reset.
+type rateLimitReport struct {
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// newHealthReport reads the report from the files the daemon and the syncs
+// write, without opening the mirror, which the daemon may hold.
+func newHealthReport() (*healthReport, error) {
+	r := &healthReport{Status: "ok", RateLimit: rateLimitReport{Remaining: -1}}
+
+	status, err := readDaemonStatus()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		r.Daemon = status
+		if status.Running && status.Failures >= daemonNotifyFailures {
+			r.Status = "failing"
+		}
+	}
+
+	snapshot := &statusSnapshot{}
+	if err := loadJSONFromFile(statusSnapshotPath(), snapshot); err == nil {
+		r.LastSync = snapshot.Synced
+	}
+
+	r.Queue, err = queueDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	if q, err := loadQuota(); err == nil {
+		r.RateLimit.Remaining, r.RateLimit.Reset = q.remaining(time.Now())
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		return nil, err
+	}
+	// Sizes need no key, even if the articles are encrypted
+	cache := mirror.NewArticleCache(filepath.Join(configDir, "articles"), int64(settings.Cache.MaxMB)<<20)
+	r.Cache, err = cache.Status()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// handleHealthz answers 200 if all is well and 503 if the daemon is failing,
+// without a token, for monitoring tools that cannot send one.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	report, err := newHealthReport()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	health := map[string]string{"status": report.Status}
+	if report.Status != "ok" {
+		health["error"] = report.Daemon.LastError
+		writeJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	report, err := newHealthReport()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// fetchHealthReport asks serve at addr for its /status.
+func fetchHealthReport(addr string) (*healthReport, error) {
+	token, err := serveToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s/status answered %s", addr, resp.Status)
+	}
+	report := &healthReport{}
+	return report, json.NewDecoder(resp.Body).Decode(report)
+}
+
+// commandStatusDaemon shows the report of /status of serve, or the same
+// read here if serve is not running, exiting with status 1 if the daemon
+// is failing.
+func commandStatusDaemon(conf Config) {
+	addr := conf.Listen
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+	report, err := fetchHealthReport(addr)
+	if err != nil {
+		slog.Debug("Could not ask serve for the status; reading it here", "addr", addr, "err", err)
+		report, err = newHealthReport()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+	}
+
+	renderOutput(conf, "status", &render.Result{
+		Value: report,
+		Text: func(w io.Writer) error {
+			printHealthReport(w, report, time.Now())
+			return nil
+		},
+	})
+	if report.Status != "ok" {
+		os.Exit(1)
+	}
+}
+
+func printHealthReport(w io.Writer, r *healthReport, now time.Time) {
+	state := "never run"
+	if d := r.Daemon; d != nil {
+		state = "stopped"
+		if d.Running {
+			state = fmt.Sprintf("running (pid %d), next run %s", d.PID, d.NextRun.Local().Format(time.RFC3339))
+		}
+	}
+	fmt.Fprintf(w, "Daemon:     %s\n", state)
+	if r.Status != "ok" {
+		fmt.Fprintf(w, "Health:     %s after %d failed syncs in a row: %s\n", r.Status, r.Daemon.Failures, r.Daemon.LastError)
+	} else {
+		fmt.Fprintf(w, "Health:     %s\n", r.Status)
+	}
+	if r.LastSync.IsZero() {
+		fmt.Fprintln(w, "Last sync:  never")
+	} else {
+		fmt.Fprintf(w, "Last sync:  %s (%s ago)\n", r.LastSync.Local().Format(time.RFC3339), shortAge(r.LastSync, now))
+	}
+	fmt.Fprintf(w, "Queue:      %d actions\n", r.Queue)
+	switch {
+	case r.RateLimit.Remaining < 0:
+		fmt.Fprintln(w, "Rate limit: unknown")
+	case r.RateLimit.Reset.IsZero():
+		fmt.Fprintf(w, "Rate limit: %d requests left\n", r.RateLimit.Remaining)
+	default:
+		fmt.Fprintf(w, "Rate limit: %d requests left until %s\n", r.RateLimit.Remaining, r.RateLimit.Reset.Local().Format("15:04"))
+	}
+	if r.Cache != nil {
+		fmt.Fprintf(w, "Cache:      %d articles, %.1f of %.1f MB\n", r.Cache.Articles, float64(r.Cache.Bytes)/(1<<20), float64(r.Cache.MaxBytes)/(1<<20))
+	}
+}
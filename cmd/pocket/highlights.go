@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// localHighlightsPath is the file of the highlights Pocket did not take,
+// kept locally instead and exported and pushed along with its own.
+func localHighlightsPath() string {
+	return filepath.Join(configDir, "highlights.json")
+}
+
+// loadLocalHighlights returns the highlights kept locally by item ID.
+func loadLocalHighlights() (map[int][]api.Annotation, error) {
+	highlights := map[int][]api.Annotation{}
+	err := loadJSONFromFile(localHighlightsPath(), &highlights)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return highlights, nil
+}
+
+// addLocalHighlight keeps a highlight of an item locally, with an ID of its
+// own for Readwise to tell it apart.
+func addLocalHighlight(itemID int, quote string, now time.Time) error {
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	highlights, err := loadLocalHighlights()
+	if err != nil {
+		return err
+	}
+	highlights[itemID] = append(highlights[itemID], api.Annotation{
+		AnnotationID: fmt.Sprintf("local-%d-%d", itemID, now.UnixNano()),
+		ItemID:       itemID,
+		Quote:        quote,
+		CreatedAt:    now.UTC().Format("2006-01-02 15:04:05"),
+	})
+	return saveJSONToFile(localHighlightsPath(), highlights)
+}
+
+// withLocalHighlights returns items with the highlights kept locally added
+// to those from Pocket.
+func withLocalHighlights(items []api.Item) ([]api.Item, error) {
+	highlights, err := loadLocalHighlights()
+	if err != nil || len(highlights) == 0 {
+		return items, err
+	}
+	added := make([]api.Item, len(items))
+	for i, item := range items {
+		if local := highlights[item.ItemID]; len(local) > 0 {
+			item.Annotations = append(append([]api.Annotation{}, item.Annotations...), local...)
+		}
+		added[i] = item
+	}
+	return added, nil
+}
+
+// highlightedArticle groups the highlights of a single item.
+type highlightedArticle struct {
+	ItemID     int              `json:"item_id"`
+	Title      string           `json:"title"`
+	URL        string           `json:"url"`
+	Tags       []string         `json:"tags"`
+	Highlights []api.Annotation `json:"highlights"`
+}
+
+func writeHighlightsMarkdown(w io.Writer, articles []highlightedArticle) error {
+	for _, article := range articles {
+		_, err := fmt.Fprintf(w, "## %s\n\n<%s>\n\n", article.Title, article.URL)
+		if err != nil {
+			return err
+		}
+		for _, h := range article.Highlights {
+			_, err := fmt.Fprintf(w, "> %s\n\n", h.Quote)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeHighlightsJSON(w io.Writer, articles []highlightedArticle) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(articles)
+}
+
+// writeHighlightsCSV writes highlights in the CSV format Readwise imports.
+func writeHighlightsCSV(w io.Writer, articles []highlightedArticle) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"Highlight", "Title", "Author", "URL", "Note", "Location", "Date"})
+	if err != nil {
+		return err
+	}
+
+	for _, article := range articles {
+		for _, h := range article.Highlights {
+			err := cw.Write([]string{h.Quote, article.Title, "", article.URL, "", "", h.CreatedAt})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func commandHighlights(conf Config, client *api.Client) {
+	if conf.Push {
+		commandPushHighlights(conf, client)
+		return
+	}
+
+	format := conf.FormatTemplate
+	if format == "" {
+		format = "markdown"
+	}
+
+	var write func(io.Writer, []highlightedArticle) error
+	switch format {
+	case "markdown", "md":
+		write = writeHighlightsMarkdown
+	case "json":
+		write = writeHighlightsJSON
+	case "csv", "readwise":
+		write = writeHighlightsCSV
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown highlights format %q; use \"markdown\", \"json\", or \"csv\"\n", format)
+		os.Exit(1)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:       api.StateAll,
+		Domain:      conf.Domain,
+		Search:      conf.SearchQuery,
+		Tag:         conf.Tag,
+		DetailType:  api.DetailTypeComplete,
+		Annotations: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	items, err = withLocalHighlights(items)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	articles := []highlightedArticle{}
+	for _, item := range items {
+		if len(item.Annotations) == 0 {
+			continue
+		}
+		articles = append(articles, highlightedArticle{
+			ItemID:     item.ItemID,
+			Title:      item.Title(),
+			URL:        item.URL(),
+			Tags:       item.TagNames(),
+			Highlights: item.Annotations,
+		})
+	}
+
+	w, err := createOutput(conf.Out)
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	err = write(w, articles)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func commandHighlight(conf Config, client *api.Client) {
+	quote := strings.Join(conf.Quote, " ")
+	if quote == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		quote = strings.TrimSpace(string(b))
+	}
+	if quote == "" {
+		exitWithError(conf, &usageError{command: "highlight", err: fmt.Errorf("the quote is empty")})
+	}
+
+	// Pocket takes highlights from its own apps, and may refuse them from
+	// others, or not be reachable; they are kept locally then
+	res, err := modifyWithHooks([]*api.Action{api.NewAddAnnotationAction(conf.ItemID, quote)}, client.Modify)
+	var reason string
+	switch {
+	case err != nil && isNetworkError(err):
+		reason = "Pocket is unreachable"
+	case err != nil:
+		exitWithError(conf, err)
+	case len(res.ActionDetails) == 0:
+		reason = "Pocket did not take it"
+	case !res.ActionDetails[0].Success:
+		reason = "Pocket did not take it"
+		if res.ActionDetails[0].Error != "" {
+			reason += ": " + res.ActionDetails[0].Error
+		}
+	}
+	if reason == "" {
+		fmt.Printf("Highlighted item %d in Pocket\n", conf.ItemID)
+		return
+	}
+
+	if err := checkItemExists(client, conf.ItemID); err != nil {
+		exitWithError(conf, err)
+	}
+	if err := addLocalHighlight(conf.ItemID, quote, time.Now()); err != nil {
+		exitWithError(conf, err)
+	}
+	fmt.Printf("Highlighted item %d locally; %s\n", conf.ItemID, reason)
+}
+
+// checkItemExists returns an error unless the item is in Pocket or, while
+// Pocket is unreachable, in the local mirror, so that no highlights are kept
+// of items that are not there.
+func checkItemExists(client *api.Client, itemID int) error {
+	options := &api.RetrieveOption{State: api.StateAll}
+	items, err := retrieveItems(client, options)
+	if err != nil && isNetworkError(err) {
+		if _, statErr := os.Stat(filepath.Join(configDir, "mirror.db")); statErr != nil {
+			return fmt.Errorf("could not check that item %d exists, as Pocket is unreachable and there is no local mirror; run pocket sync first", itemID)
+		}
+		items, err = retrieveMirrorItems(options)
+	}
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.ItemID == itemID {
+			return nil
+		}
+	}
+	return fmt.Errorf("No item %d", itemID)
+}
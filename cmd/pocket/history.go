@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/render"
+)
+
+// The sources of the changes in the history: made by a command here, or
+// seen by a sync, having been made elsewhere.
+const (
+	historyMade = "made"
+	historySeen = "seen"
+)
+
+// changeResurfaced is the change of a snoozed item brought back by sync.
+const changeResurfaced mirror.ChangeKind = "resurfaced"
+
+// historyEntry is a change to an item, as recorded in the history.
+type historyEntry struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source,omitempty"`
+	mirror.Change
+}
+
+// historyPath is the journal of the changes made to items by commands and
+// seen by syncs, one JSON entry per line.
+func historyPath() string {
+	return filepath.Join(configDir, "history.jsonl")
+}
+
+// recordHistory appends changes from source to the history.
+func recordHistory(source string, changes ...mirror.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(historyPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	now := time.Now()
+	for _, c := range changes {
+		if err := enc.Encode(historyEntry{Time: now, Source: source, Change: c}); err != nil {
+			return err
+		}
+	}
+	_, err = f.WriteString(b.String())
+	return err
+}
+
+// actionHistory returns the change made by an action, given its result,
+// which may be nil, for the history; ok is false for actions not recorded.
+func actionHistory(action *api.Action, result *api.ActionResult) (c mirror.Change, ok bool) {
+	switch action.Action {
+	case "tags_add":
+		c = mirror.Change{Kind: mirror.ChangeTagsChanged, ItemID: action.ItemID, TagsAdded: splitTags(action.Tags)}
+	case "tags_remove":
+		c = mirror.Change{Kind: mirror.ChangeTagsChanged, ItemID: action.ItemID, TagsRemoved: splitTags(action.Tags)}
+	default:
+		return actionChange(action, result)
+	}
+	return c, true
+}
+
+// recordActions records the actions made in the history, given their
+// result, which is nil if they were queued. Failing to is only logged.
+func recordActions(actions []*api.Action, res *api.ModifyResult) {
+	changes := []mirror.Change{}
+	for i, action := range actions {
+		var result *api.ActionResult
+		if res != nil && i < len(res.ActionDetails) {
+			result = &res.ActionDetails[i]
+			if !result.Success {
+				continue
+			}
+		}
+		if c, ok := actionHistory(action, result); ok {
+			changes = append(changes, c)
+		}
+	}
+	if err := recordHistory(historyMade, changes...); err != nil {
+		slog.Warn("Could not record the changes in the history", "err", err)
+	}
+}
+
+// readHistory returns the entries of the history about an item.
+func readHistory(itemID int) ([]historyEntry, error) {
+	f, err := os.Open(historyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []historyEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A line cut short by a crash
+			continue
+		}
+		if e.ItemID == itemID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// historyKey identifies a change by its kind, or a single tag added or
+// removed, to tell when a sync sees a change made here.
+func historyKey(kind mirror.ChangeKind, tag string) string {
+	if kind == changeResurfaced {
+		kind = mirror.ChangeUnarchived
+	}
+	return string(kind) + " " + tag
+}
+
+// itemHistory returns the history of an item in order: the changes
+// recorded, leaving out those a sync saw after they were made here, along
+// with the times Pocket keeps of the item being added, archived, and
+// favorited, if the history has no record of them.
+func itemHistory(item *api.Item, entries []historyEntry) []historyEntry {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	history := []historyEntry{}
+	made := map[string]bool{}
+	kinds := map[mirror.ChangeKind]bool{}
+	for _, e := range entries {
+		kinds[e.Kind] = true
+		if e.Kind != mirror.ChangeTagsChanged {
+			key := historyKey(e.Kind, "")
+			if e.Source == historySeen && made[key] {
+				delete(made, key)
+				continue
+			}
+			made[key] = e.Source == historyMade
+			history = append(history, e)
+			continue
+		}
+
+		unseen := func(kind mirror.ChangeKind, tags []string) []string {
+			result := []string{}
+			for _, tag := range tags {
+				key := historyKey(kind, tag)
+				if e.Source == historySeen && made[key] {
+					delete(made, key)
+					continue
+				}
+				made[key] = e.Source == historyMade
+				result = append(result, tag)
+			}
+			return result
+		}
+		e.TagsAdded = unseen("+", e.TagsAdded)
+		e.TagsRemoved = unseen("-", e.TagsRemoved)
+		if len(e.TagsAdded)+len(e.TagsRemoved) > 0 {
+			history = append(history, e)
+		}
+	}
+
+	if item != nil {
+		change := func(kind mirror.ChangeKind) mirror.Change {
+			return mirror.Change{Kind: kind, ItemID: item.ItemID, Title: item.Title(), URL: item.URL()}
+		}
+		if !item.TimeAdded.IsZero() && item.TimeAdded.Unix() > 0 {
+			history = append(history, historyEntry{Time: item.TimeAdded.Time, Change: change(mirror.ChangeAdded)})
+		}
+		if item.Status == api.ItemStatusArchived && item.TimeRead.Unix() > 0 && !kinds[mirror.ChangeArchived] {
+			history = append(history, historyEntry{Time: item.TimeRead.Time, Change: change(mirror.ChangeArchived)})
+		}
+		if item.Favorite == 1 && item.TimeFavorited.Unix() > 0 && !kinds[mirror.ChangeFavorited] {
+			history = append(history, historyEntry{Time: item.TimeFavorited.Time, Change: change(mirror.ChangeFavorited)})
+		}
+		sort.SliceStable(history, func(i, j int) bool { return history[i].Time.Before(history[j].Time) })
+	}
+
+	return history
+}
+
+// describeHistory describes an entry of the history for humans.
+func describeHistory(e historyEntry) string {
+	var s string
+	switch e.Kind {
+	case mirror.ChangeTagsChanged:
+		parts := []string{}
+		if len(e.TagsAdded) > 0 {
+			parts = append(parts, "tagged "+strings.Join(e.TagsAdded, ", "))
+		}
+		if len(e.TagsRemoved) > 0 {
+			parts = append(parts, "untagged "+strings.Join(e.TagsRemoved, ", "))
+		}
+		s = strings.Join(parts, "; ")
+	case mirror.ChangeUnarchived:
+		s = "moved back to the unread list"
+	case changeResurfaced:
+		s = "brought back from snooze"
+	default:
+		s = string(e.Kind)
+	}
+
+	switch e.Source {
+	case historyMade:
+		s += " (here)"
+	case historySeen:
+		s += " (seen by sync)"
+	}
+	return s
+}
+
+func commandHistory(conf Config, client *api.Client) {
+	checkOutput(conf, "history")
+
+	entries, err := readHistory(conf.ItemID)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	var item *api.Item
+	if m, err := openMirror(); err == nil {
+		items, err := m.Items()
+		m.Close()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		for i := range items {
+			if items[i].ItemID == conf.ItemID {
+				item = &items[i]
+			}
+		}
+	}
+
+	history := itemHistory(item, entries)
+	if len(history) == 0 {
+		fmt.Fprintf(os.Stderr, "Nothing is known of item %d; run \"pocket sync\" to mirror it\n", conf.ItemID)
+		os.Exit(1)
+	}
+
+	title, url := "", ""
+	if item != nil {
+		title, url = item.Title(), item.URL()
+	}
+	for _, e := range history {
+		if title == "" {
+			title, url = e.Title, e.URL
+		}
+	}
+	renderOutput(conf, "history", &render.Result{
+		Value: history,
+		Text: func(w io.Writer) error {
+			fmt.Fprintf(w, "[%9d] %s\n<%s>\n\n", conf.ItemID, title, url)
+			for _, e := range history {
+				fmt.Fprintf(w, "  %-16s  %s\n", formatTime(e.Time, "2006-01-02 15:04"), describeHistory(e))
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/hooks"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// hookRunner runs the hooks of the settings, if there are any.
+var hookRunner *hooks.Runner
+
+// actionChange describes the change made by an action given its result, which
+// may be nil; ok is false for actions not triggering events.
+func actionChange(action *api.Action, result *api.ActionResult) (c mirror.Change, ok bool) {
+	kind, ok := actionEvents[action.Action]
+	if !ok {
+		return c, false
+	}
+	c = mirror.Change{Kind: kind, ItemID: action.ItemID, Title: action.Title, URL: action.URL}
+	if result != nil && result.ItemID != 0 {
+		c.ItemID = result.ItemID
+	}
+	return c, true
+}
+
+// runHook runs the hooks of a change, logging failures.
+func runHook(c mirror.Change) {
+	if err := hookRunner.Run(string(c.Kind), c); err != nil {
+		slog.Warn("Hook failed", "event", c.Kind, "item_id", c.ItemID, "err", err)
+	}
+}
+
+// modifyWithHooks sends actions with send, leaving out the deletions vetoed
+// by a pre-delete hook, and runs the hooks of the changes made. The result
+// has an entry for each action given, unsuccessful for those vetoed. Actions
+// queued by send, which returns no result then, count as made. The changes
+// made are recorded in the history.
+func modifyWithHooks(actions []*api.Action, send func(actions ...*api.Action) (*api.ModifyResult, error)) (*api.ModifyResult, error) {
+	if hookRunner == nil {
+		res, err := send(actions...)
+		if err == nil {
+			recordActions(actions, res)
+		}
+		return res, err
+	}
+
+	allowed := []*api.Action{}
+	vetoed := make([]bool, len(actions))
+	for i, action := range actions {
+		if action.Action == "delete" && hookRunner.Wants(hooks.EventPreDelete) {
+			c, _ := actionChange(action, nil)
+			if ok, err := hookRunner.Allow(hooks.EventPreDelete, c); !ok {
+				slog.Warn("Not deleting an item, as a hook vetoed it", "item_id", action.ItemID, "err", err)
+				vetoed[i] = true
+				continue
+			}
+		}
+		allowed = append(allowed, action)
+	}
+
+	res := &api.ModifyResult{Status: 1}
+	if len(allowed) > 0 {
+		var err error
+		res, err = send(allowed...)
+		if err != nil {
+			return res, err
+		}
+		recordActions(allowed, res)
+	}
+
+	for i, action := range allowed {
+		var result *api.ActionResult
+		if res != nil && i < len(res.ActionDetails) {
+			result = &res.ActionDetails[i]
+			if !result.Success {
+				continue
+			}
+		}
+		if c, ok := actionChange(action, result); ok {
+			runHook(c)
+		}
+	}
+
+	if res == nil || len(allowed) == len(actions) {
+		return res, nil
+	}
+	spread := &api.ModifyResult{Status: res.Status, ActionErrors: res.ActionErrors}
+	sent := res.ActionDetails
+	for i := range actions {
+		result := api.ActionResult{}
+		switch {
+		case vetoed[i]:
+			result.Error = "vetoed by a pre-delete hook"
+		case len(sent) > 0:
+			result, sent = sent[0], sent[1:]
+		}
+		spread.ActionDetails = append(spread.ActionDetails, result)
+		spread.ActionResults = append(spread.ActionResults, result.Success)
+	}
+	return spread, nil
+}
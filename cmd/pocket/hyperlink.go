@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// hyperlinkPrograms are the values of $TERM_PROGRAM of terminals showing
+// OSC 8 hyperlinks.
+var hyperlinkPrograms = map[string]bool{
+	"iTerm.app": true,
+	"WezTerm":   true,
+	"vscode":    true,
+	"Hyper":     true,
+	"ghostty":   true,
+}
+
+// hyperlinkTerms are the values of $TERM of terminals showing OSC 8
+// hyperlinks.
+var hyperlinkTerms = map[string]bool{
+	"xterm-kitty":   true,
+	"xterm-ghostty": true,
+	"alacritty":     true,
+	"foot":          true,
+	"foot-extra":    true,
+	"wezterm":       true,
+}
+
+// supportsHyperlinks guesses whether stdout is a terminal showing OSC 8
+// hyperlinks, from the variables terminals set. FORCE_HYPERLINK=1 or 0
+// overrides the guess.
+func supportsHyperlinks() bool {
+	if force := os.Getenv("FORCE_HYPERLINK"); force != "" {
+		return force != "0"
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	// Multiplexers may not pass the links on
+	if os.Getenv("TMUX") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return false
+	}
+
+	if hyperlinkPrograms[os.Getenv("TERM_PROGRAM")] || hyperlinkTerms[os.Getenv("TERM")] {
+		return true
+	}
+	if version, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && version >= 5000 {
+		return true
+	}
+	for _, name := range []string{"WT_SESSION", "KITTY_WINDOW_ID", "KONSOLE_VERSION", "ALACRITTY_WINDOW_ID"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var hyperlinks = sync.OnceValue(supportsHyperlinks)
+
+// hyperlink returns text linking to url on terminals showing OSC 8
+// hyperlinks, or text alone elsewhere.
+func hyperlink(url, text string) string {
+	if url == "" || screenReader || !hyperlinks() || strings.ContainsAny(url, "\x1b\a") {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// idRange is an argument such as "1000-1200", standing for the items with
+// IDs from First to Last.
+type idRange struct {
+	First, Last int
+}
+
+// parseIDRange parses an ID range, reporting false for other arguments.
+func parseIDRange(arg string) (idRange, bool) {
+	first, last, ok := strings.Cut(arg, "-")
+	if !ok {
+		return idRange{}, false
+	}
+	r := idRange{}
+	var err1, err2 error
+	r.First, err1 = strconv.Atoi(first)
+	r.Last, err2 = strconv.Atoi(last)
+	if err1 != nil || err2 != nil || r.First < 0 || r.Last < r.First {
+		return idRange{}, false
+	}
+	return r, true
+}
+
+// selectItems returns the IDs of the items archive and delete act on: those
+// given, where "N-M" stands for the items with IDs from N to M, or those of
+// --state added before --before-id and after --after-id. The items found
+// for ranges and anchors are returned too, to show what is about to change.
+func selectItems(conf Config, client *api.Client) ([]int, []api.Item, error) {
+	ranges := []idRange{}
+	args := []string{}
+	for _, arg := range conf.ItemIDs {
+		if r, ok := parseIDRange(arg); ok {
+			ranges = append(ranges, r)
+		} else {
+			args = append(args, arg)
+		}
+	}
+	if len(ranges) == 0 && conf.BeforeID == 0 && conf.AfterID == 0 {
+		ids, err := readItemIDs(args, os.Stdin)
+		return ids, nil, err
+	}
+
+	ids := []int{}
+	if len(args) > 0 {
+		var err error
+		ids, err = readItemIDs(args, os.Stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	all, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll})
+	if err != nil {
+		return nil, nil, err
+	}
+	anchor := func(id int) (api.Item, error) {
+		for _, item := range all {
+			if item.ItemID == id {
+				return item, nil
+			}
+		}
+		return api.Item{}, fmt.Errorf("no item %d", id)
+	}
+	var before, after api.Item
+	if conf.BeforeID != 0 {
+		if before, err = anchor(conf.BeforeID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if conf.AfterID != 0 {
+		if after, err = anchor(conf.AfterID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	state := &api.RetrieveOption{State: api.State(conf.State)}
+	items := []api.Item{}
+	for _, item := range all {
+		if !mirror.Match(item, state) {
+			continue
+		}
+		in := len(ranges) == 0
+		for _, r := range ranges {
+			if r.First <= item.ItemID && item.ItemID <= r.Last {
+				in = true
+			}
+		}
+		if conf.BeforeID != 0 && !library.AddedBefore(item, before) {
+			in = false
+		}
+		if conf.AfterID != 0 && !library.AddedBefore(after, item) {
+			in = false
+		}
+		if in {
+			items = append(items, item)
+			ids = append(ids, item.ItemID)
+		}
+	}
+	return ids, items, nil
+}
+
+// previewItems shows the items selected by ID ranges or anchors, oldest
+// first, before asking to change them.
+func previewItems(items []api.Item, state string) {
+	sorted := append([]api.Item{}, items...)
+	sort.Slice(sorted, func(i, j int) bool { return library.AddedBefore(sorted[i], sorted[j]) })
+
+	fmt.Printf("%d %s items:\n", len(sorted), state)
+	for _, item := range sorted[:min(len(sorted), domainPreviewTitles)] {
+		fmt.Printf("  [%d] %s (%s)\n", item.ItemID, item.Title(), item.TimeAdded.Format("2006-01-02 15:04"))
+	}
+	if len(sorted) > domainPreviewTitles {
+		fmt.Printf("  and %d more\n", len(sorted)-domainPreviewTitles)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// importTagPrefix starts the tag given to the items added by an import run:
+// restore, copy, migrate, or bridge pull.
+const importTagPrefix = "import:"
+
+// importRunID names an import run from source by the minute it started, as
+// in "2024-06-01-1504-pinboard". A run resumed keeps its name, as it keeps
+// the time it started.
+func importRunID(started time.Time, source string) string {
+	return started.Format("2006-01-02-1504") + "-" + source
+}
+
+// reportImportRun tells how to undo an import run that added items.
+func reportImportRun(runID string, added int) {
+	if added > 0 {
+		fmt.Fprintf(os.Stderr, "Tagged the %d items added %s%s; \"pocket import rollback %s\" deletes them\n",
+			added, importTagPrefix, runID, runID)
+	}
+}
+
+// importRun is an import run found from the tags of the items.
+type importRun struct {
+	ID    string
+	Count int
+}
+
+// importRuns returns the import runs items were added by, the latest first.
+func importRuns(items []api.Item) []importRun {
+	counts := map[string]int{}
+	for _, item := range items {
+		for tag := range item.Tags {
+			if id, ok := strings.CutPrefix(tag, importTagPrefix); ok {
+				counts[id]++
+			}
+		}
+	}
+
+	runs := make([]importRun, 0, len(counts))
+	for id, count := range counts {
+		runs = append(runs, importRun{id, count})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return runs
+}
+
+func commandImport(conf Config, consumerKey string, client *api.Client) {
+	if conf.From != defaultAccount {
+		c, err := accountClient(consumerKey, conf.From)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		client = c
+	}
+
+	if !conf.Rollback {
+		items, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll, DetailType: api.DetailTypeComplete})
+		if err != nil {
+			panic(err)
+		}
+		runs := importRuns(items)
+		if len(runs) == 0 {
+			fmt.Fprintln(os.Stderr, "No items were added by an import run")
+			return
+		}
+		for _, run := range runs {
+			fmt.Printf("%-40s %5d items\n", run.ID, run.Count)
+		}
+		return
+	}
+
+	checkDryRunOutput(conf, "import")
+	runID := strings.TrimPrefix(conf.RunID, importTagPrefix)
+	items, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll, Tag: importTagPrefix + runID, DetailType: api.DetailTypeComplete})
+	if err != nil {
+		panic(err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintf(os.Stderr, "No items tagged %s%s\n", importTagPrefix, runID)
+		return
+	}
+	if conf.DryRun && conf.Output != "text" {
+		actions := make([]*api.Action, len(items))
+		for i, item := range items {
+			actions[i] = api.NewDeleteAction(item.ItemID)
+		}
+		if err := writeDryRun(os.Stdout, conf.Output, actions, items); err != nil {
+			exitWithError(conf, err)
+		}
+		return
+	}
+	previewItems(items, "imported")
+	if conf.DryRun {
+		fmt.Printf("Would delete %d items\n", len(items))
+		return
+	}
+	if !confirmOperation(conf, confirmDelete, len(items), fmt.Sprintf("Delete the %d items added by %s?", len(items), runID)) {
+		os.Exit(1)
+	}
+
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ItemID
+	}
+	summary := newBulkSummary("import rollback")
+	summary.describe(items)
+	modifyItems(client, ids, api.NewDeleteAction, summary)
+	summary.report(conf.Summary)
+}
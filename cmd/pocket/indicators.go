@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+const (
+	// longReadMinutes is the reading time over which an item is a long read.
+	longReadMinutes = 20
+	// staleAge is the age over which an item is stale.
+	staleAge = 365 * 24 * time.Hour
+)
+
+// itemIndicators returns emoji marking an item as a favorite, a video or an
+// image, a long read, or stale, followed by a space, or nothing if none
+// apply.
+func itemIndicators(item api.Item) string {
+	s := ""
+	if item.Favorite == 1 {
+		s += "⭐"
+	}
+	switch {
+	case item.HasVideo == api.ItemMediaAttachmentIsMedia:
+		s += "\U0001f3ac"
+	case item.HasImage == api.ItemMediaAttachmentIsMedia:
+		s += "\U0001f4f7"
+	}
+	if item.ReadingMinutes() > longReadMinutes {
+		s += "\U0001f4da"
+	}
+	if !item.TimeAdded.IsZero() && time.Since(item.TimeAdded.Time) > staleAge {
+		s += "\U0001f4a4"
+	}
+	if s == "" {
+		return ""
+	}
+	return s + " "
+}
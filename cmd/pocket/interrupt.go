@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// shutdown tracks interrupts. Critical sections, such as a Modify batch in
+// flight and the bookkeeping following it, delay exiting until they end.
+var shutdown struct {
+	sync.Mutex
+	// busy is the depth of critical sections entered.
+	busy int
+	// signal is the interrupt received, if any.
+	signal os.Signal
+	// flushers save progress before exiting, last registered first.
+	flushers []func()
+	// exiting is set while the flushers run, which may enter critical
+	// sections of their own.
+	exiting bool
+}
+
+// handleInterrupts makes SIGINT and SIGTERM exit gracefully: at once outside
+// critical sections, or at the end of the one running. Either way the
+// functions registered with onShutdown run first. Another signal while
+// waiting exits right away.
+func handleInterrupts() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for sig := range signals {
+			shutdown.Lock()
+			if shutdown.signal != nil {
+				shutdown.Unlock()
+				os.Exit(exitStatus(sig))
+			}
+			shutdown.signal = sig
+			busy := shutdown.busy > 0
+			shutdown.Unlock()
+
+			if busy {
+				slog.Warn("Interrupted; finishing the batch in flight (interrupt again to quit now)")
+				continue
+			}
+			exitInterrupted()
+		}
+	}()
+}
+
+// exitStatus is the status to exit with after sig, 128 plus the signal
+// number as shells report it.
+func exitStatus(sig os.Signal) int {
+	if sig, ok := sig.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return 1
+}
+
+// exitInterrupted runs the flushers and exits.
+func exitInterrupted() {
+	shutdown.Lock()
+	sig := shutdown.signal
+	flushers := shutdown.flushers
+	shutdown.flushers = nil
+	shutdown.exiting = true
+	shutdown.Unlock()
+
+	for i := len(flushers) - 1; i >= 0; i-- {
+		flushers[i]()
+	}
+	fmt.Fprintln(os.Stderr, "Interrupted")
+	os.Exit(exitStatus(sig))
+}
+
+// onShutdown registers f to save progress when interrupted.
+func onShutdown(f func()) {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+	shutdown.flushers = append(shutdown.flushers, f)
+}
+
+// interruptRequested reports whether an interrupt is waiting for the
+// critical section running to end, for loops within it to stop early.
+func interruptRequested() bool {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+	return shutdown.signal != nil
+}
+
+// beginCritical enters a critical section; interrupts wait for the matching
+// endCritical.
+func beginCritical() {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+	shutdown.busy++
+}
+
+// endCritical leaves a critical section, exiting if it was the outermost
+// one and an interrupt came in the meantime.
+func endCritical() {
+	shutdown.Lock()
+	shutdown.busy--
+	pending := shutdown.busy == 0 && shutdown.signal != nil && !shutdown.exiting
+	shutdown.Unlock()
+
+	if pending {
+		exitInterrupted()
+	}
+}
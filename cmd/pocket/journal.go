@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// journal is the checkpoint of a long import or export, saved after each
+// batch, so that a run stopped by a crash, an interrupt, or a rate limit is
+// resumed with --resume instead of started over.
+type journal struct {
+	Command string `json:"command"`
+	// Run tells the runs of the command apart, as in the file restored or
+	// the accounts copied between.
+	Run     string    `json:"run"`
+	Started time.Time `json:"started"`
+	// Done are the URLs, as keyed by library.URLKey, of the items done.
+	Done map[string]bool `json:"done"`
+
+	mu sync.Mutex
+}
+
+// journalFile returns the file of the journal of a run of command.
+func journalFile(command, run string) string {
+	sum := sha256.Sum256([]byte(run))
+	return filepath.Join(configDir, fmt.Sprintf("journal-%s-%x.json", strings.ReplaceAll(command, " ", "-"), sum[:4]))
+}
+
+// openJournal resumes the journal of a run of command with --resume, or
+// starts a new one, saying so if an unfinished one is overwritten.
+func openJournal(conf Config, command, run string) (*journal, error) {
+	j := &journal{}
+	err := loadJSONFromFile(journalFile(command, run), j)
+	switch {
+	case err == nil && conf.Resume:
+		fmt.Fprintf(os.Stderr, "Resuming the %s started %s: %d items done\n",
+			command, j.Started.Format("2006-01-02 15:04"), len(j.Done))
+		return j, nil
+	case err == nil:
+		fmt.Fprintf(os.Stderr, "pocket: starting over the %s left unfinished %s; pass --resume to continue it instead\n",
+			command, j.Started.Format("2006-01-02 15:04"))
+	case !os.IsNotExist(err):
+		return nil, err
+	case conf.Resume:
+		fmt.Fprintf(os.Stderr, "pocket: no unfinished %s to resume; starting one\n", command)
+	}
+
+	j = &journal{
+		Command: command,
+		Run:     run,
+		Started: time.Now(),
+		Done:    map[string]bool{},
+	}
+	return j, j.save()
+}
+
+func (j *journal) save() error {
+	return saveJSONToFile(journalFile(j.Command, j.Run), j)
+}
+
+// done tells if the item with url was done in an earlier run.
+func (j *journal) done(url string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Done[library.URLKey(url)]
+}
+
+// markDone records the items with urls as done and saves the journal.
+func (j *journal) markDone(urls ...string) {
+	if len(urls) == 0 {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, url := range urls {
+		j.Done[library.URLKey(url)] = true
+	}
+	if err := j.save(); err != nil {
+		logFatal("Could not save the journal", err)
+	}
+}
+
+// markAdded records the items of batch added, all but those failed, as done.
+func (j *journal) markAdded(batch, failed []api.Item) {
+	notAdded := map[string]bool{}
+	for _, item := range failed {
+		notAdded[item.URL()] = true
+	}
+	urls := []string{}
+	for _, item := range batch {
+		if !notAdded[item.URL()] {
+			urls = append(urls, item.URL())
+		}
+	}
+	j.markDone(urls...)
+}
+
+// finish removes the journal of a run done.
+func (j *journal) finish() {
+	if err := os.Remove(journalFile(j.Command, j.Run)); err != nil && !os.IsNotExist(err) {
+		logFatal("Could not remove the journal", err)
+	}
+}
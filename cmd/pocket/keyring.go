@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// cacheKeyName is the name of the key of the local cache in the keyring.
+const cacheKeyName = "cache-key"
+
+// cacheKeyEnv names the variable that gives the key of the local cache, in
+// base64, in place of the keyring, as on machines without one.
+const cacheKeyEnv = "POCKET_CACHE_KEY"
+
+// errKeyNotFound is returned by keyringGet when there is no such secret.
+var errKeyNotFound = errors.New("not found in the keyring")
+
+// cacheKey returns the key of the local cache, creating one in the keyring
+// the first time.
+func cacheKey() ([]byte, error) {
+	encoded := os.Getenv(cacheKeyEnv)
+	if encoded == "" {
+		var err error
+		encoded, err = keyringGet(cacheKeyName)
+		if errors.Is(err, errKeyNotFound) {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			encoded = base64.StdEncoding.EncodeToString(key)
+			err = keyringSet(cacheKeyName, encoded)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get the key of the cache from the keyring: %w", err)
+		}
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("the key of the cache is not base64: %w", err)
+	}
+	return key, nil
+}
+
+// cacheCipher returns the Cipher of the local cache — the mirror, the
+// article text, and the search index — or nil unless cache.encrypt is set.
+var cacheCipher = sync.OnceValues(func() (mirror.Cipher, error) {
+	settings, err := loadSettings()
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Cache.Encrypt {
+		return nil, nil
+	}
+
+	key, err := cacheKey()
+	if err != nil {
+		return nil, err
+	}
+	return mirror.NewAESCipher(key)
+})
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// alfredItem is a result of an Alfred Script Filter, as in
+// https://www.alfredapp.com/help/workflows/inputs/script-filter/json/.
+type alfredItem struct {
+	UID      string `json:"uid"`
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	// Arg is the item ID, passed to the action, "pocket open {query}"
+	Arg string `json:"arg"`
+	// Match is what Alfred filters the results on, with "Alfred filters
+	// results" set
+	Match        string            `json:"match"`
+	QuickLookURL string            `json:"quicklookurl"`
+	Text         map[string]string `json:"text"`
+}
+
+// launcherSubtitle describes an item under its title: its site and tags.
+func launcherSubtitle(item api.Item) string {
+	parts := []string{item.Domain()}
+	for _, tag := range item.TagNames() {
+		parts = append(parts, "#"+tag)
+	}
+	return strings.Join(parts, " ")
+}
+
+func writeAlfredItems(w io.Writer, items []api.Item) error {
+	results := make([]alfredItem, len(items))
+	for i, item := range items {
+		subtitle := launcherSubtitle(item)
+		results[i] = alfredItem{
+			UID:          strconv.Itoa(item.ItemID),
+			Title:        item.Title(),
+			Subtitle:     subtitle,
+			Arg:          strconv.Itoa(item.ItemID),
+			Match:        item.Title() + " " + subtitle,
+			QuickLookURL: item.URL(),
+			Text:         map[string]string{"copy": item.URL(), "largetype": item.Title()},
+		}
+	}
+	return json.NewEncoder(w).Encode(map[string][]alfredItem{"items": results})
+}
+
+// raycastItem is an item of a Raycast List, with the props of List.Item, for
+// an extension to show as it is.
+type raycastItem struct {
+	ID          string              `json:"id"`
+	Title       string              `json:"title"`
+	Subtitle    string              `json:"subtitle"`
+	Keywords    []string            `json:"keywords"`
+	Accessories []map[string]string `json:"accessories"`
+	Actions     []raycastAction     `json:"actions"`
+}
+
+// raycastAction is an action of an item: copying Content, or running pocket
+// with Args, so that opened items are tracked as with "pocket open".
+type raycastAction struct {
+	Title string `json:"title"`
+	// Type is "copy" or "run"
+	Type    string   `json:"type"`
+	Content string   `json:"content,omitempty"`
+	Args    []string `json:"args,omitempty"`
+}
+
+func writeRaycastItems(w io.Writer, items []api.Item) error {
+	results := make([]raycastItem, len(items))
+	for i, item := range items {
+		id := strconv.Itoa(item.ItemID)
+		accessories := []map[string]string{}
+		if minutes := item.ReadingMinutes(); minutes > 0 {
+			accessories = append(accessories, map[string]string{"text": fmt.Sprintf("%d min", minutes)})
+		}
+		accessories = append(accessories, map[string]string{"date": item.TimeAdded.UTC().Format(time.RFC3339)})
+		results[i] = raycastItem{
+			ID:          id,
+			Title:       item.Title(),
+			Subtitle:    launcherSubtitle(item),
+			Keywords:    append([]string{item.Domain()}, item.TagNames()...),
+			Accessories: accessories,
+			Actions: []raycastAction{
+				{Title: "Open in Browser", Type: "run", Args: []string{"open", "--cached", id}},
+				{Title: "Copy URL", Type: "copy", Content: item.URL()},
+				{Title: "Archive", Type: "run", Args: []string{"archive", "--yes", id}},
+			},
+		}
+	}
+	return json.NewEncoder(w).Encode(map[string][]raycastItem{"items": results})
+}
+
+// launcherLine flattens s onto one line, for the launchers reading lines.
+func launcherLine(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r < ' ' }), " ")
+}
+
+// writeRofiItems writes the rows of a rofi script mode, each with the item
+// ID as its info, given back in $ROFI_INFO when it is chosen, and its site
+// and tags to match on.
+func writeRofiItems(w io.Writer, items []api.Item) error {
+	if _, err := io.WriteString(w, "\x00prompt\x1fpocket\n\x00no-custom\x1ftrue\n"); err != nil {
+		return err
+	}
+	for _, item := range items {
+		_, err := fmt.Fprintf(w, "%s\x00info\x1f%d\x1fmeta\x1f%s\n", launcherLine(item.Title()), item.ItemID, launcherLine(launcherSubtitle(item)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDmenuItems writes a line for each item starting with its ID, for
+// dmenu, wofi, fuzzel, or fzf, whose choice "pocket open -" reads.
+func writeDmenuItems(w io.Writer, items []api.Item) error {
+	for _, item := range items {
+		_, err := fmt.Fprintf(w, "%d\t%s\t%s\n", item.ItemID, launcherLine(item.Title()), launcherLine(launcherSubtitle(item)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func commandLauncher(conf Config) {
+	if conf.FormatTemplate == "" {
+		conf.FormatTemplate = "dmenu"
+	}
+	write := map[string]func(io.Writer, []api.Item) error{
+		"alfred":  writeAlfredItems,
+		"raycast": writeRaycastItems,
+		"rofi":    writeRofiItems,
+		"dmenu":   writeDmenuItems,
+	}[conf.FormatTemplate]
+	if write == nil {
+		exitWithError(conf, &usageError{err: fmt.Errorf(`unknown launcher format %q; use "alfred", "raycast", "rofi", or "dmenu"`, conf.FormatTemplate)})
+	}
+
+	// Launchers run this on every keystroke, or at least every time they
+	// are shown, so the items come from the local mirror
+	useCache = true
+
+	// rofi runs the script mode again with the row chosen
+	if conf.FormatTemplate == "rofi" && os.Getenv("ROFI_RETV") == "1" {
+		conf.ItemIDs = []string{os.Getenv("ROFI_INFO")}
+		items, err := itemsByID(conf, nil)
+		if err == nil {
+			err = openItem(items[0])
+		}
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		return
+	}
+
+	items, err := retrieveItems(nil, &api.RetrieveOption{
+		State:  api.State(conf.State),
+		Domain: conf.Domain,
+		Search: conf.SearchQuery,
+		Tag:    conf.Tag,
+		Sort:   api.SortNewest,
+	})
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	if err := write(os.Stdout, items); err != nil {
+		panic(err)
+	}
+}
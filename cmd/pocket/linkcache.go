@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/library"
+)
+
+// linkChecking is the link_check setting, set up in main.
+var linkChecking LinkCheckSettings
+
+// ttl returns how long the outcome of a link check is reused; zero or less
+// checks every link again.
+func (s LinkCheckSettings) ttl() time.Duration {
+	if s.TTL == 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(s.TTL) * time.Hour
+}
+
+// cachedLinkCheck is a link check kept in links.json in the config
+// directory, so that culling again does not request every link again.
+type cachedLinkCheck struct {
+	Status    string                 `json:"status"`
+	OK        bool                   `json:"ok"`
+	FinalURL  string                 `json:"final_url"`
+	Redirects []library.LinkRedirect `json:"redirects,omitempty"`
+	Paywalled bool                   `json:"paywalled,omitempty"`
+	Checked   time.Time              `json:"checked_at"`
+}
+
+func linkCachePath() string {
+	return filepath.Join(configDir, "links.json")
+}
+
+// linkCache is the link checks kept by URL, read once and saved after
+// checking.
+type linkCache struct {
+	sync.Mutex
+	checks  map[string]cachedLinkCheck
+	changed bool
+	hosts   *hostLimiter
+}
+
+// loadLinkCache reads the link checks still fresh. A cache that cannot be
+// read is only logged, and the links checked again.
+func loadLinkCache() *linkCache {
+	cache := &linkCache{checks: map[string]cachedLinkCheck{}, hosts: newHostLimiter(linkChecking)}
+	if linkChecking.ttl() <= 0 {
+		return cache
+	}
+	checks := map[string]cachedLinkCheck{}
+	if err := loadJSONFromFile(linkCachePath(), &checks); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Could not read the link checks", "err", err)
+		}
+		return cache
+	}
+	for url, chk := range checks {
+		if time.Since(chk.Checked) < linkChecking.ttl() {
+			cache.checks[url] = chk
+		} else {
+			cache.changed = true
+		}
+	}
+	return cache
+}
+
+// check checks url as library.CheckLink does, in the turn of its host, unless it
+// was checked within the TTL of link_check. Errors, such as timeouts, are not kept, as they often pass.
+func (c *linkCache) check(url string) library.LinkCheck {
+	c.Lock()
+	cached, ok := c.checks[url]
+	c.Unlock()
+	if ok {
+		return library.LinkCheck{
+			Status: cached.Status, OK: cached.OK, FinalURL: cached.FinalURL, Redirects: cached.Redirects, Paywalled: cached.Paywalled,
+		}
+	}
+
+	done := c.hosts.wait(url)
+	chk := library.CheckLink(url)
+	done()
+	if chk.Err == nil && linkChecking.ttl() > 0 {
+		c.Lock()
+		c.checks[url] = cachedLinkCheck{
+			Status: chk.Status, OK: chk.OK, FinalURL: chk.FinalURL, Redirects: chk.Redirects, Paywalled: chk.Paywalled,
+			Checked: time.Now(),
+		}
+		c.changed = true
+		c.Unlock()
+	}
+	return chk
+}
+
+// save writes the link checks back, dropping those expired.
+func (c *linkCache) save() {
+	c.Lock()
+	defer c.Unlock()
+	if !c.changed {
+		return
+	}
+	if err := saveJSONToFile(linkCachePath(), c.checks); err != nil {
+		slog.Warn("Could not save the link checks", "err", err)
+	}
+	c.changed = false
+}
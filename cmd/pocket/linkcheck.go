@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// redirectChain shows the redirects followed from url, as in
+// "301 https://a → 308 https://b → https://c".
+func redirectChain(chk library.LinkCheck) string {
+	var b strings.Builder
+	for _, r := range chk.Redirects {
+		fmt.Fprintf(&b, "%d %s → ", r.Status, r.URL)
+	}
+	b.WriteString(chk.FinalURL)
+	return b.String()
+}
+
+// parseLinkStatus parses the kinds of links of --link-status, all but those
+// working if empty.
+func parseLinkStatus(s string) (map[string]bool, error) {
+	if s == "" {
+		return map[string]bool{library.LinkBroken: true, library.LinkPaywalled: true, library.LinkRedirected: true}, nil
+	}
+	kinds := map[string]bool{}
+	for _, kind := range splitTags(s) {
+		switch kind {
+		case library.LinkBroken, library.LinkPaywalled, library.LinkRedirected:
+			kinds[kind] = true
+		default:
+			return nil, fmt.Errorf(`unknown link status %q; use "broken", "paywalled", or "redirected"`, kind)
+		}
+	}
+	return kinds, nil
+}
+
+func commandLinkCheck(conf Config, client *api.Client) {
+	checkListingOutput(conf.Output)
+	shown, err := parseLinkStatus(conf.LinkStatus)
+	if err != nil {
+		exitWithError(conf, &usageError{err: err})
+	}
+
+	// In detail, for the tags carried over by --fix-redirects
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.State(conf.State),
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintf(os.Stderr, "No %s items\n", conf.State)
+		return
+	}
+
+	checks := make([]library.LinkCheck, len(items))
+	links := loadLinkCache()
+	newBulk("Checking links", linkChecking.concurrency()).Run(len(items), func(i int) error {
+		checks[i] = links.check(items[i].URL())
+		return nil
+	})
+	links.save()
+
+	counts := map[string]int{}
+	moved := []api.Item{}
+	for i, item := range items {
+		chk := checks[i]
+		kind := chk.Kind()
+		counts[kind]++
+		if kind == library.LinkRedirected && chk.PermanentlyRedirected() && chk.FinalURL != item.URL() {
+			fixed := item
+			fixed.GivenURL = chk.FinalURL
+			moved = append(moved, fixed)
+		}
+		if !shown[kind] {
+			continue
+		}
+		if conf.Output == "ids" {
+			fmt.Println(item.ItemID)
+			continue
+		}
+
+		fmt.Printf("[%d] %s\n", item.ItemID, item.Title())
+		switch {
+		case chk.Err != nil:
+			fmt.Printf("    %s\n", chk.Err)
+		case kind == library.LinkPaywalled:
+			fmt.Printf("    Paywalled or needs a login (%s)\n", chk.Status)
+		case kind == library.LinkBroken:
+			fmt.Printf("    %s\n", chk.Status)
+		default:
+			fmt.Printf("    %s\n", redirectChain(chk))
+		}
+	}
+	if conf.Output != "ids" {
+		fmt.Printf("%d links checked: %d broken, %d paywalled, %d redirected, %d of them permanently\n",
+			len(items), counts[library.LinkBroken], counts[library.LinkPaywalled], counts[library.LinkRedirected], len(moved))
+	}
+
+	if !conf.FixRedirects || len(moved) == 0 {
+		return
+	}
+	if !confirmOperation(conf, confirmDelete, len(moved), fmt.Sprintf("Save the %d items redirected permanently under their new URLs, deleting the old ones?", len(moved))) {
+		return
+	}
+	fixRedirects(conf, client, items, moved)
+}
+
+// fixRedirects saves the items moved under their new URLs, with their
+// tags, favorite and archive state, and time added, then deletes those
+// under the old ones. An item is deleted only once it is saved again.
+func fixRedirects(conf Config, client *api.Client, items, moved []api.Item) {
+	summary := newBulkSummary("linkcheck")
+	summary.describe(items)
+	defer summary.report(conf.Summary)
+
+	beginCritical()
+	defer endCritical()
+
+	adds := make([]*api.Action, len(moved))
+	for i, item := range moved {
+		adds[i] = library.AddAction(item)
+	}
+	results, err := modifyInBatches(client, adds)
+	summary.addResults(adds, results, err)
+	if err != nil {
+		return
+	}
+
+	states := []*api.Action{}
+	deletes := []*api.Action{}
+	for i, r := range results {
+		// Saving a URL already saved gives back the item under it
+		if r.Success && r.ItemID != 0 && r.ItemID != moved[i].ItemID {
+			states = append(states, library.StateActions(moved[i], r.ItemID, nil)...)
+			deletes = append(deletes, api.NewDeleteAction(moved[i].ItemID))
+		}
+	}
+	results, err = modifyInBatches(client, states)
+	summary.addResults(states, results, err)
+	if err != nil {
+		return
+	}
+	results, err = modifyInBatches(client, deletes)
+	summary.addResults(deletes, results, err)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// audioExtensions are those of the audio files a listening queue takes.
+var audioExtensions = map[string]bool{
+	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".oga": true, ".opus": true, ".flac": true, ".wav": true,
+}
+
+// listenable reports whether an item is a video or an audio file, for a
+// media player to play.
+func listenable(item api.Item) bool {
+	if item.HasVideo == api.ItemMediaAttachmentIsMedia {
+		return true
+	}
+	u := item.URL()
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	return audioExtensions[strings.ToLower(path.Ext(u))]
+}
+
+// listenEntry is an item of the listening queue, as printed by --output json.
+type listenEntry struct {
+	ItemID   int    `json:"item_id"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Duration int    `json:"duration_seconds"`
+}
+
+// formatDuration formats seconds as h:mm:ss or m:ss, or "?:??" if unknown.
+func formatDuration(seconds int) string {
+	switch {
+	case seconds <= 0:
+		return "?:??"
+	case seconds >= 3600:
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	default:
+		return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	}
+}
+
+// writeM3U writes the queue as an extended M3U playlist. Entries of unknown
+// length get -1, as the format has it.
+func writeM3U(w io.Writer, queue []listenEntry) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, entry := range queue {
+		duration := entry.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+		// A line break in the title would end the entry
+		title := strings.Join(strings.Fields(entry.Title), " ")
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n%s\n", duration, title, entry.URL)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// listenQueue returns the entries of the queue of items.
+func listenQueue(items []api.Item) []listenEntry {
+	queue := []listenEntry{}
+	for _, item := range items {
+		queue = append(queue, listenEntry{
+			ItemID:   item.ItemID,
+			URL:      item.URL(),
+			Title:    item.Title(),
+			Duration: item.ListenSeconds(),
+		})
+	}
+	return queue
+}
+
+// printListenQueue prints the items with their durations as text, JSON, or
+// their IDs.
+func printListenQueue(output string, items []api.Item) {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(listenQueue(items)); err != nil {
+			panic(err)
+		}
+	case "ids":
+		printItemIDs(items)
+	default:
+		total := 0
+		for _, entry := range listenQueue(items) {
+			total += max(entry.Duration, 0)
+			fmt.Printf("[%9d] %8s  %s\n            <%s>\n", entry.ItemID, formatDuration(entry.Duration), entry.Title, entry.URL)
+		}
+		fmt.Printf("\n%d items, %s in all\n", len(items), formatDuration(total))
+	}
+}
+
+func commandListen(conf Config, client *api.Client) {
+	if conf.Output != "json" {
+		checkListingOutput(conf.Output)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.State(conf.State),
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	queued := []api.Item{}
+	for _, item := range items {
+		if listenable(item) {
+			queued = append(queued, item)
+		}
+	}
+
+	if conf.Playlist != "" {
+		w, err := createOutput(conf.Playlist)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer w.Close()
+		if err := writeM3U(w, listenQueue(queued)); err != nil {
+			exitWithError(conf, err)
+		}
+		if conf.Playlist == "-" && conf.Output == "text" {
+			return
+		}
+	}
+
+	printListenQueue(conf.Output, queued)
+}
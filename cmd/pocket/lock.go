@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// waitForLock makes lockState wait for the other pocket process holding the
+// lock, as --wait does, instead of failing.
+var waitForLock bool
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// stateLock is the lock of this process on the state in the config
+// directory, counting the holders so that it can be taken again while held.
+var stateLock struct {
+	sync.Mutex
+	file    *os.File
+	holders int
+}
+
+func lockPath() string {
+	return filepath.Join(configDir, "pocket.lock")
+}
+
+// lockState takes the lock on the state kept in the config directory: the
+// mirror, with its offline queue and article cache, and the access tokens.
+// One pocket process holds it at a time, so that a sync run by cron and a
+// command run meanwhile do not undo each other's writes. Unless waitForLock
+// is set, it fails at once if another process holds it.
+func lockState() (unlock func(), err error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	if stateLock.holders == 0 {
+		f, err := os.OpenFile(lockPath(), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		err = lockFile(f, waitForLock)
+		if errors.Is(err, errLocked) {
+			holder := tr(msgLockHolder, nil)
+			if b, err := os.ReadFile(lockPath()); err == nil {
+				if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+					holder = tr(msgLockHolderPID, map[string]int{"PID": pid})
+				}
+			}
+			f.Close()
+			return nil, errors.New(tr(msgLocked, map[string]string{"Holder": holder}))
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		// Name the holder for the others
+		if err := f.Truncate(0); err == nil {
+			fmt.Fprintln(f, os.Getpid())
+		}
+		stateLock.file = f
+	}
+	stateLock.holders++
+
+	var once sync.Once
+	return func() { once.Do(releaseState) }, nil
+}
+
+func releaseState() {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	stateLock.holders--
+	if stateLock.holders == 0 {
+		unlockFile(stateLock.file)
+		stateLock.file.Close()
+		stateLock.file = nil
+	}
+}
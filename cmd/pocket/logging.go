@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// setupLogging sends the log to stderr through slog, at the level and in the
+// format chosen by --quiet, --verbose, and --log-format. Messages of the log
+// package go through it too.
+func setupLogging(conf Config) error {
+	level := slog.LevelInfo
+	switch {
+	case conf.Quiet:
+		level = slog.LevelWarn
+	case conf.Verbose:
+		level = slog.LevelDebug
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch conf.LogFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("unknown log format %q; use \"text\" or \"json\"", conf.LogFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// logFatal logs err at the error level and exits with status 1.
+func logFatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/mail"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mailin"
+)
+
+// mailInAllowed tells whether a message may save items: any message, unless
+// From lists the senders allowed, either by the envelope or by the header.
+func mailInAllowed(s MailInSettings, envelope string, msg *mail.Message) bool {
+	if len(s.From) == 0 {
+		return true
+	}
+	senders := []string{envelope}
+	if addrs, err := msg.Header.AddressList("From"); err == nil {
+		for _, addr := range addrs {
+			senders = append(senders, addr.Address)
+		}
+	}
+	for _, allowed := range s.From {
+		for _, sender := range senders {
+			if strings.EqualFold(sender, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveMailIn takes the mail sent to the address of s, saving the URLs in
+// each message with modify, applying the rules of live, until it cannot
+// listen. It is run by the daemon and serve.
+func serveMailIn(s MailInSettings, live *liveSettings, modify func(actions ...*api.Action) error) {
+	if host, _, err := net.SplitHostPort(s.Listen); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			slog.Warn("The mail address is reachable from other machines; anyone can save to your list", "addr", s.Listen)
+		}
+	}
+
+	srv := &mailin.Server{
+		Handle: func(from string, msg *mail.Message) error {
+			if !mailInAllowed(s, from, msg) {
+				slog.Warn("Ignoring mail from a sender not allowed", "from", from)
+				return nil
+			}
+			urls, err := mailin.URLs(msg)
+			if err != nil {
+				return err
+			}
+			rules := live.Get().Rules
+			actions := make([]*api.Action, len(urls))
+			for i, url := range urls {
+				actions[i] = newSaveAction(addRequest{URL: url, Tags: s.Tags}, rules)
+			}
+			if len(actions) == 0 {
+				slog.Info("No URLs in the mail", "from", from)
+				return nil
+			}
+			// A failure has the forwarder try again; Pocket does not save a
+			// URL twice
+			if err := modify(actions...); err != nil {
+				return err
+			}
+			slog.Info("Saved from mail", "from", from, "urls", len(urls))
+			return nil
+		},
+	}
+	slog.Info("Taking mail", "addr", s.Listen)
+	err := srv.ListenAndServe(s.Listen)
+	slog.Error("Stopped taking mail", "addr", s.Listen, "err", err)
+}
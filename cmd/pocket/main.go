@@ -3,161 +3,723 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
-	"net/http"
-	"net/http/httptest"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
-	"github.com/docopt/docopt-go"
 	"github.com/motemen/go-pocket/api"
 	"github.com/motemen/go-pocket/auth"
+	"github.com/motemen/go-pocket/hooks"
+	"github.com/motemen/go-pocket/i18n"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+	"github.com/motemen/go-pocket/library"
 )
 
 var version = "0.1"
 
-var defaultItemTemplate = template.Must(template.New("item").Parse(
-	"[{{.ItemID | printf \"%9d\"}}] ({{.TimeAdded.Format \"Mon, 02 Jan 2006 15:04:05 MST\"}}) {{.Title}}\n<{{.URL}}>",
+// templateFuncs are the functions of item templates.
+var templateFuncs = template.FuncMap{
+	"date":       templateDate,
+	"indicators": itemIndicators,
+	"link":       hyperlink,
+	"highlight":  func(s string) string { return listHighlight(s) },
+}
+
+// listHighlight marks the matches of --search in the titles listed, as the
+// "highlight" function of item templates.
+var listHighlight = func(s string) string { return s }
+
+var defaultItemTemplate = template.Must(template.New("item").Funcs(templateFuncs).Parse(
+	"[{{.ItemID | printf \"%9d\"}}] {{indicators .}}({{date .TimeAdded}}) {{link .URL (highlight .Title)}}\n<{{link .URL .URL}}>",
+))
+
+// plainItemTemplate is the default item template without indicators, for
+// --plain.
+var plainItemTemplate = template.Must(template.New("item").Funcs(templateFuncs).Parse(
+	"[{{.ItemID | printf \"%9d\"}}] ({{date .TimeAdded}}) {{link .URL .Title}}\n<{{link .URL .URL}}>",
 ))
 
 var configDir string
 
+// settingsDir holds config.json and rules.json. It is configDir but under
+// --simulate, which keeps the state apart and not the settings.
+var settingsDir string
+
+// stdin is shared by the prompts, so that answers piped in are not lost in
+// the buffer of an earlier one.
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompter asks for the consumer key and sends the user to authorize pocket.
+var prompter auth.Prompter = &auth.TerminalPrompter{In: stdin}
+
+// The environment can point pocket at another config directory and another
+// API, as the end-to-end tests do with a fake one.
 func init() {
-	usr, err := user.Current()
-	if err != nil {
-		panic(err)
+	configDir = os.Getenv("POCKET_CONFIG_DIR")
+	if configDir == "" {
+		usr, err := user.Current()
+		if err != nil {
+			panic(err)
+		}
+		configDir = defaultConfigDir(usr.HomeDir)
 	}
-
-	configDir = filepath.Join(usr.HomeDir, ".config", "pocket")
-	err = os.MkdirAll(configDir, 0777)
+	err := os.MkdirAll(configDir, 0777)
 	if err != nil {
 		panic(err)
 	}
-}
+	settingsDir = configDir
 
-func CleanURL(url string) string {
-	// HTTPS by default
-	url = strings.ReplaceAll(url, "http://", "https://")
-	// Remove some youtube params
-	url = strings.TrimSuffix(url, "&a")
-	url = strings.ReplaceAll(url, "feature=g-u", "")
-	url = strings.ReplaceAll(url, "feature=youtu.be", "")
-	url = strings.ReplaceAll(url, "feature=youtube_gdata", "")
-	// Deduplicate any double ampersands
-	amps := regexp.MustCompile("&+")
-	url = amps.ReplaceAllString(url, "&")
-	url = strings.TrimRight(url, "&")
-	// Drop trailing slash
-	url = strings.TrimRight(url, "/")
-
-	return url
+	// Also for the Article View API, as pockettest serves both
+	if origin := os.Getenv("POCKET_API_ORIGIN"); origin != "" {
+		api.Origin = origin
+		api.ArticleOrigin = origin
+	}
 }
 
+// Config is the parsed command line; the cli tag of each field names the
+// command, option, or argument it is bound to.
 type Config struct {
-	List    bool `docopt:"list"`
-	Archive bool `docopt:"archive"`
-	Add     bool `docopt:"add"`
-	Delete  bool `docopt:"delete"`
+	List          bool `cli:"list"`
+	Archive       bool `cli:"archive"`
+	ArchiveDomain bool `cli:"archive-domain"`
+	LinkCheck     bool `cli:"linkcheck"`
+	Add           bool `cli:"add"`
+	Delete        bool `cli:"delete"`
+	Export        bool `cli:"export"`
+	Site          bool `cli:"site"`
+	Snapshot      bool `cli:"snapshot"`
+	Restore       bool `cli:"restore"`
+	Backup        bool `cli:"backup"`
+	Copy          bool `cli:"copy"`
+	Migrate       bool `cli:"migrate"`
+	Apply         bool `cli:"apply"`
+	Bridge        bool `cli:"bridge"`
+
+	Highlights bool `cli:"highlights"`
+	Highlight  bool `cli:"highlight"`
+	EPUB       bool `cli:"epub"`
+	PDF        bool `cli:"pdf"`
+	Email      bool `cli:"email"`
+	Feed       bool `cli:"feed"`
+	Stats      bool `cli:"stats"`
+	Top        bool `cli:"top"`
+	DomainList bool `cli:"domains"`
+	TagList    bool `cli:"tags"`
+	Plan       bool `cli:"plan"`
+	Listening  bool `cli:"listen"`
+	Videos     bool `cli:"videos"`
+	Play       bool `cli:"play"`
+	Goals      bool `cli:"goals"`
+	Timeline   bool `cli:"timeline"`
+	Sync       bool `cli:"sync"`
+	TagItem    bool `cli:"tag"`
+	Diff       bool `cli:"diff"`
+	Drift      bool `cli:"drift"`
+	Search     bool `cli:"search"`
+	Read       bool `cli:"read"`
+	Summarize  bool `cli:"summarize"`
+	AutoTag    bool `cli:"autotag"`
+	Note       bool `cli:"note"`
+	Cache      bool `cli:"cache"`
+	Rules      bool `cli:"rules"`
+	Daemon     bool `cli:"daemon"`
+	Serve      bool `cli:"serve"`
+	Clipboard  bool `cli:"watch-clipboard"`
+	NativeHost bool `cli:"native-host"`
+	MCP        bool `cli:"mcp"`
+	TUI        bool `cli:"tui"`
+	Pick       bool `cli:"pick"`
+	Triage     bool `cli:"triage"`
+	Snooze     bool `cli:"snooze"`
+	Prioritize bool `cli:"priority"`
+	History    bool `cli:"history"`
+	Audit      bool `cli:"audit"`
+	Collection bool `cli:"collection"`
+	Queued     bool `cli:"queue"`
+	Trash      bool `cli:"trash"`
+	Import     bool `cli:"import"`
+	OpenItems  bool `cli:"open"`
+	Launcher   bool `cli:"launcher"`
+	Similar    bool `cli:"similar"`
+	Doctor     bool `cli:"doctor"`
+	QR         bool `cli:"qr"`
+	Completion bool `cli:"completion"`
+	Man        bool `cli:"man"`
+	Demo       bool `cli:"demo"`
+
+	// Read items from the local mirror instead of the API
+	Cached bool `cli:"--cached"`
 
 	// Options for list
-	FormatTemplate string `docopt:"-f,--format"`
-	Domain         string `docopt:"-d,--domain"`
-	SearchQuery    string `docopt:"-s,--search"`
-	Tag            string `docopt:"-t,--tag"`
-	Sort           string `docopt:"-o,--sort"`
-	Cull           bool   `docopt:"--cull"`
-	DeleteAll      bool   `docopt:"--delete"`
-
-	// Parameter for archive and delete
-	ItemID int `docopt:"<item-id>"`
-
-	// Options for add
-	URL   string `docopt:"<url>"`
-	Title string `docopt:"--title"`
-	Tags  string `docopt:"--tags"`
+	FormatTemplate string `cli:"--format"`
+	Plain          bool   `cli:"--plain"`
+	Domain         string `cli:"--domain"`
+	SearchQuery    string `cli:"--search"`
+	Tag            string `cli:"--tag"`
+	Lang           string `cli:"--lang"`
+	MinMinutes     int    `cli:"--min-minutes"`
+	MaxMinutes     int    `cli:"--max-minutes"`
+	Sort           string `cli:"--sort"`
+	Cull           bool   `cli:"--cull"`
+	DeleteAll      bool   `cli:"--delete"`
+	Opened         bool   `cli:"--opened-unarchived"`
+
+	// Options for linkcheck
+	FixRedirects bool   `cli:"--fix-redirects"`
+	LinkStatus   string `cli:"--link-status"`
+
+	// Parameter for archive, delete, and tag
+	ItemID   int      `cli:"<item-id>"`
+	ItemIDs  []string `cli:"<item-id>..."`
+	TagNames []string `cli:"<tag>"`
+
+	// Arguments and options for archive-domain, with Yes also for the other
+	// commands asking for confirmation
+	Domains []string `cli:"<domain>..."`
+	Yes     bool     `cli:"--yes"`
+
+	// Options for the commands changing many items
+	Summary     string `cli:"--summary"`
+	MaxAPICalls int    `cli:"--max-api-calls"`
+
+	// Option for restore, copy, and bridge
+	Resume bool `cli:"--resume"`
+
+	// Options for open, with Oldest also for summarize
+	Oldest int  `cli:"--oldest"`
+	Next   bool `cli:"--next"`
+
+	// Option for summarize
+	Refresh bool `cli:"--refresh"`
+
+	// Option for autotag
+	ApplyTags bool `cli:"--apply"`
+
+	// Options for archive and delete
+	BeforeID int `cli:"--before-id"`
+	AfterID  int `cli:"--after-id"`
+
+	// Options for add, with Tags also for watch-clipboard
+	URL      string `cli:"<url>"`
+	Title    string `cli:"--title"`
+	Tags     string `cli:"--tags"`
+	FromTabs string `cli:"--from-tabs"`
+	Profile  string `cli:"--profile"`
+	Queue    bool   `cli:"--queue"`
+
+	// Options for export and snapshot
+	Dir      string `cli:"--dir"`
+	PrintPDF bool   `cli:"--pdf"`
+	Out      string `cli:"--out"`
+	GitDir   string `cli:"--git"`
+
+	// Options for epub
+	ArchiveAfter bool `cli:"--archive"`
+
+	// Options for email
+	AttachEPUB bool   `cli:"--epub"`
+	To         string `cli:"--to"`
+
+	// Options for feed
+	Listen string `cli:"--listen"`
+	// StatusDaemon makes status report on the daemon.
+	StatusDaemon bool `cli:"--daemon"`
+
+	// Options for stats
+	Output string `cli:"--output"`
+	Chart  bool   `cli:"--chart"`
+
+	// Options for top
+	Since string `cli:"--since"`
+	Limit int    `cli:"--limit"`
+
+	// Options for domains
+	By string `cli:"--by"`
+
+	// Options for tags
+	Cloud bool `cli:"--cloud"`
+	Stale bool `cli:"--stale"`
+
+	// Options for plan
+	Minutes int    `cli:"--minutes"`
+	Prefer  string `cli:"--prefer"`
+	TagAs   string `cli:"--tag-as"`
+	Open    bool   `cli:"--open"`
+	Start   string `cli:"--start"`
+
+	// Options for listen
+	Playlist string `cli:"--export"`
+
+	// Options for timeline
+	State      string `cli:"--state"`
+	CountsOnly bool   `cli:"--counts"`
+
+	// Options for restore
+	File     string `cli:"<file>"`
+	Conflict string `cli:"--conflict"`
+
+	// Arguments and options for backup
+	BackupDir  string `cli:"<dir>"`
+	KeepDaily  int    `cli:"--keep-daily"`
+	KeepWeekly int    `cli:"--keep-weekly"`
+
+	// Options for apply and rules
+	DryRun bool `cli:"--dry-run"`
+
+	// Options for copy and migrate, along with To
+	From string `cli:"--from"`
+
+	// Subcommands of bridge, highlights, and queue, and the argument of
+	// bridge
+	Push    bool   `cli:"push"`
+	Pop     bool   `cli:"pop"`
+	Pull    bool   `cli:"pull"`
+	Service string `cli:"<service>"`
+
+	// Options for sync
+	FetchArticles bool `cli:"--articles"`
+	CheckDrift    bool `cli:"--drift"`
+
+	// Subcommands of cache, daemon, and rules
+	Status     bool `cli:"status"`
+	CacheClear bool `cli:"clear"`
+	CacheGC    bool `cli:"gc"`
+	Encrypt    bool `cli:"encrypt"`
+	WordCount  bool `cli:"wordcount"`
+	RulesRun   bool `cli:"run"`
+
+	// Options for daemon
+	Interval string `cli:"--interval"`
+	Install  bool   `cli:"install"`
+	Print    bool   `cli:"--print"`
+
+	// Options for native-host
+	ExtensionID string `cli:"<extension-id>"`
+	Browser     string `cli:"--browser"`
+
+	// Options for search
+	Query   []string `cli:"<query>"`
+	Reindex bool     `cli:"--reindex"`
+	Saved   string   `cli:"--saved"`
+
+	// Arguments and options for note
+	NoteText []string `cli:"<text>"`
+	Clear    bool     `cli:"--clear"`
+
+	// Argument for highlight
+	Quote []string `cli:"<quote>"`
+
+	// Subcommands and arguments of collection, with add, delete, and export
+	// bound to the commands named so
+	Create         bool   `cli:"create"`
+	Remove         bool   `cli:"remove"`
+	Move           bool   `cli:"move"`
+	Show           bool   `cli:"show"`
+	CollectionName string `cli:"<collection>"`
+	Position       int    `cli:"<position>"`
+
+	// Subcommand and argument of import
+	Rollback bool   `cli:"rollback"`
+	RunID    string `cli:"<run-id>"`
+
+	// Subcommand of trash, with restore bound to the command named so
+	Empty bool `cli:"empty"`
+
+	// Arguments for snooze and priority
+	Until    string `cli:"<until>"`
+	Priority string `cli:"<priority>"`
+
+	// Arguments for completion
+	Shell string `cli:"<shell>"`
+
+	// Options for pick
+	Action string `cli:"--action"`
+	Lines  bool   `cli:"--lines"`
+
+	// Options for demo
+	Items int    `cli:"--items"`
+	Seed  int    `cli:"--seed"`
+	Now   string `cli:"--now"`
+
+	// Global options
+	Quiet        bool   `cli:"--quiet"`
+	Verbose      bool   `cli:"--verbose"`
+	LogFormat    string `cli:"--log-format"`
+	Dates        string `cli:"--dates"`
+	Wait         bool   `cli:"--wait"`
+	WaitForQuota bool   `cli:"--wait-for-quota"`
+	Locale       string `cli:"--locale"`
+	ScreenReader bool   `cli:"--screen-reader"`
+	ReadOnly     bool   `cli:"--read-only"`
+	Simulate     string `cli:"--simulate"`
+	LogActions   string `cli:"--log-actions"`
 }
 
 func main() {
-	usage := `A Pocket <getpocket.com> client.
-
-Usage:
-  pocket list [--format=<template>] [--domain=<domain>] [--tag=<tag>] [--search=<query>] [--sort=<sort>] [--cull|--delete]
-  pocket archive <item-id>
-  pocket delete <item-id>
-  pocket add <url> [--title=<title>] [--tags=<tags>]
-
-Options for list:
-  -f, --format <template> A Go template to show items.
-  -d, --domain <domain>   Filter items by its domain when listing.
-  -s, --search <query>    Search query when listing.
-  -t, --tag <tag>         Filter items by a tag when listing.
-  -o, --sort <sort>       Sort items by "newest", "oldest", "title", or "site"
-  --cull                  Open items one by one in a browser and prompt to delete each one
-  --delete                Delete all items retrieved
-
-Options for add:
-  --title <title>         A manually specified title for the article
-  --tags <tags>           A comma-separated list of tags
-`
-	opts, err := docopt.ParseArgs(usage, nil, version)
-	if err != nil {
-		panic(err)
+	if len(os.Args) > 1 && os.Args[1] == completeCommand {
+		runComplete(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "--version" {
+		fmt.Println("pocket " + version)
+		return
 	}
 
-	var conf Config
-	err = opts.Bind(&conf)
+	settings, err := loadSettings()
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "pocket: ignoring aliases and saved searches: %v\n", err)
+		settings = &Settings{}
+	}
+	command, conf := parseCommandLineOrExit(os.Args[1:], settings)
+	if conf.Simulate != "" {
+		stop, err := startSimulation(conf.Simulate)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer stop()
+		settings.simulate()
+	}
+	if len(settings.Hooks) > 0 {
+		hookRunner = hooks.NewRunner(settings.Hooks)
+	}
+	if conf.Output == "json" {
+		defer reportJSONError()
+	}
+	defer exitOnReadOnly(conf, command.Name)
+	if err := setupLogging(conf); err != nil {
+		exitWithError(conf, &usageError{err: err})
+	}
+	screenReader = conf.ScreenReader || settings.ScreenReader
+	api.ReadOnly = conf.ReadOnly || settings.ReadOnly
+	api.NormalizeTitles = settings.NormalizeTitles
+	linkChecking = settings.LinkCheck
+	trashing = settings.Trash
+	tagRoutes = settings.Routes
+	if conf.LogActions != "" {
+		f, err := os.OpenFile(conf.LogActions, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer f.Close()
+		api.ActionLog = f
+	}
+	settings.HTTP.apply()
+	if err := setConfirmPolicy(settings.Confirm); err != nil {
+		exitWithError(conf, err)
+	}
+	if err := setDateStyle(conf.Dates); err != nil {
+		exitWithError(conf, &usageError{err: err})
+	}
+	if err := checkMinutes(conf.MinMinutes, conf.MaxMinutes); err != nil {
+		exitWithError(conf, &usageError{command: command.Name, err: err})
+	}
+	if err := checkSummaryFormat(conf.Summary); err != nil {
+		exitWithError(conf, &usageError{command: command.Name, err: err})
+	}
+	if conf.MaxAPICalls < 0 {
+		exitWithError(conf, &usageError{command: command.Name, err: fmt.Errorf("--max-api-calls must be a positive number of calls")})
+	}
+	// A bare "add" fits the form of --from-tabs, which takes no <url>
+	if command.Name == "add" && conf.URL == "" && conf.FromTabs == "" {
+		exitWithError(conf, &usageError{command: command.Name, err: &localizedError{msgMissingArgument, map[string]string{"Argument": "<url>"}}})
+	}
+	// Servers and the daemon wait for the commands run meanwhile
+	waitForLock = conf.Wait || conf.Daemon || conf.Serve || conf.MCP || conf.NativeHost
+	waitForQuota = conf.WaitForQuota
+	maxAPICalls = conf.MaxAPICalls
+	api.OnRateLimit = saveQuota
+	api.OnGone = saveGoneEndpoint
+	restoreGoneEndpoints()
+	api.OnModify = recordAudit
+	audit.command = command.Name
+	handleInterrupts()
+
+	if conf.Completion {
+		commandCompletion(conf)
+		return
+	}
+	if conf.Man {
+		commandMan(conf)
+		return
+	}
+	if conf.Demo {
+		commandDemo(conf)
+		return
+	}
+	// Before authorizing, to tell whether it is needed
+	if conf.Doctor {
+		commandDoctor(conf)
+		return
+	}
+	// Before authorizing and the notices too, as status lines and prompts
+	// run it without a terminal; Status is set by "cache status" and
+	// "daemon status" as well
+	if command.Name == "status" && conf.StatusDaemon {
+		commandStatusDaemon(conf)
+		return
+	}
+	if command.Name == "status" {
+		commandStatus(conf)
+		return
+	}
+	// Before authorizing, for the machines that are not to queue for those
+	// that are
+	if command.Name == "add" && conf.Queue {
+		commandAddQueued(conf)
+		return
+	}
+	// Not for the servers, which other programs start without showing stderr
+	if !conf.Quiet && !conf.Serve && !conf.MCP && !conf.NativeHost {
+		warnOfNotices(settings)
 	}
 
 	consumerKey := getConsumerKey()
 
-	accessToken, err := restoreAccessToken(consumerKey)
+	client, err := accountClient(consumerKey, defaultAccount)
 	if err != nil {
 		panic(err)
 	}
-
-	client := api.NewClient(consumerKey, accessToken.AccessToken)
+	useCache = conf.Cached
+	langFilter = splitTags(conf.Lang)
+	minutesFilter.Min, minutesFilter.Max = conf.MinMinutes, conf.MaxMinutes
 
 	switch {
+	// First, as its subcommands set the commands they are named after
+	case conf.Collection:
+		commandCollection(conf, client)
+	case conf.Queued:
+		commandQueue(conf, client)
+	case conf.Trash:
+		commandTrash(conf, client)
+	case conf.Import:
+		commandImport(conf, consumerKey, client)
 	case conf.List:
 		commandList(conf, client)
 	case conf.Archive:
 		commandArchive(conf, client)
 	case conf.Delete:
 		commandDelete(conf, client)
+	case conf.ArchiveDomain:
+		commandArchiveDomain(conf, client)
+	case conf.LinkCheck:
+		commandLinkCheck(conf, client)
 	case conf.Add:
 		commandAdd(conf, client)
+	case conf.Export:
+		commandExport(conf, client)
+	case conf.Site:
+		commandSite(conf)
+	case conf.Snapshot:
+		commandSnapshot(conf, client)
+	case conf.Restore:
+		commandRestore(conf, client)
+	case conf.Backup:
+		commandBackup(conf, client)
+	case conf.Copy:
+		commandCopy(conf, consumerKey, client)
+	case conf.Apply:
+		commandApply(conf, client)
+	case conf.Migrate:
+		commandMigrate(conf, consumerKey, client)
+	case conf.Bridge:
+		commandBridge(conf, client)
+	case conf.Highlight:
+		commandHighlight(conf, client)
+	case conf.Highlights:
+		commandHighlights(conf, client)
+	case conf.EPUB:
+		commandEPUB(conf, client)
+	case conf.PDF:
+		commandPDF(conf, client)
+	case conf.Email:
+		commandEmail(conf, client)
+	case conf.Feed:
+		commandFeed(conf, client)
+	case conf.Stats:
+		commandStats(conf, client)
+	case conf.Top:
+		commandTop(conf, client)
+	case conf.DomainList:
+		commandDomains(conf, client)
+	case conf.TagList:
+		commandTags(conf, client)
+	case conf.Plan:
+		commandPlan(conf, client)
+	case conf.Listening:
+		commandListen(conf, client)
+	case conf.Videos:
+		commandVideos(conf, client)
+	case conf.Goals:
+		commandGoals(conf, client)
+	case conf.Timeline:
+		commandTimeline(conf, client)
+	case conf.Sync:
+		commandSync(conf, client)
+	case conf.TagItem:
+		commandTag(conf, client)
+	case conf.Diff:
+		commandDiff(conf, client)
+	case conf.Drift:
+		commandDrift(conf)
+	case conf.Search:
+		commandSearch(conf, client)
+	case conf.OpenItems:
+		commandOpen(conf, client)
+	case conf.Launcher:
+		commandLauncher(conf)
+	case conf.Similar:
+		commandSimilar(conf, client)
+	case conf.Read:
+		commandRead(conf, client)
+	case conf.Summarize:
+		commandSummarize(conf, client)
+	case conf.AutoTag:
+		commandAutoTag(conf, client)
+	case conf.Note:
+		commandNote(conf, client)
+	case conf.Cache:
+		commandCache(conf, client)
+	case conf.Rules:
+		commandRules(conf, client)
+	case conf.Daemon:
+		commandDaemon(conf, client)
+	case conf.Clipboard:
+		commandWatchClipboard(conf, client)
+	case conf.Serve:
+		commandServe(conf, client)
+	case conf.NativeHost:
+		commandNativeHost(conf, client)
+	case conf.MCP:
+		commandMCP(conf, client)
+	case conf.TUI:
+		commandTUI(conf, client)
+	case conf.Pick:
+		commandPick(conf, client)
+	case conf.Triage:
+		commandTriage(conf, client)
+	case conf.Snooze:
+		commandSnooze(conf, client)
+	case conf.Prioritize:
+		commandPriority(conf, client)
+	case conf.History:
+		commandHistory(conf, client)
+	case conf.Audit:
+		commandAudit(conf, client)
+	case conf.QR:
+		commandQR(conf, client)
 	default:
 		panic("Not implemented")
 	}
 }
 
-type bySortID []api.Item
+// useCache makes retrieveItems read from the local mirror.
+var useCache bool
 
-func (s bySortID) Len() int           { return len(s) }
-func (s bySortID) Less(i, j int) bool { return s[i].SortId < s[j].SortId }
-func (s bySortID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+// langFilter makes retrieveItems keep only the items in these languages, as
+// given to --lang.
+var langFilter []string
+
+// retrieveItems retrieves the items matching options, ordered by their sort ID.
+func retrieveItems(client *api.Client, options *api.RetrieveOption) ([]api.Item, error) {
+	if useCache {
+		return retrieveMirrorItems(options)
+	}
+
+	checkBudgetLeft()
+	res, err := client.Retrieve(options)
+	var goneErr *api.GoneError
+	if errors.As(err, &goneErr) {
+		return retrieveInstead(goneErr, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items := res.Items()
+	items = library.FilterByLang(items, langFilter)
+	items = library.FilterByMinutes(items, minutesFilter.Min, minutesFilter.Max)
+	// Pocket leaves out the tags of simple items
+	if options.DetailType == api.DetailTypeComplete {
+		updateTagCache(items, retrievedAll(options))
+	}
+	slog.Debug("Retrieved items", "source", "api", "count", len(items))
+
+	return items, nil
+}
+
+// retrieveMirrorItems retrieves the items matching options from the local
+// mirror.
+func retrieveMirrorItems(options *api.RetrieveOption) ([]api.Item, error) {
+	m, err := openMirror()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	items, err := m.Retrieve(options)
+	if err != nil {
+		return nil, err
+	}
+	items = library.FilterByLang(items, langFilter)
+	items = library.FilterByMinutes(items, minutesFilter.Min, minutesFilter.Max)
+	updateTagCache(items, retrievedAll(options))
+	slog.Debug("Retrieved items", "source", "mirror", "count", len(items))
+	return items, nil
+}
+
+// retrieveInstead retrieves the items from the local mirror once Pocket no
+// longer serves its list, saying so and as of when, or returns goneErr if
+// the mirror has never been synced.
+func retrieveInstead(goneErr *api.GoneError, options *api.RetrieveOption) ([]api.Item, error) {
+	m, err := openMirror()
+	if err != nil {
+		return nil, goneErr
+	}
+	since, err := m.Since()
+	m.Close()
+	if err != nil || since == 0 {
+		return nil, goneErr
+	}
+	fmt.Fprintf(os.Stderr, "%s; showing the local mirror as of %s\n", goneErr, time.Unix(int64(since), 0).Local().Format("2006-01-02 15:04"))
+	return retrieveMirrorItems(options)
+}
+
+// modifyUninterrupted sends actions to Pocket, delaying interrupts until
+// the response is in.
+func modifyUninterrupted(client *api.Client, actions ...*api.Action) (*api.ModifyResult, error) {
+	beginCritical()
+	defer endCritical()
+	return modifyWithHooks(actions, client.Modify)
+}
 
 // confirm asks the user for confirmation. A user must type in "yes" or "no" and
 // then press enter. It has fuzzy matching, so "y", "Y", "yes", "YES", and "Yes" all count as
 // confirmations. If the input is not recognized, it will ask again. The function does not return
 // until it gets a valid response from the user.
 func confirm(s string) bool {
-	reader := bufio.NewReader(os.Stdin)
+	reader := stdin
 
 	for {
-		fmt.Printf("%s [y/n]: ", s)
+		if screenReader {
+			fmt.Printf("%s %s: ", s, tr(msgConfirmSpoken, nil))
+		} else {
+			fmt.Printf("%s %s: ", s, tr(msgConfirmChoices, nil))
+		}
 
 		response, err := reader.ReadString('\n')
 		if err != nil {
@@ -167,150 +729,424 @@ func confirm(s string) bool {
 
 		response = strings.ToLower(strings.TrimSpace(response))
 
-		switch response {
-		case "y", "yes":
+		switch {
+		case response == "y" || response == "yes" || isAnswer(response, msgConfirmYes):
 			return true
-		case "n", "no":
+		case response == "n" || response == "no" || isAnswer(response, msgConfirmNo):
 			return false
-		case "q", "quit":
-			fmt.Fprintln(os.Stderr, "Quitting. Bye!")
+		case response == "q" || response == "quit":
+			fmt.Fprintln(os.Stderr, tr(msgConfirmQuit, nil))
 			os.Exit(1)
 		}
 	}
 }
 
+// isAnswer tells if response is one of the comma-separated answers of m.
+func isAnswer(response string, m *i18n.Message) bool {
+	for _, answer := range strings.Split(tr(m, nil), ",") {
+		if response == answer {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTags splits a comma-separated list of tags, dropping empty ones.
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 func commandList(conf Config, client *api.Client) {
 	options := api.RetrieveOption{
+		State:  api.State(conf.State),
 		Domain: conf.Domain,
 		Search: conf.SearchQuery,
 		Tag:    conf.Tag,
 		Sort:   api.Sort(conf.Sort),
 	}
+	if conf.Sort == sortPriority {
+		options.Sort = api.SortNewest
+	}
+	if conf.Opened {
+		options.State = api.StateUnread
+	}
+	if conf.Output != "text" && conf.Output != "ids" {
+		// The tags, for the tables and JSON
+		options.DetailType = api.DetailTypeComplete
+	}
 
-	res, err := client.Retrieve(&options)
+	items, err := retrieveItems(client, &options)
 	if err != nil {
 		panic(err)
 	}
+	sort.Sort(library.BySortID(items))
+	if conf.Sort == sortPriority {
+		library.SortByPriority(items)
+	}
+	if conf.Opened {
+		if !trackOpened() {
+			fmt.Fprintln(os.Stderr, `Opened items are not recorded; set "track_opened": true in config.json`)
+		}
+		items, err = openedUnread(items)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	checkOutput(conf, "list")
+	if conf.Output != "text" {
+		renderOutput(conf, "list", itemsResult(items))
+		return
+	}
 
 	var itemTemplate *template.Template
 	if conf.FormatTemplate != "" {
-		itemTemplate = template.Must(template.New("item").Parse(conf.FormatTemplate))
+		itemTemplate = template.Must(template.New("item").Funcs(templateFuncs).Parse(conf.FormatTemplate))
+	} else if conf.Plain || screenReader {
+		itemTemplate = plainItemTemplate
 	} else {
 		itemTemplate = defaultItemTemplate
 	}
 
-	items := []api.Item{}
-	for _, item := range res.List {
-		items = append(items, item)
+	// On a terminal, the matches of --search are highlighted, and the part of
+	// the excerpt matching too is shown under the default template
+	excerpts := false
+	if conf.SearchQuery != "" && styledStdout() {
+		listHighlight = func(s string) string { return highlightMatches(s, conf.SearchQuery, true) }
+		excerpts = itemTemplate == defaultItemTemplate
 	}
+
 	if conf.DeleteAll {
-		if confirm(fmt.Sprintf("Really delete %d items?", len(items))) {
+		if confirmOperation(conf, confirmDelete, len(items), fmt.Sprintf("Really delete %d items?", len(items))) {
 			deleteItems := []*api.Action{}
 			for _, item := range items {
 				deleteItems = append(deleteItems, api.NewDeleteAction(item.ItemID))
 			}
-			res, err := client.Modify(deleteItems...)
-			if err != nil {
-				fmt.Printf("%#v, %v\n", res, err)
-			}
+			summary := newBulkSummary("delete")
+			summary.describe(items)
+			results, err := modifyInBatches(client, deleteItems)
+			summary.addResults(deleteItems, results, err)
+			summary.report(conf.Summary)
 		}
 		return
 	}
-	sort.Sort(bySortID(items))
-	seenURLs := map[string]struct{}{}
+	keptFor, detailed := findDuplicatesInDetail(client, &options, items)
+	duplicate := make([]bool, len(items))
+	for i, item := range items {
+		_, duplicate[i] = keptFor[item.ItemID]
+	}
+
+	var checks []library.LinkCheck
+	if conf.Cull {
+		checks = make([]library.LinkCheck, len(items))
+		links := loadLinkCache()
+		newBulk("Checking links", linkChecking.concurrency()).Run(len(items), func(i int) error {
+			if !duplicate[i] {
+				checks[i] = links.check(items[i].URL())
+			}
+			return nil
+		})
+		links.save()
+	}
+
+	// Deletions decided on with --cull are sent also when interrupted,
+	// those of duplicates only once confirmed at the end
+	deletions := &pendingActions{}
+	onShutdown(func() { deletions.send(client) })
+	defer deletions.send(client)
+	duplicates := []int{}
+
 	itemsLen := len(items)
 	for i, item := range items {
-		fmt.Printf("%d/%d ", i+1, itemsLen)
+		if screenReader {
+			fmt.Printf("Item %d of %d: ", i+1, itemsLen)
+		} else {
+			fmt.Printf("%d/%d ", i+1, itemsLen)
+		}
 		err := itemTemplate.Execute(os.Stdout, item)
 		if err != nil {
 			panic(err)
 		}
-		url := CleanURL(item.URL())
-		if _, found := seenURLs[url]; found {
-			fmt.Println("\nItem already seen. Deleting...")
-			action := api.NewDeleteAction(item.ItemID)
-			res, err := client.Modify(action)
-			if err != nil {
-				fmt.Printf("%#v, %v\n", res, err)
+		if excerpts {
+			if snippet := searchSnippet(item.Excerpt, conf.SearchQuery); snippet != "" {
+				fmt.Printf("\n            %s", highlightMatches(snippet, conf.SearchQuery, true))
 			}
+		}
+		if duplicate[i] {
+			fmt.Printf("\nDuplicate of item %d; deleting it at the end.\n", keptFor[item.ItemID])
+			duplicates = append(duplicates, item.ItemID)
 			fmt.Println("")
 			continue
-		} else {
-			seenURLs[url] = struct{}{}
 		}
 		if conf.Cull {
-			chk, err := http.Head(item.URL())
-			if err != nil {
-				fmt.Printf("\nGot an err when HEADing: %s, GETting instead...\n", err.Error())
-				chk, err = http.Get(item.URL())
-				if err != nil {
-					fmt.Printf("\n%s\n", err.Error())
-				}
-			}
-			if err != nil && !strings.HasSuffix(err.Error(), ": EOF") {
-				if body, err := io.ReadAll(chk.Body); err != nil &&
-					(strings.Contains(string(body), "isn't available anymore") ||
-						strings.Contains(string(body), "this page doesn")) {
-					chk.StatusCode = http.StatusNotFound
-					chk.Status = "Not Available"
-				}
-			}
-			if err == nil && chk.StatusCode <= http.StatusPermanentRedirect {
+			chk := checks[i]
+			switch {
+			case chk.Err != nil:
+				fmt.Printf("\n%s\n", chk.Err)
+			case chk.Paywalled:
+				fmt.Printf("\nPaywalled or needs a login (%s)\n", chk.Status)
+			case chk.OK:
 				fmt.Printf(" %s\n", chk.Status)
-				fin := chk.Request.URL.String()
 				openPrompt := "Open?"
-				if fin != item.URL() {
-					openPrompt = fmt.Sprintf("Open %s?", fin)
+				if chk.FinalURL != item.URL() {
+					openPrompt = fmt.Sprintf("Open %s?", chk.FinalURL)
 				}
 				if confirm(openPrompt) {
-					cmd := exec.Command("firefox", "--new-tab", fin)
-					if _, err := cmd.Output(); err != nil {
-						if exitErr, ok := err.(*exec.ExitError); ok {
-							log.Fatalf("Failed to run firefox: %s, %s", err, exitErr.Stderr)
-						}
-						log.Fatalf("Failed to run firefox: %s", err)
+					if err := openInBrowser(chk.FinalURL); err != nil {
+						logFatal("Could not open the item", err)
 					}
 				}
-			} else if (err != nil && !strings.HasSuffix(err.Error(), ": EOF")) || err == nil {
+			default:
 				fmt.Printf("\nStatus was %s\n", chk.Status)
 			}
 			if confirm("Delete?") {
-				action := api.NewDeleteAction(item.ItemID)
-				res, err := client.Modify(action)
-				if err != nil {
-					fmt.Printf("%#v, %v\n", res, err)
+				for _, action := range deleteActions(item.ItemID, time.Now()) {
+					deletions.add(action)
 				}
 			}
 		}
 		fmt.Println("")
 	}
+
+	if len(duplicates) > 0 && confirmOperation(conf, confirmDelete, len(duplicates), fmt.Sprintf("Delete the %d duplicates?", len(duplicates))) {
+		dedupe(conf, client, items, detailed, keptFor, duplicates)
+	}
+}
+
+// dedupe deletes the duplicates found by list, or moves them to the trash,
+// once the copies kept are given the tags and favorite state they are
+// missing. detailed are the
+// items with their tags, by ID.
+func dedupe(conf Config, client *api.Client, items []api.Item, detailed map[int]api.Item, keptFor map[int]int, duplicates []int) {
+	summary := newBulkSummary("dedupe")
+	summary.describe(items)
+
+	merges := library.MergeDuplicateActions(detailed, keptFor, duplicates)
+	results, err := modifyInBatches(client, merges)
+	summary.addResults(merges, results, err)
+	merged := err == nil
+	for _, r := range results {
+		merged = merged && r.Success
+	}
+	if !merged {
+		// The duplicates are kept while their tags may not be elsewhere
+		summary.report(conf.Summary)
+		return
+	}
+
+	actions := []*api.Action{}
+	for _, id := range duplicates {
+		actions = append(actions, deleteActions(id, time.Now())...)
+	}
+	results, err = modifyInBatches(client, actions)
+	summary.addResults(actions, results, err)
+	summary.report(conf.Summary)
+}
+
+// pendingActions collects actions to send together once a command is done,
+// or when it is interrupted.
+type pendingActions struct {
+	sync.Mutex
+	actions []*api.Action
+}
+
+func (p *pendingActions) add(action *api.Action) {
+	p.Lock()
+	defer p.Unlock()
+	p.actions = append(p.actions, action)
+}
+
+// send sends the actions collected so far, reporting an error on stderr.
+func (p *pendingActions) send(client *api.Client) {
+	p.Lock()
+	actions := p.actions
+	p.actions = nil
+	p.Unlock()
+
+	if len(actions) == 0 {
+		return
+	}
+	beginCritical()
+	defer endCritical()
+	if _, err := modifyInBatches(client, actions); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// openInBrowser opens url in the browser named by $BROWSER, or else in the
+// default one.
+func openInBrowser(url string) error {
+	cmd := openCommand(url)
+	if browser := strings.Fields(os.Getenv("BROWSER")); len(browser) > 0 {
+		cmd = exec.Command(browser[0], append(browser[1:], url)...)
+	}
+	name := filepath.Base(cmd.Path)
+	if _, err := cmd.Output(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("Failed to run %s: %s, %s", name, err, exitErr.Stderr)
+		}
+		return fmt.Errorf("Failed to run %s: %s", name, err)
+	}
+	return nil
+}
+
+// readItemIDs returns the item IDs given as arguments, reading those of "-"
+// from r: the first field of each line, as printed by --output ids or
+// "pocket pick --lines".
+func readItemIDs(args []string, r io.Reader) ([]int, error) {
+	ids := []int{}
+	for _, arg := range args {
+		if arg != "-" {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid item ID %q", arg)
+			}
+			ids = append(ids, id)
+			continue
+		}
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			id, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid item ID %q on standard input", fields[0])
+			}
+			ids = append(ids, id)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no item IDs given")
+	}
+	return ids, nil
+}
+
+// modifyItems sends an action for each item in batches, queueing them if
+// Pocket cannot be reached, and counts their outcome in summary. If a batch
+// fails, the rest are not sent.
+func modifyItems(client *api.Client, ids []int, newAction func(int) *api.Action, summary *bulkSummary) error {
+	actions := make([]*api.Action, len(ids))
+	for i, id := range ids {
+		actions[i] = newAction(id)
+	}
+	batches := (len(actions) + modifyBatchSize - 1) / modifyBatchSize
+	checkQuota(batches)
+	sendable := fitBudget(batches, 1)
+	for start := 0; start < len(actions); start += modifyBatchSize {
+		if start/modifyBatchSize == sendable {
+			summary.addResults(actions[start:], nil, errOverBudget)
+			return errOverBudget
+		}
+		batch := actions[start:min(start+modifyBatchSize, len(actions))]
+		res, queued, err := modifyOrQueue(client, batch...)
+		switch {
+		case err != nil:
+			summary.addResults(actions[start:], nil, err)
+			return err
+		case queued:
+			summary.addQueued(len(batch))
+		default:
+			summary.addResults(batch, res.ActionDetails, nil)
+		}
+	}
+	return nil
 }
 
 func commandArchive(conf Config, client *api.Client) {
-	if conf.ItemID != 0 {
-		action := api.NewArchiveAction(conf.ItemID)
-		res, err := client.Modify(action)
-		fmt.Println(res, err)
-	} else {
-		panic("Wrong arguments, need <item-id>")
+	ids, selected, err := selectItems(conf, client)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if selected != nil {
+		if len(ids) == 0 {
+			fmt.Fprintf(os.Stderr, "No %s items selected\n", conf.State)
+			return
+		}
+		previewItems(selected, conf.State)
+	}
+
+	if !confirmOperation(conf, confirmArchive, len(ids), fmt.Sprintf("Archive %d items?", len(ids))) {
+		os.Exit(1)
 	}
+
+	summary := newBulkSummary("archive")
+	modifyItems(client, ids, api.NewArchiveAction, summary)
+	summary.report(conf.Summary)
 }
 
 func commandDelete(conf Config, client *api.Client) {
-	if conf.ItemID != 0 {
-		action := api.NewDeleteAction(conf.ItemID)
-		res, err := client.Modify(action)
-		if err != nil {
-			fmt.Println(res, err)
-		} else {
-			fmt.Printf("Deleted item %d\n", conf.ItemID)
+	ids, selected, err := selectItems(conf, client)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if selected != nil {
+		if len(ids) == 0 {
+			fmt.Fprintf(os.Stderr, "No %s items selected\n", conf.State)
+			return
 		}
+		previewItems(selected, conf.State)
+	}
+
+	if !confirmOperation(conf, confirmDelete, len(ids), fmt.Sprintf("Delete %d items?", len(ids))) {
+		os.Exit(1)
+	}
+
+	summary := newBulkSummary("delete")
+	modifyItems(client, ids, api.NewDeleteAction, summary)
+	summary.report(conf.Summary)
+}
+
+// checkListingOutput exits unless output is one of those of commands
+// listing items.
+func checkListingOutput(output string) {
+	if output != "text" && output != "ids" {
+		fmt.Fprintf(os.Stderr, "Unknown output %q; use \"text\" or \"ids\"\n", output)
+		os.Exit(1)
+	}
+}
+
+// printItemIDs prints the IDs of items one per line, for --output ids.
+func printItemIDs(items []api.Item) {
+	for _, item := range items {
+		fmt.Println(item.ItemID)
+	}
+}
+
+func commandTag(conf Config, client *api.Client) {
+	if conf.ItemID == 0 || len(conf.TagNames) == 0 {
+		panic("Wrong arguments, need <item-id> and <tag>")
+	}
+
+	action := api.NewTagsAddAction(conf.ItemID, conf.TagNames...)
+	res, queued, err := modifyOrQueue(client, action)
+	if err != nil {
+		fmt.Println(res, err)
+	} else if queued {
+		fmt.Printf("Offline; queued tagging item %d until the next sync\n", conf.ItemID)
 	} else {
-		panic("Wrong arguments, need <item-id>")
+		fmt.Printf("Tagged item %d with %s\n", conf.ItemID, strings.Join(conf.TagNames, ", "))
 	}
 }
 
 func commandAdd(conf Config, client *api.Client) {
+	if conf.FromTabs != "" {
+		commandAddTabs(conf, client)
+		return
+	}
 	if conf.URL == "" {
 		panic("Wrong arguments, need <url>")
 	}
@@ -333,35 +1169,49 @@ func getConsumerKey() string {
 	consumerKey, err := ioutil.ReadFile(consumerKeyPath)
 
 	if err != nil {
-		log.Printf("Can't get consumer key: %v\n", err)
-		log.Print("Enter your consumer key (from here https://getpocket.com/developer/apps/): ")
-
-		consumerKey, _, err = bufio.NewReader(os.Stdin).ReadLine()
+		slog.Debug("Could not read the consumer key", "err", err)
+		key, err := prompter.PromptConsumerKey()
 		if err != nil {
 			panic(err)
 		}
 
-		err = ioutil.WriteFile(consumerKeyPath, consumerKey, 0600)
+		err = atomicfile.WriteFile(consumerKeyPath, []byte(key), 0600)
 		if err != nil {
 			panic(err)
 		}
 
-		return string(consumerKey)
+		return key
 	}
 
 	return string(bytes.SplitN(consumerKey, []byte("\n"), 2)[0])
 }
 
-func restoreAccessToken(consumerKey string) (*auth.Authorization, error) {
+func restoreAccessToken(consumerKey, account string) (*auth.Authorization, error) {
 	accessToken := &auth.Authorization{}
-	authFile := filepath.Join(configDir, "auth.json")
+	authFile, err := accountAuthFile(account)
+	if err != nil {
+		return nil, err
+	}
 
-	err := loadJSONFromFile(authFile, accessToken)
+	err = loadJSONFromFile(authFile, accessToken)
+	if err != nil {
+		// Another process may be authorizing; once it is done, its token
+		// is used
+		unlock, lockErr := lockState()
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		defer unlock()
+		err = loadJSONFromFile(authFile, accessToken)
+	}
 
 	if err != nil {
-		log.Println(err)
+		slog.Debug("Could not read the access token; authorizing", "account", account, "err", err)
+		if account != defaultAccount {
+			fmt.Fprintf(os.Stderr, "Authorizing the account %q; log in to it on getpocket.com before visiting this URL:\n", account)
+		}
 
-		accessToken, err = obtainAccessToken(consumerKey)
+		accessToken, err = auth.Authorize(context.Background(), consumerKey, prompter)
 		if err != nil {
 			return nil, err
 		}
@@ -375,45 +1225,27 @@ func restoreAccessToken(consumerKey string) (*auth.Authorization, error) {
 	return accessToken, nil
 }
 
-func obtainAccessToken(consumerKey string) (*auth.Authorization, error) {
-	ch := make(chan struct{})
-	ts := httptest.NewServer(
-		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			if req.URL.Path == "/favicon.ico" {
-				http.Error(w, "Not Found", 404)
-				return
-			}
-
-			w.Header().Set("Content-Type", "text/plain")
-			fmt.Fprintln(w, "Authorized.")
-			ch <- struct{}{}
-		}))
-	defer ts.Close()
-
-	redirectURL := ts.URL
-
-	requestToken, err := auth.ObtainRequestToken(consumerKey, redirectURL)
-	if err != nil {
-		return nil, err
+// createOutput creates the file at path for writing, or returns stdout if
+// path is empty or "-".
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
 	}
-
-	url := auth.GenerateAuthorizationURL(requestToken, redirectURL)
-	fmt.Println(url)
-
-	<-ch
-
-	return auth.ObtainAccessToken(consumerKey, requestToken)
+	return os.Create(path)
 }
 
-func saveJSONToFile(path string, v interface{}) error {
-	w, err := os.Create(path)
-	if err != nil {
-		return err
-	}
+type nopWriteCloser struct {
+	io.Writer
+}
 
-	defer w.Close()
+func (nopWriteCloser) Close() error { return nil }
 
-	return json.NewEncoder(w).Encode(v)
+// saveJSONToFile writes v to the file at path, replacing it at once so that
+// other processes never read it half written, nor does a crash leave it so.
+func saveJSONToFile(path string, v interface{}) error {
+	return atomicfile.Write(path, 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
 }
 
 func loadJSONFromFile(path string, v interface{}) error {
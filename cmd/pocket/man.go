@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// roffEscape escapes text for roff, so that backslashes, hyphens, and lines
+// starting with a dot or quote come out as written.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func manHeader(b *strings.Builder, name, summary string) {
+	fmt.Fprintf(b, ".TH %s 1 \"\" \"pocket %s\" \"User Commands\"\n", strings.ToUpper(name), version)
+	fmt.Fprintf(b, ".SH NAME\n%s \\- %s\n", roffEscape(name), roffEscape(strings.ToLower(summary[:1])+summary[1:]))
+}
+
+// manOption renders an option as a tagged paragraph.
+func manOption(b *strings.Builder, option optionSpec) {
+	term := `\fB` + roffEscape(option.Long) + `\fR`
+	if option.Short != "" {
+		term = `\fB` + roffEscape(option.Short) + `\fR, ` + term
+	}
+	if option.Arg != "" {
+		term += ` \fI` + roffEscape(option.Arg) + `\fR`
+	}
+	help := option.Help
+	if option.Default != "" {
+		help += fmt.Sprintf(" (default: %s)", option.Default)
+	}
+	fmt.Fprintf(b, ".TP\n%s\n%s\n", term, roffEscape(help))
+}
+
+// manPage renders the manual page of pocket itself, listing the commands.
+func manPage() string {
+	var b strings.Builder
+	manHeader(&b, "pocket", "A client for Pocket <getpocket.com>")
+
+	b.WriteString(".SH SYNOPSIS\n.B pocket\n.I command\n[\\fIoptions\\fR]\n")
+
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString("Manages the items saved to Pocket from the command line. " +
+		"Commands reading items use the Pocket API, or with \\fB\\-\\-cached\\fR the local mirror updated by \\fBpocket sync\\fR.\n")
+
+	b.WriteString(".SH COMMANDS\n")
+	for _, command := range commandSpecs {
+		fmt.Fprintf(&b, ".TP\n.B %s\n%s. See \\fBpocket\\-%s\\fR(1).\n", roffEscape(command.Name), roffEscape(command.Summary), roffEscape(command.Name))
+	}
+
+	b.WriteString(".SH GLOBAL OPTIONS\n")
+	for _, option := range globalOptions {
+		manOption(&b, option)
+	}
+
+	b.WriteString(".SH EXIT STATUS\n")
+	for _, status := range []struct{ code, help string }{
+		{"0", "Success"},
+		{"1", "An error"},
+		{"2", "A mistake on the command line"},
+		{"130, 143", "Interrupted by SIGINT or SIGTERM, after the batch of changes in flight was sent"},
+	} {
+		fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", status.code, roffEscape(status.help))
+	}
+
+	b.WriteString(".SH FILES\n")
+	for _, file := range []struct{ name, help string }{
+		{"~/.config/pocket/consumer_key", "The consumer key of the Pocket application"},
+		{"~/.config/pocket/auth.json", "The access token"},
+		{"~/.config/pocket/config.json", "Settings: SMTP, reading goals, the article cache size, rules, notifications, webhooks, and aliases"},
+		{"~/.config/pocket/mirror.db", "The local mirror"},
+		{"~/.config/pocket/queue/", "Actions queued while Pocket was unreachable, or with add --queue, one journal per machine syncing the directory"},
+		{"~/.config/pocket/tags.json", "The tags in use, for completion and the TUI, updated as items are retrieved"},
+		{"~/.config/pocket/version_check.json", "The notices about this release last fetched, checked again daily"},
+		{"~/.config/pocket/journal-*.json", "The progress of a restore, copy, or bridge run, saved after each batch for --resume, removed once it is done"},
+		{"~/.config/pocket/quota.json", "The API rate limits as of the last response, checked before large operations"},
+		{"~/.config/pocket/pocket.lock", "Held by the pocket process using the local mirror or authorizing, naming it"},
+	} {
+		fmt.Fprintf(&b, ".TP\n.I %s\n%s\n", roffEscape(file.name), roffEscape(file.help))
+	}
+
+	return b.String()
+}
+
+// commandManPage renders the manual page of a command.
+func commandManPage(command commandSpec) string {
+	var b strings.Builder
+	manHeader(&b, "pocket-"+command.Name, command.Summary)
+
+	b.WriteString(".SH SYNOPSIS\n")
+	for i, form := range command.Forms {
+		if i > 0 {
+			b.WriteString(".br\n")
+		}
+		fmt.Fprintf(&b, ".B pocket\n%s\n", roffEscape(form))
+	}
+
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString(roffEscape(command.Summary) + ".\n")
+	if command.Description != "" {
+		b.WriteString(".PP\n" + roffEscape(command.Description) + "\n")
+	}
+
+	if len(command.Args) > 0 {
+		b.WriteString(".SH ARGUMENTS\n")
+		for _, arg := range command.Args {
+			fmt.Fprintf(&b, ".TP\n.I %s\n%s\n", roffEscape(arg.Name), roffEscape(arg.Help))
+		}
+	}
+
+	if options := command.options(); len(options) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for _, option := range options {
+			manOption(&b, option)
+		}
+	}
+
+	b.WriteString(".SH SEE ALSO\n\\fBpocket\\fR(1)\n")
+
+	return b.String()
+}
+
+// commandMan writes the manual pages, pocket.1 and pocket-<command>.1.
+func commandMan(conf Config) {
+	pages := map[string]string{"pocket.1": manPage()}
+	for _, command := range commandSpecs {
+		pages["pocket-"+command.Name+".1"] = commandManPage(command)
+	}
+
+	err := os.MkdirAll(conf.Dir, 0777)
+	if err != nil {
+		panic(err)
+	}
+	for name, page := range pages {
+		err := os.WriteFile(filepath.Join(conf.Dir, name), []byte(page), 0644)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	fmt.Printf("Wrote %d manual pages to %s\n", len(pages), conf.Dir)
+}
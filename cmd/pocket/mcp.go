@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mcp"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// mcpItem is the summary of an item given to assistants.
+type mcpItem struct {
+	ItemID  int      `json:"item_id"`
+	Title   string   `json:"title"`
+	URL     string   `json:"url"`
+	Domain  string   `json:"domain"`
+	Tags    []string `json:"tags,omitempty"`
+	Added   string   `json:"added"`
+	Status  string   `json:"status"`
+	Minutes int      `json:"minutes,omitempty"`
+	Excerpt string   `json:"excerpt,omitempty"`
+}
+
+func newMCPItem(item api.Item) mcpItem {
+	status := "unread"
+	if item.Status == api.ItemStatusArchived {
+		status = "archived"
+	}
+	return mcpItem{
+		ItemID:  item.ItemID,
+		Title:   item.Title(),
+		URL:     item.URL(),
+		Domain:  item.Domain(),
+		Tags:    item.TagNames(),
+		Added:   item.TimeAdded.Format("2006-01-02"),
+		Status:  status,
+		Minutes: item.ReadingMinutes(),
+		Excerpt: item.Excerpt,
+	}
+}
+
+func mcpItemsJSON(items []api.Item) (string, error) {
+	summaries := make([]mcpItem, len(items))
+	for i, item := range items {
+		summaries[i] = newMCPItem(item)
+	}
+	data, err := json.MarshalIndent(summaries, "", "  ")
+	return string(data), err
+}
+
+func withMirrorItems(f func(m *mirror.Mirror) ([]api.Item, error)) (string, error) {
+	m, err := openMirror()
+	if err != nil {
+		return "", err
+	}
+	defer m.Close()
+
+	items, err := f(m)
+	if err != nil {
+		return "", err
+	}
+	return mcpItemsJSON(items)
+}
+
+func findMirrorItem(itemID int) (*api.Item, error) {
+	m, err := openMirror()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.ItemID == itemID {
+			return &item, nil
+		}
+	}
+	return nil, fmt.Errorf("item %d is not in the local mirror", itemID)
+}
+
+func schema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func prop(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+// newMCPServer creates the MCP server exposing the library. Reads use the
+// local mirror; changes go through the client, or the offline queue.
+func newMCPServer(client *api.Client, rules []Rule) *mcp.Server {
+	s := mcp.NewServer("pocket", version)
+
+	s.AddTool(mcp.Tool{
+		Name:        "search_items",
+		Description: `Full-text search of saved items, best matches first. Supports "quoted phrases", tag:<tag>, and domain:<domain>.`,
+		InputSchema: schema(map[string]interface{}{
+			"query": prop("string", "The search query"),
+			"limit": prop("integer", "Maximum number of items to return (default 20)"),
+		}, "query"),
+		Handler: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				Query string `json:"query"`
+				Limit int    `json:"limit"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			if args.Limit == 0 {
+				args.Limit = 20
+			}
+			return withMirrorItems(func(m *mirror.Mirror) ([]api.Item, error) {
+				hits, _, _, err := searchMirror(m, args.Query, args.Limit)
+				items := make([]api.Item, len(hits))
+				for i, hit := range hits {
+					items[i] = hit.Item
+				}
+				return items, err
+			})
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "list_items",
+		Description: "List saved items, filtered like the Pocket retrieve API.",
+		InputSchema: schema(map[string]interface{}{
+			"state":  prop("string", `"unread" (default), "archive", or "all"`),
+			"tag":    prop("string", "Only items with this tag; _untagged_ for items without tags"),
+			"domain": prop("string", "Only items from this domain"),
+			"search": prop("string", "Only items whose title or URL contains this"),
+			"sort":   prop("string", `"newest" (default), "oldest", "title", or "site"`),
+			"count":  prop("integer", "Maximum number of items to return (default 50)"),
+		}),
+		Handler: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				State  api.State `json:"state"`
+				Tag    string    `json:"tag"`
+				Domain string    `json:"domain"`
+				Search string    `json:"search"`
+				Sort   api.Sort  `json:"sort"`
+				Count  int       `json:"count"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			if args.Count == 0 {
+				args.Count = 50
+			}
+			return withMirrorItems(func(m *mirror.Mirror) ([]api.Item, error) {
+				return m.Retrieve(&api.RetrieveOption{
+					State: args.State, Tag: args.Tag, Domain: args.Domain,
+					Search: args.Search, Sort: args.Sort, Count: args.Count,
+				})
+			})
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "get_article",
+		Description: "Get the text of a saved item's article.",
+		InputSchema: schema(map[string]interface{}{
+			"item_id": prop("integer", "The item ID"),
+		}, "item_id"),
+		Handler: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				ItemID int `json:"item_id"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			item, err := findMirrorItem(args.ItemID)
+			if err != nil {
+				return "", err
+			}
+
+			cache, err := openArticleCache()
+			if err != nil {
+				return "", err
+			}
+			article, err := cache.Get(item.ItemID)
+			if os.IsNotExist(err) {
+				article, err = client.Article(item.URL())
+				if err == nil {
+					err = cache.Put(item.ItemID, article)
+				}
+			}
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf("%s\n<%s>\n\n%s", item.Title(), item.URL(), articleText(article.HTML)), nil
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "save_item",
+		Description: "Save a URL to Pocket.",
+		InputSchema: schema(map[string]interface{}{
+			"url":   prop("string", "The URL to save"),
+			"title": prop("string", "A title, if the page's own is not wanted"),
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+		}, "url"),
+		Handler: func(arguments json.RawMessage) (string, error) {
+			var req addRequest
+			if err := json.Unmarshal(arguments, &req); err != nil {
+				return "", err
+			}
+			_, queued, err := modifyOrQueue(client, newSaveAction(req, rules))
+			if err != nil {
+				return "", err
+			}
+			if queued {
+				return "Pocket is unreachable; the item will be saved with the next sync.", nil
+			}
+			return "Saved.", nil
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "archive_item",
+		Description: "Archive a saved item, marking it as read.",
+		InputSchema: schema(map[string]interface{}{
+			"item_id": prop("integer", "The item ID"),
+		}, "item_id"),
+		Handler: func(arguments json.RawMessage) (string, error) {
+			var args struct {
+				ItemID int `json:"item_id"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			_, queued, err := modifyOrQueue(client, api.NewArchiveAction(args.ItemID))
+			if err != nil {
+				return "", err
+			}
+			if queued {
+				return "Pocket is unreachable; the item will be archived with the next sync.", nil
+			}
+			return "Archived.", nil
+		},
+	})
+
+	return s
+}
+
+// commandMCP serves the Model Context Protocol on stdin and stdout.
+func commandMCP(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		panic(err)
+	}
+
+	err = newMCPServer(client, settings.Rules).Serve(os.Stdin, os.Stdout)
+	if err != nil {
+		logFatal("Serving MCP failed", err)
+	}
+}
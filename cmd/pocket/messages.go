@@ -0,0 +1,149 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/motemen/go-pocket/i18n"
+)
+
+// locales holds the translations of the messages below, of the summaries of
+// the commands, keyed "command.<name>", and of the help of the options,
+// keyed "option.<name>", one catalog per language. Untranslated messages are
+// shown in English.
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalog is the bundle of the translations in locales.
+var catalog = func() *i18n.Bundle {
+	bundle := i18n.NewBundle()
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range files {
+		data, err := locales.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		if err := bundle.LoadJSON(strings.TrimSuffix(f.Name(), ".json"), data); err != nil {
+			panic(err)
+		}
+	}
+	return bundle
+}()
+
+// localizer translates the messages into the language of the locale given by
+// --locale, or else that of $LC_ALL, $LC_MESSAGES, or $LANG.
+var localizer = i18n.NewLocalizer(catalog, envLocale("LC_ALL", "LC_MESSAGES", "LANG"))
+
+// setLocale makes the messages use the locale given, if any.
+func setLocale(locale string) error {
+	if locale == "" {
+		return nil
+	}
+	l := i18n.NewLocalizer(catalog, locale)
+	if lang, _, _ := strings.Cut(strings.ToLower(locale), "_"); l.Language() == "en" && !strings.HasPrefix(lang, "en") {
+		return fmt.Errorf("no translations for the locale %q; use one of en, %s", locale, strings.Join(catalog.Languages(), ", "))
+	}
+	localizer = l
+	return nil
+}
+
+// envLocale returns the locale set by the first of the environment
+// variables named, as in "de_DE.UTF-8".
+func envLocale(names ...string) string {
+	for _, name := range names {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// tr translates m, filling in its template with data.
+func tr(m *i18n.Message, data interface{}) string {
+	return localizer.Localize(m, data)
+}
+
+// localizedError is an error translated as it is shown, once --locale has
+// been parsed.
+type localizedError struct {
+	m    *i18n.Message
+	data interface{}
+}
+
+func (e *localizedError) Error() string {
+	return tr(e.m, e.data)
+}
+
+// commandSummary translates the summary of command.
+func commandSummary(command commandSpec) string {
+	return tr(&i18n.Message{ID: "command." + command.Name, Other: command.Summary}, nil)
+}
+
+// optionHelp translates the help of option.
+func optionHelp(option optionSpec) string {
+	return tr(&i18n.Message{ID: "option." + option.Long, Other: option.Help}, nil)
+}
+
+// Help
+var (
+	msgHelpIntro         = &i18n.Message{ID: "help.intro", Other: "A Pocket <getpocket.com> client."}
+	msgHelpUsage         = &i18n.Message{ID: "help.usage", Other: "Usage:"}
+	msgHelpCommands      = &i18n.Message{ID: "help.commands", Other: "Commands:"}
+	msgHelpAliases       = &i18n.Message{ID: "help.aliases", Other: "Aliases:"}
+	msgHelpArguments     = &i18n.Message{ID: "help.arguments", Other: "Arguments:"}
+	msgHelpOptions       = &i18n.Message{ID: "help.options", Other: "Options:"}
+	msgHelpGlobalOptions = &i18n.Message{ID: "help.global_options", Other: "Global options:"}
+	msgHelpDefault       = &i18n.Message{ID: "help.default", Other: "(default: {{.Default}})"}
+	msgHelpMore          = &i18n.Message{ID: "help.more", Other: `Run "pocket help <command>" for the arguments and options of a command.`}
+	msgHelpDetails       = &i18n.Message{ID: "help.details", Other: `Run "pocket help {{.Command}}" for details.`}
+	msgHelpCommandList   = &i18n.Message{ID: "help.command_list", Other: `Run "pocket help" for the list of commands.`}
+)
+
+// Mistakes on the command line
+var (
+	msgUnknownCommand    = &i18n.Message{ID: "usage.unknown_command", Other: "unknown command {{printf \"%q\" .Command}}"}
+	msgUnknownOption     = &i18n.Message{ID: "usage.unknown_option", Other: "unknown option {{.Option}}"}
+	msgUnknownSubcommand = &i18n.Message{ID: "usage.unknown_subcommand", Other: "unknown subcommand {{printf \"%q\" .Subcommand}}"}
+	msgMissingSubcommand = &i18n.Message{ID: "usage.missing_subcommand", Other: "missing subcommand"}
+	msgOptionNotAccepted = &i18n.Message{ID: "usage.option_not_accepted", Other: "option {{.Option}} is not accepted by {{printf \"%q\" .Command}}"}
+	msgOnlyOneOf         = &i18n.Message{ID: "usage.only_one_of", Other: "only one of {{.Options}} may be given"}
+	msgMissingArgument   = &i18n.Message{ID: "usage.missing_argument", Other: "missing {{.Argument}}"}
+	msgUnexpectedArg     = &i18n.Message{ID: "usage.unexpected_argument", Other: "unexpected argument {{printf \"%q\" .Argument}}"}
+	msgNotANumber        = &i18n.Message{ID: "usage.not_a_number", Other: "{{.Argument}} must be a number: {{printf \"%q\" .Value}}"}
+)
+
+// Prompts
+var (
+	msgConfirmChoices = &i18n.Message{ID: "confirm.choices", Other: "[y/n]"}
+	// The answers accepted, besides those in English
+	msgConfirmYes  = &i18n.Message{ID: "confirm.yes", Other: "y,yes"}
+	msgConfirmNo   = &i18n.Message{ID: "confirm.no", Other: "n,no"}
+	msgConfirmQuit = &i18n.Message{ID: "confirm.quit", Other: "Quitting. Bye!"}
+	// Spelled out for screen readers
+	msgConfirmSpoken = &i18n.Message{ID: "confirm.spoken", Other: "Type yes or no, then press Enter"}
+)
+
+// Screen readers
+var (
+	msgProgress      = &i18n.Message{ID: "screen_reader.progress", Other: "{{.Label}}: {{.Done}} of {{.Total}} done"}
+	msgNarrowDown    = &i18n.Message{ID: "screen_reader.narrow_down", Other: "{{.Count}} items; type words to narrow them down, or press Enter to list them all: "}
+	msgNoMatches     = &i18n.Message{ID: "screen_reader.no_matches", Other: "No items match; try other words."}
+	msgChooseNumbers = &i18n.Message{ID: "screen_reader.choose_numbers", Other: "Type the numbers of the items to choose, as in 1 3-5, then press Enter, or press Enter alone to choose none: "}
+	msgBadNumber     = &i18n.Message{ID: "screen_reader.bad_number", Other: "{{.Number}} is not a number from 1 to {{.Max}}"}
+	msgNoFullScreen  = &i18n.Message{ID: "screen_reader.no_full_screen", Other: "pocket {{.Command}} draws a full-screen interface; with --screen-reader, use pocket triage or pocket pick instead"}
+)
+
+// Errors
+var (
+	msgLocked        = &i18n.Message{ID: "error.locked", Other: "{{.Holder}} is running; try again once it is done, or pass --wait to wait for it"}
+	msgLockHolder    = &i18n.Message{ID: "error.lock_holder", Other: "another pocket process"}
+	msgLockHolderPID = &i18n.Message{ID: "error.lock_holder_pid", Other: "another pocket process (pid {{.PID}})"}
+	msgReadOnly      = &i18n.Message{ID: "error.read_only", Other: "pocket {{.Command}} would change the account, which read-only mode, set by --read-only or \"read_only\" in config.json, refuses"}
+)
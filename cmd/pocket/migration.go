@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// migration is the checkpoint of a migration between accounts, saved after
+// each batch so that a run stopped by a rate limit or an interrupt resumes
+// where it was.
+type migration struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Started time.Time `json:"started"`
+	// Items are every item of the source account when the migration started.
+	Items []api.Item `json:"items"`
+	// Done are the IDs, in the source account, of the items replayed.
+	Done map[int]bool `json:"done"`
+}
+
+// migrationFile returns the checkpoint file of a migration between accounts.
+func migrationFile(from, to string) string {
+	return filepath.Join(configDir, fmt.Sprintf("migrate-%s-%s.json", from, to))
+}
+
+func (m *migration) save() error {
+	return saveJSONToFile(migrationFile(m.From, m.To), m)
+}
+
+// markDone records the items as replayed and saves the checkpoint.
+func (m *migration) markDone(items []api.Item) {
+	for _, item := range items {
+		m.Done[item.ItemID] = true
+	}
+	if err := m.save(); err != nil {
+		logFatal("Could not save the migration checkpoint", err)
+	}
+}
+
+// startMigration resumes the migration between the accounts, or starts one by
+// exporting every item of from.
+func startMigration(conf Config, from *api.Client) (*migration, error) {
+	m := &migration{}
+	err := loadJSONFromFile(migrationFile(conf.From, conf.To), m)
+	if err == nil {
+		fmt.Printf("Resuming the migration started %s: %d of %d items done\n",
+			m.Started.Format("2006-01-02 15:04"), len(m.Done), len(m.Items))
+		return m, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	items, err := retrieveItems(from, &api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m = &migration{
+		From:    conf.From,
+		To:      conf.To,
+		Started: time.Now(),
+		Items:   items,
+		Done:    map[int]bool{},
+	}
+	return m, m.save()
+}
+
+func commandMigrate(conf Config, consumerKey string, client *api.Client) {
+	from, to := fromToClients(conf, "migrate", consumerKey, client)
+
+	m, err := startMigration(conf, from)
+	if err != nil {
+		panic(err)
+	}
+
+	existing, err := retrieveItems(to, &api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+	byURL := map[string]api.Item{}
+	for _, item := range existing {
+		byURL[library.URLKey(item.URL())] = item
+	}
+
+	// Items already in the other account are brought to the same state;
+	// the others are added.
+	adds := []api.Item{}
+	present := []api.Item{}
+	updates := []*api.Action{}
+	for _, item := range m.Items {
+		if m.Done[item.ItemID] {
+			continue
+		}
+		if current, found := byURL[library.URLKey(item.URL())]; found {
+			present = append(present, item)
+			updates = append(updates, library.StateActions(item, current.ItemID, &current)...)
+			continue
+		}
+		adds = append(adds, item)
+		byURL[library.URLKey(item.URL())] = item
+	}
+
+	stopped := func(err error) {
+		fmt.Fprintf(os.Stderr, "Stopped with %d of %d items done: %v\n", len(m.Done), len(m.Items), err)
+		fmt.Fprintf(os.Stderr, "Run \"pocket migrate --from %s --to %s\" again to resume\n", m.From, m.To)
+		os.Exit(1)
+	}
+	onShutdown(func() {
+		fmt.Fprintf(os.Stderr, "Migrated %d of %d items before being interrupted; run again to resume\n", len(m.Done), len(m.Items))
+	})
+
+	beginCritical()
+	_, err = modifyInBatches(to, updates)
+	if err != nil {
+		stopped(err)
+	}
+	m.markDone(present)
+	endCritical()
+
+	added := 0
+	runID := importRunID(m.Started, "migrate")
+	err = addItemsWithState(to, adds, runID, func(batch, failed []api.Item) {
+		added += len(batch) - len(failed)
+		m.markDone(batch)
+	})
+	if err != nil {
+		stopped(err)
+	}
+
+	if err := os.Remove(migrationFile(m.From, m.To)); err != nil {
+		logFatal("Could not remove the migration checkpoint", err)
+	}
+	fmt.Printf("Migrated %d items from %s to %s; this run added %d, updated %d already there, and could not add %d\n",
+		len(m.Items), m.From, m.To, added, len(present), len(adds)-added)
+	reportImportRun(runID, added)
+}
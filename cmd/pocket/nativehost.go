@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// nativeHostName is the name browser extensions use to reach the native
+// messaging host.
+const nativeHostName = "com.github.motemen.go_pocket"
+
+// maxNativeMessageSize bounds the messages accepted from the browser.
+const maxNativeMessageSize = 64 << 20
+
+// nativeRequest is a message from a browser extension.
+type nativeRequest struct {
+	// Action is "save", "archive", or "ping".
+	Action string   `json:"action"`
+	URL    string   `json:"url"`
+	Title  string   `json:"title"`
+	Tags   []string `json:"tags"`
+}
+
+// nativeResponse is the reply to a nativeRequest.
+type nativeResponse struct {
+	OK     bool   `json:"ok"`
+	Queued bool   `json:"queued,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// readNativeMessage reads a message framed as the native messaging protocol
+// specifies: a 32-bit length in native byte order, then that much JSON.
+func readNativeMessage(r io.Reader) ([]byte, error) {
+	var length uint32
+	err := binary.Read(r, binary.NativeEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxNativeMessageSize {
+		return nil, fmt.Errorf("message of %d bytes is too large", length)
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	return data, err
+}
+
+// writeNativeMessage writes v framed like readNativeMessage expects.
+func writeNativeMessage(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, binary.NativeEndian, uint32(len(data)))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// archiveByURL archives the item saved with url, as the given or resolved URL.
+func archiveByURL(client *api.Client, url string) (queued bool, err error) {
+	m, err := openMirror()
+	if err != nil {
+		return false, err
+	}
+	items, err := m.Items()
+	m.Close()
+	if err != nil {
+		return false, err
+	}
+
+	cleaned := library.CleanURL(url)
+	for _, item := range items {
+		for _, u := range []string{item.GivenURL, item.ResolvedURL} {
+			if u != "" && (u == url || library.CleanURL(u) == cleaned) {
+				_, queued, err := modifyOrQueue(client, api.NewArchiveAction(item.ItemID))
+				return queued, err
+			}
+		}
+	}
+
+	return false, errors.New("the page is not in your list, or the mirror needs a sync")
+}
+
+func handleNativeRequest(client *api.Client, rules []Rule, req nativeRequest) nativeResponse {
+	var queued bool
+	var err error
+	switch req.Action {
+	case "ping":
+	case "save":
+		if req.URL == "" {
+			err = errors.New("url is required")
+			break
+		}
+		_, queued, err = modifyOrQueue(client, newSaveAction(addRequest{URL: req.URL, Title: req.Title, Tags: req.Tags}, rules))
+	case "archive":
+		queued, err = archiveByURL(client, req.URL)
+	default:
+		err = fmt.Errorf("unknown action %q", req.Action)
+	}
+
+	if err != nil {
+		return nativeResponse{Error: err.Error()}
+	}
+	return nativeResponse{OK: true, Queued: queued}
+}
+
+// nativeHostManifestPath returns where the browser looks for the manifest of
+// the host, on the current platform.
+func nativeHostManifestPath(browser string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	var dir string
+	switch {
+	case browser == "chrome" && runtime.GOOS == "darwin":
+		dir = filepath.Join(home, "Library", "Application Support", "Google", "Chrome", "NativeMessagingHosts")
+	case browser == "chrome" && runtime.GOOS == "linux":
+		dir = filepath.Join(home, ".config", "google-chrome", "NativeMessagingHosts")
+	case browser == "chromium" && runtime.GOOS == "linux":
+		dir = filepath.Join(home, ".config", "chromium", "NativeMessagingHosts")
+	case browser == "firefox" && runtime.GOOS == "darwin":
+		dir = filepath.Join(home, "Library", "Application Support", "Mozilla", "NativeMessagingHosts")
+	case browser == "firefox" && runtime.GOOS == "linux":
+		dir = filepath.Join(home, ".mozilla", "native-messaging-hosts")
+	default:
+		return "", fmt.Errorf("installing the native host for %s on %s is not supported", browser, runtime.GOOS)
+	}
+
+	return filepath.Join(dir, nativeHostName+".json"), nil
+}
+
+// nativeHostManifest returns the manifest registering the host at path for
+// the extension.
+func nativeHostManifest(browser, path, extensionID string) map[string]interface{} {
+	manifest := map[string]interface{}{
+		"name":        nativeHostName,
+		"description": "Pocket command line client",
+		"path":        path,
+		"type":        "stdio",
+	}
+	if browser == "firefox" {
+		manifest["allowed_extensions"] = []string{extensionID}
+	} else {
+		manifest["allowed_origins"] = []string{"chrome-extension://" + extensionID + "/"}
+	}
+	return manifest
+}
+
+// installNativeHost writes a launcher script running "pocket native-host",
+// since browsers start the host without our arguments, and the manifest
+// pointing the browser to it.
+func installNativeHost(conf Config) {
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		panic(err)
+	}
+
+	manifestPath, err := nativeHostManifestPath(conf.Browser)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	launcherPath := filepath.Join(configDir, "native-host")
+	launcher := fmt.Sprintf("#!/bin/sh\nexec %s native-host\n", shellQuote(executable))
+	manifest, err := json.MarshalIndent(nativeHostManifest(conf.Browser, launcherPath, conf.ExtensionID), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	if conf.Print {
+		fmt.Printf("%s:\n%s\n%s:\n%s\n", launcherPath, launcher, manifestPath, manifest)
+		return
+	}
+
+	err = os.WriteFile(launcherPath, []byte(launcher), 0755)
+	if err != nil {
+		panic(err)
+	}
+	err = os.MkdirAll(filepath.Dir(manifestPath), 0755)
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(manifestPath, append(manifest, '\n'), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %s and %s\n", launcherPath, manifestPath)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commandNativeHost speaks the native messaging protocol on stdin and
+// stdout until the browser closes the connection.
+func commandNativeHost(conf Config, client *api.Client) {
+	if conf.Install {
+		installNativeHost(conf)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		panic(err)
+	}
+
+	for {
+		data, err := readNativeMessage(os.Stdin)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			logFatal("Could not read a message", err)
+		}
+
+		var req nativeRequest
+		var res nativeResponse
+		if err := json.Unmarshal(data, &req); err != nil {
+			res = nativeResponse{Error: err.Error()}
+		} else {
+			res = handleNativeRequest(client, settings.Rules, req)
+		}
+
+		err = writeNativeMessage(os.Stdout, res)
+		if err != nil {
+			logFatal("Could not write a message", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// notesPath is the file of the notes of items, kept locally as the Pocket
+// API has no place for them.
+func notesPath() string {
+	return filepath.Join(configDir, "notes.json")
+}
+
+// loadNotes returns the notes of items by their ID.
+func loadNotes() (map[int]string, error) {
+	notes := map[int]string{}
+	err := loadJSONFromFile(notesPath(), &notes)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// setNote sets the note of an item, removing it if note is empty.
+func setNote(itemID int, note string) error {
+	notes, err := loadNotes()
+	if err != nil {
+		return err
+	}
+	if note == "" {
+		delete(notes, itemID)
+	} else {
+		notes[itemID] = note
+	}
+	return saveJSONToFile(notesPath(), notes)
+}
+
+// notedItem is an item along with its note, as exported to JSON.
+type notedItem struct {
+	api.Item
+	Note string `json:"note,omitempty"`
+}
+
+func commandNote(conf Config, client *api.Client) {
+	if conf.Clear {
+		if err := setNote(conf.ItemID, ""); err != nil {
+			exitWithError(conf, err)
+		}
+		fmt.Printf("Removed the note of item %d\n", conf.ItemID)
+		return
+	}
+
+	if len(conf.NoteText) == 0 {
+		notes, err := loadNotes()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		note, ok := notes[conf.ItemID]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Item %d has no note\n", conf.ItemID)
+			os.Exit(1)
+		}
+		fmt.Println(note)
+		return
+	}
+
+	note := strings.Join(conf.NoteText, " ")
+	if note == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		note = strings.TrimSpace(string(b))
+	}
+	if err := setNote(conf.ItemID, note); err != nil {
+		exitWithError(conf, err)
+	}
+	fmt.Printf("Noted item %d\n", conf.ItemID)
+}
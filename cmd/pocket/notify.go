@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// maxItemNotifications is the number of new items notified one by one; more
+// are summarized in a single notification.
+const maxItemNotifications = 3
+
+// notify shows a desktop notification, using notify-send on Linux and BSDs,
+// osascript on macOS, and a toast through PowerShell on Windows.
+func notify(title, body string) error {
+	cmd := notifyCommand(title, body)
+	if _, err := cmd.Output(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("Failed to run %s: %s, %s", cmd.Path, err, exitErr.Stderr)
+		}
+		return fmt.Errorf("Failed to run %s: %s", cmd.Path, err)
+	}
+	return nil
+}
+
+// notableItems returns the items among added that should be notified: all of
+// them if tags is empty, or otherwise those with one of tags.
+func notableItems(m *mirror.Mirror, added []int, tags []string) ([]api.Item, error) {
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+
+	notable := []api.Item{}
+	for _, id := range added {
+		item, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if len(tags) == 0 {
+			notable = append(notable, item)
+			continue
+		}
+		for _, tag := range tags {
+			if _, ok := item.Tags[tag]; ok {
+				notable = append(notable, item)
+				break
+			}
+		}
+	}
+	return notable, nil
+}
+
+// notifyNewItems notifies each item, or a summary if there are too many.
+func notifyNewItems(items []api.Item) error {
+	if len(items) > maxItemNotifications {
+		titles := []string{}
+		for _, item := range items[:maxItemNotifications] {
+			titles = append(titles, item.Title())
+		}
+		return notify(fmt.Sprintf("%d new items in Pocket", len(items)), strings.Join(titles, "\n")+"\n…")
+	}
+
+	for _, item := range items {
+		err := notify("New in Pocket", item.Title()+"\n"+item.Domain())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// weeklyDigest summarizes the items added and read in the week before now.
+func weeklyDigest(m *mirror.Mirror, now time.Time) (string, error) {
+	items, err := m.Items()
+	if err != nil {
+		return "", err
+	}
+
+	weekAgo := now.AddDate(0, 0, -7)
+	added, read, unread := 0, 0, 0
+	for _, item := range items {
+		if item.TimeAdded.After(weekAgo) {
+			added++
+		}
+		if item.Status == api.ItemStatusArchived && item.TimeRead.After(weekAgo) {
+			read++
+		}
+		if item.Status == api.ItemStatusUnread {
+			unread++
+		}
+	}
+
+	return fmt.Sprintf("%d saved and %d read this week; %d unread in total", added, read, unread), nil
+}
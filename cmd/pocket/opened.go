@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// openedPath is the file recording when items were last opened, if the
+// track_opened setting is on.
+func openedPath() string {
+	return filepath.Join(configDir, "opened.json")
+}
+
+// loadOpened returns the times items were last opened by their ID.
+func loadOpened() (map[int]time.Time, error) {
+	opened := map[int]time.Time{}
+	err := loadJSONFromFile(openedPath(), &opened)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return opened, nil
+}
+
+// trackOpened reports whether opening items is recorded.
+var trackOpened = sync.OnceValue(func() bool {
+	settings, err := loadSettings()
+	return err == nil && settings.TrackOpened
+})
+
+// openItem opens an item in a browser, recording it if the track_opened
+// setting is on. Failing to record it is only logged.
+func openItem(item api.Item) error {
+	if err := openInBrowser(item.URL()); err != nil {
+		return err
+	}
+	if !trackOpened() {
+		return nil
+	}
+
+	opened, err := loadOpened()
+	if err == nil {
+		opened[item.ItemID] = time.Now()
+		err = saveJSONToFile(openedPath(), opened)
+	}
+	if err != nil {
+		slog.Warn("Could not record the item opened", "item_id", item.ItemID, "err", err)
+	}
+	return nil
+}
+
+// openedUnread returns the unread items that were opened, last opened first.
+func openedUnread(items []api.Item) ([]api.Item, error) {
+	opened, err := loadOpened()
+	if err != nil {
+		return nil, err
+	}
+	kept := []api.Item{}
+	for _, item := range items {
+		if _, ok := opened[item.ItemID]; ok && item.Status == api.ItemStatusUnread {
+			kept = append(kept, item)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return opened[kept[i].ItemID].After(opened[kept[j].ItemID]) })
+	return kept, nil
+}
+
+// maxOpen is the most items opened at once, to not flood the browser with
+// tabs; the confirmation policy asks first before opening fewer.
+const maxOpen = 30
+
+// itemsToOpen returns the items chosen by the options of conf: the oldest
+// --oldest unread ones, or else the first --limit, matching the filters.
+func itemsToOpen(conf Config, client *api.Client) ([]api.Item, error) {
+	options := &api.RetrieveOption{
+		State:  api.StateUnread,
+		Domain: conf.Domain,
+		Search: conf.SearchQuery,
+		Tag:    conf.Tag,
+		Sort:   api.SortNewest,
+	}
+	n := conf.Limit
+	if conf.Oldest > 0 {
+		options.Sort, n = api.SortOldest, conf.Oldest
+	}
+	options.Count = n
+	// Filters applied locally need all the items to choose from
+	if len(langFilter) > 0 || minutesFilter.Min > 0 || minutesFilter.Max > 0 {
+		options.Count = 0
+	}
+
+	items, err := retrieveItems(client, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items, nil
+}
+
+// itemsByID returns the items with the IDs given as arguments, in their
+// order.
+func itemsByID(conf Config, client *api.Client) ([]api.Item, error) {
+	ids, err := readItemIDs(conf.ItemIDs, os.Stdin)
+	if err != nil {
+		return nil, &usageError{err: err}
+	}
+
+	all, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll})
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]api.Item{}
+	for _, item := range all {
+		byID[item.ItemID] = item
+	}
+	items := make([]api.Item, 0, len(ids))
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("No item %d", id)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func commandOpen(conf Config, client *api.Client) {
+	var items []api.Item
+	if conf.Next {
+		queued, err := queuedItems(client)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if len(queued) == 0 {
+			fmt.Println("No items to open; the reading queue is empty")
+			return
+		}
+		items = queued[:1]
+	} else if len(conf.ItemIDs) == 0 {
+		var err error
+		items, err = itemsToOpen(conf, client)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if len(items) == 0 {
+			fmt.Println("No items to open")
+			return
+		}
+	} else {
+		var err error
+		items, err = itemsByID(conf, client)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+	}
+
+	if len(items) > maxOpen {
+		fmt.Fprintf(os.Stderr, "Not opening %d items at once; open at most %d\n", len(items), maxOpen)
+		os.Exit(1)
+	}
+	if asksConfirmation(conf, confirmOpen, len(items)) {
+		for _, item := range items {
+			fmt.Printf("[%9d] %s\n", item.ItemID, item.Title())
+		}
+	}
+	if !confirmOperation(conf, confirmOpen, len(items), fmt.Sprintf("Open %d tabs?", len(items))) {
+		return
+	}
+
+	failed := false
+	for _, item := range items {
+		if err := openItem(item); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	// Taken off the reading queue once opened
+	if conf.Next {
+		if err := unqueue(items[0].ItemID); err != nil {
+			exitWithError(conf, err)
+		}
+	}
+}
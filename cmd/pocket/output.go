@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/render"
+)
+
+// renderOutput writes result to stdout in the format of --output, or
+// executes the template of --format with each of its records, exiting if
+// the format is unknown or the command has no output in it.
+func renderOutput(conf Config, command string, result *render.Result) {
+	renderer, ok := render.Lookup(conf.Output)
+	if conf.FormatTemplate != "" && conf.Output == "text" {
+		t, err := template.New("item").Funcs(templateFuncs).Parse(conf.FormatTemplate)
+		if err != nil {
+			exitWithError(conf, &usageError{command: command, err: err})
+		}
+		renderer, ok = render.Template(t), true
+	}
+	if !ok || !render.Accepts(renderer, result) {
+		exitWithError(conf, &usageError{command: command, err: unknownOutput(conf.Output, render.Names(result))})
+	}
+	if err := renderer.Render(os.Stdout, result); err != nil {
+		panic(err)
+	}
+}
+
+// checkOutput exits unless --output names a registered format, for the
+// commands to check before doing any work.
+func checkOutput(conf Config, command string) {
+	if _, ok := render.Lookup(conf.Output); !ok {
+		exitWithError(conf, &usageError{command: command, err: unknownOutput(conf.Output, render.Names(nil))})
+	}
+}
+
+// unknownOutput returns the error of output not being one of names.
+func unknownOutput(output string, names []string) error {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	switch len(quoted) {
+	case 0:
+		return fmt.Errorf("unknown output %q", output)
+	case 1:
+		return fmt.Errorf("unknown output %q; use %s", output, quoted[0])
+	case 2:
+		return fmt.Errorf("unknown output %q; use %s or %s", output, quoted[0], quoted[1])
+	}
+	return fmt.Errorf("unknown output %q; use %s, or %s", output, strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+}
+
+// itemsResult is the result of a command listing items, as a table of
+// their IDs, titles, URLs, tags, and when they were added, and executing
+// templates with the items themselves.
+func itemsResult(items []api.Item) *render.Result {
+	result := &render.Result{
+		Value:   items,
+		Columns: []string{"id", "title", "url", "tags", "added", "state"},
+		Rows:    [][]string{},
+		Records: []any{},
+		IDs:     []int{},
+	}
+	for _, item := range items {
+		state := "unread"
+		switch item.Status {
+		case api.ItemStatusArchived:
+			state = "archived"
+		case api.ItemStatusDeleted:
+			state = "deleted"
+		}
+		result.Rows = append(result.Rows, []string{
+			strconv.Itoa(item.ItemID), item.Title(), item.URL(), strings.Join(item.TagNames(), ","), formatTime(item.TimeAdded.Time, "2006-01-02"), state,
+		})
+		result.Records = append(result.Records, item)
+		result.IDs = append(result.IDs, item.ItemID)
+	}
+	return result
+}
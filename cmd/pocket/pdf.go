@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/pdf"
+)
+
+// pdfStyle is how a block of an article is set.
+type pdfStyle struct {
+	font   pdf.Font
+	size   float64
+	indent float64
+}
+
+var pdfBodyStyle = pdfStyle{font: pdf.Regular, size: 11}
+
+// pdfArticle sets the text of an article in a document, one paragraph per
+// block element. Images are left out.
+type pdfArticle struct {
+	doc   *pdf.Document
+	style pdfStyle
+	text  strings.Builder
+	// bullet is the bullet of a list item, set before its first paragraph.
+	bullet string
+}
+
+// flush sets the text gathered since the last block as a paragraph.
+func (a *pdfArticle) flush() {
+	if strings.TrimSpace(a.text.String()) != "" {
+		a.doc.Text(a.style.font, a.style.size, a.style.indent, a.bullet+a.text.String())
+		a.doc.Space(a.style.size / 2)
+		a.bullet = ""
+	}
+	a.text.Reset()
+}
+
+func (a *pdfArticle) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		a.text.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	style := a.style
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Img, atom.Svg:
+		return
+	case atom.Br:
+		if style.font == pdf.Mono {
+			a.text.WriteString("\n")
+		} else {
+			a.flush()
+		}
+		return
+	case atom.H1, atom.H2:
+		style = pdfStyle{font: pdf.Bold, size: 15, indent: style.indent}
+	case atom.H3, atom.H4, atom.H5, atom.H6:
+		style = pdfStyle{font: pdf.Bold, size: 12.5, indent: style.indent}
+	case atom.Blockquote:
+		style = pdfStyle{font: pdf.Italic, size: style.size, indent: style.indent + 20}
+	case atom.Figcaption:
+		style = pdfStyle{font: pdf.Italic, size: 9.5, indent: style.indent}
+	case atom.Pre:
+		style = pdfStyle{font: pdf.Mono, size: 9, indent: style.indent}
+	case atom.Ul, atom.Ol:
+		style.indent += 14
+	default:
+		if !blockElements[n.DataAtom] {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				a.walk(c)
+			}
+			return
+		}
+	}
+
+	a.flush()
+	parent := a.style
+	a.style = style
+	if n.DataAtom == atom.Li {
+		a.bullet = "• "
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		a.walk(c)
+	}
+	a.flush()
+	a.style = parent
+}
+
+// addPDFArticle adds an article to a document, starting on a new page with
+// its title and URL.
+func addPDFArticle(doc *pdf.Document, item api.Item, article *api.Article) error {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(article.HTML), body)
+	if err != nil {
+		return err
+	}
+
+	doc.NewPage()
+	doc.Text(pdf.Bold, 18, 0, item.Title())
+	if item.URL() != "" {
+		doc.Text(pdf.Regular, 9, 0, item.URL())
+	}
+	doc.Space(12)
+
+	a := &pdfArticle{doc: doc, style: pdfBodyStyle}
+	for _, n := range nodes {
+		a.walk(n)
+	}
+	a.flush()
+	return nil
+}
+
+func commandPDF(conf Config, client *api.Client) {
+	ids, err := readItemIDs(conf.ItemIDs, os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cache, err := openArticleCache()
+	if err != nil {
+		panic(err)
+	}
+
+	m, err := openMirror()
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	all, err := m.Items()
+	if err != nil {
+		panic(err)
+	}
+	byID := map[int]api.Item{}
+	for _, item := range all {
+		byID[item.ItemID] = item
+	}
+	items := []api.Item{}
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Item %d is not in the local mirror; run \"pocket sync\" first\n", id)
+			os.Exit(1)
+		}
+		items = append(items, item)
+	}
+
+	// Articles not cached yet are fetched a few at a time, then cached
+	// one by one, as caching may evict others
+	articles := make([]*api.Article, len(items))
+	fetched := make([]bool, len(items))
+	errs, _ := newBulk("Fetching articles", 4).Run(len(items), func(i int) error {
+		article, err := cache.Peek(items[i].ItemID)
+		if os.IsNotExist(err) {
+			article, err = client.Article(items[i].URL())
+			fetched[i] = err == nil
+		}
+		articles[i] = article
+		return err
+	})
+
+	title := items[0].Title()
+	if len(items) > 1 {
+		title = fmt.Sprintf("Pocket %d articles", len(items))
+	}
+	doc := pdf.New(title)
+	doc.Author = "Pocket"
+
+	added := 0
+	for i, item := range items {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "Could not fetch the article of %s: %v\n", item.URL(), errs[i])
+			continue
+		}
+		if fetched[i] {
+			if err := cache.Put(item.ItemID, articles[i]); err != nil {
+				panic(err)
+			}
+		}
+		if err := addPDFArticle(doc, item, articles[i]); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not lay out the article of %s: %v\n", item.URL(), err)
+			continue
+		}
+		added++
+	}
+	if added == 0 {
+		os.Exit(1)
+	}
+
+	w, err := createOutput(conf.Out)
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	err = doc.Write(w)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %d of %d articles\n", added, len(items))
+}
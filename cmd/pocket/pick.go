@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// pickLine formats an item as a tab-separated line for fuzzy finders, with
+// the item ID as the first field.
+func pickLine(item api.Item) string {
+	return pickFields(strconv.Itoa(item.ItemID), item.Title(), item.URL(), strings.Join(item.TagNames(), ","))
+}
+
+// pickFields joins fields into a tab-separated line, replacing the tabs and
+// line breaks in them.
+func pickFields(fields ...string) string {
+	for i, field := range fields {
+		fields[i] = strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, field)
+	}
+	return strings.Join(fields, "\t")
+}
+
+// pickedIDs parses the item IDs from lines chosen in a fuzzy finder.
+func pickedIDs(output string) []int {
+	ids := []int{}
+	for _, line := range strings.Split(output, "\n") {
+		id, err := strconv.Atoi(strings.SplitN(line, "\t", 2)[0])
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// pickWithFzf runs fzf on lines, returning the chosen item IDs.
+func pickWithFzf(fzf string, lines []string) ([]int, error) {
+	cmd := exec.Command(fzf, "--multi", "--delimiter=\t", "--with-nth=2..", "--prompt=pocket> ")
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n"))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && (exitErr.ExitCode() == 1 || exitErr.ExitCode() == 130) {
+		// No match, or interrupted
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pickedIDs(string(out)), nil
+}
+
+// fuzzyScore scores how well text matches pattern as a case-insensitive
+// subsequence, preferring consecutive characters and word starts. It returns
+// -1 if text does not match.
+func fuzzyScore(pattern, text string) int {
+	pattern = strings.ToLower(pattern)
+	runes := []rune(strings.ToLower(text))
+
+	score, last, i := 0, -2, 0
+	for _, p := range pattern {
+		if unicode.IsSpace(p) {
+			continue
+		}
+		for i < len(runes) && runes[i] != p {
+			i++
+		}
+		if i == len(runes) {
+			return -1
+		}
+		score++
+		if i == last+1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			score++
+		}
+		last = i
+		i++
+	}
+	return score
+}
+
+// picker is the embedded fuzzy finder, used when fzf is not installed.
+type picker struct {
+	lines    []string
+	matches  []int
+	selected map[int]bool
+	query    string
+	cursor   int
+}
+
+func (p *picker) filter() {
+	type match struct{ index, score int }
+	matches := []match{}
+	for i, line := range p.lines {
+		text := line[strings.IndexByte(line, '\t')+1:]
+		if score := fuzzyScore(p.query, text); score >= 0 {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	p.matches = make([]int, len(matches))
+	for i, m := range matches {
+		p.matches[i] = m.index
+	}
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+func (p *picker) draw(w io.Writer, width, height int) {
+	var b strings.Builder
+	b.WriteString(escHome)
+
+	rows := height - 2
+	offset := 0
+	if p.cursor >= rows {
+		offset = p.cursor - rows + 1
+	}
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			b.WriteString("\r\n")
+		}
+		i := offset + row
+		if i < len(p.matches) {
+			index := p.matches[i]
+			mark := "  "
+			if p.selected[index] {
+				mark = "> "
+			}
+			fields := strings.Split(p.lines[index], "\t")
+			line := fit(mark+strings.Join(fields[1:], "  "), width)
+			if i == p.cursor {
+				line = escReverse + line + escReset
+			}
+			b.WriteString(line)
+		}
+		b.WriteString(escClearLine)
+	}
+
+	b.WriteString("\r\n" + escDim + fit(fmt.Sprintf("  %d/%d (Tab to select more, Enter to choose, Esc to cancel)", len(p.matches), len(p.lines)), width) + escReset)
+	b.WriteString("\r\n" + fit("pocket> "+p.query, width) + escClearLine)
+	io.WriteString(w, b.String())
+}
+
+// run lets the user choose lines on the terminal, returning their indexes.
+func (p *picker) run() ([]int, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(fd, state)
+
+	os.Stdout.WriteString(escAltScreen + escHideCursor)
+	defer os.Stdout.WriteString(escShowCursor + escMainScreen)
+
+	p.selected = map[int]bool{}
+	p.filter()
+
+	buf := make([]byte, 64)
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return nil, err
+		}
+		p.draw(os.Stdout, width, height)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		switch key := string(buf[:n]); key {
+		case keyEscape, keyCtrlC:
+			return nil, nil
+		case keyEnter:
+			chosen := []int{}
+			for index := range p.selected {
+				chosen = append(chosen, index)
+			}
+			if len(chosen) == 0 && len(p.matches) > 0 {
+				chosen = append(chosen, p.matches[p.cursor])
+			}
+			sort.Ints(chosen)
+			return chosen, nil
+		case keyTab:
+			if len(p.matches) > 0 {
+				index := p.matches[p.cursor]
+				p.selected[index] = !p.selected[index]
+				if p.cursor < len(p.matches)-1 {
+					p.cursor++
+				}
+			}
+		case keyUp, "\x10": // Ctrl-P
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case keyDown, "\x0e": // Ctrl-N
+			if p.cursor < len(p.matches)-1 {
+				p.cursor++
+			}
+		case keyBack:
+			if runes := []rune(p.query); len(runes) > 0 {
+				p.query = string(runes[:len(runes)-1])
+				p.filter()
+			}
+		default:
+			if !strings.HasPrefix(key, "\x1b") && key >= " " {
+				p.query += key
+				p.cursor = 0
+				p.filter()
+			}
+		}
+	}
+}
+
+// copyToClipboard puts text on the system clipboard.
+func copyToClipboard(text string) error {
+	for _, args := range clipboardCopyCommands {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("Failed to run %s: %s, %s", args[0], err, stderr.String())
+		}
+		return nil
+	}
+
+	return errors.New("no clipboard command found")
+}
+
+// chooseItems lets the user choose among items, shown as lines, with fzf
+// or the built-in fuzzy finder, or by number for screen readers, returning
+// the IDs of those chosen.
+func chooseItems(command string, items []api.Item, lines []string) []int {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Title() + ", " + item.Domain()
+	}
+	ids := []int{}
+	for _, index := range chooseLines(command, labels, lines) {
+		ids = append(ids, items[index].ItemID)
+	}
+	return ids
+}
+
+// chooseLines lets the user choose among lines starting with an ID, as
+// chooseItems does, listing them by their labels for screen readers, and
+// returns the indexes of those chosen.
+func chooseLines(command string, labels, lines []string) []int {
+	if screenReader {
+		return chooseNumbered(labels, lines)
+	}
+
+	if fzf, err := exec.LookPath("fzf"); err == nil {
+		ids, err := pickWithFzf(fzf, lines)
+		if err != nil {
+			panic(err)
+		}
+		indexes := map[int]int{}
+		for i, line := range lines {
+			for _, id := range pickedIDs(line) {
+				indexes[id] = i
+			}
+		}
+		chosen := []int{}
+		for _, id := range ids {
+			if index, ok := indexes[id]; ok {
+				chosen = append(chosen, index)
+			}
+		}
+		return chosen
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintf(os.Stderr, "pocket %s needs a terminal, or fzf\n", command)
+		os.Exit(1)
+	}
+	chosen, err := (&picker{lines: lines}).run()
+	if err != nil {
+		panic(err)
+	}
+	return chosen
+}
+
+func commandPick(conf Config, client *api.Client) {
+	action := conf.Action
+	if action == "" {
+		action = "open"
+	}
+	switch action {
+	case "open", "archive", "delete", "copy":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown action %q; use open, archive, delete, or copy\n", action)
+		os.Exit(1)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateUnread,
+		DetailType: api.DetailTypeComplete,
+		Domain:     conf.Domain,
+		Tag:        conf.Tag,
+		Search:     conf.SearchQuery,
+	})
+	if err != nil {
+		panic(err)
+	}
+	sort.Sort(library.BySortID(items))
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = pickLine(item)
+	}
+
+	if conf.Lines {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	ids := chooseItems("pick", items, lines)
+
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+
+	urls := []string{}
+	actions := []*api.Action{}
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			continue
+		}
+		switch action {
+		case "open":
+			if err := openItem(item); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case "archive":
+			actions = append(actions, api.NewArchiveAction(id))
+		case "delete":
+			actions = append(actions, api.NewDeleteAction(id))
+		case "copy":
+			urls = append(urls, item.URL())
+		}
+	}
+
+	if len(urls) > 0 {
+		if err := copyToClipboard(strings.Join(urls, "\n")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Copied %d URL(s)\n", len(urls))
+	}
+
+	if len(actions) > 0 {
+		_, queued, err := modifyOrQueue(client, actions...)
+		if err != nil {
+			panic(err)
+		}
+		if queued {
+			fmt.Printf("Queued %d action(s) until the next sync\n", len(actions))
+		} else {
+			fmt.Printf("Applied %d action(s)\n", len(actions))
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// planPriority orders candidates for the reading plan, most preferred first.
+func planPriority(items []api.Item, prefer string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if prefer == "tagged" {
+			ti, tj := len(items[i].Tags) > 0, len(items[j].Tags) > 0
+			if ti != tj {
+				return ti
+			}
+		}
+		return items[i].TimeAdded.Before(items[j].TimeAdded.Time)
+	})
+}
+
+// The knapsack of planReading takes at most this many of the items
+// preferred, and a budget of at most a week, keeping its table small.
+const (
+	maxPlanCandidates = 1000
+	maxPlanMinutes    = 7 * 24 * 60
+)
+
+// planReading picks a set of items whose total reading time fits into budget
+// minutes. It solves a 0/1 knapsack in which each item is worth its reading
+// time, weighted by up to twice as much for the items earliest in the given
+// order, so that the budget is filled as fully as possible while favoring
+// the preferred items.
+func planReading(items []api.Item, budget int) []api.Item {
+	budget = min(budget, maxPlanMinutes)
+	candidates := []api.Item{}
+	total := 0
+	for _, item := range items {
+		if len(candidates) == maxPlanCandidates {
+			break
+		}
+		if m := item.ReadingMinutes(); m > 0 && m <= budget {
+			candidates = append(candidates, item)
+			total += m
+		}
+	}
+	if total <= budget {
+		return candidates
+	}
+
+	n := len(candidates)
+	best := make([]int, budget+1)
+	keep := make([][]bool, n)
+	for i, item := range candidates {
+		minutes := item.ReadingMinutes()
+		value := minutes * (1000 + 1000*(n-i)/n)
+		keep[i] = make([]bool, budget+1)
+		for b := budget; b >= minutes; b-- {
+			if v := best[b-minutes] + value; v > best[b] {
+				best[b] = v
+				keep[i][b] = true
+			}
+		}
+	}
+
+	plan := []api.Item{}
+	b := budget
+	for i := n - 1; i >= 0; i-- {
+		if keep[i][b] {
+			plan = append(plan, candidates[i])
+			b -= candidates[i].ReadingMinutes()
+		}
+	}
+
+	// Restore the order of preference
+	for i, j := 0, len(plan)-1; i < j; i, j = i+1, j-1 {
+		plan[i], plan[j] = plan[j], plan[i]
+	}
+
+	return plan
+}
+
+func commandPlan(conf Config, client *api.Client) {
+	if conf.Prefer != "oldest" && conf.Prefer != "tagged" {
+		fmt.Fprintf(os.Stderr, "Unknown preference %q; use \"oldest\" or \"tagged\"\n", conf.Prefer)
+		os.Exit(1)
+	}
+	if conf.Minutes < 1 {
+		exitWithError(conf, &usageError{command: "plan", err: fmt.Errorf("--minutes must be a positive number of minutes")})
+	}
+	if conf.Output != "ics" {
+		checkListingOutput(conf.Output)
+	}
+	start := nextQuarterHour(time.Now())
+	if conf.Start != "" {
+		var err error
+		start, err = parseStart(conf.Start, time.Now())
+		if err != nil {
+			exitWithError(conf, &usageError{err: err})
+		}
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateUnread,
+		Domain:     conf.Domain,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	planPriority(items, conf.Prefer)
+	plan := planReading(items, conf.Minutes)
+
+	switch conf.Output {
+	case "ids":
+		printItemIDs(plan)
+	case "ics":
+		err := writeCalendar(os.Stdout, readingSessions(plan, start), time.Now())
+		if err != nil {
+			panic(err)
+		}
+	default:
+		total := 0
+		for _, item := range plan {
+			total += item.ReadingMinutes()
+			fmt.Printf("[%9d] %3d min  %s\n            <%s>\n", item.ItemID, item.ReadingMinutes(), item.Title(), item.URL())
+		}
+		fmt.Printf("\n%d items, %d of %d minutes\n", len(plan), total, conf.Minutes)
+	}
+
+	if conf.TagAs != "" && len(plan) > 0 {
+		actions := []*api.Action{}
+		for _, item := range plan {
+			actions = append(actions, api.NewTagsAddAction(item.ItemID, conf.TagAs))
+		}
+		_, err := modifyInBatches(client, actions)
+		if err != nil {
+			panic(err)
+		}
+		if conf.Output == "text" {
+			fmt.Printf("Tagged %d items with %q\n", len(actions), conf.TagAs)
+		}
+	}
+
+	if conf.Open {
+		for _, item := range plan {
+			if err := openItem(item); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+}
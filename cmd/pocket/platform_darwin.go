@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultConfigDir returns where the config directory is unless
+// POCKET_CONFIG_DIR is set. It is the same as elsewhere rather than under
+// ~/Library, as it has always been.
+func defaultConfigDir(home string) string {
+	return filepath.Join(home, ".config", "pocket")
+}
+
+// openCommand returns the command opening url in the default browser.
+func openCommand(url string) *exec.Cmd {
+	return exec.Command("open", url)
+}
+
+// clipboardCommand returns the command printing the contents of the
+// clipboard.
+func clipboardCommand() (*exec.Cmd, error) {
+	return exec.Command("pbpaste"), nil
+}
+
+// clipboardCopyCommands are the commands putting their input on the
+// clipboard, the first installed one being used.
+var clipboardCopyCommands = [][]string{{"pbcopy"}}
+
+// notifyCommand returns the command showing a desktop notification.
+func notifyCommand(title, body string) *exec.Cmd {
+	script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+	return exec.Command("osascript", "-e", script)
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// chromeCandidates are the names or paths Chrome or Chromium is looked for
+// under.
+var chromeCandidates = []string{
+	"chromium", "google-chrome", "chrome",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+}
+
+// keyringGet returns the secret stored under name in the login keychain, or
+// errKeyNotFound.
+func keyringGet(name string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", "pocket", "-a", name, "-w").Output()
+	// 44 is errSecItemNotFound
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+		return "", errKeyNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to run security: %s", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keyringSet stores secret under name in the login keychain.
+func keyringSet(name, secret string) error {
+	out, err := exec.Command("security", "add-generic-password", "-U", "-s", "pocket", "-a", name, "-w", secret).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to run security: %s, %s", err, out)
+	}
+	return nil
+}
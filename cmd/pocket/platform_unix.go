@@ -0,0 +1,79 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The desktop integration of Linux and the BSDs relies on the freedesktop.org
+// tools: xdg-open, notify-send, and the clipboard tools of Wayland or X11.
+
+// defaultConfigDir returns where the config directory is unless
+// POCKET_CONFIG_DIR is set.
+func defaultConfigDir(home string) string {
+	return filepath.Join(home, ".config", "pocket")
+}
+
+// openCommand returns the command opening url in the default browser.
+func openCommand(url string) *exec.Cmd {
+	return exec.Command("xdg-open", url)
+}
+
+// clipboardCommand returns the command printing the contents of the
+// clipboard: wl-paste under Wayland, or else xclip or xsel.
+func clipboardCommand() (*exec.Cmd, error) {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-paste"); err == nil {
+			return exec.Command("wl-paste", "--no-newline"), nil
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return exec.Command("xclip", "-selection", "clipboard", "-o"), nil
+	}
+	if _, err := exec.LookPath("xsel"); err == nil {
+		return exec.Command("xsel", "--clipboard", "--output"), nil
+	}
+	return nil, errors.New("no clipboard tool found; install wl-clipboard, xclip, or xsel")
+}
+
+// clipboardCopyCommands are the commands putting their input on the
+// clipboard, the first installed one being used.
+var clipboardCopyCommands = [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+
+// notifyCommand returns the command showing a desktop notification.
+func notifyCommand(title, body string) *exec.Cmd {
+	return exec.Command("notify-send", "--app-name=pocket", title, body)
+}
+
+// chromeCandidates are the names or paths Chrome or Chromium is looked for
+// under.
+var chromeCandidates = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// keyringGet returns the secret stored under name in the Secret Service
+// keyring of the desktop, with secret-tool, or errKeyNotFound.
+func keyringGet(name string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", "pocket", "account", name).Output()
+	if _, ok := err.(*exec.ExitError); ok && len(out) == 0 {
+		return "", errKeyNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to run secret-tool: %s; install libsecret-tools or set %s", err, cacheKeyEnv)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keyringSet stores secret under name in the keyring.
+func keyringSet(name, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=pocket "+name, "service", "pocket", "account", name)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Failed to run secret-tool: %s, %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/motemen/go-pocket/internal/atomicfile"
+)
+
+// defaultConfigDir returns where the config directory is unless
+// POCKET_CONFIG_DIR is set: under %AppData%, or under the home directory
+// where earlier versions kept it, if it is there.
+func defaultConfigDir(home string) string {
+	old := filepath.Join(home, ".config", "pocket")
+	if _, err := os.Stat(old); err == nil {
+		return old
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "pocket")
+	}
+	return old
+}
+
+// openCommand returns the command opening url in the default browser.
+// Unlike "cmd /c start", it passes url along without cmd's parsing, in
+// which "&" would end the command.
+func openCommand(url string) *exec.Cmd {
+	return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+}
+
+// clipboardCommand returns the command printing the contents of the
+// clipboard.
+func clipboardCommand() (*exec.Cmd, error) {
+	return exec.Command("powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"), nil
+}
+
+// clipboardCopyCommands are the commands putting their input on the
+// clipboard, the first installed one being used.
+var clipboardCopyCommands = [][]string{{"clip"}}
+
+// notifyCommand returns the command showing a desktop notification, a toast
+// shown through PowerShell.
+func notifyCommand(title, body string) *exec.Cmd {
+	return exec.Command("powershell", "-NoProfile", "-Command", windowsToastScript(title, body))
+}
+
+func windowsToastScript(title, body string) string {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	return `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode(` + quote(title) + `)) | Out-Null
+$text.Item(1).AppendChild($template.CreateTextNode(` + quote(body) + `)) | Out-Null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('pocket').Show($toast)`
+}
+
+// chromeCandidates are the names or paths Chrome or Chromium is looked for
+// under.
+var chromeCandidates = []string{
+	"chrome",
+	filepath.Join(os.Getenv("ProgramFiles"), `Google\Chrome\Application\chrome.exe`),
+	filepath.Join(os.Getenv("ProgramFiles(x86)"), `Google\Chrome\Application\chrome.exe`),
+	filepath.Join(os.Getenv("LocalAppData"), `Google\Chrome\Application\chrome.exe`),
+	filepath.Join(os.Getenv("LocalAppData"), `Chromium\Application\chrome.exe`),
+}
+
+// keyringPath is where the secret stored under name is kept, encrypted with
+// DPAPI for the user, as Windows has no keyring readable from the command
+// line.
+func keyringPath(name string) string {
+	return filepath.Join(configDir, name+".dpapi")
+}
+
+// keyringGet returns the secret stored under name, or errKeyNotFound.
+func keyringGet(name string) (string, error) {
+	b, err := os.ReadFile(keyringPath(name))
+	if os.IsNotExist(err) {
+		return "", errKeyNotFound
+	}
+	if err != nil || len(b) == 0 {
+		return "", err
+	}
+
+	in := windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+	var out windows.DataBlob
+	err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt %s: %w", keyringPath(name), err)
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return string(unsafe.Slice(out.Data, out.Size)), nil
+}
+
+// keyringSet stores secret under name.
+func keyringSet(name, secret string) error {
+	b := []byte(secret)
+	in := windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+	var out windows.DataBlob
+	err := windows.CryptProtectData(&in, windows.StringToUTF16Ptr("pocket "+name), nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return atomicfile.WriteFile(keyringPath(name), unsafe.Slice(out.Data, out.Size), 0600)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// concurrency returns the most links checked at once.
+func (s LinkCheckSettings) concurrency() int {
+	if s.Concurrency <= 0 {
+		return 8
+	}
+	return s.Concurrency
+}
+
+// domainDelay returns the time left between the requests to one host.
+func (s LinkCheckSettings) domainDelay() time.Duration {
+	switch {
+	case s.DomainDelay == 0:
+		return 250 * time.Millisecond
+	case s.DomainDelay < 0:
+		return 0
+	}
+	return time.Duration(s.DomainDelay) * time.Millisecond
+}
+
+// hostLimiter spaces out the requests made to each host while checking
+// links, so that checking many items saved from one site does not get
+// pocket blocked there.
+type hostLimiter struct {
+	delay  time.Duration
+	serial bool
+
+	mu    sync.Mutex
+	hosts map[string]*hostTurn
+}
+
+// hostTurn is when a host may be requested next. It is held through each
+// request if requests are made one at a time.
+type hostTurn struct {
+	sync.Mutex
+	next time.Time
+}
+
+func newHostLimiter(s LinkCheckSettings) *hostLimiter {
+	return &hostLimiter{delay: s.domainDelay(), serial: s.SerializeDomains, hosts: map[string]*hostTurn{}}
+}
+
+// wait waits for the turn of the host of rawURL, returning the function to
+// call once the request is done.
+func (l *hostLimiter) wait(rawURL string) (done func()) {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	l.mu.Lock()
+	turn, ok := l.hosts[host]
+	if !ok {
+		turn = &hostTurn{}
+		l.hosts[host] = turn
+	}
+	if !l.serial {
+		// Requests take their turns in the order they come
+		start := time.Now()
+		if turn.next.After(start) {
+			start = turn.next
+		}
+		turn.next = start.Add(l.delay)
+		l.mu.Unlock()
+		time.Sleep(time.Until(start))
+		return func() {}
+	}
+	l.mu.Unlock()
+
+	turn.Lock()
+	time.Sleep(time.Until(turn.next))
+	return func() {
+		turn.next = time.Now().Add(l.delay)
+		turn.Unlock()
+	}
+}
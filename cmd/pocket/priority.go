@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// sortPriority is the value of --sort putting the items with the highest
+// priority first.
+const sortPriority = "priority"
+
+func commandPriority(conf Config, client *api.Client) {
+	priority, err := strconv.Atoi(conf.Priority)
+	if conf.Priority == "none" {
+		priority, err = 0, nil
+	}
+	if err != nil || priority != 0 && (priority < library.HighestPriority || priority > library.LowestPriority) {
+		exitWithError(conf, &usageError{command: "priority", err: fmt.Errorf("priority must be 1 to 5, or 0 or \"none\" to remove it: %q", conf.Priority)})
+	}
+
+	_, queued, err := modifyOrQueue(client, library.PriorityActions(conf.ItemID, priority)...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch {
+	case queued:
+		fmt.Printf("Offline; queued the priority of item %d until the next sync\n", conf.ItemID)
+	case priority == 0:
+		fmt.Printf("Removed the priority of item %d\n", conf.ItemID)
+	default:
+		fmt.Printf("Set the priority of item %d to %d\n", conf.ItemID, priority)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// stillActive is the exit code of processes still running, STILL_ACTIVE.
+const stillActive = 259
+
+// processRunning reports whether the process with the given ID is running.
+func processRunning(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	err = windows.GetExitCodeProcess(h, &code)
+	return err == nil && code == stillActive
+}
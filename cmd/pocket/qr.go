@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/qr"
+)
+
+// commandQR shows the URL of an item as a QR code, to open it on a phone.
+func commandQR(conf Config, client *api.Client) {
+	item, err := findMirrorItem(conf.ItemID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s; run \"pocket sync\" first\n", err)
+		os.Exit(1)
+	}
+
+	code, err := qr.Encode(item.URL(), qr.M)
+	if err == qr.ErrTooLong {
+		code, err = qr.Encode(item.URL(), qr.L)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Print(code.Terminal(4))
+	fmt.Printf("%s\n<%s>\n", item.Title(), item.URL())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// quota is the state of the rate limits of the Pocket API as of the last
+// response telling them, kept in quota.json so that every command knows it
+// before making requests of its own.
+type quota struct {
+	// The requests left within the limits of the user and of the consumer
+	// key, or -1 if unknown, and when those limits reset
+	UserRemaining int       `json:"user_remaining"`
+	UserReset     time.Time `json:"user_reset"`
+	KeyRemaining  int       `json:"key_remaining"`
+	KeyReset      time.Time `json:"key_reset"`
+	Updated       time.Time `json:"updated"`
+}
+
+// quotaFile returns the path of quota.json.
+func quotaFile() string {
+	return filepath.Join(configDir, "quota.json")
+}
+
+// quotaSaving serializes writes of quota.json by concurrent requests.
+var quotaSaving sync.Mutex
+
+// saveQuota keeps the rate limits of usage in quota.json, as api.OnRateLimit.
+func saveQuota(usage api.Usage) {
+	quotaSaving.Lock()
+	defer quotaSaving.Unlock()
+
+	q := quota{
+		UserRemaining: usage.UserRemaining,
+		UserReset:     usage.UserReset,
+		KeyRemaining:  usage.KeyRemaining,
+		KeyReset:      usage.KeyReset,
+		Updated:       time.Now(),
+	}
+	if err := saveJSONToFile(quotaFile(), q); err != nil {
+		slog.Debug("Could not save the rate limits", "err", err)
+	}
+}
+
+// loadQuota returns the rate limits last seen, if any.
+func loadQuota() (*quota, error) {
+	q := &quota{UserRemaining: -1, KeyRemaining: -1}
+	if err := loadJSONFromFile(quotaFile(), q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// remaining returns the requests left at now within the tighter of the rate
+// limits and when it resets, or -1 if neither is known. Limits reset since
+// they were seen are left out, as are those of unknown reset seen over an
+// hour ago, the period of the user limit.
+func (q *quota) remaining(now time.Time) (int, time.Time) {
+	left, reset := -1, time.Time{}
+	for _, limit := range []struct {
+		remaining int
+		reset     time.Time
+	}{
+		{q.UserRemaining, q.UserReset},
+		{q.KeyRemaining, q.KeyReset},
+	} {
+		switch {
+		case limit.remaining < 0:
+			continue
+		case limit.reset.IsZero() && now.Sub(q.Updated) > time.Hour:
+			continue
+		case !limit.reset.IsZero() && now.After(limit.reset):
+			continue
+		}
+		if left < 0 || limit.remaining < left {
+			left, reset = limit.remaining, limit.reset
+		}
+	}
+	return left, reset
+}
+
+// waitForQuota, set by --wait-for-quota, makes checkQuota wait for the rate
+// limits to reset instead of only warning.
+var waitForQuota bool
+
+// quotaWarned is set once checkQuota has warned, not to repeat it for each
+// batch.
+var quotaWarned bool
+
+// checkQuota warns if an operation taking about calls requests would exceed
+// the rate limits last seen, or with waitForQuota, waits for them to reset.
+func checkQuota(calls int) {
+	q, err := loadQuota()
+	if err != nil {
+		return
+	}
+	left, reset := q.remaining(time.Now())
+	if left < 0 || calls <= left {
+		return
+	}
+
+	if !waitForQuota || reset.IsZero() {
+		if quotaWarned {
+			return
+		}
+		quotaWarned = true
+		until := ""
+		if !reset.IsZero() {
+			until = " until " + reset.Local().Format("15:04")
+		}
+		fmt.Fprintf(os.Stderr, "pocket: this takes about %d API calls, but the rate limit leaves %d%s; pass --wait-for-quota to wait for it to reset\n", calls, left, until)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Waiting until %s for the rate limit to reset, as %d API calls are needed and %d left\n", reset.Local().Format("15:04"), calls, left)
+	for time.Now().Before(reset) && !interruptRequested() {
+		time.Sleep(min(time.Until(reset), time.Second))
+	}
+}
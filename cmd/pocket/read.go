@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// openArticleCache opens the article text cache in the config directory,
+// sized by the cache settings.
+func openArticleCache() (*mirror.ArticleCache, error) {
+	settings, err := loadSettings()
+	if err != nil {
+		return nil, err
+	}
+	cache := mirror.NewArticleCache(filepath.Join(configDir, "articles"), int64(settings.Cache.MaxMB)<<20)
+	cache.Cipher, err = cacheCipher()
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// blockElements are the elements that start a new paragraph in articleText.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Tr: true, atom.Figure: true, atom.Section: true, atom.Article: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// articleText converts an article's HTML to plain text, one paragraph per
+// block element.
+func articleText(fragment string) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return fragment
+	}
+
+	var buf strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			buf.WriteString(n.Data)
+		case html.ElementNode:
+			if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+				return
+			}
+			block := blockElements[n.DataAtom]
+			if block {
+				buf.WriteString("\n\n")
+			}
+			if n.DataAtom == atom.Li {
+				buf.WriteString("* ")
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+			if block {
+				buf.WriteString("\n\n")
+			}
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	paragraphs := []string{}
+	for _, p := range strings.Split(buf.String(), "\n\n") {
+		if p := strings.Join(strings.Fields(p), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func commandRead(conf Config, client *api.Client) {
+	cache, err := openArticleCache()
+	if err != nil {
+		panic(err)
+	}
+
+	m, err := openMirror()
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	items, err := m.Items()
+	if err != nil {
+		panic(err)
+	}
+	var item *api.Item
+	for i := range items {
+		if items[i].ItemID == conf.ItemID {
+			item = &items[i]
+			break
+		}
+	}
+	if item == nil {
+		fmt.Fprintf(os.Stderr, "Item %d is not in the local mirror; run \"pocket sync\" first\n", conf.ItemID)
+		os.Exit(1)
+	}
+
+	cache.Keep, err = wantedArticles(m)
+	if err != nil {
+		panic(err)
+	}
+	article, err := cache.Get(item.ItemID)
+	if os.IsNotExist(err) {
+		article, err = client.Article(item.URL())
+		if err == nil {
+			err = cache.Put(item.ItemID, article)
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%s\n<%s>\n\n%s\n", item.Title(), item.URL(), articleText(article.HTML))
+}
+
+// fileSize returns the size of the file at path, or zero if there is none.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func commandCache(conf Config, client *api.Client) {
+	cache, err := openArticleCache()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	switch {
+	case conf.Status:
+		status, err := cache.Status()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%d articles, %.1f of %.1f MB\n", status.Articles, float64(status.Bytes)/(1<<20), float64(status.MaxBytes)/(1<<20))
+	case conf.CacheClear:
+		unlock, err := lockState()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer unlock()
+		err = cache.Clear()
+		if err != nil {
+			panic(err)
+		}
+	case conf.CacheGC:
+		c, err := cacheCipher()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+
+		unlock, err := lockState()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer unlock()
+		path := filepath.Join(configDir, "mirror.db")
+		before := fileSize(path)
+		err = compactMirror(c)
+		if err != nil {
+			panic(err)
+		}
+		m, err := openMirror()
+		if err != nil {
+			panic(err)
+		}
+		defer m.Close()
+		removed, err := trimArticleCache(m, cache)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Removed %d articles; compacted the mirror from %.1f to %.1f MB\n", removed, float64(before)/(1<<20), float64(fileSize(path))/(1<<20))
+	case conf.Encrypt:
+		c, err := cacheCipher()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if c == nil {
+			exitWithError(conf, errors.New(`set "cache": {"encrypt": true} in config.json first`))
+		}
+
+		unlock, err := lockState()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer unlock()
+		err = compactMirror(c)
+		if err != nil {
+			panic(err)
+		}
+		m, err := openMirror()
+		if err != nil {
+			panic(err)
+		}
+		defer m.Close()
+		items, err := m.Items()
+		if err != nil {
+			panic(err)
+		}
+		articles, err := cache.Rewrite()
+		if err != nil {
+			panic(err)
+		}
+		err = refreshSearchIndex(m)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Encrypted %d items and %d articles\n", len(items), articles)
+	case conf.WordCount:
+		m, err := openMirror()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		defer m.Close()
+		beginCritical()
+		result, err := m.BackfillWordCounts(client, cache, func(article *api.Article) int {
+			return len(strings.Fields(articleText(article.HTML)))
+		})
+		endCritical()
+		if err != nil && !errors.Is(err, mirror.ErrInterrupted) {
+			exitWithError(conf, err)
+		}
+		fmt.Printf("Filled in the word counts of %d of %d articles: %d counted by Pocket, %d from their text; %d failed\n",
+			result.FromAPI+result.FromText, result.Missing, result.FromAPI, result.FromText, result.Failed)
+	}
+}
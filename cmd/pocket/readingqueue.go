@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// readingQueuePath is the file of the reading queue: the items to read
+// next, in the order to read them, kept locally apart from when they were
+// added.
+func readingQueuePath() string {
+	return filepath.Join(configDir, "reading-queue.json")
+}
+
+// loadReadingQueue returns the item IDs of the reading queue, in order.
+func loadReadingQueue() ([]int, error) {
+	ids := []int{}
+	err := loadJSONFromFile(readingQueuePath(), &ids)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func saveReadingQueue(ids []int) error {
+	return saveJSONToFile(readingQueuePath(), ids)
+}
+
+// queuedItems returns the unread items of the reading queue in its order,
+// taking those archived or gone from Pocket off the queue.
+func queuedItems(client *api.Client) ([]api.Item, error) {
+	ids, err := loadReadingQueue()
+	if err != nil {
+		return nil, err
+	}
+	items, missing, err := collectionItems(client, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	unread, done := []api.Item{}, missing
+	for _, item := range items {
+		if item.Status == api.ItemStatusUnread {
+			unread = append(unread, item)
+		} else {
+			done = append(done, item.ItemID)
+		}
+	}
+	if len(done) > 0 {
+		ids, _ = removeFromCollection(ids, done)
+		if err := saveReadingQueue(ids); err != nil {
+			return nil, err
+		}
+	}
+	return unread, nil
+}
+
+// popReadingQueue takes the next item to read off the reading queue,
+// returning nil if it is empty.
+func popReadingQueue(client *api.Client) (*api.Item, error) {
+	items, err := queuedItems(client)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], unqueue(items[0].ItemID)
+}
+
+// unqueue takes an item off the reading queue.
+func unqueue(itemID int) error {
+	ids, err := loadReadingQueue()
+	if err != nil {
+		return err
+	}
+	ids, _ = removeFromCollection(ids, []int{itemID})
+	return saveReadingQueue(ids)
+}
+
+func commandQueue(conf Config, client *api.Client) {
+	ids, err := loadReadingQueue()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	switch {
+	case conf.Push:
+		push, err := readItemIDs(conf.ItemIDs, os.Stdin)
+		if err != nil {
+			exitWithError(conf, &usageError{command: "queue", err: err})
+		}
+		var pushed int
+		ids, pushed = addToCollection(ids, push)
+		if err := saveReadingQueue(ids); err != nil {
+			exitWithError(conf, err)
+		}
+		fmt.Printf("Queued %d items, %d in all\n", pushed, len(ids))
+
+	case conf.Pop:
+		checkListingOutput(conf.Output)
+		item, err := popReadingQueue(client)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if item == nil {
+			fmt.Fprintln(os.Stderr, "The reading queue is empty")
+			os.Exit(1)
+		}
+		if conf.Output == "ids" {
+			fmt.Println(item.ItemID)
+			return
+		}
+		fmt.Printf("[%9d] %s\n            <%s>\n", item.ItemID, hyperlink(item.URL(), item.Title()), item.URL())
+
+	case conf.Move:
+		moved, ok := moveInCollection(ids, conf.ItemID, conf.Position)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Item %d is not in the reading queue\n", conf.ItemID)
+			os.Exit(1)
+		}
+		if err := saveReadingQueue(moved); err != nil {
+			exitWithError(conf, err)
+		}
+		fmt.Printf("Moved item %d to position %d of the reading queue\n", conf.ItemID, min(max(conf.Position, 1), len(moved)))
+
+	default:
+		checkOutput(conf, "queue")
+		items, err := queuedItems(client)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if conf.Output != "text" {
+			renderOutput(conf, "queue", itemsResult(items))
+			return
+		}
+		if len(items) == 0 {
+			fmt.Println(`The reading queue is empty; add to it with "pocket queue push <item-id>..."`)
+		}
+		for i, item := range items {
+			fmt.Printf("%3d. [%9d] %s\n       <%s>\n", i+1, item.ItemID, hyperlink(item.URL(), item.Title()), item.URL())
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+)
+
+// minutesFilter makes retrieveItems keep only the items taking at least Min
+// and at most Max minutes to read, as given to --min-minutes and
+// --max-minutes; zero is no limit.
+var minutesFilter struct{ Min, Max int }
+
+// checkMinutes checks the limits of the reading time.
+func checkMinutes(min, max int) error {
+	if min < 0 || max < 0 {
+		return fmt.Errorf("reading times must not be negative")
+	}
+	if max > 0 && min > max {
+		return fmt.Errorf("--min-minutes %d is more than --max-minutes %d", min, max)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/readwise"
+)
+
+// readwiseState records the highlights already pushed to Readwise.
+type readwiseState struct {
+	// Pushed are the IDs of the annotations pushed.
+	Pushed map[string]bool `json:"pushed"`
+}
+
+func readwiseStatePath() string {
+	return filepath.Join(configDir, "readwise.json")
+}
+
+// readwiseHighlights returns the highlights of items not pushed yet, along
+// with their annotation IDs.
+func readwiseHighlights(items []api.Item, pushed map[string]bool) ([]readwise.Highlight, []string) {
+	highlights := []readwise.Highlight{}
+	ids := []string{}
+	for _, item := range items {
+		for _, a := range item.Annotations {
+			if pushed[a.AnnotationID] {
+				continue
+			}
+			h := readwise.Highlight{
+				Text:       a.Quote,
+				Title:      item.Title(),
+				SourceURL:  item.URL(),
+				SourceType: "pocket",
+				Category:   "articles",
+			}
+			if t, err := time.ParseInLocation("2006-01-02 15:04:05", a.CreatedAt, time.UTC); err == nil {
+				h.HighlightedAt = &t
+			}
+			highlights = append(highlights, h)
+			ids = append(ids, a.AnnotationID)
+		}
+	}
+	return highlights, ids
+}
+
+// pushHighlights pushes the highlights of items not pushed before to
+// Readwise, recording them as pushed after each batch, and returns the
+// number pushed.
+func pushHighlights(token string, items []api.Item) (int, error) {
+	if token == "" {
+		return 0, errors.New("set readwise.token in config.json to the access token from https://readwise.io/access_token")
+	}
+	items, err := withLocalHighlights(items)
+	if err != nil {
+		return 0, err
+	}
+
+	state := &readwiseState{}
+	err = loadJSONFromFile(readwiseStatePath(), state)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if state.Pushed == nil {
+		state.Pushed = map[string]bool{}
+	}
+
+	highlights, ids := readwiseHighlights(items, state.Pushed)
+	if len(highlights) == 0 {
+		return 0, nil
+	}
+
+	pushed := 0
+	err = readwise.NewClient(token).Create(highlights, func(sent int) {
+		for _, id := range ids[pushed:sent] {
+			state.Pushed[id] = true
+		}
+		pushed = sent
+		if err := saveJSONToFile(readwiseStatePath(), state); err != nil {
+			logFatal("Could not record the highlights pushed", err)
+		}
+	})
+	return pushed, err
+}
+
+func commandPushHighlights(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:       api.StateAll,
+		Domain:      conf.Domain,
+		Search:      conf.SearchQuery,
+		Tag:         conf.Tag,
+		DetailType:  api.DetailTypeComplete,
+		Annotations: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if conf.DryRun {
+		items, err := withLocalHighlights(items)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		state := &readwiseState{}
+		err = loadJSONFromFile(readwiseStatePath(), state)
+		if err != nil && !os.IsNotExist(err) {
+			exitWithError(conf, err)
+		}
+		highlights, _ := readwiseHighlights(items, state.Pushed)
+		fmt.Printf("Would push %d highlights to Readwise\n", len(highlights))
+		return
+	}
+
+	pushed, err := pushHighlights(settings.Readwise.Token, items)
+	if err != nil {
+		if pushed > 0 {
+			fmt.Printf("Pushed %d highlights to Readwise before failing\n", pushed)
+		}
+		exitWithError(conf, err)
+	}
+	fmt.Printf("Pushed %d highlights to Readwise\n", pushed)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingsPollInterval is how often the daemon and serve look for changes to
+// config.json and rules.json.
+const settingsPollInterval = 2 * time.Second
+
+// restartSettings are the settings read once as the daemon or serve starts,
+// whose changes are only logged as waiting for a restart.
+var restartSettings = []string{
+	"bot", "telegram", "mail_in", "clipboard.watch", "hooks", "routes", "http",
+	"cache.encrypt", "read_only", "normalize_titles", "confirm",
+}
+
+// liveSettings are the settings of the daemon or serve, replaced as
+// config.json and rules.json change, for iterating on the rules without
+// restarting them.
+type liveSettings struct {
+	mu       sync.RWMutex
+	settings *Settings
+	// validate checks new settings, which are not used if it fails.
+	validate func(*Settings) error
+	// changed is sent to when the settings have been replaced.
+	changed chan struct{}
+	// stamps are the sizes and modification times of the files last read.
+	stamps []string
+}
+
+// watchSettings returns settings, replaced in the background as the files
+// they were read from change, if validate accepts the new ones.
+func watchSettings(settings *Settings, validate func(*Settings) error) *liveSettings {
+	l := &liveSettings{settings: settings, validate: validate, changed: make(chan struct{}, 1), stamps: settingsStamps()}
+	go func() {
+		for range time.Tick(settingsPollInterval) {
+			l.poll()
+		}
+	}()
+	return l
+}
+
+// Get returns the current settings, which are not to be modified.
+func (l *liveSettings) Get() *Settings {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.settings
+}
+
+// settingsStamps returns the size and modification time of config.json and
+// rules.json, to tell when they change.
+func settingsStamps() []string {
+	stamps := []string{}
+	for _, path := range []string{filepath.Join(settingsDir, "config.json"), rulesFile()} {
+		stamp := "missing"
+		if fi, err := os.Stat(path); err == nil {
+			stamp = fmt.Sprint(fi.ModTime().UnixNano(), fi.Size())
+		}
+		stamps = append(stamps, stamp)
+	}
+	return stamps
+}
+
+// poll reloads the settings if their files changed since last read, keeping
+// the current ones if the new ones cannot be read or are invalid.
+func (l *liveSettings) poll() {
+	stamps := settingsStamps()
+	if slices.Equal(stamps, l.stamps) {
+		return
+	}
+	l.stamps = stamps
+
+	settings, err := loadSettings()
+	if err == nil && l.validate != nil {
+		err = l.validate(settings)
+	}
+	if err != nil {
+		slog.Warn("Not reloading the settings, keeping those in use", "err", err)
+		return
+	}
+
+	l.mu.Lock()
+	old := l.settings
+	l.settings = settings
+	l.mu.Unlock()
+	logSettingsChanges(old, settings)
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
+// logSettingsChanges logs what differs between the settings old and new: the
+// settings by their paths in config.json, as "notify.tags", and the rules
+// by their names.
+func logSettingsChanges(old, new *Settings) {
+	changed := settingsDiff("", jsonValue(old), jsonValue(new))
+	changed = slices.DeleteFunc(changed, func(path string) bool { return path == "rules" || strings.HasPrefix(path, "rules.") })
+	added, removed, modified := rulesDiff(old.Rules, new.Rules)
+	if len(changed)+len(added)+len(removed)+len(modified) == 0 {
+		slog.Info("Reloaded the settings; nothing changed")
+		return
+	}
+
+	args := []any{}
+	if len(changed) > 0 {
+		args = append(args, "changed", changed)
+	}
+	if len(added) > 0 {
+		args = append(args, "rules_added", added)
+	}
+	if len(removed) > 0 {
+		args = append(args, "rules_removed", removed)
+	}
+	if len(modified) > 0 {
+		args = append(args, "rules_changed", modified)
+	}
+	slog.Info("Reloaded the settings", args...)
+
+	restart := []string{}
+	for _, path := range changed {
+		for _, prefix := range restartSettings {
+			if path == prefix || strings.HasPrefix(path, prefix+".") {
+				restart = append(restart, path)
+				break
+			}
+		}
+	}
+	if len(restart) > 0 {
+		slog.Warn("Restart to apply some of the changes", "changed", restart)
+	}
+}
+
+// jsonValue returns v as decoded from its JSON, for settingsDiff.
+func jsonValue(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var value any
+	json.Unmarshal(b, &value)
+	return value
+}
+
+// settingsDiff returns the paths under prefix of the values differing
+// between old and new, going into objects but not arrays. The values
+// themselves are left out, as they may be tokens.
+func settingsDiff(prefix string, old, new any) []string {
+	oldObject, ok1 := old.(map[string]any)
+	newObject, ok2 := new.(map[string]any)
+	if !ok1 || !ok2 {
+		if reflect.DeepEqual(old, new) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	keys := []string{}
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	paths := []string{}
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		paths = append(paths, settingsDiff(path, oldObject[key], newObject[key])...)
+	}
+	return paths
+}
+
+// rulesDiff returns the labels of the rules added, removed, and changed,
+// matching them by their labels.
+func rulesDiff(old, new []Rule) (added, removed, changed []string) {
+	byLabel := map[string]Rule{}
+	for i, rule := range old {
+		byLabel[rule.label(i)] = rule
+	}
+	for i, rule := range new {
+		label := rule.label(i)
+		previous, ok := byLabel[label]
+		switch {
+		case !ok:
+			added = append(added, label)
+		case !reflect.DeepEqual(previous, rule):
+			changed = append(changed, label)
+		}
+		delete(byLabel, label)
+	}
+	for label := range byLabel {
+		removed = append(removed, label)
+	}
+	sort.Strings(removed)
+	return added, removed, changed
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// addItemsWithState adds items along with their favorite and archive state,
+// tagged as added by the import run runID, calling done with each batch and
+// those of its items not added. Each batch is added and given its state
+// together, so that adding again after an interrupt skips only complete
+// items. Batches over --max-api-calls are not sent, failing with
+// errOverBudget.
+func addItemsWithState(client *api.Client, items []api.Item, runID string, done func(batch, failed []api.Item)) error {
+	// A request adding each batch, and another giving it its state
+	batches := (len(items) + modifyBatchSize - 1) / modifyBatchSize
+	checkQuota(2 * batches)
+	sendable := fitBudget(batches, 2)
+	for start := 0; start < len(items); start += modifyBatchSize {
+		if start/modifyBatchSize == sendable {
+			return errOverBudget
+		}
+		end := min(start+modifyBatchSize, len(items))
+		actions := make([]*api.Action, 0, end-start)
+		for _, item := range items[start:end] {
+			actions = append(actions, library.AddAction(item, importTagPrefix+runID))
+		}
+
+		err := func() error {
+			beginCritical()
+			defer endCritical()
+
+			results, err := modifyInBatches(client, actions)
+			if err != nil {
+				return err
+			}
+
+			failed := []api.Item{}
+			states := []*api.Action{}
+			for i, r := range results {
+				if r.Success && r.ItemID != 0 {
+					states = append(states, library.StateActions(items[start+i], r.ItemID, nil)...)
+				} else {
+					failed = append(failed, items[start+i])
+				}
+			}
+
+			_, err = modifyInBatches(client, states)
+			if err != nil {
+				return err
+			}
+			done(items[start:end], failed)
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func commandRestore(conf Config, client *api.Client) {
+	switch conf.Conflict {
+	case "merge", "update", "skip":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown conflict policy %q; use \"merge\", \"update\", or \"skip\"\n", conf.Conflict)
+		os.Exit(1)
+	}
+
+	backup := []api.Item{}
+	err := loadJSONFromFile(conf.File, &backup)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	path, err := filepath.Abs(conf.File)
+	if err != nil {
+		panic(err)
+	}
+	j, err := openJournal(conf, "restore", path)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	// Items done by the run resumed are left as they are
+	todo := []api.Item{}
+	for _, item := range backup {
+		if !j.done(item.URL()) {
+			todo = append(todo, item)
+		}
+	}
+	backup = todo
+
+	existing, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	byURL := map[string]api.Item{}
+	for _, item := range existing {
+		byURL[library.URLKey(item.URL())] = item
+	}
+
+	// Items saved more than once in the backup are added once, with the
+	// tags and state of all
+	adds := []api.Item{}
+	pending := map[string]int{}
+	duplicates := 0
+	for _, item := range backup {
+		key := library.URLKey(item.URL())
+		if i, found := pending[key]; found {
+			library.MergeItem(&adds[i], item)
+			duplicates++
+			continue
+		}
+		if _, found := byURL[key]; found {
+			continue
+		}
+		pending[key] = len(adds)
+		adds = append(adds, item)
+	}
+
+	// Items already in Pocket get the tags and state they are missing,
+	// unless skipped
+	updates := []*api.Action{}
+	present := []string{}
+	merged, skipped := 0, 0
+	for _, item := range backup {
+		key := library.URLKey(item.URL())
+		current, found := byURL[key]
+		if !found {
+			continue
+		}
+		present = append(present, item.URL())
+
+		actions := library.StateActions(item, current.ItemID, &current)
+		if conf.Conflict == "skip" || len(actions) == 0 {
+			skipped++
+			continue
+		}
+		updates = append(updates, actions...)
+		merged++
+		// Later duplicates only merge what this one did not
+		library.MergeItem(&current, item)
+		byURL[key] = current
+	}
+
+	summary := newBulkSummary("restore")
+	_, err = modifyInBatches(client, updates)
+	if err == errOverBudget {
+		summary.addNotSent(merged + len(adds))
+		summary.report(conf.Summary)
+	}
+	if err != nil {
+		panic(err)
+	}
+	j.markDone(present...)
+	summary.Processed += merged + skipped
+	summary.Succeeded += merged
+	summary.Skipped += skipped
+
+	restored := 0
+	onShutdown(func() {
+		fmt.Printf("Restored %d of %d items before being interrupted; restore again with --resume to add the rest\n", restored, len(adds))
+	})
+
+	sent := 0
+	runID := importRunID(j.Started, "restore")
+	err = addItemsWithState(client, adds, runID, func(batch, failed []api.Item) {
+		restored += len(batch) - len(failed)
+		sent += len(batch)
+		summary.addAdded(batch, failed)
+		j.markAdded(batch, failed)
+	})
+	if err == errOverBudget {
+		summary.addNotSent(len(adds) - sent)
+	} else if err != nil {
+		panic(err)
+	}
+
+	// Those failed or not sent are tried again by --resume
+	if summary.complete() {
+		j.finish()
+	}
+	if duplicates > 0 {
+		fmt.Printf("Merged %d items saved more than once in the backup\n", duplicates)
+	}
+	reportImportRun(runID, restored)
+	summary.report(conf.Summary)
+}
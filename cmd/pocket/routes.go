@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/webhook"
+)
+
+// tagRoutes is the routes setting, set up in main.
+var tagRoutes []TagRoute
+
+// routeEvent is the event of the webhook requests of routes.
+const routeEvent = "routed"
+
+// TagRoute sends the items a sync sees given a tag, or added with it,
+// somewhere else: appended to a file, to a webhook, or both.
+type TagRoute struct {
+	Tag string `json:"tag"`
+	// Append is a file the items are appended to, relative to the config
+	// directory unless absolute or starting with "~/".
+	Append string `json:"append"`
+	// Format is how the items are appended: "markdown", a list entry, by
+	// default; "org", a list entry too; or "json", a line each.
+	Format  string        `json:"format"`
+	Webhook *webhook.Hook `json:"webhook"`
+}
+
+// routePayload is the data of the webhook request of an item routed.
+type routePayload struct {
+	Tag    string   `json:"tag"`
+	ItemID int      `json:"item_id"`
+	Title  string   `json:"title"`
+	URL    string   `json:"url"`
+	Tags   []string `json:"tags"`
+	Added  api.Time `json:"time_added"`
+}
+
+func newRoutePayload(tag string, item api.Item) routePayload {
+	return routePayload{Tag: tag, ItemID: item.ItemID, Title: item.Title(), URL: item.URL(), Tags: item.TagNames(), Added: item.TimeAdded}
+}
+
+// routePath resolves the file of a route.
+func routePath(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	return filepath.Join(configDir, path), nil
+}
+
+// writeRouteEntry writes item as an entry of the file of route.
+func writeRouteEntry(w io.Writer, item api.Item, route TagRoute) error {
+	var err error
+	switch route.Format {
+	case "", "markdown":
+		_, err = fmt.Fprintf(w, "- [%s](%s)\n", strings.NewReplacer("[", `\[`, "]", `\]`).Replace(item.Title()), item.URL())
+	case "org":
+		_, err = fmt.Fprintf(w, "- [[%s][%s]]\n", item.URL(), strings.NewReplacer("[", "{", "]", "}").Replace(item.Title()))
+	case "json":
+		err = json.NewEncoder(w).Encode(newRoutePayload(route.Tag, item))
+	default:
+		err = fmt.Errorf(`unknown route format %q; use "markdown", "org", or "json"`, route.Format)
+	}
+	return err
+}
+
+// appendRouted appends items to the file of a route.
+func appendRouted(route TagRoute, items []api.Item) error {
+	path, err := routePath(route.Append)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := writeRouteEntry(f, item, route); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// routeItems sends the items given tags through the routes of those tags,
+// returning the number of items routed. tagged has the tags given to each
+// item by ID, nil for those new to the mirror, which were given all of
+// theirs. Failures are logged, not to stop the sync.
+func routeItems(m *mirror.Mirror, routes []TagRoute, tagged map[int][]string) (int, error) {
+	if len(routes) == 0 || len(tagged) == 0 {
+		return 0, nil
+	}
+	items, err := m.Items()
+	if err != nil {
+		return 0, err
+	}
+
+	routed := map[int]bool{}
+	for _, route := range routes {
+		matched := []api.Item{}
+		for _, item := range items {
+			given, ok := tagged[item.ItemID]
+			if !ok {
+				continue
+			}
+			if given == nil {
+				given = item.TagNames()
+			}
+			for _, tag := range given {
+				if tag == route.Tag {
+					matched = append(matched, item)
+					break
+				}
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if route.Append != "" {
+			if err := appendRouted(route, matched); err != nil {
+				slog.Warn("Could not append to the file of a route", "tag", route.Tag, "file", route.Append, "err", err)
+			}
+		}
+		if route.Webhook != nil {
+			sender := webhook.NewSender([]webhook.Hook{*route.Webhook})
+			for _, item := range matched {
+				if err := sender.Send(routeEvent, newRoutePayload(route.Tag, item)); err != nil {
+					slog.Warn("Webhook delivery failed", "event", routeEvent, "tag", route.Tag, "item_id", item.ItemID, "err", err)
+				}
+			}
+		}
+		for _, item := range matched {
+			routed[item.ItemID] = true
+		}
+	}
+	return len(routed), nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// Rule is an automatic action applied to items by "pocket rules run" and by
+// the daemon. Every condition that is set must match.
+type Rule struct {
+	// Name identifies the rule in reports; rules without one are numbered.
+	Name string `json:"name"`
+
+	Domain        string `json:"domain"`
+	Search        string `json:"search"`
+	Tag           string `json:"tag"`
+	OlderThanDays int    `json:"older_than_days"`
+	// MinWords and MaxWords bound the word count of the article. Items
+	// whose word count is unknown never match them.
+	MinWords int `json:"min_words"`
+	MaxWords int `json:"max_words"`
+	// State is the state of the items the rule applies to: unread, the
+	// default, archive, or all.
+	State api.State `json:"state"`
+
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	Archive    bool     `json:"archive"`
+	Favorite   bool     `json:"favorite"`
+	Delete     bool     `json:"delete"`
+	// Snooze archives unread items until a date or for a period, as given
+	// to "pocket snooze".
+	Snooze string `json:"snooze"`
+}
+
+// ruleCount is how many items a rule matched, and the actions it took on
+// them.
+type ruleCount struct {
+	Rule    string
+	Matched int
+	Actions int
+}
+
+// rulesFile is the file of rules applied in addition to those of config.json.
+func rulesFile() string {
+	return filepath.Join(settingsDir, "rules.json")
+}
+
+// loadRules reads the rules file, which holds an array of rules. A missing
+// file has no rules.
+func loadRules() ([]Rule, error) {
+	rules := []Rule{}
+	err := loadJSONFromFile(rulesFile(), &rules)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", rulesFile(), err)
+	}
+	return rules, nil
+}
+
+func (r Rule) label(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+func (r Rule) validate() error {
+	switch r.State {
+	case "", api.StateUnread, api.StateArchive, api.StateAll:
+	default:
+		return fmt.Errorf("unknown state %q; use unread, archive, or all", r.State)
+	}
+	if r.Snooze != "" {
+		if _, err := parseUntil(r.Snooze, time.Now()); err != nil {
+			return err
+		}
+	}
+	if len(r.AddTags) == 0 && len(r.RemoveTags) == 0 && !r.Archive && !r.Favorite && !r.Delete && r.Snooze == "" {
+		return errors.New("no action")
+	}
+	return nil
+}
+
+// validateRules reports every invalid rule.
+func validateRules(rules []Rule) error {
+	problems := []string{}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", rule.label(i), err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// matches reports whether item meets every condition of the rule.
+func (r Rule) matches(item api.Item, now time.Time) bool {
+	options := &api.RetrieveOption{State: r.State, Domain: r.Domain, Search: r.Search, Tag: r.Tag}
+	if !mirror.Match(item, options) {
+		return false
+	}
+	if r.OlderThanDays > 0 && now.Sub(item.TimeAdded.Time) < time.Duration(r.OlderThanDays)*24*time.Hour {
+		return false
+	}
+	if (r.MinWords > 0 || r.MaxWords > 0) && item.WordCount == 0 {
+		return false
+	}
+	if r.MinWords > 0 && item.WordCount < r.MinWords {
+		return false
+	}
+	if r.MaxWords > 0 && item.WordCount > r.MaxWords {
+		return false
+	}
+	return true
+}
+
+// actions returns the actions needed to apply the rule to item, if it
+// matches and has not had the rule applied yet.
+func (r Rule) actions(item api.Item, now time.Time) []*api.Action {
+	if !r.matches(item, now) {
+		return nil
+	}
+	if r.Delete {
+		return []*api.Action{api.NewDeleteAction(item.ItemID)}
+	}
+
+	actions := []*api.Action{}
+	missing := []string{}
+	for _, tag := range r.AddTags {
+		if _, ok := item.Tags[tag]; !ok {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) > 0 {
+		actions = append(actions, api.NewTagsAddAction(item.ItemID, missing...))
+	}
+	present := []string{}
+	for _, tag := range r.RemoveTags {
+		if _, ok := item.Tags[tag]; ok {
+			present = append(present, tag)
+		}
+	}
+	if len(present) > 0 {
+		actions = append(actions, api.NewTagsRemoveAction(item.ItemID, present...))
+	}
+	if r.Favorite && item.Favorite == 0 {
+		actions = append(actions, api.NewFavoriteAction(item.ItemID))
+	}
+	if item.Status != api.ItemStatusUnread {
+		return actions
+	}
+	if r.Snooze != "" {
+		if _, _, snoozed := snoozedUntil(item, now.Location()); !snoozed {
+			until, _ := parseUntil(r.Snooze, now)
+			return append(actions, snoozeActions(item.ItemID, until)...)
+		}
+	}
+	if r.Archive {
+		actions = append(actions, api.NewArchiveAction(item.ItemID))
+	}
+	return actions
+}
+
+// evaluateRules returns the actions the rules call for on the items, and
+// what each rule did. Rules apply in order, each to the items as the earlier
+// ones left them.
+func evaluateRules(items []api.Item, rules []Rule, now time.Time) ([]*api.Action, []ruleCount) {
+	counts := make([]ruleCount, len(rules))
+	for i, rule := range rules {
+		counts[i].Rule = rule.label(i)
+	}
+
+	actions := []*api.Action{}
+	for _, item := range items {
+		// The actions of one rule are seen by the next, and not by the
+		// caller, whose items share the map of tags
+		item.Tags = maps.Clone(item.Tags)
+		for i, rule := range rules {
+			if !rule.matches(item, now) {
+				continue
+			}
+			counts[i].Matched++
+			a := rule.actions(item, now)
+			counts[i].Actions += len(a)
+			actions = append(actions, a...)
+			for _, action := range a {
+				if !mirror.ApplyAction(&item, action) {
+					item.Status = api.ItemStatusDeleted
+				}
+			}
+		}
+	}
+	return actions, counts
+}
+
+// ruleActions returns the actions the rules call for on the items in the
+// mirror.
+func ruleActions(m *mirror.Mirror, rules []Rule, now time.Time) ([]*api.Action, []ruleCount, error) {
+	if len(rules) == 0 {
+		return nil, nil, nil
+	}
+
+	items, err := m.Retrieve(&api.RetrieveOption{State: api.StateAll})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	actions, counts := evaluateRules(items, rules, now)
+	return actions, counts, nil
+}
+
+func commandRules(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	rules := settings.Rules
+	if err := validateRules(rules); err != nil {
+		exitWithError(conf, err)
+	}
+	checkDryRunOutput(conf, "rules")
+	if len(rules) == 0 {
+		fmt.Printf("No rules; add them to %s\n", rulesFile())
+		return
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	actions, counts := evaluateRules(items, rules, time.Now())
+
+	// The actions written by --dry-run are all there is on stdout, for
+	// "pocket apply" to take
+	table := os.Stdout
+	if conf.Output != "text" {
+		table = os.Stderr
+	}
+	fmt.Fprintf(table, "%7s  %7s  %s\n", "MATCHED", "ACTIONS", "RULE")
+	for _, c := range counts {
+		fmt.Fprintf(table, "%7d  %7d  %s\n", c.Matched, c.Actions, c.Rule)
+	}
+
+	if !conf.RulesRun || len(actions) == 0 {
+		return
+	}
+	if conf.DryRun && conf.Output != "text" {
+		if err := writeDryRun(os.Stdout, conf.Output, actions, items); err != nil {
+			exitWithError(conf, err)
+		}
+		return
+	}
+	if conf.DryRun {
+		fmt.Printf("Would take %d actions\n", len(actions))
+		return
+	}
+
+	summary := newBulkSummary("rules run")
+	summary.describe(items)
+	results, err := modifyInBatches(client, actions)
+	summary.addResults(actions, results, err)
+	summary.report(conf.Summary)
+}
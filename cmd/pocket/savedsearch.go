@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// options returns the options setting the filters of a saved search.
+func (s SavedSearch) options() []string {
+	options := []string{}
+	for _, option := range [][2]string{
+		{"--domain", s.Domain},
+		{"--tag", s.Tag},
+		{"--search", s.Search},
+		{"--lang", s.Lang},
+		{"--state", s.State},
+		{"--sort", s.Sort},
+	} {
+		if option[1] != "" {
+			options = append(options, option[0]+"="+option[1])
+		}
+	}
+	return options
+}
+
+// query returns the search query matching the items of a saved search, as
+// taken by the search command.
+func (s SavedSearch) query() string {
+	words := []string{}
+	if s.Search != "" {
+		words = append(words, s.Search)
+	}
+	if s.Tag != "" {
+		words = append(words, "tag:"+s.Tag)
+	}
+	if s.Domain != "" {
+		words = append(words, "domain:"+s.Domain)
+	}
+	return strings.Join(words, " ")
+}
+
+// expandSavedSearches replaces each "@name" given to a command filtering
+// items with the options of the saved search, put right after the command so
+// that the options given override them. Options the command does not take,
+// such as --sort for stats, are left out; the search command takes the name
+// as --saved.
+func expandSavedSearches(args []string, searches map[string]SavedSearch) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+	command, ok := findCommand(args[0])
+	if !ok {
+		return args, nil
+	}
+	accepted := map[string]bool{}
+	for _, option := range command.options() {
+		accepted[option.Long] = true
+	}
+	if !accepted["--tag"] && !accepted["--saved"] {
+		return args, nil
+	}
+
+	expanded, rest := []string{args[0]}, []string{}
+	for i, arg := range args[1:] {
+		if arg == "--" {
+			rest = append(rest, args[i+1:]...)
+			break
+		}
+		name, ok := strings.CutPrefix(arg, "@")
+		if !ok || name == "" {
+			rest = append(rest, arg)
+			continue
+		}
+		saved, ok := searches[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown saved search %q; name it under \"searches\" in config.json", name)
+		}
+		if accepted["--saved"] {
+			expanded = append(expanded, "--saved="+name)
+			continue
+		}
+		for _, option := range saved.options() {
+			long, _, _ := strings.Cut(option, "=")
+			if accepted[long] {
+				expanded = append(expanded, option)
+			}
+		}
+	}
+	return append(expanded, rest...), nil
+}
+
+// savedSearchNames returns the names of the saved searches in order.
+func savedSearchNames(searches map[string]SavedSearch) []string {
+	names := make([]string, 0, len(searches))
+	for name := range searches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	// Time zones are named in config.json on Windows too, which has no
+	// database of them
+	_ "time/tzdata"
+
+	"github.com/motemen/go-pocket/schedule"
+)
+
+// daemonSchedule is when the daemon runs what, as DaemonSettings say. A nil
+// schedule leaves the default: syncing every --interval, notifying the
+// digest a week after the last, and running the bulk jobs with every sync.
+type daemonSchedule struct {
+	sync, digest, bulk *schedule.Schedule
+	quiet              *schedule.Window
+}
+
+// schedule parses the settings, the errors naming the setting at fault.
+func (s DaemonSettings) schedule() (*daemonSchedule, error) {
+	loc := time.Local
+	if s.TimeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(s.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("daemon.time_zone: unknown time zone %q", s.TimeZone)
+		}
+	}
+
+	sched := &daemonSchedule{}
+	for _, field := range []struct {
+		name string
+		expr string
+		to   **schedule.Schedule
+	}{
+		{"sync", s.Sync, &sched.sync},
+		{"digest", s.Digest, &sched.digest},
+		{"bulk", s.Bulk, &sched.bulk},
+	} {
+		if field.expr == "" {
+			continue
+		}
+		parsed, err := schedule.Parse(field.expr, loc)
+		if err != nil {
+			return nil, fmt.Errorf("daemon.%s: %w", field.name, err)
+		}
+		*field.to = parsed
+	}
+	if s.QuietHours != "" {
+		var err error
+		sched.quiet, err = schedule.ParseWindow(s.QuietHours, loc)
+		if err != nil {
+			return nil, fmt.Errorf("daemon.quiet_hours: %w", err)
+		}
+	}
+	return sched, nil
+}
+
+// quietAt reports whether t is in the quiet hours.
+func (d *daemonSchedule) quietAt(t time.Time) bool {
+	return d.quiet != nil && d.quiet.Contains(t)
+}
+
+// nextAfter returns the first time of s after last, or after start if there
+// was none, the run when the daemon started.
+func nextAfter(s *schedule.Schedule, last, start time.Time) time.Time {
+	if last.IsZero() {
+		last = start
+	}
+	return s.Next(last)
+}
+
+// due reports whether a run of s after last is due by now.
+func due(s *schedule.Schedule, last, start, now time.Time) bool {
+	next := nextAfter(s, last, start)
+	return !next.IsZero() && !next.After(now)
+}
+
+// bulkDue reports whether the bulk jobs run at now.
+func (d *daemonSchedule) bulkDue(status *DaemonStatus, now time.Time) bool {
+	return d.bulk == nil || due(d.bulk, status.LastBulk, status.StartedAt, now)
+}
+
+// digestDue reports whether the weekly digest is notified at now, held in
+// the quiet hours.
+func (d *daemonSchedule) digestDue(status *DaemonStatus, now time.Time) bool {
+	if d.quietAt(now) {
+		return false
+	}
+	if d.digest == nil {
+		return now.Sub(status.LastDigest) >= 7*24*time.Hour
+	}
+	return due(d.digest, status.LastDigest, status.StartedAt, now)
+}
+
+// nextRun returns when the daemon runs next, after a run at now: at the
+// next time of the sync schedule, or sooner to try a failed sync again
+// after wait, or else after wait; sooner still if the digest or the bulk
+// jobs are due before.
+func (d *daemonSchedule) nextRun(status *DaemonStatus, settings *Settings, bulk bool, wait time.Duration, now time.Time) time.Time {
+	next := now.Add(wait)
+	if d.sync != nil {
+		if scheduled := d.sync.Next(now); !scheduled.IsZero() && (status.Failures == 0 || scheduled.Before(next)) {
+			next = scheduled
+		}
+	}
+
+	sooner := func(t time.Time) {
+		if t.After(now) && t.Before(next) {
+			next = t
+		}
+	}
+	if d.digest != nil && settings.Notify.WeeklyDigest {
+		t := nextAfter(d.digest, status.LastDigest, status.StartedAt)
+		if !t.After(now) {
+			// Held in the quiet hours
+			t = now
+		}
+		if d.quietAt(t) {
+			t = d.quiet.End(t)
+		}
+		sooner(t)
+	}
+	if d.bulk != nil && bulk {
+		sooner(nextAfter(d.bulk, status.LastBulk, status.StartedAt))
+	}
+	return next
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/render"
+	"github.com/motemen/go-pocket/search"
+)
+
+// searchIndexPath is where the search index is kept. The index is optional:
+// it is created by the first search and refreshed by sync while it exists.
+func searchIndexPath() string {
+	return filepath.Join(configDir, "search.idx")
+}
+
+// buildSearchIndex indexes all items in the mirror, along with their article
+// text if it is cached, and saves the index.
+func buildSearchIndex(m *mirror.Mirror) (*search.Index, error) {
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := openArticleCache()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := search.New()
+	for _, item := range items {
+		doc := search.Document{
+			ID:      item.ItemID,
+			Title:   item.Title(),
+			Excerpt: item.Excerpt,
+			Tags:    item.TagNames(),
+			Domain:  item.Domain(),
+		}
+		if article, err := cache.Peek(item.ItemID); err == nil {
+			doc.Text = articleText(article.HTML)
+		}
+		idx.Add(doc)
+	}
+
+	var buf bytes.Buffer
+	err = idx.Save(&buf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := mirror.Seal(cache.Cipher, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, atomicfile.WriteFile(searchIndexPath(), b, 0600)
+}
+
+// loadSearchIndex loads the saved search index, building it if there is none.
+func loadSearchIndex(m *mirror.Mirror) (*search.Index, error) {
+	b, err := os.ReadFile(searchIndexPath())
+	if os.IsNotExist(err) {
+		return buildSearchIndex(m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := cacheCipher()
+	if err != nil {
+		return nil, err
+	}
+	b, err = mirror.Unseal(c, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return search.Load(bytes.NewReader(b))
+}
+
+// refreshSearchIndex rebuilds the search index if it has been created.
+func refreshSearchIndex(m *mirror.Mirror) error {
+	if _, err := os.Stat(searchIndexPath()); err != nil {
+		return nil
+	}
+	_, err := buildSearchIndex(m)
+	return err
+}
+
+type searchHit struct {
+	api.Item
+	Score float64 `json:"score"`
+}
+
+// hitsResult is the result of a search, the items found with their scores.
+func hitsResult(hits []searchHit) *render.Result {
+	items := make([]api.Item, len(hits))
+	for i, hit := range hits {
+		items[i] = hit.Item
+	}
+	result := itemsResult(items)
+	result.Value = hits
+	result.Columns = append(result.Columns, "score")
+	for i, hit := range hits {
+		result.Rows[i] = append(result.Rows[i], strconv.FormatFloat(hit.Score, 'f', 3, 64))
+		result.Records[i] = hit
+	}
+	return result
+}
+
+// topFacets formats the n most common facet values as "name (count)".
+func topFacets(counts map[string]int, n int) string {
+	entries := topCounts(counts, n)
+	parts := make([]string, len(entries))
+	for i, e := range entries {
+		parts[i] = fmt.Sprintf("%s (%d)", e.Name, e.Count)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// searchMirror runs query against the search index of the mirror, returning
+// at most limit items (all if limit is zero), the total number of matches,
+// and their facets.
+func searchMirror(m *mirror.Mirror, query string, limit int) ([]searchHit, int, search.Facets, error) {
+	idx, err := loadSearchIndex(m)
+	if err != nil {
+		return nil, 0, search.Facets{}, err
+	}
+
+	results, facets := idx.Search(search.ParseQuery(query))
+
+	items, err := m.Items()
+	if err != nil {
+		return nil, 0, search.Facets{}, err
+	}
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+
+	hits := []searchHit{}
+	for _, r := range results {
+		item, ok := byID[r.ID]
+		if !ok {
+			// The index is older than the mirror; skip removed items.
+			continue
+		}
+		hits = append(hits, searchHit{Item: item, Score: r.Score})
+		if limit > 0 && len(hits) == limit {
+			break
+		}
+	}
+
+	return hits, len(results), facets, nil
+}
+
+// escBoldYellow highlights the words matched by a search.
+const escBoldYellow = "\x1b[1;33m"
+
+// styledStdout tells whether stdout is a terminal to highlight matches on.
+func styledStdout() bool {
+	return term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == "" && !screenReader
+}
+
+// apiSearch runs query through the search parameter of the API, which
+// matches one string against titles and URLs: the longest phrase or word of
+// the query is sent, and the items are narrowed down to those matching the
+// rest of it here.
+func apiSearch(client *api.Client, query search.Query, state api.State, limit int) ([]searchHit, int, search.Facets, error) {
+	words := append([]string{}, query.Terms...)
+	for _, phrase := range query.Phrases {
+		words = append(words, strings.Join(phrase, " "))
+	}
+	sort.SliceStable(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+
+	options := &api.RetrieveOption{State: state, DetailType: api.DetailTypeComplete}
+	if len(words) > 0 {
+		options.Search = words[0]
+	}
+	if len(query.Tags) > 0 {
+		options.Tag = query.Tags[0]
+	}
+	if len(query.Domains) > 0 {
+		options.Domain = query.Domains[0]
+	}
+	items, err := retrieveItems(client, options)
+	if err != nil {
+		return nil, 0, search.Facets{}, err
+	}
+
+	hits := []searchHit{}
+	facets := search.Facets{Tags: map[string]int{}, Domains: map[string]int{}}
+	for _, item := range items {
+		if !matchesAPISearch(item, words, query) {
+			continue
+		}
+		for _, tag := range item.TagNames() {
+			facets.Tags[tag]++
+		}
+		facets.Domains[item.Domain()]++
+		if limit == 0 || len(hits) < limit {
+			hits = append(hits, searchHit{Item: item})
+		}
+	}
+	total := 0
+	for _, n := range facets.Domains {
+		total += n
+	}
+	return hits, total, facets, nil
+}
+
+// matchesAPISearch reports whether item matches all of the words and tags
+// and one of the domains of a query, as the API matches one of them.
+func matchesAPISearch(item api.Item, words []string, query search.Query) bool {
+	text := strings.ToLower(item.Title() + " " + item.URL())
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	for _, tag := range query.Tags {
+		if _, ok := item.Tags[tag]; !ok {
+			return false
+		}
+	}
+	if len(query.Domains) == 0 {
+		return true
+	}
+	for _, domain := range query.Domains {
+		if item.Domain() == domain || strings.HasSuffix(item.Domain(), "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight marks the words of s for which match is true, if styled.
+func highlight(s string, match func(word string) bool, styled bool) string {
+	if !styled {
+		return s
+	}
+
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsNumber(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsNumber(runes[j])) {
+			j++
+		}
+		word := string(runes[i:j])
+		if match(strings.ToLower(word)) {
+			b.WriteString(escBoldYellow + word + escReset)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// highlightMatches marks the occurrences of substr in s, ignoring case, if
+// styled, as the search parameter of the API matches it.
+func highlightMatches(s, substr string, styled bool) string {
+	if !styled || substr == "" {
+		return s
+	}
+
+	var b strings.Builder
+	for {
+		i, n := indexFold(s, substr)
+		if i < 0 {
+			break
+		}
+		b.WriteString(s[:i] + escBoldYellow + s[i:i+n] + escReset)
+		s = s[i+n:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// indexFold returns the index and length in s of the first occurrence of
+// substr, ignoring case, or -1.
+func indexFold(s, substr string) (int, int) {
+	n := utf8.RuneCountInString(substr)
+	for i := range s {
+		end, runes := i, 0
+		for end < len(s) && runes < n {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+			runes++
+		}
+		if runes < n {
+			break
+		}
+		if strings.EqualFold(s[i:end], substr) {
+			return i, end - i
+		}
+	}
+	return -1, 0
+}
+
+// searchSnippet returns the part of excerpt around the first occurrence of
+// query, or "" if there is none.
+func searchSnippet(excerpt, query string) string {
+	if i, _ := indexFold(excerpt, query); i < 0 {
+		return ""
+	}
+	words := search.Tokenize(query)
+	return excerptSnippet(excerpt, func(word string) bool {
+		return len(words) > 0 && strings.Contains(word, words[0])
+	}, 100)
+}
+
+// excerptSnippet shortens an excerpt to about width characters, around the
+// first word for which match is true.
+func excerptSnippet(excerpt string, match func(word string) bool, width int) string {
+	words := strings.Fields(excerpt)
+	start := 0
+	for i, word := range words {
+		if tokens := search.Tokenize(word); len(tokens) > 0 && match(tokens[0]) {
+			start = max(i-3, 0)
+			break
+		}
+	}
+
+	snippet := ""
+	for _, word := range words[start:] {
+		if len([]rune(snippet))+len([]rune(word)) > width {
+			snippet += " ..."
+			break
+		}
+		if snippet != "" {
+			snippet += " "
+		}
+		snippet += word
+	}
+	if start > 0 {
+		snippet = "... " + snippet
+	}
+	return snippet
+}
+
+func commandSearch(conf Config, client *api.Client) {
+	state := api.State(api.StateAll)
+	var langs []string
+	if conf.Saved != "" {
+		settings, err := loadSettings()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		saved, ok := settings.Searches[conf.Saved]
+		if !ok {
+			exitWithError(conf, &usageError{command: "search", err: fmt.Errorf("unknown saved search %q", conf.Saved)})
+		}
+		conf.Query = []string{saved.query()}
+		if saved.State != "" {
+			state = api.State(saved.State)
+		}
+		langs = splitTags(saved.Lang)
+	}
+	query := search.ParseQuery(strings.Join(conf.Query, " "))
+
+	var (
+		hits   []searchHit
+		total  int
+		facets search.Facets
+		err    error
+		match  func(word string) bool
+	)
+	if conf.Cached || conf.Reindex {
+		hits, total, facets, err = searchIndex(conf)
+		terms := map[string]bool{}
+		for _, term := range query.Terms {
+			terms[term] = true
+		}
+		for _, phrase := range query.Phrases {
+			for _, term := range phrase {
+				terms[term] = true
+			}
+		}
+		match = func(word string) bool { return terms[word] }
+	} else {
+		hits, total, facets, err = apiSearch(client, query, state, conf.Limit)
+		words := append([]string{}, query.Terms...)
+		for _, phrase := range query.Phrases {
+			words = append(words, phrase...)
+		}
+		match = func(word string) bool {
+			for _, w := range words {
+				if strings.Contains(word, w) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+	if len(langs) > 0 {
+		kept := []searchHit{}
+		for _, hit := range hits {
+			if len(library.FilterByLang([]api.Item{hit.Item}, langs)) > 0 {
+				kept = append(kept, hit)
+			}
+		}
+		total -= len(hits) - len(kept)
+		hits = kept
+	}
+
+	styled := styledStdout()
+
+	result := hitsResult(hits)
+	result.Text = func(w io.Writer) error {
+		for _, hit := range hits {
+			fmt.Fprintf(w, "[%9d] %s <%s>\n", hit.ItemID, hyperlink(hit.URL(), highlight(hit.Title(), match, styled)), hyperlink(hit.URL(), hit.URL()))
+			if hit.Excerpt != "" {
+				fmt.Fprintf(w, "            %s\n", highlight(excerptSnippet(hit.Excerpt, match, 100), match, styled))
+			}
+		}
+		fmt.Fprintf(w, "\n%d matching items\n", total)
+		if len(facets.Tags) > 0 {
+			fmt.Fprintf(w, "Tags: %s\n", topFacets(facets.Tags, 10))
+		}
+		if len(facets.Domains) > 0 {
+			fmt.Fprintf(w, "Domains: %s\n", topFacets(facets.Domains, 10))
+		}
+		return nil
+	}
+	renderOutput(conf, "search", result)
+}
+
+// searchIndex runs the query of conf against the search index of the
+// local mirror, rebuilding it first with --reindex.
+func searchIndex(conf Config) ([]searchHit, int, search.Facets, error) {
+	m, err := openMirror()
+	if err != nil {
+		return nil, 0, search.Facets{}, err
+	}
+	defer m.Close()
+
+	if conf.Reindex {
+		_, err := buildSearchIndex(m)
+		if err != nil {
+			return nil, 0, search.Facets{}, err
+		}
+	}
+
+	return searchMirror(m, strings.Join(conf.Query, " "), conf.Limit)
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/webhook"
+)
+
+// defaultServeAddr is where the server listens unless --listen is given.
+const defaultServeAddr = "127.0.0.1:8765"
+
+// serveToken returns the token clients of the server must present, creating
+// it on first use, or anew if the file is left empty.
+func serveToken() (string, error) {
+	path := filepath.Join(configDir, "serve_token")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	return token, atomicfile.WriteFile(path, []byte(token+"\n"), 0600)
+}
+
+// server exposes the local mirror and a few modify actions over HTTP.
+type server struct {
+	client *api.Client
+	token  string
+	// settings hold the rules adding tags to saved items, like the daemon
+	// does after syncing, and the webhooks notified of the changes made.
+	settings *liveSettings
+
+	// mu serializes access to the mirror, which bbolt opens exclusively.
+	mu sync.Mutex
+}
+
+func (s *server) withMirror(f func(m *mirror.Mirror) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := openMirror()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return f(m)
+}
+
+// actionEvents maps actions to the events of hooks and webhooks they
+// trigger, named like the changes reported by the daemon.
+var actionEvents = map[string]mirror.ChangeKind{
+	"add":        mirror.ChangeAdded,
+	"archive":    mirror.ChangeArchived,
+	"readd":      mirror.ChangeUnarchived,
+	"delete":     mirror.ChangeDeleted,
+	"favorite":   mirror.ChangeFavorited,
+	"unfavorite": mirror.ChangeUnfavorited,
+}
+
+// modify sends actions like modifyOrQueue does, holding the mirror lock in
+// case they have to be queued, and notifies the webhooks in the background.
+func (s *server) modify(actions ...*api.Action) (queued bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, queued, err := modifyOrQueue(s.client, actions...)
+	webhooks := s.settings.Get().Webhooks
+	if err != nil || len(webhooks) == 0 {
+		return queued, err
+	}
+	sender := webhook.NewSender(webhooks)
+
+	changes := []mirror.Change{}
+	for i, action := range actions {
+		var result *api.ActionResult
+		if res != nil && i < len(res.ActionDetails) {
+			result = &res.ActionDetails[i]
+		}
+		if c, ok := actionChange(action, result); ok {
+			changes = append(changes, c)
+		}
+	}
+	go func() {
+		for _, c := range changes {
+			err := sender.Send(string(c.Kind), c)
+			if err != nil {
+				slog.Warn("Webhook delivery failed", "event", c.Kind, "item_id", c.ItemID, "err", err)
+			}
+		}
+	}()
+
+	return queued, nil
+}
+
+// authorized checks the token, given either as a bearer token or as the
+// token query parameter.
+func (s *server) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	// An empty token would let in requests without one
+	return s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Error("Could not write the response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" && r.Method == http.MethodGet {
+		s.handleHealthz(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		s.handleIndex(w, r)
+	case path == "api/items" && r.Method == http.MethodGet:
+		s.handleList(w, r)
+	case path == "api/items" && r.Method == http.MethodPost:
+		s.handleAdd(w, r)
+	case path == "api/search" && r.Method == http.MethodGet:
+		s.handleSearch(w, r)
+	case path == "save" && r.Method == http.MethodGet:
+		s.handleSave(w, r)
+	case path == "bookmarklet" && r.Method == http.MethodGet:
+		s.handleBookmarklet(w, r)
+	case path == "status" && r.Method == http.MethodGet:
+		s.handleStatus(w, r)
+	case len(parts) == 3 && parts[0] == "api" && parts[1] == "items" && r.Method == http.MethodDelete:
+		s.handleAction(w, parts[2], "delete")
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "items" && r.Method == http.MethodPost:
+		s.handleAction(w, parts[2], parts[3])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// retrieveOptionFromQuery reads the filters of the list endpoint, named like
+// the parameters of the retrieve API.
+func retrieveOptionFromQuery(q map[string][]string) *api.RetrieveOption {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	count, _ := strconv.Atoi(get("count"))
+	offset, _ := strconv.Atoi(get("offset"))
+
+	return &api.RetrieveOption{
+		State:  api.State(get("state")),
+		Tag:    get("tag"),
+		Domain: get("domain"),
+		Search: get("search"),
+		Sort:   api.Sort(get("sort")),
+		Count:  count,
+		Offset: offset,
+	}
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	var items []api.Item
+	err := s.withMirror(func(m *mirror.Mirror) error {
+		var err error
+		items, err = m.Retrieve(retrieveOptionFromQuery(r.URL.Query()))
+		return err
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	var result struct {
+		Total   int            `json:"total"`
+		Items   []searchHit    `json:"items"`
+		Tags    map[string]int `json:"tags"`
+		Domains map[string]int `json:"domains"`
+	}
+	err := s.withMirror(func(m *mirror.Mirror) error {
+		hits, total, facets, err := searchMirror(m, r.URL.Query().Get("q"), limit)
+		result.Items, result.Total, result.Tags, result.Domains = hits, total, facets.Tags, facets.Domains
+		return err
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// addRequest is the body of an add request, given as JSON or as a form.
+type addRequest struct {
+	URL   string   `json:"url"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+func (s *server) handleAdd(w http.ResponseWriter, r *http.Request) {
+	var req addRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		req.URL = r.FormValue("url")
+		req.Title = r.FormValue("title")
+		req.Tags = splitTags(r.FormValue("tags"))
+	}
+	if req.URL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url is required"})
+		return
+	}
+
+	queued, err := s.save(req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"queued": queued})
+}
+
+// ruleTags returns the tags that rules add to a new item with the given URL,
+// title, and tags. Rules about the age, length, or state of items never match
+// new ones.
+func ruleTags(rules []Rule, url, title string, tags []string) []string {
+	item := api.Item{GivenURL: url, GivenTitle: title, Tags: map[string]map[string]interface{}{}}
+	for _, tag := range tags {
+		item.Tags[tag] = map[string]interface{}{"tag": tag}
+	}
+
+	added := []string{}
+	for _, rule := range rules {
+		if rule.OlderThanDays > 0 || rule.MinWords > 0 || rule.MaxWords > 0 || rule.State == api.StateArchive {
+			continue
+		}
+		if !mirror.Match(item, &api.RetrieveOption{Domain: rule.Domain, Search: rule.Search, Tag: rule.Tag}) {
+			continue
+		}
+		for _, tag := range rule.AddTags {
+			if _, ok := item.Tags[tag]; !ok {
+				item.Tags[tag] = map[string]interface{}{"tag": tag}
+				added = append(added, tag)
+			}
+		}
+	}
+	return added
+}
+
+// newSaveAction returns the action adding the item requested, with its URL
+// cleaned and the tags of matching rules added.
+func newSaveAction(req addRequest, rules []Rule) *api.Action {
+	url := library.CleanURL(req.URL)
+	tags := append(req.Tags, ruleTags(rules, url, req.Title, req.Tags)...)
+	return api.NewAddAction(url, req.Title, tags...)
+}
+
+// save adds an item like newSaveAction describes, queueing it if Pocket
+// cannot be reached.
+func (s *server) save(req addRequest) (queued bool, err error) {
+	return s.modify(newSaveAction(req, s.settings.Get().Rules))
+}
+
+var saveTemplate = template.Must(template.New("save").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Pocket</title></head>
+<body style="font-family: sans-serif">
+<p>{{if .Error}}Could not save: {{.Error}}{{else if .Queued}}Saved offline; it will be sent with the next sync.{{else}}Saved.{{end}}</p>
+<p><small>{{.URL}}</small></p>
+{{if not .Error}}<script>setTimeout(() => window.close(), 1500)</script>{{end}}
+</body>
+</html>
+`))
+
+// handleSave saves the URL given as a query parameter and shows the outcome
+// as a page, for the bookmarklet to open.
+func (s *server) handleSave(w http.ResponseWriter, r *http.Request) {
+	req := addRequest{URL: r.URL.Query().Get("url"), Title: r.URL.Query().Get("title")}
+	req.Tags = splitTags(r.URL.Query().Get("tags"))
+
+	data := map[string]interface{}{"URL": req.URL}
+	status := http.StatusOK
+	if req.URL == "" {
+		data["Error"] = "url is required"
+		status = http.StatusBadRequest
+	} else if queued, err := s.save(req); err != nil {
+		data["Error"] = err.Error()
+		status = http.StatusBadGateway
+	} else {
+		data["Queued"] = queued
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := saveTemplate.Execute(w, data)
+	if err != nil {
+		slog.Error("Could not render the page", "err", err)
+	}
+}
+
+var bookmarkletTemplate = template.Must(template.New("bookmarklet").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Pocket bookmarklet</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 1em auto">
+<p>Drag this link to your bookmarks bar, then click it on any page to save the page to Pocket:</p>
+<p><a href="{{.Bookmarklet}}">Save to Pocket</a></p>
+<p><small>The bookmarklet contains your server token; do not share it.</small></p>
+</body>
+</html>
+`))
+
+// bookmarklet returns the JavaScript URL that opens /save on origin for the
+// current page.
+func bookmarklet(origin, token string) string {
+	return "javascript:(function(){window.open('" + origin + "/save?token=" + token +
+		"&url='+encodeURIComponent(location.href)+'&title='+encodeURIComponent(document.title)," +
+		"'pocket','width=420,height=160')})()"
+}
+
+func (s *server) handleBookmarklet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := bookmarkletTemplate.Execute(w, map[string]interface{}{
+		// html/template rejects javascript: URLs unless marked safe.
+		"Bookmarklet": template.URL(bookmarklet("http://"+r.Host, s.token)),
+	})
+	if err != nil {
+		slog.Error("Could not render the page", "err", err)
+	}
+}
+
+// itemActions are the actions that can be posted to /api/items/<id>/<action>.
+var itemActions = map[string]func(int) *api.Action{
+	"archive":    api.NewArchiveAction,
+	"readd":      api.NewReaddAction,
+	"delete":     api.NewDeleteAction,
+	"favorite":   api.NewFavoriteAction,
+	"unfavorite": api.NewUnfavoriteAction,
+}
+
+func (s *server) handleAction(w http.ResponseWriter, id string, name string) {
+	itemID, err := strconv.Atoi(id)
+	newAction, ok := itemActions[name]
+	if err != nil || !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	queued, err := s.modify(newAction(itemID))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"queued": queued})
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pocket</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 1em auto; padding: 0 1em; }
+li { margin: 0.5em 0; }
+small { color: #666; }
+button { font-size: small; }
+</style>
+</head>
+<body>
+<form method="get" action="/">
+<input type="hidden" name="token" value="{{.Token}}">
+<input type="search" name="q" value="{{.Query}}" placeholder="Search">
+<button>Search</button>
+</form>
+<form method="post" action="/api/items?token={{.Token}}" onsubmit="return add(this)">
+<input type="url" name="url" placeholder="https://" required>
+<input type="text" name="tags" placeholder="tags">
+<button>Save</button>
+<a href="/bookmarklet?token={{.Token}}">Bookmarklet</a>
+</form>
+<ul>
+{{range .Items}}<li id="item-{{.ItemID}}">
+<a href="{{.URL}}">{{.Title}}</a> <small>{{.Domain}}</small>
+<button onclick="act({{.ItemID}}, 'archive')">Archive</button>
+<button onclick="act({{.ItemID}}, 'delete')">Delete</button>
+</li>
+{{else}}<li>No items</li>
+{{end}}</ul>
+<script>
+const token = {{.Token}};
+function act(id, action) {
+  fetch('/api/items/' + id + '/' + action, {method: 'POST', headers: {Authorization: 'Bearer ' + token}})
+    .then(res => { if (res.ok) document.getElementById('item-' + id).remove(); else res.text().then(alert); });
+}
+function add(form) {
+  fetch('/api/items', {method: 'POST', headers: {Authorization: 'Bearer ' + token}, body: new URLSearchParams(new FormData(form))})
+    .then(res => { if (res.ok) form.reset(); else res.text().then(alert); });
+  return false;
+}
+</script>
+</body>
+</html>
+`))
+
+// indexPageSize is the number of items shown on the web page.
+const indexPageSize = 100
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	items := []api.Item{}
+	err := s.withMirror(func(m *mirror.Mirror) error {
+		if query == "" {
+			var err error
+			items, err = m.Retrieve(&api.RetrieveOption{Count: indexPageSize})
+			return err
+		}
+
+		hits, _, _, err := searchMirror(m, query, indexPageSize)
+		for _, hit := range hits {
+			items = append(items, hit.Item)
+		}
+		return err
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = indexTemplate.Execute(w, map[string]interface{}{
+		"Token": s.token,
+		"Query": query,
+		"Items": items,
+	})
+	if err != nil {
+		slog.Error("Could not render the page", "err", err)
+	}
+}
+
+func commandServe(conf Config, client *api.Client) {
+	addr := conf.Listen
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			slog.Warn("The address is reachable from other machines; anyone with the token can change your list", "addr", addr)
+		}
+	}
+
+	token, err := serveToken()
+	if err != nil {
+		panic(err)
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		panic(err)
+	}
+
+	live := watchSettings(settings, func(settings *Settings) error { return validateRules(settings.Rules) })
+	srv := &server{client: client, token: token, settings: live}
+	if settings.MailIn.Listen != "" {
+		go serveMailIn(settings.MailIn, live, func(actions ...*api.Action) error {
+			_, err := srv.modify(actions...)
+			return err
+		})
+	}
+
+	slog.Info("Serving", "url", "http://"+addr+"/?token="+token)
+	logFatal("Serving failed", http.ListenAndServe(addr, srv))
+}
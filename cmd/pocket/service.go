@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// launchdLabel identifies the daemon's launchd job.
+const launchdLabel = "com.github.motemen.go-pocket.daemon"
+
+// daemonArgs returns the command line the service runs.
+func daemonArgs(executable string, conf Config) []string {
+	args := []string{executable, "daemon", "--interval=" + conf.Interval}
+	if conf.FetchArticles {
+		args = append(args, "--articles")
+	}
+	return args
+}
+
+// systemdQuote quotes an argument of ExecStart.
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\$%") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%").Replace(s) + `"`
+}
+
+// systemdUnit returns a systemd user unit running the daemon.
+func systemdUnit(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(arg)
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=Pocket sync daemon
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=%s
+WorkingDirectory=%s
+Restart=on-failure
+RestartSec=60
+
+[Install]
+WantedBy=default.target
+`, strings.Join(quoted, " "), systemdQuote(configDir))
+}
+
+// launchdPlist returns a launchd agent definition running the daemon.
+func launchdPlist(args []string) string {
+	var programArgs strings.Builder
+	for _, arg := range args {
+		fmt.Fprintf(&programArgs, "\t\t<string>%s</string>\n", html.EscapeString(arg))
+	}
+	logPath := html.EscapeString(filepath.Join(configDir, "daemon.log"))
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, launchdLabel, programArgs.String(), html.EscapeString(configDir), logPath, logPath)
+}
+
+// commandDaemonInstall writes a service definition that runs the daemon in
+// the background: a systemd user unit on Linux, or a launchd agent on macOS.
+func commandDaemonInstall(conf Config) {
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		panic(err)
+	}
+	args := daemonArgs(executable, conf)
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+
+	var path, content, enable string
+	switch runtime.GOOS {
+	case "darwin":
+		path = filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist")
+		content = launchdPlist(args)
+		enable = "launchctl load -w " + path
+	case "linux", "freebsd", "openbsd", "netbsd":
+		path = filepath.Join(home, ".config", "systemd", "user", "pocket.service")
+		content = systemdUnit(args)
+		enable = "systemctl --user daemon-reload && systemctl --user enable --now pocket.service"
+	default:
+		fmt.Fprintf(os.Stderr, "Installing the daemon is not supported on %s\n", runtime.GOOS)
+		os.Exit(1)
+	}
+
+	if conf.Print {
+		fmt.Print(content)
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %s\nStart it with:\n  %s\n", path, enable)
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/bridge"
+	"github.com/motemen/go-pocket/hooks"
+	"github.com/motemen/go-pocket/webhook"
+)
+
+// Settings is the user configuration read from config.json in the config
+// directory. Every field is optional.
+type Settings struct {
+	SMTP  SMTPSettings  `json:"smtp"`
+	Goals GoalsSettings `json:"goals"`
+	Cache CacheSettings `json:"cache"`
+	// Rules are applied by the daemon after each sync and by "pocket rules
+	// run". Those of the rules file follow those of config.json.
+	Rules  []Rule         `json:"rules"`
+	Notify NotifySettings `json:"notify"`
+	// Daemon schedules the syncs, the digest, and the bulk jobs of the
+	// daemon in a time zone.
+	Daemon DaemonSettings `json:"daemon"`
+	// Webhooks receive the changes seen by the daemon and made through serve.
+	Webhooks []webhook.Hook `json:"webhooks"`
+	// Hooks run commands on the changes made, as on webhooks, and can veto
+	// deletions.
+	Hooks []hooks.Hook `json:"hooks"`
+	// Routes send the items each sync sees given a tag to a file or a
+	// webhook, as in appending those tagged "recipe" to recipes.md.
+	Routes []TagRoute `json:"routes"`
+	// Feeds are polled by the daemon, which saves their new entries.
+	Feeds []FeedSettings `json:"feeds"`
+	// Clipboard makes the daemon save the URLs copied to the clipboard.
+	Clipboard ClipboardSettings `json:"clipboard"`
+	// MailIn makes the daemon and serve take mail over SMTP, saving the
+	// URLs in it.
+	MailIn MailInSettings `json:"mail_in"`
+	// Bot makes the daemon take commands posted to a chat channel.
+	Bot BotSettings `json:"bot"`
+	// Telegram makes the daemon save the URLs messaged to a Telegram bot.
+	Telegram TelegramSettings `json:"telegram"`
+	// Readwise receives highlights from "pocket highlights push" and, if
+	// set to, the daemon.
+	Readwise ReadwiseSettings `json:"readwise"`
+	// Bridge sets up the bookmark managers of "pocket bridge".
+	Bridge BridgeSettings `json:"bridge"`
+	// Videos sets the player of "pocket videos play".
+	Videos VideoSettings `json:"videos"`
+	// Aliases name command lines, as in "yt": "list --domain youtube.com", run
+	// with the arguments given after the alias appended.
+	Aliases map[string]string `json:"aliases"`
+	// Searches name combinations of filters, used as "pocket list @golang"
+	// or "pocket search --saved golang".
+	Searches map[string]SavedSearch `json:"searches"`
+	// TrackOpened records the items opened by pocket, for "pocket list
+	// --opened-unarchived".
+	TrackOpened bool `json:"track_opened"`
+	// VersionCheck, if false, stops pocket from checking the project
+	// metadata for notices about changes to the Pocket API.
+	VersionCheck *bool `json:"version_check"`
+	// ScreenReader turns on --screen-reader for every command.
+	ScreenReader bool `json:"screen_reader"`
+	// ReadOnly turns on --read-only for every command.
+	ReadOnly bool `json:"read_only"`
+	// NormalizeTitles repairs the titles garbled by HTML entities or UTF-8
+	// encoded twice, as with api.NormalizeTitles.
+	NormalizeTitles bool `json:"normalize_titles"`
+	// Trash makes the deletions of "pocket list --cull" and of duplicates
+	// move items to the trash instead, for "pocket trash empty" to delete.
+	Trash TrashSettings `json:"trash"`
+	// LinkCheck sets how the links are checked by "pocket list --cull".
+	LinkCheck LinkCheckSettings `json:"link_check"`
+	// Summarize sets up the language model of "pocket summarize".
+	Summarize SummarizeSettings `json:"summarize"`
+	// AutoTag sets up the embedding model of "pocket autotag", and whether
+	// the daemon runs it.
+	AutoTag AutoTagSettings `json:"autotag"`
+	// Confirm says which operations ask for confirmation, overriding the
+	// defaults of confirmPolicy.
+	Confirm map[string]confirmRule `json:"confirm"`
+	// HTTP tunes the connections kept open to the Pocket API.
+	HTTP HTTPSettings `json:"http"`
+}
+
+// SMTPSettings configures the mail server used by the email command.
+type SMTPSettings struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// GoalsSettings sets the number of items to read, used by the goals command.
+type GoalsSettings struct {
+	Daily  int `json:"daily"`
+	Weekly int `json:"weekly"`
+}
+
+// CacheSettings limits the size of the article text cache filled by
+// "pocket sync --articles".
+type CacheSettings struct {
+	MaxMB int `json:"max_mb"`
+	// Encrypt encrypts the mirror, the article text, and the search index
+	// with a key kept in the keyring of the system.
+	Encrypt bool `json:"encrypt"`
+}
+
+// FeedSettings is an RSS or Atom feed followed by the daemon.
+type FeedSettings struct {
+	URL string `json:"url"`
+	// Tags are given to the items saved from the feed.
+	Tags []string `json:"tags"`
+}
+
+// MailInSettings configures taking mail in the daemon and serve, from a
+// forwarder such as fetchmail or a local MTA.
+type MailInSettings struct {
+	// Listen is the address of the SMTP server, as in "localhost:2525"; no
+	// mail is taken if it is empty.
+	Listen string `json:"listen"`
+	// From, if not empty, lists the only senders whose mail saves items.
+	From []string `json:"from"`
+	// Tags are given to the items saved from mail.
+	Tags []string `json:"tags"`
+}
+
+// BotSettings sets up the chat bot of the daemon, through the chatbot
+// package.
+type BotSettings struct {
+	// Service is "matrix", "slack", or "discord"; there is no bot if it is
+	// empty.
+	Service string `json:"service"`
+	// URL is the homeserver on Matrix, https://matrix.org by default.
+	URL     string `json:"url"`
+	Token   string `json:"token"`
+	Channel string `json:"channel"`
+	// Users, if not empty, lists the IDs of the only users the bot answers.
+	Users []string `json:"users"`
+	// Prefix starts the commands, "!" by default.
+	Prefix string `json:"prefix"`
+	// Interval is the seconds between polls of Slack and Discord, 5 by
+	// default.
+	Interval int `json:"interval"`
+}
+
+// TelegramSettings sets up the Telegram bot of the daemon.
+type TelegramSettings struct {
+	// Token is that BotFather gave the bot; there is no bot if it is
+	// empty.
+	Token string `json:"token"`
+	// URL is the origin of a Bot API server of your own, if not Telegram's.
+	URL string `json:"url"`
+	// Users lists the user IDs or usernames of those the bot saves for.
+	Users []string `json:"users"`
+	// Tags are given to the items saved, along with the hashtags of the
+	// messages.
+	Tags []string `json:"tags"`
+}
+
+// ClipboardSettings configures watching the clipboard in the daemon.
+type ClipboardSettings struct {
+	Watch bool `json:"watch"`
+	// Tags are given to the items saved from the clipboard.
+	Tags []string `json:"tags"`
+}
+
+// ReadwiseSettings configures pushing highlights to Readwise.
+type ReadwiseSettings struct {
+	// Token is the access token from https://readwise.io/access_token.
+	Token string `json:"token"`
+	// PushAfterSync makes the daemon push new highlights after each sync.
+	PushAfterSync bool `json:"push_after_sync"`
+}
+
+// BridgeSettings sets up the bookmark managers of the bridge command.
+type BridgeSettings struct {
+	// Buku and Shiori are their executables, if not those in $PATH.
+	Buku          string `json:"buku"`
+	Shiori        string `json:"shiori"`
+	PinboardToken string `json:"pinboard_token"`
+	// Tags maps Pocket tags to those of the bookmark managers.
+	Tags bridge.TagMap `json:"tags"`
+}
+
+// VideoSettings configures playing videos.
+type VideoSettings struct {
+	// Player is the command line of the player, run with the URL of each
+	// video appended; mpv or VLC by default.
+	Player string `json:"player"`
+}
+
+// HTTPSettings tunes the connections to the Pocket API kept open between
+// requests, zero leaving the defaults of api.Transport, and sets up
+// api.Breaker.
+type HTTPSettings struct {
+	// MaxIdleConns is the number of idle connections kept to each host.
+	MaxIdleConns int `json:"max_idle_conns"`
+	// IdleTimeout is the seconds an idle connection is kept.
+	IdleTimeout int `json:"idle_timeout"`
+	// BreakerThreshold, if set, is the number of failed requests in a row
+	// after which no more are made for BreakerCooldown seconds, a minute
+	// by default.
+	BreakerThreshold int `json:"breaker_threshold"`
+	BreakerCooldown  int `json:"breaker_cooldown"`
+}
+
+// apply sets up api.Transport and api.Breaker as the settings say.
+func (s HTTPSettings) apply() {
+	if s.MaxIdleConns > 0 {
+		api.Transport.MaxIdleConnsPerHost = s.MaxIdleConns
+		api.Transport.MaxIdleConns = max(api.Transport.MaxIdleConns, s.MaxIdleConns)
+	}
+	if s.IdleTimeout > 0 {
+		api.Transport.IdleConnTimeout = time.Duration(s.IdleTimeout) * time.Second
+	}
+	if s.BreakerThreshold > 0 {
+		cooldown := time.Minute
+		if s.BreakerCooldown > 0 {
+			cooldown = time.Duration(s.BreakerCooldown) * time.Second
+		}
+		api.Breaker = &api.CircuitBreaker{Threshold: s.BreakerThreshold, Cooldown: cooldown}
+	}
+}
+
+// LinkCheckSettings sets how often and how fast links are checked, zero
+// leaving the defaults and -1 turning each off.
+type LinkCheckSettings struct {
+	// TTL is the hours a link checked is not checked again, a week by
+	// default.
+	TTL int `json:"ttl"`
+	// Concurrency is the most links checked at once, 8 by default.
+	Concurrency int `json:"concurrency"`
+	// DomainDelay is the milliseconds between the requests to one host,
+	// 250 by default.
+	DomainDelay int `json:"domain_delay"`
+	// SerializeDomains checks the links of each host one at a time.
+	SerializeDomains bool `json:"serialize_domains"`
+}
+
+// TrashSettings sets how long items stay in the trash.
+type TrashSettings struct {
+	// Days, if set, turns the trash on: items are archived and tagged
+	// trash: and the day instead of deleted, and "pocket trash empty"
+	// deletes those in the trash for this many days.
+	Days int `json:"days"`
+}
+
+// SummarizeSettings sets up the language model summarizing items, through
+// the llm package.
+type SummarizeSettings struct {
+	// Backend is "openai", for OpenAI and the servers compatible with its
+	// API, or "ollama".
+	Backend string `json:"backend"`
+	// URL is the origin of the API, by default that of OpenAI, or
+	// http://localhost:11434 for Ollama.
+	URL   string `json:"url"`
+	Model string `json:"model"`
+	// APIKey is sent to OpenAI and compatible APIs; $OPENAI_API_KEY is used
+	// if it is empty.
+	APIKey string `json:"api_key"`
+	// Prompt replaces the instructions given along with the text.
+	Prompt string `json:"prompt"`
+	// MaxWords is the most words of the text sent, 4000 by default, for
+	// long articles to fit in the context of the model.
+	MaxWords int `json:"max_words"`
+}
+
+// AutoTagSettings sets up tagging items like the tagged items closest to
+// them in meaning, as embedded by a model through the llm package.
+type AutoTagSettings struct {
+	// Backend, URL, Model, and APIKey name the embedding model as those of
+	// SummarizeSettings do, as in "nomic-embed-text" on Ollama.
+	Backend string `json:"backend"`
+	URL     string `json:"url"`
+	Model   string `json:"model"`
+	APIKey  string `json:"api_key"`
+	// Daemon makes the daemon embed the items new since the last sync and,
+	// with Apply, give the untagged ones the tags suggested.
+	Daemon bool `json:"daemon"`
+	Apply  bool `json:"apply"`
+	// Neighbors is the number of tagged items closest to an item voting for
+	// its tags, 10 by default.
+	Neighbors int `json:"neighbors"`
+	// MinScore is the least share of the votes a tag needs, 0.5 by default.
+	MinScore float64 `json:"min_score"`
+}
+
+// SavedSearch is a named combination of filters, each of them optional.
+type SavedSearch struct {
+	Domain string `json:"domain"`
+	Tag    string `json:"tag"`
+	Search string `json:"search"`
+	Lang   string `json:"lang"`
+	State  string `json:"state"`
+	Sort   string `json:"sort"`
+}
+
+// NotifySettings selects the desktop notifications sent by the daemon.
+type NotifySettings struct {
+	NewItems bool `json:"new_items"`
+	// Tags limits new item notifications to items with one of these tags.
+	Tags         []string `json:"tags"`
+	WeeklyDigest bool     `json:"weekly_digest"`
+}
+
+// DaemonSettings schedules the runs of the daemon with cron expressions, as
+// parsed by the schedule package, in TimeZone. Each is optional.
+type DaemonSettings struct {
+	// TimeZone is an IANA name, as in "Europe/Berlin"; the local time
+	// zone by default.
+	TimeZone string `json:"time_zone"`
+	// Sync is when to sync, replacing --interval, which then only sets how
+	// soon a failed sync is tried again.
+	Sync string `json:"sync"`
+	// Digest is when to notify the weekly digest, instead of a week after
+	// the last one.
+	Digest string `json:"digest"`
+	// Bulk is when to fetch the articles with --articles and run autotag,
+	// instead of with every sync.
+	Bulk string `json:"bulk"`
+	// QuietHours, as in "22:00-07:00", holds the notifications: new items
+	// are not notified, and a digest due waits until the end.
+	QuietHours string `json:"quiet_hours"`
+}
+
+// simulate leaves out the settings reaching beyond the simulated account
+// under --simulate: the hooks, the webhooks, pushing to Readwise, and the
+// key of the cache in the keyring.
+func (s *Settings) simulate() {
+	s.Hooks = nil
+	s.Webhooks = nil
+	s.Readwise.PushAfterSync = false
+	s.Cache.Encrypt = false
+}
+
+// loadSettings reads config.json, returning empty settings if it does not exist.
+func loadSettings() (*Settings, error) {
+	settings := &Settings{}
+
+	err := loadJSONFromFile(filepath.Join(settingsDir, "config.json"), settings)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if simulating {
+		settings.simulate()
+	}
+
+	rules, err := loadRules()
+	if err != nil {
+		return nil, err
+	}
+	settings.Rules = append(settings.Rules, rules...)
+
+	return settings, nil
+}
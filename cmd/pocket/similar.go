@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// similarReasons describes what an item has in common with another beyond
+// words, as in "same site, tags: go, books".
+func similarReasons(item, other api.Item) string {
+	reasons := []string{}
+	if domain := item.Domain(); domain != "" && strings.TrimPrefix(domain, "www.") == strings.TrimPrefix(other.Domain(), "www.") {
+		reasons = append(reasons, "same site")
+	}
+	shared := []string{}
+	for _, tag := range other.TagNames() {
+		if _, ok := item.Tags[tag]; ok {
+			shared = append(shared, tag)
+		}
+	}
+	if len(shared) > 0 {
+		reasons = append(reasons, "tags: "+strings.Join(shared, ", "))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+func commandSimilar(conf Config, client *api.Client) {
+	m, err := openMirror()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	defer m.Close()
+
+	load := loadSearchIndex
+	if conf.Reindex {
+		load = buildSearchIndex
+	}
+	idx, err := load(m)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	items, err := m.Items()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+
+	item, ok := byID[conf.ItemID]
+	if _, indexed := idx.Docs[conf.ItemID]; !ok || !indexed {
+		fmt.Fprintf(os.Stderr, "Item %d is not in the local mirror; run \"pocket sync\" first\n", conf.ItemID)
+		os.Exit(1)
+	}
+
+	hits := []searchHit{}
+	for _, r := range idx.Similar(conf.ItemID) {
+		other, ok := byID[r.ID]
+		if !ok {
+			// The index is older than the mirror; skip removed items.
+			continue
+		}
+		hits = append(hits, searchHit{Item: other, Score: r.Score})
+		if conf.Limit > 0 && len(hits) == conf.Limit {
+			break
+		}
+	}
+
+	result := hitsResult(hits)
+	result.Text = func(w io.Writer) error {
+		fmt.Fprintf(w, "Similar to [%d] %s:\n\n", item.ItemID, item.Title())
+		for _, hit := range hits {
+			fmt.Fprintf(w, "[%9d] %s <%s>\n", hit.ItemID, hyperlink(hit.URL(), hit.Title()), hyperlink(hit.URL(), hit.URL()))
+			if reasons := similarReasons(item, hit.Item); reasons != "" {
+				fmt.Fprintf(w, "            %s\n", reasons)
+			}
+		}
+		if len(hits) == 0 {
+			fmt.Fprintln(w, "Nothing found with words, tags, or a site in common")
+		}
+		return nil
+	}
+	renderOutput(conf, "similar", result)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/auth"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/pockettest"
+)
+
+// simulating is set by --simulate, under which commands run against an
+// account kept in memory instead of Pocket.
+var simulating bool
+
+// startSimulation points pocket at an account kept in memory, served by
+// pockettest and holding the items of fixture, as written by "pocket export
+// --format json". The mirror, queue, and other state are kept in a config
+// directory of their own, while the settings are still read from
+// settingsDir. stop ends the simulation, telling on stderr what changed.
+func startSimulation(fixture string) (stop func(), err error) {
+	items := []api.Item{}
+	err = loadJSONFromFile(fixture, &items)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the fixture: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "pocket-simulate-")
+	if err != nil {
+		return nil, err
+	}
+	server := pockettest.NewServer()
+	for _, item := range items {
+		server.Add(item)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "consumer_key"), []byte(server.ConsumerKey), 0600)
+	if err == nil {
+		err = saveJSONToFile(filepath.Join(dir, "auth.json"), &auth.Authorization{AccessToken: server.AccessToken, Username: "simulated"})
+	}
+	if err != nil {
+		server.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	simulating = true
+	configDir = dir
+	api.Origin = server.URL
+	return func() {
+		fmt.Fprintln(os.Stderr, describeSimulation(items, server.Items()))
+		server.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// describeSimulation counts the changes from the items of the fixture to
+// those of the simulated account at the end.
+func describeSimulation(fixture, current []api.Item) string {
+	counts := map[mirror.ChangeKind]int{}
+	before := map[int]*api.Item{}
+	for i := range fixture {
+		before[fixture[i].ItemID] = &fixture[i]
+	}
+	for _, item := range current {
+		for _, c := range mirror.Compare(before[item.ItemID], item) {
+			counts[c.Kind]++
+		}
+		delete(before, item.ItemID)
+	}
+	counts[mirror.ChangeDeleted] += len(before)
+
+	parts := []string{}
+	for _, kind := range []mirror.ChangeKind{
+		mirror.ChangeAdded, mirror.ChangeArchived, mirror.ChangeUnarchived, mirror.ChangeFavorited,
+		mirror.ChangeUnfavorited, mirror.ChangeTagsChanged, mirror.ChangeDeleted,
+	} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], strings.ReplaceAll(string(kind), "_", " ")))
+		}
+	}
+	if len(parts) == 0 {
+		return "Simulated: no changes; nothing was sent to Pocket"
+	}
+	return "Simulated: " + strings.Join(parts, ", ") + "; nothing was sent to Pocket"
+}
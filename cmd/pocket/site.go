@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/search"
+)
+
+// siteMarker is written at the top of a site built by "pocket site", which
+// only then removes the pages of the items, tags, and domains gone from it.
+const siteMarker = ".pocket-site"
+
+var siteTemplates = template.Must(template.New("site").Funcs(template.FuncMap{
+	"date": func(t api.Time) string { return formatTime(t.Time, "2006-01-02") },
+}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}style.css">
+</head>
+<body>
+<nav>
+<a href="{{.Root}}index.html">By date</a>
+<a href="{{.Root}}tags/index.html">Tags</a>
+<a href="{{.Root}}domains/index.html">Sites</a>
+<input type="search" id="search" placeholder="Search" data-root="{{.Root}}">
+</nav>
+<ul id="results"></ul>
+<main>
+<h1>{{.Title}}</h1>
+{{end}}
+
+{{define "foot"}}</main>
+<script src="{{.Root}}search.js"></script>
+</body>
+</html>
+{{end}}
+
+{{define "items"}}<ul>
+{{range .Items}}<li><a href="{{$.Root}}items/{{.ItemID}}.html">{{.Title}}</a> <small>{{.Domain}}, {{date .TimeAdded}}</small></li>
+{{end}}</ul>
+{{end}}
+
+{{define "index"}}{{template "head" .}}
+<p>{{len .Items}} items</p>
+{{range .Months}}<h2>{{.Name}}</h2>
+<ul>
+{{range .Items}}<li><a href="items/{{.ItemID}}.html">{{.Title}}</a> <small>{{.Domain}}, {{date .TimeAdded}}</small></li>
+{{end}}</ul>
+{{end}}
+{{template "foot" .}}{{end}}
+
+{{define "list"}}{{template "head" .}}
+{{template "items" .}}
+{{template "foot" .}}{{end}}
+
+{{define "groups"}}{{template "head" .}}
+<ul class="groups">
+{{range .Groups}}<li><a href="{{.Slug}}.html">{{.Name}}</a> <small>{{len .Items}}</small></li>
+{{end}}</ul>
+{{template "foot" .}}{{end}}
+
+{{define "item"}}{{template "head" .}}
+{{with .Item}}<p><a href="{{.URL}}">{{.URL}}</a></p>
+<p><small>Saved {{date .TimeAdded}}{{with .ReadingMinutes}}, {{.}} min read{{end}},
+from <a href="{{$.Root}}domains/{{$.DomainSlug}}.html">{{.Domain}}</a></small></p>
+{{if .Excerpt}}<blockquote>{{.Excerpt}}</blockquote>{{end}}{{end}}
+{{if .Note}}<p class="note">{{.Note}}</p>{{end}}
+{{if .Tags}}<p class="tags">{{range .Tags}}<a href="{{$.Root}}tags/{{.Slug}}.html">{{.Name}}</a> {{end}}</p>{{end}}
+{{template "foot" .}}{{end}}
+`))
+
+const siteStyle = `body { font-family: sans-serif; max-width: 50em; margin: 1em auto; padding: 0 1em; line-height: 1.4; }
+nav a { margin-right: 1em; }
+nav input { float: right; }
+li { margin: 0.4em 0; }
+small { color: #666; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #333; }
+.tags a { background: #eee; border-radius: 3px; padding: 0 0.3em; }
+#results:empty { display: none; }
+#results { border-bottom: 1px solid #ccc; padding-bottom: 1em; }
+`
+
+// siteScript searches search.json for the items having every word of the
+// query, the last one possibly unfinished, splitting words as
+// search.Tokenize does.
+const siteScript = `(function () {
+  var input = document.getElementById('search');
+  var results = document.getElementById('results');
+  var root = input.dataset.root;
+  var index = null;
+
+  function tokenize(s) {
+    return s.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(Boolean);
+  }
+
+  function matching(word, prefix) {
+    var ids = {};
+    Object.keys(index.terms).forEach(function (term) {
+      if (term === word || (prefix && term.indexOf(word) === 0)) {
+        index.terms[term].forEach(function (i) { ids[i] = true; });
+      }
+    });
+    return ids;
+  }
+
+  function show() {
+    var words = tokenize(input.value);
+    results.textContent = '';
+    if (!words.length) return;
+    var found = null;
+    words.forEach(function (word, n) {
+      var ids = matching(word, n === words.length - 1);
+      found = found === null ? ids : Object.keys(found).reduce(function (both, i) {
+        if (ids[i]) both[i] = true;
+        return both;
+      }, {});
+    });
+    Object.keys(found).slice(0, 50).forEach(function (i) {
+      var doc = index.docs[i];
+      var li = document.createElement('li');
+      var a = document.createElement('a');
+      a.href = root + 'items/' + doc.id + '.html';
+      a.textContent = doc.title;
+      li.appendChild(a);
+      results.appendChild(li);
+    });
+  }
+
+  input.addEventListener('input', function () {
+    if (index) return show();
+    fetch(root + 'search.json').then(function (res) { return res.json(); }).then(function (data) {
+      index = data;
+      show();
+    });
+  });
+})();
+`
+
+// siteGroup is the items with a tag, or from a site.
+type siteGroup struct {
+	Name  string
+	Slug  string
+	Items []api.Item
+}
+
+// siteSearchIndex is search.json: the items, and the items in which each
+// word of the titles, excerpts, tags, and sites appears, by their index.
+type siteSearchIndex struct {
+	Docs  []siteSearchDoc  `json:"docs"`
+	Terms map[string][]int `json:"terms"`
+}
+
+type siteSearchDoc struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// siteSlug makes a file name of a tag or domain, keeping letters and
+// digits; taken lists the slugs given so far, to tell apart those that
+// would be the same.
+func siteSlug(name string, taken map[string]bool) string {
+	slug := strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '.' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, name), "-.")
+	if slug == "" {
+		slug = "-"
+	}
+	unique := slug
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", slug, i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// siteGroups groups items by the keys given by keys, sorted by name.
+func siteGroups(items []api.Item, keys func(api.Item) []string) []*siteGroup {
+	byName := map[string]*siteGroup{}
+	for _, item := range items {
+		for _, key := range keys(item) {
+			if byName[key] == nil {
+				byName[key] = &siteGroup{Name: key}
+			}
+			byName[key].Items = append(byName[key].Items, item)
+		}
+	}
+	groups := make([]*siteGroup, 0, len(byName))
+	for _, g := range byName {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	taken := map[string]bool{"index": true}
+	for _, g := range groups {
+		g.Slug = siteSlug(g.Name, taken)
+	}
+	return groups
+}
+
+// siteWriter writes the files of a site into dir, remembering them.
+type siteWriter struct {
+	dir     string
+	written map[string]bool
+}
+
+func (w *siteWriter) write(name string, b []byte) error {
+	path := filepath.Join(w.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	w.written[path] = true
+	return os.WriteFile(path, b, 0644)
+}
+
+func (w *siteWriter) page(name, tmpl string, data map[string]interface{}) error {
+	// Links are relative, for the site to be served from anywhere
+	data["Root"] = ""
+	if strings.Contains(name, "/") {
+		data["Root"] = "../"
+	}
+	var b strings.Builder
+	if err := siteTemplates.ExecuteTemplate(&b, tmpl, data); err != nil {
+		return err
+	}
+	return w.write(name, []byte(b.String()))
+}
+
+// prune removes the pages of the subdirectories of the site not written
+// this time, of items, tags, and domains no longer in it.
+func (w *siteWriter) prune() error {
+	for _, sub := range []string{"items", "tags", "domains"} {
+		err := filepath.WalkDir(filepath.Join(w.dir, sub), func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".html" || w.written[path] {
+				return err
+			}
+			return os.Remove(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildSite writes a static site of items into dir.
+func buildSite(dir string, items []api.Item, notes map[int]string) error {
+	_, err := os.Stat(filepath.Join(dir, siteMarker))
+	built := err == nil
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].TimeAdded.After(items[j].TimeAdded.Time) })
+	w := &siteWriter{dir: dir, written: map[string]bool{}}
+
+	months := []*siteGroup{}
+	for _, item := range items {
+		name := item.TimeAdded.Format("January 2006")
+		if len(months) == 0 || months[len(months)-1].Name != name {
+			months = append(months, &siteGroup{Name: name})
+		}
+		months[len(months)-1].Items = append(months[len(months)-1].Items, item)
+	}
+	err = w.page("index.html", "index", map[string]interface{}{"Title": "Pocket", "Items": items, "Months": months})
+	if err != nil {
+		return err
+	}
+
+	tags := siteGroups(items, func(item api.Item) []string { return item.TagNames() })
+	domains := siteGroups(items, func(item api.Item) []string { return []string{item.Domain()} })
+	tagSlugs, domainSlugs := map[string]string{}, map[string]string{}
+	for _, sub := range []struct {
+		dir    string
+		title  string
+		groups []*siteGroup
+		slugs  map[string]string
+	}{{"tags", "Tags", tags, tagSlugs}, {"domains", "Sites", domains, domainSlugs}} {
+		err := w.page(sub.dir+"/index.html", "groups", map[string]interface{}{"Title": sub.title, "Groups": sub.groups})
+		if err != nil {
+			return err
+		}
+		for _, g := range sub.groups {
+			sub.slugs[g.Name] = g.Slug
+			err := w.page(sub.dir+"/"+g.Slug+".html", "list", map[string]interface{}{"Title": g.Name, "Items": g.Items})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	index := siteSearchIndex{Docs: make([]siteSearchDoc, len(items)), Terms: map[string][]int{}}
+	for i, item := range items {
+		itemTags := []siteGroup{}
+		for _, tag := range item.TagNames() {
+			itemTags = append(itemTags, siteGroup{Name: tag, Slug: tagSlugs[tag]})
+		}
+		sort.Slice(itemTags, func(i, j int) bool { return itemTags[i].Name < itemTags[j].Name })
+		err := w.page(fmt.Sprintf("items/%d.html", item.ItemID), "item", map[string]interface{}{
+			"Title":      item.Title(),
+			"Item":       item,
+			"DomainSlug": domainSlugs[item.Domain()],
+			"Tags":       itemTags,
+			"Note":       notes[item.ItemID],
+		})
+		if err != nil {
+			return err
+		}
+
+		index.Docs[i] = siteSearchDoc{ID: item.ItemID, Title: item.Title()}
+		seen := map[string]bool{}
+		text := strings.Join(append([]string{item.Title(), item.Excerpt, item.Domain()}, item.TagNames()...), " ")
+		for _, term := range search.Tokenize(text) {
+			if !seen[term] {
+				seen[term] = true
+				index.Terms[term] = append(index.Terms[term], i)
+			}
+		}
+	}
+	b, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	for name, content := range map[string][]byte{
+		"search.json": b,
+		"search.js":   []byte(siteScript),
+		"style.css":   []byte(siteStyle),
+		siteMarker:    nil,
+	} {
+		if err := w.write(name, content); err != nil {
+			return err
+		}
+	}
+
+	if built {
+		return w.prune()
+	}
+	return nil
+}
+
+func commandSite(conf Config) {
+	// The site is a copy of the library kept locally, rebuilt as often as
+	// it syncs
+	useCache = true
+	items, err := retrieveItems(nil, &api.RetrieveOption{
+		State:  api.StateAll,
+		Domain: conf.Domain,
+		Search: conf.SearchQuery,
+		Tag:    conf.Tag,
+	})
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	notes, err := loadNotes()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	if err := buildSite(conf.Dir, items, notes); err != nil {
+		exitWithError(conf, err)
+	}
+	fmt.Printf("Wrote a site of %d items to %s\n", len(items), conf.Dir)
+}
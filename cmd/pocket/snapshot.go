@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/snapshot"
+)
+
+// snapshotManifestName is the file listing the snapshots in a directory.
+const snapshotManifestName = "manifest.json"
+
+// maxSnapshotResource bounds the size of a page or of a resource inlined
+// into it.
+const maxSnapshotResource = 20 << 20
+
+// snapshotEntry links a snapshot to its item.
+type snapshotEntry struct {
+	ItemID  int       `json:"item_id"`
+	URL     string    `json:"url"`
+	Title   string    `json:"title"`
+	File    string    `json:"file"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+var snapshotClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetchSnapshotResource downloads a page or a resource of a page.
+func fetchSnapshotResource(u string) (data []byte, mediaType string, final string, err error) {
+	resp, err := snapshotClient.Get(u)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("got response %d for %s", resp.StatusCode, u)
+	}
+
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxSnapshotResource+1))
+	if err != nil {
+		return nil, "", "", err
+	}
+	if len(data) > maxSnapshotResource {
+		return nil, "", "", fmt.Errorf("%s is larger than %d MB", u, maxSnapshotResource>>20)
+	}
+
+	mediaType = resp.Header.Get("Content-Type")
+	if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+		mediaType = http.DetectContentType(data)
+	}
+	return data, mediaType, resp.Request.URL.String(), nil
+}
+
+// snapshotHTML saves the page at pageURL as a single HTML file.
+func snapshotHTML(pageURL, file string) error {
+	page, contentType, final, err := fetchSnapshotResource(pageURL)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(final)
+	if err != nil {
+		return err
+	}
+
+	out, err := snapshot.Inline(page, contentType, base, func(u string) ([]byte, string, error) {
+		data, mediaType, _, err := fetchSnapshotResource(u)
+		return data, mediaType, err
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, out, 0644)
+}
+
+// chromePath returns the path of Chrome or Chromium, or "" if neither is
+// installed.
+func chromePath() string {
+	for _, name := range chromeCandidates {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// snapshotPDF prints the page at pageURL to a PDF file with headless Chrome.
+func snapshotPDF(chrome, pageURL, file string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, chrome, "--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf="+abs, pageURL)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s timed out", filepath.Base(chrome))
+		}
+		return fmt.Errorf("%s failed: %s, %s", filepath.Base(chrome), err, out)
+	}
+	return nil
+}
+
+func commandSnapshot(conf Config, client *api.Client) {
+	chrome := ""
+	if conf.PrintPDF {
+		if chrome = chromePath(); chrome == "" {
+			exitWithError(conf, &usageError{command: "snapshot", err: errors.New("--pdf needs Chrome or Chromium, and neither was found")})
+		}
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.State(conf.State),
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if err := os.MkdirAll(conf.Dir, 0755); err != nil {
+		exitWithError(conf, err)
+	}
+	manifestPath := filepath.Join(conf.Dir, snapshotManifestName)
+	manifest := []snapshotEntry{}
+	err = loadJSONFromFile(manifestPath, &manifest)
+	if err != nil && !os.IsNotExist(err) {
+		exitWithError(conf, err)
+	}
+
+	// Items are snapshotted once; removing the file takes a new one
+	saved := map[int]bool{}
+	for _, entry := range manifest {
+		if _, err := os.Stat(filepath.Join(conf.Dir, entry.File)); err == nil {
+			saved[entry.ItemID] = true
+		}
+	}
+	todo := []api.Item{}
+	for _, item := range items {
+		if !saved[item.ItemID] {
+			todo = append(todo, item)
+		}
+	}
+
+	ext := "html"
+	if conf.PrintPDF {
+		ext = "pdf"
+	}
+
+	var mu sync.Mutex
+	errs, runErr := newBulk("Saving snapshots", 4).Run(len(todo), func(i int) error {
+		item := todo[i]
+		name := library.ExportFileName(item, ext)
+		file := filepath.Join(conf.Dir, name)
+
+		var err error
+		if conf.PrintPDF {
+			err = snapshotPDF(chrome, item.URL(), file)
+		} else {
+			err = snapshotHTML(item.URL(), file)
+		}
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		kept := manifest[:0]
+		for _, entry := range manifest {
+			if entry.ItemID != item.ItemID {
+				kept = append(kept, entry)
+			}
+		}
+		manifest = append(kept, snapshotEntry{
+			ItemID:  item.ItemID,
+			URL:     item.URL(),
+			Title:   item.Title(),
+			File:    name,
+			SavedAt: time.Now(),
+		})
+		return saveJSONToFile(manifestPath, manifest)
+	})
+
+	done := 0
+	for i, err := range errs {
+		if err == nil {
+			done++
+		} else if runErr == nil {
+			fmt.Fprintf(os.Stderr, "Could not save %s: %v\n", todo[i].URL(), err)
+		}
+	}
+
+	fmt.Printf("Saved %d of %d snapshots to %s, skipped %d saved before\n", done, len(todo), conf.Dir, len(items)-len(todo))
+	if runErr != nil {
+		exitWithError(conf, runErr)
+	}
+	if done < len(todo) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// snoozeTagPrefix starts the tag recording until when an item is snoozed, as
+// in "snooze:2025-07-01".
+const snoozeTagPrefix = "snooze:"
+
+// parseUntil parses either a date (2006-01-02) or a period such as "3d",
+// "2w", "1m", or "1y" counting forward from now.
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	if m := relativeTimePattern.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return now.AddDate(0, 0, n), nil
+		case "w":
+			return now.AddDate(0, 0, 7*n), nil
+		case "m":
+			return now.AddDate(0, n, 0), nil
+		default:
+			return now.AddDate(n, 0, 0), nil
+		}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q; use a date like 2006-01-02 or a period like 3d, 2w, 1m, 1y", s)
+	}
+	return t, nil
+}
+
+// snoozeActions archives an item until the given day, tagging it to be
+// brought back then.
+func snoozeActions(itemID int, until time.Time) []*api.Action {
+	return []*api.Action{
+		api.NewTagsAddAction(itemID, snoozeTagPrefix+until.Format("2006-01-02")),
+		api.NewArchiveAction(itemID),
+	}
+}
+
+// snoozedUntil returns the day an item is snoozed until, and its snooze tag.
+func snoozedUntil(item api.Item, loc *time.Location) (until time.Time, tag string, ok bool) {
+	for name := range item.Tags {
+		if !strings.HasPrefix(name, snoozeTagPrefix) {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", strings.TrimPrefix(name, snoozeTagPrefix), loc)
+		if err == nil {
+			return t, name, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// wakeActions returns the actions that bring back the archived items whose
+// snooze is due by now, moving them to the unread list and removing the
+// snooze tag.
+func wakeActions(items []api.Item, now time.Time) []*api.Action {
+	actions := []*api.Action{}
+	for _, item := range items {
+		until, tag, ok := snoozedUntil(item, now.Location())
+		if !ok || until.After(now) {
+			continue
+		}
+		if item.Status == api.ItemStatusArchived {
+			actions = append(actions, api.NewReaddAction(item.ItemID))
+		}
+		actions = append(actions, api.NewTagsRemoveAction(item.ItemID, tag))
+	}
+	return actions
+}
+
+// wakeSnoozed brings back the items in the mirror whose snooze is due,
+// through the queue so that it works offline too, returning their number.
+func wakeSnoozed(m *mirror.Mirror, client *api.Client, now time.Time) (int, error) {
+	items, err := m.Items()
+	if err != nil {
+		return 0, err
+	}
+
+	actions := wakeActions(items, now)
+	if len(actions) == 0 {
+		return 0, nil
+	}
+
+	err = m.Enqueue(actions...)
+	if err != nil {
+		return 0, err
+	}
+	_, err = m.Push(client, modifyBatchSize)
+	if err != nil && !isNetworkError(err) {
+		return 0, err
+	}
+
+	woken := 0
+	resurfaced := []mirror.Change{}
+	for _, action := range actions {
+		switch action.Action {
+		case "readd":
+			resurfaced = append(resurfaced, mirror.Change{Kind: changeResurfaced, ItemID: action.ItemID})
+		case "tags_remove":
+			woken++
+		}
+	}
+	if err := recordHistory(historyMade, resurfaced...); err != nil {
+		slog.Warn("Could not record the changes in the history", "err", err)
+	}
+	return woken, nil
+}
+
+func commandSnooze(conf Config, client *api.Client) {
+	until, err := parseUntil(conf.Until, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	_, queued, err := modifyOrQueue(client, snoozeActions(conf.ItemID, until)...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Snoozed until %s; \"pocket sync\" or the daemon brings it back then\n", until.Format("2006-01-02"))
+	if queued {
+		fmt.Println("Pocket is unreachable; the change is queued until the next sync")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/render"
+)
+
+// statsWeeks is the number of recent weeks included in weekly statistics.
+const statsWeeks = 12
+
+type countEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// topCounts returns the n entries of counts with the highest counts, ties
+// broken by name. If n is zero or less, all entries are returned.
+func topCounts(counts map[string]int, n int) []countEntry {
+	entries := make([]countEntry, 0, len(counts))
+	for name, count := range counts {
+		entries = append(entries, countEntry{Name: name, Count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// weekStart returns midnight of the Monday starting the week t falls in.
+func weekStart(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset)
+}
+
+type weekCount struct {
+	Week  string `json:"week"`
+	Count int    `json:"count"`
+}
+
+type statsItem struct {
+	ItemID int    `json:"item_id"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Added  string `json:"added"`
+}
+
+type libraryStats struct {
+	Total            int          `json:"total"`
+	Unread           int          `json:"unread"`
+	Archived         int          `json:"archived"`
+	Favorites        int          `json:"favorites"`
+	AverageWordCount int          `json:"average_word_count"`
+	BacklogHours     float64      `json:"backlog_hours"`
+	OldestUnread     *statsItem   `json:"oldest_unread"`
+	AddedPerWeek     []weekCount  `json:"added_per_week"`
+	ArchivedPerWeek  []weekCount  `json:"archived_per_week"`
+	TopDomains       []countEntry `json:"top_domains"`
+	TopTags          []countEntry `json:"top_tags"`
+	Languages        []countEntry `json:"languages"`
+}
+
+func computeStats(items []api.Item, now time.Time) *libraryStats {
+	stats := &libraryStats{Total: len(items)}
+
+	thisWeek := weekStart(now)
+	firstWeek := thisWeek.AddDate(0, 0, -7*(statsWeeks-1))
+	added := make([]int, statsWeeks)
+	archived := make([]int, statsWeeks)
+	weekIndex := func(t api.Time) int {
+		if t.Before(firstWeek) {
+			return -1
+		}
+		// Round to absorb daylight saving time shifts
+		i := int(math.Round(weekStart(t.In(now.Location())).Sub(firstWeek).Hours() / 24 / 7))
+		if i >= statsWeeks {
+			return -1
+		}
+		return i
+	}
+
+	domains := map[string]int{}
+	tags := map[string]int{}
+	langs := map[string]int{}
+	words, counted, unreadWords := 0, 0, 0
+	var oldest *api.Item
+	for i, item := range items {
+		switch item.Status {
+		case api.ItemStatusUnread:
+			stats.Unread++
+			unreadWords += item.WordCount
+			if oldest == nil || item.TimeAdded.Before(oldest.TimeAdded.Time) {
+				oldest = &items[i]
+			}
+		case api.ItemStatusArchived:
+			stats.Archived++
+			if w := weekIndex(item.TimeRead); w >= 0 {
+				archived[w]++
+			}
+		}
+		if item.Favorite == 1 {
+			stats.Favorites++
+		}
+		if item.WordCount > 0 {
+			words += item.WordCount
+			counted++
+		}
+		if w := weekIndex(item.TimeAdded); w >= 0 {
+			added[w]++
+		}
+		if domain := item.Domain(); domain != "" {
+			domains[domain]++
+		}
+		for _, tag := range item.TagNames() {
+			tags[tag]++
+		}
+		langs[library.Lang(item)]++
+	}
+
+	if counted > 0 {
+		stats.AverageWordCount = words / counted
+	}
+	stats.BacklogHours = float64(unreadWords) / float64(api.WordsPerMinute) / 60
+	if oldest != nil {
+		stats.OldestUnread = &statsItem{
+			ItemID: oldest.ItemID,
+			Title:  oldest.Title(),
+			URL:    oldest.URL(),
+			Added:  oldest.TimeAdded.Format("2006-01-02"),
+		}
+	}
+	for i := 0; i < statsWeeks; i++ {
+		week := firstWeek.AddDate(0, 0, 7*i).Format("2006-01-02")
+		stats.AddedPerWeek = append(stats.AddedPerWeek, weekCount{Week: week, Count: added[i]})
+		stats.ArchivedPerWeek = append(stats.ArchivedPerWeek, weekCount{Week: week, Count: archived[i]})
+	}
+	stats.TopDomains = topCounts(domains, 10)
+	stats.TopTags = topCounts(tags, 10)
+	stats.Languages = topCounts(langs, 0)
+
+	return stats
+}
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders counts as a line of block characters.
+func sparkline(counts []weekCount) string {
+	max := 0
+	for _, c := range counts {
+		if c.Count > max {
+			max = c.Count
+		}
+	}
+
+	var b strings.Builder
+	for _, c := range counts {
+		i := 0
+		if max > 0 {
+			i = c.Count * (len(sparkTicks) - 1) / max
+		}
+		b.WriteRune(sparkTicks[i])
+	}
+	return b.String()
+}
+
+func printStats(w io.Writer, stats *libraryStats, chart bool) {
+	fmt.Fprintf(w, "Items:          %d (%d unread, %d archived, %d favorites)\n", stats.Total, stats.Unread, stats.Archived, stats.Favorites)
+	fmt.Fprintf(w, "Avg word count: %d\n", stats.AverageWordCount)
+	fmt.Fprintf(w, "Backlog:        %.1f hours of reading\n", stats.BacklogHours)
+	if stats.OldestUnread != nil {
+		fmt.Fprintf(w, "Oldest unread:  %s (%s) <%s>\n", stats.OldestUnread.Title, stats.OldestUnread.Added, stats.OldestUnread.URL)
+	}
+
+	addedTotal, archivedTotal := 0, 0
+	for i := range stats.AddedPerWeek {
+		addedTotal += stats.AddedPerWeek[i].Count
+		archivedTotal += stats.ArchivedPerWeek[i].Count
+	}
+	fmt.Fprintf(w, "\nLast %d weeks:  %.1f added/week, %.1f archived/week\n", statsWeeks, float64(addedTotal)/statsWeeks, float64(archivedTotal)/statsWeeks)
+	if chart {
+		fmt.Fprintf(w, "  added         %s\n", sparkline(stats.AddedPerWeek))
+		fmt.Fprintf(w, "  archived      %s\n", sparkline(stats.ArchivedPerWeek))
+	}
+
+	fmt.Fprintln(w, "\nTop domains:")
+	for _, e := range stats.TopDomains {
+		fmt.Fprintf(w, "  %5d  %s\n", e.Count, e.Name)
+	}
+	fmt.Fprintln(w, "\nTop tags:")
+	for _, e := range stats.TopTags {
+		fmt.Fprintf(w, "  %5d  %s\n", e.Count, e.Name)
+	}
+	if len(stats.Languages) > 1 {
+		fmt.Fprintln(w, "\nLanguages:")
+		for _, e := range stats.Languages {
+			fmt.Fprintf(w, "  %5d  %s\n", e.Count, e.Name)
+		}
+	}
+}
+
+func commandStats(conf Config, client *api.Client) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateAll,
+		Domain:     conf.Domain,
+		Search:     conf.SearchQuery,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	stats := computeStats(items, time.Now())
+
+	renderOutput(conf, "stats", &render.Result{
+		Value: stats,
+		Text: func(w io.Writer) error {
+			printStats(w, stats, conf.Chart)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// statusSnapshot is a summary of the local mirror, kept in status.json in
+// the config directory by each sync, so that status lines and prompts can
+// show it without opening the mirror, which a sync may hold.
+type statusSnapshot struct {
+	Unread    int `json:"unread"`
+	Archived  int `json:"archived"`
+	Favorites int `json:"favorites"`
+	// Minutes is the reading time of the unread items.
+	Minutes      int       `json:"minutes"`
+	OldestUnread time.Time `json:"oldest_unread"`
+	Synced       time.Time `json:"synced"`
+}
+
+func statusSnapshotPath() string {
+	return filepath.Join(configDir, "status.json")
+}
+
+// newStatusSnapshot summarizes the items in m.
+func newStatusSnapshot(m *mirror.Mirror) (*statusSnapshot, error) {
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	since, err := m.Since()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &statusSnapshot{}
+	if since > 0 {
+		s.Synced = time.Unix(int64(since), 0)
+	}
+	for _, item := range items {
+		switch item.Status {
+		case api.ItemStatusUnread:
+			s.Unread++
+			s.Minutes += item.ReadingMinutes()
+			if s.OldestUnread.IsZero() || item.TimeAdded.Before(s.OldestUnread) {
+				s.OldestUnread = item.TimeAdded.Time
+			}
+		case api.ItemStatusArchived:
+			s.Archived++
+		}
+		if item.Favorite == 1 {
+			s.Favorites++
+		}
+	}
+	return s, nil
+}
+
+// refreshStatusSnapshot replaces the status snapshot with a summary of the
+// items in m.
+func refreshStatusSnapshot(m *mirror.Mirror) error {
+	s, err := newStatusSnapshot(m)
+	if err != nil {
+		return err
+	}
+	if err := saveJSONToFile(statusSnapshotPath(), s); err != nil {
+		slog.Warn("Could not update the status snapshot", "err", err)
+	}
+	return nil
+}
+
+// shortAge is how long ago t was in the largest whole unit, as in "45m",
+// "3d", or "2w", short enough for a status line; "-" if t is zero.
+func shortAge(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := max(now.Sub(t), 0)
+	day := 24 * time.Hour
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d < day:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d < 7*day:
+		return fmt.Sprintf("%dd", d/day)
+	case d < 30*day:
+		return fmt.Sprintf("%dw", d/(7*day))
+	case d < 365*day:
+		return fmt.Sprintf("%dmo", d/(30*day))
+	}
+	return fmt.Sprintf("%dy", d/(365*day))
+}
+
+var statusPlaceholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// formatStatus fills the placeholders of format, as in "{unread} unread",
+// with the values of s.
+func formatStatus(format string, s *statusSnapshot, now time.Time) (string, error) {
+	values := map[string]string{
+		"unread":     strconv.Itoa(s.Unread),
+		"archived":   strconv.Itoa(s.Archived),
+		"favorites":  strconv.Itoa(s.Favorites),
+		"minutes":    strconv.Itoa(s.Minutes),
+		"oldest_age": shortAge(s.OldestUnread, now),
+		"synced_age": shortAge(s.Synced, now),
+	}
+	var err error
+	out := statusPlaceholderPattern.ReplaceAllStringFunc(format, func(p string) string {
+		v, ok := values[p[1:len(p)-1]]
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown placeholder %s; use {unread}, {archived}, {favorites}, {minutes}, {oldest_age}, or {synced_age}", p)
+		}
+		return v
+	})
+	return out, err
+}
+
+func commandStatus(conf Config) {
+	format := conf.FormatTemplate
+	if format == "" {
+		format = "{unread} unread"
+	}
+	// Checked before anything is read, so that a mistake shows at once
+	if _, err := formatStatus(format, &statusSnapshot{}, time.Now()); err != nil {
+		exitWithError(conf, &usageError{command: "status", err: err})
+	}
+
+	s := &statusSnapshot{}
+	if err := loadJSONFromFile(statusSnapshotPath(), s); err != nil {
+		// Not synced since the snapshot was added: summarized once from
+		// the mirror
+		m, err := openMirror()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		s, err = newStatusSnapshot(m)
+		m.Close()
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		if err := saveJSONToFile(statusSnapshotPath(), s); err != nil {
+			slog.Warn("Could not update the status snapshot", "err", err)
+		}
+	}
+
+	out, _ := formatStatus(format, s, time.Now())
+	fmt.Fprintln(os.Stdout, out)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/llm"
+)
+
+// defaultSummaryPrompt is given to the model along with the text of each
+// item, unless the summarize setting has a prompt.
+const defaultSummaryPrompt = "Summarize the article given in three sentences at most, " +
+	"in the language it is written in. Answer with the summary only."
+
+// summariesPath is the file keeping the summaries made by "pocket summarize".
+func summariesPath() string {
+	return filepath.Join(configDir, "summaries.json")
+}
+
+// storedSummary is the summary of an item, with the model that wrote it.
+type storedSummary struct {
+	Summary string    `json:"summary"`
+	Model   string    `json:"model"`
+	At      time.Time `json:"at"`
+}
+
+// loadSummaries returns the summaries made so far by item ID.
+func loadSummaries() (map[int]storedSummary, error) {
+	summaries := map[int]storedSummary{}
+	err := loadJSONFromFile(summariesPath(), &summaries)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// summaryBackend sets up the model of the summarize setting.
+func summaryBackend(s SummarizeSettings) (llm.Backend, error) {
+	if s.Model == "" {
+		return nil, errors.New(`set "summarize": {"backend": "ollama", "model": "llama3"}, or another model, in config.json first`)
+	}
+	return llm.New(s.Backend, s.URL, s.Model, modelAPIKey(s.APIKey))
+}
+
+// modelAPIKey is the API key set for a model, or $OPENAI_API_KEY.
+func modelAPIKey(key string) string {
+	if key == "" {
+		return os.Getenv("OPENAI_API_KEY")
+	}
+	return key
+}
+
+// summaryText returns the article text of item, cut to maxWords, from the
+// article cache if it is there.
+func summaryText(client *api.Client, item api.Item, maxWords int) (string, error) {
+	cache, err := openArticleCache()
+	if err != nil {
+		return "", err
+	}
+	article, err := cache.Peek(item.ItemID)
+	if os.IsNotExist(err) {
+		article, err = client.Article(item.URL())
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text := articleText(article.HTML)
+	if words := strings.Fields(text); len(words) > maxWords {
+		text = strings.Join(words[:maxWords], " ")
+	}
+	return fmt.Sprintf("%s\n\n%s", item.Title(), text), nil
+}
+
+func commandSummarize(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	backend, err := summaryBackend(settings.Summarize)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	prompt := settings.Summarize.Prompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+	maxWords := settings.Summarize.MaxWords
+	if maxWords <= 0 {
+		maxWords = 4000
+	}
+
+	var items []api.Item
+	if len(conf.ItemIDs) == 0 {
+		items, err = itemsToOpen(conf, client)
+	} else {
+		items, err = itemsByID(conf, client)
+	}
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, "No items to summarize")
+		return
+	}
+
+	summaries, err := loadSummaries()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	failed := false
+	for i, item := range items {
+		if interruptRequested() {
+			break
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("[%d] %s\n", item.ItemID, item.Title())
+
+		stored, ok := summaries[item.ItemID]
+		if !ok || conf.Refresh {
+			text, err := summaryText(client, item, maxWords)
+			if err == nil {
+				stored.Summary, err = backend.Complete(context.Background(), prompt, text)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not summarize item %d: %s\n", item.ItemID, err)
+				failed = true
+				continue
+			}
+			stored.Model, stored.At = settings.Summarize.Model, time.Now()
+			summaries[item.ItemID] = stored
+			// Saved after each, so that an interrupt keeps those made
+			if err := saveJSONToFile(summariesPath(), summaries); err != nil {
+				exitWithError(conf, err)
+			}
+		}
+		fmt.Println(stored.Summary)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
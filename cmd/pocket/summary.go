@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// bulkSummary is the report printed on stderr at the end of the commands
+// changing many items, as text or JSON as --summary says.
+type bulkSummary struct {
+	Command   string        `json:"command"`
+	Processed int           `json:"processed"`
+	Succeeded int           `json:"succeeded"`
+	Queued    int           `json:"queued"`
+	Skipped   int           `json:"skipped"`
+	Failed    []bulkFailure `json:"failed"`
+	// NotSent are the changes left out for --max-api-calls.
+	NotSent     int `json:"not_sent"`
+	APICalls    int `json:"api_calls"`
+	MaxAPICalls int `json:"max_api_calls,omitempty"`
+	// The requests left within the rate limits, if the API told them
+	UserRateLimitRemaining *int `json:"user_rate_limit_remaining"`
+	KeyRateLimitRemaining  *int `json:"key_rate_limit_remaining"`
+
+	// items are those the actions are on, by their ID, to tell which
+	// failed.
+	items map[int]api.Item
+}
+
+// bulkFailure is an item, or a URL for items never added, that could not be
+// changed, with the action to retry on it.
+type bulkFailure struct {
+	ItemID int    `json:"item_id,omitempty"`
+	Action string `json:"action,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// newBulkSummary starts the summary of command.
+func newBulkSummary(command string) *bulkSummary {
+	return &bulkSummary{Command: command, Failed: []bulkFailure{}, items: map[int]api.Item{}}
+}
+
+// describe makes the failures of actions on items name their URL and title.
+func (s *bulkSummary) describe(items []api.Item) {
+	for _, item := range items {
+		s.items[item.ItemID] = item
+	}
+}
+
+// describeFromMirror names the URL and title of the failed items not
+// described, from the local mirror if there is one.
+func (s *bulkSummary) describeFromMirror() {
+	missing := false
+	for _, f := range s.Failed {
+		missing = missing || f.ItemID != 0 && f.URL == ""
+	}
+	if !missing {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "mirror.db")); err != nil {
+		return
+	}
+	m, err := openMirror()
+	if err != nil {
+		return
+	}
+	defer m.Close()
+	items, err := m.Items()
+	if err != nil {
+		return
+	}
+	s.describe(items)
+	for i, f := range s.Failed {
+		if item, ok := s.items[f.ItemID]; ok && f.URL == "" {
+			s.Failed[i].URL, s.Failed[i].Title = item.URL(), item.Title()
+		}
+	}
+}
+
+// checkSummaryFormat returns an error unless format is one --summary takes.
+func checkSummaryFormat(format string) error {
+	switch format {
+	case "", "text", "json", "none":
+		return nil
+	}
+	return fmt.Errorf("unknown summary format %q; use \"text\", \"json\", or \"none\"", format)
+}
+
+// addResults counts the outcome of actions, failed if their results say so
+// or, with err, if they were not sent.
+func (s *bulkSummary) addResults(actions []*api.Action, results []api.ActionResult, err error) {
+	for i, action := range actions {
+		s.Processed++
+		switch {
+		case i < len(results) && results[i].Success:
+			s.Succeeded++
+		case err == errOverBudget:
+			s.Processed--
+			s.NotSent++
+		case err != nil:
+			s.fail(action, "not sent: "+err.Error())
+		case i < len(results) && results[i].Error != "":
+			s.fail(action, results[i].Error)
+		default:
+			s.fail(action, "failed")
+		}
+	}
+}
+
+// addAdded counts the items of batch added, all but those failed.
+func (s *bulkSummary) addAdded(batch, failed []api.Item) {
+	s.Processed += len(batch)
+	s.Succeeded += len(batch) - len(failed)
+	for _, item := range failed {
+		s.Failed = append(s.Failed, bulkFailure{Action: "add", URL: item.URL(), Title: item.Title(), Reason: "not added"})
+	}
+}
+
+// addNotSent counts n changes left out for --max-api-calls.
+func (s *bulkSummary) addNotSent(n int) {
+	s.NotSent += n
+}
+
+// complete tells if every change was made or queued.
+func (s *bulkSummary) complete() bool {
+	return len(s.Failed) == 0 && s.NotSent == 0
+}
+
+// addQueued counts n actions queued until the next sync.
+func (s *bulkSummary) addQueued(n int) {
+	s.Processed += n
+	s.Queued += n
+}
+
+// fail counts a failure of action, naming its item, or the URL it adds.
+func (s *bulkSummary) fail(action *api.Action, reason string) {
+	f := bulkFailure{ItemID: action.ItemID, Action: action.Action, URL: action.URL, Title: action.Title, Reason: reason}
+	if item, ok := s.items[action.ItemID]; ok && action.ItemID != 0 {
+		f.URL, f.Title = item.URL(), item.Title()
+	}
+	s.Failed = append(s.Failed, f)
+}
+
+// report prints the summary in format, with the API calls made by the
+// command, and exits with status 1 unless it is complete.
+func (s *bulkSummary) report(format string) {
+	s.describeFromMirror()
+	usage := api.CurrentUsage()
+	s.APICalls = usage.Requests
+	s.MaxAPICalls = maxAPICalls
+	if usage.UserRemaining >= 0 {
+		s.UserRateLimitRemaining = &usage.UserRemaining
+	}
+	if usage.KeyRemaining >= 0 {
+		s.KeyRateLimitRemaining = &usage.KeyRemaining
+	}
+
+	switch format {
+	case "json":
+		if err := json.NewEncoder(os.Stderr).Encode(s); err != nil {
+			panic(err)
+		}
+	case "none":
+	default:
+		fmt.Fprint(os.Stderr, s.text())
+	}
+
+	if !s.complete() {
+		os.Exit(1)
+	}
+}
+
+// text returns the summary as lines of text.
+func (s *bulkSummary) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d processed, %d succeeded", s.Command, s.Processed, s.Succeeded)
+	if s.Queued > 0 {
+		fmt.Fprintf(&b, ", %d queued until the next sync", s.Queued)
+	}
+	if s.Skipped > 0 {
+		fmt.Fprintf(&b, ", %d skipped", s.Skipped)
+	}
+	fmt.Fprintf(&b, ", %d failed", len(s.Failed))
+	if s.NotSent > 0 {
+		fmt.Fprintf(&b, ", %d not sent within --max-api-calls", s.NotSent)
+	}
+	b.WriteString("\n")
+	for _, f := range s.Failed {
+		what := f.URL
+		if f.ItemID != 0 {
+			what = fmt.Sprintf("item %d", f.ItemID)
+			if f.URL != "" {
+				what += " " + f.URL
+			}
+		}
+		if f.Title != "" {
+			what += fmt.Sprintf(" (%s)", f.Title)
+		}
+		fmt.Fprintf(&b, "  %s %s: %s\n", f.Action, what, f.Reason)
+	}
+
+	if s.APICalls == 1 {
+		b.WriteString("1 API call")
+	} else {
+		fmt.Fprintf(&b, "%d API calls", s.APICalls)
+	}
+	if s.MaxAPICalls > 0 {
+		fmt.Fprintf(&b, " of the %d allowed", s.MaxAPICalls)
+	}
+	limits := []string{}
+	if s.UserRateLimitRemaining != nil {
+		limits = append(limits, fmt.Sprintf("%d for the user", *s.UserRateLimitRemaining))
+	}
+	if s.KeyRateLimitRemaining != nil {
+		limits = append(limits, fmt.Sprintf("%d for the consumer key", *s.KeyRateLimitRemaining))
+	}
+	if len(limits) > 0 {
+		fmt.Fprintf(&b, "; rate limit remaining: %s", strings.Join(limits, ", "))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
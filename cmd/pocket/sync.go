@@ -0,0 +1,457 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// lockedStore releases the state lock once the store is closed.
+type lockedStore struct {
+	mirror.Store
+	unlock func()
+}
+
+func (s lockedStore) Close() error {
+	defer s.unlock()
+	return s.Store.Close()
+}
+
+// openMirror opens the local mirror of the account in the config directory,
+// holding the state lock until it is closed.
+func openMirror() (*mirror.Mirror, error) {
+	unlock, err := lockState()
+	if err != nil {
+		return nil, err
+	}
+	c, err := cacheCipher()
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	store, err := mirror.OpenBoltStore(filepath.Join(configDir, "mirror.db"))
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	store.Cipher = c
+	m := mirror.New(lockedStore{Store: store, unlock: unlock})
+	m.Queue, err = openQueue()
+	if err != nil {
+		store.Close()
+		unlock()
+		return nil, err
+	}
+	m.Interrupted = interruptRequested
+	return m, nil
+}
+
+// compactMirror copies the mirror to a new database encrypted with c, if not
+// nil, which replaces it. bbolt never gives back the pages it frees, so this
+// is how the file shrinks; it also leaves none of the items in the clear in
+// those pages when encrypting. The state lock is to be held.
+func compactMirror(c mirror.Cipher) error {
+	path := filepath.Join(configDir, "mirror.db")
+	src, err := mirror.OpenBoltStore(path)
+	if err != nil {
+		return err
+	}
+	src.Cipher = c
+
+	tmp := path + ".new"
+	os.Remove(tmp)
+	dst, err := mirror.OpenBoltStore(tmp)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	dst.Cipher = c
+	err = mirror.CopyStore(dst, src)
+	src.Close()
+	if err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	err = dst.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// wantedArticles returns the Keep function of the article cache, which
+// wants the articles of the items still unread in the mirror.
+func wantedArticles(m *mirror.Mirror) (func(itemID int) bool, error) {
+	items, err := m.Retrieve(&api.RetrieveOption{State: api.StateUnread})
+	if err != nil {
+		return nil, err
+	}
+	unread := map[int]bool{}
+	for _, item := range items {
+		unread[item.ItemID] = true
+	}
+	return func(itemID int) bool { return unread[itemID] }, nil
+}
+
+// trimArticleCache removes the articles of the items no longer in the
+// mirror, then evicts articles until the cache is within its size limit,
+// those of items no longer unread first. It returns how many were removed.
+func trimArticleCache(m *mirror.Mirror, cache *mirror.ArticleCache) (int, error) {
+	items, err := m.Items()
+	if err != nil {
+		return 0, err
+	}
+	mirrored := map[int]bool{}
+	for _, item := range items {
+		mirrored[item.ItemID] = true
+	}
+	pruned, err := cache.Prune(func(itemID int) bool { return mirrored[itemID] })
+	if err != nil {
+		return pruned, err
+	}
+
+	cache.Keep, err = wantedArticles(m)
+	if err != nil {
+		return pruned, err
+	}
+	evicted, err := cache.Trim()
+	return pruned + evicted, err
+}
+
+// isNetworkError reports whether err means Pocket could not be reached at all,
+// answered only with a maintenance page or the like, or no longer serves the
+// endpoint, as opposed to the API returning an error.
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	var downErr *api.UnavailableError
+	var pageErr *api.PageError
+	var goneErr *api.GoneError
+	return errors.As(err, &urlErr) || errors.As(err, &downErr) || errors.As(err, &pageErr) ||
+		errors.As(err, &goneErr)
+}
+
+// queueHost names this machine among those syncing the config directory,
+// with $POCKET_HOST, or its host name.
+func queueHost() string {
+	host := os.Getenv("POCKET_HOST")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	host = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, host)
+	if host == "" {
+		return "localhost"
+	}
+	return host
+}
+
+// openQueue returns the queue of actions waiting to be sent, kept in
+// queue/ by every machine syncing the config directory, moving into it the
+// actions queued in queue.jsonl by older versions. The state lock must be
+// held.
+func openQueue() (*mirror.Queue, error) {
+	q := mirror.NewSharedQueue(filepath.Join(configDir, "queue"), queueHost())
+
+	legacyPath := filepath.Join(configDir, "queue.jsonl")
+	legacy, err := mirror.NewQueue(legacyPath).Pending()
+	if err != nil {
+		return nil, err
+	}
+	if len(legacy) > 0 {
+		if err := q.Add(legacy...); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Remove(legacyPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return q, nil
+}
+
+// queueDepth returns the number of actions waiting to be sent, without
+// taking the lock nor moving the legacy queue into the shared one.
+func queueDepth() (int, error) {
+	actions, err := mirror.NewSharedQueue(filepath.Join(configDir, "queue"), queueHost()).Pending()
+	if err != nil {
+		return 0, err
+	}
+	// Not moved yet into the shared queue
+	legacy, err := mirror.NewQueue(filepath.Join(configDir, "queue.jsonl")).Pending()
+	if err != nil {
+		return 0, err
+	}
+	return len(actions) + len(legacy), nil
+}
+
+// commandAddQueued queues the URL to be saved by the next sync, of this
+// machine or of any other syncing the config directory, without reaching
+// Pocket nor needing to be authorized.
+func commandAddQueued(conf Config) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	unlock, err := lockState()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	defer unlock()
+	q, err := openQueue()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	action := newSaveAction(addRequest{URL: conf.URL, Title: conf.Title, Tags: splitTags(conf.Tags)}, settings.Rules)
+	action.Time = time.Now().Unix()
+	if err := q.Add(action); err != nil {
+		exitWithError(conf, err)
+	}
+	fmt.Printf("Queued %s until the next sync\n", action.URL)
+}
+
+// modifyOrQueue sends actions to Pocket. If Pocket cannot be reached, the
+// actions are applied to the local mirror and queued for the next sync
+// instead, and queued is true.
+func modifyOrQueue(client *api.Client, actions ...*api.Action) (res *api.ModifyResult, queued bool, err error) {
+	beginCritical()
+	defer endCritical()
+
+	res, err = modifyWithHooks(actions, func(actions ...*api.Action) (*api.ModifyResult, error) {
+		res, err := client.Modify(actions...)
+		if err == nil || !isNetworkError(err) {
+			return res, err
+		}
+		slog.Debug("Pocket is unreachable; queueing actions", "actions", len(actions), "err", err)
+
+		m, err := openMirror()
+		if err != nil {
+			return nil, err
+		}
+		defer m.Close()
+
+		err = m.Enqueue(actions...)
+		queued = err == nil
+		return nil, err
+	})
+	return res, queued, err
+}
+
+// syncReport is the outcome of runSync.
+type syncReport struct {
+	Pushed *mirror.PushResult
+	Synced *mirror.SyncResult
+	// Woken is the number of snoozed items brought back.
+	Woken int
+	// Routed is the number of items sent through the routes of their tags.
+	Routed int
+	// Articles is nil unless article text was fetched.
+	Articles *mirror.FetchArticlesResult
+	// Drift is nil unless articles were fingerprinted.
+	Drift *driftResult
+}
+
+// runSync pushes the queued actions, syncs the mirror, brings back snoozed
+// items that are due, caches the article text of unread items if
+// fetchArticles is set, fingerprints articles to find those changed if
+// drift is, and refreshes the search index. The changes seen are
+// recorded in the history, and the items given tags sent through their
+// routes.
+func runSync(m *mirror.Mirror, client *api.Client, fetchArticles, drift bool) (*syncReport, error) {
+	// Push and FetchArticles stop early when interrupted; the queue and
+	// the article cache are consistent between their steps.
+	beginCritical()
+	defer endCritical()
+
+	report := &syncReport{}
+
+	var err error
+	// In read-only mode, the queue waits for a sync without it
+	report.Pushed = &mirror.PushResult{}
+	if !api.ReadOnly {
+		report.Pushed, err = m.Push(client, modifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := []mirror.Change{}
+	tagged := map[int][]string{}
+	onChange := m.OnChange
+	m.OnChange = func(c mirror.Change) {
+		// Pocket keeps when items were added, and updates say nothing
+		if c.Kind != mirror.ChangeAdded && c.Kind != mirror.ChangeUpdated {
+			seen = append(seen, c)
+		}
+		switch c.Kind {
+		case mirror.ChangeAdded:
+			tagged[c.ItemID] = nil
+		case mirror.ChangeTagsChanged:
+			if len(c.TagsAdded) > 0 {
+				tagged[c.ItemID] = append(tagged[c.ItemID], c.TagsAdded...)
+			}
+		}
+		if onChange != nil {
+			onChange(c)
+		}
+	}
+	report.Synced, err = m.Sync(client)
+	m.OnChange = onChange
+	if err != nil {
+		return nil, err
+	}
+	// A first sync sees every item as new
+	if !report.Synced.Full {
+		if err := recordHistory(historySeen, seen...); err != nil {
+			slog.Warn("Could not record the changes in the history", "err", err)
+		}
+		report.Routed, err = routeItems(m, tagRoutes, tagged)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !api.ReadOnly {
+		report.Woken, err = wakeSnoozed(m, client, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cache, err := openArticleCache()
+	if err != nil {
+		return nil, err
+	}
+	if fetchArticles {
+		report.Articles, err = m.FetchArticles(client, cache)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Items deleted or archived, or a lower size limit, may leave articles
+	// to remove without --articles too
+	_, err = trimArticleCache(m, cache)
+	if err != nil {
+		return nil, err
+	}
+	if drift {
+		report.Drift, err = checkDrift(m, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = refreshSearchIndex(m)
+	if err != nil {
+		return nil, err
+	}
+	err = refreshTagCache(m)
+	if err != nil {
+		return nil, err
+	}
+	err = refreshStatusSnapshot(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Lines describes the report for humans.
+func (r *syncReport) Lines() []string {
+	lines := []string{}
+
+	if r.Pushed.Sent+r.Pushed.Dropped > 0 {
+		lines = append(lines, fmt.Sprintf("Pushed %d queued actions (%d rejected by Pocket and dropped)", r.Pushed.Sent, r.Pushed.Dropped))
+	}
+
+	kind := "Synced"
+	if r.Synced.Full {
+		kind = "Downloaded"
+	}
+	lines = append(lines, fmt.Sprintf("%s: %d added, %d updated, %d deleted", kind, r.Synced.Added, r.Synced.Updated, r.Synced.Deleted))
+
+	if r.Woken > 0 {
+		lines = append(lines, fmt.Sprintf("Brought back %d snoozed items", r.Woken))
+	}
+
+	if r.Routed > 0 {
+		lines = append(lines, fmt.Sprintf("Routed %d items by their tags", r.Routed))
+	}
+
+	if r.Articles != nil {
+		lines = append(lines, fmt.Sprintf("Articles: %d fetched, %d failed, %d removed", r.Articles.Fetched, r.Articles.Failed, r.Articles.Pruned))
+		if r.Articles.Full {
+			lines = append(lines, "The article cache is full; raise cache.max_mb in config.json to keep more")
+		}
+	}
+
+	if r.Drift != nil {
+		lines = append(lines, fmt.Sprintf("Drift: %d articles fingerprinted, %d failed, %d newly changed", r.Drift.Checked, r.Drift.Failed, r.Drift.Drifted))
+		if r.Drift.Drifted > 0 {
+			lines = append(lines, `Run "pocket drift" to see the articles changed since they were saved`)
+		}
+	}
+
+	return lines
+}
+
+// LogArgs describes the report for the log, as slog key-value pairs.
+func (r *syncReport) LogArgs() []any {
+	args := []any{
+		"pushed", r.Pushed.Sent,
+		"dropped", r.Pushed.Dropped,
+		"full", r.Synced.Full,
+		"added", r.Synced.Added,
+		"updated", r.Synced.Updated,
+		"deleted", r.Synced.Deleted,
+		"woken", r.Woken,
+		"routed", r.Routed,
+	}
+	if r.Articles != nil {
+		args = append(args,
+			"articles_fetched", r.Articles.Fetched,
+			"articles_failed", r.Articles.Failed,
+			"articles_pruned", r.Articles.Pruned,
+			"cache_full", r.Articles.Full,
+		)
+	}
+	if r.Drift != nil {
+		args = append(args,
+			"drift_checked", r.Drift.Checked,
+			"drift_failed", r.Drift.Failed,
+			"drifted", r.Drift.Drifted,
+		)
+	}
+	return args
+}
+
+func commandSync(conf Config, client *api.Client) {
+	m, err := openMirror()
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	report, err := runSync(m, client, conf.FetchArticles, conf.CheckDrift)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, line := range report.Lines() {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	neturl "net/url"
+	"os"
+	"strconv"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/tabs"
+)
+
+// unsavedTabs leaves out of open the tabs whose pages are saved already,
+// as far as the local mirror knows, returning how many were.
+func unsavedTabs(open []tabs.Tab) ([]tabs.Tab, int) {
+	m, err := openMirror()
+	if err != nil {
+		slog.Debug("Could not open the local mirror to find the tabs saved", "err", err)
+		return open, 0
+	}
+	items, err := m.Items()
+	m.Close()
+	if err != nil {
+		slog.Debug("Could not read the local mirror to find the tabs saved", "err", err)
+		return open, 0
+	}
+
+	saved := map[string]bool{}
+	for _, item := range items {
+		saved[library.URLKey(item.URL())] = true
+	}
+	unsaved := []tabs.Tab{}
+	for _, tab := range open {
+		if !saved[library.URLKey(tab.URL)] {
+			unsaved = append(unsaved, tab)
+		}
+	}
+	return unsaved, len(open) - len(unsaved)
+}
+
+// commandAddTabs saves the tabs open in the browser of --from-tabs that the
+// user chooses, or all of them with --yes.
+func commandAddTabs(conf Config, client *api.Client) {
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	open, err := tabs.Open(conf.FromTabs, conf.Profile)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	open, saved := unsavedTabs(open)
+	if saved > 0 {
+		fmt.Fprintf(os.Stderr, "Leaving out %d tab(s) saved already\n", saved)
+	}
+	if len(open) == 0 {
+		fmt.Println("No tabs to save")
+		return
+	}
+
+	chosen := make([]int, len(open))
+	for i := range open {
+		chosen[i] = i
+	}
+	if !conf.Yes {
+		labels := make([]string, len(open))
+		lines := make([]string, len(open))
+		for i, tab := range open {
+			title, host := tab.Title, tab.URL
+			if u, err := neturl.Parse(tab.URL); err == nil {
+				host = u.Host
+			}
+			if title == "" {
+				title = tab.URL
+			}
+			labels[i] = title + ", " + host
+			lines[i] = pickFields(strconv.Itoa(i), title, tab.URL)
+		}
+		chosen = chooseLines("add --from-tabs", labels, lines)
+	}
+	if len(chosen) == 0 {
+		return
+	}
+
+	tags := splitTags(conf.Tags)
+	actions := make([]*api.Action, len(chosen))
+	for i, index := range chosen {
+		tab := open[index]
+		actions[i] = newSaveAction(addRequest{URL: tab.URL, Title: tab.Title, Tags: tags}, settings.Rules)
+	}
+	_, queued, err := modifyOrQueue(client, actions...)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	if queued {
+		fmt.Printf("Queued %d tab(s) until the next sync\n", len(actions))
+	} else {
+		fmt.Printf("Saved %d tab(s)\n", len(actions))
+	}
+}
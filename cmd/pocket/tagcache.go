@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// tagCache is the tags in use, with the number of items having each, kept in
+// tags.json in the config directory for shell completion and the TUI, so
+// that they do not have to read every item.
+type tagCache struct {
+	Updated time.Time      `json:"updated"`
+	Counts  map[string]int `json:"counts"`
+}
+
+func tagCachePath() string {
+	return filepath.Join(configDir, "tags.json")
+}
+
+// loadTagCache reads the tag cache, returning nil if there is none yet.
+func loadTagCache() *tagCache {
+	cache := &tagCache{}
+	if err := loadJSONFromFile(tagCachePath(), cache); err != nil || cache.Counts == nil {
+		return nil
+	}
+	return cache
+}
+
+// cachedTags returns the cached tags by how often they are used, or nil if
+// there are none.
+func cachedTags() []string {
+	cache := loadTagCache()
+	if cache == nil {
+		return nil
+	}
+	tags := make([]string, 0, len(cache.Counts))
+	for tag := range cache.Counts {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if cache.Counts[tags[i]] != cache.Counts[tags[j]] {
+			return cache.Counts[tags[i]] > cache.Counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags
+}
+
+// updateTagCache counts the tags of items. If they are all of the items, the
+// cache is replaced; otherwise the tags are added to it, counted at least as
+// many times as among items, and tags no longer used only go with the next
+// complete retrieve or sync.
+func updateTagCache(items []api.Item, complete bool) {
+	counts := map[string]int{}
+	for _, item := range items {
+		for tag := range item.Tags {
+			counts[tag]++
+		}
+	}
+
+	cache := loadTagCache()
+	if cache == nil {
+		cache = &tagCache{Counts: map[string]int{}}
+	}
+	if complete {
+		if maps.Equal(cache.Counts, counts) {
+			return
+		}
+		cache.Counts = counts
+	} else {
+		changed := false
+		for tag, n := range counts {
+			if n > cache.Counts[tag] {
+				cache.Counts[tag] = n
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+	cache.Updated = time.Now()
+
+	if err := saveJSONToFile(tagCachePath(), cache); err != nil {
+		slog.Warn("Could not update the tag cache", "err", err)
+	}
+}
+
+// retrievedAll tells if the items retrieved with options are all of them.
+func retrievedAll(options *api.RetrieveOption) bool {
+	return options.State == api.StateAll &&
+		options.Favorite == "" && options.Tag == "" && options.ContentType == "" &&
+		options.Search == "" && options.Domain == "" &&
+		options.Since == 0 && options.Count == 0 && options.Offset == 0 &&
+		len(langFilter) == 0 && minutesFilter.Min == 0 && minutesFilter.Max == 0
+}
+
+// refreshTagCache replaces the tag cache with the tags of the items in the
+// mirror.
+func refreshTagCache(m *mirror.Mirror) error {
+	items, err := m.Items()
+	if err != nil {
+		return err
+	}
+	updateTagCache(items, true)
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/render"
+)
+
+// escBoldCyan styles the most used tags of a tag cloud.
+const escBoldCyan = "\x1b[1;36m"
+
+// tagSummary describes the items with a tag.
+type tagSummary struct {
+	Tag    string `json:"tag"`
+	Items  int    `json:"items"`
+	Unread int    `json:"unread"`
+	// LastAdded is when the newest item with the tag was added.
+	LastAdded time.Time `json:"last_added"`
+}
+
+// summarizeTags summarizes the items by tag, most used first.
+func summarizeTags(items []api.Item) []tagSummary {
+	summaries := map[string]*tagSummary{}
+	for _, item := range items {
+		for _, tag := range item.TagNames() {
+			s, ok := summaries[tag]
+			if !ok {
+				s = &tagSummary{Tag: tag}
+				summaries[tag] = s
+			}
+			s.Items++
+			if item.Status == api.ItemStatusUnread {
+				s.Unread++
+			}
+			if item.TimeAdded.After(s.LastAdded) {
+				s.LastAdded = item.TimeAdded.Time
+			}
+		}
+	}
+
+	result := make([]tagSummary, 0, len(summaries))
+	for _, s := range summaries {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Items != result[j].Items {
+			return result[i].Items > result[j].Items
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
+
+// staleTags returns the tags of no item added since the given time, least
+// recently used first.
+func staleTags(summaries []tagSummary, since time.Time) []tagSummary {
+	stale := []tagSummary{}
+	for _, s := range summaries {
+		if s.LastAdded.Before(since) {
+			stale = append(stale, s)
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].LastAdded.Before(stale[j].LastAdded) })
+	return stale
+}
+
+// cloudWeight places count on a scale from 0 to 3 relative to the most used
+// tag, logarithmically so that a few popular tags do not flatten the rest.
+func cloudWeight(count, most int) int {
+	if most <= 1 {
+		return 1
+	}
+	return min(int(4*math.Log(float64(count))/math.Log(float64(most)+1)), 3)
+}
+
+// printTagCloud prints the tags in alphabetical order, wrapped to width.
+// Styled, little used tags are dim and much used ones bold; otherwise each
+// tag is followed by its count.
+func printTagCloud(w io.Writer, summaries []tagSummary, width int, styled bool) {
+	tags := append([]tagSummary{}, summaries...)
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Tag < tags[j].Tag })
+
+	most := 0
+	for _, s := range tags {
+		most = max(most, s.Items)
+	}
+
+	styles := []string{escDim, "", escBold, escBoldCyan}
+	column := 0
+	for _, s := range tags {
+		word := s.Tag
+		if !styled {
+			word = fmt.Sprintf("%s (%d)", s.Tag, s.Items)
+		}
+		if column > 0 && column+2+len(word) > width {
+			fmt.Fprintln(w)
+			column = 0
+		}
+		if column > 0 {
+			fmt.Fprint(w, "  ")
+			column += 2
+		}
+		if style := styles[cloudWeight(s.Items, most)]; styled && style != "" {
+			fmt.Fprint(w, style+word+escReset)
+		} else {
+			fmt.Fprint(w, word)
+		}
+		column += len(word)
+	}
+	if column > 0 {
+		fmt.Fprintln(w)
+	}
+}
+
+func commandTags(conf Config, client *api.Client) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateAll,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	summaries := summarizeTags(items)
+	if conf.Stale {
+		summaries = staleTags(summaries, time.Now().AddDate(-1, 0, 0))
+	}
+
+	rows := [][]string{}
+	for _, s := range summaries {
+		rows = append(rows, []string{s.Tag, strconv.Itoa(s.Items), strconv.Itoa(s.Unread), formatTime(s.LastAdded, "2006-01-02")})
+	}
+	renderOutput(conf, "tags", &render.Result{
+		Value:   summaries,
+		Columns: []string{"tag", "items", "unread", "last_added"},
+		Rows:    rows,
+		Text: func(w io.Writer) error {
+			if conf.Cloud {
+				width, styled := 80, false
+				if fd := int(os.Stdout.Fd()); term.IsTerminal(fd) {
+					if cols, _, err := term.GetSize(fd); err == nil {
+						width = cols
+					}
+					styled = os.Getenv("NO_COLOR") == "" && !screenReader
+				}
+				printTagCloud(w, summaries, width, styled)
+				return nil
+			}
+			fmt.Fprintf(w, "%6s  %6s  %-10s  %s\n", "ITEMS", "UNREAD", "LAST ADDED", "TAG")
+			for _, s := range summaries {
+				fmt.Fprintf(w, "%6d  %6d  %-10s  %s\n", s.Items, s.Unread, formatTime(s.LastAdded, "2006-01-02"), s.Tag)
+			}
+			return nil
+		},
+	})
+}
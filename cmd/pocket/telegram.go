@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/chatbot"
+)
+
+// hashtagPattern finds the hashtags of a message, not those in URLs.
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]+)`)
+
+// telegramAllowed tells whether the sender of m is among the users of s,
+// named by their ID or username.
+func telegramAllowed(s TelegramSettings, m chatbot.TelegramMessage) bool {
+	for _, user := range s.Users {
+		user = strings.TrimPrefix(user, "@")
+		if user == strconv.FormatInt(m.UserID, 10) || m.Username != "" && strings.EqualFold(user, m.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+// telegramSave saves the URLs messaged in m, with its hashtags as tags, and
+// returns the reply: the titles Pocket found for them.
+func telegramSave(client *api.Client, s TelegramSettings, rules []Rule, m chatbot.TelegramMessage) string {
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, url := range append(append([]string{}, m.Links...), clipboardURLs(m.Text)...) {
+		if !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return "Send me a URL to save it, with #hashtags to tag it"
+	}
+	tags := append([]string{}, s.Tags...)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(m.Text, -1) {
+		tags = append(tags, match[1])
+	}
+
+	actions := make([]*api.Action, len(urls))
+	for i, url := range urls {
+		actions[i] = newSaveAction(addRequest{URL: url, Tags: tags}, rules)
+	}
+	res, queued, err := modifyOrQueue(client, actions...)
+	switch {
+	case err != nil:
+		return "Could not save: " + err.Error()
+	case queued:
+		return "Saved offline, to be sent with the next sync:\n" + strings.Join(urls, "\n")
+	}
+
+	lines := []string{}
+	for i, url := range urls {
+		var result api.ActionResult
+		if res != nil && i < len(res.ActionDetails) {
+			result = res.ActionDetails[i]
+		}
+		switch {
+		case !result.Success:
+			lines = append(lines, fmt.Sprintf("Could not save %s: %s", url, result.Error))
+		case result.Title != "":
+			lines = append(lines, "Saved: "+result.Title)
+		default:
+			lines = append(lines, "Saved "+url)
+		}
+	}
+	if len(tags) > 0 {
+		lines = append(lines, "Tagged "+strings.Join(tags, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// daemonTelegram saves the URLs messaged to the Telegram bot of the
+// settings, for as long as the daemon runs.
+func daemonTelegram(client *api.Client, live *liveSettings) {
+	s := live.Get().Telegram
+	bot, err := chatbot.NewTelegram(s.URL, s.Token)
+	if err != nil {
+		slog.Error("Could not start the Telegram bot", "err", err)
+		return
+	}
+
+	ctx := context.Background()
+	slog.Info("Taking URLs from Telegram")
+	for {
+		messages, err := bot.Updates(ctx)
+		if err != nil {
+			slog.Warn("Could not read the messages of the Telegram bot", "err", err)
+			time.Sleep(botRetryWait)
+			continue
+		}
+		for _, m := range messages {
+			reply := ""
+			if telegramAllowed(s, m) {
+				reply = telegramSave(client, s, live.Get().Rules, m)
+			} else {
+				slog.Warn("Ignoring a Telegram user not allowed", "user_id", m.UserID, "username", m.Username)
+				reply = fmt.Sprintf("Your Telegram user ID is %d; add it to telegram.users in config.json to save with this bot", m.UserID)
+			}
+			if err := bot.Reply(ctx, m, reply); err != nil {
+				slog.Warn("Could not answer on Telegram", "err", err)
+			}
+		}
+	}
+}
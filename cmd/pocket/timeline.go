@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+type timelineMonth struct {
+	Month string     `json:"month"`
+	Items []api.Item `json:"items"`
+}
+
+// groupByMonth groups items by the month they were added in, oldest month
+// first, with the items in each month ordered by add time.
+func groupByMonth(items []api.Item) []timelineMonth {
+	byMonth := map[string][]api.Item{}
+	for _, item := range items {
+		month := item.TimeAdded.Format("2006-01")
+		byMonth[month] = append(byMonth[month], item)
+	}
+
+	months := make([]timelineMonth, 0, len(byMonth))
+	for month, items := range byMonth {
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].TimeAdded.Before(items[j].TimeAdded.Time)
+		})
+		months = append(months, timelineMonth{Month: month, Items: items})
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Month < months[j].Month
+	})
+
+	return months
+}
+
+func commandTimeline(conf Config, client *api.Client) {
+	checkOutput(conf, "timeline")
+	switch api.State(conf.State) {
+	case api.StateUnread, api.StateArchive, api.StateAll:
+	default:
+		exitWithError(conf, &usageError{command: "timeline", err: fmt.Errorf("unknown state %q; use \"unread\", \"archive\", or \"all\"", conf.State)})
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:  api.State(conf.State),
+		Domain: conf.Domain,
+		Search: conf.SearchQuery,
+		Tag:    conf.Tag,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	months := groupByMonth(items)
+	listed := []api.Item{}
+	for _, month := range months {
+		listed = append(listed, month.Items...)
+	}
+	result := itemsResult(listed)
+	result.Value = months
+	result.Text = func(w io.Writer) error {
+		for _, month := range months {
+			if conf.CountsOnly {
+				fmt.Fprintf(w, "%s %5d %s\n", month.Month, len(month.Items), strings.Repeat("▇", (len(month.Items)+4)/5))
+				continue
+			}
+
+			fmt.Fprintf(w, "%s (%d)\n", month.Month, len(month.Items))
+			for _, item := range month.Items {
+				fmt.Fprintf(w, "  [%9d] %s %s\n", item.ItemID, formatTime(item.TimeAdded.Time, "01-02"), item.Title())
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+	renderOutput(conf, "timeline", result)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/render"
+)
+
+var relativeTimePattern = regexp.MustCompile(`^(\d+)([dwmy])$`)
+
+// parseSince parses either a date (2006-01-02) or a relative period such as
+// "30d", "6w", "3m", or "1y" counting back from now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if m := relativeTimePattern.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return now.AddDate(0, 0, -n), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		case "m":
+			return now.AddDate(0, -n, 0), nil
+		default:
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q; use a date like 2006-01-02 or a period like 30d, 6w, 3m, 1y", s)
+	}
+	return t, nil
+}
+
+// authorNames returns the names of the item's authors.
+func authorNames(item api.Item) []string {
+	names := []string{}
+	for _, author := range item.Authors {
+		if name, ok := author["name"].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type topEntry struct {
+	Name    string  `json:"name"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+type topReport struct {
+	Items   int        `json:"items"`
+	Domains []topEntry `json:"domains"`
+	Authors []topEntry `json:"authors"`
+	Tags    []topEntry `json:"tags"`
+}
+
+func withPercentages(entries []countEntry, total int) []topEntry {
+	result := make([]topEntry, 0, len(entries))
+	for _, e := range entries {
+		percent := 0.0
+		if total > 0 {
+			percent = 100 * float64(e.Count) / float64(total)
+		}
+		result = append(result, topEntry{Name: e.Name, Count: e.Count, Percent: percent})
+	}
+	return result
+}
+
+func computeTop(items []api.Item, limit int) *topReport {
+	domains := map[string]int{}
+	authors := map[string]int{}
+	tags := map[string]int{}
+	for _, item := range items {
+		if domain := item.Domain(); domain != "" {
+			domains[domain]++
+		}
+		for _, name := range authorNames(item) {
+			authors[name]++
+		}
+		for _, tag := range item.TagNames() {
+			tags[tag]++
+		}
+	}
+
+	return &topReport{
+		Items:   len(items),
+		Domains: withPercentages(topCounts(domains, limit), len(items)),
+		Authors: withPercentages(topCounts(authors, limit), len(items)),
+		Tags:    withPercentages(topCounts(tags, limit), len(items)),
+	}
+}
+
+func printTopSection(w io.Writer, title string, entries []topEntry) {
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, e := range entries {
+		fmt.Fprintf(w, "  %5d  %5.1f%%  %s\n", e.Count, e.Percent, e.Name)
+	}
+	fmt.Fprintln(w)
+}
+
+func commandTop(conf Config, client *api.Client) {
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateAll,
+		Tag:        conf.Tag,
+		DetailType: api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if conf.Since != "" {
+		since, err := parseSince(conf.Since, time.Now())
+		if err != nil {
+			exitWithError(conf, &usageError{command: "top", err: err})
+		}
+
+		recent := []api.Item{}
+		for _, item := range items {
+			if !item.TimeAdded.Before(since) {
+				recent = append(recent, item)
+			}
+		}
+		items = recent
+	}
+
+	report := computeTop(items, conf.Limit)
+
+	rows := [][]string{}
+	for _, section := range []struct {
+		name    string
+		entries []topEntry
+	}{{"domain", report.Domains}, {"author", report.Authors}, {"tag", report.Tags}} {
+		for _, e := range section.entries {
+			rows = append(rows, []string{section.name, e.Name, strconv.Itoa(e.Count), strconv.FormatFloat(e.Percent, 'f', 1, 64)})
+		}
+	}
+	renderOutput(conf, "top", &render.Result{
+		Value:   report,
+		Columns: []string{"kind", "name", "items", "percent"},
+		Rows:    rows,
+		Text: func(w io.Writer) error {
+			fmt.Fprintf(w, "%d items\n\n", report.Items)
+			printTopSection(w, "Domains", report.Domains)
+			printTopSection(w, "Authors", report.Authors)
+			printTopSection(w, "Tags", report.Tags)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// trashTagPrefix starts the tag recording when an item was moved to the
+// trash, as in "trash:2025-07-01".
+const trashTagPrefix = "trash:"
+
+// trashing is the trash setting, set up in main.
+var trashing TrashSettings
+
+// deleteActions returns the actions deleting an item or, with the trash
+// setting on, moving it to the trash: archiving it, tagged with the day.
+func deleteActions(itemID int, now time.Time) []*api.Action {
+	if trashing.Days <= 0 {
+		return []*api.Action{api.NewDeleteAction(itemID)}
+	}
+	return []*api.Action{
+		api.NewTagsAddAction(itemID, trashTagPrefix+now.Format("2006-01-02")),
+		api.NewArchiveAction(itemID),
+	}
+}
+
+// trashedOn returns the day an item was moved to the trash, and its trash
+// tag.
+func trashedOn(item api.Item, loc *time.Location) (on time.Time, tag string, ok bool) {
+	for name := range item.Tags {
+		if !strings.HasPrefix(name, trashTagPrefix) {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", strings.TrimPrefix(name, trashTagPrefix), loc)
+		if err == nil {
+			return t, name, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// untrashed returns the items not in the trash, which only tells them apart
+// if they were retrieved with their tags.
+func untrashed(items []api.Item) []api.Item {
+	kept := make([]api.Item, 0, len(items))
+	for _, item := range items {
+		if _, _, ok := trashedOn(item, time.Local); !ok {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// trashedItem is an item in the trash, with the day it was moved there.
+type trashedItem struct {
+	api.Item
+	On  time.Time
+	Tag string
+}
+
+// trashedItems returns the items in items that are in the trash, those
+// moved there first first.
+func trashedItems(items []api.Item, loc *time.Location) []trashedItem {
+	trashed := []trashedItem{}
+	for _, item := range items {
+		if on, tag, ok := trashedOn(item, loc); ok {
+			trashed = append(trashed, trashedItem{item, on, tag})
+		}
+	}
+	sort.SliceStable(trashed, func(i, j int) bool { return trashed[i].On.Before(trashed[j].On) })
+	return trashed
+}
+
+// dueForDeletion returns the items that have been in the trash for days by
+// now; all of them if days is not positive.
+func dueForDeletion(trashed []trashedItem, days int, now time.Time) []api.Item {
+	due := []api.Item{}
+	for _, t := range trashed {
+		if days <= 0 || !t.On.AddDate(0, 0, days).After(now) {
+			due = append(due, t.Item)
+		}
+	}
+	return due
+}
+
+// untrashActions returns the actions taking an item out of the trash,
+// moving it back to the unread list.
+func untrashActions(item trashedItem) []*api.Action {
+	return []*api.Action{
+		api.NewReaddAction(item.ItemID),
+		api.NewTagsRemoveAction(item.ItemID, item.Tag),
+	}
+}
+
+func commandTrash(conf Config, client *api.Client) {
+	items, err := retrieveItems(client, &api.RetrieveOption{State: api.StateAll, DetailType: api.DetailTypeComplete})
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	trashed := trashedItems(items, now.Location())
+
+	switch {
+	case conf.Empty:
+		due := dueForDeletion(trashed, trashing.Days, now)
+		if len(due) == 0 {
+			fmt.Fprintln(os.Stderr, "No items are due to leave the trash")
+			return
+		}
+		previewItems(due, "trashed")
+		prompt := fmt.Sprintf("Delete the %d items in the trash?", len(due))
+		if trashing.Days > 0 {
+			prompt = fmt.Sprintf("Delete the %d items in the trash for %d days or more?", len(due), trashing.Days)
+		}
+		if !confirmOperation(conf, confirmDelete, len(due), prompt) {
+			os.Exit(1)
+		}
+		ids := make([]int, len(due))
+		for i, item := range due {
+			ids[i] = item.ItemID
+		}
+		summary := newBulkSummary("trash empty")
+		summary.describe(due)
+		modifyItems(client, ids, api.NewDeleteAction, summary)
+		summary.report(conf.Summary)
+
+	case conf.Restore:
+		ids, err := readItemIDs(conf.ItemIDs, os.Stdin)
+		if err != nil {
+			exitWithError(conf, &usageError{command: "trash", err: err})
+		}
+		byID := map[int]trashedItem{}
+		for _, t := range trashed {
+			byID[t.ItemID] = t
+		}
+		actions := []*api.Action{}
+		for _, id := range ids {
+			t, ok := byID[id]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Item %d is not in the trash\n", id)
+				os.Exit(1)
+			}
+			actions = append(actions, untrashActions(t)...)
+		}
+		_, queued, err := modifyOrQueue(client, actions...)
+		if err != nil {
+			exitWithError(conf, err)
+		}
+		fmt.Printf("Restored %d items to the unread list\n", len(ids))
+		if queued {
+			fmt.Println("Pocket is unreachable; the changes are queued until the next sync")
+		}
+
+	default:
+		if len(trashed) == 0 {
+			fmt.Println("The trash is empty")
+			return
+		}
+		for _, t := range trashed {
+			fmt.Printf("[%9d] (%s) %s\n            <%s>\n", t.ItemID, t.On.Format("2006-01-02"), hyperlink(t.URL(), t.Title()), t.URL())
+		}
+		if trashing.Days > 0 {
+			fmt.Printf("\"pocket trash empty\" deletes those in the trash for %d days or more\n", trashing.Days)
+		}
+	}
+}
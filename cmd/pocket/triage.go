@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+)
+
+// triageBatchSize is the number of decisions whose actions are sent
+// together. The latest ones are held back, as only decisions not yet sent can
+// be undone.
+const triageBatchSize = 20
+
+// triagePositions maps a key of the filters used to the last item triaged
+// with them, so that a session resumes where the previous one stopped.
+type triagePositions map[string]int
+
+func triagePositionsPath() string {
+	return filepath.Join(configDir, "triage.json")
+}
+
+func triageKey(conf Config) string {
+	return strings.Join([]string{conf.Domain, conf.Tag, conf.SearchQuery}, "\x00")
+}
+
+// triageStep is a decision made on an item.
+type triageStep struct {
+	index   int
+	actions []*api.Action
+	// advance is whether the decision moved on to the next item.
+	advance bool
+	label   string
+}
+
+// triage is the state of a triage session.
+type triage struct {
+	client *api.Client
+	items  []api.Item
+	index  int
+
+	steps []triageStep
+	// sent is the number of steps whose actions have been sent.
+	sent int
+}
+
+func (t *triage) decide(step triageStep) {
+	step.index = t.index
+	t.steps = append(t.steps, step)
+	if step.advance {
+		t.index++
+	}
+}
+
+// undo takes back the last decision not sent yet, returning to its item.
+func (t *triage) undo() (string, bool) {
+	if len(t.steps) == t.sent {
+		return "", false
+	}
+	step := t.steps[len(t.steps)-1]
+	t.steps = t.steps[:len(t.steps)-1]
+	t.index = step.index
+	return step.label, true
+}
+
+// flush sends the actions of the decisions made so far, keeping the latest
+// keep decisions back so that they can still be undone.
+func (t *triage) flush(keep int) error {
+	end := len(t.steps) - keep
+	if end <= t.sent {
+		return nil
+	}
+
+	actions := []*api.Action{}
+	for _, step := range t.steps[t.sent:end] {
+		actions = append(actions, step.actions...)
+	}
+	if len(actions) > 0 {
+		_, queued, err := modifyOrQueue(t.client, actions...)
+		if err != nil {
+			return err
+		}
+		if queued {
+			fmt.Println("(Pocket is unreachable; the changes are queued until the next sync)")
+		}
+	}
+	t.sent = end
+	return nil
+}
+
+// readKey reads a single key press in raw mode.
+func readKey() (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+
+	buf := make([]byte, 16)
+	n, err := os.Stdin.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func readLine(reader *bufio.Reader, prompt string) string {
+	fmt.Print(prompt)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return strings.TrimSpace(line)
+}
+
+// triageChoices are the keys of triage, numbered for screen readers.
+var triageChoices = []struct{ key, name string }{
+	{"o", "open"},
+	{"a", "archive"},
+	{"d", "delete"},
+	{"f", "favorite"},
+	{"t", "tag"},
+	{"s", "snooze"},
+	{"n", "next"},
+	{"u", "undo"},
+	{"q", "quit"},
+}
+
+func printTriageChoices() {
+	fmt.Println("Choices:")
+	for i, choice := range triageChoices {
+		fmt.Printf("%d. %s (%s)\n", i+1, choice.name, choice.key)
+	}
+}
+
+// readTriageChoice reads a choice as a line, for screen readers: its
+// number, its key, or nothing for the next item.
+func readTriageChoice(reader *bufio.Reader) string {
+	answer := strings.ToLower(readLine(reader, "Choice, by number or letter, or ? to list them: "))
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(triageChoices) {
+		return triageChoices[n-1].key
+	}
+	if answer == "" {
+		return "n"
+	}
+	return answer
+}
+
+func printTriageItem(item api.Item, n, total int) {
+	if screenReader {
+		fmt.Printf("\nItem %d of %d: %s\n", n, total, item.Title())
+	} else {
+		fmt.Printf("\n[%d/%d] %s\n", n, total, item.Title())
+	}
+	fmt.Printf("  %s\n", item.URL())
+
+	details := []string{"added " + formatTime(item.TimeAdded.Time, "2006-01-02")}
+	if minutes := item.ReadingMinutes(); minutes > 0 {
+		details = append(details, fmt.Sprintf("%d min", minutes))
+	}
+	if tags := item.TagNames(); len(tags) > 0 {
+		details = append(details, "tags: "+strings.Join(tags, ", "))
+	}
+	if item.Favorite == 1 {
+		details = append(details, "favorite")
+	}
+	separator := " · "
+	if screenReader {
+		separator = ", "
+	}
+	fmt.Printf("  %s\n", strings.Join(details, separator))
+
+	if item.Excerpt != "" {
+		excerpt := []rune(item.Excerpt)
+		if len(excerpt) > 200 {
+			excerpt = append(excerpt[:200], '…')
+		}
+		fmt.Printf("  %s\n", string(excerpt))
+	}
+}
+
+func commandTriage(conf Config, client *api.Client) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "pocket triage needs a terminal")
+		os.Exit(1)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateUnread,
+		DetailType: api.DetailTypeComplete,
+		Domain:     conf.Domain,
+		Tag:        conf.Tag,
+		Search:     conf.SearchQuery,
+	})
+	if err != nil {
+		panic(err)
+	}
+	sort.Sort(library.BySortID(items))
+
+	positions := triagePositions{}
+	_ = loadJSONFromFile(triagePositionsPath(), &positions)
+	key := triageKey(conf)
+
+	t := &triage{client: client, items: items}
+	if last, ok := positions[key]; ok {
+		for i, item := range items {
+			if item.ItemID == last {
+				t.index = i + 1
+				fmt.Printf("Resuming after %q\n", item.Title())
+			}
+		}
+	}
+	if t.index >= len(items) {
+		t.index = 0
+	}
+
+	// finish sends the decisions still pending and saves where the session
+	// stopped, also when interrupted.
+	finish := func() {
+		if err := t.flush(0); err != nil {
+			panic(err)
+		}
+
+		if t.index >= len(items) {
+			fmt.Println("\nAll items triaged.")
+			delete(positions, key)
+		} else if t.index > 0 {
+			positions[key] = items[t.index-1].ItemID
+		}
+		err := saveJSONToFile(triagePositionsPath(), positions)
+		if err != nil {
+			panic(err)
+		}
+	}
+	onShutdown(finish)
+
+	reader := stdin
+	help := "[o]pen [a]rchive [d]elete [f]avorite [t]ag [s]nooze [n]ext [u]ndo [q]uit"
+	if screenReader {
+		printTriageChoices()
+	}
+
+	shown := -1
+loop:
+	for t.index < len(items) {
+		item := items[t.index]
+		if shown != t.index {
+			printTriageItem(item, t.index+1, len(items))
+			shown = t.index
+		}
+
+		var key string
+		if screenReader {
+			key = readTriageChoice(reader)
+		} else {
+			fmt.Printf("%s: ", help)
+			key, err = readKey()
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(strings.TrimSpace(key))
+		}
+
+		switch key {
+		case "o":
+			if err := openItem(item); err != nil {
+				fmt.Println(err)
+			}
+		case "a":
+			t.decide(triageStep{actions: []*api.Action{api.NewArchiveAction(item.ItemID)}, advance: true, label: "archive"})
+		case "d":
+			t.decide(triageStep{actions: []*api.Action{api.NewDeleteAction(item.ItemID)}, advance: true, label: "delete"})
+		case "f":
+			action := api.NewFavoriteAction(item.ItemID)
+			if item.Favorite == 1 {
+				action = api.NewUnfavoriteAction(item.ItemID)
+			}
+			t.decide(triageStep{actions: []*api.Action{action}, label: "favorite"})
+			fmt.Println("Favorite toggled")
+		case "t":
+			tags := splitTags(readLine(reader, "Tags (comma-separated): "))
+			if len(tags) > 0 {
+				t.decide(triageStep{actions: []*api.Action{api.NewTagsAddAction(item.ItemID, tags...)}, label: "tag"})
+				fmt.Printf("Tagged with %s\n", strings.Join(tags, ", "))
+			}
+		case "s":
+			days, err := strconv.Atoi(readLine(reader, "Snooze for how many days? [7]: "))
+			if err != nil || days <= 0 {
+				days = 7
+			}
+			until := time.Now().AddDate(0, 0, days)
+			t.decide(triageStep{actions: snoozeActions(item.ItemID, until), advance: true, label: "snooze"})
+			fmt.Printf("Snoozed until %s\n", until.Format("2006-01-02"))
+		case "n", " ", "\r":
+			t.decide(triageStep{advance: true, label: "skip"})
+		case "u":
+			if label, ok := t.undo(); ok {
+				fmt.Printf("Undid %s\n", label)
+				shown = -1
+			} else {
+				fmt.Println("Nothing to undo")
+			}
+		case "q", keyCtrlC, keyEscape:
+			break loop
+		case "?":
+			if screenReader {
+				printTriageChoices()
+			}
+		default:
+			if screenReader {
+				fmt.Println("Unknown choice; type ? to list them")
+			} else {
+				fmt.Println("Unknown key")
+			}
+		}
+
+		if len(t.steps)-t.sent >= 2*triageBatchSize {
+			if err := t.flush(triageBatchSize); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	finish()
+}
@@ -0,0 +1,699 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+	"github.com/motemen/go-pocket/mirror"
+)
+
+// ANSI escape sequences used by the TUI
+const (
+	escAltScreen  = "\x1b[?1049h"
+	escMainScreen = "\x1b[?1049l"
+	escHideCursor = "\x1b[?25l"
+	escShowCursor = "\x1b[?25h"
+	escHome       = "\x1b[H"
+	escClearLine  = "\x1b[K"
+	escReverse    = "\x1b[7m"
+	escBold       = "\x1b[1m"
+	escDim        = "\x1b[2m"
+	escReset      = "\x1b[0m"
+)
+
+// Keys as read from the terminal in raw mode
+const (
+	keyUp       = "\x1b[A"
+	keyDown     = "\x1b[B"
+	keyPageUp   = "\x1b[5~"
+	keyPageDown = "\x1b[6~"
+	keyEscape   = "\x1b"
+	keyEnter    = "\r"
+	keyTab      = "\t"
+	keyCtrlC    = "\x03"
+	keyBack     = "\x7f"
+)
+
+// sidebarWidth is the width of the tag sidebar, including its border.
+const sidebarWidth = 20
+
+// allTags is the sidebar entry that shows items with any tags.
+const allTags = "(all)"
+
+type tuiMode int
+
+const (
+	modeNormal tuiMode = iota
+	modeSearch
+	modeTag
+	modeNote
+	modeConfirmDelete
+)
+
+type tuiFocus int
+
+const (
+	focusList tuiFocus = iota
+	focusTags
+)
+
+// tui is the state of the interactive terminal UI.
+type tui struct {
+	client *api.Client
+	cache  *mirror.ArticleCache
+
+	all   []api.Item
+	items []api.Item
+	tags  []string
+	// known are the tags of the tag cache, shown in the sidebar even if
+	// none of the items loaded has them, and counts those of the items.
+	known  []string
+	counts map[string]int
+
+	tag    int
+	cursor int
+	offset int
+	focus  tuiFocus
+
+	mode   tuiMode
+	query  string
+	input  string
+	status string
+
+	// articles holds the article text fetched for the preview pane.
+	articles map[int]string
+	// notes are the notes of items, kept locally.
+	notes map[int]string
+	// queue is the reading queue, shown in its order under _queue_.
+	queue []int
+
+	width, height int
+}
+
+func newTUI(client *api.Client, items []api.Item) *tui {
+	t := &tui{client: client, all: items, articles: map[int]string{}}
+	t.cache, _ = openArticleCache()
+	t.notes, _ = loadNotes()
+	if t.notes == nil {
+		t.notes = map[int]string{}
+	}
+	t.queue, _ = loadReadingQueue()
+	t.known = cachedTags()
+	t.refresh()
+	return t
+}
+
+// refresh recomputes the tag list and the items matching the filters.
+func (t *tui) refresh() {
+	t.counts = map[string]int{}
+	for _, item := range t.all {
+		for tag := range item.Tags {
+			t.counts[tag]++
+		}
+	}
+	current := ""
+	if t.tag < len(t.tags) {
+		current = t.tags[t.tag]
+	}
+	t.tags = []string{allTags, "_untagged_", "_queue_"}
+	names := make([]string, 0, len(t.counts))
+	for tag := range t.counts {
+		names = append(names, tag)
+	}
+	for _, tag := range t.known {
+		if t.counts[tag] == 0 {
+			names = append(names, tag)
+		}
+	}
+	sort.Strings(names)
+	t.tags = append(t.tags, names...)
+	t.tag = 0
+	for i, tag := range t.tags {
+		if tag == current {
+			t.tag = i
+		}
+	}
+
+	position := map[int]int{}
+	for i, id := range t.queue {
+		position[id] = i + 1
+	}
+	query := strings.ToLower(t.query)
+	t.items = []api.Item{}
+	for _, item := range t.all {
+		switch tag := t.tags[t.tag]; tag {
+		case allTags:
+		case "_untagged_":
+			if len(item.Tags) > 0 {
+				continue
+			}
+		case "_queue_":
+			if position[item.ItemID] == 0 {
+				continue
+			}
+		default:
+			if _, ok := item.Tags[tag]; !ok {
+				continue
+			}
+		}
+		if query != "" && !strings.Contains(strings.ToLower(item.Title()+" "+item.URL()+" "+strings.Join(item.TagNames(), " ")), query) {
+			continue
+		}
+		t.items = append(t.items, item)
+	}
+	if t.tags[t.tag] == "_queue_" {
+		sort.SliceStable(t.items, func(i, j int) bool { return position[t.items[i].ItemID] < position[t.items[j].ItemID] })
+	}
+
+	if t.cursor >= len(t.items) {
+		t.cursor = len(t.items) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// toggleQueued puts the selected item at the end of the reading queue, or
+// takes it off.
+func (t *tui) toggleQueued() {
+	item := t.selected()
+	if item == nil {
+		return
+	}
+	queue, removed := removeFromCollection(t.queue, []int{item.ItemID})
+	if removed == 0 {
+		queue = append(queue, item.ItemID)
+	}
+	if err := saveReadingQueue(queue); err != nil {
+		t.status = "Error: " + err.Error()
+		return
+	}
+	t.queue = queue
+	if removed == 0 {
+		t.status = fmt.Sprintf("Queued at %d", len(queue))
+	} else {
+		t.status = "Taken off the queue"
+	}
+	t.refresh()
+}
+
+func (t *tui) selected() *api.Item {
+	if t.cursor < len(t.items) {
+		return &t.items[t.cursor]
+	}
+	return nil
+}
+
+// listHeight is the number of rows available to the item list.
+func (t *tui) listHeight() int {
+	if h := t.height - 2; h > 1 {
+		return h
+	}
+	return 1
+}
+
+func (t *tui) move(delta int) {
+	if t.focus == focusTags {
+		t.tag += delta
+		if t.tag < 0 {
+			t.tag = 0
+		}
+		if t.tag >= len(t.tags) {
+			t.tag = len(t.tags) - 1
+		}
+		t.cursor, t.offset = 0, 0
+		t.refresh()
+		return
+	}
+
+	t.cursor += delta
+	if t.cursor >= len(t.items) {
+		t.cursor = len(t.items) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// replace updates an item in both the full and the filtered list, or removes
+// it if item is nil.
+func (t *tui) replace(itemID int, item *api.Item) {
+	update := func(items []api.Item) []api.Item {
+		result := items[:0]
+		for _, it := range items {
+			if it.ItemID != itemID {
+				result = append(result, it)
+			} else if item != nil {
+				result = append(result, *item)
+			}
+		}
+		return result
+	}
+	t.all = update(t.all)
+	t.refresh()
+}
+
+// act sends actions for the selected item, applying them to the list when
+// they succeed.
+func (t *tui) act(done string, actions ...*api.Action) {
+	item := t.selected()
+	if item == nil {
+		return
+	}
+
+	_, queued, err := modifyOrQueue(t.client, actions...)
+	if err != nil {
+		t.status = "Error: " + err.Error()
+		return
+	}
+
+	updated := *item
+	applied := false
+	for _, action := range actions {
+		applied = mirror.ApplyAction(&updated, action) || applied
+	}
+	if applied && updated.Status == api.ItemStatusUnread {
+		t.replace(item.ItemID, &updated)
+	} else {
+		t.replace(item.ItemID, nil)
+	}
+
+	t.status = done
+	if queued {
+		t.status += " (queued until the next sync)"
+	}
+}
+
+// saveNote sets the note of the selected item, removing it if note is empty.
+func (t *tui) saveNote(note string) {
+	item := t.selected()
+	if item == nil {
+		return
+	}
+	if err := setNote(item.ItemID, note); err != nil {
+		t.status = "Error: " + err.Error()
+		return
+	}
+	if note == "" {
+		delete(t.notes, item.ItemID)
+		t.status = "Note removed"
+	} else {
+		t.notes[item.ItemID] = note
+		t.status = "Noted"
+	}
+}
+
+// loadArticle fetches the article text of the selected item for the preview.
+func (t *tui) loadArticle() {
+	item := t.selected()
+	if item == nil {
+		return
+	}
+
+	article, err := t.client.Article(item.URL())
+	if err != nil {
+		t.status = "Error: " + err.Error()
+		return
+	}
+	if t.cache != nil {
+		t.cache.Put(item.ItemID, article)
+	}
+	t.articles[item.ItemID] = articleText(article.HTML)
+}
+
+// previewText returns the text shown for item in the preview pane.
+func (t *tui) previewText(item api.Item) string {
+	if text, ok := t.articles[item.ItemID]; ok {
+		return text
+	}
+	if t.cache != nil {
+		if article, err := t.cache.Peek(item.ItemID); err == nil {
+			text := articleText(article.HTML)
+			t.articles[item.ItemID] = text
+			return text
+		}
+	}
+	text := item.Excerpt
+	if text == "" {
+		text = "(no excerpt)"
+	}
+	return text + "\n\nPress Enter to load the article text."
+}
+
+// handleKey processes a key press, returning false to quit.
+func (t *tui) handleKey(key string) bool {
+	switch t.mode {
+	case modeSearch, modeTag, modeNote:
+		switch key {
+		case keyEscape, keyCtrlC:
+			if t.mode == modeSearch {
+				t.query = ""
+				t.refresh()
+			}
+			t.mode = modeNormal
+		case keyEnter:
+			if t.mode == modeTag {
+				tags := splitTags(t.input)
+				if item := t.selected(); item != nil && len(tags) > 0 {
+					t.act("Tagged", api.NewTagsAddAction(item.ItemID, tags...))
+				}
+			}
+			if t.mode == modeNote {
+				t.saveNote(strings.TrimSpace(t.input))
+			}
+			t.mode = modeNormal
+		case keyBack:
+			if _, size := utf8.DecodeLastRuneInString(t.input); size > 0 {
+				t.input = t.input[:len(t.input)-size]
+			}
+		default:
+			if utf8.ValidString(key) && !strings.HasPrefix(key, "\x1b") && key >= " " {
+				t.input += key
+			}
+		}
+		if t.mode == modeSearch {
+			t.query = t.input
+			t.cursor, t.offset = 0, 0
+			t.refresh()
+		}
+		return true
+
+	case modeConfirmDelete:
+		t.mode = modeNormal
+		if key == "y" {
+			if item := t.selected(); item != nil {
+				t.act("Deleted", api.NewDeleteAction(item.ItemID))
+			}
+		} else {
+			t.status = ""
+		}
+		return true
+	}
+
+	t.status = ""
+	switch key {
+	case "q", keyCtrlC:
+		return false
+	case "j", keyDown:
+		t.move(1)
+	case "k", keyUp:
+		t.move(-1)
+	case keyPageDown, " ":
+		t.move(t.listHeight())
+	case keyPageUp:
+		t.move(-t.listHeight())
+	case "g":
+		t.move(-len(t.items) - len(t.tags))
+	case "G":
+		t.move(len(t.items) + len(t.tags))
+	case keyTab:
+		if t.focus == focusList {
+			t.focus = focusTags
+		} else {
+			t.focus = focusList
+		}
+	case "/":
+		t.mode = modeSearch
+		t.input = t.query
+	case keyEscape:
+		t.query = ""
+		t.refresh()
+	case keyEnter:
+		t.loadArticle()
+	case "o":
+		if item := t.selected(); item != nil {
+			if err := openItem(*item); err != nil {
+				t.status = "Error: " + err.Error()
+			}
+		}
+	case "a":
+		if item := t.selected(); item != nil {
+			t.act("Archived", api.NewArchiveAction(item.ItemID))
+		}
+	case "d":
+		if t.selected() != nil {
+			t.mode = modeConfirmDelete
+		}
+	case "f":
+		if item := t.selected(); item != nil {
+			if item.Favorite == 1 {
+				t.act("Unfavorited", api.NewUnfavoriteAction(item.ItemID))
+			} else {
+				t.act("Favorited", api.NewFavoriteAction(item.ItemID))
+			}
+		}
+	case "t":
+		if t.selected() != nil {
+			t.mode = modeTag
+			t.input = ""
+		}
+	case "p":
+		t.toggleQueued()
+	case "n":
+		if item := t.selected(); item != nil {
+			t.mode = modeNote
+			t.input = t.notes[item.ItemID]
+		}
+	case "0", "1", "2", "3", "4", "5":
+		if item := t.selected(); item != nil {
+			priority := int(key[0] - '0')
+			done := fmt.Sprintf("Priority %d", priority)
+			if priority == 0 {
+				done = "Priority removed"
+			}
+			t.act(done, library.PriorityActions(item.ItemID, priority)...)
+		}
+	case "?":
+		t.status = "j/k move  Tab tags  / search  Enter load text  o open  a archive  d delete  f favorite  t tag  n note  p queue  1-5 priority  q quit"
+	}
+	return true
+}
+
+// fit truncates or pads s to exactly width columns.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, s)
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// wrap breaks text into lines of at most width columns.
+func wrap(text string, width int) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// render draws the whole screen as lines.
+func (t *tui) render() []string {
+	listHeight := t.listHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+listHeight {
+		t.offset = t.cursor - listHeight + 1
+	}
+
+	mainWidth := t.width - sidebarWidth
+	listWidth := mainWidth
+	previewWidth := 0
+	if t.width >= 80 {
+		listWidth = mainWidth * 45 / 100
+		previewWidth = mainWidth - listWidth - 1
+	}
+
+	header := fmt.Sprintf(" Pocket: %d of %d items", len(t.items), len(t.all))
+	if t.query != "" {
+		header += fmt.Sprintf("  search: %q", t.query)
+	}
+	lines := []string{escBold + fit(header, t.width) + escReset}
+
+	var preview []string
+	if item := t.selected(); item != nil && previewWidth > 0 {
+		preview = append(preview, escBold+fit(item.Title(), previewWidth)+escReset)
+		preview = append(preview, escDim+fit(item.URL(), previewWidth)+escReset)
+		if tags := item.TagNames(); len(tags) > 0 {
+			preview = append(preview, escDim+fit("tags: "+strings.Join(tags, ", "), previewWidth)+escReset)
+		}
+		if note := t.notes[item.ItemID]; note != "" {
+			for _, line := range wrap("note: "+note, previewWidth) {
+				preview = append(preview, escBold+fit(line, previewWidth)+escReset)
+			}
+		}
+		preview = append(preview, "")
+		for _, line := range wrap(t.previewText(*item), previewWidth) {
+			preview = append(preview, fit(line, previewWidth))
+		}
+	}
+
+	for row := 0; row < listHeight; row++ {
+		var b strings.Builder
+
+		tag := ""
+		if row < len(t.tags) {
+			tag = t.tags[row]
+		}
+		cell := fit(" "+tag, sidebarWidth-1)
+		if row == t.tag && row < len(t.tags) {
+			if t.focus == focusTags {
+				cell = escReverse + cell + escReset
+			} else {
+				cell = escBold + cell + escReset
+			}
+		} else if row > 2 && row < len(t.tags) && t.counts[tag] == 0 {
+			// Only in the tag cache
+			cell = escDim + cell + escReset
+		}
+		b.WriteString(cell + escDim + "│" + escReset)
+
+		i := t.offset + row
+		cell = fit("", listWidth)
+		if i < len(t.items) {
+			item := t.items[i]
+			star := " "
+			if item.Favorite == 1 {
+				star = "*"
+			}
+			priority := "  "
+			if p := library.Priority(item); p != 0 {
+				priority = strconv.Itoa(p) + " "
+			}
+			cell = fit(star+priority+item.Title()+"  "+item.Domain(), listWidth)
+			if i == t.cursor {
+				cell = escReverse + cell + escReset
+			}
+		}
+		b.WriteString(cell)
+
+		if previewWidth > 0 {
+			b.WriteString(escDim + "│" + escReset)
+			if row < len(preview) {
+				b.WriteString(preview[row])
+			}
+		}
+
+		lines = append(lines, b.String())
+	}
+
+	footer := t.status
+	switch t.mode {
+	case modeSearch:
+		footer = "/" + t.input
+	case modeTag:
+		footer = "Add tags (comma-separated): " + t.input
+	case modeNote:
+		footer = "Note (empty to remove): " + t.input
+	case modeConfirmDelete:
+		footer = "Delete this item? (y/n)"
+	case modeNormal:
+		if footer == "" {
+			footer = "? for help"
+		}
+	}
+	lines = append(lines, fit(footer, t.width))
+
+	return lines
+}
+
+func (t *tui) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err == nil {
+		t.width, t.height = width, height
+	}
+
+	var b strings.Builder
+	b.WriteString(escHome)
+	for i, line := range t.render() {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line + escClearLine)
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+// run takes over the terminal until the user quits.
+func (t *tui) run() error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	os.Stdout.WriteString(escAltScreen + escHideCursor)
+	defer os.Stdout.WriteString(escShowCursor + escMainScreen)
+
+	buf := make([]byte, 64)
+	for {
+		t.draw()
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		if !t.handleKey(string(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+func commandTUI(conf Config, client *api.Client) {
+	if screenReader {
+		fmt.Fprintln(os.Stderr, tr(msgNoFullScreen, map[string]string{"Command": "tui"}))
+		os.Exit(1)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintln(os.Stderr, "pocket tui needs a terminal")
+		os.Exit(1)
+	}
+
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:      api.StateUnread,
+		DetailType: api.DetailTypeComplete,
+		Domain:     conf.Domain,
+		Tag:        conf.Tag,
+		Search:     conf.SearchQuery,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = newTUI(client, items).run()
+	if err != nil {
+		panic(err)
+	}
+}
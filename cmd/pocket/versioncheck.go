@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metadataURL is the project metadata, listing the latest release and the
+// notices for the releases broken by changes to the Pocket API.
+var metadataURL = "https://raw.githubusercontent.com/motemen/go-pocket/master/metadata.json"
+
+// versionCheckInterval is how long the metadata is cached for.
+const versionCheckInterval = 24 * time.Hour
+
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// projectMetadata is the content of metadata.json.
+type projectMetadata struct {
+	Latest  string          `json:"latest"`
+	Notices []versionNotice `json:"notices"`
+}
+
+// versionNotice is a warning for the releases older than Before, or all of
+// them if it is empty.
+type versionNotice struct {
+	Before  string `json:"before"`
+	Message string `json:"message"`
+}
+
+// cachedMetadata is the metadata last fetched, kept in version_check.json in
+// the config directory. Checked is updated on failures too, so that pocket
+// does not wait on an unreachable endpoint every time it is run.
+type cachedMetadata struct {
+	Checked  time.Time       `json:"checked"`
+	Metadata projectMetadata `json:"metadata"`
+}
+
+func metadataCachePath() string {
+	return filepath.Join(configDir, "version_check.json")
+}
+
+// versionCheckDisabled tells if the user opted out of the check, with
+// "version_check": false in config.json or POCKET_NO_VERSION_CHECK.
+func versionCheckDisabled(settings *Settings) bool {
+	if os.Getenv("POCKET_NO_VERSION_CHECK") != "" {
+		return true
+	}
+	return settings.VersionCheck != nil && !*settings.VersionCheck
+}
+
+// fetchMetadata fetches the project metadata, sending nothing but the
+// version and OS of pocket in the User-Agent, and caches it.
+func fetchMetadata() (projectMetadata, error) {
+	cache := cachedMetadata{}
+	loadJSONFromFile(metadataCachePath(), &cache)
+	cache.Checked = time.Now()
+
+	metadata, err := func() (projectMetadata, error) {
+		metadata := projectMetadata{}
+		req, err := http.NewRequest("GET", metadataURL, nil)
+		if err != nil {
+			return metadata, err
+		}
+		req.Header.Set("User-Agent", fmt.Sprintf("go-pocket/%s (%s)", version, runtime.GOOS))
+		resp, err := metadataClient.Do(req)
+		if err != nil {
+			return metadata, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return metadata, fmt.Errorf("fetching %s: %s", metadataURL, resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&metadata)
+		return metadata, err
+	}()
+	if err == nil {
+		cache.Metadata = metadata
+	}
+	saveJSONToFile(metadataCachePath(), cache)
+	return metadata, err
+}
+
+// cachedNotices returns the notices for this version, fetching the metadata
+// again if the cache is older than versionCheckInterval. Failures to fetch it
+// are left to "pocket doctor".
+func cachedNotices() []string {
+	cache := cachedMetadata{}
+	err := loadJSONFromFile(metadataCachePath(), &cache)
+	if err != nil || time.Since(cache.Checked) > versionCheckInterval {
+		if metadata, err := fetchMetadata(); err == nil {
+			cache.Metadata = metadata
+		}
+	}
+	return cache.Metadata.noticesFor(version)
+}
+
+// warnOfNotices prints the notices for this version on startup.
+func warnOfNotices(settings *Settings) {
+	if versionCheckDisabled(settings) {
+		return
+	}
+	for _, notice := range cachedNotices() {
+		fmt.Fprintf(os.Stderr, "pocket: %s\n", notice)
+	}
+}
+
+// noticesFor returns the messages of the notices applying to version v.
+func (m projectMetadata) noticesFor(v string) []string {
+	messages := []string{}
+	for _, notice := range m.Notices {
+		if notice.Before == "" || compareVersions(v, notice.Before) < 0 {
+			messages = append(messages, notice.Message)
+		}
+	}
+	return messages
+}
+
+// compareVersions compares dotted versions such as "0.1" and "v0.10.2"
+// number by number, missing numbers counting as 0.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
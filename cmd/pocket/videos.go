@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// defaultPlayers are the players tried when none is set, with the options
+// making them quit after playing.
+var defaultPlayers = []string{"mpv", "vlc --play-and-exit"}
+
+// videoPlayer returns the command line of the player to use: that set in
+// the settings, or the first default player installed.
+func videoPlayer(settings *Settings) ([]string, error) {
+	if settings.Videos.Player != "" {
+		return splitWords(settings.Videos.Player)
+	}
+	for _, player := range defaultPlayers {
+		words := strings.Fields(player)
+		if _, err := exec.LookPath(words[0]); err == nil {
+			return words, nil
+		}
+	}
+	return nil, errors.New(`no video player found; install mpv or VLC, or set videos.player in config.json`)
+}
+
+// playVideo plays url in the player, on the terminal, until it quits.
+func playVideo(player []string, url string) error {
+	cmd := exec.Command(player[0], append(player[1:], url)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func commandVideos(conf Config, client *api.Client) {
+	if !conf.Play && conf.Output != "json" {
+		checkListingOutput(conf.Output)
+	}
+
+	state := api.State(conf.State)
+	if conf.Play {
+		state = api.StateUnread
+	}
+	items, err := retrieveItems(client, &api.RetrieveOption{
+		State:       state,
+		Domain:      conf.Domain,
+		Search:      conf.SearchQuery,
+		Tag:         conf.Tag,
+		ContentType: api.ContentTypeVideo,
+		DetailType:  api.DetailTypeComplete,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if !conf.Play {
+		printListenQueue(conf.Output, items)
+		return
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		exitWithError(conf, err)
+	}
+	player, err := videoPlayer(settings)
+	if err != nil {
+		exitWithError(conf, err)
+	}
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		fields := strings.SplitN(pickLine(item), "\t", 2)
+		lines[i] = fields[0] + "\t[" + formatDuration(item.ListenSeconds()) + "] " + fields[1]
+	}
+	ids := chooseItems("videos play", items, lines)
+
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+	for _, id := range ids {
+		item := byID[id]
+		fmt.Printf("Playing %s <%s>\n", item.Title(), item.URL())
+		if err := playVideo(player, item.URL()); err != nil {
+			// Leave the rest for later once playback is stopped
+			fmt.Fprintf(os.Stderr, "%s stopped: %v\n", player[0], err)
+			os.Exit(1)
+		}
+
+		if conf.ArchiveAfter {
+			_, queued, err := modifyOrQueue(client, api.NewArchiveAction(id))
+			if err != nil {
+				exitWithError(conf, err)
+			}
+			if queued {
+				fmt.Printf("Offline; queued archiving item %d until the next sync\n", id)
+			} else {
+				fmt.Printf("Archived item %d\n", id)
+			}
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package epub builds simple EPUB 3 books out of HTML articles.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FetchFunc downloads the resource at url, returning its content and media type.
+type FetchFunc func(url string) (data []byte, mediaType string, err error)
+
+// Book is an EPUB book under construction.
+type Book struct {
+	Title      string
+	Author     string
+	Language   string
+	Identifier string
+	Modified   time.Time
+
+	chapters []chapter
+	images   []image
+}
+
+type chapter struct {
+	title string
+	href  string
+	body  string
+}
+
+type image struct {
+	id        string
+	href      string
+	mediaType string
+	data      []byte
+}
+
+// NewBook creates an empty book.
+func NewBook(title string) *Book {
+	return &Book{
+		Title:      title,
+		Language:   "en",
+		Identifier: fmt.Sprintf("urn:go-pocket:%d", time.Now().UnixNano()),
+		Modified:   time.Now(),
+	}
+}
+
+// AddChapter adds a chapter whose content is an HTML fragment. The fragment
+// is converted to XHTML. Images are downloaded with fetch and embedded into
+// the book, their URLs resolved against baseURL, the URL of the page the
+// fragment is from; images that cannot be fetched are dropped. If fetch is
+// nil, all images are dropped.
+func (b *Book) AddChapter(title, fragment, baseURL string, fetch FetchFunc) error {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		b.embedImages(n, base, fetch)
+		err := html.Render(&buf, n)
+		if err != nil {
+			return err
+		}
+	}
+
+	b.chapters = append(b.chapters, chapter{
+		title: title,
+		href:  fmt.Sprintf("chapter%04d.xhtml", len(b.chapters)+1),
+		body:  buf.String(),
+	})
+
+	return nil
+}
+
+func (b *Book) embedImages(n *html.Node, base *url.URL, fetch FetchFunc) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.DataAtom == atom.Img {
+			href := b.fetchImage(attr(c, "src"), base, fetch)
+			if href == "" {
+				n.RemoveChild(c)
+			} else {
+				c.Attr = []html.Attribute{{Key: "src", Val: href}, {Key: "alt", Val: attr(c, "alt")}}
+			}
+		} else {
+			b.embedImages(c, base, fetch)
+		}
+		c = next
+	}
+}
+
+func (b *Book) fetchImage(src string, base *url.URL, fetch FetchFunc) string {
+	if src == "" || fetch == nil {
+		return ""
+	}
+	// Relative to the page, as /img/a.png or //cdn.example.com/a.png
+	ref, err := url.Parse(src)
+	if err != nil {
+		return ""
+	}
+	src = base.ResolveReference(ref).String()
+
+	for _, img := range b.images {
+		if img.id == src {
+			return img.href
+		}
+	}
+
+	data, mediaType, err := fetch(src)
+	if err != nil || !strings.HasPrefix(mediaType, "image/") {
+		return ""
+	}
+
+	ext := ".img"
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		ext = exts[0]
+	}
+
+	img := image{
+		id:        src,
+		href:      fmt.Sprintf("images/image%04d%s", len(b.images)+1, ext),
+		mediaType: mediaType,
+		data:      data,
+	}
+	b.images = append(b.images, img)
+
+	return img.href
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// Write writes the book as an EPUB archive.
+func (b *Book) Write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	// The mimetype file must come first and must not be compressed.
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "application/epub+zip")
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		name    string
+		content []byte
+	}
+
+	files := []file{
+		{"META-INF/container.xml", []byte(containerXML)},
+		{"OEBPS/content.opf", b.packageDocument()},
+		{"OEBPS/nav.xhtml", b.navDocument()},
+	}
+	for _, c := range b.chapters {
+		files = append(files, file{path.Join("OEBPS", c.href), chapterDocument(c)})
+	}
+	for _, img := range b.images {
+		files = append(files, file{path.Join("OEBPS", img.href), img.data})
+	}
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(file.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func (b *Book) packageDocument() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">%s</dc:identifier>
+    <dc:title>%s</dc:title>
+    <dc:creator>%s</dc:creator>
+    <dc:language>%s</dc:language>
+    <meta property="dcterms:modified">%s</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+`, escape(b.Identifier), escape(b.Title), escape(b.Author), escape(b.Language), b.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+
+	for i, c := range b.chapters {
+		fmt.Fprintf(&buf, "    <item id=\"chapter%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, c.href)
+	}
+	for i, img := range b.images {
+		fmt.Fprintf(&buf, "    <item id=\"image%d\" href=\"%s\" media-type=\"%s\"/>\n", i+1, img.href, escape(img.mediaType))
+	}
+
+	buf.WriteString("  </manifest>\n  <spine>\n")
+	for i := range b.chapters {
+		fmt.Fprintf(&buf, "    <itemref idref=\"chapter%d\"/>\n", i+1)
+	}
+	buf.WriteString("  </spine>\n</package>\n")
+
+	return buf.Bytes()
+}
+
+func (b *Book) navDocument() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>%s</title></head>
+<body>
+<nav epub:type="toc">
+<h1>Contents</h1>
+<ol>
+`, escape(b.Title))
+
+	for _, c := range b.chapters {
+		fmt.Fprintf(&buf, "<li><a href=\"%s\">%s</a></li>\n", c.href, escape(c.title))
+	}
+	buf.WriteString("</ol>\n</nav>\n</body>\n</html>\n")
+
+	return buf.Bytes()
+}
+
+func chapterDocument(c chapter) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>%s</title></head>
+<body>
+<h1>%s</h1>
+%s
+</body>
+</html>
+`, escape(c.title), escape(c.title), c.body))
+}
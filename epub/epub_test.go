@@ -0,0 +1,83 @@
+package epub_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/motemen/go-pocket/epub"
+	. "github.com/onsi/gomega"
+)
+
+func readZip(data []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	Expect(err).To(BeNil())
+
+	files := map[string]string{}
+	for i, f := range zr.File {
+		if i == 0 {
+			Expect(f.Name).To(Equal("mimetype"))
+			Expect(f.Method).To(Equal(zip.Store))
+		}
+		r, err := f.Open()
+		Expect(err).To(BeNil())
+		b, err := io.ReadAll(r)
+		Expect(err).To(BeNil())
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+func TestBook(t *testing.T) {
+	RegisterTestingT(t)
+
+	fetched := []string{}
+	fetch := func(url string) ([]byte, string, error) {
+		fetched = append(fetched, url)
+		return []byte("PNG"), "image/png", nil
+	}
+
+	book := epub.NewBook("Weekly & more")
+	err := book.AddChapter("First", `<p>Hello<br>world <img src="http://example.com/a.png"></p>`, "http://example.com/first", fetch)
+	Expect(err).To(BeNil())
+	err = book.AddChapter("Second", `<p>No images <img src="http://example.com/b.png"></p>`, "http://example.com/second", nil)
+	Expect(err).To(BeNil())
+
+	var buf bytes.Buffer
+	Expect(book.Write(&buf)).To(Succeed())
+
+	files := readZip(buf.Bytes())
+	Expect(files["mimetype"]).To(Equal("application/epub+zip"))
+	Expect(files["OEBPS/content.opf"]).To(ContainSubstring("<dc:title>Weekly &amp; more</dc:title>"))
+	Expect(files["OEBPS/content.opf"]).To(ContainSubstring(`href="images/image0001.png"`))
+	Expect(files["OEBPS/nav.xhtml"]).To(ContainSubstring(`<a href="chapter0002.xhtml">Second</a>`))
+	Expect(files["OEBPS/chapter0001.xhtml"]).To(ContainSubstring(`<br/>world <img src="images/image0001.png" alt=""/>`))
+	Expect(files["OEBPS/chapter0002.xhtml"]).NotTo(ContainSubstring("<img"))
+	Expect(files["OEBPS/images/image0001.png"]).To(Equal("PNG"))
+	Expect(fetched).To(Equal([]string{"http://example.com/a.png"}))
+}
+
+func TestBookRelativeImages(t *testing.T) {
+	RegisterTestingT(t)
+
+	fetched := []string{}
+	fetch := func(url string) ([]byte, string, error) {
+		fetched = append(fetched, url)
+		return []byte("PNG"), "image/png", nil
+	}
+
+	book := epub.NewBook("Relative")
+	err := book.AddChapter("First", `<p><img src="/img/a.png"><img src="b.png"><img src="//cdn.example.net/c.png"></p>`, "https://example.com/posts/first", fetch)
+	Expect(err).To(BeNil())
+	Expect(fetched).To(Equal([]string{
+		"https://example.com/img/a.png",
+		"https://example.com/posts/b.png",
+		"https://cdn.example.net/c.png",
+	}))
+
+	var buf bytes.Buffer
+	Expect(book.Write(&buf)).To(Succeed())
+	files := readZip(buf.Bytes())
+	Expect(files["OEBPS/chapter0001.xhtml"]).To(ContainSubstring(`<img src="images/image0003.png" alt=""/>`))
+}
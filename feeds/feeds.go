@@ -0,0 +1,328 @@
+// Package feeds reads RSS and Atom feeds, keeping track of the entries
+// already seen.
+package feeds
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// maxSeen bounds the number of entry IDs a feed remembers, beyond the
+// entries in the feed itself.
+const maxSeen = 500
+
+// Entry is an entry of a feed.
+type Entry struct {
+	// ID identifies the entry: its guid or id, or else its link.
+	ID        string
+	URL       string
+	Title     string
+	Published time.Time
+}
+
+type rssFeed struct {
+	Items []rssItem `xml:"channel>item"`
+}
+
+// rdfFeed is an RSS 1.0 feed, whose items follow the channel.
+type rdfFeed struct {
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// timeLayouts are the layouts dates are found in, RFC 822 ones in RSS and
+// RFC 3339 in Atom and Dublin Core.
+var timeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+}
+
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Parse reads the entries of an RSS 2.0, RSS 1.0, or Atom feed, in the order
+// of the feed. Entries without a link are left out.
+func Parse(r io.Reader) ([]Entry, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+	// Feeds in the wild are often sloppy, with HTML entities in particular
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	var root xml.StartElement
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("not a feed: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	entries := []Entry{}
+	switch root.Name.Local {
+	case "rss", "RDF":
+		var items []rssItem
+		if root.Name.Local == "rss" {
+			var feed rssFeed
+			if err := dec.DecodeElement(&feed, &root); err != nil {
+				return nil, err
+			}
+			items = feed.Items
+		} else {
+			var feed rdfFeed
+			if err := dec.DecodeElement(&feed, &root); err != nil {
+				return nil, err
+			}
+			items = feed.Items
+		}
+		for _, item := range items {
+			e := Entry{ID: strings.TrimSpace(item.GUID), URL: strings.TrimSpace(item.Link), Title: strings.TrimSpace(item.Title)}
+			e.Published = parseTime(item.PubDate)
+			if e.Published.IsZero() {
+				e.Published = parseTime(item.Date)
+			}
+			entries = append(entries, e)
+		}
+	case "feed":
+		var feed atomFeed
+		if err := dec.DecodeElement(&feed, &root); err != nil {
+			return nil, err
+		}
+		for _, entry := range feed.Entries {
+			e := Entry{ID: strings.TrimSpace(entry.ID), Title: strings.TrimSpace(entry.Title)}
+			for _, link := range entry.Links {
+				if link.Rel == "" || link.Rel == "alternate" {
+					e.URL = strings.TrimSpace(link.Href)
+					break
+				}
+			}
+			e.Published = parseTime(entry.Published)
+			if e.Published.IsZero() {
+				e.Published = parseTime(entry.Updated)
+			}
+			entries = append(entries, e)
+		}
+	default:
+		return nil, fmt.Errorf("not a feed: unknown root element <%s>", root.Name.Local)
+	}
+
+	result := entries[:0]
+	for _, e := range entries {
+		if e.URL == "" {
+			continue
+		}
+		if e.ID == "" {
+			e.ID = e.URL
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// Feed is a feed polled for new entries, with what is needed to poll it
+// again: the validators of the last response and the entries seen.
+type Feed struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Seen are the IDs of the entries returned by New, oldest first.
+	Seen []string `json:"seen"`
+}
+
+// ErrNotModified is returned by Fetch when the feed has not changed since
+// it was last fetched.
+var ErrNotModified = errors.New("not modified")
+
+// Fetch gets the entries of the feed, with the links resolved against its
+// URL. The request is conditional on the feed having changed since the
+// last fetch, and ErrNotModified is returned if it has not.
+func (f *Feed) Fetch(client *http.Client) ([]Entry, error) {
+	base, err := url.Parse(f.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "go-pocket-feeds")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8, */*;q=0.1")
+	if f.ETag != "" {
+		req.Header.Set("If-None-Match", f.ETag)
+	}
+	if f.LastModified != "" {
+		req.Header.Set("If-Modified-Since", f.LastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("got response %d", resp.StatusCode)
+	}
+
+	entries, err := Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		if u, err := base.Parse(e.URL); err == nil {
+			entries[i].URL = u.String()
+		}
+	}
+
+	f.ETag = resp.Header.Get("ETag")
+	f.LastModified = resp.Header.Get("Last-Modified")
+	return entries, nil
+}
+
+// New returns the entries not seen before, oldest first, and records them
+// as seen. Only the most recent IDs are remembered, but always at least
+// those of entries.
+func (f *Feed) New(entries []Entry) []Entry {
+	seen := map[string]bool{}
+	for _, id := range f.Seen {
+		seen[id] = true
+	}
+
+	// Feeds list their newest entries first, as a rule
+	fresh := []Entry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		fresh = append(fresh, e)
+		f.Seen = append(f.Seen, e.ID)
+	}
+
+	if limit := max(maxSeen, 2*len(entries)); len(f.Seen) > limit {
+		f.Seen = append([]string{}, f.Seen[len(f.Seen)-limit:]...)
+	}
+	return fresh
+}
+
+// Link is a feed linked from a web page.
+type Link struct {
+	URL   string
+	Title string
+	// Type is the media type of the feed, as in "application/rss+xml".
+	Type string
+}
+
+// feedTypes are the media types of the feeds found by Discover.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// Discover finds the feeds a web page links to with <link rel="alternate">,
+// as feed readers do, with their URLs resolved against that of the page.
+func Discover(client *http.Client, pageURL string) ([]Link, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "go-pocket-feeds")
+	req.Header.Set("Accept", "text/html, application/xhtml+xml;q=0.9, */*;q=0.1")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("got response %d", resp.StatusCode)
+	}
+
+	// The links are in the head, within the first megabyte
+	doc, err := html.Parse(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	base := resp.Request.URL
+
+	links := []Link{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "body" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			attrs := map[string]string{}
+			for _, a := range n.Attr {
+				attrs[strings.ToLower(a.Key)] = strings.TrimSpace(a.Val)
+			}
+			rels := strings.Fields(strings.ToLower(attrs["rel"]))
+			typ := strings.ToLower(attrs["type"])
+			if len(rels) == 1 && rels[0] == "alternate" && feedTypes[typ] && attrs["href"] != "" {
+				if u, err := base.Parse(attrs["href"]); err == nil {
+					links = append(links, Link{URL: u.String(), Title: attrs["title"], Type: typ})
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return links, nil
+}
@@ -0,0 +1,143 @@
+package feeds_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/feeds"
+	. "github.com/onsi/gomega"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0"><channel><title>Blog</title>
+<item><title>Second &amp; caf` + "\xe9" + `</title><link>https://example.com/2</link><guid>urn:2</guid><pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate></item>
+<item><title>First&nbsp;post</title><link>https://example.com/1</link><pubDate>Mon, 1 Jan 2024 10:00:00 GMT</pubDate></item>
+<item><title>No link</title></item>
+</channel></rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+<entry><title>Entry</title><id>tag:example.com,2024:1</id>
+<link rel="edit" href="/edit/1"/><link href="/posts/1"/>
+<updated>2024-01-03T10:00:00Z</updated></entry>
+</feed>`
+
+const rdfFeed = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><title>Blog</title></channel>
+<item><title>Item</title><link>https://example.com/rdf</link><dc:date>2024-01-04T10:00:00Z</dc:date></item>
+</rdf:RDF>`
+
+func TestParse(t *testing.T) {
+	RegisterTestingT(t)
+
+	entries, err := feeds.Parse(strings.NewReader(rssFeed))
+	Expect(err).To(BeNil())
+	Expect(entries).To(HaveLen(2))
+	Expect(entries[0].ID).To(Equal("urn:2"))
+	Expect(entries[0].Title).To(Equal("Second & café"))
+	Expect(entries[0].Published).To(BeTemporally("==", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)))
+	Expect(entries[1].ID).To(Equal("https://example.com/1"))
+	Expect(entries[1].Title).To(Equal("First\u00a0post"))
+	Expect(entries[1].Published.IsZero()).To(BeFalse())
+
+	entries, err = feeds.Parse(strings.NewReader(atomFeed))
+	Expect(err).To(BeNil())
+	Expect(entries).To(Equal([]feeds.Entry{{
+		ID:        "tag:example.com,2024:1",
+		URL:       "/posts/1",
+		Title:     "Entry",
+		Published: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC),
+	}}))
+
+	entries, err = feeds.Parse(strings.NewReader(rdfFeed))
+	Expect(err).To(BeNil())
+	Expect(entries).To(HaveLen(1))
+	Expect(entries[0].URL).To(Equal("https://example.com/rdf"))
+	Expect(entries[0].Published).To(BeTemporally("==", time.Date(2024, 1, 4, 10, 0, 0, 0, time.UTC)))
+
+	_, err = feeds.Parse(strings.NewReader("<html><body>Hi</body></html>"))
+	Expect(err).To(MatchError(ContainSubstring("not a feed")))
+}
+
+func TestFetch(t *testing.T) {
+	RegisterTestingT(t)
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, atomFeed)
+	}))
+	defer ts.Close()
+
+	feed := &feeds.Feed{URL: ts.URL + "/feed.xml"}
+	entries, err := feed.Fetch(http.DefaultClient)
+	Expect(err).To(BeNil())
+	Expect(entries).To(HaveLen(1))
+	Expect(entries[0].URL).To(Equal(ts.URL + "/posts/1"))
+	Expect(feed.ETag).To(Equal(`"v1"`))
+
+	_, err = feed.Fetch(http.DefaultClient)
+	Expect(err).To(Equal(feeds.ErrNotModified))
+	Expect(requests).To(Equal(2))
+}
+
+func TestNew(t *testing.T) {
+	RegisterTestingT(t)
+
+	feed := &feeds.Feed{}
+	entries := []feeds.Entry{{ID: "2"}, {ID: "1"}}
+	Expect(feed.New(entries)).To(Equal([]feeds.Entry{{ID: "1"}, {ID: "2"}}))
+	Expect(feed.New(entries)).To(BeEmpty())
+
+	entries = append([]feeds.Entry{{ID: "3"}}, entries...)
+	Expect(feed.New(entries)).To(Equal([]feeds.Entry{{ID: "3"}}))
+	Expect(feed.Seen).To(Equal([]string{"1", "2", "3"}))
+
+	for i := 0; i < 600; i++ {
+		feed.New([]feeds.Entry{{ID: fmt.Sprint(i + 10)}})
+	}
+	Expect(feed.Seen).To(HaveLen(500))
+	Expect(feed.Seen[499]).To(Equal("609"))
+}
+
+func TestDiscover(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/blog/", http.StatusMovedPermanently)
+		case "/blog/":
+			fmt.Fprint(w, `<!DOCTYPE html><html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="Posts" href="feed.xml">
+<link rel="alternate" hreflang="de" href="/de/">
+<link rel="alternate" type="application/feed+json" href="https://example.com/feed.json">
+<link rel="alternate stylesheet" type="application/atom+xml" href="/odd.xml">
+</head><body><link rel="alternate" type="application/atom+xml" href="/comments.xml"></body></html>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	links, err := feeds.Discover(http.DefaultClient, ts.URL+"/")
+	Expect(err).To(BeNil())
+	Expect(links).To(Equal([]feeds.Link{
+		{URL: ts.URL + "/blog/feed.xml", Title: "Posts", Type: "application/rss+xml"},
+		{URL: "https://example.com/feed.json", Type: "application/feed+json"},
+	}))
+
+	_, err = feeds.Discover(http.DefaultClient, ts.URL+"/gone")
+	Expect(err).To(MatchError("got response 404"))
+}
@@ -1,15 +1,17 @@
 module github.com/motemen/go-pocket
 
-go 1.18
+go 1.21
 
 require (
-	github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815
 	github.com/onsi/gomega v1.20.2
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/net v0.0.0-20220722155237-a158d28d115b
+	golang.org/x/sys v0.4.0
+	golang.org/x/term v0.4.0
 )
 
 require (
 	github.com/google/go-cmp v0.5.8 // indirect
-	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
 	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
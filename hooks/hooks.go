@@ -0,0 +1,126 @@
+// Package hooks runs external commands on library events, passing each event
+// as JSON on standard input.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/webhook"
+)
+
+// EventPreDelete runs before an item is deleted. A hook exiting with a
+// non-zero status vetoes the deletion.
+const EventPreDelete = "pre-delete"
+
+// Hook is a command to run on events.
+type Hook struct {
+	// Event is the event to run on, such as "added", "archived", "deleted",
+	// or EventPreDelete. If empty, the command runs on every event but
+	// EventPreDelete.
+	Event string `json:"event"`
+	// Command is a command line run by the shell, sh -c or cmd /C.
+	Command string `json:"command"`
+}
+
+// Wants reports whether the hook runs on event.
+func (h Hook) Wants(event string) bool {
+	if h.Event == "" {
+		return event != EventPreDelete
+	}
+	return h.Event == event
+}
+
+// Runner runs the hooks of events.
+type Runner struct {
+	Hooks []Hook
+	// Timeout bounds the time a command may run.
+	Timeout time.Duration
+}
+
+// NewRunner creates a runner giving each command 30 seconds.
+func NewRunner(hooks []Hook) *Runner {
+	return &Runner{Hooks: hooks, Timeout: 30 * time.Second}
+}
+
+// Wants reports whether any hook runs on event.
+func (r *Runner) Wants(event string) bool {
+	for _, hook := range r.Hooks {
+		if hook.Wants(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the hooks of event, each with a webhook.Payload of data on its
+// standard input and the event in $POCKET_EVENT; their output goes to
+// standard error. All hooks are run even if some fail; the returned error
+// describes the failures.
+func (r *Runner) Run(event string, data interface{}) error {
+	body, err := json.Marshal(webhook.Payload{Event: event, Time: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	for _, hook := range r.Hooks {
+		if !hook.Wants(event) {
+			continue
+		}
+		err := r.run(hook, event, body)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", hook.Command, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("hook failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Allow runs the hooks of event like Run, reporting whether all of them
+// allow it to go ahead by exiting with status zero. A hook that cannot be
+// run does not allow it either.
+func (r *Runner) Allow(event string, data interface{}) (bool, error) {
+	err := r.Run(event, data)
+	return err == nil, err
+}
+
+func (r *Runner) run(hook Hook, event string, body []byte) error {
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	// Standard output is kept for the output of pocket itself
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "POCKET_EVENT="+event)
+	// Children of the shell may outlive it when it is killed
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", r.Timeout)
+	}
+	return err
+}
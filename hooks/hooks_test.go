@@ -0,0 +1,81 @@
+package hooks_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/hooks"
+	"github.com/motemen/go-pocket/webhook"
+	. "github.com/onsi/gomega"
+)
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are tested with sh")
+	}
+}
+
+func TestRun(t *testing.T) {
+	RegisterTestingT(t)
+	skipOnWindows(t)
+
+	out := filepath.Join(t.TempDir(), "out")
+	runner := hooks.NewRunner([]hooks.Hook{
+		{Event: "added", Command: `cat > "` + out + `"; echo "$POCKET_EVENT" >> "` + out + `"`},
+		{Event: "deleted", Command: "exit 1"},
+	})
+
+	Expect(runner.Run("added", map[string]int{"item_id": 1})).To(Succeed())
+
+	b, err := os.ReadFile(out)
+	Expect(err).To(BeNil())
+	var payload webhook.Payload
+	dec := json.NewDecoder(bytes.NewReader(b))
+	Expect(dec.Decode(&payload)).To(Succeed())
+	Expect(payload.Event).To(Equal("added"))
+	Expect(payload.Data).To(Equal(map[string]interface{}{"item_id": float64(1)}))
+	Expect(string(b)).To(HaveSuffix("added\n"))
+
+	// Not run on other events
+	Expect(runner.Run("archived", nil)).To(Succeed())
+
+	err = runner.Run("deleted", nil)
+	Expect(err).To(MatchError(ContainSubstring("exit status 1")))
+}
+
+func TestAllow(t *testing.T) {
+	RegisterTestingT(t)
+	skipOnWindows(t)
+
+	runner := hooks.NewRunner([]hooks.Hook{
+		{Event: hooks.EventPreDelete, Command: `grep -q '"item_id":2' && exit 1 || exit 0`},
+		{Command: "exit 1"},
+	})
+
+	ok, err := runner.Allow(hooks.EventPreDelete, map[string]int{"item_id": 1})
+	Expect(ok).To(BeTrue())
+	Expect(err).To(BeNil())
+
+	ok, _ = runner.Allow(hooks.EventPreDelete, map[string]int{"item_id": 2})
+	Expect(ok).To(BeFalse())
+
+	Expect(runner.Wants(hooks.EventPreDelete)).To(BeTrue())
+	Expect(runner.Wants("archived")).To(BeTrue())
+}
+
+func TestRunTimeout(t *testing.T) {
+	RegisterTestingT(t)
+	skipOnWindows(t)
+
+	runner := hooks.NewRunner([]hooks.Hook{{Command: "exec sleep 5"}})
+	runner.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	Expect(runner.Run("added", nil)).To(MatchError(ContainSubstring("timed out")))
+	Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+}
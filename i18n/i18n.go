@@ -0,0 +1,186 @@
+// Package i18n translates the messages of a program, in the manner of
+// go-i18n: each message has an ID and its English text, translations are kept
+// in a JSON catalog per language, and the plural form is picked by a count.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Message is a text to translate. Its text may be a text/template, filled in
+// with the data given to Localize.
+type Message struct {
+	ID string
+	// One is the singular text, if it differs from Other.
+	One   string
+	Other string
+}
+
+// Bundle holds the translations of messages into each language.
+type Bundle struct {
+	translations map[string]map[string]*Message
+}
+
+// NewBundle creates a bundle without translations.
+func NewBundle() *Bundle {
+	return &Bundle{translations: map[string]map[string]*Message{}}
+}
+
+// AddMessages adds the translations of messages into lang, as in "de" or
+// "pt_BR".
+func (b *Bundle) AddMessages(lang string, messages ...*Message) {
+	lang = normalize(lang)
+	if b.translations[lang] == nil {
+		b.translations[lang] = map[string]*Message{}
+	}
+	for _, m := range messages {
+		b.translations[lang][m.ID] = m
+	}
+}
+
+// LoadJSON adds the translations into lang of a catalog mapping message IDs
+// to their text, or to the "one" and "other" plural forms of it.
+func (b *Bundle) LoadJSON(lang string, data []byte) error {
+	catalog := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("catalog %s: %w", lang, err)
+	}
+
+	messages := make([]*Message, 0, len(catalog))
+	for id, raw := range catalog {
+		m := &Message{ID: id}
+		if err := json.Unmarshal(raw, &m.Other); err != nil {
+			forms := struct{ One, Other string }{}
+			if err := json.Unmarshal(raw, &forms); err != nil {
+				return fmt.Errorf("catalog %s: message %q is neither a string nor plural forms", lang, id)
+			}
+			m.One, m.Other = forms.One, forms.Other
+		}
+		messages = append(messages, m)
+	}
+	b.AddMessages(lang, messages...)
+	return nil
+}
+
+// Languages returns the languages messages are translated into.
+func (b *Bundle) Languages() []string {
+	langs := make([]string, 0, len(b.translations))
+	for lang := range b.translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Localizer translates messages into the first of its languages with a
+// translation of each, or else leaves them in English.
+type Localizer struct {
+	bundle *Bundle
+	langs  []string
+}
+
+// NewLocalizer creates a localizer for the locales given, best first, as in
+// "de_AT.UTF-8" or "de-AT"; each is tried as is and then as its language.
+func NewLocalizer(bundle *Bundle, locales ...string) *Localizer {
+	l := &Localizer{bundle: bundle}
+	for _, locale := range locales {
+		locale, _, _ = strings.Cut(locale, ".")
+		locale, _, _ = strings.Cut(locale, "@")
+		if locale = normalize(locale); locale == "" {
+			continue
+		}
+		l.langs = append(l.langs, locale)
+		if lang, _, ok := strings.Cut(locale, "_"); ok {
+			l.langs = append(l.langs, lang)
+		}
+	}
+	return l
+}
+
+// Language returns the language messages are translated into, or "en" if
+// there is no translation into any of the locales of l.
+func (l *Localizer) Language() string {
+	for _, lang := range l.langs {
+		if _, ok := l.bundle.translations[lang]; ok {
+			return lang
+		}
+	}
+	return "en"
+}
+
+// Localize translates m, filling in its template with data.
+func (l *Localizer) Localize(m *Message, data interface{}) string {
+	translated, _ := l.lookup(m)
+	return render(translated.Other, data)
+}
+
+// Plural translates m in the plural form for count, filling in its template
+// with data.
+func (l *Localizer) Plural(m *Message, count int, data interface{}) string {
+	translated, lang := l.lookup(m)
+	if translated.One != "" && isOne(lang, count) {
+		return render(translated.One, data)
+	}
+	return render(translated.Other, data)
+}
+
+// lookup returns the translation of m and its language, or m itself.
+func (l *Localizer) lookup(m *Message) (*Message, string) {
+	for _, lang := range l.langs {
+		if t, ok := l.bundle.translations[lang][m.ID]; ok {
+			return t, lang
+		}
+	}
+	return m, "en"
+}
+
+// zeroIsOne are the languages using the singular for zero too.
+var zeroIsOne = map[string]bool{"fr": true, "pt": true, "hi": true}
+
+// noPlural are the languages with a single form.
+var noPlural = map[string]bool{"ja": true, "zh": true, "ko": true, "th": true}
+
+// isOne tells if count takes the singular in lang.
+func isOne(lang string, count int) bool {
+	lang, _, _ = strings.Cut(lang, "_")
+	switch {
+	case noPlural[lang]:
+		return false
+	case zeroIsOne[lang]:
+		return count == 0 || count == 1
+	default:
+		return count == 1
+	}
+}
+
+func render(text string, data interface{}) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	tmpl, err := template.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return text
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return text
+	}
+	return b.String()
+}
+
+// normalize turns locales such as "pt-br" into "pt_BR".
+func normalize(locale string) string {
+	lang, region, ok := strings.Cut(strings.ReplaceAll(locale, "-", "_"), "_")
+	lang = strings.ToLower(lang)
+	if lang == "c" || lang == "posix" {
+		return ""
+	}
+	if !ok {
+		return lang
+	}
+	return lang + "_" + strings.ToUpper(region)
+}
@@ -0,0 +1,79 @@
+package i18n_test
+
+import (
+	"testing"
+
+	"github.com/motemen/go-pocket/i18n"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	greeting = &i18n.Message{ID: "greeting", Other: "Hello, {{.Name}}"}
+	items    = &i18n.Message{ID: "items", One: "{{.Count}} item", Other: "{{.Count}} items"}
+	farewell = &i18n.Message{ID: "farewell", Other: "Bye"}
+)
+
+func newBundle() *i18n.Bundle {
+	bundle := i18n.NewBundle()
+	err := bundle.LoadJSON("de", []byte(`{
+		"greeting": "Hallo, {{.Name}}",
+		"items": {"one": "{{.Count}} Eintrag", "other": "{{.Count}} Einträge"}
+	}`))
+	Expect(err).NotTo(HaveOccurred())
+	err = bundle.LoadJSON("fr", []byte(`{"items": {"one": "{{.Count}} article", "other": "{{.Count}} articles"}}`))
+	Expect(err).NotTo(HaveOccurred())
+	return bundle
+}
+
+func TestLocalize(t *testing.T) {
+	RegisterTestingT(t)
+
+	bundle := newBundle()
+	Expect(bundle.Languages()).To(Equal([]string{"de", "fr"}))
+
+	de := i18n.NewLocalizer(bundle, "de_AT.UTF-8")
+	Expect(de.Language()).To(Equal("de"))
+	Expect(de.Localize(greeting, map[string]string{"Name": "Welt"})).To(Equal("Hallo, Welt"))
+	// Untranslated messages stay in English
+	Expect(de.Localize(farewell, nil)).To(Equal("Bye"))
+
+	en := i18n.NewLocalizer(bundle, "C", "en_US")
+	Expect(en.Language()).To(Equal("en"))
+	Expect(en.Localize(greeting, map[string]string{"Name": "World"})).To(Equal("Hello, World"))
+
+	// Later locales are tried when the first has no translation
+	Expect(i18n.NewLocalizer(bundle, "ja", "de-de").Localize(greeting, map[string]string{"Name": "Welt"})).To(Equal("Hallo, Welt"))
+}
+
+func TestPlural(t *testing.T) {
+	RegisterTestingT(t)
+
+	bundle := newBundle()
+	en := i18n.NewLocalizer(bundle)
+	de := i18n.NewLocalizer(bundle, "de")
+	fr := i18n.NewLocalizer(bundle, "fr_FR")
+
+	for _, tt := range []struct {
+		localizer *i18n.Localizer
+		count     int
+		text      string
+	}{
+		{en, 0, "0 items"},
+		{en, 1, "1 item"},
+		{en, 2, "2 items"},
+		{de, 1, "1 Eintrag"},
+		{de, 0, "0 Einträge"},
+		{fr, 0, "0 article"},
+		{fr, 1, "1 article"},
+		{fr, 3, "3 articles"},
+	} {
+		Expect(tt.localizer.Plural(items, tt.count, map[string]int{"Count": tt.count})).To(Equal(tt.text))
+	}
+}
+
+func TestLoadJSONInvalid(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(i18n.NewBundle().LoadJSON("de", []byte(`{"x": 1}`))).To(MatchError(ContainSubstring(`message "x"`)))
+	Expect(i18n.NewBundle().LoadJSON("de", []byte(`[`))).To(HaveOccurred())
+}
@@ -0,0 +1,269 @@
+// Package apicompat lists the API of the stable packages of the module, for
+// its tests to check that no release breaks it, as apidiff would: each
+// exported function, method, type, field, constant, and variable is a line
+// of text, named with its type, and a change to the API is told by the
+// lines removed and added.
+package apicompat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Stable are the packages of the module under the compatibility rules of
+// v1, by their directories from the root of the module.
+var Stable = []string{"api", "auth", "library", "pocketops", "render"}
+
+// experimentalMarker starts the paragraph of a doc comment leaving the
+// identifier out of the compatibility rules.
+const experimentalMarker = "Experimental:"
+
+// Features returns the API of the package in dir, sorted, each line
+// starting with the name of the package, as in
+//
+//	pkg api, func NewClient(string, string) *Client
+//
+// Identifiers documented as experimental are left out, and so are the
+// fields and methods of the types that are.
+func Features(dir string) ([]string, error) {
+	ctx := build.Default
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	features := []string{}
+	add := func(format string, args ...any) {
+		features = append(features, fmt.Sprintf("pkg %s, ", pkg.Name)+fmt.Sprintf(format, args...))
+	}
+
+	files := []*ast.File{}
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	// The types left out, whose methods are too
+	experimental := map[string]bool{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if isExperimental(gen.Doc) || isExperimental(ts.Doc) {
+						experimental[ts.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if !decl.Name.IsExported() || isExperimental(decl.Doc) {
+					continue
+				}
+				if decl.Recv == nil {
+					add("func %s%s", decl.Name.Name, signature(decl.Type))
+					continue
+				}
+				recv := decl.Recv.List[0].Type
+				base := recv
+				if star, ok := base.(*ast.StarExpr); ok {
+					base = star.X
+				}
+				if ident, ok := base.(*ast.Ident); !ok || !ident.IsExported() || experimental[ident.Name] {
+					continue
+				}
+				add("method (%s) %s%s", exprString(recv), decl.Name.Name, signature(decl.Type))
+
+			case *ast.GenDecl:
+				genFeatures(decl, add)
+			}
+		}
+	}
+	sort.Strings(features)
+	return features, nil
+}
+
+func genFeatures(decl *ast.GenDecl, add func(format string, args ...any)) {
+	if isExperimental(decl.Doc) {
+		return
+	}
+	// The type of the constants of a group repeating the spec before
+	var implicit ast.Expr
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			if !spec.Name.IsExported() || isExperimental(spec.Doc) {
+				continue
+			}
+			typeFeatures(spec, add)
+
+		case *ast.ValueSpec:
+			if spec.Type != nil || len(spec.Values) > 0 {
+				implicit = spec.Type
+			}
+			if isExperimental(spec.Doc) {
+				continue
+			}
+			kind := "var"
+			if decl.Tok == token.CONST {
+				kind = "const"
+			}
+			for _, name := range spec.Names {
+				if !name.IsExported() {
+					continue
+				}
+				if typ := implicit; typ != nil && (kind == "const" || spec.Type != nil) {
+					add("%s %s %s", kind, name.Name, exprString(typ))
+				} else {
+					add("%s %s", kind, name.Name)
+				}
+			}
+		}
+	}
+}
+
+func typeFeatures(spec *ast.TypeSpec, add func(format string, args ...any)) {
+	name := spec.Name.Name
+	if spec.TypeParams != nil {
+		name += exprString(&ast.IndexListExpr{X: spec.Name, Indices: fieldTypes(spec.TypeParams)})
+	}
+	if spec.Assign.IsValid() {
+		add("type %s = %s", name, exprString(spec.Type))
+		return
+	}
+
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		add("type %s struct", name)
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 {
+				add("type %s struct, embedded %s", name, exprString(field.Type))
+				continue
+			}
+			if isExperimental(field.Doc) {
+				continue
+			}
+			for _, n := range field.Names {
+				if n.IsExported() {
+					add("type %s struct, %s %s", name, n.Name, exprString(field.Type))
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		add("type %s interface", name)
+		for _, m := range t.Methods.List {
+			if len(m.Names) == 0 {
+				add("type %s interface, embedded %s", name, exprString(m.Type))
+				continue
+			}
+			for _, n := range m.Names {
+				if n.IsExported() {
+					add("type %s interface, %s%s", name, n.Name, signature(m.Type.(*ast.FuncType)))
+				} else {
+					// Unexported methods keep others from implementing it
+					add("type %s interface, unexported methods", name)
+				}
+			}
+		}
+	default:
+		add("type %s %s", name, exprString(spec.Type))
+	}
+}
+
+// isExperimental reports whether a doc comment has a paragraph starting
+// with experimentalMarker.
+func isExperimental(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, paragraph := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(strings.TrimSpace(paragraph), experimentalMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// signature returns the parameters and results of a function, without
+// their names, which callers do not depend on.
+func signature(t *ast.FuncType) string {
+	s := strings.TrimPrefix(exprString(t), "func")
+	if t.TypeParams != nil {
+		// Printed after the name
+		s = "[" + strings.Join(typeParams(t.TypeParams), ", ") + "]" + s
+	}
+	return s
+}
+
+// typeParams returns the type parameters of a list, with their
+// constraints.
+func typeParams(list *ast.FieldList) []string {
+	params := []string{}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			params = append(params, name.Name+" "+exprString(field.Type))
+		}
+	}
+	return params
+}
+
+// fieldTypes returns the names of a list of type parameters.
+func fieldTypes(list *ast.FieldList) []ast.Expr {
+	exprs := []ast.Expr{}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			exprs = append(exprs, name)
+		}
+	}
+	return exprs
+}
+
+// exprString prints a type with the names of the parameters of any
+// function types in it left out.
+func exprString(x ast.Expr) string {
+	// A copy to change, as the type is printed again and parsed
+	copied, err := parser.ParseExpr(types.ExprString(x))
+	if err != nil {
+		return types.ExprString(x)
+	}
+	ast.Inspect(copied, func(n ast.Node) bool {
+		if t, ok := n.(*ast.FuncType); ok {
+			t.TypeParams = nil
+			unname(t.Params)
+			unname(t.Results)
+		}
+		return true
+	})
+	return types.ExprString(copied)
+}
+
+// unname drops the names of the fields of list, repeating the type of each
+// name.
+func unname(list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	fields := []*ast.Field{}
+	for _, field := range list.List {
+		for i := 0; i < max(len(field.Names), 1); i++ {
+			fields = append(fields, &ast.Field{Type: field.Type})
+		}
+	}
+	list.List = fields
+}
@@ -0,0 +1,86 @@
+package apicompat_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/internal/apicompat"
+	. "github.com/onsi/gomega"
+)
+
+var update = flag.Bool("update", false, "record the API added since v1 in testdata/next.txt")
+
+func TestFeatures(t *testing.T) {
+	RegisterTestingT(t)
+
+	features, err := apicompat.Features("testdata/example")
+	Expect(err).To(BeNil())
+	Expect(features).To(Equal([]string{
+		"pkg example, const Answer",
+		"pkg example, const KindA Kind",
+		"pkg example, const KindB Kind",
+		"pkg example, func Map[T any]([]T, func(T) T) []T",
+		"pkg example, method (*Thing) Do(io.Writer, ...string) (int, error)",
+		"pkg example, type Kind int",
+		"pkg example, type Renderer interface",
+		"pkg example, type Renderer interface, Render(io.Writer, func(int, int) bool) error",
+		"pkg example, type Thing struct",
+		"pkg example, type Thing struct, Name string",
+		"pkg example, type Thing struct, embedded io.Writer",
+		"pkg example, var Default",
+	}))
+}
+
+// readFeatures reads the lines of a file of features, skipping blank lines
+// and comments.
+func readFeatures(path string) []string {
+	b, err := os.ReadFile(path)
+	Expect(err).To(BeNil())
+	features := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			features = append(features, line)
+		}
+	}
+	return features
+}
+
+// TestCompatible checks that the stable packages keep all of the API of v1,
+// in testdata/v1.txt, and that any added since is recorded in
+// testdata/next.txt, to be reviewed as such.
+func TestCompatible(t *testing.T) {
+	RegisterTestingT(t)
+
+	current := []string{}
+	for _, dir := range apicompat.Stable {
+		features, err := apicompat.Features(filepath.Join("..", "..", dir))
+		Expect(err).To(BeNil())
+		current = append(current, features...)
+	}
+
+	v1 := readFeatures("testdata/v1.txt")
+	removed := []string{}
+	for _, feature := range v1 {
+		if !slices.Contains(current, feature) {
+			removed = append(removed, feature)
+		}
+	}
+	Expect(removed).To(BeEmpty(), "The API of v1 changed or was removed; only a v2 may break it")
+
+	added := []string{}
+	for _, feature := range current {
+		if !slices.Contains(v1, feature) {
+			added = append(added, feature)
+		}
+	}
+	if *update {
+		content := "# The API added since v1, for the next minor release\n" + strings.Join(added, "\n")
+		Expect(os.WriteFile("testdata/next.txt", []byte(strings.TrimSpace(content)+"\n"), 0644)).To(Succeed())
+		return
+	}
+	Expect(added).To(ConsistOf(readFeatures("testdata/next.txt")), "Record the API added with go test ./internal/apicompat -update")
+}
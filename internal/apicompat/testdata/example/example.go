@@ -0,0 +1,49 @@
+// Package example is a package to list the API of.
+package example
+
+import "io"
+
+// Kind is a kind.
+type Kind int
+
+const (
+	KindA Kind = iota
+	KindB
+	kindC
+)
+
+const Answer = 42
+
+var Default = &Thing{}
+
+// Thing is a thing.
+type Thing struct {
+	Name string
+	io.Writer
+	private int
+	// Experimental: may go away.
+	Draft bool
+}
+
+// Do does.
+func (t *Thing) Do(w io.Writer, names ...string) (n int, err error) { return 0, nil }
+
+func (t Thing) hidden() {}
+
+// Renderer renders.
+type Renderer interface {
+	Render(w io.Writer, f func(a, b int) bool) error
+}
+
+// Experimental: not ready.
+type Draft struct{ Name string }
+
+func (Draft) Publish() {}
+
+// Map maps.
+func Map[T any](items []T, f func(item T) T) []T { return nil }
+
+// Gone is not for keeps.
+//
+// Experimental: may go away.
+func Gone() {}
@@ -0,0 +1,69 @@
+// Package atomicfile writes files so that a crash, a power cut, or a full
+// disk leaves either the file as it was or as it was to be, never something
+// in between: the data goes to a temporary file in the same directory, is
+// synced to disk, and is then renamed over the file.
+package atomicfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Write replaces the file at path with what write writes, the file having
+// the permissions perm. The file is left as it was if write or any of the
+// steps fails.
+func Write(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		// Without it the rename can reach the disk before the data does
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// WriteFile is Write for data already at hand, as os.WriteFile.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return Write(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// syncDir syncs the directory at dir, for the rename in it to last. Windows
+// can't open directories for that, and some file systems refuse to sync
+// them, which leaves the rename as safe as they make it.
+func syncDir(dir string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
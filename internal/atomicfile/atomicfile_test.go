@@ -0,0 +1,61 @@
+package atomicfile_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/motemen/go-pocket/internal/atomicfile"
+)
+
+func TestWriteFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	Expect(os.WriteFile(path, []byte("old"), 0644)).To(Succeed())
+
+	Expect(atomicfile.WriteFile(path, []byte("new"), 0600)).To(Succeed())
+
+	data, err := os.ReadFile(path)
+	Expect(err).To(BeNil())
+	Expect(string(data)).To(Equal("new"))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		Expect(err).To(BeNil())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	}
+
+	entries, err := os.ReadDir(dir)
+	Expect(err).To(BeNil())
+	Expect(entries).To(HaveLen(1))
+}
+
+func TestWriteFailing(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "queue.jsonl")
+	Expect(os.WriteFile(path, []byte("{}\n"), 0600)).To(Succeed())
+
+	// Half written when the disk fills up
+	errFull := errors.New("no space left on device")
+	err := atomicfile.Write(path, 0600, func(w io.Writer) error {
+		io.WriteString(w, "{\"item")
+		return errFull
+	})
+	Expect(err).To(Equal(errFull))
+
+	data, err := os.ReadFile(path)
+	Expect(err).To(BeNil())
+	Expect(string(data)).To(Equal("{}\n"))
+
+	entries, err := os.ReadDir(dir)
+	Expect(err).To(BeNil())
+	Expect(entries).To(HaveLen(1))
+}
@@ -0,0 +1,129 @@
+// Package lang guesses the language of short texts, such as titles and
+// excerpts, from their script and their most common words.
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts name the language of texts written mostly in a script used by one
+// language, or by one language more than any other. Han, shared by Chinese
+// and Japanese, is told apart by kana.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Cyrillic, "ru"},
+}
+
+// stopwords are the most common words of languages written in the Latin
+// script, which are rare in the others.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "with", "you", "how", "what", "why", "are", "this", "your", "from", "on", "it", "be"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "sich", "auf", "für", "ein", "eine", "zu", "den", "von", "wie", "warum", "im", "dem", "auch"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "du", "pour", "dans", "que", "qui", "pas", "sur", "au", "avec", "comment", "pourquoi", "ce", "il"},
+	"es": {"el", "los", "las", "y", "es", "del", "una", "por", "para", "con", "que", "se", "como", "cómo", "qué", "su", "al", "lo", "más", "pero"},
+	"it": {"il", "di", "che", "è", "per", "una", "della", "sono", "con", "non", "gli", "del", "come", "perché", "anche", "nel", "alla", "questo", "da", "si"},
+	"pt": {"o", "os", "as", "e", "é", "do", "da", "uma", "para", "com", "não", "que", "em", "no", "na", "como", "por", "dos", "mais", "você"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "met", "op", "voor", "zijn", "dat", "je", "hoe", "waarom", "ook", "maar", "naar", "bij", "wat"},
+	"sv": {"och", "att", "det", "som", "är", "för", "med", "inte", "på", "av", "en", "till", "har", "hur", "varför", "den", "om", "jag", "vi", "du"},
+}
+
+// languagesOf are the languages of which each word of stopwords is common.
+var languagesOf = func() map[string][]string {
+	languages := map[string][]string{}
+	for lang, words := range stopwords {
+		for _, w := range words {
+			languages[w] = append(languages[w], lang)
+		}
+	}
+	return languages
+}()
+
+// ukrainian are the Cyrillic letters used in Ukrainian but not Russian.
+const ukrainian = "іїєґ"
+
+// minimumHits is the number of common words a text needs for its language
+// to be guessed.
+const minimumHits = 2
+
+// Detect returns the ISO 639-1 code of the language of text, as in "en", or
+// an empty string if it cannot tell.
+func Detect(text string) string {
+	letters := 0
+	counts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Japanese mixes kana with Han, which alone is Chinese
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	best, most := "", 0
+	for lang, count := range counts {
+		if count > most || count == most && lang < best {
+			best, most = lang, count
+		}
+	}
+	if most*2 > letters {
+		if best == "ru" && strings.ContainsAny(strings.ToLower(text), ukrainian) {
+			return "uk"
+		}
+		return best
+	}
+
+	return detectLatin(text)
+}
+
+// detectLatin guesses the language of a text in the Latin script from the
+// common words it uses.
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	hits := map[string]int{}
+	for _, w := range words {
+		for _, lang := range languagesOf[w] {
+			hits[lang]++
+		}
+	}
+
+	best, most, tied := "", 0, false
+	for lang, n := range hits {
+		switch {
+		case n > most:
+			best, most, tied = lang, n, false
+		case n == most:
+			tied = true
+		}
+	}
+	if most < minimumHits || tied {
+		return ""
+	}
+	return best
+}
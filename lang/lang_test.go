@@ -0,0 +1,40 @@
+package lang_test
+
+import (
+	"testing"
+
+	"github.com/motemen/go-pocket/lang"
+	. "github.com/onsi/gomega"
+)
+
+func TestDetect(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, tt := range []struct {
+		text string
+		lang string
+	}{
+		{"How to write the tests for your Go code", "en"},
+		{"Warum die Bahn nicht pünktlich ist und was man tun kann", "de"},
+		{"Comment les villes se préparent pour la chaleur", "fr"},
+		{"Cómo funciona el sistema de pensiones y por qué importa", "es"},
+		{"Perché il caffè è più buono con la moka", "it"},
+		{"Como a inflação afeta os salários no Brasil", "pt"},
+		{"Waarom het weer in Nederland zo wisselvallig is", "nl"},
+		{"Så fungerar det svenska skattesystemet för dig som är ny", "sv"},
+		{"Почему кошки любят коробки", "ru"},
+		{"Як працює українська енергосистема", "uk"},
+		{"Γιατί οι γάτες αγαπούν τα κουτιά", "el"},
+		{"プログラミング言語Goの入門", "ja"},
+		{"如何学习编程", "zh"},
+		{"프로그래밍 언어 배우기", "ko"},
+		{"لماذا تحب القطط الصناديق", "ar"},
+
+		// Too little to tell
+		{"Kubernetes", ""},
+		{"", ""},
+		{"12345", ""},
+	} {
+		Expect(lang.Detect(tt.text)).To(Equal(tt.lang), tt.text)
+	}
+}
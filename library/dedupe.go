@@ -0,0 +1,139 @@
+package library
+
+import (
+	"maps"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// Richer tells whether a is the copy of an item to keep over b: a favorite,
+// then the one with more tags, then the one added first.
+func Richer(a, b api.Item) bool {
+	if a.Favorite != b.Favorite {
+		return a.Favorite == 1
+	}
+	if len(a.Tags) != len(b.Tags) {
+		return len(a.Tags) > len(b.Tags)
+	}
+	return AddedBefore(a, b)
+}
+
+// FindDuplicates finds the items saved more than once, by their cleaned up
+// URL, returning the ID of the copy kept for each of the others. The items
+// are to have their tags, which decide the copy kept.
+func FindDuplicates(items []api.Item) map[int]int {
+	groups := map[string][]api.Item{}
+	for _, item := range items {
+		url := CleanURL(item.URL())
+		groups[url] = append(groups[url], item)
+	}
+
+	keptFor := map[int]int{}
+	for _, group := range groups {
+		kept := group[0]
+		for _, item := range group[1:] {
+			if Richer(item, kept) {
+				kept = item
+			}
+		}
+		for _, item := range group {
+			if item.ItemID != kept.ItemID {
+				keptFor[item.ItemID] = kept.ItemID
+			}
+		}
+	}
+	return keptFor
+}
+
+// MergeDuplicateActions returns the actions giving the copies kept the tags
+// and favorite state of the duplicates deleted, leaving their archive state
+// as it is. items are the items with their tags, by ID, and keptFor the
+// copies kept as FindDuplicates returns them.
+func MergeDuplicateActions(items map[int]api.Item, keptFor map[int]int, deleted []int) []*api.Action {
+	merged := map[int]api.Item{}
+	order := []int{}
+	for _, id := range deleted {
+		keptID := keptFor[id]
+		kept, ok := merged[keptID]
+		if !ok {
+			kept = items[keptID]
+			kept.Tags = maps.Clone(kept.Tags)
+			order = append(order, keptID)
+		}
+		MergeItem(&kept, items[id])
+		kept.Status = items[keptID].Status
+		merged[keptID] = kept
+	}
+
+	actions := []*api.Action{}
+	for _, id := range order {
+		current := items[id]
+		actions = append(actions, StateActions(merged[id], id, &current)...)
+	}
+	return actions
+}
+
+// MergeItem merges the tags and favorite and archive state of a duplicate
+// into item.
+func MergeItem(item *api.Item, duplicate api.Item) {
+	for tag, v := range duplicate.Tags {
+		if item.Tags == nil {
+			item.Tags = map[string]map[string]interface{}{}
+		}
+		if _, ok := item.Tags[tag]; !ok {
+			item.Tags[tag] = v
+		}
+	}
+	if duplicate.Favorite == 1 && item.Favorite != 1 {
+		item.Favorite, item.TimeFavorited = 1, duplicate.TimeFavorited
+	}
+	if duplicate.Status == api.ItemStatusArchived && item.Status != api.ItemStatusArchived {
+		item.Status, item.TimeRead = api.ItemStatusArchived, duplicate.TimeRead
+	}
+}
+
+// StateActions returns the actions needed to bring the item with itemID to
+// the tags and the favorite and archive state of saved, as in a backup.
+// current is the item as it is in Pocket now, or nil if it has just been
+// added with its tags.
+func StateActions(saved api.Item, itemID int, current *api.Item) []*api.Action {
+	actions := []*api.Action{}
+
+	if current != nil {
+		missing := []string{}
+		for _, tag := range saved.TagNames() {
+			if _, ok := current.Tags[tag]; !ok {
+				missing = append(missing, tag)
+			}
+		}
+		if len(missing) > 0 {
+			actions = append(actions, api.NewTagsAddAction(itemID, missing...))
+		}
+	}
+
+	if saved.Favorite == 1 && (current == nil || current.Favorite != 1) {
+		action := api.NewFavoriteAction(itemID)
+		action.Time = UnixTime(saved.TimeFavorited)
+		actions = append(actions, action)
+	}
+
+	if saved.Status == api.ItemStatusArchived && (current == nil || current.Status != api.ItemStatusArchived) {
+		action := api.NewArchiveAction(itemID)
+		action.Time = UnixTime(saved.TimeRead)
+		actions = append(actions, action)
+	}
+
+	return actions
+}
+
+// AddAction returns the action adding an item as it was saved, with its
+// title, tags, and time added, and tags added.
+func AddAction(item api.Item, tags ...string) *api.Action {
+	url := item.GivenURL
+	if url == "" {
+		url = item.URL()
+	}
+	action := api.NewAddAction(url, item.GivenTitle, append(item.TagNames(), tags...)...)
+	action.Time = UnixTime(item.TimeAdded)
+	return action
+}
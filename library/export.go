@@ -0,0 +1,170 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// exportDateLayout is the layout used for dates in exported front-matter.
+const exportDateLayout = "2006-01-02"
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// nonOrgTagChars are the characters Org mode does not allow in tags.
+var nonOrgTagChars = regexp.MustCompile(`[^\pL\pN_@#%]+`)
+
+// Slugify turns a title into a lowercase, hyphen-separated string that is safe
+// to use in file names. It is truncated to keep paths reasonably short.
+func Slugify(s string) string {
+	slug := nonSlugChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	return strings.Trim(slug, "-")
+}
+
+// ExportFileName returns a stable file name for an item. The item ID is always
+// part of the name, so repeated exports of the same item overwrite the same
+// file instead of creating new ones.
+func ExportFileName(item api.Item, ext string) string {
+	slug := Slugify(item.Title())
+	if slug == "" {
+		return fmt.Sprintf("%d.%s", item.ItemID, ext)
+	}
+	return fmt.Sprintf("%s-%d.%s", slug, item.ItemID, ext)
+}
+
+// RemoveStaleExports removes the files in dir that ExportFileName gave item
+// under titles it no longer has, so that a retitled item is left with only
+// the file of its current name.
+func RemoveStaleExports(dir string, item api.Item, ext string) error {
+	current := ExportFileName(item, ext)
+	bare := fmt.Sprintf("%d.%s", item.ItemID, ext)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == current || entry.IsDir() {
+			continue
+		}
+		if name != bare && !strings.HasSuffix(name, "-"+bare) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlString quotes s for use as a YAML scalar. JSON strings are valid YAML.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// WriteMarkdown writes item as a Markdown note with YAML front matter,
+// followed by its excerpt and note, if any.
+func WriteMarkdown(w io.Writer, item api.Item, note string) error {
+	tags := item.TagNames()
+	quoted := make([]string, len(tags))
+	for i, tag := range tags {
+		quoted[i] = yamlString(tag)
+	}
+
+	_, err := fmt.Fprintf(w, `---
+title: %s
+url: %s
+item_id: %d
+tags: [%s]
+added: %s
+excerpt: %s
+---
+
+# %s
+
+<%s>
+`,
+		yamlString(item.Title()),
+		yamlString(item.URL()),
+		item.ItemID,
+		strings.Join(quoted, ", "),
+		item.TimeAdded.UTC().Format(exportDateLayout),
+		yamlString(item.Excerpt),
+		item.Title(),
+		item.URL(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if item.Excerpt != "" {
+		_, err = fmt.Fprintf(w, "\n> %s\n", strings.ReplaceAll(item.Excerpt, "\n", "\n> "))
+		if err != nil {
+			return err
+		}
+	}
+	if note != "" {
+		_, err = fmt.Fprintf(w, "\n## Note\n\n%s\n", note)
+	}
+	return err
+}
+
+// WriteOrg writes item as an Org mode note, as for org-roam, followed by
+// its excerpt and note, if any.
+func WriteOrg(w io.Writer, item api.Item, note string) error {
+	tags := item.TagNames()
+	fileTags := ""
+	if len(tags) > 0 {
+		orgTags := make([]string, len(tags))
+		for i, tag := range tags {
+			orgTags[i] = nonOrgTagChars.ReplaceAllString(tag, "_")
+		}
+		fileTags = ":" + strings.Join(orgTags, ":") + ":"
+	}
+
+	_, err := fmt.Fprintf(w, `:PROPERTIES:
+:ID:       pocket-%d
+:URL:      %s
+:ADDED:    %s
+:END:
+#+title: %s
+#+filetags: %s
+
+[[%s][%s]]
+`,
+		item.ItemID,
+		item.URL(),
+		item.TimeAdded.UTC().Format(exportDateLayout),
+		item.Title(),
+		fileTags,
+		item.URL(),
+		item.Title(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if item.Excerpt != "" {
+		_, err = fmt.Fprintf(w, "\n#+begin_quote\n%s\n#+end_quote\n", item.Excerpt)
+		if err != nil {
+			return err
+		}
+	}
+	if note != "" {
+		_, err = fmt.Fprintf(w, "\n* Note\n%s\n", note)
+	}
+	return err
+}
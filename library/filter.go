@@ -0,0 +1,137 @@
+package library
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/lang"
+)
+
+// BySortID orders items by their sort ID, as Pocket lists them.
+type BySortID []api.Item
+
+func (s BySortID) Len() int           { return len(s) }
+func (s BySortID) Less(i, j int) bool { return s[i].SortId < s[j].SortId }
+func (s BySortID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// AddedBefore tells whether a was added before b, ordering the items added
+// in the same second, as by an import, by their IDs.
+func AddedBefore(a, b api.Item) bool {
+	if !a.TimeAdded.Equal(b.TimeAdded.Time) {
+		return a.TimeAdded.Before(b.TimeAdded.Time)
+	}
+	return a.ItemID < b.ItemID
+}
+
+// UnknownLang is the language of items whose language is not known.
+const UnknownLang = "unknown"
+
+// Lang returns the language of an item, as in "en": the one detected by
+// Pocket, or else one guessed from its title and excerpt, or UnknownLang.
+func Lang(item api.Item) string {
+	if item.Lang != "" {
+		code, _, _ := strings.Cut(strings.ToLower(item.Lang), "-")
+		code, _, _ = strings.Cut(code, "_")
+		return code
+	}
+	if code := lang.Detect(item.Title() + "\n" + item.Excerpt); code != "" {
+		return code
+	}
+	return UnknownLang
+}
+
+// FilterByLang returns the items in one of langs, or all if there are none.
+func FilterByLang(items []api.Item, langs []string) []api.Item {
+	if len(langs) == 0 {
+		return items
+	}
+	kept := []api.Item{}
+	for _, item := range items {
+		code := Lang(item)
+		for _, l := range langs {
+			if strings.EqualFold(l, code) {
+				kept = append(kept, item)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// FilterByMinutes returns the items taking from min to max minutes to read,
+// either zero for no limit. With a limit, items of unknown length, such as
+// videos, are left out.
+func FilterByMinutes(items []api.Item, min, max int) []api.Item {
+	if min == 0 && max == 0 {
+		return items
+	}
+	kept := []api.Item{}
+	for _, item := range items {
+		minutes := item.ReadingMinutes()
+		if minutes == 0 || minutes < min || max > 0 && minutes > max {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// PriorityTagPrefix starts the tag recording the priority of an item, from
+// "p:1", the highest, to "p:5".
+const PriorityTagPrefix = "p:"
+
+const (
+	HighestPriority = 1
+	LowestPriority  = 5
+)
+
+// PriorityTag returns the tag recording priority.
+func PriorityTag(priority int) string {
+	return PriorityTagPrefix + strconv.Itoa(priority)
+}
+
+// Priority returns the priority of an item from its tags, the highest if it
+// has several, or zero if it has none.
+func Priority(item api.Item) int {
+	priority := 0
+	for name := range item.Tags {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, PriorityTagPrefix))
+		if !strings.HasPrefix(name, PriorityTagPrefix) || err != nil || n < HighestPriority || n > LowestPriority {
+			continue
+		}
+		if priority == 0 || n < priority {
+			priority = n
+		}
+	}
+	return priority
+}
+
+// PriorityActions set the priority of an item, replacing any other, or
+// remove it if priority is zero.
+func PriorityActions(itemID, priority int) []*api.Action {
+	others := []string{}
+	for p := HighestPriority; p <= LowestPriority; p++ {
+		if p != priority {
+			others = append(others, PriorityTag(p))
+		}
+	}
+	actions := []*api.Action{api.NewTagsRemoveAction(itemID, others...)}
+	if priority != 0 {
+		actions = append(actions, api.NewTagsAddAction(itemID, PriorityTag(priority)))
+	}
+	return actions
+}
+
+// SortByPriority orders items by their priority, highest first and those
+// without one last, keeping the order of items of the same priority.
+func SortByPriority(items []api.Item) {
+	rank := func(item api.Item) int {
+		if p := Priority(item); p != 0 {
+			return p
+		}
+		return LowestPriority + 1
+	}
+	sort.SliceStable(items, func(i, j int) bool { return rank(items[i]) < rank(items[j]) })
+}
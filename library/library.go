@@ -0,0 +1,87 @@
+// Package library is the logic of the pocket command over the items of a
+// Pocket list, for other Go programs to reuse: cleaning up URLs to find the
+// items saved more than once, filtering and ordering items, merging
+// duplicates, checking that links still work, and exporting items as notes.
+// It works on the items retrieved with the api package, or read from a
+// mirror, without making any request to Pocket itself.
+package library
+
+import (
+	neturl "net/url"
+	"regexp"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// CleanURL cleans up url for comparing it to others: HTTPS is assumed, a
+// few YouTube parameters are dropped, and so are doubled and trailing
+// ampersands and trailing slashes.
+func CleanURL(url string) string {
+	// HTTPS by default
+	url = strings.ReplaceAll(url, "http://", "https://")
+	// Remove some youtube params
+	url = strings.TrimSuffix(url, "&a")
+	url = strings.ReplaceAll(url, "feature=g-u", "")
+	url = strings.ReplaceAll(url, "feature=youtu.be", "")
+	url = strings.ReplaceAll(url, "feature=youtube_gdata", "")
+	// Deduplicate any double ampersands
+	amps := regexp.MustCompile("&+")
+	url = amps.ReplaceAllString(url, "&")
+	url = strings.TrimRight(url, "&")
+	// Drop trailing slash
+	url = strings.TrimRight(url, "/")
+
+	return url
+}
+
+// trackingParams are query parameters that only track where a link was
+// followed from.
+var trackingParams = regexp.MustCompile(`^(utm_.*|fbclid|gclid|mc_cid|mc_eid|ref_src|igshid)$`)
+
+// URLKey normalizes url for finding the items saved more than once: on top
+// of CleanURL, the host is lowercased and loses "www.", and the fragment and
+// tracking parameters are dropped, with the rest of the query sorted.
+func URLKey(url string) string {
+	// Each pass can leave something for the next one to clean, as dropping
+	// the query does a trailing "&", so that keys stay keys of themselves.
+	key := normalizeURL(url)
+	for i := 0; i < 8; i++ {
+		next := normalizeURL(key)
+		if next == key {
+			break
+		}
+		key = next
+	}
+	return key
+}
+
+// normalizeURL is a single pass of URLKey.
+func normalizeURL(url string) string {
+	u, err := neturl.Parse(CleanURL(url))
+	if err != nil || u.Host == "" {
+		return CleanURL(url)
+	}
+
+	u.Scheme = "https"
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	u.Fragment = ""
+	u.RawFragment = ""
+	query := u.Query()
+	for name := range query {
+		if trackingParams.MatchString(name) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return strings.TrimRight(u.String(), "/")
+}
+
+// UnixTime returns t as Unix seconds, or zero if t is unset so that the API
+// falls back to the current time.
+func UnixTime(t api.Time) int64 {
+	if t.IsZero() || t.Unix() <= 0 {
+		return 0
+	}
+	return t.Unix()
+}
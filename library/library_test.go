@@ -0,0 +1,187 @@
+package library_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/library"
+	. "github.com/onsi/gomega"
+)
+
+func tagged(tags ...string) map[string]map[string]interface{} {
+	m := map[string]map[string]interface{}{}
+	for _, tag := range tags {
+		m[tag] = map[string]interface{}{"tag": tag}
+	}
+	return m
+}
+
+func added(day int) api.Time {
+	return api.Time{Time: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestURLKey(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(library.CleanURL("http://example.com/a?x=1&&a")).To(Equal("https://example.com/a?x=1"))
+	Expect(library.URLKey("http://www.Example.com/article?utm_source=feed&id=3#comments")).To(Equal("https://example.com/article?id=3"))
+}
+
+func TestFindDuplicates(t *testing.T) {
+	RegisterTestingT(t)
+
+	items := []api.Item{
+		{ItemID: 1, GivenURL: "http://example.com/a/", TimeAdded: added(1)},
+		{ItemID: 2, GivenURL: "https://example.com/a", TimeAdded: added(2), Tags: tagged("go")},
+		{ItemID: 3, GivenURL: "https://example.com/a", TimeAdded: added(3), Status: api.ItemStatusArchived, Favorite: 1},
+		{ItemID: 4, GivenURL: "https://example.com/b", TimeAdded: added(4)},
+	}
+	keptFor := library.FindDuplicates(items)
+	// The favorite is kept
+	Expect(keptFor).To(Equal(map[int]int{1: 3, 2: 3}))
+
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+	actions := library.MergeDuplicateActions(byID, keptFor, []int{1, 2})
+	Expect(actions).To(HaveLen(1))
+	Expect(actions[0].Action).To(Equal("tags_add"))
+	Expect(actions[0].ItemID).To(Equal(3))
+	Expect(actions[0].Tags).To(Equal("go"))
+}
+
+func TestStateActions(t *testing.T) {
+	RegisterTestingT(t)
+
+	saved := api.Item{Favorite: 1, Status: api.ItemStatusArchived, TimeRead: added(5), Tags: tagged("go")}
+	actions := library.StateActions(saved, 7, nil)
+	Expect(actions).To(HaveLen(2))
+	Expect(actions[0].Action).To(Equal("favorite"))
+	Expect(actions[1].Action).To(Equal("archive"))
+	Expect(actions[1].Time).To(Equal(added(5).Unix()))
+
+	current := api.Item{Favorite: 1, Tags: tagged("go")}
+	Expect(library.StateActions(saved, 7, &current)).To(HaveLen(1))
+}
+
+func TestPriority(t *testing.T) {
+	RegisterTestingT(t)
+
+	items := []api.Item{
+		{ItemID: 1},
+		{ItemID: 2, Tags: tagged("p:3")},
+		{ItemID: 3, Tags: tagged("p:1", "p:4")},
+		{ItemID: 4, Tags: tagged("p:9")},
+	}
+	Expect(library.Priority(items[2])).To(Equal(1))
+	Expect(library.Priority(items[3])).To(Equal(0))
+	library.SortByPriority(items)
+	ids := []int{}
+	for _, item := range items {
+		ids = append(ids, item.ItemID)
+	}
+	Expect(ids).To(Equal([]int{3, 2, 1, 4}))
+
+	actions := library.PriorityActions(5, 2)
+	Expect(actions).To(HaveLen(2))
+	Expect(actions[0].Tags).To(Equal("p:1,p:3,p:4,p:5"))
+	Expect(actions[1].Tags).To(Equal("p:2"))
+}
+
+func TestFilters(t *testing.T) {
+	RegisterTestingT(t)
+
+	items := []api.Item{
+		{ItemID: 1, Lang: "en-US", WordCount: 450},
+		{ItemID: 2, Lang: "de", WordCount: 4500},
+		{ItemID: 3, Lang: "en"},
+	}
+	Expect(library.Lang(items[0])).To(Equal("en"))
+	Expect(library.FilterByLang(items, []string{"EN"})).To(HaveLen(2))
+	Expect(library.FilterByLang(items, nil)).To(HaveLen(3))
+	// Without a word count, the reading time is not known
+	Expect(library.FilterByMinutes(items, 1, 5)).To(ConsistOf(items[0]))
+	Expect(library.FilterByMinutes(items, 0, 0)).To(HaveLen(3))
+}
+
+func TestCheckLink(t *testing.T) {
+	RegisterTestingT(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<p>Still here</p>"))
+	})
+	mux.HandleFunc("/paywalled", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<div class="paywall">Subscribe to continue reading</div>`))
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Sorry, this page isn't available anymore"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	chk := library.CheckLink(server.URL + "/old")
+	Expect(chk.Kind()).To(Equal(library.LinkRedirected))
+	Expect(chk.PermanentlyRedirected()).To(BeTrue())
+	Expect(chk.FinalURL).To(Equal(server.URL + "/new"))
+	Expect(library.CheckLink(server.URL + "/new").Kind()).To(Equal(library.LinkOK))
+	Expect(library.CheckLink(server.URL + "/paywalled").Kind()).To(Equal(library.LinkPaywalled))
+	Expect(library.CheckLink(server.URL + "/gone").Kind()).To(Equal(library.LinkBroken))
+	Expect(library.CheckLink(server.URL + "/missing").Status).To(Equal("404 Not Found"))
+}
+
+func TestExport(t *testing.T) {
+	RegisterTestingT(t)
+
+	item := api.Item{ItemID: 42, GivenURL: "https://example.com/go", GivenTitle: `Go: "Generics"`, TimeAdded: added(2), Tags: tagged("go")}
+	Expect(library.Slugify(item.Title())).To(Equal("go-generics"))
+	Expect(library.ExportFileName(item, "md")).To(Equal("go-generics-42.md"))
+	Expect(library.ExportFileName(api.Item{ItemID: 7}, "org")).To(Equal("7.org"))
+
+	var buf bytes.Buffer
+	Expect(library.WriteMarkdown(&buf, item, "A note")).To(Succeed())
+	Expect(buf.String()).To(HavePrefix("---\ntitle: \"Go: \\\"Generics\\\"\"\nurl: \"https://example.com/go\"\nitem_id: 42\ntags: [\"go\"]\nadded: 2024-01-02\n"))
+	Expect(buf.String()).To(HaveSuffix("\n## Note\n\nA note\n"))
+
+	buf.Reset()
+	Expect(library.WriteOrg(&buf, item, "")).To(Succeed())
+	Expect(buf.String()).To(ContainSubstring(":ID:       pocket-42\n"))
+	Expect(buf.String()).To(ContainSubstring("#+filetags: :go:\n"))
+
+	buf.Reset()
+	item.Tags = tagged("to-read", "long reads")
+	Expect(library.WriteOrg(&buf, item, "")).To(Succeed())
+	Expect(buf.String()).To(ContainSubstring("#+filetags: :long_reads:to_read:\n"))
+}
+
+func TestRemoveStaleExports(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Names in the directory are not taken for patterns
+	dir := filepath.Join(t.TempDir(), "[notes]*")
+	Expect(os.Mkdir(dir, 0700)).To(Succeed())
+	for _, name := range []string{"42.md", "go-42.md", "go-generics-42.md", "go-142.md", "go-42.org"} {
+		Expect(os.WriteFile(filepath.Join(dir, name), nil, 0600)).To(Succeed())
+	}
+
+	item := api.Item{ItemID: 42, GivenTitle: "Go Generics"}
+	Expect(library.RemoveStaleExports(dir, item, "md")).To(Succeed())
+
+	entries, err := os.ReadDir(dir)
+	Expect(err).NotTo(HaveOccurred())
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	Expect(names).To(ConsistOf("go-generics-42.md", "go-142.md", "go-42.org"))
+}
@@ -0,0 +1,114 @@
+package library
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LinkCheck is the outcome of checking that the link of an item still
+// works.
+type LinkCheck struct {
+	Status   string
+	OK       bool
+	FinalURL string
+	// Redirects are the redirects followed to FinalURL, in order.
+	Redirects []LinkRedirect
+	// Paywalled is set for pages asking to subscribe or log in, which are
+	// not counted as broken.
+	Paywalled bool
+	Err       error
+}
+
+// Kinds of link checks.
+const (
+	LinkBroken     = "broken"
+	LinkPaywalled  = "paywalled"
+	LinkRedirected = "redirected"
+	LinkOK         = "ok"
+)
+
+// Kind classifies the check: a paywall first, then a broken link, then a
+// redirect.
+func (chk LinkCheck) Kind() string {
+	switch {
+	case chk.Paywalled:
+		return LinkPaywalled
+	case chk.Err != nil || !chk.OK:
+		return LinkBroken
+	case len(chk.Redirects) > 0:
+		return LinkRedirected
+	}
+	return LinkOK
+}
+
+// LinkRedirect is a URL that redirected elsewhere, with the status saying
+// how.
+type LinkRedirect struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// PermanentlyRedirected tells whether every redirect followed was
+// permanent, and so the link is better replaced with where they lead.
+func (chk LinkCheck) PermanentlyRedirected() bool {
+	for _, r := range chk.Redirects {
+		if r.Status != http.StatusMovedPermanently && r.Status != http.StatusPermanentRedirect {
+			return false
+		}
+	}
+	return len(chk.Redirects) > 0
+}
+
+// paywallMarkers are the signs, in the lower-cased start of a page, that it
+// is only shown in full to subscribers or after logging in.
+var paywallMarkers = []string{
+	`"isaccessibleforfree":false`, `"isaccessibleforfree": false`,
+	`"isaccessibleforfree":"false"`, `"isaccessibleforfree": "false"`,
+	`class="paywall`, `id="paywall`,
+	"subscribe to continue reading", "subscribe to read the full",
+	"log in to continue", "sign in to continue", "sign in to read",
+}
+
+// CheckLink requests url, counting pages saying they are gone as not
+// available, and those answering 401, 402, or 403 or showing a paywall
+// marker as paywalled. Only the first megabyte of a page is read.
+func CheckLink(url string) LinkCheck {
+	resp, err := http.Get(url)
+	if err != nil {
+		return LinkCheck{Err: err}
+	}
+	defer resp.Body.Close()
+
+	chk := LinkCheck{
+		Status:   resp.Status,
+		OK:       resp.StatusCode < http.StatusBadRequest,
+		FinalURL: resp.Request.URL.String(),
+	}
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		r := LinkRedirect{URL: req.Response.Request.URL.String(), Status: req.Response.StatusCode}
+		chk.Redirects = append([]LinkRedirect{r}, chk.Redirects...)
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden:
+		chk.Paywalled = true
+		return chk
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if strings.Contains(string(body), "isn't available anymore") ||
+		strings.Contains(string(body), "this page doesn") {
+		chk.Status = "Not Available"
+		chk.OK = false
+	}
+	if chk.OK {
+		page := strings.ToLower(string(body))
+		for _, marker := range paywallMarkers {
+			if strings.Contains(page, marker) {
+				chk.Paywalled = true
+				break
+			}
+		}
+	}
+	return chk
+}
@@ -0,0 +1,227 @@
+// Package llm asks language models for text and embeddings, through the API
+// of OpenAI, which llama.cpp, vLLM, LM Studio, and many hosted services also
+// serve, or through that of Ollama.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backends, as named in New.
+const (
+	BackendOpenAI = "openai"
+	BackendOllama = "ollama"
+)
+
+// Default origins of the backends.
+const (
+	OpenAIOrigin = "https://api.openai.com"
+	OllamaOrigin = "http://localhost:11434"
+)
+
+// Backend answers a prompt, following the instructions of system.
+type Backend interface {
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// Embedder turns texts into vectors, close for texts close in meaning.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Error is an error response of a backend.
+type Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("llm: got response %d: %s", e.StatusCode, e.Body)
+}
+
+// New creates the backend named backend, "openai" or "ollama", using model
+// at origin, or the default origin of the backend if empty. apiKey is only
+// sent to OpenAI and compatible APIs, and only if set.
+func New(backend, origin, model, apiKey string) (Backend, error) {
+	if model == "" {
+		return nil, fmt.Errorf("llm: no model given")
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	switch backend {
+	case BackendOpenAI, "":
+		if origin == "" {
+			origin = OpenAIOrigin
+		}
+		return &OpenAI{Origin: strings.TrimSuffix(origin, "/"), Model: model, APIKey: apiKey, Client: client}, nil
+	case BackendOllama:
+		if origin == "" {
+			origin = OllamaOrigin
+		}
+		return &Ollama{Origin: strings.TrimSuffix(origin, "/"), Model: model, Client: client}, nil
+	}
+	return nil, fmt.Errorf("llm: unknown backend %q; use %q or %q", backend, BackendOpenAI, BackendOllama)
+}
+
+// NewEmbedder creates the backend named backend for embeddings, as New.
+func NewEmbedder(backend, origin, model, apiKey string) (Embedder, error) {
+	b, err := New(backend, origin, model, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return b.(Embedder), nil
+}
+
+// message is a message of a chat, in both APIs.
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAI uses the chat completions API of OpenAI, at Origin.
+type OpenAI struct {
+	Origin string
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+// Complete implements Backend.
+func (b *OpenAI) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body := map[string]interface{}{
+		"model":    b.Model,
+		"messages": []message{{"system", system}, {"user", prompt}},
+	}
+	header := http.Header{}
+	if b.APIKey != "" {
+		header.Set("Authorization", "Bearer "+b.APIKey)
+	}
+
+	var res struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := post(ctx, b.Client, b.Origin+"/v1/chat/completions", header, body, &res); err != nil {
+		return "", err
+	}
+	if len(res.Choices) == 0 {
+		return "", fmt.Errorf("llm: no answer from %s", b.Model)
+	}
+	return strings.TrimSpace(res.Choices[0].Message.Content), nil
+}
+
+// Embed implements Embedder, with the embeddings API.
+func (b *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body := map[string]interface{}{
+		"model": b.Model,
+		"input": texts,
+	}
+	header := http.Header{}
+	if b.APIKey != "" {
+		header.Set("Authorization", "Bearer "+b.APIKey)
+	}
+
+	var res struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := post(ctx, b.Client, b.Origin+"/v1/embeddings", header, body, &res); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range res.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("llm: embedding %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, checkEmbeddings(b.Model, vectors)
+}
+
+// Ollama uses the chat API of Ollama, at Origin.
+type Ollama struct {
+	Origin string
+	Model  string
+	Client *http.Client
+}
+
+// Complete implements Backend.
+func (b *Ollama) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body := map[string]interface{}{
+		"model":    b.Model,
+		"messages": []message{{"system", system}, {"user", prompt}},
+		"stream":   false,
+	}
+
+	var res struct {
+		Message message `json:"message"`
+	}
+	if err := post(ctx, b.Client, b.Origin+"/api/chat", http.Header{}, body, &res); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(res.Message.Content), nil
+}
+
+// Embed implements Embedder, with the embed API.
+func (b *Ollama) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body := map[string]interface{}{
+		"model": b.Model,
+		"input": texts,
+	}
+
+	var res struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := post(ctx, b.Client, b.Origin+"/api/embed", http.Header{}, body, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("llm: got %d embeddings from %s for %d texts", len(res.Embeddings), b.Model, len(texts))
+	}
+	return res.Embeddings, checkEmbeddings(b.Model, res.Embeddings)
+}
+
+// checkEmbeddings makes sure that a vector came back for every text.
+func checkEmbeddings(model string, vectors [][]float32) error {
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return fmt.Errorf("llm: no embedding from %s for text %d", model, i)
+		}
+	}
+	return nil
+}
+
+// post sends body as JSON to url and decodes the response into res.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body, res interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &Error{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(body))}
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
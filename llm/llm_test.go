@@ -0,0 +1,126 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/motemen/go-pocket/llm"
+	. "github.com/onsi/gomega"
+)
+
+func TestOpenAI(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.URL.Path).To(Equal("/v1/chat/completions"))
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Incorrect API key"}}`))
+			return
+		}
+		var body struct {
+			Model    string
+			Messages []map[string]string
+		}
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		Expect(body.Model).To(Equal("small"))
+		Expect(body.Messages).To(Equal([]map[string]string{
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "Hello"},
+		}))
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": " Hi.\n"}}]}`))
+	}))
+	defer ts.Close()
+
+	b, err := llm.New("openai", ts.URL+"/", "small", "secret")
+	Expect(err).To(BeNil())
+	answer, err := b.Complete(context.Background(), "Be brief.", "Hello")
+	Expect(err).To(BeNil())
+	Expect(answer).To(Equal("Hi."))
+
+	b, err = llm.New("openai", ts.URL, "small", "wrong")
+	Expect(err).To(BeNil())
+	_, err = b.Complete(context.Background(), "Be brief.", "Hello")
+	Expect(err).To(MatchError(`llm: got response 401: {"error": {"message": "Incorrect API key"}}`))
+}
+
+func TestOllama(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.URL.Path).To(Equal("/api/chat"))
+		Expect(r.Header.Get("Authorization")).To(BeEmpty())
+		var body struct {
+			Model    string
+			Messages []map[string]string
+			Stream   *bool
+		}
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		Expect(body.Model).To(Equal("llama3"))
+		Expect(body.Messages).To(HaveLen(2))
+		Expect(body.Stream).NotTo(BeNil())
+		Expect(*body.Stream).To(BeFalse())
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "Hi."}, "done": true}`))
+	}))
+	defer ts.Close()
+
+	b, err := llm.New("ollama", ts.URL, "llama3", "ignored")
+	Expect(err).To(BeNil())
+	answer, err := b.Complete(context.Background(), "Be brief.", "Hello")
+	Expect(err).To(BeNil())
+	Expect(answer).To(Equal("Hi."))
+}
+
+func TestNew(t *testing.T) {
+	RegisterTestingT(t)
+
+	b, err := llm.New("", "", "gpt-4o-mini", "")
+	Expect(err).To(BeNil())
+	Expect(b.(*llm.OpenAI).Origin).To(Equal(llm.OpenAIOrigin))
+	b, err = llm.New("ollama", "", "llama3", "")
+	Expect(err).To(BeNil())
+	Expect(b.(*llm.Ollama).Origin).To(Equal(llm.OllamaOrigin))
+
+	_, err = llm.New("gpt", "", "x", "")
+	Expect(err).To(MatchError(`llm: unknown backend "gpt"; use "openai" or "ollama"`))
+	_, err = llm.New("ollama", "", "", "")
+	Expect(err).To(MatchError("llm: no model given"))
+}
+
+func TestEmbed(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string
+			Input []string
+		}
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		Expect(body.Input).To(Equal([]string{"go", "rust"}))
+		switch r.URL.Path {
+		case "/v1/embeddings":
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer secret"))
+			// In any order, by index
+			w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+		case "/api/embed":
+			w.Write([]byte(`{"embeddings": [[1, 0]]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	e, err := llm.NewEmbedder("openai", ts.URL, "small", "secret")
+	Expect(err).To(BeNil())
+	vectors, err := e.Embed(context.Background(), []string{"go", "rust"})
+	Expect(err).To(BeNil())
+	Expect(vectors).To(Equal([][]float32{{1, 0}, {0, 1}}))
+
+	e, err = llm.NewEmbedder("ollama", ts.URL, "nomic-embed-text", "")
+	Expect(err).To(BeNil())
+	_, err = e.Embed(context.Background(), []string{"go", "rust"})
+	Expect(err).To(MatchError("llm: got 1 embeddings from nomic-embed-text for 2 texts"))
+}
@@ -0,0 +1,331 @@
+// Package mailin receives email over SMTP and finds the URLs in it, for an
+// address forwarding to pocket.
+package mailin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultMaxSize is the largest message accepted unless MaxSize is set.
+const DefaultMaxSize = 10 << 20
+
+// Server is an SMTP server taking messages for Handle, meant to listen on
+// localhost for a mail forwarder such as fetchmail or a local MTA.
+type Server struct {
+	// Hostname is given in the greeting; "localhost" if empty.
+	Hostname string
+	// Handle is called with each message received, with the sender given
+	// by MAIL FROM. The message is refused if it returns an error.
+	Handle func(from string, msg *mail.Message) error
+	// MaxSize is the largest message accepted, in bytes.
+	MaxSize int64
+	// Timeout bounds each command and the message, five minutes by
+	// default.
+	Timeout time.Duration
+}
+
+// Serve accepts connections on l, each served in its own goroutine, until l
+// is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// ListenAndServe listens on addr and serves it.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// errTooLarge is read when a message is larger than MaxSize.
+var errTooLarge = errors.New("message too large")
+
+// limitReader reads up to max bytes of r, then fails with errTooLarge.
+type limitReader struct {
+	r   io.Reader
+	max int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.max -= int64(n)
+	if l.max < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	maxSize := s.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	text := textproto.NewConn(conn)
+	reply := func(code int, msg string) error {
+		return text.PrintfLine("%d %s", code, msg)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if reply(220, hostname+" ESMTP pocket") != nil {
+		return
+	}
+
+	var from string
+	var recipients int
+	for {
+		conn.SetDeadline(time.Now().Add(timeout))
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			err = reply(250, hostname)
+		case "EHLO":
+			if err = text.PrintfLine("250-%s", hostname); err == nil {
+				err = text.PrintfLine("250 SIZE %d", maxSize)
+			}
+		case "MAIL":
+			addr, ok := pathArg(arg, "FROM:")
+			if !ok {
+				err = reply(501, "Syntax: MAIL FROM:<address>")
+				break
+			}
+			from, recipients = addr, 0
+			err = reply(250, "OK")
+		case "RCPT":
+			if _, ok := pathArg(arg, "TO:"); !ok {
+				err = reply(501, "Syntax: RCPT TO:<address>")
+				break
+			}
+			recipients++
+			err = reply(250, "OK")
+		case "DATA":
+			if recipients == 0 {
+				err = reply(503, "RCPT first")
+				break
+			}
+			if err = reply(354, "End data with <CR><LF>.<CR><LF>"); err != nil {
+				break
+			}
+			err = s.receive(text, from, maxSize, reply)
+			from, recipients = "", 0
+		case "RSET":
+			from, recipients = "", 0
+			err = reply(250, "OK")
+		case "NOOP":
+			err = reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			err = reply(502, "Command not implemented")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// receive reads a message after DATA and hands it to Handle.
+func (s *Server) receive(text *textproto.Conn, from string, maxSize int64, reply func(int, string) error) error {
+	dot := text.DotReader()
+	body, err := io.ReadAll(&limitReader{r: dot, max: maxSize})
+	if errors.Is(err, errTooLarge) {
+		// The rest is read for the connection to go on
+		io.Copy(io.Discard, dot)
+		return reply(552, "Message too large")
+	}
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(body))
+	if err != nil {
+		return reply(554, "Malformed message: "+err.Error())
+	}
+	if s.Handle != nil {
+		if err := s.Handle(from, msg); err != nil {
+			slog.Warn("Could not handle a message", "from", from, "err", err)
+			return reply(451, "Could not handle the message; try again later")
+		}
+	}
+	return reply(250, "OK")
+}
+
+// pathArg returns the address of a MAIL FROM or RCPT TO argument, as in
+// "FROM:<a@example.com> SIZE=100", which may be empty, as for bounces.
+func pathArg(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", false
+	}
+	addr, _, ok := strings.Cut(arg[1:], ">")
+	return addr, ok
+}
+
+// urlPattern finds the URLs in text.
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// textURLs returns the URLs in text, without the punctuation that usually
+// follows a URL in a sentence.
+func textURLs(text string) []string {
+	urls := []string{}
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}")
+		if len(u) > len("https://") {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// htmlURLs returns the links of an HTML document, and the URLs in its text.
+func htmlURLs(r io.Reader) ([]string, error) {
+	urls := []string{}
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return urls, nil
+			}
+			return urls, z.Err()
+		case html.TextToken:
+			urls = append(urls, textURLs(string(z.Text()))...)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "a" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if string(key) == "href" && urlPattern.Match(val) {
+					urls = append(urls, string(val))
+				}
+			}
+		}
+	}
+}
+
+// decodeBody decodes a body in the transfer encoding given; base64 bodies
+// keep their line breaks, which the decoder skips.
+func decodeBody(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// partURLs returns the URLs of a part, of the type given, going into the
+// parts of multipart ones. Attachments are left out.
+func partURLs(header textproto.MIMEHeader, body io.Reader) ([]string, error) {
+	if disposition, _, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && disposition == "attachment" {
+		return nil, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	body = decodeBody(body, header.Get("Content-Transfer-Encoding"))
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		urls := []string{}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return urls, nil
+			}
+			if err != nil {
+				return urls, err
+			}
+			found, err := partURLs(part.Header, part)
+			if err != nil {
+				return urls, err
+			}
+			urls = append(urls, found...)
+		}
+	case mediaType == "text/html":
+		return htmlURLs(body)
+	case mediaType == "text/plain":
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return textURLs(string(b)), nil
+	}
+	return nil, nil
+}
+
+// URLs returns the URLs in the subject and the text of msg, each once, in
+// the order they come in. Both the plain text and the HTML of a message
+// having both are read, for the links only in the HTML.
+func URLs(msg *mail.Message) ([]string, error) {
+	decoder := &mime.WordDecoder{}
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	found := textURLs(subject)
+
+	body, err := partURLs(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading the message: %w", err)
+	}
+	found = append(found, body...)
+
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, u := range found {
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls, nil
+}
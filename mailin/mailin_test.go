@@ -0,0 +1,83 @@
+package mailin_test
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/mailin"
+	. "github.com/onsi/gomega"
+)
+
+const multipartMessage = `From: Someone <someone@example.com>
+To: pocket@localhost
+Subject: Read this: https://example.com/subject
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary="b"
+
+--b
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+See https://example.com/a-very-long-article-whose-url-is-broken-by-quoted-pr=
+intable, and https://example.com/subject.
+
+--b
+Content-Type: text/html; charset=utf-8
+
+<p><a href="https://example.com/linked?a=1&amp;b=2">A link</a> and https://example.com/in-text</p>
+--b
+Content-Type: text/plain
+Content-Disposition: attachment; filename="urls.txt"
+
+https://example.com/attached
+--b--
+`
+
+func TestURLs(t *testing.T) {
+	RegisterTestingT(t)
+
+	msg, err := mail.ReadMessage(strings.NewReader(multipartMessage))
+	Expect(err).To(BeNil())
+	Expect(mailin.URLs(msg)).To(Equal([]string{
+		"https://example.com/subject",
+		"https://example.com/a-very-long-article-whose-url-is-broken-by-quoted-printable",
+		"https://example.com/linked?a=1&b=2",
+		"https://example.com/in-text",
+	}))
+
+	msg, err = mail.ReadMessage(strings.NewReader("Subject: =?utf-8?q?Link?=\r\nContent-Transfer-Encoding: base64\r\n\r\naHR0cHM6Ly9leGFtcGxl\r\nLmNvbS9iYXNlNjQ=\r\n"))
+	Expect(err).To(BeNil())
+	Expect(mailin.URLs(msg)).To(Equal([]string{"https://example.com/base64"}))
+}
+
+func TestServer(t *testing.T) {
+	RegisterTestingT(t)
+
+	received := make(chan []string, 1)
+	from := make(chan string, 1)
+	srv := &mailin.Server{
+		Handle: func(sender string, msg *mail.Message) error {
+			urls, err := mailin.URLs(msg)
+			from <- sender
+			received <- urls
+			return err
+		},
+		MaxSize: 4096,
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	defer l.Close()
+	go srv.Serve(l)
+
+	body := "Subject: hi\r\n\r\nhttps://example.com/mailed\r\n.leading dot\r\n"
+	Expect(smtp.SendMail(l.Addr().String(), nil, "me@example.com", []string{"pocket@localhost"}, []byte(body))).To(Succeed())
+	Expect(<-from).To(Equal("me@example.com"))
+	Expect(<-received).To(Equal([]string{"https://example.com/mailed"}))
+
+	// Too large
+	err = smtp.SendMail(l.Addr().String(), nil, "me@example.com", []string{"pocket@localhost"}, []byte("Subject: big\r\n\r\n"+strings.Repeat("x", 5000)))
+	Expect(err).To(MatchError(ContainSubstring("552")))
+}
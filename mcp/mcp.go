@@ -0,0 +1,180 @@
+// Package mcp implements a minimal Model Context Protocol server over stdio,
+// exposing tools to AI assistants and editors through JSON-RPC 2.0.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the MCP revision implemented.
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a function the client can call.
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema is the JSON Schema of the arguments.
+	InputSchema map[string]interface{}
+	// Handler runs the tool with its arguments, returning text for the
+	// model. An error is reported to the model as a failed call rather than
+	// as a protocol error.
+	Handler func(arguments json.RawMessage) (string, error)
+}
+
+// Server answers MCP requests with its tools.
+type Server struct {
+	Name    string
+	Version string
+
+	tools []Tool
+}
+
+// NewServer creates a server with no tools.
+func NewServer(name, version string) *Server {
+	return &Server{Name: name, Version: version}
+}
+
+// AddTool registers a tool.
+func (s *Server) AddTool(tool Tool) {
+	s.tools = append(s.tools, tool)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// Serve reads newline-delimited requests from r and writes the responses to
+// w, until r is exhausted.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	enc := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		var res *response
+		if err := json.Unmarshal(line, &req); err != nil {
+			res = &response{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}}
+		} else {
+			res = s.handle(req)
+		}
+		if res == nil {
+			continue
+		}
+
+		res.JSONRPC = "2.0"
+		err := enc.Encode(res)
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handle answers a request, or returns nil for notifications.
+func (s *Server) handle(req request) *response {
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	res := &response{ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		res.Result = map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}
+	case "ping":
+		res.Result = map[string]interface{}{}
+	case "tools/list":
+		tools := []map[string]interface{}{}
+		for _, tool := range s.tools {
+			tools = append(tools, map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": tool.InputSchema,
+			})
+		}
+		res.Result = map[string]interface{}{"tools": tools}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			res.Error = &rpcError{codeInvalidParams, err.Error()}
+			break
+		}
+		result, err := s.call(params.Name, params.Arguments)
+		if err != nil {
+			res.Error = &rpcError{codeInvalidParams, err.Error()}
+			break
+		}
+		res.Result = result
+	default:
+		res.Error = &rpcError{codeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)}
+	}
+
+	return res
+}
+
+func (s *Server) call(name string, arguments json.RawMessage) (*toolResult, error) {
+	for _, tool := range s.tools {
+		if tool.Name != name {
+			continue
+		}
+
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		}
+		text, err := tool.Handler(arguments)
+		if err != nil {
+			return &toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}, nil
+		}
+		return &toolResult{Content: []textContent{{"text", text}}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown tool %q", name)
+}
@@ -0,0 +1,73 @@
+package mcp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/mcp"
+	. "github.com/onsi/gomega"
+)
+
+func TestServe(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := mcp.NewServer("pocket", "0.1")
+	s.AddTool(mcp.Tool{
+		Name:        "echo",
+		Description: "Echoes its input",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Handler: func(arguments json.RawMessage) (string, error) {
+			var args struct{ Text string }
+			json.Unmarshal(arguments, &args)
+			if args.Text == "" {
+				return "", errors.New("text is required")
+			}
+			return args.Text, nil
+		},
+	})
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"nope"}}`,
+		`{"jsonrpc":"2.0","id":"six","method":"resources/list"}`,
+		`not json`,
+	}, "\n")
+
+	var out bytes.Buffer
+	Expect(s.Serve(strings.NewReader(in), &out)).To(Succeed())
+
+	responses := []map[string]interface{}{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var res map[string]interface{}
+		Expect(dec.Decode(&res)).To(Succeed())
+		responses = append(responses, res)
+	}
+
+	// No response to the notification
+	Expect(responses).To(HaveLen(7))
+
+	Expect(responses[0]["result"]).To(HaveKeyWithValue("protocolVersion", mcp.ProtocolVersion))
+
+	tools := responses[1]["result"].(map[string]interface{})["tools"].([]interface{})
+	Expect(tools).To(HaveLen(1))
+	Expect(tools[0]).To(HaveKeyWithValue("name", "echo"))
+
+	Expect(responses[2]["result"]).To(Equal(map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": "hi"}},
+	}))
+	Expect(responses[3]["result"]).To(HaveKeyWithValue("isError", true))
+
+	Expect(responses[4]["error"]).To(HaveKeyWithValue("code", float64(-32602)))
+	Expect(responses[5]["id"]).To(Equal("six"))
+	Expect(responses[5]["error"]).To(HaveKeyWithValue("code", float64(-32601)))
+	Expect(responses[6]["id"]).To(BeNil())
+	Expect(responses[6]["error"]).To(HaveKeyWithValue("code", float64(-32700)))
+}
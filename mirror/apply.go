@@ -0,0 +1,163 @@
+package mirror
+
+import (
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// ApplyAction changes item the way Pocket would for action. It returns false
+// if the action deletes the item.
+func ApplyAction(item *api.Item, action *api.Action) bool {
+	t := api.Time{Time: time.Now()}
+	if action.Time > 0 {
+		t = api.Time{Time: time.Unix(action.Time, 0)}
+	}
+
+	tags := []string{}
+	for _, tag := range strings.Split(action.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	switch action.Action {
+	case "delete":
+		return false
+	case "archive":
+		item.Status = api.ItemStatusArchived
+		item.TimeRead = t
+	case "readd":
+		item.Status = api.ItemStatusUnread
+		item.TimeRead = api.Time{}
+	case "favorite":
+		item.Favorite = 1
+		item.TimeFavorited = t
+	case "unfavorite":
+		item.Favorite = 0
+		item.TimeFavorited = api.Time{}
+	case "tags_add":
+		if item.Tags == nil {
+			item.Tags = map[string]map[string]interface{}{}
+		}
+		for _, tag := range tags {
+			item.Tags[tag] = map[string]interface{}{"item_id": item.ItemID, "tag": tag}
+		}
+	case "tags_remove":
+		for _, tag := range tags {
+			delete(item.Tags, tag)
+		}
+	case "tags_replace":
+		item.Tags = map[string]map[string]interface{}{}
+		for _, tag := range tags {
+			item.Tags[tag] = map[string]interface{}{"item_id": item.ItemID, "tag": tag}
+		}
+	case "tags_clear":
+		item.Tags = nil
+	}
+
+	item.TimeUpdated = t
+	return true
+}
+
+// Enqueue applies actions to the items in the mirror right away and records
+// them in the mirror's queue, to be sent to Pocket by the next Push.
+// Actions without an item ID, such as adds, are only queued.
+func (m *Mirror) Enqueue(actions ...*api.Action) error {
+	now := time.Now().Unix()
+	for _, action := range actions {
+		if action.Time == 0 {
+			action.Time = now
+		}
+	}
+
+	items, err := m.Items()
+	if err != nil {
+		return err
+	}
+	byID := map[int]*api.Item{}
+	for i := range items {
+		byID[items[i].ItemID] = &items[i]
+	}
+
+	changed := map[int]*api.Item{}
+	deleted := []int{}
+	for _, action := range actions {
+		item, ok := byID[action.ItemID]
+		if !ok {
+			continue
+		}
+		if ApplyAction(item, action) {
+			changed[item.ItemID] = item
+		} else {
+			delete(byID, item.ItemID)
+			delete(changed, item.ItemID)
+			deleted = append(deleted, item.ItemID)
+		}
+	}
+
+	upserts := make([]api.Item, 0, len(changed))
+	for _, item := range changed {
+		upserts = append(upserts, *item)
+	}
+	if _, err := m.Store.Upsert(upserts); err != nil {
+		return err
+	}
+	if _, err := m.Store.Delete(deleted); err != nil {
+		return err
+	}
+
+	return m.Queue.Add(actions...)
+}
+
+// PushResult summarizes the queued actions sent by a push.
+type PushResult struct {
+	Sent int
+	// Dropped is the number of actions Pocket rejected, for example
+	// because the item has been deleted elsewhere in the meantime.
+	Dropped int
+}
+
+// Push sends the queued actions to Pocket in batches of batchSize. Actions
+// that Pocket rejects are dropped rather than retried: changes made on the
+// server win, in particular deletions. The next Sync brings the mirror back
+// in line with the server.
+func (m *Mirror) Push(client *api.Client, batchSize int) (*PushResult, error) {
+	result := &PushResult{}
+	if m.Queue == nil {
+		return result, nil
+	}
+
+	for {
+		actions, err := m.Queue.Pending()
+		if err != nil {
+			return result, err
+		}
+		if len(actions) == 0 {
+			return result, nil
+		}
+		if m.interrupted() {
+			return result, ErrInterrupted
+		}
+		if len(actions) > batchSize {
+			actions = actions[:batchSize]
+		}
+
+		res, err := client.Modify(actions...)
+		if err != nil {
+			return result, err
+		}
+		for i := range actions {
+			if i < len(res.ActionDetails) && res.ActionDetails[i].Success {
+				result.Sent++
+			} else {
+				result.Dropped++
+			}
+		}
+
+		if err := m.Queue.Remove(len(actions)); err != nil {
+			return result, err
+		}
+	}
+}
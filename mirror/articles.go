@@ -0,0 +1,338 @@
+package mirror
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+)
+
+// DefaultArticleCacheSize is the default size limit of an ArticleCache, in
+// bytes.
+const DefaultArticleCacheSize = 100 << 20
+
+// ArticleCache stores the parsed text of articles for offline reading, one
+// JSON file per item in a directory. When the cache grows over MaxBytes, the
+// least recently read articles are evicted.
+type ArticleCache struct {
+	Dir      string
+	MaxBytes int64
+	// Cipher, if set, encrypts the articles stored.
+	Cipher Cipher
+	// Keep, if set, reports whether the article of an item is still wanted,
+	// as those of unread items are. Articles not wanted are evicted first.
+	Keep func(itemID int) bool
+}
+
+// NewArticleCache creates a cache in dir. If maxBytes is zero,
+// DefaultArticleCacheSize is used.
+func NewArticleCache(dir string, maxBytes int64) *ArticleCache {
+	if maxBytes == 0 {
+		maxBytes = DefaultArticleCacheSize
+	}
+	return &ArticleCache{Dir: dir, MaxBytes: maxBytes}
+}
+
+func (c *ArticleCache) path(itemID int) string {
+	return filepath.Join(c.Dir, strconv.Itoa(itemID)+".json")
+}
+
+// Has reports whether the article of the item is cached.
+func (c *ArticleCache) Has(itemID int) bool {
+	_, err := os.Stat(c.path(itemID))
+	return err == nil
+}
+
+// Peek returns the cached article of the item without affecting eviction.
+// If it is not cached, the error satisfies os.IsNotExist.
+func (c *ArticleCache) Peek(itemID int) (*api.Article, error) {
+	data, err := os.ReadFile(c.path(itemID))
+	if err != nil {
+		return nil, err
+	}
+	data, err = Unseal(c.Cipher, data)
+	if err != nil {
+		return nil, err
+	}
+
+	article := &api.Article{}
+	err = json.Unmarshal(data, article)
+	if err != nil {
+		return nil, err
+	}
+
+	return article, nil
+}
+
+// Get is like Peek, but also marks the article as recently read.
+func (c *ArticleCache) Get(itemID int) (*api.Article, error) {
+	article, err := c.Peek(itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = os.Chtimes(c.path(itemID), now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return article, nil
+}
+
+// Put stores the article of the item, then evicts articles as needed to stay
+// within MaxBytes.
+func (c *ArticleCache) Put(itemID int, article *api.Article) error {
+	err := os.MkdirAll(c.Dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(article)
+	if err != nil {
+		return err
+	}
+	data, err = Seal(c.Cipher, data)
+	if err != nil {
+		return err
+	}
+
+	err = atomicfile.WriteFile(c.path(itemID), data, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Trim()
+	return err
+}
+
+type cachedArticle struct {
+	itemID  int
+	size    int64
+	modTime time.Time
+}
+
+func (c *ArticleCache) entries() ([]cachedArticle, error) {
+	dirEntries, err := os.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []cachedArticle{}
+	for _, e := range dirEntries {
+		id, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, cachedArticle{itemID: id, size: info.Size(), modTime: info.ModTime()})
+	}
+
+	return entries, nil
+}
+
+// Trim evicts articles until the cache is within MaxBytes: first those Keep
+// reports as no longer wanted, then the least recently read. Put calls it,
+// but it is also needed after MaxBytes is lowered or Keep changes. It
+// returns how many articles were evicted.
+func (c *ArticleCache) Trim() (evicted int, err error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	if total <= c.MaxBytes {
+		return 0, nil
+	}
+
+	wanted := map[int]bool{}
+	for _, e := range entries {
+		wanted[e.itemID] = c.Keep == nil || c.Keep(e.itemID)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if wanted[entries[i].itemID] != wanted[entries[j].itemID] {
+			return !wanted[entries[i].itemID]
+		}
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		err := os.Remove(c.path(e.itemID))
+		if err != nil {
+			return evicted, err
+		}
+		total -= e.size
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+// Rewrite writes all cached articles again, encrypting those written before
+// Cipher was set, and keeping the order in which they are evicted. It
+// returns how many there are.
+func (c *ArticleCache) Rewrite() (int, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range entries {
+		data, err := os.ReadFile(c.path(e.itemID))
+		if err != nil {
+			return i, err
+		}
+		data, err = Unseal(c.Cipher, data)
+		if err != nil {
+			return i, err
+		}
+		data, err = Seal(c.Cipher, data)
+		if err != nil {
+			return i, err
+		}
+		err = atomicfile.WriteFile(c.path(e.itemID), data, 0600)
+		if err != nil {
+			return i, err
+		}
+		err = os.Chtimes(c.path(e.itemID), e.modTime, e.modTime)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+// Prune removes the articles of items for which keep returns false.
+func (c *ArticleCache) Prune(keep func(itemID int) bool) (removed int, err error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		if keep(e.itemID) {
+			continue
+		}
+		err := os.Remove(c.path(e.itemID))
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// ArticleCacheStatus summarizes the contents of an ArticleCache.
+type ArticleCacheStatus struct {
+	Articles int   `json:"articles"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// Status returns the number and total size of the cached articles.
+func (c *ArticleCache) Status() (*ArticleCacheStatus, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ArticleCacheStatus{Articles: len(entries), MaxBytes: c.MaxBytes}
+	for _, e := range entries {
+		status.Bytes += e.size
+	}
+
+	return status, nil
+}
+
+// Clear removes all cached articles.
+func (c *ArticleCache) Clear() error {
+	_, err := c.Prune(func(int) bool { return false })
+	return err
+}
+
+// FetchArticlesResult summarizes a FetchArticles call.
+type FetchArticlesResult struct {
+	Fetched int
+	Failed  int
+	Pruned  int
+	// Full is true if fetching stopped because the cache reached its size
+	// limit.
+	Full bool
+}
+
+// FetchArticles caches the article text of the unread items in the mirror,
+// newest first, and removes the articles of items that are no longer
+// unread. Items whose article cannot be fetched are skipped. Fetching stops
+// once the cache is full, so that it does not evict what it has just fetched.
+func (m *Mirror) FetchArticles(client *api.Client, cache *ArticleCache) (*FetchArticlesResult, error) {
+	items, err := m.Retrieve(&api.RetrieveOption{State: api.StateUnread, Sort: api.SortNewest})
+	if err != nil {
+		return nil, err
+	}
+
+	unread := map[int]bool{}
+	for _, item := range items {
+		unread[item.ItemID] = true
+	}
+
+	result := &FetchArticlesResult{}
+	result.Pruned, err = cache.Prune(func(itemID int) bool { return unread[itemID] })
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := cache.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if m.interrupted() {
+			return result, ErrInterrupted
+		}
+		if status.Bytes >= cache.MaxBytes {
+			result.Full = true
+			break
+		}
+		if cache.Has(item.ItemID) {
+			continue
+		}
+
+		article, err := client.Article(item.URL())
+		if err != nil {
+			result.Failed++
+			continue
+		}
+
+		err = cache.Put(item.ItemID, article)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(cache.path(item.ItemID)); err == nil {
+			status.Bytes += info.Size()
+		}
+		result.Fetched++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,150 @@
+package mirror
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+var (
+	itemsBucket = []byte("items")
+	metaBucket  = []byte("meta")
+	sinceKey    = []byte("since")
+)
+
+// BoltStore is a Store in a bbolt database.
+type BoltStore struct {
+	// Cipher, if set, encrypts the items stored.
+	Cipher Cipher
+
+	db *bolt.DB
+}
+
+// OpenBoltStore opens the bbolt database at path, creating it if necessary.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{itemsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func itemKey(itemID int) []byte {
+	return []byte(strconv.Itoa(itemID))
+}
+
+// Upsert implements Store.
+func (s *BoltStore) Upsert(items []api.Item) (int, error) {
+	added := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(itemsBucket)
+		for _, item := range items {
+			key := itemKey(item.ItemID)
+			if b.Get(key) == nil {
+				added++
+			}
+
+			v, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			v, err = Seal(s.Cipher, v)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
+// Delete implements Store.
+func (s *BoltStore) Delete(itemIDs []int) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(itemsBucket)
+		for _, id := range itemIDs {
+			key := itemKey(id)
+			if b.Get(key) == nil {
+				continue
+			}
+			deleted++
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// Query implements Store.
+func (s *BoltStore) Query(options *api.RetrieveOption) ([]api.Item, error) {
+	items := []api.Item{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(itemsBucket).ForEach(func(k, v []byte) error {
+			v, err := Unseal(s.Cipher, v)
+			if err != nil {
+				return err
+			}
+			var item api.Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Query(items, options), nil
+}
+
+// Since implements Store.
+func (s *BoltStore) Since() (int, error) {
+	since := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(metaBucket).Get(sinceKey)
+		if v == nil {
+			return nil
+		}
+		var err error
+		since, err = strconv.Atoi(string(v))
+		return err
+	})
+	return since, err
+}
+
+// SetSince implements Store.
+func (s *BoltStore) SetSince(since int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(sinceKey, []byte(strconv.Itoa(since)))
+	})
+}
+
+// Close implements Store.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
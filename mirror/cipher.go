@@ -0,0 +1,93 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Cipher encrypts what stores and article caches write to disk, when they
+// are given one. NewAESCipher provides one; others, backed by a hardware
+// key for example, can be plugged in the same way.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// ErrEncrypted is returned when reading data encrypted with a Cipher
+// without one.
+var ErrEncrypted = errors.New("the data is encrypted and no key was given to read it")
+
+// sealedPrefix marks the data encrypted by Seal. Neither JSON nor anything
+// else the package writes starts with a NUL byte.
+var sealedPrefix = []byte("\x00pocket-sealed\x00")
+
+// Seal encrypts data with c, marking it as encrypted. If c is nil, data is
+// returned as is.
+func Seal(c Cipher, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	sealed, err := c.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, sealedPrefix...), sealed...), nil
+}
+
+// Unseal decrypts data sealed with c. Data not sealed, as written before
+// encryption was turned on, is returned as is, so that it can still be read
+// until it is written again.
+func Unseal(c Cipher, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, sealedPrefix) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrEncrypted
+	}
+	return c.Decrypt(data[len(sealedPrefix):])
+}
+
+type aesCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESCipher returns a Cipher encrypting with AES-256 in GCM mode, which
+// also detects data that was tampered with. key must be 32 bytes long.
+func NewAESCipher(key []byte) (Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the key is %d bytes long rather than 32", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesCipher{aead: aead}, nil
+}
+
+func (c *aesCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *aesCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("the encrypted data is truncated")
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+	if err != nil {
+		return nil, errors.New("the encrypted data cannot be decrypted with this key")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,129 @@
+package mirror_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	. "github.com/onsi/gomega"
+)
+
+func TestCipher(t *testing.T) {
+	RegisterTestingT(t)
+
+	c, err := mirror.NewAESCipher(bytes.Repeat([]byte{1}, 32))
+	Expect(err).To(BeNil())
+	other, err := mirror.NewAESCipher(bytes.Repeat([]byte{2}, 32))
+	Expect(err).To(BeNil())
+	_, err = mirror.NewAESCipher([]byte("short"))
+	Expect(err).NotTo(BeNil())
+
+	sealed, err := mirror.Seal(c, []byte(`{"secret":1}`))
+	Expect(err).To(BeNil())
+	Expect(string(sealed)).NotTo(ContainSubstring("secret"))
+	data, err := mirror.Unseal(c, sealed)
+	Expect(err).To(BeNil())
+	Expect(string(data)).To(Equal(`{"secret":1}`))
+
+	_, err = mirror.Unseal(nil, sealed)
+	Expect(err).To(Equal(mirror.ErrEncrypted))
+	_, err = mirror.Unseal(other, sealed)
+	Expect(err).NotTo(BeNil())
+
+	// Data from before encryption was turned on is read as is
+	data, err = mirror.Unseal(c, []byte(`{"plain":1}`))
+	Expect(err).To(BeNil())
+	Expect(string(data)).To(Equal(`{"plain":1}`))
+}
+
+func TestEncryptedStores(t *testing.T) {
+	RegisterTestingT(t)
+
+	c, err := mirror.NewAESCipher(bytes.Repeat([]byte{1}, 32))
+	Expect(err).To(BeNil())
+	item := api.Item{ItemID: 1, ResolvedTitle: "A secret title"}
+
+	dir := t.TempDir()
+	stores := []struct {
+		ext  string
+		open func(path string, c mirror.Cipher) (mirror.Store, error)
+	}{
+		{".db", func(path string, c mirror.Cipher) (mirror.Store, error) {
+			s, err := mirror.OpenBoltStore(path)
+			if err == nil {
+				s.Cipher = c
+			}
+			return s, err
+		}},
+		{".json", func(path string, c mirror.Cipher) (mirror.Store, error) {
+			s, err := mirror.OpenJSONStore(path)
+			if err == nil {
+				s.Cipher = c
+			}
+			return s, err
+		}},
+	}
+
+	for _, s := range stores {
+		plainPath := filepath.Join(dir, "plain"+s.ext)
+		path := filepath.Join(dir, "mirror"+s.ext)
+
+		// Written in the clear, and still read once encryption is on
+		plain, err := s.open(plainPath, nil)
+		Expect(err).To(BeNil())
+		_, err = plain.Upsert([]api.Item{item})
+		Expect(err).To(BeNil())
+		Expect(plain.SetSince(100)).To(Succeed())
+		Expect(plain.Close()).To(Succeed())
+		plain, err = s.open(plainPath, c)
+		Expect(err).To(BeNil())
+
+		// Copied to a store encrypting it
+		store, err := s.open(path, c)
+		Expect(err).To(BeNil())
+		Expect(mirror.CopyStore(store, plain)).To(Succeed())
+		Expect(plain.Close()).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		b, err := os.ReadFile(path)
+		Expect(err).To(BeNil())
+		Expect(string(b)).NotTo(ContainSubstring("secret"), path)
+
+		store, err = s.open(path, nil)
+		Expect(err).To(BeNil())
+		_, err = store.Query(&api.RetrieveOption{})
+		Expect(errors.Is(err, mirror.ErrEncrypted)).To(BeTrue(), path)
+		Expect(store.Close()).To(Succeed())
+
+		store, err = s.open(path, c)
+		Expect(err).To(BeNil())
+		items, err := store.Query(&api.RetrieveOption{})
+		Expect(err).To(BeNil())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Title()).To(Equal("A secret title"))
+		Expect(store.Since()).To(Equal(100))
+		Expect(store.Close()).To(Succeed())
+	}
+
+	cache := mirror.NewArticleCache(filepath.Join(dir, "articles"), 0)
+	Expect(cache.Put(1, &api.Article{Title: "A secret article"})).To(Succeed())
+	cache.Cipher = c
+	Expect(cache.Put(2, &api.Article{Title: "Another secret article"})).To(Succeed())
+	Expect(cache.Rewrite()).To(Equal(2))
+	for _, name := range []string{"1.json", "2.json"} {
+		b, err := os.ReadFile(filepath.Join(dir, "articles", name))
+		Expect(err).To(BeNil())
+		Expect(string(b)).NotTo(ContainSubstring("secret"))
+	}
+	article, err := cache.Get(1)
+	Expect(err).To(BeNil())
+	Expect(article.Title).To(Equal("A secret article"))
+
+	cache.Cipher = nil
+	_, err = cache.Get(2)
+	Expect(err).To(Equal(mirror.ErrEncrypted))
+}
@@ -0,0 +1,164 @@
+package mirror
+
+import (
+	"sort"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// ChangeKind is the kind of change made to an item.
+type ChangeKind string
+
+const (
+	ChangeAdded       ChangeKind = "added"
+	ChangeDeleted     ChangeKind = "deleted"
+	ChangeArchived    ChangeKind = "archived"
+	ChangeUnarchived  ChangeKind = "unarchived"
+	ChangeFavorited   ChangeKind = "favorited"
+	ChangeUnfavorited ChangeKind = "unfavorited"
+	ChangeTagsChanged ChangeKind = "tags_changed"
+	ChangeUpdated     ChangeKind = "updated"
+)
+
+// Change describes one change between two versions of an item.
+type Change struct {
+	Kind   ChangeKind `json:"kind"`
+	ItemID int        `json:"item_id"`
+	Title  string     `json:"title"`
+	URL    string     `json:"url"`
+
+	// For ChangeTagsChanged
+	TagsAdded   []string `json:"tags_added,omitempty"`
+	TagsRemoved []string `json:"tags_removed,omitempty"`
+}
+
+// Compare returns the changes between the old version of an item, which is
+// nil if the item is new, and the current one. A current item with status
+// deleted is reported as deleted.
+func Compare(old *api.Item, current api.Item) []Change {
+	if current.Status == api.ItemStatusDeleted {
+		if old == nil {
+			return nil
+		}
+		return []Change{{Kind: ChangeDeleted, ItemID: old.ItemID, Title: old.Title(), URL: old.URL()}}
+	}
+
+	change := func(kind ChangeKind) Change {
+		return Change{Kind: kind, ItemID: current.ItemID, Title: current.Title(), URL: current.URL()}
+	}
+
+	if old == nil {
+		return []Change{change(ChangeAdded)}
+	}
+
+	changes := []Change{}
+	if old.Status != current.Status {
+		if current.Status == api.ItemStatusArchived {
+			changes = append(changes, change(ChangeArchived))
+		} else {
+			changes = append(changes, change(ChangeUnarchived))
+		}
+	}
+
+	if old.Favorite != current.Favorite {
+		if current.Favorite == 1 {
+			changes = append(changes, change(ChangeFavorited))
+		} else {
+			changes = append(changes, change(ChangeUnfavorited))
+		}
+	}
+
+	c := change(ChangeTagsChanged)
+	for tag := range current.Tags {
+		if _, ok := old.Tags[tag]; !ok {
+			c.TagsAdded = append(c.TagsAdded, tag)
+		}
+	}
+	for tag := range old.Tags {
+		if _, ok := current.Tags[tag]; !ok {
+			c.TagsRemoved = append(c.TagsRemoved, tag)
+		}
+	}
+	if len(c.TagsAdded)+len(c.TagsRemoved) > 0 {
+		sort.Strings(c.TagsAdded)
+		sort.Strings(c.TagsRemoved)
+		changes = append(changes, c)
+	}
+
+	if old.Title() != current.Title() || old.URL() != current.URL() {
+		changes = append(changes, change(ChangeUpdated))
+	}
+
+	return changes
+}
+
+// fetchChanges retrieves the items changed on the server since the given
+// timestamp, returning them with the new timestamp.
+func fetchChanges(client *api.Client, since int) (map[string]api.Item, int, error) {
+	list := map[string]api.Item{}
+	newSince := 0
+	for offset := 0; ; offset += PageSize {
+		res, err := client.Retrieve(&api.RetrieveOption{
+			State:       api.StateAll,
+			DetailType:  api.DetailTypeComplete,
+			Annotations: true,
+			Since:       since,
+			Count:       PageSize,
+			Offset:      offset,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if newSince == 0 {
+			newSince = res.Since
+		}
+
+		for key, item := range res.List {
+			list[key] = item
+		}
+
+		if len(res.List) < PageSize {
+			return list, newSince, nil
+		}
+	}
+}
+
+// changesAgainst compares the fetched items to the mirror's items.
+func (m *Mirror) changesAgainst(list map[string]api.Item) ([]Change, error) {
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]*api.Item{}
+	for i := range items {
+		byID[items[i].ItemID] = &items[i]
+	}
+
+	current := make([]api.Item, 0, len(list))
+	for _, item := range list {
+		current = append(current, item)
+	}
+	sort.Slice(current, func(i, j int) bool { return current[i].ItemID < current[j].ItemID })
+
+	changes := []Change{}
+	for _, item := range current {
+		changes = append(changes, Compare(byID[item.ItemID], item)...)
+	}
+	return changes, nil
+}
+
+// Diff returns the changes made on the server since the last sync, without
+// applying them to the mirror.
+func (m *Mirror) Diff(client *api.Client) ([]Change, error) {
+	since, err := m.Store.Since()
+	if err != nil {
+		return nil, err
+	}
+
+	list, _, err := fetchChanges(client, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.changesAgainst(list)
+}
@@ -0,0 +1,196 @@
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+)
+
+// JSONStore is a Store kept in memory and saved to a single JSON file after
+// every change. It suits small accounts and is easy to inspect by hand,
+// unless it is encrypted.
+type JSONStore struct {
+	// Cipher, if set, encrypts the file. A file encrypted already is only
+	// read once the store is first used, so that Cipher can be set after
+	// opening it.
+	Cipher Cipher
+
+	path string
+
+	mu     sync.Mutex
+	data   jsonStoreData
+	sealed []byte
+}
+
+type jsonStoreData struct {
+	Since int                 `json:"since"`
+	Items map[string]api.Item `json:"items"`
+}
+
+// OpenJSONStore loads the JSON file at path, which is created on the first
+// change if it does not exist.
+func OpenJSONStore(path string) (*JSONStore, error) {
+	s := &JSONStore{
+		path: path,
+		data: jsonStoreData{Items: map[string]api.Item{}},
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(b, sealedPrefix) {
+		s.sealed = b
+		return s, nil
+	}
+	err = s.decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONStore) decode(b []byte) error {
+	err := json.Unmarshal(b, &s.data)
+	if err != nil {
+		return err
+	}
+	if s.data.Items == nil {
+		s.data.Items = map[string]api.Item{}
+	}
+	return nil
+}
+
+// load decrypts the file read by OpenJSONStore if it is encrypted. It is
+// called with mu held by every method.
+func (s *JSONStore) load() error {
+	if s.sealed == nil {
+		return nil
+	}
+	b, err := Unseal(s.Cipher, s.sealed)
+	if err != nil {
+		return err
+	}
+	err = s.decode(b)
+	if err != nil {
+		return err
+	}
+	s.sealed = nil
+	return nil
+}
+
+// save writes the data to a temporary file first, so that a crash never
+// leaves a truncated store behind.
+func (s *JSONStore) save() error {
+	b, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	b, err = Seal(s.Cipher, append(b, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(s.path, b, 0600)
+}
+
+// Upsert implements Store.
+func (s *JSONStore) Upsert(items []api.Item) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, item := range items {
+		key := strconv.Itoa(item.ItemID)
+		if _, ok := s.data.Items[key]; !ok {
+			added++
+		}
+		s.data.Items[key] = item
+	}
+
+	if len(items) == 0 {
+		return 0, nil
+	}
+	return added, s.save()
+}
+
+// Delete implements Store.
+func (s *JSONStore) Delete(itemIDs []int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range itemIDs {
+		key := strconv.Itoa(id)
+		if _, ok := s.data.Items[key]; ok {
+			deleted++
+			delete(s.data.Items, key)
+		}
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, s.save()
+}
+
+// Query implements Store.
+func (s *JSONStore) Query(options *api.RetrieveOption) ([]api.Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	items := make([]api.Item, 0, len(s.data.Items))
+	for _, item := range s.data.Items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ItemID < items[j].ItemID })
+
+	return Query(items, options), nil
+}
+
+// Since implements Store.
+func (s *JSONStore) Since() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	return s.data.Since, nil
+}
+
+// SetSince implements Store.
+func (s *JSONStore) SetSince(since int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.data.Since = since
+	return s.save()
+}
+
+// Close implements Store.
+func (s *JSONStore) Close() error {
+	return nil
+}
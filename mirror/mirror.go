@@ -0,0 +1,195 @@
+// Package mirror maintains a local copy of a Pocket account, kept up to date
+// incrementally with the retrieve API's since parameter.
+package mirror
+
+import (
+	"errors"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// ErrInterrupted is returned by Push, FetchArticles, and BackfillWordCounts
+// when they stop early because Interrupted reported true.
+var ErrInterrupted = errors.New("interrupted")
+
+// PageSize is the number of items requested per retrieve call while syncing.
+var PageSize = 500
+
+// Store persists the items of a mirror. Implementations are provided for
+// bbolt (BoltStore), a JSON file (JSONStore), and SQLite through
+// database/sql (SQLStore).
+type Store interface {
+	// Upsert inserts or replaces items, returning how many of them were new.
+	Upsert(items []api.Item) (added int, err error)
+	// Delete removes the items with the given IDs, returning how many of
+	// them existed.
+	Delete(itemIDs []int) (deleted int, err error)
+	// Query returns the items matching options, as described by Query.
+	Query(options *api.RetrieveOption) ([]api.Item, error)
+	// Since returns the timestamp of the last sync, or zero if the store
+	// has never been synced.
+	Since() (int, error)
+	// SetSince records the timestamp of a sync.
+	SetSince(since int) error
+	// Close releases the store's resources.
+	Close() error
+}
+
+// CopyStore copies the items of src, and the time of its last sync, to dst.
+// Copying a store to a new one with a Cipher encrypts it, without leaving
+// the items in the clear in the free space of the old one, as rewriting
+// them in place would.
+func CopyStore(dst, src Store) error {
+	items, err := src.Query(&api.RetrieveOption{State: api.StateAll})
+	if err != nil {
+		return err
+	}
+	since, err := src.Since()
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Upsert(items)
+	if err != nil {
+		return err
+	}
+	return dst.SetSince(since)
+}
+
+// Mirror is a local copy of a Pocket account.
+type Mirror struct {
+	Store Store
+
+	// Queue holds the actions made locally that are yet to be pushed to
+	// Pocket. It is required by Enqueue.
+	Queue *Queue
+
+	// OnChange, if set, is called by Sync for every change made on the
+	// server since the previous sync, before the change is applied. It is
+	// called from the goroutine running Sync; to consume changes from a
+	// channel, send them to the channel from OnChange.
+	OnChange func(Change)
+
+	// Interrupted, if set, is checked by Push before each batch and by
+	// FetchArticles and BackfillWordCounts before each article. Once it
+	// returns true, they stop with ErrInterrupted, leaving the rest for the
+	// next call.
+	Interrupted func() bool
+}
+
+// New creates a mirror backed by store.
+func New(store Store) *Mirror {
+	return &Mirror{Store: store}
+}
+
+// Open opens the mirror stored in the bbolt database at path, creating it if
+// necessary.
+func Open(path string) (*Mirror, error) {
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(store), nil
+}
+
+func (m *Mirror) interrupted() bool {
+	return m.Interrupted != nil && m.Interrupted()
+}
+
+// Close closes the store.
+func (m *Mirror) Close() error {
+	return m.Store.Close()
+}
+
+// Since returns the timestamp of the last sync, or zero if the mirror has
+// never been synced.
+func (m *Mirror) Since() (int, error) {
+	return m.Store.Since()
+}
+
+// Items returns all items in the mirror.
+func (m *Mirror) Items() ([]api.Item, error) {
+	return m.Store.Query(&api.RetrieveOption{State: api.StateAll})
+}
+
+// Retrieve returns the items in the mirror matching options, like the
+// retrieve API does.
+func (m *Mirror) Retrieve(options *api.RetrieveOption) ([]api.Item, error) {
+	return m.Store.Query(options)
+}
+
+// SyncResult summarizes the changes applied by a sync.
+type SyncResult struct {
+	// Full is true if the whole account was downloaded.
+	Full    bool
+	Added   int
+	Updated int
+	Deleted int
+}
+
+// Sync downloads the changes made since the last sync and applies them to
+// the mirror. The first sync downloads the whole account.
+func (m *Mirror) Sync(client *api.Client) (*SyncResult, error) {
+	since, err := m.Store.Since()
+	if err != nil {
+		return nil, err
+	}
+
+	list, newSince, err := fetchChanges(client, since)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.OnChange != nil {
+		changes, err := m.changesAgainst(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			m.OnChange(c)
+		}
+	}
+
+	result := &SyncResult{Full: since == 0}
+	err = m.apply(list, result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Store.SetSince(newSince)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *Mirror) apply(list map[string]api.Item, result *SyncResult) error {
+	upserts := []api.Item{}
+	deletes := []int{}
+	for _, item := range list {
+		if item.Status == api.ItemStatusDeleted {
+			deletes = append(deletes, item.ItemID)
+		} else {
+			upserts = append(upserts, item)
+		}
+	}
+
+	if err := m.keepWordCounts(upserts); err != nil {
+		return err
+	}
+	added, err := m.Store.Upsert(upserts)
+	if err != nil {
+		return err
+	}
+	result.Added += added
+	result.Updated += len(upserts) - added
+
+	deleted, err := m.Store.Delete(deletes)
+	if err != nil {
+		return err
+	}
+	result.Deleted += deleted
+
+	return nil
+}
@@ -0,0 +1,481 @@
+package mirror_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	. "github.com/onsi/gomega"
+)
+
+func TestSync(t *testing.T) {
+	RegisterTestingT(t)
+
+	bolt, err := mirror.OpenBoltStore(filepath.Join(t.TempDir(), "mirror.db"))
+	Expect(err).To(BeNil())
+	testSync(t, bolt)
+
+	path := filepath.Join(t.TempDir(), "mirror.json")
+	js, err := mirror.OpenJSONStore(path)
+	Expect(err).To(BeNil())
+	testSync(t, js)
+
+	// The JSON store is reloaded from disk
+	js, err = mirror.OpenJSONStore(path)
+	Expect(err).To(BeNil())
+	since, err := js.Since()
+	Expect(err).To(BeNil())
+	Expect(since).To(Equal(300))
+	items, err := js.Query(&api.RetrieveOption{State: api.StateAll})
+	Expect(err).To(BeNil())
+	Expect(items).To(HaveLen(2))
+}
+
+func testSync(t *testing.T, store mirror.Store) {
+
+	responses := []string{
+		`{"status":1,"since":100,"list":{
+			"1":{"item_id":"1","resolved_url":"https://example.com/a","resolved_title":"A","status":"0","time_added":"10"},
+			"2":{"item_id":"2","resolved_url":"https://example.com/b","resolved_title":"B","status":"1","time_added":"20"}}}`,
+		`{"status":1,"since":200,"list":{
+			"1":{"item_id":"1","status":"2"},
+			"3":{"item_id":"3","resolved_url":"https://www.example.org/c","resolved_title":"C","status":"0","time_added":"30","tags":{"go":{"tag":"go"}}}}}`,
+		`{"status":2,"since":300,"list":[]}`,
+	}
+	sinces := []int{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Since int `json:"since"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		sinces = append(sinces, req.Since)
+
+		w.Write([]byte(responses[0]))
+		responses = responses[1:]
+	}))
+	defer ts.Close()
+
+	api.Origin = ts.URL
+	client := api.NewClient("key", "token")
+
+	m := mirror.New(store)
+	defer m.Close()
+
+	res, err := m.Sync(client)
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(mirror.SyncResult{Full: true, Added: 2}))
+
+	changes := []mirror.Change{}
+	m.OnChange = func(c mirror.Change) { changes = append(changes, c) }
+
+	res, err = m.Sync(client)
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(mirror.SyncResult{Added: 1, Deleted: 1}))
+	Expect(changes).To(Equal([]mirror.Change{
+		{Kind: mirror.ChangeDeleted, ItemID: 1, Title: "A", URL: "https://example.com/a"},
+		{Kind: mirror.ChangeAdded, ItemID: 3, Title: "C", URL: "https://www.example.org/c"},
+	}))
+
+	res, err = m.Sync(client)
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(mirror.SyncResult{}))
+
+	Expect(sinces).To(Equal([]int{0, 100, 200}))
+
+	items, err := m.Retrieve(&api.RetrieveOption{State: api.StateAll})
+	Expect(err).To(BeNil())
+	Expect(items).To(HaveLen(2))
+	Expect(items[0].ItemID).To(Equal(3))
+	Expect(items[1].ItemID).To(Equal(2))
+
+	items, err = m.Retrieve(&api.RetrieveOption{Domain: "example.org", Tag: "go"})
+	Expect(err).To(BeNil())
+	Expect(items).To(HaveLen(1))
+	Expect(items[0].Title()).To(Equal("C"))
+	Expect(items[0].TimeAdded.Unix()).To(Equal(int64(30)))
+
+	items, err = m.Retrieve(&api.RetrieveOption{})
+	Expect(err).To(BeNil())
+	Expect(items).To(HaveLen(1))
+}
+
+func TestEnqueueAndPush(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	store, err := mirror.OpenJSONStore(filepath.Join(dir, "mirror.json"))
+	Expect(err).To(BeNil())
+	_, err = store.Upsert([]api.Item{{ItemID: 1}, {ItemID: 2}, {ItemID: 3}})
+	Expect(err).To(BeNil())
+
+	m := mirror.New(store)
+	m.Queue = mirror.NewQueue(filepath.Join(dir, "queue.jsonl"))
+
+	Expect(m.Enqueue(api.NewArchiveAction(1), api.NewDeleteAction(2), api.NewTagsAddAction(3, "a", "b"))).To(Succeed())
+
+	items, err := m.Retrieve(&api.RetrieveOption{State: api.StateAll})
+	Expect(err).To(BeNil())
+	Expect(items).To(HaveLen(2))
+	byID := map[int]api.Item{}
+	for _, item := range items {
+		byID[item.ItemID] = item
+	}
+	Expect(byID[1].Status).To(Equal(api.ItemStatus(api.ItemStatusArchived)))
+	Expect(byID[3].TagNames()).To(Equal([]string{"a", "b"}))
+
+	pending, err := m.Queue.Pending()
+	Expect(err).To(BeNil())
+	Expect(pending).To(HaveLen(3))
+	Expect(pending[0].Time).NotTo(BeZero())
+
+	sent := [][]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Actions []*api.Action `json:"actions"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		names := []string{}
+		for _, a := range req.Actions {
+			names = append(names, a.Action)
+		}
+		sent = append(sent, names)
+
+		// The item deleted elsewhere can't be tagged
+		results := []bool{}
+		for _, a := range req.Actions {
+			results = append(results, a.Action != "tags_add")
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": 1, "action_results": results})
+	}))
+	defer ts.Close()
+
+	api.Origin = ts.URL
+	res, err := m.Push(api.NewClient("key", "token"), 2)
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(mirror.PushResult{Sent: 2, Dropped: 1}))
+	Expect(sent).To(Equal([][]string{{"archive", "delete"}, {"tags_add"}}))
+
+	pending, err = m.Queue.Pending()
+	Expect(err).To(BeNil())
+	Expect(pending).To(BeEmpty())
+}
+
+func TestPushInterrupted(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	store, err := mirror.OpenJSONStore(filepath.Join(dir, "mirror.json"))
+	Expect(err).To(BeNil())
+	_, err = store.Upsert([]api.Item{{ItemID: 1}, {ItemID: 2}, {ItemID: 3}})
+	Expect(err).To(BeNil())
+
+	m := mirror.New(store)
+	m.Queue = mirror.NewQueue(filepath.Join(dir, "queue.jsonl"))
+	Expect(m.Enqueue(api.NewArchiveAction(1), api.NewArchiveAction(2), api.NewArchiveAction(3))).To(Succeed())
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": 1, "action_results": []bool{true, true}})
+	}))
+	defer ts.Close()
+
+	// Interrupted while the first batch is in flight
+	m.Interrupted = func() bool { return requests > 0 }
+
+	api.Origin = ts.URL
+	res, err := m.Push(api.NewClient("key", "token"), 2)
+	Expect(err).To(Equal(mirror.ErrInterrupted))
+	Expect(*res).To(Equal(mirror.PushResult{Sent: 2}))
+	Expect(requests).To(Equal(1))
+
+	pending, err := m.Queue.Pending()
+	Expect(err).To(BeNil())
+	Expect(pending).To(HaveLen(1))
+	Expect(pending[0].ItemID).To(Equal(3))
+}
+
+func TestQueueCutShort(t *testing.T) {
+	RegisterTestingT(t)
+
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	q := mirror.NewQueue(path)
+	Expect(q.Add(api.NewArchiveAction(1))).To(Succeed())
+
+	// A crash while appending the next action
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	Expect(err).To(BeNil())
+	_, err = f.WriteString(`{"action":"archi`)
+	Expect(err).To(BeNil())
+	Expect(f.Close()).To(Succeed())
+
+	pending, err := q.Pending()
+	Expect(err).To(BeNil())
+	Expect(pending).To(HaveLen(1))
+
+	Expect(q.Add(api.NewFavoriteAction(2))).To(Succeed())
+	pending, err = q.Pending()
+	Expect(err).To(BeNil())
+	Expect(pending).To(HaveLen(2))
+	Expect(pending[1].Action).To(Equal("favorite"))
+}
+
+func TestSharedQueue(t *testing.T) {
+	RegisterTestingT(t)
+
+	// Two machines syncing the directory, one of them never sending
+	dir := t.TempDir()
+	laptop := mirror.NewSharedQueue(dir, "laptop")
+	phone := mirror.NewSharedQueue(dir, "phone")
+
+	add := api.NewAddAction("https://example.com/", "")
+	add.Time = 20
+	archive := api.NewArchiveAction(1)
+	archive.Time = 10
+	Expect(phone.Add(add)).To(Succeed())
+	Expect(laptop.Add(archive)).To(Succeed())
+
+	actions := func(q *mirror.Queue) []string {
+		pending, err := q.Pending()
+		Expect(err).To(BeNil())
+		names := []string{}
+		for _, a := range pending {
+			names = append(names, a.Action)
+		}
+		return names
+	}
+	Expect(actions(laptop)).To(Equal([]string{"archive", "add"}))
+	Expect(actions(phone)).To(Equal([]string{"archive", "add"}))
+
+	// Queued on the phone while the laptop was sending, even if older
+	older := api.NewFavoriteAction(2)
+	older.Time = 5
+	Expect(phone.Add(older)).To(Succeed())
+	Expect(laptop.Remove(2)).To(Succeed())
+	Expect(actions(laptop)).To(Equal([]string{"favorite"}))
+	Expect(actions(phone)).To(Equal([]string{"favorite"}))
+
+	// A conflicting copy of a journal is no more than the journal
+	b, err := os.ReadFile(filepath.Join(dir, "phone.jsonl"))
+	Expect(err).To(BeNil())
+	Expect(os.WriteFile(filepath.Join(dir, "phone.sync-conflict-20240101-120000-ABC.jsonl"), b, 0600)).To(Succeed())
+	Expect(actions(laptop)).To(Equal([]string{"favorite"}))
+
+	// The phone drops what was sent from its journal as it queues more,
+	// and each machine only writes files of its own
+	Expect(phone.Add(api.NewArchiveAction(3))).To(Succeed())
+	b, err = os.ReadFile(filepath.Join(dir, "phone.jsonl"))
+	Expect(err).To(BeNil())
+	Expect(strings.Count(string(b), "\n")).To(Equal(2))
+	Expect(string(b)).NotTo(ContainSubstring("example.com"))
+	entries, err := os.ReadDir(dir)
+	Expect(err).To(BeNil())
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	Expect(names).To(ConsistOf("laptop.jsonl", "laptop.sent", "phone.jsonl", "phone.sync-conflict-20240101-120000-ABC.jsonl"))
+
+	Expect(actions(phone)).To(ConsistOf("favorite", "archive"))
+	Expect(phone.Remove(2)).To(Succeed())
+	Expect(actions(laptop)).To(BeEmpty())
+}
+
+func TestCompare(t *testing.T) {
+	RegisterTestingT(t)
+
+	old := api.Item{ItemID: 1, Tags: map[string]map[string]interface{}{"a": nil, "b": nil}}
+	current := api.Item{ItemID: 1, Status: api.ItemStatusArchived, Favorite: 1, Tags: map[string]map[string]interface{}{"b": nil, "c": nil}}
+
+	changes := mirror.Compare(&old, current)
+	kinds := []mirror.ChangeKind{}
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+	}
+	Expect(kinds).To(Equal([]mirror.ChangeKind{mirror.ChangeArchived, mirror.ChangeFavorited, mirror.ChangeTagsChanged}))
+	Expect(changes[2].TagsAdded).To(Equal([]string{"c"}))
+	Expect(changes[2].TagsRemoved).To(Equal([]string{"a"}))
+
+	Expect(mirror.Compare(nil, current)[0].Kind).To(Equal(mirror.ChangeAdded))
+	Expect(mirror.Compare(&old, api.Item{ItemID: 1, Status: api.ItemStatusDeleted})[0].Kind).To(Equal(mirror.ChangeDeleted))
+	Expect(mirror.Compare(nil, api.Item{ItemID: 1, Status: api.ItemStatusDeleted})).To(BeEmpty())
+	Expect(mirror.Compare(&old, old)).To(BeEmpty())
+}
+
+func TestFetchArticles(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	store, err := mirror.OpenJSONStore(filepath.Join(dir, "mirror.json"))
+	Expect(err).To(BeNil())
+	_, err = store.Upsert([]api.Item{
+		{ItemID: 1, GivenURL: "http://example.com/1", TimeAdded: api.Time{Time: time.Unix(100, 0)}},
+		{ItemID: 2, GivenURL: "http://example.com/2", TimeAdded: api.Time{Time: time.Unix(200, 0)}},
+		{ItemID: 3, GivenURL: "http://example.com/fail", TimeAdded: api.Time{Time: time.Unix(300, 0)}},
+	})
+	Expect(err).To(BeNil())
+	m := mirror.New(store)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasSuffix(req.URL, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(api.Article{Title: req.URL, HTML: "<p>" + strings.Repeat("x", 100) + "</p>"})
+	}))
+	defer ts.Close()
+	api.ArticleOrigin = ts.URL
+	client := api.NewClient("key", "token")
+
+	cache := mirror.NewArticleCache(filepath.Join(dir, "articles"), 0)
+	Expect(cache.Put(9, &api.Article{Title: "gone"})).To(Succeed())
+
+	res, err := m.FetchArticles(client, cache)
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(mirror.FetchArticlesResult{Fetched: 2, Failed: 1, Pruned: 1}))
+
+	article, err := cache.Get(2)
+	Expect(err).To(BeNil())
+	Expect(article.Title).To(Equal("http://example.com/2"))
+
+	_, err = cache.Get(9)
+	Expect(os.IsNotExist(err)).To(BeTrue())
+
+	// Article 1 was read least recently, so it is evicted first
+	old := time.Now().Add(-time.Hour)
+	Expect(os.Chtimes(filepath.Join(dir, "articles", "1.json"), old, old)).To(Succeed())
+	status, err := cache.Status()
+	Expect(err).To(BeNil())
+	cache.MaxBytes = status.Bytes
+	Expect(cache.Put(4, &api.Article{Title: "new"})).To(Succeed())
+	Expect(cache.Has(1)).To(BeFalse())
+	Expect(cache.Has(2)).To(BeTrue())
+	Expect(cache.Has(4)).To(BeTrue())
+
+	Expect(cache.Clear()).To(Succeed())
+	status, err = cache.Status()
+	Expect(err).To(BeNil())
+	Expect(status.Articles).To(BeZero())
+}
+
+func TestArticleCacheTrim(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	cache := mirror.NewArticleCache(filepath.Join(dir, "articles"), 0)
+	for id := 1; id <= 4; id++ {
+		Expect(cache.Put(id, &api.Article{Title: strings.Repeat("x", 100)})).To(Succeed())
+		at := time.Now().Add(time.Duration(id-10) * time.Minute)
+		Expect(os.Chtimes(filepath.Join(dir, "articles", fmt.Sprintf("%d.json", id)), at, at)).To(Succeed())
+	}
+
+	evicted, err := cache.Trim()
+	Expect(err).To(BeNil())
+	Expect(evicted).To(BeZero())
+
+	// Article 3 is of an archived item, so it goes before the older 1 and 2
+	status, err := cache.Status()
+	Expect(err).To(BeNil())
+	cache.MaxBytes = status.Bytes * 3 / 4
+	cache.Keep = func(itemID int) bool { return itemID != 3 }
+	evicted, err = cache.Trim()
+	Expect(err).To(BeNil())
+	Expect(evicted).To(Equal(1))
+	Expect(cache.Has(3)).To(BeFalse())
+
+	cache.MaxBytes = status.Bytes / 2
+	evicted, err = cache.Trim()
+	Expect(err).To(BeNil())
+	Expect(evicted).To(Equal(1))
+	Expect(cache.Has(1)).To(BeFalse())
+	Expect(cache.Has(2)).To(BeTrue())
+	Expect(cache.Has(4)).To(BeTrue())
+}
+
+func TestBackfillWordCounts(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	store, err := mirror.OpenJSONStore(filepath.Join(dir, "mirror.json"))
+	Expect(err).To(BeNil())
+	_, err = store.Upsert([]api.Item{
+		{ItemID: 1, GivenURL: "http://example.com/counted", WordCount: 10},
+		{ItemID: 2, GivenURL: "http://example.com/recounted"},
+		{ItemID: 3, GivenURL: "http://example.com/parsed"},
+		{ItemID: 4, GivenURL: "http://example.com/cached"},
+		{ItemID: 5, GivenURL: "http://example.com/fail"},
+		{ItemID: 6, GivenURL: "http://example.com/video", HasVideo: api.ItemMediaAttachmentIsMedia},
+	})
+	Expect(err).To(BeNil())
+	m := mirror.New(store)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL   string `json:"url"`
+			Since int    `json:"since"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case r.URL.Path == "/v3/get" && req.Since == 0:
+			// Pocket has counted the words of one since
+			fmt.Fprint(w, `{"status":1,"since":500,"list":{
+				"1":{"item_id":"1","word_count":"10"},
+				"2":{"item_id":"2","word_count":"1200"},
+				"3":{"item_id":"3","word_count":"0"}}}`)
+		case r.URL.Path == "/v3/get":
+			// A later sync, with the item changed but not counted
+			fmt.Fprint(w, `{"status":1,"since":600,"list":{
+				"3":{"item_id":"3","given_url":"http://example.com/parsed","status":"1","word_count":"0"}}}`)
+		case strings.HasSuffix(req.URL, "fail"):
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			json.NewEncoder(w).Encode(api.Article{HTML: "<p>three short words</p>"})
+		}
+	}))
+	defer ts.Close()
+	origin, articleOrigin := api.Origin, api.ArticleOrigin
+	api.Origin, api.ArticleOrigin = ts.URL, ts.URL
+	defer func() { api.Origin, api.ArticleOrigin = origin, articleOrigin }()
+	client := api.NewClient("key", "token")
+
+	cache := mirror.NewArticleCache(filepath.Join(dir, "articles"), 0)
+	Expect(cache.Put(4, &api.Article{HTML: "<p>two words</p>"})).To(Succeed())
+	count := func(article *api.Article) int {
+		return len(strings.Fields(strings.NewReplacer("<p>", "", "</p>", "").Replace(article.HTML)))
+	}
+
+	res, err := m.BackfillWordCounts(client, cache, count)
+	Expect(err).To(BeNil())
+	Expect(*res).To(Equal(mirror.BackfillResult{Missing: 4, FromAPI: 1, FromText: 2, Failed: 1}))
+	Expect(cache.Has(3)).To(BeTrue())
+
+	counts := func() map[int]int {
+		items, err := m.Items()
+		Expect(err).To(BeNil())
+		counts := map[int]int{}
+		for _, item := range items {
+			counts[item.ItemID] = item.WordCount
+		}
+		return counts
+	}
+	Expect(counts()).To(Equal(map[int]int{1: 10, 2: 1200, 3: 3, 4: 2, 5: 0, 6: 0}))
+
+	// Syncs keep the counts made here
+	Expect(store.SetSince(500)).To(Succeed())
+	_, err = m.Sync(client)
+	Expect(err).To(BeNil())
+	Expect(counts()[3]).To(Equal(3))
+}
@@ -0,0 +1,218 @@
+package mirror_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	"github.com/motemen/go-pocket/pockettest"
+	. "github.com/onsi/gomega"
+)
+
+// syncState is what must agree between the mirror and the server about an
+// item.
+type syncState struct {
+	Status   api.ItemStatus
+	Favorite int
+	Tags     string
+}
+
+func syncStates(items []api.Item) map[int]syncState {
+	states := map[int]syncState{}
+	for _, item := range items {
+		if item.Status == api.ItemStatusDeleted {
+			continue
+		}
+		states[item.ItemID] = syncState{item.Status, item.Favorite, strings.Join(item.TagNames(), ",")}
+	}
+	return states
+}
+
+// syncRun drives a mirror and a fake server through random steps: changes
+// made on the server by another device, actions made offline on the mirror,
+// pushes, which may be interrupted, and syncs.
+type syncRun struct {
+	rand   *rand.Rand
+	server *pockettest.Server
+	client *api.Client
+	mirror *mirror.Mirror
+	steps  []string
+}
+
+// randomAction returns a random action on one of ids, or an add.
+func (r *syncRun) randomAction(ids []int) *api.Action {
+	if len(ids) == 0 || r.rand.Intn(8) == 0 {
+		n := r.rand.Intn(1000)
+		return api.NewAddAction(fmt.Sprintf("https://example.com/%d", n), fmt.Sprint(n), r.randomTags()...)
+	}
+	id := ids[r.rand.Intn(len(ids))]
+	switch r.rand.Intn(7) {
+	case 0:
+		return api.NewArchiveAction(id)
+	case 1:
+		return api.NewReaddAction(id)
+	case 2:
+		return api.NewFavoriteAction(id)
+	case 3:
+		return api.NewUnfavoriteAction(id)
+	case 4:
+		return api.NewTagsAddAction(id, r.randomTags()...)
+	case 5:
+		return api.NewTagsRemoveAction(id, r.randomTags()...)
+	default:
+		return api.NewDeleteAction(id)
+	}
+}
+
+func (r *syncRun) randomTags() []string {
+	tags := []string{}
+	for _, tag := range []string{"a", "b", "c"} {
+		if r.rand.Intn(2) == 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (r *syncRun) describe(action *api.Action) string {
+	if action.Action == "add" {
+		return fmt.Sprintf("add(%q, tags=%q)", action.URL, action.Tags)
+	}
+	if action.Tags != "" {
+		return fmt.Sprintf("%s(%d, tags=%q)", action.Action, action.ItemID, action.Tags)
+	}
+	return fmt.Sprintf("%s(%d)", action.Action, action.ItemID)
+}
+
+// step takes a random step, returning whether it was a sync of a mirror with
+// nothing left to push.
+func (r *syncRun) step() bool {
+	switch n := r.rand.Intn(10); {
+	case n < 4:
+		ids := []int{}
+		for _, item := range r.server.Items() {
+			ids = append(ids, item.ItemID)
+		}
+		action := r.randomAction(ids)
+		r.steps = append(r.steps, "server "+r.describe(action))
+		_, err := r.client.Modify(action)
+		Expect(err).To(BeNil(), r.String())
+
+	case n < 7:
+		items, err := r.mirror.Items()
+		Expect(err).To(BeNil(), r.String())
+		ids := []int{}
+		for _, item := range items {
+			ids = append(ids, item.ItemID)
+		}
+		action := r.randomAction(ids)
+		r.steps = append(r.steps, "offline "+r.describe(action))
+		Expect(r.mirror.Enqueue(action)).To(Succeed(), r.String())
+
+	case n < 8:
+		batchSize := 1 + r.rand.Intn(3)
+		batches := r.rand.Intn(3)
+		r.steps = append(r.steps, fmt.Sprintf("push(%d batches of %d)", batches, batchSize))
+		r.mirror.Interrupted = func() bool {
+			batches--
+			return batches < 0
+		}
+		_, err := r.mirror.Push(r.client, batchSize)
+		r.mirror.Interrupted = nil
+		if !errors.Is(err, mirror.ErrInterrupted) {
+			Expect(err).To(BeNil(), r.String())
+		}
+
+	default:
+		r.steps = append(r.steps, "sync")
+		_, err := r.mirror.Sync(r.client)
+		Expect(err).To(BeNil(), r.String())
+		pending, err := r.mirror.Queue.Pending()
+		Expect(err).To(BeNil(), r.String())
+		return len(pending) == 0
+	}
+	return false
+}
+
+// String describes the steps so far, for failures to be reproduced.
+func (r *syncRun) String() string {
+	return strings.Join(r.steps, "\n")
+}
+
+// expectConverged checks that the mirror holds the items of the server.
+func (r *syncRun) expectConverged() {
+	items, err := r.mirror.Items()
+	Expect(err).To(BeNil())
+	Expect(syncStates(items)).To(Equal(syncStates(r.server.Items())), r.String())
+}
+
+// TestSyncConverges checks, over random interleavings of changes on the
+// server, offline actions, pushes and syncs, that a sync with nothing left
+// to push leaves the mirror with the items of the server, and that pushing
+// and syncing always brings it there in the end.
+func TestSyncConverges(t *testing.T) {
+	RegisterTestingT(t)
+
+	origin, pageSize := api.Origin, mirror.PageSize
+	defer func() { api.Origin, mirror.PageSize = origin, pageSize }()
+	// Small pages so that syncs go through several
+	mirror.PageSize = 3
+
+	stores := map[string]func(dir string) (mirror.Store, error){
+		"bolt": func(dir string) (mirror.Store, error) {
+			return mirror.OpenBoltStore(filepath.Join(dir, "mirror.db"))
+		},
+		"json": func(dir string) (mirror.Store, error) {
+			return mirror.OpenJSONStore(filepath.Join(dir, "mirror.json"))
+		},
+	}
+	names := []string{}
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for seed := int64(1); seed <= 20; seed++ {
+			t.Run(fmt.Sprintf("%s/seed=%d", name, seed), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				server := pockettest.NewServer()
+				defer server.Close()
+				api.Origin = server.URL
+
+				dir := t.TempDir()
+				store, err := stores[name](dir)
+				Expect(err).To(BeNil())
+				m := mirror.New(store)
+				defer m.Close()
+				m.Queue = mirror.NewQueue(filepath.Join(dir, "queue.jsonl"))
+
+				r := &syncRun{
+					rand:   rand.New(rand.NewSource(seed)),
+					server: server,
+					client: api.NewClient(server.ConsumerKey, server.AccessToken),
+					mirror: m,
+				}
+				for i := 0; i < 60; i++ {
+					if r.step() {
+						r.expectConverged()
+					}
+				}
+
+				r.steps = append(r.steps, "push", "sync")
+				_, err = m.Push(r.client, 10)
+				Expect(err).To(BeNil(), r.String())
+				_, err = m.Sync(r.client)
+				Expect(err).To(BeNil(), r.String())
+				r.expectConverged()
+			})
+		}
+	}
+}
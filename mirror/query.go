@@ -0,0 +1,141 @@
+package mirror
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// untaggedTag is the special tag the retrieve API uses to match items with
+// no tags.
+const untaggedTag = "_untagged_"
+
+// Match reports whether item satisfies the filters in options, following
+// the semantics of the retrieve API. Sorting and paging are ignored.
+func Match(item api.Item, options *api.RetrieveOption) bool {
+	switch options.State {
+	case api.StateArchive:
+		if item.Status != api.ItemStatusArchived {
+			return false
+		}
+	case api.StateAll:
+	default:
+		if item.Status != api.ItemStatusUnread {
+			return false
+		}
+	}
+
+	switch options.Favorite {
+	case api.FavoriteFilterFavorited:
+		if item.Favorite != 1 {
+			return false
+		}
+	case api.FavoriteFilterUnfavorited:
+		if item.Favorite == 1 {
+			return false
+		}
+	}
+
+	if options.Tag == untaggedTag {
+		if len(item.Tags) > 0 {
+			return false
+		}
+	} else if options.Tag != "" {
+		if _, ok := item.Tags[options.Tag]; !ok {
+			return false
+		}
+	}
+
+	switch options.ContentType {
+	case api.ContentTypeArticle:
+		if item.IsArticle != 1 {
+			return false
+		}
+	case api.ContentTypeVideo:
+		if item.HasVideo == api.ItemMediaAttachmentNoMedia {
+			return false
+		}
+	case api.ContentTypeImage:
+		if item.HasImage == api.ItemMediaAttachmentNoMedia {
+			return false
+		}
+	}
+
+	if options.Domain != "" {
+		domain := strings.TrimPrefix(strings.ToLower(options.Domain), "www.")
+		if d := item.Domain(); d != domain && !strings.HasSuffix(d, "."+domain) {
+			return false
+		}
+	}
+
+	if options.Search != "" {
+		search := strings.ToLower(options.Search)
+		if !strings.Contains(strings.ToLower(item.Title()), search) &&
+			!strings.Contains(strings.ToLower(item.URL()), search) {
+			return false
+		}
+	}
+
+	if options.Since > 0 && item.TimeUpdated.Unix() < int64(options.Since) {
+		return false
+	}
+
+	return true
+}
+
+// Query returns the items matching options, sorted and paged like the
+// retrieve API does. SortId of the returned items is set to their position,
+// the newest first unless another sort order is requested. If
+// api.NormalizeTitles is set, the titles of items stored before it was are
+// normalized first.
+func Query(items []api.Item, options *api.RetrieveOption) []api.Item {
+	matched := []api.Item{}
+	for _, item := range items {
+		if api.NormalizeTitles {
+			item.GivenTitle = api.NormalizeTitle(item.GivenTitle)
+			item.ResolvedTitle = api.NormalizeTitle(item.ResolvedTitle)
+		}
+		if Match(item, options) {
+			matched = append(matched, item)
+		}
+	}
+
+	var less func(a, b api.Item) bool
+	switch options.Sort {
+	case api.SortOldest:
+		less = func(a, b api.Item) bool { return a.TimeAdded.Before(b.TimeAdded.Time) }
+	case api.SortTitle:
+		less = func(a, b api.Item) bool { return a.Title() < b.Title() }
+	case api.SortSite:
+		less = func(a, b api.Item) bool { return a.URL() < b.URL() }
+	default:
+		less = func(a, b api.Item) bool { return a.TimeAdded.After(b.TimeAdded.Time) }
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if less(matched[i], matched[j]) {
+			return true
+		}
+		if less(matched[j], matched[i]) {
+			return false
+		}
+		return matched[i].ItemID < matched[j].ItemID
+	})
+
+	if options.Offset > 0 {
+		if options.Offset >= len(matched) {
+			matched = matched[:0]
+		} else {
+			matched = matched[options.Offset:]
+		}
+	}
+	if options.Count > 0 && len(matched) > options.Count {
+		matched = matched[:options.Count]
+	}
+
+	for i := range matched {
+		matched[i].SortId = i
+	}
+
+	return matched
+}
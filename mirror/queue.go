@@ -0,0 +1,388 @@
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/internal/atomicfile"
+)
+
+// sentKept is how long a shared queue remembers the actions sent whose
+// entries are gone from every journal, in case a journal of another
+// machine still having them comes back late through the sync.
+const sentKept = 30 * 24 * time.Hour
+
+// Queue is a journal of actions made while offline, waiting to be sent to
+// Pocket. It is stored as one JSON-encoded action per line.
+type Queue struct {
+	path string
+	// dir and host are those of a shared queue.
+	dir, host string
+	mu        sync.Mutex
+	// pending are the IDs of the entries Pending returned last, in its
+	// order, for Remove to drop.
+	pending []string
+}
+
+// NewQueue returns the queue stored in the file at path.
+func NewQueue(path string) *Queue {
+	return &Queue{path: path}
+}
+
+// NewSharedQueue returns the queue kept in dir by machines sharing it, as a
+// config directory synced between them with Dropbox or Syncthing is. The
+// machine named host only ever writes files of its own: it appends the
+// actions it queues to <host>.jsonl, each with an ID, and records those it
+// sent, whichever machine queued them, in <host>.sent, so that the sync
+// finds no conflicts and any of the machines may send what the others
+// queued.
+func NewSharedQueue(dir, host string) *Queue {
+	return &Queue{dir: dir, host: host}
+}
+
+// queueEntry is an action in a journal of a shared queue.
+type queueEntry struct {
+	ID     string      `json:"id"`
+	Action *api.Action `json:"action"`
+}
+
+// sentEntry records an action of a shared queue sent to Pocket.
+type sentEntry struct {
+	ID   string `json:"id"`
+	Time int64  `json:"time"`
+}
+
+// Add appends actions to the queue.
+func (q *Queue) Add(actions ...*api.Action) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	path := q.path
+	values := make([]any, len(actions))
+	for i, action := range actions {
+		values[i] = action
+	}
+	if q.dir != "" {
+		if err := os.MkdirAll(q.dir, 0700); err != nil {
+			return err
+		}
+		// Not to grow forever on a machine that never sends
+		if err := q.compact(); err != nil {
+			return err
+		}
+		path = q.file(".jsonl")
+		for i, action := range actions {
+			id, err := q.newID()
+			if err != nil {
+				return err
+			}
+			values[i] = queueEntry{ID: id, Action: action}
+		}
+	}
+	return appendJSONLines(path, values)
+}
+
+// Pending returns the queued actions, oldest first.
+func (q *Queue) Pending() ([]*api.Action, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.dir == "" {
+		return q.read()
+	}
+	entries, _, err := q.readShared()
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]*api.Action, len(entries))
+	q.pending = make([]string, len(entries))
+	for i, e := range entries {
+		actions[i], q.pending[i] = e.Action, e.ID
+	}
+	return actions, nil
+}
+
+func (q *Queue) read() ([]*api.Action, error) {
+	actions := []*api.Action{}
+	err := readJSONLines(q.path, func(line []byte) error {
+		action := &api.Action{}
+		if err := json.Unmarshal(line, action); err != nil {
+			return err
+		}
+		actions = append(actions, action)
+		return nil
+	})
+	return actions, err
+}
+
+// Remove drops the first n actions from the queue, once they have been sent.
+// For a shared queue, they are the first n that Pending returned, even if
+// other machines queued older ones since.
+func (q *Queue) Remove(n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.dir != "" {
+		return q.removeShared(n)
+	}
+
+	actions, err := q.read()
+	if err != nil {
+		return err
+	}
+	if n > len(actions) {
+		n = len(actions)
+	}
+
+	values := make([]any, 0, len(actions)-n)
+	for _, action := range actions[n:] {
+		values = append(values, action)
+	}
+	return writeJSONLines(q.path, values)
+}
+
+// file returns the path of the file of the queue's host with ext.
+func (q *Queue) file(ext string) string {
+	return filepath.Join(q.dir, q.host+ext)
+}
+
+func (q *Queue) newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return q.host + "-" + hex.EncodeToString(b), nil
+}
+
+// readShared returns the entries of the journals of every machine yet to be
+// sent, oldest first, and the actions sent, by their IDs. An entry copied
+// into a conflicting copy of a journal is taken once.
+func (q *Queue) readShared() ([]queueEntry, map[string]sentEntry, error) {
+	sent := map[string]sentEntry{}
+	files, err := filepath.Glob(filepath.Join(q.dir, "*.sent"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		err := readJSONLines(file, func(line []byte) error {
+			var e sentEntry
+			// A line cut short as the file was being synced is left out
+			if json.Unmarshal(line, &e) == nil && e.ID != "" {
+				sent[e.ID] = e
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	entries := []queueEntry{}
+	seen := map[string]bool{}
+	files, err = filepath.Glob(filepath.Join(q.dir, "*.jsonl"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		err := readJSONLines(file, func(line []byte) error {
+			var e queueEntry
+			if json.Unmarshal(line, &e) != nil || e.ID == "" || e.Action == nil || seen[e.ID] {
+				return nil
+			}
+			seen[e.ID] = true
+			if _, ok := sent[e.ID]; !ok {
+				entries = append(entries, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Action.Time < entries[j].Action.Time })
+	return entries, sent, nil
+}
+
+func (q *Queue) removeShared(n int) error {
+	if q.pending == nil {
+		entries, _, err := q.readShared()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			q.pending = append(q.pending, e.ID)
+		}
+	}
+	n = min(n, len(q.pending))
+
+	now := time.Now().Unix()
+	values := make([]any, n)
+	for i, id := range q.pending[:n] {
+		values[i] = sentEntry{ID: id, Time: now}
+	}
+	if err := os.MkdirAll(q.dir, 0700); err != nil {
+		return err
+	}
+	if err := appendJSONLines(q.file(".sent"), values); err != nil {
+		return err
+	}
+	q.pending = q.pending[n:]
+	return q.compact()
+}
+
+// compact drops the entries sent from the journal of the queue's host, and
+// forgets those sent long ago whose entries are gone from every journal.
+func (q *Queue) compact() error {
+	_, sent, err := q.readShared()
+	if err != nil {
+		return err
+	}
+
+	journal := q.file(".jsonl")
+	kept := []any{}
+	dropped := false
+	err = readJSONLines(journal, func(line []byte) error {
+		var e queueEntry
+		if json.Unmarshal(line, &e) != nil {
+			dropped = true
+			return nil
+		}
+		if _, ok := sent[e.ID]; ok {
+			dropped = true
+			return nil
+		}
+		kept = append(kept, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if dropped {
+		if err := writeJSONLines(journal, kept); err != nil {
+			return err
+		}
+	}
+
+	queued := map[string]bool{}
+	files, err := filepath.Glob(filepath.Join(q.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		err := readJSONLines(file, func(line []byte) error {
+			var e queueEntry
+			if json.Unmarshal(line, &e) == nil {
+				queued[e.ID] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	record := q.file(".sent")
+	remembered := []any{}
+	forgotten := false
+	cutoff := time.Now().Add(-sentKept).Unix()
+	err = readJSONLines(record, func(line []byte) error {
+		var e sentEntry
+		if json.Unmarshal(line, &e) != nil || !queued[e.ID] && e.Time < cutoff {
+			forgotten = true
+			return nil
+		}
+		remembered = append(remembered, e)
+		return nil
+	})
+	if err != nil || !forgotten {
+		return err
+	}
+	return writeJSONLines(record, remembered)
+}
+
+// readJSONLines calls f with each line of the file at path that is not
+// blank. A missing file has no lines, and lines that are not JSON, cut
+// short by a crash while appending them, are passed over.
+func readJSONLines(path string, f func(line []byte) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := []byte(strings.TrimSpace(scanner.Text()))
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+		if err := f(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// appendJSONLines appends values to the file at path, one per line, in a
+// single write synced to disk before it returns. A crash can still cut the
+// last line short, which readJSONLines passes over and the next append
+// starts a new line after.
+func appendJSONLines(path string, values []any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		b := make([]byte, 1)
+		if _, err := f.ReadAt(b, info.Size()-1); err == nil && b[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeJSONLines replaces the file at path with values, one per line,
+// through a temporary file renamed over it.
+func writeJSONLines(path string, values []any) error {
+	return atomicfile.Write(path, 0600, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,184 @@
+package mirror
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// SQLStore is a Store in a SQLite database accessed through database/sql.
+// The package does not depend on a driver; open the database with the
+// SQLite driver of your choice (such as github.com/mattn/go-sqlite3 or
+// modernc.org/sqlite) and pass it to NewSQLStore.
+type SQLStore struct {
+	// Cipher, if set, encrypts the items stored, which are then kept as
+	// base64 text.
+	Cipher Cipher
+
+	db *sql.DB
+}
+
+const sqlStoreSchema = `
+CREATE TABLE IF NOT EXISTS pocket_items (
+	item_id INTEGER PRIMARY KEY,
+	data    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pocket_meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// NewSQLStore creates the tables of the store in db if they do not exist.
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	_, err := db.Exec(sqlStoreSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Upsert implements Store.
+func (s *SQLStore) Upsert(items []api.Item) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, item := range items {
+		var exists int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM pocket_items WHERE item_id = ?`, item.ItemID).Scan(&exists)
+		if err != nil {
+			return 0, err
+		}
+		if exists == 0 {
+			added++
+		}
+
+		data, err := s.encode(item)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO pocket_items (item_id, data) VALUES (?, ?)`, item.ItemID, data)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return added, tx.Commit()
+}
+
+// encode returns item as stored in the data column: JSON, encrypted and
+// encoded in base64 if Cipher is set.
+func (s *SQLStore) encode(item api.Item) (string, error) {
+	data, err := json.Marshal(item)
+	if err != nil || s.Cipher == nil {
+		return string(data), err
+	}
+	sealed, err := Seal(s.Cipher, data)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decode reverses encode. Items in JSON are read whether Cipher is set or
+// not, as stored before encryption was turned on.
+func (s *SQLStore) decode(data string) (api.Item, error) {
+	var item api.Item
+	b := []byte(data)
+	if !strings.HasPrefix(data, "{") {
+		sealed, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return item, err
+		}
+		b, err = Unseal(s.Cipher, sealed)
+		if err != nil {
+			return item, err
+		}
+	}
+	err := json.Unmarshal(b, &item)
+	return item, err
+}
+
+// Delete implements Store.
+func (s *SQLStore) Delete(itemIDs []int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deleted := 0
+	for _, id := range itemIDs {
+		res, err := tx.Exec(`DELETE FROM pocket_items WHERE item_id = ?`, id)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+
+	return deleted, tx.Commit()
+}
+
+// Query implements Store.
+func (s *SQLStore) Query(options *api.RetrieveOption) ([]api.Item, error) {
+	rows, err := s.db.Query(`SELECT data FROM pocket_items ORDER BY item_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []api.Item{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		item, err := s.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return Query(items, options), nil
+}
+
+// Since implements Store.
+func (s *SQLStore) Since() (int, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM pocket_meta WHERE key = 'since'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// SetSince implements Store.
+func (s *SQLStore) SetSince(since int) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO pocket_meta (key, value) VALUES ('since', ?)`, strconv.Itoa(since))
+	return err
+}
+
+// Close implements Store. The database itself is left open, since it is
+// owned by the caller.
+func (s *SQLStore) Close() error {
+	return nil
+}
@@ -0,0 +1,222 @@
+package mirror_test
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/mirror"
+	. "github.com/onsi/gomega"
+)
+
+// fakeSQL is a database/sql driver that understands just the statements of
+// SQLStore, keeping the tables in memory, so that SQLStore can be tested
+// without depending on a SQLite driver. Each name opened is a database of
+// its own.
+type fakeSQL struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+type fakeDB struct {
+	mu    sync.Mutex
+	items map[int64]string
+	meta  map[string]string
+}
+
+func init() {
+	sql.Register("fakesql", &fakeSQL{dbs: map[string]*fakeDB{}})
+}
+
+func (d *fakeSQL) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.dbs[name]
+	if !ok {
+		db = &fakeDB{}
+		d.dbs[name] = db
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+	// items and meta are the tables as they were when the transaction
+	// began, to go back to on rollback.
+	items map[int64]string
+	meta  map[string]string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: strings.TrimSpace(query)}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.items, c.meta = copyMap(c.db.items), copyMap(c.db.meta)
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error { return nil }
+
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.items, c.db.meta = c.items, c.meta
+	return nil
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		if db.items == nil {
+			db.items, db.meta = map[int64]string{}, map[string]string{}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE INTO pocket_items"):
+		db.items[args[0].(int64)] = args[1].(string)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE INTO pocket_meta"):
+		db.meta["since"] = args[0].(string)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE FROM pocket_items"):
+		id := args[0].(int64)
+		if _, ok := db.items[id]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		delete(db.items, id)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("fakesql: unknown statement %q", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows := &fakeRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT COUNT(*) FROM pocket_items"):
+		n := int64(0)
+		if _, ok := db.items[args[0].(int64)]; ok {
+			n = 1
+		}
+		rows.values = [][]driver.Value{{n}}
+	case strings.HasPrefix(s.query, "SELECT data FROM pocket_items"):
+		ids := make([]int64, 0, len(db.items))
+		for id := range db.items {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			rows.values = append(rows.values, []driver.Value{db.items[id]})
+		}
+	case strings.HasPrefix(s.query, "SELECT value FROM pocket_meta"):
+		if since, ok := db.meta["since"]; ok {
+			rows.values = [][]driver.Value{{since}}
+		}
+	default:
+		return nil, fmt.Errorf("fakesql: unknown query %q", s.query)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func openFakeSQL(t *testing.T) *sql.DB {
+	db, err := sql.Open("fakesql", t.Name())
+	Expect(err).To(BeNil())
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLStore(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := openFakeSQL(t)
+	store, err := mirror.NewSQLStore(db)
+	Expect(err).To(BeNil())
+	testSync(t, store)
+
+	// The items are in the database, for another store on it to read
+	store, err = mirror.NewSQLStore(db)
+	Expect(err).To(BeNil())
+	since, err := store.Since()
+	Expect(err).To(BeNil())
+	Expect(since).To(Equal(300))
+	items, err := store.Query(&api.RetrieveOption{State: api.StateAll})
+	Expect(err).To(BeNil())
+	Expect(items).To(HaveLen(2))
+}
+
+func TestSQLStoreCipher(t *testing.T) {
+	RegisterTestingT(t)
+
+	c, err := mirror.NewAESCipher(bytes.Repeat([]byte{1}, 32))
+	Expect(err).To(BeNil())
+
+	db := openFakeSQL(t)
+	store, err := mirror.NewSQLStore(db)
+	Expect(err).To(BeNil())
+	store.Cipher = c
+	testSync(t, store)
+
+	var data string
+	Expect(db.QueryRow(`SELECT data FROM pocket_items ORDER BY item_id`).Scan(&data)).To(Succeed())
+	Expect(data).NotTo(HavePrefix("{"))
+	Expect(data).NotTo(ContainSubstring("example.org"))
+
+	// Without the cipher the items can't be read
+	store, err = mirror.NewSQLStore(db)
+	Expect(err).To(BeNil())
+	_, err = store.Query(&api.RetrieveOption{State: api.StateAll})
+	Expect(err).To(Equal(mirror.ErrEncrypted))
+}
@@ -0,0 +1,117 @@
+package mirror
+
+import (
+	"github.com/motemen/go-pocket/api"
+)
+
+// BackfillResult summarizes a BackfillWordCounts call.
+type BackfillResult struct {
+	// Missing is the number of articles that lacked a word count.
+	Missing int
+	// FromAPI and FromText are the numbers of them counted by Pocket since
+	// they were mirrored, and counted from their article text.
+	FromAPI  int
+	FromText int
+	// Failed is the number whose article could not be fetched.
+	Failed int
+}
+
+// missingWordCount tells if item is an article without a word count.
+func missingWordCount(item api.Item) bool {
+	return item.WordCount == 0 &&
+		item.HasVideo != api.ItemMediaAttachmentIsMedia && item.HasImage != api.ItemMediaAttachmentIsMedia
+}
+
+// BackfillWordCounts fills in the word counts of the mirrored articles
+// saved before Pocket counted words. The whole account is retrieved again
+// in full detail first, for the counts Pocket has made since; the words of
+// the rest are counted by count from their article, cached or fetched from
+// the Article View API and cached.
+func (m *Mirror) BackfillWordCounts(client *api.Client, cache *ArticleCache, count func(*api.Article) int) (*BackfillResult, error) {
+	items, err := m.Items()
+	if err != nil {
+		return nil, err
+	}
+	missing := []api.Item{}
+	for _, item := range items {
+		if missingWordCount(item) {
+			missing = append(missing, item)
+		}
+	}
+	result := &BackfillResult{Missing: len(missing)}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	list, _, err := fetchChanges(client, 0)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]api.Item{}
+	for _, item := range list {
+		byID[item.ItemID] = item
+	}
+
+	filled := []api.Item{}
+	for _, item := range missing {
+		if m.interrupted() {
+			break
+		}
+		if fetched := byID[item.ItemID]; fetched.WordCount > 0 {
+			item.WordCount = fetched.WordCount
+			result.FromAPI++
+			filled = append(filled, item)
+			continue
+		}
+
+		article, err := cache.Peek(item.ItemID)
+		if err != nil {
+			article, err = client.Article(item.URL())
+			if err != nil {
+				result.Failed++
+				continue
+			}
+			if err := cache.Put(item.ItemID, article); err != nil {
+				return nil, err
+			}
+		}
+		if item.WordCount = count(article); item.WordCount == 0 {
+			result.Failed++
+			continue
+		}
+		result.FromText++
+		filled = append(filled, item)
+	}
+
+	if _, err := m.Store.Upsert(filled); err != nil {
+		return nil, err
+	}
+	if m.interrupted() {
+		return result, ErrInterrupted
+	}
+	return result, nil
+}
+
+// keepWordCounts carries the word counts of the mirrored items over to
+// those in upserts Pocket sent without one, as backfilled by
+// BackfillWordCounts.
+func (m *Mirror) keepWordCounts(upserts []api.Item) error {
+	var counts map[int]int
+	for i := range upserts {
+		if !missingWordCount(upserts[i]) {
+			continue
+		}
+		if counts == nil {
+			items, err := m.Items()
+			if err != nil {
+				return err
+			}
+			counts = map[int]int{}
+			for _, item := range items {
+				counts[item.ItemID] = item.WordCount
+			}
+		}
+		upserts[i].WordCount = counts[upserts[i].ItemID]
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pdf
+
+// winAnsi maps the characters of WinAnsiEncoding between 0x80 and 0x9f.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// encode converts s to WinAnsiEncoding, the encoding of the standard fonts,
+// replacing the characters it lacks with "?".
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f || r > 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case winAnsi[r] != 0:
+			b = append(b, winAnsi[r])
+		case r == '\u00a0' || r == '\u2002' || r == '\u2003' || r == '\u2009' || r == '\u202f':
+			b = append(b, ' ')
+		case r == '\u00ad' || r == '\u200b' || r == '\ufeff':
+			// Invisible
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// The widths of the printable ASCII characters, from " " to "~", in
+// thousandths of the font size, as in the Adobe font metrics.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// punctuationWidths are the widths of the punctuation above 0x7f, the same
+// in both Helvetica weights but for the quotes.
+var punctuationWidths = map[byte]int{
+	0x80: 556, 0x82: 222, 0x84: 333, 0x85: 1000, 0x86: 556, 0x87: 556, 0x89: 1000,
+	0x8b: 333, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333, 0x95: 350, 0x96: 556,
+	0x97: 1000, 0x99: 1000, 0x9b: 333, 0xa0: 278, 0xab: 556, 0xb0: 400, 0xb7: 278, 0xbb: 556,
+}
+
+// latin1Letters are the letters the characters from 0xc0 to 0xff are based
+// on, for their widths.
+const latin1Letters = "AAAAAAACEEEEIIIIDNOOOOO+OUUUUYPsaaaaaaaceeeeiiiidnooooo+ouuuuypy"
+
+// charWidth returns the width of the WinAnsiEncoding character c in font.
+// Characters beyond ASCII take the width of the letter they are based on,
+// which is close enough for wrapping lines.
+func charWidth(font Font, c byte) int {
+	if font == Mono {
+		return 600
+	}
+	widths := &helveticaWidths
+	if font == Bold {
+		widths = &helveticaBoldWidths
+	}
+
+	switch {
+	case c >= 0x20 && c < 0x7f:
+		return widths[c-0x20]
+	case c == 0xc6 || c == 0xe6 || c == 0x8c || c == 0x9c:
+		return 1000
+	case c >= 0xc0:
+		return widths[latin1Letters[c-0xc0]-0x20]
+	case (c == 0x82 || c == 0x91 || c == 0x92) && font == Bold:
+		return 278
+	case (c == 0x84 || c == 0x93 || c == 0x94) && font == Bold:
+		return 500
+	case punctuationWidths[c] != 0:
+		return punctuationWidths[c]
+	}
+	return 556
+}
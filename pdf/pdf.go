@@ -0,0 +1,214 @@
+// Package pdf lays out text into simple printable PDF documents, using the
+// standard fonts every PDF reader has, so no fonts need to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Font is one of the fonts text can be set in.
+type Font int
+
+const (
+	Regular Font = iota
+	Bold
+	Italic
+	Mono
+)
+
+// baseFonts are the standard fonts used for each Font.
+var baseFonts = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Courier"}
+
+// lineSpacing is the height of a line relative to the font size.
+const lineSpacing = 1.4
+
+// Document is a PDF document under construction, filled from the top of the
+// first page down.
+type Document struct {
+	Title  string
+	Author string
+
+	// Width and Height are the page size in points; A4 by default.
+	Width  float64
+	Height float64
+	// Margin is the space left around the text on each side.
+	Margin float64
+
+	pages []*bytes.Buffer
+	// y is the baseline of the last line set on the current page.
+	y float64
+}
+
+// New creates an empty document of A4 pages.
+func New(title string) *Document {
+	return &Document{
+		Title:  title,
+		Width:  595,
+		Height: 842,
+		Margin: 56,
+	}
+}
+
+// NewPage starts a new page.
+func (d *Document) NewPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = d.Height - d.Margin
+}
+
+// Space leaves h points of vertical space, unless at the top of a page.
+func (d *Document) Space(h float64) {
+	if len(d.pages) > 0 && d.y < d.Height-d.Margin {
+		d.y -= h
+	}
+}
+
+// Text sets a paragraph of text, wrapped to the width of the page less
+// indent, starting new pages as needed. Runs of white space are collapsed,
+// except in Mono, where line breaks are kept as in preformatted text.
+func (d *Document) Text(font Font, size, indent float64, text string) {
+	var lines []string
+	if font == Mono {
+		for _, l := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			lines = append(lines, wrap(font, size, d.Width-2*d.Margin-indent, []string{strings.ReplaceAll(l, "\t", "    ")})...)
+		}
+	} else {
+		lines = wrap(font, size, d.Width-2*d.Margin-indent, strings.Fields(text))
+	}
+
+	for _, line := range lines {
+		if len(d.pages) == 0 || d.y-size*lineSpacing < d.Margin {
+			d.NewPage()
+		}
+		d.y -= size * lineSpacing
+		d.show(font, size, d.Margin+indent, d.y, line)
+	}
+}
+
+func (d *Document) show(font Font, size, x, y float64, text string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /F%d %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font+1, size, x, y, escape(encode(text)))
+}
+
+// wrap breaks the words into lines no wider than width, breaking words that
+// are wider on their own.
+func wrap(font Font, size, width float64, words []string) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range words {
+		if line != "" && Width(font, size, line+" "+word) <= width {
+			line += " " + word
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = ""
+		for _, r := range word {
+			if line != "" && Width(font, size, line+string(r)) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			line += string(r)
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Width returns the width of s set in font at size, in points.
+func Width(font Font, size float64, s string) float64 {
+	units := 0
+	for _, c := range encode(s) {
+		units += charWidth(font, c)
+	}
+	return float64(units) * size / 1000
+}
+
+// Write writes out the document, numbering its pages.
+func (d *Document) Write(w io.Writer) error {
+	if len(d.pages) == 0 {
+		d.NewPage()
+	}
+
+	var buf bytes.Buffer
+	offsets := []int{}
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+
+	// Objects 1 and 2 are the catalog and the page tree, the fonts follow,
+	// then the info dictionary, then each page and its content
+	const firstFont = 3
+	infoObj := firstFont + len(baseFonts)
+	firstPage := infoObj + 1
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	fonts := []string{}
+	for i, name := range baseFonts {
+		object("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name)
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, firstFont+i))
+	}
+
+	info := "<< /Producer (go-pocket)"
+	if d.Title != "" {
+		info += " /Title " + textString(d.Title)
+	}
+	if d.Author != "" {
+		info += " /Author " + textString(d.Author)
+	}
+	object("%s >>", info)
+
+	for i, page := range d.pages {
+		content := page.String()
+		number := fmt.Sprintf("%d / %d", i+1, len(d.pages))
+		content += fmt.Sprintf("BT /F1 9.0 Tf %.2f %.2f Td (%s) Tj ET\n", (d.Width-Width(Regular, 9, number))/2, d.Margin/2, number)
+
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			d.Width, d.Height, strings.Join(fonts, " "), firstPage+2*i+1)
+		object("<< /Length %d >>\nstream\n%sendstream", len(content), content)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, infoObj, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// escape escapes the special characters of a PDF literal string.
+func escape(s []byte) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`)
+	return r.Replace(string(s))
+}
+
+// textString encodes s as a PDF text string in UTF-16, for the document
+// information, which unlike page text is not limited to the font encoding.
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package pdf_test
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/pdf"
+	. "github.com/onsi/gomega"
+)
+
+// shown returns the strings set on the pages of a document.
+func shown(doc string) []string {
+	texts := []string{}
+	for _, m := range regexp.MustCompile(`\((.*)\) Tj`).FindAllStringSubmatch(doc, -1) {
+		texts = append(texts, m[1])
+	}
+	return texts
+}
+
+func TestDocument(t *testing.T) {
+	RegisterTestingT(t)
+
+	doc := pdf.New("Café (notes)")
+	doc.Text(pdf.Bold, 18, 0, "A title")
+	doc.Text(pdf.Regular, 11, 0, strings.Repeat("lorem ipsum dolor ", 400))
+	doc.Space(8)
+	doc.Text(pdf.Mono, 9, 20, "func main() {\n\tfmt.Println(`\\o/`)\n}")
+	doc.Text(pdf.Italic, 11, 0, "“Quoted” — café, 日本")
+
+	var buf bytes.Buffer
+	Expect(doc.Write(&buf)).To(Succeed())
+	out := buf.String()
+
+	Expect(out).To(HavePrefix("%PDF-1.4\n"))
+	Expect(out).To(HaveSuffix("%%EOF\n"))
+	Expect(out).To(ContainSubstring("/Count 2"))
+	Expect(out).To(ContainSubstring("/BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding"))
+	Expect(out).To(ContainSubstring("/Title <FEFF00430061006600E900200028006E006F0074006500730029>"))
+
+	texts := shown(out)
+	Expect(texts[0]).To(Equal("A title"))
+	for _, text := range texts[1:] {
+		if strings.HasPrefix(text, "lorem") {
+			Expect(pdf.Width(pdf.Regular, 11, text)).To(BeNumerically("<=", 595-2*56))
+		}
+	}
+	Expect(texts).To(ContainElement(`func main\(\) {`))
+	Expect(texts).To(ContainElement("    fmt.Println\\(`\\\\o/`\\)"))
+	Expect(texts).To(ContainElement("\x93Quoted\x94 \x97 caf\xe9, ??"))
+	Expect(texts).To(ContainElement("1 / 2"))
+	Expect(texts).To(ContainElement("2 / 2"))
+
+	// The cross-reference table points at each object
+	start, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(out)[1])
+	Expect(err).To(BeNil())
+	Expect(out[start:]).To(HavePrefix("xref\n"))
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(out, -1)
+	Expect(offsets).To(HaveLen(strings.Count(out, " 0 obj\n")))
+	for i, m := range offsets {
+		offset, _ := strconv.Atoi(m[1])
+		Expect(out[offset:]).To(HavePrefix(strconv.Itoa(i+1) + " 0 obj\n"))
+	}
+}
+
+func TestWidth(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(pdf.Width(pdf.Regular, 10, "Hi")).To(BeNumerically("~", 9.44, 0.001))
+	Expect(pdf.Width(pdf.Bold, 10, "Hi")).To(BeNumerically("~", 10, 0.001))
+	Expect(pdf.Width(pdf.Mono, 10, "Hi")).To(BeNumerically("~", 12, 0.001))
+	Expect(pdf.Width(pdf.Regular, 10, "é")).To(Equal(pdf.Width(pdf.Regular, 10, "e")))
+}
@@ -0,0 +1,293 @@
+// Package pocketops changes many items at once, as the pocket command does,
+// for other Go programs: it retrieves the items a filter selects, page by
+// page, then sends the actions on them in batches, retried on failure and
+// paced by the rate limits of the API.
+package pocketops
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/bulk"
+)
+
+// Default sizes of the requests made.
+const (
+	DefaultPageSize  = 500
+	DefaultBatchSize = 100
+)
+
+// Filter reports whether an item is to be changed. A nil Filter selects
+// every item retrieved.
+type Filter func(item api.Item) bool
+
+// Options tune a bulk operation. A nil *Options uses the defaults.
+type Options struct {
+	// Retrieve narrows the items the filter is applied to, as to a state
+	// or a tag. By default, all items are retrieved with their tags. Count
+	// and Offset are set for each page.
+	Retrieve *api.RetrieveOption
+	// PageSize is the number of items retrieved per request, and BatchSize
+	// the number of actions sent per request.
+	PageSize  int
+	BatchSize int
+
+	// Reporter, if set, is told of the progress of the operation.
+	Reporter ProgressReporter
+	// Interrupted, if set, is checked before each request and while waiting.
+	// Once it returns true, the operation stops with bulk.ErrInterrupted.
+	Interrupted func() bool
+}
+
+// ProgressReporter is told of the progress of a bulk operation, as to show
+// a progress bar. Its methods are called from one goroutine at a time, but
+// not always the same one: they run on the worker goroutines sending the
+// actions, while Options.Interrupted is called from the goroutine handing
+// the actions out, so a reporter whose state Interrupted reads must guard
+// it, as with a sync.Mutex.
+type ProgressReporter interface {
+	// OnPage is called after each page retrieved, with the number of items
+	// retrieved so far and of those selected.
+	OnPage(retrieved, selected int)
+	// OnAction is called after each batch sent, with the number of actions
+	// sent so far and their total.
+	OnAction(done, total int)
+	// OnRetry is called before a failed request is tried again, with the
+	// number of the retry, from 1, and the error.
+	OnRetry(retry int, err error)
+	// OnRateLimitWait is called when requests wait for the rate limits to
+	// reset, with how long.
+	OnRateLimitWait(wait time.Duration)
+}
+
+// NopReporter does nothing. Embed it to implement only some of the methods
+// of ProgressReporter.
+type NopReporter struct{}
+
+func (NopReporter) OnPage(retrieved, selected int)     {}
+func (NopReporter) OnAction(done, total int)           {}
+func (NopReporter) OnRetry(retry int, err error)       {}
+func (NopReporter) OnRateLimitWait(wait time.Duration) {}
+
+// Observe reports the progress of the tasks e runs, as actions, their
+// retries and waits to r.
+//
+// Experimental: the bulk package is not under the compatibility rules of
+// v1.
+func Observe(e *bulk.Engine, r ProgressReporter) {
+	e.Progress = r.OnAction
+	e.Retrying = r.OnRetry
+	e.Waiting = r.OnRateLimitWait
+}
+
+func (o *Options) reporter() ProgressReporter {
+	if o == nil || o.Reporter == nil {
+		return NopReporter{}
+	}
+	return o.Reporter
+}
+
+func (o *Options) pageSize() int {
+	if o == nil || o.PageSize <= 0 {
+		return DefaultPageSize
+	}
+	return o.PageSize
+}
+
+func (o *Options) batchSize() int {
+	if o == nil || o.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return o.BatchSize
+}
+
+func (o *Options) interrupted() bool {
+	return o != nil && o.Interrupted != nil && o.Interrupted()
+}
+
+// Select retrieves the items filter selects.
+func Select(client *api.Client, filter Filter, opts *Options) ([]api.Item, error) {
+	base := api.RetrieveOption{State: api.StateAll, DetailType: api.DetailTypeComplete}
+	if opts != nil && opts.Retrieve != nil {
+		base = *opts.Retrieve
+	}
+	pageSize := opts.pageSize()
+	reporter := opts.reporter()
+
+	items := []api.Item{}
+	retrieved := 0
+	for offset := 0; ; offset += pageSize {
+		if opts.interrupted() {
+			return nil, bulk.ErrInterrupted
+		}
+		options := base
+		options.Count, options.Offset = pageSize, offset
+		res, err := client.Retrieve(&options)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range res.Items() {
+			if filter == nil || filter(item) {
+				items = append(items, item)
+			}
+		}
+		retrieved += len(res.List)
+		reporter.OnPage(retrieved, len(items))
+		if len(res.List) < pageSize {
+			return items, nil
+		}
+	}
+}
+
+// quotaError makes a batch wait for the rate limits to reset.
+type quotaError struct {
+	wait time.Duration
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("the rate limit is used up for %s", e.wait.Round(time.Second))
+}
+
+// quotaWait returns how long to wait for the rate limits of usage to leave
+// a request, or zero.
+func quotaWait(usage api.Usage, now time.Time) time.Duration {
+	var wait time.Duration
+	if usage.UserRemaining == 0 && usage.UserReset.After(now) {
+		wait = usage.UserReset.Sub(now)
+	}
+	if usage.KeyRemaining == 0 && usage.KeyReset.After(now) {
+		wait = max(wait, usage.KeyReset.Sub(now))
+	}
+	return wait
+}
+
+// Send sends actions in batches, one at a time, returning one result per
+// action. A batch is not sent while the rate limits last seen are used up,
+// but once they reset. Failed batches are retried; if one still fails, the
+// rest are not sent, and their results are left zero.
+func Send(client *api.Client, actions []*api.Action, opts *Options) ([]api.ActionResult, error) {
+	results := make([]api.ActionResult, len(actions))
+	batchSize := opts.batchSize()
+	batches := (len(actions) + batchSize - 1) / batchSize
+
+	e := bulk.New(1)
+	e.StopOnError = true
+	e.Retryable = func(err error) (bool, time.Duration) {
+		var quotaErr *quotaError
+		if errors.As(err, &quotaErr) {
+			return true, quotaErr.wait
+		}
+		return bulk.Retryable(err)
+	}
+	reporter := opts.reporter()
+	Observe(e, reporter)
+	e.Progress = func(done, total int) {
+		reporter.OnAction(min(done*batchSize, len(actions)), len(actions))
+	}
+	if opts != nil {
+		e.Interrupted = opts.Interrupted
+	}
+
+	errs, err := e.Run(batches, func(b int) error {
+		if wait := quotaWait(api.CurrentUsage(), time.Now()); wait > 0 {
+			return &quotaError{wait: wait}
+		}
+		start := b * batchSize
+		end := min(start+batchSize, len(actions))
+		res, err := client.Modify(actions[start:end]...)
+		if err != nil {
+			return err
+		}
+		copy(results[start:end], res.ActionDetails)
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Result is the outcome of a bulk operation.
+type Result struct {
+	// Items are the items selected, and Results the results of the actions
+	// on them, in the same order. The results of the items left as they
+	// were, and of the actions not sent, are zero.
+	Items   []api.Item
+	Results []api.ActionResult
+}
+
+// Succeeded returns the number of actions Pocket made.
+func (r *Result) Succeeded() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Success {
+			n++
+		}
+	}
+	return n
+}
+
+// Apply sends the action returned by action for each item filter selects.
+// Items for which action returns nil are left as they are.
+func Apply(client *api.Client, filter Filter, action func(item api.Item) *api.Action, opts *Options) (*Result, error) {
+	items, err := Select(client, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, sent := []*api.Action{}, []int{}
+	for i, item := range items {
+		if a := action(item); a != nil {
+			actions = append(actions, a)
+			sent = append(sent, i)
+		}
+	}
+
+	result := &Result{Items: items, Results: make([]api.ActionResult, len(items))}
+	results, err := Send(client, actions, opts)
+	for j, i := range sent {
+		result.Results[i] = results[j]
+	}
+	return result, err
+}
+
+// ArchiveAll archives the unread items filter selects.
+func ArchiveAll(client *api.Client, filter Filter, opts *Options) (*Result, error) {
+	return Apply(client, filter, func(item api.Item) *api.Action {
+		if item.Status != api.ItemStatusUnread {
+			return nil
+		}
+		return api.NewArchiveAction(item.ItemID)
+	}, opts)
+}
+
+// DeleteAll deletes the items filter selects.
+func DeleteAll(client *api.Client, filter Filter, opts *Options) (*Result, error) {
+	return Apply(client, filter, func(item api.Item) *api.Action {
+		return api.NewDeleteAction(item.ItemID)
+	}, opts)
+}
+
+// TagAll adds tags to the items filter selects, leaving out those having
+// them all already.
+func TagAll(client *api.Client, filter Filter, tags []string, opts *Options) (*Result, error) {
+	return Apply(client, filter, func(item api.Item) *api.Action {
+		missing := []string{}
+		for _, tag := range tags {
+			if _, ok := item.Tags[tag]; !ok {
+				missing = append(missing, tag)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		return api.NewTagsAddAction(item.ItemID, missing...)
+	}, opts)
+}
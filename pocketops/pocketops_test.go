@@ -0,0 +1,129 @@
+package pocketops_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/bulk"
+	"github.com/motemen/go-pocket/pocketops"
+	"github.com/motemen/go-pocket/pockettest"
+	. "github.com/onsi/gomega"
+)
+
+func newServer(t *testing.T) (*pockettest.Server, *api.Client) {
+	server := pockettest.NewServer()
+	t.Cleanup(server.Close)
+	origin := api.Origin
+	api.Origin = server.URL
+	t.Cleanup(func() { api.Origin = origin })
+
+	for i := 1; i <= 7; i++ {
+		host := "example.com"
+		if i%2 == 0 {
+			host = "example.org"
+		}
+		item := api.Item{GivenURL: fmt.Sprintf("https://%s/%d", host, i), GivenTitle: fmt.Sprint(i)}
+		if i == 3 {
+			item.Status = api.ItemStatusArchived
+		}
+		server.Add(item)
+	}
+	return server, api.NewClient(server.ConsumerKey, server.AccessToken)
+}
+
+func fromExampleCom(item api.Item) bool {
+	return strings.HasPrefix(item.URL(), "https://example.com/")
+}
+
+// recorder records the progress reported. The actions are reported on the
+// workers of the bulk engine, and read by Interrupted on another goroutine.
+type recorder struct {
+	pocketops.NopReporter
+	mu             sync.Mutex
+	pages, actions [][2]int
+}
+
+func (r *recorder) OnPage(retrieved, selected int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pages = append(r.pages, [2]int{retrieved, selected})
+}
+
+func (r *recorder) OnAction(done, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actions = append(r.actions, [2]int{done, total})
+}
+
+func (r *recorder) actionsDone() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.actions)
+}
+
+func TestArchiveAll(t *testing.T) {
+	RegisterTestingT(t)
+
+	server, client := newServer(t)
+	reporter := &recorder{}
+	opts := &pocketops.Options{PageSize: 3, BatchSize: 2, Reporter: reporter}
+
+	// Item 3 is archived already, and left out of the actions
+	res, err := pocketops.ArchiveAll(client, fromExampleCom, opts)
+	Expect(err).To(BeNil())
+	Expect(res.Items).To(HaveLen(4))
+	Expect(res.Succeeded()).To(Equal(3))
+	Expect(reporter.pages).To(Equal([][2]int{{3, 2}, {6, 3}, {7, 4}}))
+	Expect(reporter.actions).To(Equal([][2]int{{2, 3}, {3, 3}}))
+
+	for _, item := range server.Items() {
+		archived := item.Status == api.ItemStatusArchived
+		Expect(archived).To(Equal(fromExampleCom(item)), item.URL())
+	}
+}
+
+func TestTagAllAndDeleteAll(t *testing.T) {
+	RegisterTestingT(t)
+
+	server, client := newServer(t)
+	opts := &pocketops.Options{PageSize: 2, BatchSize: 3}
+
+	res, err := pocketops.TagAll(client, fromExampleCom, []string{"old"}, opts)
+	Expect(err).To(BeNil())
+	Expect(res.Succeeded()).To(Equal(4))
+	// Tagged already, so nothing is sent
+	res, err = pocketops.TagAll(client, fromExampleCom, []string{"old"}, opts)
+	Expect(err).To(BeNil())
+	Expect(res.Items).To(HaveLen(4))
+	Expect(res.Succeeded()).To(BeZero())
+
+	res, err = pocketops.DeleteAll(client, func(item api.Item) bool {
+		_, ok := item.Tags["old"]
+		return ok
+	}, opts)
+	Expect(err).To(BeNil())
+	Expect(res.Succeeded()).To(Equal(4))
+	Expect(server.Items()).To(HaveLen(3))
+}
+
+func TestInterrupted(t *testing.T) {
+	RegisterTestingT(t)
+
+	server, client := newServer(t)
+	reporter := &recorder{}
+	opts := &pocketops.Options{
+		BatchSize: 1,
+		Reporter:  reporter,
+		Interrupted: func() bool {
+			return reporter.actionsDone() >= 2
+		},
+	}
+
+	res, err := pocketops.DeleteAll(client, nil, opts)
+	Expect(err).To(Equal(bulk.ErrInterrupted))
+	Expect(res.Succeeded()).To(Equal(2))
+	Expect(server.Items()).To(HaveLen(5))
+}
@@ -0,0 +1,527 @@
+// Package pockettest serves a fake Pocket API for tests, keeping the items of
+// one account in memory. It answers the requests of the api and auth
+// packages as Pocket does: retrieving with filters, sorting and paging,
+// adding, sending actions, and the OAuth flow, whose authorization page
+// approves at once and redirects back. It also serves the Article View API
+// for the articles set with SetArticle.
+package pockettest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/motemen/go-pocket/api"
+)
+
+// Server is a fake Pocket API, listening on a local address given by URL.
+type Server struct {
+	*httptest.Server
+
+	// ConsumerKey and AccessToken are those accepted; requests with others
+	// fail with 403 and 401.
+	ConsumerKey string
+	AccessToken string
+
+	mu         sync.Mutex
+	items      map[int]*api.Item
+	nextID     int
+	authorized bool
+	down       bool
+	gone       map[string]bool
+	requests   map[string]int
+	articles   map[string]api.Article
+}
+
+// NewServer starts a server with no items, accepting the consumer key
+// "consumer-key" and the access token "access-token". It is to be closed
+// by the caller.
+func NewServer() *Server {
+	s := &Server{
+		ConsumerKey: "consumer-key",
+		AccessToken: "access-token",
+		items:       map[int]*api.Item{},
+		nextID:      1,
+		requests:    map[string]int{},
+		gone:        map[string]bool{},
+		articles:    map[string]api.Article{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/oauth/request", s.handleRequestToken)
+	mux.HandleFunc("/auth/authorize", s.handleAuthorizePage)
+	mux.HandleFunc("/v3/oauth/authorize", s.handleAccessToken)
+	mux.HandleFunc("/v3/get", s.handleGet)
+	mux.HandleFunc("/v3/add", s.handleAdd)
+	mux.HandleFunc("/v3/send", s.handleSend)
+	mux.HandleFunc("/v3/text", s.handleText)
+	s.Server = httptest.NewServer(s.count(mux))
+	return s
+}
+
+// count counts requests by path, drops them while the server is down, and
+// answers 410 Gone to those to endpoints retired.
+func (s *Server) count(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		down, gone := s.down, s.gone[r.URL.Path]
+		s.mu.Unlock()
+		if down {
+			panic(http.ErrAbortHandler)
+		}
+		if gone {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// SetDown makes the server close the connection of every request, as if
+// Pocket could not be reached, or serve them again.
+func (s *Server) SetDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// SetGone makes the server answer 410 Gone to requests to path, as in
+// "/v3/get", as Pocket would once it retires the endpoint, or serve them
+// again.
+func (s *Server) SetGone(path string, gone bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gone[path] = gone
+}
+
+// Requests returns the number of requests made to path, as in "/v3/send".
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// SetArticle sets the parsed article the Article View API answers for url,
+// which it fails to parse otherwise.
+func (s *Server) SetArticle(url string, article api.Article) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if article.ResolvedURL == "" {
+		article.ResolvedURL = url
+	}
+	s.articles[url] = article
+}
+
+// Add stores item, with a new ID unless it has one, and returns the ID.
+// The URLs, title, and times left unset are filled in.
+func (s *Server) Add(item api.Item) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(item).ItemID
+}
+
+func (s *Server) add(item api.Item) *api.Item {
+	if item.ItemID == 0 {
+		item.ItemID = s.nextID
+	}
+	s.nextID = max(s.nextID, item.ItemID+1)
+	item.ResolvedId = item.ItemID
+	if item.GivenURL == "" {
+		item.GivenURL = item.ResolvedURL
+	}
+	if item.ResolvedURL == "" {
+		item.ResolvedURL = item.GivenURL
+	}
+	if item.ResolvedTitle == "" {
+		item.ResolvedTitle = item.GivenTitle
+	}
+	now := time.Now()
+	if item.TimeAdded.IsZero() {
+		item.TimeAdded = api.Time{Time: now}
+	}
+	item.TimeUpdated = api.Time{Time: now}
+	for tag := range item.Tags {
+		item.Tags[tag] = tagEntry(item.ItemID, tag)
+	}
+	s.items[item.ItemID] = &item
+	return &item
+}
+
+func tagEntry(itemID int, tag string) map[string]interface{} {
+	return map[string]interface{}{"item_id": fmt.Sprint(itemID), "tag": tag}
+}
+
+// Item returns the item with id, unless there is none or it was deleted.
+func (s *Server) Item(id int) (api.Item, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok || item.Status == api.ItemStatusDeleted {
+		return api.Item{}, false
+	}
+	return *item, true
+}
+
+// Items returns the items not deleted, in the order of their IDs.
+func (s *Server) Items() []api.Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := []api.Item{}
+	for _, item := range s.items {
+		if item.Status != api.ItemStatusDeleted {
+			items = append(items, *item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ItemID < items[j].ItemID })
+	return items
+}
+
+// fail answers with an error of the API, as Pocket does, in the headers.
+func fail(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("X-Error", message)
+	w.WriteHeader(status)
+}
+
+func respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// decode reads the request into v, failing unless it carries the consumer
+// key and, if withToken, the access token.
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}, withToken bool) bool {
+	var body struct {
+		ConsumerKey string `json:"consumer_key"`
+		AccessToken string `json:"access_token"`
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		fail(w, http.StatusBadRequest, "Invalid request")
+		return false
+	}
+	json.Unmarshal(raw, &body)
+	if body.ConsumerKey != s.ConsumerKey {
+		fail(w, http.StatusForbidden, "Invalid consumer key.")
+		return false
+	}
+	if withToken && body.AccessToken != s.AccessToken {
+		fail(w, http.StatusUnauthorized, "Invalid access token.")
+		return false
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		fail(w, http.StatusBadRequest, "Invalid request")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleRequestToken(w http.ResponseWriter, r *http.Request) {
+	var req struct{}
+	if !s.decode(w, r, &req, false) {
+		return
+	}
+	respond(w, map[string]string{"code": "request-token"})
+}
+
+// handleAuthorizePage stands for the page where the user approves the
+// application: it approves and redirects to redirect_uri.
+func (s *Server) handleAuthorizePage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.authorized = true
+	s.mu.Unlock()
+	http.Redirect(w, r, r.URL.Query().Get("redirect_uri"), http.StatusFound)
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if !s.decode(w, r, &req, false) {
+		return
+	}
+	s.mu.Lock()
+	authorized := s.authorized
+	s.mu.Unlock()
+	if req.Code != "request-token" || !authorized {
+		fail(w, http.StatusForbidden, "User rejected code.")
+		return
+	}
+	respond(w, map[string]string{"access_token": s.AccessToken, "username": "pockettest"})
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	var opts api.RetrieveOption
+	if !s.decode(w, r, &opts, true) {
+		return
+	}
+
+	// Held until the response is written, as the items share their maps
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := []api.Item{}
+	for _, item := range s.items {
+		if matches(item, &opts) {
+			items = append(items, *item)
+		}
+	}
+
+	sortItems(items, opts.Sort)
+	if opts.Offset > 0 {
+		items = items[min(opts.Offset, len(items)):]
+	}
+	if opts.Count > 0 {
+		items = items[:min(opts.Count, len(items))]
+	}
+
+	list := map[string]api.Item{}
+	for i, item := range items {
+		item.SortId = i
+		if opts.DetailType != api.DetailTypeComplete {
+			item.Tags, item.Authors, item.Images, item.Videos = nil, nil, nil, nil
+		}
+		list[fmt.Sprint(item.ItemID)] = item
+	}
+	res := map[string]interface{}{
+		"status":   1,
+		"complete": 1,
+		"since":    time.Now().Unix(),
+		"list":     list,
+	}
+	// As Pocket does, an empty list is an array
+	if len(list) == 0 {
+		res["list"] = []interface{}{}
+	}
+	respond(w, res)
+}
+
+// matches tells if item is retrieved with opts. Deleted items are only
+// retrieved as changes since a time.
+func matches(item *api.Item, opts *api.RetrieveOption) bool {
+	if opts.Since > 0 {
+		if item.TimeUpdated.Unix() < int64(opts.Since) {
+			return false
+		}
+	} else if item.Status == api.ItemStatusDeleted {
+		return false
+	}
+
+	switch opts.State {
+	case "", api.StateUnread:
+		if item.Status == api.ItemStatusArchived {
+			return false
+		}
+	case api.StateArchive:
+		if item.Status == api.ItemStatusUnread {
+			return false
+		}
+	}
+	if opts.Favorite != api.FavoriteFilterUnspecified && fmt.Sprint(item.Favorite) != string(opts.Favorite) {
+		return false
+	}
+	switch opts.Tag {
+	case "":
+	case "_untagged_":
+		if len(item.Tags) > 0 {
+			return false
+		}
+	default:
+		if _, ok := item.Tags[opts.Tag]; !ok {
+			return false
+		}
+	}
+	switch opts.ContentType {
+	case api.ContentTypeArticle:
+		if item.IsArticle != 1 {
+			return false
+		}
+	case api.ContentTypeVideo:
+		if item.HasVideo == api.ItemMediaAttachmentNoMedia {
+			return false
+		}
+	case api.ContentTypeImage:
+		if item.HasImage == api.ItemMediaAttachmentNoMedia {
+			return false
+		}
+	}
+	if opts.Domain != "" {
+		domain := strings.TrimPrefix(strings.ToLower(opts.Domain), "www.")
+		if d := item.Domain(); d != domain && !strings.HasSuffix(d, "."+domain) {
+			return false
+		}
+	}
+	if opts.Search != "" {
+		search := strings.ToLower(opts.Search)
+		if !strings.Contains(strings.ToLower(item.Title()), search) && !strings.Contains(strings.ToLower(item.URL()), search) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortItems(items []api.Item, by api.Sort) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		switch by {
+		case api.SortOldest:
+			return a.TimeAdded.Before(b.TimeAdded.Time) || a.TimeAdded.Equal(b.TimeAdded.Time) && a.ItemID < b.ItemID
+		case api.SortTitle:
+			return a.Title() < b.Title()
+		case api.SortSite:
+			return a.URL() < b.URL()
+		default:
+			return a.TimeAdded.After(b.TimeAdded.Time) || a.TimeAdded.Equal(b.TimeAdded.Time) && a.ItemID > b.ItemID
+		}
+	})
+}
+
+func (s *Server) handleText(w http.ResponseWriter, r *http.Request) {
+	var opts struct {
+		URL string `json:"url"`
+	}
+	if !s.decode(w, r, &opts, false) {
+		return
+	}
+
+	s.mu.Lock()
+	article, ok := s.articles[opts.URL]
+	s.mu.Unlock()
+	if !ok {
+		fail(w, http.StatusBadRequest, "Could not parse the article")
+		return
+	}
+	respond(w, article)
+}
+
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
+	var opts api.AddOption
+	if !s.decode(w, r, &opts, true) {
+		return
+	}
+	if opts.URL == "" {
+		fail(w, http.StatusBadRequest, "Missing URL")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	respond(w, map[string]interface{}{"status": 1, "item": s.addURL(opts.URL, opts.Title, opts.Tags, 0)})
+}
+
+// addURL adds the item with url, or updates the one already saved with it,
+// as Pocket does.
+func (s *Server) addURL(url, title, tags string, at int64) *api.Item {
+	item := api.Item{GivenURL: url, GivenTitle: title, Tags: map[string]map[string]interface{}{}}
+	for _, existing := range s.items {
+		if existing.GivenURL == url && existing.Status != api.ItemStatusDeleted {
+			item = *existing
+			item.Status = api.ItemStatusUnread
+		}
+	}
+	if item.Tags == nil {
+		item.Tags = map[string]map[string]interface{}{}
+	}
+	for _, tag := range splitTags(tags) {
+		item.Tags[tag] = nil
+	}
+	if at > 0 {
+		item.TimeAdded = api.Time{Time: time.Unix(at, 0)}
+	}
+	return s.add(item)
+}
+
+func splitTags(tags string) []string {
+	names := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			names = append(names, tag)
+		}
+	}
+	return names
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Actions []*api.Action `json:"actions"`
+	}
+	if !s.decode(w, r, &req, true) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := []interface{}{}
+	errors := []interface{}{}
+	for _, action := range req.Actions {
+		result, err := s.do(action)
+		results = append(results, result)
+		if err != "" {
+			errors = append(errors, map[string]interface{}{"message": err, "type": "Bad Request", "code": 422})
+		} else {
+			errors = append(errors, nil)
+		}
+	}
+	respond(w, map[string]interface{}{"status": 1, "action_results": results, "action_errors": errors})
+}
+
+// do applies action, returning its result and, if it failed, why.
+func (s *Server) do(action *api.Action) (interface{}, string) {
+	if action.Action == "add" {
+		if action.URL == "" {
+			return false, "Missing URL"
+		}
+		return s.addURL(action.URL, action.Title, action.Tags, action.Time), ""
+	}
+
+	item, ok := s.items[action.ItemID]
+	if !ok || item.Status == api.ItemStatusDeleted {
+		return false, "Invalid item"
+	}
+	at := time.Now()
+	if action.Time > 0 {
+		at = time.Unix(action.Time, 0)
+	}
+	switch action.Action {
+	case "archive":
+		item.Status, item.TimeRead = api.ItemStatusArchived, api.Time{Time: at}
+	case "readd":
+		item.Status, item.TimeRead = api.ItemStatusUnread, api.Time{}
+	case "delete":
+		item.Status = api.ItemStatusDeleted
+	case "favorite":
+		item.Favorite, item.TimeFavorited = 1, api.Time{Time: at}
+	case "unfavorite":
+		item.Favorite, item.TimeFavorited = 0, api.Time{}
+	case "tags_add", "tags_replace":
+		if action.Action == "tags_replace" || item.Tags == nil {
+			item.Tags = map[string]map[string]interface{}{}
+		}
+		for _, tag := range splitTags(action.Tags) {
+			item.Tags[tag] = tagEntry(item.ItemID, tag)
+		}
+	case "tags_remove":
+		for _, tag := range splitTags(action.Tags) {
+			delete(item.Tags, tag)
+		}
+	case "tags_clear":
+		item.Tags = nil
+	case "add_annotation":
+		if action.Annotation == nil || action.Annotation.Quote == "" {
+			return false, "Missing quote"
+		}
+		item.Annotations = append(item.Annotations, api.Annotation{
+			AnnotationID: fmt.Sprintf("%d-%d", item.ItemID, len(item.Annotations)+1),
+			ItemID:       item.ItemID,
+			Quote:        action.Annotation.Quote,
+			CreatedAt:    at.UTC().Format("2006-01-02 15:04:05"),
+		})
+	default:
+		return false, fmt.Sprintf("Unknown action %q", action.Action)
+	}
+	item.TimeUpdated = api.Time{Time: time.Now()}
+	return true, ""
+}
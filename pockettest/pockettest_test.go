@@ -0,0 +1,67 @@
+package pockettest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/motemen/go-pocket/api"
+	"github.com/motemen/go-pocket/pockettest"
+	. "github.com/onsi/gomega"
+)
+
+func TestServer(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := pockettest.NewServer()
+	defer s.Close()
+	origin, articleOrigin := api.Origin, api.ArticleOrigin
+	api.Origin, api.ArticleOrigin = s.URL, s.URL
+	defer func() { api.Origin, api.ArticleOrigin = origin, articleOrigin }()
+
+	client := api.NewClient(s.ConsumerKey, s.AccessToken)
+	res, err := client.Retrieve(&api.RetrieveOption{})
+	Expect(err).To(BeNil())
+	Expect(res.List).To(BeEmpty())
+
+	id := s.Add(api.Item{GivenURL: "https://example.com/a", GivenTitle: "A"})
+	modified, err := client.Modify(
+		api.NewAddAction("https://example.com/b", "B", "x"),
+		api.NewArchiveAction(id),
+		api.NewDeleteAction(999),
+	)
+	Expect(err).To(BeNil())
+	Expect(modified.ActionDetails[0].Success).To(BeTrue())
+	Expect(modified.ActionDetails[0].ItemID).To(Equal(id + 1))
+	Expect(modified.ActionDetails[1].Success).To(BeTrue())
+	Expect(modified.ActionDetails[2].Error).To(Equal("Invalid item (code 422)"))
+	Expect(modified.ActionResults).To(Equal([]bool{true, true, false}))
+
+	res, err = client.Retrieve(&api.RetrieveOption{DetailType: api.DetailTypeComplete})
+	Expect(err).To(BeNil())
+	items := res.Items()
+	Expect(items).To(HaveLen(1))
+	Expect(items[0].URL()).To(Equal("https://example.com/b"))
+	Expect(items[0].TagNames()).To(Equal([]string{"x"}))
+
+	// Deleted items are among the changes since a time
+	_, err = client.Modify(api.NewDeleteAction(id))
+	Expect(err).To(BeNil())
+	res, err = client.Retrieve(&api.RetrieveOption{State: api.StateAll, Since: int(items[0].TimeAdded.Unix())})
+	Expect(err).To(BeNil())
+	Expect(res.List).To(HaveKey("1"))
+	Expect(res.List["1"].Status).To(Equal(api.ItemStatus(api.ItemStatusDeleted)))
+	Expect(s.Items()).To(HaveLen(1))
+
+	s.SetArticle("https://example.com/b", api.Article{Title: "B", HTML: "<p>Text</p>"})
+	article, err := client.Article("https://example.com/b")
+	Expect(err).To(BeNil())
+	Expect(article.HTML).To(Equal("<p>Text</p>"))
+	Expect(article.ResolvedURL).To(Equal("https://example.com/b"))
+	_, err = client.Article("https://example.com/a")
+	Expect(err).NotTo(BeNil())
+
+	_, err = api.NewClient(s.ConsumerKey, "wrong").Retrieve(&api.RetrieveOption{})
+	var apiErr *api.Error
+	Expect(errors.As(err, &apiErr)).To(BeTrue())
+	Expect(apiErr.StatusCode).To(Equal(401))
+}
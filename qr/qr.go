@@ -0,0 +1,499 @@
+// Package qr encodes text as QR codes (ISO/IEC 18004, byte mode) and renders
+// them for terminals.
+package qr
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level is the error correction level of a code.
+type Level int
+
+const (
+	// L recovers about 7% of the codewords.
+	L Level = iota
+	// M recovers about 15% of the codewords.
+	M
+)
+
+// formatBits are the bits identifying each level in the format information.
+var formatBits = [...]int{L: 1, M: 0}
+
+// eccCodewordsPerBlock and numErrorCorrectionBlocks describe the blocks of
+// each version (index 1 to 40) at each level.
+var eccCodewordsPerBlock = [...][41]int{
+	L: {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	M: {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+}
+
+var numErrorCorrectionBlocks = [...][41]int{
+	L: {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	M: {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+}
+
+// ErrTooLong is returned when text does not fit in the largest version.
+var ErrTooLong = errors.New("qr: text too long")
+
+// Code is an encoded QR code.
+type Code struct {
+	// Version is the version (1 to 40) of the code.
+	Version int
+	// Size is the number of modules on each side, 17 + 4 * Version.
+	Size int
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// Black reports whether the module at (x, y) is dark. Modules outside the
+// code, in the quiet zone, are light.
+func (c *Code) Black(x, y int) bool {
+	return 0 <= x && x < c.Size && 0 <= y && y < c.Size && c.modules[y][x]
+}
+
+// Encode encodes text in byte mode in the smallest version that fits.
+func Encode(text string, level Level) (*Code, error) {
+	data := []byte(text)
+
+	version := 1
+	for ; ; version++ {
+		if version > 40 {
+			return nil, ErrTooLong
+		}
+		if 4+charCountBits(version)+8*len(data) <= 8*numDataCodewords(version, level) {
+			break
+		}
+	}
+
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := 8 * numDataCodewords(version, level)
+	terminator := capacity - len(bb)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bb.append(0, terminator)
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	c := &Code{Version: version, Size: 17 + 4*version}
+	c.modules = make([][]bool, c.Size)
+	c.isFunction = make([][]bool, c.Size)
+	for y := range c.modules {
+		c.modules[y] = make([]bool, c.Size)
+		c.isFunction[y] = make([]bool, c.Size)
+	}
+
+	c.drawFunctionPatterns(level)
+	c.drawCodewords(addErrorCorrection(codewords, version, level))
+
+	best, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(level, mask)
+		if penalty := c.penalty(); minPenalty < 0 || penalty < minPenalty {
+			best, minPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(level, best)
+
+	return c, nil
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (value>>i)&1 != 0)
+	}
+}
+
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules is the number of modules available for data and error
+// correction in a version.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+// addErrorCorrection splits data into blocks, appends the error correction
+// codewords to each, and interleaves them.
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockECC := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockECC)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		n := shortBlockLen - blockECC
+		if i >= numShortBlocks {
+			n++
+		}
+		block := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Padding, skipped when interleaving
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockECC || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+func (c *Code) setFunction(x, y int, black bool) {
+	c.modules[y][x] = black
+	c.isFunction[y][x] = true
+}
+
+// alignmentPositions returns the coordinates of the centers of the
+// alignment patterns on each axis.
+func (c *Code) alignmentPositions() []int {
+	if c.Version == 1 {
+		return nil
+	}
+	numAlign := c.Version/7 + 2
+	step := 26
+	if c.Version != 32 {
+		step = (c.Version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	}
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, c.Size-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (c *Code) drawFunctionPatterns(level Level) {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	for _, center := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if 0 <= x && x < c.Size && 0 <= y && y < c.Size {
+					dist := max(abs(dx), abs(dy))
+					c.setFunction(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+
+	positions := c.alignmentPositions()
+	last := len(positions) - 1
+	for i, cx := range positions {
+		for j, cy := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				// Overlaps a finder pattern
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information, drawn again once the mask is chosen.
+	c.drawFormatBits(level, 0)
+
+	if c.Version >= 7 {
+		rem := c.Version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := c.Version<<12 | rem
+		for i := 0; i < 18; i++ {
+			black := (bits>>i)&1 != 0
+			a, b := c.Size-11+i%3, i/3
+			c.setFunction(a, b, black)
+			c.setFunction(b, a, black)
+		}
+	}
+}
+
+func (c *Code) drawFormatBits(level Level, mask int) {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawCodewords places the codewords in the zigzag order, two columns at a
+// time from the bottom right.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y][x] && i < len(data)*8 {
+					c.modules[y][x] = (data[i>>3]>>(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by the mask pattern. Applying
+// the same mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !c.isFunction[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the code for the patterns that make it hard to scan.
+func (c *Code) penalty() int {
+	result := 0
+
+	line := make([]bool, c.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.Size; i++ {
+			for j := range line {
+				if vertical {
+					line[j] = c.modules[j][i]
+				} else {
+					line[j] = c.modules[i][j]
+				}
+			}
+
+			run := 1
+			for j := 1; j <= c.Size; j++ {
+				if j < c.Size && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+
+			// Finder-like patterns, with four light modules on either side
+			for j := 0; j+7 <= c.Size; j++ {
+				if !(line[j] && !line[j+1] && line[j+2] && line[j+3] && line[j+4] && !line[j+5] && line[j+6]) {
+					continue
+				}
+				if lightRun(line, j-4, j) || lightRun(line, j+7, j+11) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				color := c.modules[y][x]
+				if color == c.modules[y][x+1] && color == c.modules[y+1][x] && color == c.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+
+	return result
+}
+
+// lightRun reports whether line[from:to] is light, counting modules outside
+// the code as light.
+func lightRun(line []bool, from, to int) bool {
+	for i := from; i < to; i++ {
+		if 0 <= i && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// ANSI escapes for black on white
+const (
+	escColors = "\x1b[30;47m"
+	escReset  = "\x1b[0m"
+)
+
+// Terminal renders the code with half block characters, two modules per
+// character cell, surrounded by a quiet zone of the given width. The colors
+// are set explicitly so that the code scans on dark terminals too.
+func (c *Code) Terminal(quietZone int) string {
+	var b strings.Builder
+	for y := -quietZone; y < c.Size+quietZone; y += 2 {
+		b.WriteString(escColors)
+		for x := -quietZone; x < c.Size+quietZone; x++ {
+			top := c.Black(x, y)
+			bottom := y+1 < c.Size+quietZone && c.Black(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(escReset + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+package qr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motemen/go-pocket/qr"
+	. "github.com/onsi/gomega"
+)
+
+func TestEncodeVersion(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, tt := range []struct {
+		length  int
+		level   qr.Level
+		version int
+	}{
+		{14, qr.M, 1},
+		{15, qr.M, 2},
+		{17, qr.L, 1},
+		{213, qr.M, 10},
+		{214, qr.M, 11},
+	} {
+		code, err := qr.Encode(strings.Repeat("a", tt.length), tt.level)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code.Version).To(Equal(tt.version), "%d bytes", tt.length)
+		Expect(code.Size).To(Equal(17 + 4*tt.version))
+	}
+
+	_, err := qr.Encode(strings.Repeat("a", 3000), qr.M)
+	Expect(err).To(Equal(qr.ErrTooLong))
+}
+
+// formatInfo reads the format information next to the top left finder.
+func formatInfo(code *qr.Code) int {
+	bits := 0
+	set := func(i int, black bool) {
+		if black {
+			bits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(i, code.Black(8, i))
+	}
+	set(6, code.Black(8, 7))
+	set(7, code.Black(8, 8))
+	set(8, code.Black(7, 8))
+	for i := 9; i < 15; i++ {
+		set(i, code.Black(14-i, 8))
+	}
+	return bits ^ 0x5412
+}
+
+func TestEncodeFunctionPatterns(t *testing.T) {
+	RegisterTestingT(t)
+
+	code, err := qr.Encode("https://example.com/", qr.M)
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		for i := 0; i < 7; i++ {
+			Expect(code.Black(corner[0]+i, corner[1])).To(BeTrue())
+			Expect(code.Black(corner[0], corner[1]+i)).To(BeTrue())
+		}
+		Expect(code.Black(corner[0]+1, corner[1]+1)).To(BeFalse())
+		Expect(code.Black(corner[0]+3, corner[1]+3)).To(BeTrue())
+	}
+	for i := 8; i < code.Size-8; i++ {
+		Expect(code.Black(i, 6)).To(Equal(i%2 == 0))
+	}
+	Expect(code.Black(-1, 0)).To(BeFalse())
+
+	info := formatInfo(code)
+	Expect(info>>13).To(Equal(0), "level M")
+	rem := info >> 10
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	Expect(info & 0x3FF).To(Equal(rem))
+
+	code, err = qr.Encode(strings.Repeat("a", 100), qr.L)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(code.Version).To(Equal(5))
+	Expect(formatInfo(code)>>13).To(Equal(1), "level L")
+
+	code, err = qr.Encode(strings.Repeat("a", 120), qr.M)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(code.Version).To(Equal(7))
+	version := 0
+	for i := 0; i < 18; i++ {
+		if code.Black(code.Size-11+i%3, i/3) {
+			version |= 1 << i
+		}
+	}
+	Expect(version).To(Equal(0x07C94))
+}
+
+func gfMultiply(x, y int) int {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= ((y >> i) & 1) * x
+	}
+	return z
+}
+
+// TestEncodeData reads back a version 1 code: the data it holds, and that its
+// error correction codewords check out.
+func TestEncodeData(t *testing.T) {
+	RegisterTestingT(t)
+
+	text := "pocket://1234"
+	code, err := qr.Encode(text, qr.M)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(code.Version).To(Equal(1))
+
+	size := code.Size
+	isFunction := func(x, y int) bool {
+		return x < 9 && y < 9 || x >= size-8 && y < 9 || x < 9 && y >= size-8 || x == 6 || y == 6
+	}
+	masks := []func(x, y int) bool{
+		func(x, y int) bool { return (x+y)%2 == 0 },
+		func(x, y int) bool { return y%2 == 0 },
+		func(x, y int) bool { return x%3 == 0 },
+		func(x, y int) bool { return (x+y)%3 == 0 },
+		func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+		func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+		func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+		func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+	}
+	mask := masks[formatInfo(code)>>10&7]
+
+	codewords := make([]int, 26)
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if isFunction(x, y) || i >= 26*8 {
+					continue
+				}
+				if code.Black(x, y) != mask(x, y) {
+					codewords[i/8] |= 1 << (7 - i%8)
+				}
+				i++
+			}
+		}
+	}
+
+	// Mode 0100 (byte), an 8-bit length, then the bytes
+	Expect(codewords[0] >> 4).To(Equal(4))
+	Expect((codewords[0]&0xF)<<4 | codewords[1]>>4).To(Equal(len(text)))
+	data := []byte{}
+	for k := 0; k < len(text); k++ {
+		data = append(data, byte((codewords[1+k]&0xF)<<4|codewords[2+k]>>4))
+	}
+	Expect(string(data)).To(Equal(text))
+
+	// The codewords are a multiple of the generator polynomial, so they
+	// vanish at its roots.
+	root := 1
+	for k := 0; k < 10; k++ {
+		sum := 0
+		for _, c := range codewords {
+			sum = gfMultiply(sum, root) ^ c
+		}
+		Expect(sum).To(Equal(0), "syndrome %d", k)
+		root = gfMultiply(root, 2)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	RegisterTestingT(t)
+
+	code, err := qr.Encode("x", qr.M)
+	Expect(err).NotTo(HaveOccurred())
+
+	lines := strings.Split(strings.TrimSuffix(code.Terminal(2), "\n"), "\n")
+	Expect(lines).To(HaveLen(13))
+	Expect(lines[1]).To(ContainSubstring("█▀▀▀▀▀█"))
+}
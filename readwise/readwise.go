@@ -0,0 +1,114 @@
+// Package readwise adds highlights to Readwise through its API.
+package readwise
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Origin is the origin of the Readwise API used by new clients.
+var Origin = "https://readwise.io"
+
+// batchSize is the number of highlights sent in one request.
+const batchSize = 100
+
+// Highlight is a highlight to add. Readwise groups highlights into books by
+// their Title, Author, and SourceURL.
+type Highlight struct {
+	Text          string     `json:"text"`
+	Title         string     `json:"title,omitempty"`
+	Author        string     `json:"author,omitempty"`
+	SourceURL     string     `json:"source_url,omitempty"`
+	SourceType    string     `json:"source_type,omitempty"`
+	Category      string     `json:"category,omitempty"`
+	Note          string     `json:"note,omitempty"`
+	HighlightedAt *time.Time `json:"highlighted_at,omitempty"`
+}
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the API asks to wait, when rate limited.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return fmt.Sprintf("readwise: rate limited, retry after %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("readwise: got response %d: %s", e.StatusCode, e.Body)
+}
+
+// Client talks to the Readwise API with an access token.
+type Client struct {
+	// Token is the access token, from https://readwise.io/access_token.
+	Token  string
+	Origin string
+	Client *http.Client
+}
+
+// NewClient creates a client using token.
+func NewClient(token string) *Client {
+	return &Client{
+		Token:  token,
+		Origin: Origin,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Create adds highlights, in batches, calling done after each batch with the
+// highlights sent so far. Readwise skips highlights it already has.
+func (c *Client) Create(highlights []Highlight, done func(sent int)) error {
+	for start := 0; start < len(highlights); start += batchSize {
+		end := min(start+batchSize, len(highlights))
+		err := c.post("/api/v2/highlights/", map[string]interface{}{"highlights": highlights[start:end]})
+		if err != nil {
+			return err
+		}
+		if done != nil {
+			done(end)
+		}
+	}
+	return nil
+}
+
+func (c *Client) post(path string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Origin+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) error {
+	req.Header.Set("Authorization", "Token "+c.Token)
+	req.Header.Set("User-Agent", "go-pocket-readwise")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	apiErr := &Error{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(body))}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
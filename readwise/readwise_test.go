@@ -0,0 +1,75 @@
+package readwise_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/readwise"
+	. "github.com/onsi/gomega"
+)
+
+func TestCreate(t *testing.T) {
+	RegisterTestingT(t)
+
+	batches := [][]map[string]interface{}{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.Method).To(Equal(http.MethodPost))
+		Expect(r.URL.Path).To(Equal("/api/v2/highlights/"))
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"detail":"Invalid token."}`))
+			return
+		}
+		var body struct{ Highlights []map[string]interface{} }
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		batches = append(batches, body.Highlights)
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	client := readwise.NewClient("secret")
+	client.Origin = ts.URL
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	highlights := []readwise.Highlight{}
+	for i := 0; i < 150; i++ {
+		highlights = append(highlights, readwise.Highlight{Text: "quote", Title: "Title", SourceURL: "https://example.com/", HighlightedAt: &at})
+	}
+	progress := []int{}
+	Expect(client.Create(highlights, func(sent int) { progress = append(progress, sent) })).To(Succeed())
+	Expect(progress).To(Equal([]int{100, 150}))
+	Expect(batches).To(HaveLen(2))
+	Expect(batches[1]).To(HaveLen(50))
+	Expect(batches[0][0]).To(Equal(map[string]interface{}{
+		"text":           "quote",
+		"title":          "Title",
+		"source_url":     "https://example.com/",
+		"highlighted_at": "2024-01-02T03:04:05Z",
+	}))
+
+	client.Token = "wrong"
+	err := client.Create(highlights[:1], nil)
+	Expect(err).To(MatchError(`readwise: got response 401: {"detail":"Invalid token."}`))
+}
+
+func TestRateLimit(t *testing.T) {
+	RegisterTestingT(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := readwise.NewClient("secret")
+	client.Origin = ts.URL
+
+	err := client.Create([]readwise.Highlight{{Text: "quote"}}, nil)
+	var apiErr *readwise.Error
+	Expect(err).To(BeAssignableToTypeOf(apiErr))
+	Expect(err.(*readwise.Error).RetryAfter).To(Equal(30 * time.Second))
+	Expect(err).To(MatchError("readwise: rate limited, retry after 30s"))
+}
@@ -0,0 +1,239 @@
+// Package render writes the results of commands in the output formats of
+// the pocket command: text, JSON, CSV, Markdown, item IDs, and Go
+// templates. A command describes its result once, as a Result, and any
+// Renderer writes it; other programs can register renderers of their own.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Result is the result of a command, in the forms the renderers take. The
+// forms left out are those the command has no use for, and the renderers
+// needing them do not accept the result.
+type Result struct {
+	// Value is the whole result, as JSON encodes it.
+	Value any
+	// Columns name the fields of each of Rows, for tables.
+	Columns []string
+	Rows    [][]string
+	// Records are the values a template is executed with, one after
+	// another, as the items listed. By default, they are the rows, each as
+	// a map from the names of the columns to the values.
+	Records []any
+	// IDs are the IDs of the items the result lists, if it lists items.
+	IDs []int
+	// Text writes the result as shown on a terminal. By default, the rows
+	// are aligned under the columns.
+	Text func(w io.Writer) error
+}
+
+// records returns the values a template is executed with.
+func (r *Result) records() []any {
+	if r.Records != nil {
+		return r.Records
+	}
+	records := make([]any, len(r.Rows))
+	for i, row := range r.Rows {
+		record := map[string]string{}
+		for j, column := range r.Columns {
+			if j < len(row) {
+				record[column] = row[j]
+			}
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// Renderer writes results in an output format.
+type Renderer interface {
+	Render(w io.Writer, r *Result) error
+}
+
+// Acceptor is a Renderer telling which results it can render. Renderers
+// that are not Acceptors are taken to render any.
+type Acceptor interface {
+	Accepts(r *Result) bool
+}
+
+// ErrNotAccepted is returned by Render for a result the renderer lacks the
+// form of, as CSV does for a result without columns.
+var ErrNotAccepted = errors.New("the result is not given in this form")
+
+// Func is a Renderer of the given acceptance and rendering.
+type Func struct {
+	// AcceptsFunc, if not nil, tells the results rendered.
+	AcceptsFunc func(r *Result) bool
+	RenderFunc  func(w io.Writer, r *Result) error
+}
+
+// Accepts implements Acceptor.
+func (f Func) Accepts(r *Result) bool {
+	return f.AcceptsFunc == nil || f.AcceptsFunc(r)
+}
+
+// Render implements Renderer.
+func (f Func) Render(w io.Writer, r *Result) error {
+	if !f.Accepts(r) {
+		return ErrNotAccepted
+	}
+	return f.RenderFunc(w, r)
+}
+
+// Accepts reports whether renderer can render r.
+func Accepts(renderer Renderer, r *Result) bool {
+	a, ok := renderer.(Acceptor)
+	return !ok || a.Accepts(r)
+}
+
+// Text writes the Text of a result, or else its rows aligned under its
+// columns.
+var Text Renderer = Func{
+	AcceptsFunc: func(r *Result) bool { return r.Text != nil || r.Columns != nil },
+	RenderFunc: func(w io.Writer, r *Result) error {
+		if r.Text != nil {
+			return r.Text(w)
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.Columns, "\t")))
+		for _, row := range r.Rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	},
+}
+
+// JSON writes the Value of a result, indented.
+var JSON Renderer = Func{
+	AcceptsFunc: func(r *Result) bool { return r.Value != nil },
+	RenderFunc: func(w io.Writer, r *Result) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.Value)
+	},
+}
+
+// CSV writes the rows of a result under a header of its columns.
+var CSV Renderer = Func{
+	AcceptsFunc: func(r *Result) bool { return r.Columns != nil },
+	RenderFunc: func(w io.Writer, r *Result) error {
+		cw := csv.NewWriter(w)
+		cw.Write(r.Columns)
+		cw.WriteAll(r.Rows)
+		return cw.Error()
+	},
+}
+
+// Markdown writes the rows of a result as a table.
+var Markdown Renderer = Func{
+	AcceptsFunc: func(r *Result) bool { return r.Columns != nil },
+	RenderFunc: func(w io.Writer, r *Result) error {
+		line := func(cells []string) {
+			escaped := make([]string, len(cells))
+			for i, cell := range cells {
+				cell = strings.ReplaceAll(cell, "|", `\|`)
+				escaped[i] = strings.ReplaceAll(cell, "\n", " ")
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		}
+		line(r.Columns)
+		rule := make([]string, len(r.Columns))
+		for i := range rule {
+			rule[i] = "---"
+		}
+		line(rule)
+		for _, row := range r.Rows {
+			line(row)
+		}
+		return nil
+	},
+}
+
+// IDs writes the IDs of the items of a result one per line, to pipe into
+// "pocket archive -" and the like.
+var IDs Renderer = Func{
+	AcceptsFunc: func(r *Result) bool { return r.IDs != nil },
+	RenderFunc: func(w io.Writer, r *Result) error {
+		for _, id := range r.IDs {
+			if _, err := fmt.Fprintln(w, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Executor is a template, of text/template or html/template.
+type Executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// Template returns a renderer executing t with each record of a result, a
+// line each.
+func Template(t Executor) Renderer {
+	return Func{
+		AcceptsFunc: func(r *Result) bool { return r.Records != nil || r.Columns != nil },
+		RenderFunc: func(w io.Writer, r *Result) error {
+			for _, record := range r.records() {
+				if err := t.Execute(w, record); err != nil {
+					return err
+				}
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+var registry = struct {
+	sync.Mutex
+	renderers map[string]Renderer
+}{renderers: map[string]Renderer{
+	"text":     Text,
+	"json":     JSON,
+	"csv":      CSV,
+	"markdown": Markdown,
+	"ids":      IDs,
+}}
+
+// Register makes renderer the one of the output format name, replacing
+// any registered before under that name.
+func Register(name string, renderer Renderer) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.renderers[name] = renderer
+}
+
+// Lookup returns the renderer registered under name.
+func Lookup(name string) (Renderer, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	renderer, ok := registry.renderers[name]
+	return renderer, ok
+}
+
+// Names returns the names of the renderers registered accepting r, or of
+// all of them if r is nil, in order.
+func Names(r *Result) []string {
+	registry.Lock()
+	defer registry.Unlock()
+	names := []string{}
+	for name, renderer := range registry.renderers {
+		if r == nil || Accepts(renderer, r) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,70 @@
+package render_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"text/template"
+
+	"github.com/motemen/go-pocket/render"
+	. "github.com/onsi/gomega"
+)
+
+func tagsResult() *render.Result {
+	return &render.Result{
+		Value:   map[string]int{"go": 2, "a|b": 1},
+		Columns: []string{"tag", "items"},
+		Rows:    [][]string{{"go", "2"}, {"a|b", "1"}},
+	}
+}
+
+func rendered(renderer render.Renderer, r *render.Result) string {
+	var buf bytes.Buffer
+	Expect(renderer.Render(&buf, r)).To(Succeed())
+	return buf.String()
+}
+
+func TestRenderers(t *testing.T) {
+	RegisterTestingT(t)
+
+	r := tagsResult()
+	Expect(rendered(render.Text, r)).To(Equal("TAG  ITEMS\ngo   2\na|b  1\n"))
+	Expect(rendered(render.JSON, r)).To(Equal("{\n  \"a|b\": 1,\n  \"go\": 2\n}\n"))
+	Expect(rendered(render.CSV, r)).To(Equal("tag,items\ngo,2\na|b,1\n"))
+	Expect(rendered(render.Markdown, r)).To(Equal("| tag | items |\n| --- | --- |\n| go | 2 |\n| a\\|b | 1 |\n"))
+	Expect(rendered(render.Template(template.Must(template.New("").Parse("{{.tag}}={{.items}}"))), r)).To(Equal("go=2\na|b=1\n"))
+
+	// Without IDs, the result lists no items
+	Expect(render.Accepts(render.IDs, r)).To(BeFalse())
+	Expect(render.IDs.Render(io.Discard, r)).To(MatchError(render.ErrNotAccepted))
+	r.IDs = []int{3, 1}
+	Expect(rendered(render.IDs, r)).To(Equal("3\n1\n"))
+
+	r.Text = func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, "2 tags")
+		return err
+	}
+	Expect(rendered(render.Text, r)).To(Equal("2 tags\n"))
+}
+
+func TestRegistry(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(render.Names(nil)).To(Equal([]string{"csv", "ids", "json", "markdown", "text"}))
+	Expect(render.Names(&render.Result{Value: 1, Text: func(io.Writer) error { return nil }})).To(Equal([]string{"json", "text"}))
+
+	_, ok := render.Lookup("yaml")
+	Expect(ok).To(BeFalse())
+	render.Register("count", render.Func{
+		AcceptsFunc: func(r *render.Result) bool { return r.Rows != nil },
+		RenderFunc: func(w io.Writer, r *render.Result) error {
+			_, err := fmt.Fprintln(w, len(r.Rows))
+			return err
+		},
+	})
+	count, ok := render.Lookup("count")
+	Expect(ok).To(BeTrue())
+	Expect(rendered(count, tagsResult())).To(Equal("2\n"))
+	Expect(render.Names(&render.Result{Value: 1})).To(Equal([]string{"json"}))
+}
@@ -0,0 +1,248 @@
+// Package schedule parses cron expressions and daily windows of time, such
+// as quiet hours, in a time zone, for jobs run at set times.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron expression: the times whose minute, hour, day of the
+// month, month, and day of the week match its fields, in its time zone.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set for fields of "*", as a day then matches
+	// if the other field does; if neither is, a day matching either
+	// matches, as in cron.
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+// shorthands are the expressions named by "@".
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Parse parses a cron expression of five fields, "minute hour day-of-month
+// month day-of-week", each "*", a number, a range as in "1-5", a list of
+// them as in "1,15", or any of these with a step as in "*/15"; months and
+// days of the week may be named by their first three letters, and Sunday
+// is 0 or 7. The shorthands @hourly, @daily, @weekly, @monthly, and @yearly
+// are taken too. Times are in loc, or in the time zone named by a prefix of
+// "CRON_TZ=" or "TZ=", as in "CRON_TZ=Europe/Berlin 0 7 * * *".
+func Parse(expr string, loc *time.Location) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		_, name, _ := strings.Cut(fields[0], "=")
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q in %q", name, expr)
+		}
+		fields = fields[1:]
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		full, ok := shorthands[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown schedule %q", fields[0])
+		}
+		fields = strings.Fields(full)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q is not a cron expression of five fields: minute, hour, day of the month, month, and day of the week", expr)
+	}
+
+	s := &Schedule{loc: loc}
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if s.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of the month: %w", err)
+	}
+	if s.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if s.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("day of the week: %w", err)
+	}
+	// Sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = strings.HasPrefix(fields[2], "*")
+	s.dowAny = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parseField returns the bits of the values from min to max a field
+// matches. names, if any, name the values from min on.
+func parseField(field string, min, max int, names []string) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return min + i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%q is not a value from %d to %d", s, min, max)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		spec, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%q is not a step", stepText)
+			}
+		}
+
+		from, to := min, max
+		switch first, last, isRange := strings.Cut(spec, "-"); {
+		case spec == "*":
+		case isRange:
+			var err error
+			if from, err = value(first); err != nil {
+				return 0, err
+			}
+			if to, err = value(last); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, fmt.Errorf("%q is a range going backwards", spec)
+			}
+		default:
+			n, err := value(spec)
+			if err != nil {
+				return 0, err
+			}
+			// "5/15" goes from 5 to the end, as "5-59/15" does
+			from, to = n, n
+			if hasStep {
+				to = max
+			}
+		}
+		for n := from; n <= to; n += step {
+			bits |= 1 << n
+		}
+	}
+	return bits, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time of the schedule after t, or the zero time if
+// there is none in the next five years, as for "0 0 30 2 *".
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			// Set as a date, the hour skipped on changing to summer time
+			// is the next one
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Window is a span of each day, as "22:00-07:00" is from ten at night to
+// seven in the morning, in a time zone.
+type Window struct {
+	// start and end are minutes of the day.
+	start, end int
+	loc        *time.Location
+}
+
+// ParseWindow parses a window of the day given as "HH:MM-HH:MM", in loc,
+// or the local time zone if nil. A window ending before it starts goes on
+// past midnight.
+func ParseWindow(s string, loc *time.Location) (*Window, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	minutes := func(hhmm string) (int, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(hhmm))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a time of the day as in 07:30", hhmm)
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("%q is not a window of the day as in 22:00-07:00", s)
+	}
+	w := &Window{loc: loc}
+	var err error
+	if w.start, err = minutes(from); err != nil {
+		return nil, err
+	}
+	if w.end, err = minutes(to); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Contains reports whether t is in the window.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.loc)
+	m := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return w.start <= m && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// End returns when the window t is in ends, or t if it is not in it.
+func (w *Window) End(t time.Time) time.Time {
+	if !w.Contains(t) {
+		return t
+	}
+	t = t.In(w.loc)
+	end := time.Date(t.Year(), t.Month(), t.Day(), w.end/60, w.end%60, 0, 0, w.loc)
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
@@ -0,0 +1,101 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/motemen/go-pocket/schedule"
+	. "github.com/onsi/gomega"
+)
+
+func TestNext(t *testing.T) {
+	RegisterTestingT(t)
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	Expect(err).To(BeNil())
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	Expect(err).To(BeNil())
+	// A Wednesday
+	from := time.Date(2024, 1, 10, 8, 30, 20, 0, berlin)
+
+	for _, c := range []struct {
+		expr string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"*/15 * * * *", berlin, time.Date(2024, 1, 10, 8, 45, 0, 0, berlin)},
+		{"0 7 * * *", berlin, time.Date(2024, 1, 11, 7, 0, 0, 0, berlin)},
+		{"0 7 * * mon-fri", berlin, time.Date(2024, 1, 11, 7, 0, 0, 0, berlin)},
+		{"30 8 * * 3", berlin, time.Date(2024, 1, 17, 8, 30, 0, 0, berlin)},
+		{"0 9 * * Sun", berlin, time.Date(2024, 1, 14, 9, 0, 0, 0, berlin)},
+		{"0 9 * * 7", berlin, time.Date(2024, 1, 14, 9, 0, 0, 0, berlin)},
+		{"0 2-4/2 * * *", berlin, time.Date(2024, 1, 11, 2, 0, 0, 0, berlin)},
+		{"0 0 1,15 * *", berlin, time.Date(2024, 1, 15, 0, 0, 0, 0, berlin)},
+		{"0 0 29 feb *", berlin, time.Date(2024, 2, 29, 0, 0, 0, 0, berlin)},
+		// Either the day of the month or of the week, as both are given
+		{"0 0 20 * fri", berlin, time.Date(2024, 1, 12, 0, 0, 0, 0, berlin)},
+		{"@weekly", berlin, time.Date(2024, 1, 14, 0, 0, 0, 0, berlin)},
+		{"@hourly", berlin, time.Date(2024, 1, 10, 9, 0, 0, 0, berlin)},
+		// Half an hour off UTC
+		{"0 * * * *", kolkata, time.Date(2024, 1, 10, 14, 0, 0, 0, kolkata)},
+		{"CRON_TZ=Asia/Kolkata 0 7 * * *", berlin, time.Date(2024, 1, 11, 7, 0, 0, 0, kolkata)},
+		{"0 0 30 2 *", berlin, time.Time{}},
+	} {
+		s, err := schedule.Parse(c.expr, c.loc)
+		Expect(err).To(BeNil(), c.expr)
+		Expect(s.Next(from).Equal(c.want)).To(BeTrue(), "%s: %s", c.expr, s.Next(from))
+	}
+
+	// The hour skipped on changing to summer time
+	s, err := schedule.Parse("30 2 * * *", berlin)
+	Expect(err).To(BeNil())
+	next := s.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	Expect(next.Equal(time.Date(2024, 4, 1, 2, 30, 0, 0, berlin))).To(BeTrue(), next.String())
+	s, err = schedule.Parse("0 7 * * *", berlin)
+	Expect(err).To(BeNil())
+	next = s.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	Expect(next.Equal(time.Date(2024, 3, 31, 7, 0, 0, 0, berlin))).To(BeTrue(), next.String())
+	Expect(next.UTC().Hour()).To(Equal(5))
+}
+
+func TestParseErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	for expr, message := range map[string]string{
+		"* * * *":                        "five fields",
+		"60 * * * *":                     `minute: "60" is not a value from 0 to 59`,
+		"0 5-2 * * *":                    "going backwards",
+		"*/0 * * * *":                    "not a step",
+		"0 0 * foo *":                    "month",
+		"@sometimes":                     "unknown schedule",
+		"CRON_TZ=Mars/Olympus 0 * * * *": "unknown time zone",
+	} {
+		_, err := schedule.Parse(expr, nil)
+		Expect(err).To(MatchError(ContainSubstring(message)), expr)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	RegisterTestingT(t)
+
+	w, err := schedule.ParseWindow("22:00-07:00", time.UTC)
+	Expect(err).To(BeNil())
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 10, hour, minute, 0, 0, time.UTC) }
+	Expect(w.Contains(at(23, 0))).To(BeTrue())
+	Expect(w.Contains(at(6, 59))).To(BeTrue())
+	Expect(w.Contains(at(7, 0))).To(BeFalse())
+	Expect(w.Contains(at(12, 0))).To(BeFalse())
+	Expect(w.End(at(23, 0))).To(Equal(time.Date(2024, 1, 11, 7, 0, 0, 0, time.UTC)))
+	Expect(w.End(at(3, 0))).To(Equal(at(7, 0)))
+	Expect(w.End(at(12, 0))).To(Equal(at(12, 0)))
+
+	w, err = schedule.ParseWindow("12:30-13:30", time.UTC)
+	Expect(err).To(BeNil())
+	Expect(w.Contains(at(13, 0))).To(BeTrue())
+	Expect(w.Contains(at(14, 0))).To(BeFalse())
+
+	_, err = schedule.ParseWindow("22:00", nil)
+	Expect(err).To(MatchError(ContainSubstring("not a window")))
+	_, err = schedule.ParseWindow("22:00-25:00", nil)
+	Expect(err).To(MatchError(ContainSubstring("not a time of the day")))
+}
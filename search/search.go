@@ -0,0 +1,425 @@
+// Package search implements a small full-text index over Pocket items,
+// ranked with BM25 and supporting phrase queries and tag and domain facets.
+package search
+
+import (
+	"encoding/gob"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Field weights applied to term frequencies, so that matches in titles rank
+// above matches in body text.
+const (
+	titleWeight   = 3.0
+	tagWeight     = 2.0
+	excerptWeight = 1.0
+	textWeight    = 1.0
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// fieldGap separates the positions of different fields, so that phrases do
+// not match across fields.
+const fieldGap = 1000
+
+// Document is the indexed content of an item.
+type Document struct {
+	ID      int
+	Title   string
+	Excerpt string
+	// Text is the article text, if it has been fetched.
+	Text   string
+	Tags   []string
+	Domain string
+}
+
+// Posting records the occurrences of a term in one document.
+type Posting struct {
+	Doc       int
+	Positions []int
+	// Weight is the term frequency, weighted by field.
+	Weight float64
+}
+
+// DocInfo holds per-document data needed for ranking and facets.
+type DocInfo struct {
+	Length float64
+	Tags   []string
+	Domain string
+}
+
+// Index is an inverted index of documents. Its fields are exported only so
+// that it can be saved with encoding/gob.
+type Index struct {
+	Docs     map[int]DocInfo
+	Postings map[string][]Posting
+}
+
+// New creates an empty index.
+func New() *Index {
+	return &Index{
+		Docs:     map[int]DocInfo{},
+		Postings: map[string][]Posting{},
+	}
+}
+
+// Tokenize splits s into lowercase words.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Add indexes doc. Documents must be added only once; rebuild the index to
+// reflect changes.
+func (idx *Index) Add(doc Document) {
+	postings := map[string]*Posting{}
+	length := 0.0
+	pos := 0
+
+	fields := []struct {
+		text   string
+		weight float64
+	}{
+		{doc.Title, titleWeight},
+		{strings.Join(doc.Tags, " "), tagWeight},
+		{doc.Excerpt, excerptWeight},
+		{doc.Text, textWeight},
+	}
+	for _, field := range fields {
+		for _, term := range Tokenize(field.text) {
+			p, ok := postings[term]
+			if !ok {
+				p = &Posting{Doc: doc.ID}
+				postings[term] = p
+			}
+			p.Positions = append(p.Positions, pos)
+			p.Weight += field.weight
+			length += field.weight
+			pos++
+		}
+		pos += fieldGap
+	}
+
+	for term, p := range postings {
+		idx.Postings[term] = append(idx.Postings[term], *p)
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(doc.Domain), "www.")
+	idx.Docs[doc.ID] = DocInfo{Length: length, Tags: doc.Tags, Domain: domain}
+}
+
+// Save writes the index to w.
+func (idx *Index) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(idx)
+}
+
+// Load reads an index written by Save.
+func Load(r io.Reader) (*Index, error) {
+	idx := New()
+	err := gob.NewDecoder(r).Decode(idx)
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Query is a parsed search query. All terms and phrases must match.
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+	Tags    []string
+	Domains []string
+}
+
+// ParseQuery parses a query string. Words match anywhere, "quoted words"
+// must appear as a phrase, and tag:name and domain:name restrict the results
+// to items with the tag or from the domain.
+func ParseQuery(s string) Query {
+	q := Query{}
+
+	for i, part := range strings.Split(s, `"`) {
+		if i%2 == 1 {
+			if phrase := Tokenize(part); len(phrase) > 0 {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			switch {
+			case strings.HasPrefix(word, "tag:") && len(word) > 4:
+				q.Tags = append(q.Tags, word[4:])
+			case strings.HasPrefix(word, "domain:") && len(word) > 7:
+				q.Domains = append(q.Domains, strings.ToLower(word[7:]))
+			default:
+				q.Terms = append(q.Terms, Tokenize(word)...)
+			}
+		}
+	}
+
+	return q
+}
+
+// Result is a matching document with its relevance score.
+type Result struct {
+	ID    int
+	Score float64
+}
+
+// Facets counts the tags and domains among search results.
+type Facets struct {
+	Tags    map[string]int
+	Domains map[string]int
+}
+
+func (idx *Index) avgLength() float64 {
+	if len(idx.Docs) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, d := range idx.Docs {
+		total += d.Length
+	}
+	return total / float64(len(idx.Docs))
+}
+
+func (idx *Index) postingsFor(term string) map[int]Posting {
+	m := map[int]Posting{}
+	for _, p := range idx.Postings[term] {
+		m[p.Doc] = p
+	}
+	return m
+}
+
+func containsPhrase(postings []map[int]Posting, doc int) bool {
+	first := postings[0][doc]
+	for _, start := range first.Positions {
+		found := true
+		for i := 1; i < len(postings); i++ {
+			if !hasPosition(postings[i][doc].Positions, start+i) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPosition(positions []int, pos int) bool {
+	i := sort.SearchInts(positions, pos)
+	return i < len(positions) && positions[i] == pos
+}
+
+func matchesDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.TrimPrefix(d, "www.")
+		if domain != d && !strings.HasSuffix(domain, "."+d) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTags(docTags []string, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range docTags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns the documents matching q, best first, and the facets of the
+// results.
+func (idx *Index) Search(q Query) ([]Result, Facets) {
+	terms := append([]string{}, q.Terms...)
+	for _, phrase := range q.Phrases {
+		terms = append(terms, phrase...)
+	}
+
+	postings := map[string]map[int]Posting{}
+	for _, term := range terms {
+		postings[term] = idx.postingsFor(term)
+	}
+
+	// Candidates contain every term; with no terms, every document is one.
+	var candidates []int
+	if len(terms) == 0 {
+		for id := range idx.Docs {
+			candidates = append(candidates, id)
+		}
+	} else {
+		for id := range postings[terms[0]] {
+			ok := true
+			for _, term := range terms[1:] {
+				if _, found := postings[term][id]; !found {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				candidates = append(candidates, id)
+			}
+		}
+	}
+
+	n := float64(len(idx.Docs))
+	avg := idx.avgLength()
+	facets := Facets{Tags: map[string]int{}, Domains: map[string]int{}}
+	results := []Result{}
+	for _, id := range candidates {
+		info := idx.Docs[id]
+		if !hasTags(info.Tags, q.Tags) || !matchesDomain(info.Domain, q.Domains) {
+			continue
+		}
+
+		phrasesOK := true
+		for _, phrase := range q.Phrases {
+			ps := make([]map[int]Posting, len(phrase))
+			for i, term := range phrase {
+				ps[i] = postings[term]
+			}
+			if !containsPhrase(ps, id) {
+				phrasesOK = false
+				break
+			}
+		}
+		if !phrasesOK {
+			continue
+		}
+
+		score := 0.0
+		for _, term := range terms {
+			df := float64(len(postings[term]))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			tf := postings[term][id].Weight
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*info.Length/avg))
+		}
+
+		results = append(results, Result{ID: id, Score: score})
+		for _, tag := range info.Tags {
+			facets.Tags[tag]++
+		}
+		if info.Domain != "" {
+			facets.Domains[info.Domain]++
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	return results, facets
+}
+
+// Weights of the other likenesses of documents in Similar, next to the cosine
+// similarity of their words, which is at most 1.
+const (
+	sharedTagsWeight = 1.0
+	sameDomainWeight = 0.3
+)
+
+// Similar returns the documents most like the one with the given ID, best
+// first: those using the same uncommon words, weighted by field, sharing its
+// tags, and from its domain. Documents with nothing in common are left out.
+func (idx *Index) Similar(id int) []Result {
+	target, ok := idx.Docs[id]
+	if !ok {
+		return nil
+	}
+
+	n := float64(len(idx.Docs))
+	idf := func(df int) float64 {
+		return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	}
+
+	// The words of the document, weighted by TF-IDF, and the norms of the
+	// vectors of all documents
+	words := map[string]float64{}
+	norms := map[int]float64{}
+	for term, postings := range idx.Postings {
+		w := idf(len(postings))
+		for _, p := range postings {
+			norms[p.Doc] += (p.Weight * w) * (p.Weight * w)
+			if p.Doc == id {
+				words[term] = p.Weight * w
+			}
+		}
+	}
+
+	scores := map[int]float64{}
+	for term, weight := range words {
+		w := idf(len(idx.Postings[term]))
+		for _, p := range idx.Postings[term] {
+			if p.Doc != id {
+				scores[p.Doc] += weight * p.Weight * w
+			}
+		}
+	}
+	for doc, dot := range scores {
+		scores[doc] = dot / math.Sqrt(norms[id]*norms[doc])
+	}
+
+	for doc, info := range idx.Docs {
+		if doc == id {
+			continue
+		}
+		if shared := sharedTags(target.Tags, info.Tags); shared > 0 {
+			// The Jaccard index of the tags
+			scores[doc] += sharedTagsWeight * float64(shared) / float64(len(target.Tags)+len(info.Tags)-shared)
+		}
+		if target.Domain != "" && info.Domain == target.Domain {
+			scores[doc] += sameDomainWeight
+		}
+	}
+
+	results := []Result{}
+	for doc, score := range scores {
+		if score > 0 {
+			results = append(results, Result{ID: doc, Score: score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID
+	})
+	return results
+}
+
+// sharedTags returns the number of tags in both a and b.
+func sharedTags(a, b []string) int {
+	shared := 0
+	for _, tag := range a {
+		for _, t := range b {
+			if t == tag {
+				shared++
+				break
+			}
+		}
+	}
+	return shared
+}
@@ -0,0 +1,117 @@
+package search_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/motemen/go-pocket/search"
+	. "github.com/onsi/gomega"
+)
+
+func testIndex() *search.Index {
+	idx := search.New()
+	idx.Add(search.Document{
+		ID:      1,
+		Title:   "Learning Go",
+		Excerpt: "An introduction to the Go programming language.",
+		Tags:    []string{"golang", "books"},
+		Domain:  "www.example.com",
+	})
+	idx.Add(search.Document{
+		ID:      2,
+		Title:   "Rust for Go programmers",
+		Excerpt: "Programming language comparisons.",
+		Tags:    []string{"rust"},
+		Domain:  "blog.rust-lang.org",
+	})
+	idx.Add(search.Document{
+		ID:      3,
+		Title:   "Gardening",
+		Excerpt: "Language of flowers.",
+		Text:    "The programming of a garden takes patience.",
+		Domain:  "garden.example.com",
+	})
+	return idx
+}
+
+func ids(results []search.Result) []int {
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestParseQuery(t *testing.T) {
+	RegisterTestingT(t)
+
+	q := search.ParseQuery(`Go "programming language" tag:golang domain:Example.com`)
+	Expect(q.Terms).To(Equal([]string{"go"}))
+	Expect(q.Phrases).To(Equal([][]string{{"programming", "language"}}))
+	Expect(q.Tags).To(Equal([]string{"golang"}))
+	Expect(q.Domains).To(Equal([]string{"example.com"}))
+}
+
+func TestSearch(t *testing.T) {
+	RegisterTestingT(t)
+
+	idx := testIndex()
+
+	// Title matches rank first
+	results, facets := idx.Search(search.ParseQuery("go"))
+	Expect(ids(results)).To(Equal([]int{1, 2}))
+	Expect(facets.Tags).To(Equal(map[string]int{"golang": 1, "books": 1, "rust": 1}))
+	Expect(facets.Domains).To(Equal(map[string]int{"example.com": 1, "blog.rust-lang.org": 1}))
+
+	results, _ = idx.Search(search.ParseQuery("programming"))
+	Expect(results).To(HaveLen(3))
+
+	// Phrases must be contiguous and within one field
+	results, _ = idx.Search(search.ParseQuery(`"programming language"`))
+	Expect(ids(results)).To(ConsistOf(1, 2))
+
+	results, _ = idx.Search(search.ParseQuery(`"flowers the"`))
+	Expect(results).To(BeEmpty())
+
+	results, _ = idx.Search(search.ParseQuery("programming tag:rust"))
+	Expect(ids(results)).To(Equal([]int{2}))
+
+	results, _ = idx.Search(search.ParseQuery("domain:example.com"))
+	Expect(ids(results)).To(ConsistOf(1, 3))
+}
+
+func TestSaveLoad(t *testing.T) {
+	RegisterTestingT(t)
+
+	var buf bytes.Buffer
+	Expect(testIndex().Save(&buf)).To(Succeed())
+
+	idx, err := search.Load(&buf)
+	Expect(err).To(BeNil())
+
+	results, _ := idx.Search(search.ParseQuery("garden"))
+	Expect(ids(results)).To(Equal([]int{3}))
+}
+
+func TestSimilar(t *testing.T) {
+	RegisterTestingT(t)
+
+	idx := testIndex()
+	idx.Add(search.Document{
+		ID:      4,
+		Title:   "Go concurrency patterns",
+		Excerpt: "Channels and goroutines.",
+		Tags:    []string{"golang"},
+		Domain:  "example.com",
+	})
+	idx.Add(search.Document{
+		ID:     5,
+		Title:  "Sourdough",
+		Domain: "bread.example.net",
+	})
+
+	// Sharing a tag, a domain, and a word ranks above sharing words alone
+	Expect(ids(idx.Similar(4))).To(Equal([]int{1, 2}))
+	Expect(idx.Similar(5)).To(BeEmpty())
+	Expect(idx.Similar(99)).To(BeNil())
+}
@@ -0,0 +1,218 @@
+// Package snapshot turns web pages into single HTML files, with their
+// stylesheets and images inlined, for keeping them after they are gone.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// FetchFunc downloads the resource at url, returning its content and media
+// type.
+type FetchFunc func(url string) (data []byte, mediaType string, err error)
+
+// cssURLPattern finds the resources referred to by a stylesheet.
+var cssURLPattern = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// inliner inlines the resources of a page, fetching each once.
+type inliner struct {
+	fetch FetchFunc
+	cache map[string]string
+}
+
+// Inline makes a single file of page, an HTML document in the encoding given
+// by contentType, which was found at base. Stylesheets, images, and the
+// resources of stylesheets are fetched and inlined as data URLs; scripts,
+// frames, and resources that cannot be fetched are dropped, and links are
+// made absolute. The result is encoded in UTF-8.
+func Inline(page []byte, contentType string, base *url.URL, fetch FetchFunc) ([]byte, error) {
+	r, err := charset.NewReader(bytes.NewReader(page), contentType)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &inliner{fetch: fetch, cache: map[string]string{}}
+	if b := find(doc, atom.Base); b != nil {
+		if u, err := base.Parse(attr(b, "href")); err == nil {
+			base = u
+		}
+	}
+	in.walk(doc, base)
+
+	if head := find(doc, atom.Head); head != nil {
+		meta := &html.Node{Type: html.ElementNode, Data: "meta", DataAtom: atom.Meta, Attr: []html.Attribute{{Key: "charset", Val: "utf-8"}}}
+		head.InsertBefore(meta, head.FirstChild)
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (in *inliner) walk(n *html.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && in.element(c, base) {
+			in.walk(c, base)
+		} else if c.Type == html.ElementNode {
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// element inlines the resources of n, reporting whether it is kept.
+func (in *inliner) element(n *html.Node, base *url.URL) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Iframe, atom.Frame, atom.Object, atom.Embed, atom.Base:
+		return false
+	case atom.Meta:
+		// The page is written out in UTF-8, whatever it was in
+		return attr(n, "charset") == "" && !strings.EqualFold(attr(n, "http-equiv"), "content-type")
+	case atom.Link:
+		rel := strings.ToLower(attr(n, "rel"))
+		if !strings.Contains(rel, "stylesheet") {
+			return rel != "preload" && rel != "modulepreload" && rel != "prefetch"
+		}
+		css, ok := in.stylesheet(resolve(base, attr(n, "href")))
+		if !ok {
+			return false
+		}
+		n.Data, n.DataAtom, n.Attr = "style", atom.Style, mediaAttr(n)
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: css})
+		return true
+	case atom.Style:
+		if c := n.FirstChild; c != nil && c.Type == html.TextNode {
+			c.Data = in.css(c.Data, base)
+		}
+		return true
+	case atom.Img, atom.Source:
+		setAttr(n, "srcset", "")
+		if src := attr(n, "src"); src != "" {
+			data, ok := in.dataURL(resolve(base, src))
+			if !ok {
+				return n.DataAtom != atom.Img || attr(n, "alt") != ""
+			}
+			setAttr(n, "src", data)
+		}
+	case atom.A, atom.Area:
+		if href := attr(n, "href"); href != "" && !strings.HasPrefix(href, "#") {
+			setAttr(n, "href", resolve(base, href))
+		}
+	}
+
+	if style := attr(n, "style"); style != "" {
+		setAttr(n, "style", in.css(style, base))
+	}
+	return true
+}
+
+// stylesheet fetches the stylesheet at u, with its own resources inlined.
+func (in *inliner) stylesheet(u string) (string, bool) {
+	data, _, err := in.fetch(u)
+	if err != nil {
+		return "", false
+	}
+	base, err := url.Parse(u)
+	if err != nil {
+		return "", false
+	}
+	return in.css(string(data), base), true
+}
+
+// css inlines the resources referred to by url() in css.
+func (in *inliner) css(css string, base *url.URL) string {
+	return cssURLPattern.ReplaceAllStringFunc(css, func(m string) string {
+		ref := cssURLPattern.FindStringSubmatch(m)[2]
+		if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return m
+		}
+		u := resolve(base, ref)
+		if data, ok := in.dataURL(u); ok {
+			return `url("` + data + `")`
+		}
+		return `url("` + u + `")`
+	})
+}
+
+// dataURL fetches u as a data URL.
+func (in *inliner) dataURL(u string) (string, bool) {
+	if strings.HasPrefix(u, "data:") {
+		return u, true
+	}
+	if data, ok := in.cache[u]; ok {
+		return data, data != ""
+	}
+
+	data, mediaType, err := in.fetch(u)
+	if err != nil {
+		in.cache[u] = ""
+		return "", false
+	}
+	in.cache[u] = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	return in.cache[u], true
+}
+
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// setAttr sets an attribute of n, removing it if val is empty.
+func setAttr(n *html.Node, key, val string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	if val != "" {
+		attrs = append(attrs, html.Attribute{Key: key, Val: val})
+	}
+	n.Attr = attrs
+}
+
+// mediaAttr keeps the media query of a stylesheet link.
+func mediaAttr(n *html.Node) []html.Attribute {
+	if media := attr(n, "media"); media != "" {
+		return []html.Attribute{{Key: "media", Val: media}}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package snapshot_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/motemen/go-pocket/snapshot"
+	. "github.com/onsi/gomega"
+)
+
+const page = `<!DOCTYPE html>
+<html><head><meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">
+<title>Caf` + "\xe9" + `</title>
+<link rel="stylesheet" href="/style.css" media="screen">
+<link rel="stylesheet" href="/missing.css">
+<link rel="preload" href="/font.woff2">
+<script src="/app.js"></script>
+<style>h1 { background: url(bg.png) }</style>
+</head><body>
+<h1 style="border-image: url('/missing.png')">Hello</h1>
+<img src="img/a.png" srcset="img/a@2x.png 2x" alt="A">
+<img src="img/missing.png">
+<a href="../other">Other</a> <a href="#top">Top</a>
+<iframe src="https://ads.example.com/"></iframe>
+</body></html>`
+
+var resources = map[string]string{
+	"https://example.com/style.css":       "body { background: url(img/paper.png) }",
+	"https://example.com/img/paper.png":   "PAPER",
+	"https://example.com/posts/bg.png":    "BG",
+	"https://example.com/posts/img/a.png": "A",
+}
+
+func fetch(u string) ([]byte, string, error) {
+	data, ok := resources[u]
+	if !ok {
+		return nil, "", errors.New("not found")
+	}
+	return []byte(data), "image/png", nil
+}
+
+func TestInline(t *testing.T) {
+	RegisterTestingT(t)
+
+	base, _ := url.Parse("https://example.com/posts/1")
+	out, err := snapshot.Inline([]byte(page), "text/html", base, fetch)
+	Expect(err).To(BeNil())
+
+	html := string(out)
+	Expect(html).To(ContainSubstring(`<head><meta charset="utf-8"/>`))
+	Expect(html).To(ContainSubstring(`<title>Café</title>`))
+	Expect(html).NotTo(ContainSubstring("iso-8859-1"))
+	Expect(html).To(ContainSubstring(`<style media="screen">body { background: url("data:image/png;base64,UEFQRVI=") }</style>`))
+	Expect(html).NotTo(ContainSubstring("missing.css"))
+	Expect(html).NotTo(ContainSubstring("preload"))
+	Expect(html).NotTo(ContainSubstring("script"))
+	Expect(html).NotTo(ContainSubstring("iframe"))
+	Expect(html).To(ContainSubstring(`h1 { background: url("data:image/png;base64,Qkc=") }`))
+	Expect(html).To(ContainSubstring(`style="border-image: url(&#34;https://example.com/missing.png&#34;)"`))
+	Expect(html).To(ContainSubstring(`<img alt="A" src="data:image/png;base64,QQ=="/>`))
+	Expect(html).NotTo(ContainSubstring("img/missing.png"))
+	Expect(html).To(ContainSubstring(`<a href="https://example.com/other">`))
+	Expect(html).To(ContainSubstring(`<a href="#top">`))
+}
@@ -0,0 +1,216 @@
+package tabs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"unicode/utf16"
+)
+
+// The commands of a Chrome session file that are read, out of those
+// Chrome appends to it as tabs open, navigate, and close.
+const (
+	chromeSetTabWindow               = 0
+	chromeSetTabIndexInWindow        = 2
+	chromeUpdateTabNavigation        = 6
+	chromeSetSelectedNavigationIndex = 7
+	chromeTabClosed                  = 16
+	chromeWindowClosed               = 17
+)
+
+// chromeSessionFile returns the session file of profile, or of the profile
+// used last, of Chrome or Chromium.
+func chromeSessionFile(browser, profile string) (string, error) {
+	dirs := []string{profile}
+	if profile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		var roots []string
+		switch {
+		case runtime.GOOS == "darwin" && browser == "chrome":
+			roots = []string{filepath.Join(home, "Library", "Application Support", "Google", "Chrome")}
+		case runtime.GOOS == "darwin":
+			roots = []string{filepath.Join(home, "Library", "Application Support", "Chromium")}
+		case runtime.GOOS == "windows" && browser == "chrome":
+			roots = []string{filepath.Join(os.Getenv("LOCALAPPDATA"), "Google", "Chrome", "User Data")}
+		case runtime.GOOS == "windows":
+			roots = []string{filepath.Join(os.Getenv("LOCALAPPDATA"), "Chromium", "User Data")}
+		case browser == "chrome":
+			roots = []string{filepath.Join(home, ".config", "google-chrome")}
+		default:
+			roots = []string{
+				filepath.Join(home, ".config", "chromium"),
+				filepath.Join(home, "snap", "chromium", "common", "chromium"),
+			}
+		}
+		dirs = nil
+		for _, root := range roots {
+			dirs = append(dirs, profileDirs(root, "Preferences")...)
+		}
+	}
+
+	// Chrome writes Sessions/Session_<time> since version 100, and
+	// "Current Session" before
+	files := []string{}
+	for _, dir := range dirs {
+		sessions, _ := filepath.Glob(filepath.Join(dir, "Sessions", "Session_*"))
+		files = append(files, sessions...)
+		files = append(files, filepath.Join(dir, "Current Session"))
+	}
+	file, err := newest(files)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", browser, err)
+	}
+	return file, nil
+}
+
+// chromeTab is a tab as the commands of a session file leave it.
+type chromeTab struct {
+	window, index, order int
+	// navigations are the pages of its history, by their index.
+	navigations map[int32]Tab
+	selected    int32
+}
+
+// readChromeSession replays the commands of a Chrome session file, in the
+// SNSS format: "SNSS", its version, then commands of a 16-bit size, an 8-bit
+// ID, and their payload.
+func readChromeSession(file string) ([]Tab, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 8 || !bytes.HasPrefix(b, []byte("SNSS")) {
+		return nil, errors.New("not a Chrome session file")
+	}
+	if version := binary.LittleEndian.Uint32(b[4:]); version != 1 && version != 3 {
+		return nil, fmt.Errorf("unsupported session file version %d", version)
+	}
+
+	tabs := map[int32]*chromeTab{}
+	tab := func(id int32) *chromeTab {
+		if tabs[id] == nil {
+			tabs[id] = &chromeTab{order: len(tabs), navigations: map[int32]Tab{}, selected: -1}
+		}
+		return tabs[id]
+	}
+	windows := map[int32]int{}
+	window := func(id int32) int {
+		if _, ok := windows[id]; !ok {
+			windows[id] = len(windows)
+		}
+		return windows[id]
+	}
+	closedWindows := map[int]bool{}
+
+	for rest := b[8:]; len(rest) >= 3; {
+		size := int(binary.LittleEndian.Uint16(rest))
+		if size == 0 || 2+size > len(rest) {
+			// Cut short as Chrome was writing it
+			break
+		}
+		id, payload := rest[2], rest[3:2+size]
+		rest = rest[2+size:]
+
+		switch id {
+		case chromeSetTabWindow:
+			if len(payload) >= 8 {
+				tab(int32le(payload[4:])).window = window(int32le(payload))
+			}
+		case chromeSetTabIndexInWindow:
+			if len(payload) >= 8 {
+				tab(int32le(payload)).index = int(int32le(payload[4:]))
+			}
+		case chromeUpdateTabNavigation:
+			tabID, index, nav, ok := parseNavigation(payload)
+			if ok {
+				tab(tabID).navigations[index] = nav
+			}
+		case chromeSetSelectedNavigationIndex:
+			if len(payload) >= 8 {
+				tab(int32le(payload)).selected = int32le(payload[4:])
+			}
+		case chromeTabClosed:
+			if len(payload) >= 4 {
+				delete(tabs, int32le(payload))
+			}
+		case chromeWindowClosed:
+			if len(payload) >= 4 {
+				closedWindows[window(int32le(payload))] = true
+			}
+		}
+	}
+
+	open := []*chromeTab{}
+	for _, t := range tabs {
+		if !closedWindows[t.window] && len(t.navigations) > 0 {
+			open = append(open, t)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool {
+		a, b := open[i], open[j]
+		if a.window != b.window {
+			return a.window < b.window
+		}
+		if a.index != b.index {
+			return a.index < b.index
+		}
+		return a.order < b.order
+	})
+
+	result := []Tab{}
+	for _, t := range open {
+		nav, ok := t.navigations[t.selected]
+		if !ok {
+			// The page navigated to last
+			last := int32(-1)
+			for index := range t.navigations {
+				last = max(last, index)
+			}
+			nav = t.navigations[last]
+		}
+		result = append(result, nav)
+	}
+	return result, nil
+}
+
+func int32le(b []byte) int32 {
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+// parseNavigation parses the pickle of an UpdateTabNavigation command: its
+// payload size, the tab ID, the navigation index, the URL as UTF-8 and the
+// title as UTF-16, each after its length and padded to 4 bytes, and more.
+func parseNavigation(p []byte) (tabID, index int32, nav Tab, ok bool) {
+	if len(p) < 16 {
+		return 0, 0, Tab{}, false
+	}
+	tabID, index = int32le(p[4:]), int32le(p[8:])
+	p = p[12:]
+
+	n := int(int32le(p))
+	if n < 0 || 4+n > len(p) {
+		return 0, 0, Tab{}, false
+	}
+	nav.URL = string(p[4 : 4+n])
+	p = p[min(len(p), 4+(n+3)/4*4):]
+
+	if len(p) >= 4 {
+		n := int(int32le(p))
+		if n >= 0 && 4+2*n <= len(p) {
+			title := make([]uint16, n)
+			for i := range title {
+				title[i] = binary.LittleEndian.Uint16(p[4+2*i:])
+			}
+			nav.Title = string(utf16.Decode(title))
+		}
+	}
+	return tabID, index, nav, true
+}
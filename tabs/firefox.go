@@ -0,0 +1,180 @@
+package tabs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// mozLz4Magic starts the files Firefox compresses with LZ4.
+var mozLz4Magic = []byte("mozLz40\x00")
+
+// firefoxSessionFile returns the session file of profile, or of the profile
+// used last, that Firefox writes while running, or when it quits.
+func firefoxSessionFile(profile string) (string, error) {
+	dirs := []string{profile}
+	if profile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		var roots []string
+		switch runtime.GOOS {
+		case "darwin":
+			roots = []string{filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles")}
+		case "windows":
+			roots = []string{filepath.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox", "Profiles")}
+		default:
+			roots = []string{
+				filepath.Join(home, ".mozilla", "firefox"),
+				filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox"),
+				filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+			}
+		}
+		dirs = nil
+		for _, root := range roots {
+			dirs = append(dirs, profileDirs(root, "prefs.js")...)
+		}
+	}
+
+	files := []string{}
+	for _, dir := range dirs {
+		files = append(files,
+			filepath.Join(dir, "sessionstore-backups", "recovery.jsonlz4"),
+			filepath.Join(dir, "sessionstore.jsonlz4"),
+		)
+	}
+	file, err := newest(files)
+	if err != nil {
+		return "", fmt.Errorf("firefox: %w", err)
+	}
+	return file, nil
+}
+
+// firefoxSession is the part of a Firefox session that is read.
+type firefoxSession struct {
+	Windows []struct {
+		Tabs []struct {
+			Entries []struct {
+				URL   string `json:"url"`
+				Title string `json:"title"`
+			} `json:"entries"`
+			// Index is the entry shown, counting from 1.
+			Index int `json:"index"`
+		} `json:"tabs"`
+	} `json:"windows"`
+}
+
+func readFirefoxSession(file string) ([]Tab, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err = decodeMozLz4(b)
+	if err != nil {
+		return nil, err
+	}
+	var session firefoxSession
+	if err := json.Unmarshal(b, &session); err != nil {
+		return nil, err
+	}
+
+	tabs := []Tab{}
+	for _, w := range session.Windows {
+		for _, t := range w.Tabs {
+			if len(t.Entries) == 0 {
+				continue
+			}
+			i := t.Index - 1
+			if i < 0 || i >= len(t.Entries) {
+				i = len(t.Entries) - 1
+			}
+			tabs = append(tabs, Tab{URL: t.Entries[i].URL, Title: t.Entries[i].Title})
+		}
+	}
+	return tabs, nil
+}
+
+// decodeMozLz4 decompresses a file of Firefox compressed with LZ4: the
+// magic, the size decompressed, and an LZ4 block.
+func decodeMozLz4(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, mozLz4Magic) || len(b) < len(mozLz4Magic)+4 {
+		return nil, errors.New("not a mozLz4 file")
+	}
+	size := binary.LittleEndian.Uint32(b[len(mozLz4Magic):])
+	return decodeLZ4Block(b[len(mozLz4Magic)+4:], int(size))
+}
+
+// decodeLZ4Block decompresses an LZ4 block of size bytes decompressed:
+// sequences of literals copied as they are and of matches copying what was
+// decompressed before.
+func decodeLZ4Block(src []byte, size int) ([]byte, error) {
+	errCorrupt := errors.New("corrupt LZ4 block")
+	dst := make([]byte, 0, size)
+
+	// length reads a length continued in bytes of 255 after its nibble.
+	length := func(i, n int) (int, int, error) {
+		if n != 15 {
+			return n, i, nil
+		}
+		for {
+			if i >= len(src) {
+				return 0, i, errCorrupt
+			}
+			b := src[i]
+			i++
+			n += int(b)
+			if b != 255 {
+				return n, i, nil
+			}
+		}
+	}
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		literals, i2, err := length(i, int(token>>4))
+		if err != nil {
+			return nil, err
+		}
+		i = i2
+		if i+literals > len(src) || len(dst)+literals > size {
+			return nil, errCorrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// The last sequence has no match
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errCorrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		matchLen, i2, err := length(i, int(token&15))
+		if err != nil {
+			return nil, err
+		}
+		i = i2
+		matchLen += 4
+		if offset == 0 || offset > len(dst) || len(dst)+matchLen > size {
+			return nil, errCorrupt
+		}
+		// Byte by byte, as the match may overlap what it copies
+		start := len(dst) - offset
+		for j := 0; j < matchLen; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	if len(dst) != size {
+		return nil, errCorrupt
+	}
+	return dst, nil
+}
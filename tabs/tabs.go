@@ -0,0 +1,99 @@
+// Package tabs reads the tabs open in Firefox or Chrome from the session
+// files the browsers keep in their profiles, even while they are running.
+package tabs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tab is a tab open in a browser, at the page it shows.
+type Tab struct {
+	URL   string
+	Title string
+}
+
+// Browsers are the browsers whose tabs are read.
+var Browsers = []string{"firefox", "chrome", "chromium"}
+
+// Open returns the web pages open in the tabs of browser, "firefox",
+// "chrome", or "chromium", read from profile, the directory of a profile,
+// or from the one used last if it is empty. Tabs showing other than http
+// and https pages are left out, as are those showing a page twice.
+func Open(browser, profile string) ([]Tab, error) {
+	var (
+		file string
+		read func(file string) ([]Tab, error)
+		err  error
+	)
+	switch browser {
+	case "firefox":
+		file, err = firefoxSessionFile(profile)
+		read = readFirefoxSession
+	case "chrome", "chromium":
+		file, err = chromeSessionFile(browser, profile)
+		read = readChromeSession
+	default:
+		return nil, fmt.Errorf(`unknown browser %q; use "firefox", "chrome", or "chromium"`, browser)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	tabs := []Tab{}
+	seen := map[string]bool{}
+	for _, tab := range all {
+		if !strings.HasPrefix(tab.URL, "http://") && !strings.HasPrefix(tab.URL, "https://") || seen[tab.URL] {
+			continue
+		}
+		seen[tab.URL] = true
+		tabs = append(tabs, tab)
+	}
+	return tabs, nil
+}
+
+// newest returns the file most recently written among files, skipping those
+// missing.
+func newest(files []string) (string, error) {
+	found := ""
+	var modTime int64
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		if t := fi.ModTime().UnixNano(); found == "" || t > modTime {
+			found, modTime = file, t
+		}
+	}
+	if found == "" {
+		return "", errors.New("no session file found")
+	}
+	return found, nil
+}
+
+// profileDirs returns the directories in dir, or dir itself if it is a
+// profile, one having a file named marker.
+func profileDirs(dir, marker string) []string {
+	if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+		return []string{dir}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	dirs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs
+}
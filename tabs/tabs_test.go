@@ -0,0 +1,217 @@
+package tabs_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/motemen/go-pocket/tabs"
+	. "github.com/onsi/gomega"
+)
+
+// mozLz4 compresses data as Firefox does, finding matches of what came
+// before as LZ4 does, if less thoroughly.
+func mozLz4(data []byte) []byte {
+	var block []byte
+	length := func(n int) {
+		for ; n >= 255; n -= 255 {
+			block = append(block, 255)
+		}
+		block = append(block, byte(n))
+	}
+	sequence := func(literals []byte, offset, matchLen int) {
+		token := byte(min(len(literals), 15)) << 4
+		if offset > 0 {
+			token |= byte(min(matchLen-4, 15))
+		}
+		block = append(block, token)
+		if len(literals) >= 15 {
+			length(len(literals) - 15)
+		}
+		block = append(block, literals...)
+		if offset > 0 {
+			block = append(block, byte(offset), byte(offset>>8))
+			if matchLen-4 >= 15 {
+				length(matchLen - 4 - 15)
+			}
+		}
+	}
+
+	// The last five bytes are left as literals, as LZ4 requires
+	seen := map[string]int{}
+	anchor := 0
+	for i := 0; i+4 <= len(data)-5; {
+		j, ok := seen[string(data[i:i+4])]
+		seen[string(data[i:i+4])] = i
+		if !ok || i-j > 65535 {
+			i++
+			continue
+		}
+		n := 4
+		for i+n < len(data)-5 && data[j+n] == data[i+n] {
+			n++
+		}
+		sequence(data[anchor:i], i-j, n)
+		i += n
+		anchor = i
+	}
+	sequence(data[anchor:], 0, 0)
+
+	header := append([]byte("mozLz40\x00"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(data)))
+	return append(header, block...)
+}
+
+// snss writes the commands of a Chrome session file.
+type snss struct{ bytes.Buffer }
+
+func newSNSS() *snss {
+	s := &snss{}
+	s.WriteString("SNSS")
+	binary.Write(s, binary.LittleEndian, int32(3))
+	return s
+}
+
+func (s *snss) command(id byte, payload []byte) {
+	binary.Write(s, binary.LittleEndian, uint16(len(payload)+1))
+	s.WriteByte(id)
+	s.Write(payload)
+}
+
+func (s *snss) ints(id byte, values ...int32) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, values)
+	s.command(id, b.Bytes())
+}
+
+// navigation writes an UpdateTabNavigation command, as a pickle.
+func (s *snss) navigation(tab, index int32, url, title string) {
+	var b bytes.Buffer
+	pad := func() {
+		for b.Len()%4 != 0 {
+			b.WriteByte(0)
+		}
+	}
+	binary.Write(&b, binary.LittleEndian, []int32{0, tab, index, int32(len(url))})
+	b.WriteString(url)
+	pad()
+	title16 := utf16.Encode([]rune(title))
+	binary.Write(&b, binary.LittleEndian, int32(len(title16)))
+	binary.Write(&b, binary.LittleEndian, title16)
+	pad()
+	// The state, the transition type, and more Chrome keeps
+	binary.Write(&b, binary.LittleEndian, []int32{0, 0})
+	pickle := b.Bytes()
+	binary.LittleEndian.PutUint32(pickle, uint32(len(pickle)-4))
+	s.command(6, pickle)
+}
+
+func TestFirefox(t *testing.T) {
+	RegisterTestingT(t)
+
+	session := map[string]any{
+		"windows": []any{
+			map[string]any{"tabs": []any{
+				map[string]any{"index": 2, "entries": []any{
+					map[string]any{"url": "https://example.com/", "title": "Example"},
+					map[string]any{"url": "https://go.dev/blog/intro", "title": "The Go Blog"},
+				}},
+				map[string]any{"index": 1, "entries": []any{map[string]any{"url": "about:preferences", "title": "Settings"}}},
+				map[string]any{"index": 1, "entries": []any{map[string]any{"url": "https://go.dev/blog/intro", "title": "The Go Blog"}}},
+			}},
+			map[string]any{"tabs": []any{
+				map[string]any{"index": 1, "entries": []any{map[string]any{"url": "https://example.org/ünïcode", "title": "Ünïcode, ünïcode, ünïcode"}}},
+			}},
+		},
+	}
+	b, err := json.Marshal(session)
+	Expect(err).To(BeNil())
+
+	profile := t.TempDir()
+	Expect(os.MkdirAll(filepath.Join(profile, "sessionstore-backups"), 0700)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(profile, "sessionstore-backups", "recovery.jsonlz4"), mozLz4(b), 0600)).To(Succeed())
+	// Left when Firefox quit before
+	Expect(os.WriteFile(filepath.Join(profile, "sessionstore.jsonlz4"), []byte("mozLz40\x00"), 0600)).To(Succeed())
+	old := time.Now().Add(-time.Hour)
+	Expect(os.Chtimes(filepath.Join(profile, "sessionstore.jsonlz4"), old, old)).To(Succeed())
+
+	Expect(tabs.Open("firefox", profile)).To(Equal([]tabs.Tab{
+		{URL: "https://go.dev/blog/intro", Title: "The Go Blog"},
+		{URL: "https://example.org/ünïcode", Title: "Ünïcode, ünïcode, ünïcode"},
+	}))
+
+	Expect(os.WriteFile(filepath.Join(profile, "sessionstore-backups", "recovery.jsonlz4"), mozLz4(b)[:40], 0600)).To(Succeed())
+	_, err = tabs.Open("firefox", profile)
+	Expect(err).To(MatchError(ContainSubstring("corrupt LZ4 block")))
+}
+
+func TestChrome(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := newSNSS()
+	s.ints(0, 1, 10)
+	s.ints(2, 10, 1)
+	s.navigation(10, 0, "https://example.com/", "Example")
+	s.navigation(10, 1, "https://go.dev/blog/intro", "The Go Blog")
+	s.ints(7, 10, 1)
+	s.ints(0, 1, 11)
+	s.ints(2, 11, 0)
+	s.navigation(11, 0, "https://example.org/", "Example — 例")
+	// Closed, as is the window of the last
+	s.ints(0, 1, 12)
+	s.navigation(12, 0, "https://example.net/closed", "Closed")
+	s.ints(16, 12, 0, 0)
+	s.ints(0, 2, 13)
+	s.navigation(13, 0, "https://example.net/window", "Closed window")
+	s.ints(17, 2, 0, 0)
+	// A command cut short
+	s.Write([]byte{200, 0, 6})
+
+	profile := t.TempDir()
+	Expect(os.MkdirAll(filepath.Join(profile, "Sessions"), 0700)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(profile, "Sessions", "Session_13350000000000000"), s.Bytes(), 0600)).To(Succeed())
+
+	Expect(tabs.Open("chrome", profile)).To(Equal([]tabs.Tab{
+		{URL: "https://example.org/", Title: "Example — 例"},
+		{URL: "https://go.dev/blog/intro", Title: "The Go Blog"},
+	}))
+
+	_, err := tabs.Open("chrome", t.TempDir())
+	Expect(err).To(MatchError("chrome: no session file found"))
+	_, err = tabs.Open("safari", "")
+	Expect(err).To(MatchError(ContainSubstring(`unknown browser "safari"`)))
+}
+
+func TestOpenDefaultProfile(t *testing.T) {
+	RegisterTestingT(t)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", filepath.Join(home, "AppData", "Roaming"))
+	root := filepath.Join(home, ".mozilla", "firefox")
+	switch runtime.GOOS {
+	case "windows":
+		root = filepath.Join(home, "AppData", "Roaming", "Mozilla", "Firefox", "Profiles")
+	case "darwin":
+		root = filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles")
+	}
+
+	b := []byte(`{"windows": [{"tabs": [{"index": 1, "entries": [{"url": "https://example.com/", "title": "Example"}]}]}]}`)
+	for _, name := range []string{"old.default", "abc.default-release"} {
+		dir := filepath.Join(root, name)
+		Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "prefs.js"), nil, 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "sessionstore.jsonlz4"), mozLz4(b), 0600)).To(Succeed())
+		b = bytes.ReplaceAll(b, []byte("example.com"), []byte("example.org"))
+	}
+	old := time.Now().Add(-time.Hour)
+	Expect(os.Chtimes(filepath.Join(root, "old.default", "sessionstore.jsonlz4"), old, old)).To(Succeed())
+
+	Expect(tabs.Open("firefox", "")).To(Equal([]tabs.Tab{{URL: "https://example.org/", Title: "Example"}}))
+}
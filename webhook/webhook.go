@@ -0,0 +1,145 @@
+// Package webhook delivers library events as signed JSON POST requests.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, as
+// "sha256=<hex>", for hooks with a secret.
+const SignatureHeader = "X-Pocket-Signature"
+
+// Hook is a URL to deliver events to.
+type Hook struct {
+	URL string `json:"url"`
+	// Secret, if set, is used to sign the requests.
+	Secret string `json:"secret"`
+	// Events lists the events to deliver, such as "added", "archived", or
+	// "deleted". If empty, every event is delivered.
+	Events []string `json:"events"`
+}
+
+// Wants reports whether the hook subscribes to event.
+func (h Hook) Wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the body of a webhook request.
+type Payload struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// Sign returns the value of SignatureHeader for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender delivers events to a set of hooks.
+type Sender struct {
+	Hooks  []Hook
+	Client *http.Client
+
+	// Attempts is the number of times a delivery is tried before giving up.
+	// Requests are retried on network errors and 5xx responses.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after that.
+	Backoff time.Duration
+}
+
+// NewSender creates a sender trying each delivery three times.
+func NewSender(hooks []Hook) *Sender {
+	return &Sender{
+		Hooks:    hooks,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		Attempts: 3,
+		Backoff:  time.Second,
+	}
+}
+
+// Send delivers event with data to every hook subscribed to it. All hooks
+// are tried even if some fail; the returned error describes the failures.
+func (s *Sender) Send(event string, data interface{}) error {
+	body, err := json.Marshal(Payload{Event: event, Time: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	for _, hook := range s.Hooks {
+		if !hook.Wants(event) {
+			continue
+		}
+		err := s.deliver(hook, body)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", hook.URL, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("webhook delivery failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (s *Sender) deliver(hook Hook, body []byte) error {
+	wait := s.Backoff
+	var err error
+	for attempt := 0; attempt < s.Attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		var retry bool
+		retry, err = s.post(hook, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// post sends one request, reporting whether a failure is worth retrying.
+func (s *Sender) post(hook Hook, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-pocket-webhook")
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("got response %d", resp.StatusCode)
+	}
+	return false, nil
+}
@@ -0,0 +1,66 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/motemen/go-pocket/webhook"
+	. "github.com/onsi/gomega"
+)
+
+func TestSend(t *testing.T) {
+	RegisterTestingT(t)
+
+	requests := 0
+	var payload webhook.Payload
+	var signature string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+		Expect(signature).To(Equal(webhook.Sign("s3cret", body)))
+		json.Unmarshal(body, &payload)
+	}))
+	defer ts.Close()
+
+	sender := webhook.NewSender([]webhook.Hook{
+		{URL: ts.URL, Secret: "s3cret", Events: []string{"added"}},
+	})
+	sender.Backoff = 0
+
+	// Not subscribed
+	Expect(sender.Send("deleted", nil)).To(Succeed())
+	Expect(requests).To(Equal(0))
+
+	// Retried after the 503
+	Expect(sender.Send("added", map[string]int{"item_id": 1})).To(Succeed())
+	Expect(requests).To(Equal(2))
+	Expect(payload.Event).To(Equal("added"))
+	Expect(payload.Data).To(Equal(map[string]interface{}{"item_id": float64(1)}))
+	Expect(signature).To(HavePrefix("sha256="))
+}
+
+func TestSendFailure(t *testing.T) {
+	RegisterTestingT(t)
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	sender := webhook.NewSender([]webhook.Hook{{URL: ts.URL}})
+	sender.Backoff = 0
+
+	// Client errors are not retried
+	Expect(sender.Send("archived", nil)).NotTo(Succeed())
+	Expect(requests).To(Equal(1))
+}